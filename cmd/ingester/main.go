@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -19,11 +20,27 @@ import (
 	"github.com/AIAleph/mvp_wallet_context/internal/eth"
 	"github.com/AIAleph/mvp_wallet_context/internal/ingest"
 	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+	"github.com/AIAleph/mvp_wallet_context/internal/shutdown"
+	"github.com/AIAleph/mvp_wallet_context/pkg/ch"
 )
 
 const (
 	defaultMaxBatchBlocks = 20000
 	defaultMaxRateLimit   = 200
+	// Exit codes beyond 0 (ok), 1 (error) and 2 (usage) report states a
+	// scheduler can act on.
+	// exitCapReached tells a scheduler that --max-blocks stopped the run
+	// after checkpointing and it should be re-invoked.
+	exitCapReached = 3
+	// exitLocked means another run holds the address's --run-lock; nothing
+	// was ingested and the run can be retried once it finishes.
+	exitLocked = 4
+	// exitUpToDate means a delta found no new confirmed blocks.
+	exitUpToDate = 5
+	// exitBudgetExhausted tells a scheduler that --max-rpc-calls stopped the
+	// run after checkpointing the ranges it completed.
+	exitBudgetExhausted = 6
 )
 
 var (
@@ -42,7 +59,9 @@ var (
 		Backfill(context.Context) error
 		Delta(context.Context) error
 	}
-	newProvider func(endpoint string, rate int, retries int, backoff time.Duration) (eth.Provider, error)
+	newProvider func(endpoint string, rate int, retries int, backoff time.Duration, opts ...eth.ProviderOption) (eth.Provider, error)
+	// pollAfter waits between delta polls; tests shorten it.
+	pollAfter = time.After
 )
 
 // default wiring functions kept separate for full coverage and testability.
@@ -62,8 +81,8 @@ func defaultNewIngestWithProvider(address string, opts ingest.Options, p eth.Pro
 	return ingest.NewWithProvider(address, opts, p)
 }
 
-func defaultNewProvider(endpoint string, rate int, retries int, backoff time.Duration) (eth.Provider, error) {
-	return eth.NewProvider(endpoint, rate, retries, backoff)
+func defaultNewProvider(endpoint string, rate int, retries int, backoff time.Duration, opts ...eth.ProviderOption) (eth.Provider, error) {
+	return eth.NewProvider(endpoint, rate, retries, backoff, opts...)
 }
 
 func wireDefaults() {
@@ -149,9 +168,50 @@ func hasInsecurePassword(dsn string) bool {
 	return false
 }
 
+// logLatencySummary emits one structured rpc_latency record per JSON-RPC
+// method so capacity dashboards can chart p50/p95/p99 per provider call.
+func logLatencySummary(rec *eth.LatencyRecorder, provider string) {
+	logger := logging.Logger()
+	for _, s := range rec.Snapshot() {
+		logger.Info("rpc_latency",
+			"component", "cmd.ingester",
+			"provider", provider,
+			"method", s.Method,
+			"count", s.Count,
+			"p50_us", s.P50.Microseconds(),
+			"p95_us", s.P95.Microseconds(),
+			"p99_us", s.P99.Microseconds(),
+		)
+	}
+}
+
+// logRunSummary emits a single run_summary record with run-level conditions
+// (e.g. missing trace support, balance discrepancies) so degraded runs are
+// visible after the fact.
+// Healthy runs log nothing.
+func logRunSummary(s ingest.RunSummary) {
+	if s == (ingest.RunSummary{}) {
+		return
+	}
+	logging.Logger().Warn("run_summary",
+		"component", "cmd.ingester",
+		"traces_unavailable", s.TracesUnavailable,
+		"balance_discrepancies", s.BalanceDiscrepancies,
+	)
+}
+
+// providerHost returns only the host of the RPC URL so API keys embedded in
+// the path never reach the logs.
+func providerHost(endpoint string) string {
+	if u, err := url.Parse(strings.TrimSpace(endpoint)); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return ""
+}
+
 // printUsage prints a detailed CLI help with env mappings and examples.
 func printUsage() {
-	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "\nUsage:\n  %s --address 0x... [--mode backfill|delta] [flags]\n\n", os.Args[0])
+	_, _ = fmt.Fprintf(flag.CommandLine.Output(), "\nUsage:\n  %s --address 0x... [--mode backfill|delta|prune|bench|diff|discover] [flags]\n\n", os.Args[0])
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Flags:")
 	flag.PrintDefaults()
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "\nEnvironment variables (defaults):")
@@ -161,6 +221,7 @@ func printUsage() {
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  CLICKHOUSE_DB      ClickHouse database name")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  CLICKHOUSE_USER    ClickHouse username (optional)")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  CLICKHOUSE_PASS    ClickHouse password (optional)")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  *_FILE             Read ETH_PROVIDER_URL/CLICKHOUSE_DSN/CLICKHOUSE_PASS from a file")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  SYNC_CONFIRMATIONS Confirmations for delta (default 12)")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  BATCH_BLOCKS       Block batch size (default 5000)")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  RATE_LIMIT         RPC rate limit (req/s, default 0 = unlimited)")
@@ -169,22 +230,37 @@ func printUsage() {
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  REDIS_URL          Redis connection URL (optional)")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  EMBEDDING_MODEL    Embedding model identifier (optional)")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  INGEST_TIMEOUT     Request timeout (default 30s)")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "\nExit codes:")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  0 ok, 1 error, 2 usage, 3 cap reached (--max-blocks), 4 address locked (--run-lock), 5 up-to-date (delta), 6 RPC call budget exhausted (--max-rpc-calls)")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "\nExamples:")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  Ingest full history for an address:")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "    ingester --address 0xabc... --mode backfill --provider $ETH_PROVIDER_URL")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  Delta update with 12 confirmations:")
 	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "    ingester --address 0xabc... --mode delta --confirmations 12")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  Delete rows more than 100000 blocks behind the checkpoint (omit --yes to preview):")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "    ingester --address 0xabc... --mode prune --retain-blocks 100000 --yes")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  Measure throughput over the last 500 blocks before a backfill:")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "    ingester --address 0xabc... --mode bench --bench-blocks 500 --provider $ETH_PROVIDER_URL")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  Preview what re-ingesting a range would change, without writing:")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "    ingester --address 0xabc... --mode diff --from-block 18000000 --to-block 18010000")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  Scan from genesis with adaptive batches and periodic checkpoints (re-run to resume):")
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), "    ingester --address 0xabc... --mode discover --provider $ETH_PROVIDER_URL")
 }
 
 // MVP ingester entrypoint. Offers helpful flags, env fallbacks, and validation.
 func main() {
 	configureLogging()
 	// Load centralized defaults from env.
-	defaults := cfgpkg.Load()
+	defaults, err := cfgpkg.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(2)
+	}
 	var (
 		address        string
 		mode           string
 		fromBlock      uint64
+		forceFrom      uint64
 		toBlock        uint64
 		schemaMode     string
 		confirmations  int
@@ -192,28 +268,169 @@ func main() {
 		providerURL    string
 		chDSN          string
 		rateLimit      int
+		methodRates    string
 		redisURL       string
 		embeddingModel string
 		timeout        time.Duration
 		dryRun         bool
+		quiet          bool
 		showVersion    bool
+		rpcLatency     bool
+		strictProvider bool
+		strictValidate bool
+		trackPending   bool
+		cacheStds      bool
+		walFile        string
+		honeypotGuard  bool
+		auditUndecoded bool
+		addressEdges   bool
+		hashRanges     bool
+		skipPrecompile bool
+		skipReceipts   bool
+		missingRcpts   string
+		providerKind   string
+		unconfirmed    bool
+		outputDir      string
+		kafkaBrokers   string
+		kafkaTopic     string
+		onlyTables     string
+		indexedAmount  string
+		eventABIs      string
+		columnNames    string
+		sortKeys       string
+		recordSource   bool
+		maxBlocks      uint64
+		maxRPCCalls    uint64
+		retainBlocks   uint64
+		retainDays     int
+		benchBlocks    uint64
+		confirmPrune   bool
+		verifyLogs     bool
+		verifyDelay    time.Duration
+		adaptiveBatch  bool
+		minBatch       int
+		batchItems     int
+		tsCacheSize    int
+		tsPrecision    string
+		rowBinary      string
+		noFinality     bool
+		maxWindow      int
+		chCompression  bool
+		chMaxConns     int
+		chMaxIdle      int
+		chMaxIdleHost  int
+		chQueryTO      time.Duration
+		chInsertTO     time.Duration
+		deterministic  bool
+		receiptBatch   int
+		maxTraces      int
+		logsBloom      bool
+		noProviderTs   bool
+		maxTracePages  int
+		userAgent      string
+		maxRespMB      int
+		insertSplitMin int
+		reconcile      bool
+		runLock        bool
+		stagedCommit   bool
+		trackFinality  bool
+		requireCH      bool
+		lockTTL        time.Duration
+		contractMode   bool
+		endBehavior    string
+		pollInterval   time.Duration
+		hedgeDelay     time.Duration
+		shutdownWait   time.Duration
+		hedgeProvider  string
+		ckptEvery      uint64
 	)
 
 	flag.Usage = printUsage
 	flag.StringVar(&address, "address", "", "Ethereum address to sync (0x...) [required]")
-	flag.StringVar(&mode, "mode", "backfill", "Mode: backfill | delta")
+	flag.StringVar(&mode, "mode", "backfill", "Mode: backfill | delta | prune | bench | diff | discover")
 	flag.Uint64Var(&fromBlock, "from-block", 0, "Start block (0 = auto)")
+	flag.Uint64Var(&forceFrom, "force-from-block", 0, "Backfill from this block whatever the stored checkpoint says, rewinding it (history is kept)")
 	flag.Uint64Var(&toBlock, "to-block", 0, "End block (0 = head)")
+	flag.Uint64Var(&retainBlocks, "retain-blocks", 0, "With --mode prune: keep this many blocks up to the checkpoint, delete older rows")
+	flag.IntVar(&retainDays, "retain-days", 0, "With --mode prune: keep rows from the last N days, delete older ones")
+	flag.Uint64Var(&benchBlocks, "bench-blocks", ingest.DefaultBenchBlocks, "With --mode bench: blocks to process for the throughput report")
+	flag.BoolVar(&confirmPrune, "yes", false, "Confirm --mode prune; without it the delete statements are only printed")
+	flag.Uint64Var(&maxBlocks, "max-blocks", 0, "Process at most this many blocks per invocation, then checkpoint and exit 3 (0 = unlimited)")
+	flag.Uint64Var(&maxRPCCalls, "max-rpc-calls", 0, "Send at most this many JSON-RPC calls per invocation, then checkpoint and exit 6 (0 = unlimited)")
 	flag.IntVar(&confirmations, "confirmations", defaults.SyncConfirmations, "Required confirmations for finality")
 	flag.StringVar(&schemaMode, "schema", ingest.DefaultSchemaMode, "Schema: dev | canonical")
+	flag.StringVar(&tsPrecision, "timestamp-precision", ingest.DefaultTimestampPrecision, "Canonical ts/version column precision: s (DateTime) | ms (DateTime64(3)) | us (DateTime64(6))")
 	flag.IntVar(&batch, "batch", defaults.BatchBlocks, "Block batch size per request")
+	flag.BoolVar(&adaptiveBatch, "adaptive-batch", false, "Halve the batch on range fetch failures and grow it back after sustained success")
+	flag.IntVar(&minBatch, "min-batch", ingest.DefaultMinBatchBlocks, "Smallest batch --adaptive-batch may shrink to")
+	flag.IntVar(&batchItems, "batch-items", 0, "Cap each range at this many fetched logs/transactions/traces, sizing the block window to fit (0 = fixed block batches)")
+	flag.IntVar(&tsCacheSize, "ts-cache-size", ingest.DefaultTimestampCacheSize, "Block timestamps kept in the in-process LRU cache")
+	flag.IntVar(&maxWindow, "max-window", 0, "Widest block window --batch-items may grow to (0 = 16x --batch)")
 	flag.StringVar(&providerURL, "provider", defaults.ProviderURL, "Ethereum RPC provider URL (ETH_PROVIDER_URL)")
 	flag.StringVar(&chDSN, "clickhouse", defaults.ClickHouseDSN, "ClickHouse DSN (CLICKHOUSE_DSN or built from CLICKHOUSE_URL/DB/USER/PASS)")
+	flag.BoolVar(&requireCH, "require-clickhouse", false, "Fail instead of warning when no ClickHouse DSN is configured")
+	flag.BoolVar(&chCompression, "clickhouse-compression", false, "Request zstd/gzip-compressed ClickHouse query responses")
+	flag.IntVar(&chMaxConns, "clickhouse-max-conns", ch.DefaultMaxConnsPerHost, "ClickHouse connections per host, in use or idle")
+	flag.IntVar(&chMaxIdle, "clickhouse-max-idle-conns", ch.DefaultMaxIdleConns, "Idle ClickHouse connections kept across hosts")
+	flag.DurationVar(&chQueryTO, "clickhouse-query-timeout", ch.DefaultQueryTimeout, "Per-attempt timeout of ClickHouse queries")
+	flag.DurationVar(&chInsertTO, "clickhouse-insert-timeout", ch.DefaultInsertTimeout, "Per-attempt timeout of ClickHouse inserts and statements")
+	flag.IntVar(&chMaxIdleHost, "clickhouse-max-idle-per-host", ch.DefaultMaxIdleConnsPerHost, "Idle ClickHouse connections kept per host")
+	flag.DurationVar(&hedgeDelay, "hedge-delay", 0, "Send a duplicate of any RPC request unanswered after this long and use the first response (0 = off)")
+	flag.StringVar(&hedgeProvider, "hedge-provider", "", "With --hedge-delay: endpoint for the duplicate requests (default the --provider endpoint)")
+	flag.StringVar(&providerKind, "provider-kind", "standard", "Provider adapter: standard | alchemy (find transaction blocks with alchemy_getAssetTransfers) | auto (alchemy for Alchemy endpoints)")
+	flag.BoolVar(&logsBloom, "logs-bloom", false, "Read each block header first and skip eth_getLogs for blocks whose logsBloom rules out the address")
+	flag.BoolVar(&noProviderTs, "no-provider-timestamps", false, "Skip the per-block header fetch that timestamps logs and traces inside the provider; the ingester fills them from its timestamp cache")
+	flag.IntVar(&maxTraces, "max-traces", 0, "Fail a range whose trace_filter results exceed this many traces (0 = unlimited)")
+	flag.IntVar(&maxTracePages, "max-trace-pages", 0, "Fail a range needing more than this many trace_filter pages of 1000 (0 = unlimited)")
+	flag.IntVar(&receiptBatch, "receipt-batch", eth.DefaultReceiptBatchSize, "Receipts per JSON-RPC batch when eth_getBlockReceipts is unavailable (<= 1 disables batching)")
 	flag.IntVar(&rateLimit, "rate-limit", defaults.RateLimit, "RPC rate limit (req/s, 0 = unlimited)")
+	flag.StringVar(&methodRates, "method-rate-limits", "", "Per-method RPC rate limits replacing --rate-limit for those methods, e.g. trace_filter=2,eth_getLogs=10")
 	flag.StringVar(&redisURL, "redis", defaults.RedisURL, "Redis connection URL (REDIS_URL)")
 	flag.StringVar(&embeddingModel, "embedding-model", defaults.EmbeddingModel, "Embedding model identifier (EMBEDDING_MODEL)")
 	flag.DurationVar(&timeout, "timeout", defaults.Timeout, "Ingestion timeout")
+	flag.StringVar(&endBehavior, "end-behavior", "exit", "Delta with no new blocks: exit (print up-to-date, exit 5) | poll (wait for new blocks; no deadline unless --timeout is set)")
+	flag.Uint64Var(&ckptEvery, "checkpoint-every", 0, "Backfill: persist the checkpoint and log progress every N blocks (0 = at the end; --mode discover defaults to 10000)")
+	flag.DurationVar(&shutdownWait, "shutdown-timeout", shutdown.DefaultTimeout, "Longest wait for shutdown hooks (metrics and connection flushes) on exit or signal")
+	flag.DurationVar(&pollInterval, "poll-interval", 12*time.Second, "Delay between delta polls with --end-behavior poll")
 	flag.BoolVar(&dryRun, "dry-run", false, "Print plan and exit")
+	flag.BoolVar(&quiet, "quiet", false, "Do not print the ok/up-to-date/cap-reached/budget-exhausted status line; the exit code carries it")
+	flag.BoolVar(&deterministic, "deterministic", false, "Serialize receipt fetches, sort fetched data and count row versions from the epoch for byte-identical output (testing/debugging; not with --clickhouse)")
+	flag.BoolVar(&contractMode, "contract", false, "Treat --address as a token contract and ingest all of its transfer events, not just the address's own activity")
+	flag.BoolVar(&reconcile, "reconcile", false, "Check each range's net ETH flow (transfers, internal traces, withdrawals, gas fees) against eth_getBalance deltas")
+	flag.BoolVar(&verifyLogs, "verify-logs", false, "Re-query eth_getLogs once when a range has no logs but the address received calls with calldata")
+	flag.DurationVar(&verifyDelay, "verify-logs-delay", ingest.DefaultVerifyLogsDelay, "Wait before the --verify-logs re-query")
+	flag.BoolVar(&runLock, "run-lock", false, "Take an advisory per-address lock in ClickHouse (run_locks) and fail if another run holds it")
+	flag.BoolVar(&stagedCommit, "staged-commit", false, "Stage each batch's ClickHouse rows and publish them together with its checkpoint")
+	flag.DurationVar(&lockTTL, "lock-ttl", ingest.DefaultLockTTL, "How long a --run-lock stays fresh without a heartbeat")
+	flag.BoolVar(&trackFinality, "track-finality", false, "Stamp rows latest/safe/finalized from the node's block tags and promote them as blocks finalize (canonical only)")
+	flag.BoolVar(&noFinality, "no-finality", false, "Local dev nodes only (Anvil, Hardhat): treat the chain head as final, ignoring --confirmations")
+	flag.BoolVar(&unconfirmed, "include-unconfirmed", false, "Also ingest blocks above the safe head, flagged unconfirmed=1 (canonical only)")
+	flag.StringVar(&rowBinary, "rowbinary-tables", "", "Comma-separated canonical tables inserted in ClickHouse RowBinary instead of JSONEachRow")
+	flag.StringVar(&onlyTables, "only-tables", "", "Comma-separated canonical tables to write (default all: "+strings.Join(ingest.CanonicalTables, ",")+")")
+	flag.StringVar(&columnNames, "column-names", "", "Comma-separated canonical column renames for existing schemas, e.g. tx_hash=transaction_hash,logs.topics=topic_list")
+	flag.StringVar(&sortKeys, "sort-keys", "", "Comma-separated per-table sort keys applied to rows before insert, e.g. logs=block_number+log_index (default: each table's ClickHouse ORDER BY)")
+	flag.BoolVar(&recordSource, "record-provider-source", false, "Stamp canonical rows with source_provider, the host of the RPC endpoint that served them")
+	flag.StringVar(&indexedAmount, "indexed-amount-tokens", "", "Comma-separated token contracts whose Transfer indexes the amount in topics[3] (decoded as erc20, non_standard=1)")
+	flag.StringVar(&eventABIs, "event-abis", "", "Comma-separated JSON ABI files whose events' indexed string/bytes/array/tuple topics are kept as hashes (logs.hashed_topics) instead of being decoded")
+	flag.StringVar(&outputDir, "output-dir", "", "Also write normalized rows to per-table .jsonl files in this directory")
+	flag.StringVar(&kafkaBrokers, "kafka-brokers", "", "Comma-separated Kafka REST proxy URLs; also publish each normalized row as a JSON message keyed by the address")
+	flag.StringVar(&kafkaTopic, "kafka-topic", ingest.DefaultKafkaTopic, "Kafka topic per table for --kafka-brokers; {table} is replaced by the table name")
+	flag.BoolVar(&rpcLatency, "rpc-latency", false, "Record per-method RPC latency and log p50/p95/p99 at exit")
+	flag.BoolVar(&trackPending, "track-pending", false, "With --mode delta: record mempool transactions touching the address in pending_transactions (needs txpool_content)")
+	flag.BoolVar(&hashRanges, "hash-ranges", false, "Record a SHA-256 of each confirmed range's canonical rows in range_hashes and log range_hash_mismatch when a re-ingested range hashes differently")
+	flag.BoolVar(&skipPrecompile, "skip-precompiles", false, "Drop transactions and traces sent to a precompiled contract (0x01-0x09) instead of writing them with is_precompile=1")
+	flag.BoolVar(&skipReceipts, "skip-receipts", false, "Skip fetching transactions and their receipts; write logs-derived tables and traces only (transactions keeps internal rows)")
+	flag.BoolVar(&auditUndecoded, "audit-undecoded", false, "Count logs matching no known event per contract and topic0, and write the counts to undecoded_events at the end of each run")
+	flag.BoolVar(&addressEdges, "address-edges", false, "Write per-block directed edges (sender, recipient, asset, count, value sum) between the address and its counterparties to address_edges (canonical schema)")
+	flag.BoolVar(&honeypotGuard, "honeypot-guard", false, "Probe ERC-20 tokens emitting transfers with balanceOf/transfer eth_calls and set suspicious=1 on transfers of tokens that revert them")
+	flag.StringVar(&walFile, "wal-file", "", "Local write-ahead log of backfill ranges; a restarted backfill skips the ranges it records as done and retries those started but not done")
+	flag.BoolVar(&cacheStds, "cache-standards", false, "Read token contract standards through the contract_standards table before decoding, and store newly classified contracts there")
+	flag.BoolVar(&strictValidate, "strict-validate", false, "Check normalized rows before writing (lowercase hex addresses and hashes, required ones set) and fail on the first malformed row")
+	flag.BoolVar(&strictProvider, "strict-provider", false, "Fail the range on the first block or receipt error instead of ingesting partial transactions")
+	flag.StringVar(&missingRcpts, "missing-receipts", "skip", "Transactions whose receipt cannot be fetched: skip | emit (store with receipt_missing=1 and zeroed gas/status)")
+	flag.IntVar(&maxRespMB, "max-response-mb", int(eth.DefaultMaxResponseBytes>>20), "Largest RPC or ClickHouse response body read, in MiB; larger responses fail the call")
+	flag.IntVar(&insertSplitMin, "insert-split-min-rows", 1, "Split ClickHouse inserts rejected with MEMORY_LIMIT_EXCEEDED in halves while they hold more than this many rows; negative disables")
+	flag.StringVar(&userAgent, "user-agent", "", "User-Agent sent on RPC and ClickHouse requests (default mvp_wallet_context/<version>)")
 	flag.BoolVar(&showVersion, "version", false, "Print version and exit")
 	flag.Parse()
 
@@ -233,14 +450,77 @@ func main() {
 	}
 
 	mode = strings.ToLower(mode)
-	if mode != "backfill" && mode != "delta" {
-		fmt.Fprintf(os.Stderr, "unknown --mode %q (use backfill|delta)\n", mode)
+	if mode != "backfill" && mode != "delta" && mode != "prune" && mode != "bench" && mode != "diff" && mode != "discover" {
+		fmt.Fprintf(os.Stderr, "unknown --mode %q (use backfill|delta|prune|bench|diff|discover)\n", mode)
+		exit(2)
+	}
+	if benchBlocks == 0 {
+		fmt.Fprintln(os.Stderr, "--bench-blocks must be > 0")
+		exit(2)
+	}
+	if retainDays < 0 {
+		fmt.Fprintln(os.Stderr, "--retain-days must be >= 0")
+		exit(2)
+	}
+	if mode == "prune" && (retainBlocks > 0) == (retainDays > 0) {
+		fmt.Fprintln(os.Stderr, "--mode prune requires exactly one of --retain-blocks or --retain-days")
+		exit(2)
+	}
+	if mode != "prune" && (retainBlocks > 0 || retainDays > 0) {
+		fmt.Fprintln(os.Stderr, "--retain-blocks and --retain-days require --mode prune")
 		exit(2)
 	}
 	if toBlock > 0 && fromBlock > toBlock {
 		fmt.Fprintln(os.Stderr, "--from-block cannot be greater than --to-block")
 		exit(2)
 	}
+	if trackPending && mode != "delta" {
+		fmt.Fprintln(os.Stderr, "--track-pending requires --mode delta")
+		exit(2)
+	}
+	var forceFromBlock *uint64
+	var timeoutSet bool
+	flag.CommandLine.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "force-from-block":
+			forceFromBlock = &forceFrom
+		case "timeout":
+			timeoutSet = true
+		}
+	})
+	if forceFromBlock != nil {
+		if mode != "backfill" {
+			fmt.Fprintln(os.Stderr, "--force-from-block requires --mode backfill")
+			exit(2)
+		}
+		if fromBlock > 0 {
+			fmt.Fprintln(os.Stderr, "--force-from-block and --from-block are mutually exclusive")
+			exit(2)
+		}
+		if toBlock > 0 && forceFrom > toBlock {
+			fmt.Fprintln(os.Stderr, "--force-from-block cannot be greater than --to-block")
+			exit(2)
+		}
+	}
+	if mode == "discover" {
+		// A hardened backfill preset: genesis to head, adaptive batches,
+		// periodic checkpoints and, unless --timeout is given, no deadline.
+		if fromBlock > 0 {
+			fmt.Fprintln(os.Stderr, "--mode discover scans from block 0; use --mode backfill with --from-block")
+			exit(2)
+		}
+		adaptiveBatch = true
+		if ckptEvery == 0 {
+			ckptEvery = ingest.DefaultCheckpointEvery
+		}
+		if !timeoutSet {
+			timeout = 0
+		}
+	}
+	if ckptEvery > 0 && mode != "backfill" && mode != "discover" {
+		fmt.Fprintln(os.Stderr, "--checkpoint-every requires --mode backfill or discover")
+		exit(2)
+	}
 	if confirmations < 0 {
 		fmt.Fprintln(os.Stderr, "--confirmations must be >= 0")
 		exit(2)
@@ -253,6 +533,116 @@ func main() {
 		fmt.Fprintf(os.Stderr, "--batch must be <= %d to avoid overwhelming the provider\n", defaultMaxBatchBlocks)
 		exit(2)
 	}
+	if minBatch <= 0 || minBatch > batch {
+		fmt.Fprintln(os.Stderr, "--min-batch must be > 0 and <= --batch")
+		exit(2)
+	}
+	if batchItems < 0 {
+		fmt.Fprintln(os.Stderr, "--batch-items must be >= 0")
+		exit(2)
+	}
+	if tsCacheSize <= 0 {
+		fmt.Fprintln(os.Stderr, "--ts-cache-size must be > 0")
+		exit(2)
+	}
+	if chMaxConns <= 0 || chMaxIdle <= 0 || chMaxIdleHost <= 0 {
+		fmt.Fprintln(os.Stderr, "--clickhouse-max-conns, --clickhouse-max-idle-conns and --clickhouse-max-idle-per-host must be > 0")
+		exit(2)
+	}
+	if chQueryTO <= 0 || chInsertTO <= 0 {
+		fmt.Fprintln(os.Stderr, "--clickhouse-query-timeout and --clickhouse-insert-timeout must be > 0")
+		exit(2)
+	}
+	if maxWindow < 0 || (maxWindow > 0 && maxWindow < batch) {
+		fmt.Fprintln(os.Stderr, "--max-window must be 0 or >= --batch")
+		exit(2)
+	}
+	missingRcpts = strings.ToLower(missingRcpts)
+	if missingRcpts != "skip" && missingRcpts != "emit" {
+		fmt.Fprintf(os.Stderr, "unknown --missing-receipts %q (use skip|emit)\n", missingRcpts)
+		exit(2)
+	}
+	providerKind = strings.ToLower(providerKind)
+	switch providerKind {
+	case "standard", "alchemy":
+	case "auto":
+		providerKind = "standard"
+		if eth.IsAlchemyEndpoint(providerURL) {
+			providerKind = "alchemy"
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown --provider-kind %q (use standard|alchemy|auto)\n", providerKind)
+		exit(2)
+	}
+	if shutdownWait <= 0 {
+		fmt.Fprintln(os.Stderr, "--shutdown-timeout must be > 0")
+		exit(2)
+	}
+	if hedgeDelay < 0 {
+		fmt.Fprintln(os.Stderr, "--hedge-delay must be >= 0")
+		exit(2)
+	}
+	if hedgeProvider != "" && hedgeDelay == 0 {
+		fmt.Fprintln(os.Stderr, "--hedge-provider requires --hedge-delay")
+		exit(2)
+	}
+	endBehavior = strings.ToLower(endBehavior)
+	if endBehavior != "exit" && endBehavior != "poll" {
+		fmt.Fprintf(os.Stderr, "unknown --end-behavior %q (use exit|poll)\n", endBehavior)
+		exit(2)
+	}
+	// A polling delta runs until interrupted; only an explicit --timeout bounds it.
+	if mode == "delta" && endBehavior == "poll" && !timeoutSet {
+		timeout = 0
+	}
+	if pollInterval <= 0 {
+		fmt.Fprintln(os.Stderr, "--poll-interval must be > 0")
+		exit(2)
+	}
+	if verifyDelay <= 0 {
+		fmt.Fprintln(os.Stderr, "--verify-logs-delay must be > 0")
+		exit(2)
+	}
+	if lockTTL <= 0 {
+		fmt.Fprintln(os.Stderr, "--lock-ttl must be > 0")
+		exit(2)
+	}
+	if runLock && chDSN == "" {
+		fmt.Fprintln(os.Stderr, "--run-lock requires a ClickHouse DSN (--clickhouse)")
+		exit(2)
+	}
+	if (mode == "prune" || mode == "diff") && chDSN == "" {
+		fmt.Fprintf(os.Stderr, "--mode %s requires a ClickHouse DSN (--clickhouse)\n", mode)
+		exit(2)
+	}
+	if stagedCommit && chDSN == "" {
+		fmt.Fprintln(os.Stderr, "--staged-commit requires a ClickHouse DSN (--clickhouse)")
+		exit(2)
+	}
+	if deterministic && chDSN != "" {
+		fmt.Fprintln(os.Stderr, "--deterministic pins row versions to the Unix epoch and cannot write to ClickHouse; use --output-dir or --dry-run")
+		exit(2)
+	}
+	if contractMode && reconcile {
+		fmt.Fprintln(os.Stderr, "--reconcile cannot be combined with --contract")
+		exit(2)
+	}
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		userAgent = "mvp_wallet_context/" + version
+	}
+	if maxRespMB <= 0 {
+		fmt.Fprintln(os.Stderr, "--max-response-mb must be > 0")
+		exit(2)
+	}
+	if maxTraces < 0 || maxTracePages < 0 {
+		fmt.Fprintln(os.Stderr, "--max-traces and --max-trace-pages must be >= 0")
+		exit(2)
+	}
+	if receiptBatch < 0 {
+		fmt.Fprintln(os.Stderr, "--receipt-batch must be >= 0")
+		exit(2)
+	}
 	if rateLimit < 0 {
 		fmt.Fprintln(os.Stderr, "--rate-limit must be >= 0")
 		exit(2)
@@ -261,13 +651,97 @@ func main() {
 		fmt.Fprintf(os.Stderr, "--rate-limit must be <= %d requests/second\n", defaultMaxRateLimit)
 		exit(2)
 	}
+	methodLimits, err := eth.ParseMethodRates(methodRates)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --method-rate-limits: %v\n", err)
+		exit(2)
+	}
+	for method, rate := range methodLimits {
+		if rate > defaultMaxRateLimit {
+			fmt.Fprintf(os.Stderr, "--method-rate-limits %s must be <= %d requests/second\n", method, defaultMaxRateLimit)
+			exit(2)
+		}
+	}
 	originalSchema := schemaMode
-	var err error
 	schemaMode, err = ingest.NormalizeSchema(schemaMode)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "unknown --schema %q (use dev|canonical)\n", originalSchema)
 		exit(2)
 	}
+	originalPrecision := tsPrecision
+	if tsPrecision, err = ingest.NormalizeTimestampPrecision(tsPrecision); err != nil {
+		fmt.Fprintf(os.Stderr, "unknown --timestamp-precision %q (use s|ms|us)\n", originalPrecision)
+		exit(2)
+	}
+	if trackFinality && schemaMode != "canonical" {
+		fmt.Fprintln(os.Stderr, "--track-finality requires --schema canonical")
+		exit(2)
+	}
+	if addressEdges && schemaMode != "canonical" {
+		fmt.Fprintln(os.Stderr, "--address-edges requires --schema canonical")
+		exit(2)
+	}
+	var tables []string
+	if onlyTables != "" {
+		tables, err = ingest.NormalizeTables(strings.Split(onlyTables, ","))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --only-tables: %v\n", err)
+			exit(2)
+		}
+	}
+	var rowBinaryTables []string
+	if rowBinary != "" {
+		rowBinaryTables, err = ingest.NormalizeTables(strings.Split(rowBinary, ","))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --rowbinary-tables: %v\n", err)
+			exit(2)
+		}
+	}
+	var indexedTokens []string
+	if indexedAmount != "" {
+		for _, t := range strings.Split(indexedAmount, ",") {
+			t = strings.TrimSpace(t)
+			if !addressRegex.MatchString(t) {
+				fmt.Fprintf(os.Stderr, "invalid --indexed-amount-tokens entry %q; expected 0x-prefixed 40 hex chars\n", t)
+				exit(2)
+			}
+			indexedTokens = append(indexedTokens, t)
+		}
+	}
+	var abiFiles []string
+	if eventABIs != "" {
+		for _, path := range strings.Split(eventABIs, ",") {
+			path = strings.TrimSpace(path)
+			raw, err := os.ReadFile(path)
+			if err == nil {
+				err = normalize.RegisterEventABI(raw)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid --event-abis entry %q: %v\n", path, err)
+				exit(2)
+			}
+			abiFiles = append(abiFiles, path)
+		}
+	}
+	var brokers []string
+	var kafkaProducer ingest.KafkaProducer
+	if kafkaBrokers != "" {
+		brokers = strings.Split(kafkaBrokers, ",")
+		if kafkaProducer, err = ingest.NewKafkaRESTProducer(brokers, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --kafka-brokers: %v\n", err)
+			exit(2)
+		}
+	}
+	columnMap, err := ingest.ParseColumnNames(columnNames)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --column-names: %v\n", err)
+		exit(2)
+	}
+	sortKeyMap, err := ingest.ParseSortKeys(sortKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --sort-keys: %v\n", err)
+		exit(2)
+	}
 	var clickhouseFlagExplicit bool
 	flag.CommandLine.Visit(func(f *flag.Flag) {
 		if f.Name == "clickhouse" {
@@ -278,23 +752,90 @@ func main() {
 		fmt.Fprintln(os.Stderr, "refusing to use --clickhouse with inline credentials; set CLICKHOUSE_DSN or CLICKHOUSE_PASS instead")
 		exit(2)
 	}
+	if chDSN == "" && !dryRun {
+		reason := "no ClickHouse DSN configured"
+		if !clickhouseFlagExplicit {
+			if err := cfgpkg.ClickHouseEnvError(); err != nil {
+				reason = err.Error()
+			}
+		}
+		switch {
+		case requireCH:
+			fmt.Fprintf(os.Stderr, "--require-clickhouse: %s\n", reason)
+			exit(2)
+		case providerURL != "" && outputDir == "" && kafkaBrokers == "":
+			fmt.Fprintf(os.Stderr, "WARNING: %s; ingested rows will NOT be persisted (set --clickhouse, --output-dir or --kafka-brokers, or pass --require-clickhouse to fail instead)\n", reason)
+		}
+	}
 
 	opts := ingest.Options{
-		ProviderURL:   providerURL,
-		ClickHouseDSN: chDSN,
-		FromBlock:     fromBlock,
-		ToBlock:       toBlock,
-		Confirmations: confirmations,
-		BatchBlocks:   batch,
-		RateLimit:     rateLimit,
-		RedisURL:      redisURL,
-		DryRun:        dryRun,
-		Timeout:       timeout,
-		Schema:        schemaMode,
+		ProviderURL:           providerURL,
+		ClickHouseDSN:         chDSN,
+		FromBlock:             fromBlock,
+		ForceFromBlock:        forceFromBlock,
+		StrictValidate:        strictValidate,
+		TrackPending:          trackPending,
+		CacheStandards:        cacheStds,
+		WALPath:               walFile,
+		HoneypotGuard:         honeypotGuard,
+		AuditUndecoded:        auditUndecoded,
+		AddressEdges:          addressEdges,
+		HashRanges:            hashRanges,
+		SkipPrecompiles:       skipPrecompile,
+		SkipReceipts:          skipReceipts,
+		ToBlock:               toBlock,
+		Confirmations:         confirmations,
+		BatchBlocks:           batch,
+		RateLimit:             rateLimit,
+		RedisURL:              redisURL,
+		DryRun:                dryRun,
+		Timeout:               timeout,
+		Schema:                schemaMode,
+		IncludeUnconfirmed:    unconfirmed,
+		NoFinality:            noFinality,
+		OutputDir:             outputDir,
+		KafkaBrokers:          brokers,
+		KafkaProducer:         kafkaProducer,
+		KafkaTopic:            kafkaTopic,
+		Tables:                tables,
+		AdaptiveBatch:         adaptiveBatch,
+		MinBatchBlocks:        minBatch,
+		MaxBatchItems:         batchItems,
+		MaxBatchWindow:        maxWindow,
+		TimestampCacheSize:    tsCacheSize,
+		TimestampPrecision:    tsPrecision,
+		RowBinaryTables:       rowBinaryTables,
+		ClickHouseCompression: chCompression,
+		ClickHousePool:        ch.PoolOptions{MaxIdleConns: chMaxIdle, MaxIdleConnsPerHost: chMaxIdleHost, MaxConnsPerHost: chMaxConns},
+		QueryTimeout:          chQueryTO,
+		InsertTimeout:         chInsertTO,
+		UserAgent:             userAgent,
+		MaxResponseBytes:      int64(maxRespMB) << 20,
+		InsertSplitMinRows:    insertSplitMin,
+		Deterministic:         deterministic,
+		Reconcile:             reconcile,
+		RunLock:               runLock,
+		StagedCommit:          stagedCommit,
+		TrackFinality:         trackFinality,
+		LockTTL:               lockTTL,
+		IndexedAmountTokens:   indexedTokens,
+		ColumnNames:           columnMap,
+		SortKeys:              sortKeyMap,
+		RecordProviderSource:  recordSource,
+		MaxBlocksPerRun:       maxBlocks,
+		VerifyLogs:            verifyLogs,
+		VerifyLogsDelay:       verifyDelay,
+		ContractMode:          contractMode,
+		CheckpointEvery:       ckptEvery,
+		ReportUpToDate:        mode == "delta",
 	}
 
 	if dryRun {
 		// Print a compact JSON plan and exit.
+		redactedBrokers := make([]string, 0, len(brokers))
+		for _, b := range brokers {
+			redactedBrokers = append(redactedBrokers, cfgpkg.RedactDSN(strings.TrimSpace(b)))
+		}
 		plan := map[string]any{
 			"address":  address,
 			"mode":     mode,
@@ -306,15 +847,84 @@ func main() {
 				}
 				return cfgpkg.RedactDSN(chDSN)
 			}(),
-			"from_block":      fromBlock,
-			"to_block":        toBlock,
-			"confirmations":   confirmations,
-			"batch":           batch,
-			"rate_limit":      rateLimit,
-			"redis_url":       redisURL,
-			"embedding_model": embeddingModel,
-			"timeout":         timeout.String(),
-			"schema":          schemaMode,
+			"from_block":             fromBlock,
+			"force_from_block":       forceFromBlock,
+			"to_block":               toBlock,
+			"confirmations":          confirmations,
+			"batch":                  batch,
+			"rate_limit":             rateLimit,
+			"method_rate_limits":     methodLimits,
+			"redis_url":              redisURL,
+			"embedding_model":        embeddingModel,
+			"timeout":                timeout.String(),
+			"schema":                 schemaMode,
+			"timestamp_precision":    tsPrecision,
+			"rowbinary_tables":       rowBinaryTables,
+			"rpc_latency":            rpcLatency,
+			"strict_provider":        strictProvider,
+			"strict_validate":        strictValidate,
+			"track_pending":          trackPending,
+			"cache_standards":        cacheStds,
+			"wal_file":               walFile,
+			"honeypot_guard":         honeypotGuard,
+			"audit_undecoded":        auditUndecoded,
+			"address_edges":          addressEdges,
+			"hash_ranges":            hashRanges,
+			"skip_precompiles":       skipPrecompile,
+			"skip_receipts":          skipReceipts,
+			"missing_receipts":       missingRcpts,
+			"provider_kind":          providerKind,
+			"hedge_delay":            hedgeDelay.String(),
+			"hedge_provider":         hedgeProvider,
+			"unconfirmed":            unconfirmed,
+			"no_finality":            noFinality,
+			"output_dir":             outputDir,
+			"kafka_brokers":          redactedBrokers,
+			"kafka_topic":            kafkaTopic,
+			"tables":                 tables,
+			"adaptive_batch":         adaptiveBatch,
+			"min_batch":              minBatch,
+			"batch_items":            batchItems,
+			"max_window":             maxWindow,
+			"ts_cache_size":          tsCacheSize,
+			"clickhouse_compression": chCompression,
+			"clickhouse_max_conns":   chMaxConns,
+			"clickhouse_max_idle":    chMaxIdle,
+			"clickhouse_idle_host":   chMaxIdleHost,
+			"ch_query_timeout":       chQueryTO.String(),
+			"ch_insert_timeout":      chInsertTO.String(),
+			"require_clickhouse":     requireCH,
+			"deterministic":          deterministic,
+			"receipt_batch":          receiptBatch,
+			"max_traces":             maxTraces,
+			"logs_bloom":             logsBloom,
+			"no_provider_timestamps": noProviderTs,
+			"max_trace_pages":        maxTracePages,
+			"user_agent":             userAgent,
+			"max_response_mb":        maxRespMB,
+			"insert_split_min_rows":  insertSplitMin,
+			"reconcile":              reconcile,
+			"run_lock":               runLock,
+			"staged_commit":          stagedCommit,
+			"track_finality":         trackFinality,
+			"lock_ttl":               lockTTL.String(),
+			"indexed_amount_tokens":  indexedTokens,
+			"event_abis":             abiFiles,
+			"column_names":           columnMap,
+			"sort_keys":              sortKeyMap,
+			"record_provider_source": recordSource,
+			"max_blocks":             maxBlocks,
+			"max_rpc_calls":          maxRPCCalls,
+			"retain_blocks":          retainBlocks,
+			"retain_days":            retainDays,
+			"bench_blocks":           benchBlocks,
+			"verify_logs":            verifyLogs,
+			"verify_logs_delay":      verifyDelay.String(),
+			"contract_mode":          contractMode,
+			"end_behavior":           endBehavior,
+			"poll_interval":          pollInterval.String(),
+			"shutdown_timeout":       shutdownWait.String(),
+			"checkpoint_every":       ckptEvery,
 		}
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
@@ -325,8 +935,12 @@ func main() {
 	// Context cancelled on SIGINT/SIGTERM and with timeout
 	baseCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
-	ctx, cancel := context.WithTimeout(baseCtx, timeout)
-	defer cancel()
+	ctx := baseCtx
+	if timeout > 0 { // 0 for --mode discover or --end-behavior poll without --timeout
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(baseCtx, timeout)
+		defer cancel()
+	}
 
 	// If a provider URL is configured, build a provider and inject it. Otherwise
 	// fall back to a stubbed ingester (tests).
@@ -334,8 +948,65 @@ func main() {
 		Backfill(context.Context) error
 		Delta(context.Context) error
 	}
+	// Flushes and closes run once the mode returns, including after a
+	// signal canceled ctx, before the exit status is decided.
+	var hooks shutdown.Registry
 	if providerURL != "" {
-		p, err := newProvider(providerURL, rateLimit, defaults.HTTPRetries, defaults.HTTPBackoffBase)
+		provOpts := []eth.ProviderOption{eth.WithUserAgent(userAgent)}
+		if rpcLatency || mode == "bench" {
+			latency := eth.NewLatencyRecorder()
+			provOpts = append(provOpts, eth.WithLatencyRecorder(latency))
+			hooks.Register("rpc_latency", func(context.Context) error {
+				logLatencySummary(latency, providerHost(providerURL))
+				return nil
+			})
+		}
+		if maxRPCCalls > 0 {
+			budget := eth.NewCallBudget(maxRPCCalls)
+			provOpts = append(provOpts, eth.WithCallBudget(budget))
+			hooks.Register("rpc_budget", func(context.Context) error {
+				logging.Logger().Info("rpc_budget",
+					"component", "cmd.ingester",
+					"calls", budget.Used(),
+					"max_calls", budget.Max(),
+				)
+				return nil
+			})
+		}
+		if deterministic {
+			provOpts = append(provOpts, eth.WithReceiptWorkers(1))
+		}
+		if logsBloom {
+			provOpts = append(provOpts, eth.WithLogsBloomFilter())
+		}
+		if noProviderTs {
+			provOpts = append(provOpts, eth.WithoutTimestampEnrichment())
+		}
+		if maxTraces > 0 || maxTracePages > 0 {
+			provOpts = append(provOpts, eth.WithTraceLimits(maxTraces, maxTracePages))
+		}
+		if maxBody := int64(maxRespMB) << 20; maxBody != eth.DefaultMaxResponseBytes {
+			provOpts = append(provOpts, eth.WithMaxResponseBytes(maxBody))
+		}
+		if receiptBatch != eth.DefaultReceiptBatchSize {
+			provOpts = append(provOpts, eth.WithReceiptBatchSize(receiptBatch))
+		}
+		if strictProvider {
+			provOpts = append(provOpts, eth.WithStrictTransactions())
+		}
+		if missingRcpts == "emit" {
+			provOpts = append(provOpts, eth.WithReceiptlessTransactions())
+		}
+		if providerKind == "alchemy" {
+			provOpts = append(provOpts, eth.WithAlchemyTransfers())
+		}
+		if len(methodLimits) > 0 {
+			provOpts = append(provOpts, eth.WithMethodRateLimits(methodLimits))
+		}
+		if hedgeDelay > 0 {
+			provOpts = append(provOpts, eth.WithHedging(hedgeDelay, hedgeProvider))
+		}
+		p, err := newProvider(providerURL, rateLimit, defaults.HTTPRetries, defaults.HTTPBackoffBase, provOpts...)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "provider error: %v\n", err)
 			exit(1)
@@ -344,15 +1015,149 @@ func main() {
 	} else {
 		ing = newIngest(address, opts)
 	}
+	if c, ok := ing.(interface{ Close(context.Context) error }); ok {
+		hooks.Register("ingester", c.Close)
+	}
 	switch mode {
-	case "backfill":
+	case "backfill", "discover":
 		err = ing.Backfill(ctx)
 	case "delta":
-		err = ing.Delta(ctx)
+		err = runDelta(ctx, ing, endBehavior, pollInterval)
+	case "prune":
+		err = runPrune(ctx, ing, ingest.PruneOptions{RetainBlocks: retainBlocks, RetainDays: retainDays}, confirmPrune)
+	case "bench":
+		err = runBench(ctx, ing, benchBlocks)
+	case "diff":
+		err = runDiff(ctx, ing)
+	}
+	_ = hooks.Run(shutdownWait) // failures are logged
+	if sr, ok := ing.(interface{ Summary() ingest.RunSummary }); ok {
+		logRunSummary(sr.Summary())
+	}
+	// The bench and diff reports are the whole of stdout, so they can be
+	// piped into jq.
+	report := mode == "bench" || mode == "diff"
+	status := func(line string) {
+		if !quiet && !report {
+			fmt.Println(line)
+		}
+	}
+	if errors.Is(err, ingest.ErrUpToDate) {
+		status("up-to-date")
+		exit(exitUpToDate)
+		return
+	}
+	if errors.Is(err, errPruneUnconfirmed) {
+		fmt.Fprintln(os.Stderr, err)
+		exit(2)
+	}
+	if errors.Is(err, ingest.ErrMaxBlocksReached) {
+		status("cap-reached")
+		exit(exitCapReached)
+	}
+	if errors.Is(err, eth.ErrBudgetExceeded) {
+		status("budget-exhausted")
+		exit(exitBudgetExhausted)
+	}
+	if errors.Is(err, ingest.ErrAddressLocked) {
+		fmt.Fprintln(os.Stderr, err)
+		exit(exitLocked)
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ingestion error: %v\n", err)
 		exit(1)
 	}
-	fmt.Println("ok")
+	status("ok")
+}
+
+// errPruneUnconfirmed is returned by runPrune when --yes is missing.
+var errPruneUnconfirmed = errors.New("--mode prune deletes data; review the statements above and re-run with --yes")
+
+// pruner is implemented by *ingest.Ingester.
+type pruner interface {
+	PruneStatements(context.Context, ingest.PruneOptions) ([]string, error)
+	Prune(context.Context, ingest.PruneOptions) error
+}
+
+// runPrune deletes the address's rows outside the retention window. Without
+// confirm it only prints the statements it would run.
+func runPrune(ctx context.Context, ing any, po ingest.PruneOptions, confirm bool) error {
+	p, ok := ing.(pruner)
+	if !ok {
+		return errors.New("prune is not supported by this ingester")
+	}
+	if confirm {
+		return p.Prune(ctx, po)
+	}
+	stmts, err := p.PruneStatements(ctx, po)
+	if errors.Is(err, ingest.ErrNothingToPrune) {
+		fmt.Println("nothing to prune")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		fmt.Println(stmt)
+	}
+	return errPruneUnconfirmed
+}
+
+// bencher is implemented by *ingest.Ingester.
+type bencher interface {
+	Bench(context.Context, uint64) (ingest.BenchReport, error)
+}
+
+// runBench processes a sample of blocks and prints the throughput report as
+// JSON. Per-method RPC latency is logged as rpc_latency like --rpc-latency.
+func runBench(ctx context.Context, ing any, blocks uint64) error {
+	b, ok := ing.(bencher)
+	if !ok {
+		return errors.New("bench is not supported by this ingester")
+	}
+	report, err := b.Bench(ctx, blocks)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// differ is implemented by *ingest.Ingester.
+type differ interface {
+	Diff(context.Context) (ingest.DiffReport, error)
+}
+
+// runDiff compares a fetched range with the stored rows and prints the
+// per-table counts as JSON. Nothing is written.
+func runDiff(ctx context.Context, ing any) error {
+	d, ok := ing.(differ)
+	if !ok {
+		return errors.New("diff is not supported by this ingester")
+	}
+	report, err := d.Diff(ctx)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// runDelta runs a delta pass. With end behavior "poll", a pass that finds no
+// new blocks is retried every interval until blocks arrive, an error occurs,
+// or ctx ends (which still reports ErrUpToDate rather than a failure).
+func runDelta(ctx context.Context, ing interface{ Delta(context.Context) error }, behavior string, interval time.Duration) error {
+	for {
+		err := ing.Delta(ctx)
+		if behavior != "poll" || !errors.Is(err, ingest.ErrUpToDate) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ingest.ErrUpToDate
+		case <-pollAfter(interval):
+		}
+	}
 }
@@ -2,6 +2,10 @@ module github.com/AIAleph/mvp_wallet_context
 
 go 1.24.0
 
-require golang.org/x/crypto v0.42.0
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.42.0
+	golang.org/x/sync v0.16.0
+)
 
 require golang.org/x/sys v0.36.0 // indirect
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
 	"os"
 	"strconv"
@@ -40,6 +42,28 @@ func env(key, def string) string {
 	return def
 }
 
+// envOrFile returns the value of key, or when unset the trimmed contents of the
+// file referenced by key_FILE (Docker/Kubernetes secrets convention). The
+// inline variable wins when both are set; an empty file yields def. A file
+// that cannot be read is an error rather than a silent fallback to def.
+func envOrFile(key, def string) (string, error) {
+	if v := os.Getenv(key); v != "" {
+		return v, nil
+	}
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return def, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s_FILE: %w", key, err)
+	}
+	if v := strings.TrimRight(string(b), "\r\n"); v != "" {
+		return v, nil
+	}
+	return def, nil
+}
+
 func parseIntEnv(key string, def int) int {
 	v := os.Getenv(key)
 	if v == "" {
@@ -84,16 +108,22 @@ func clampDuration(v, min, max time.Duration) time.Duration {
 
 // BuildClickHouseDSN assembles a ClickHouse DSN from individual env vars if provided.
 // Prefers CLICKHOUSE_DSN if set; otherwise tries CLICKHOUSE_URL/DB/USER/PASS.
-func BuildClickHouseDSN() string {
-	if dsn := env("CLICKHOUSE_DSN", ""); dsn != "" {
-		return dsn
+// CLICKHOUSE_DSN and CLICKHOUSE_PASS may also be read from *_FILE paths; an
+// unreadable one is returned as an error.
+func BuildClickHouseDSN() (string, error) {
+	dsn, err := envOrFile("CLICKHOUSE_DSN", "")
+	if err != nil || dsn != "" {
+		return dsn, err
 	}
 	base := env("CLICKHOUSE_URL", "") // e.g., http://localhost:8123
 	db := env("CLICKHOUSE_DB", "")
 	user := env("CLICKHOUSE_USER", "")
-	pass := env("CLICKHOUSE_PASS", "")
+	pass, err := envOrFile("CLICKHOUSE_PASS", "")
+	if err != nil {
+		return "", err
+	}
 	if base == "" || db == "" {
-		return ""
+		return "", nil
 	}
 	u, err := url.Parse(base)
 	if err == nil {
@@ -115,11 +145,36 @@ func BuildClickHouseDSN() string {
 		default:
 			u.Path = p + "/" + db
 		}
-		return u.String()
+		return u.String(), nil
 	}
 	// Fallback for unparsable base URL
 	base = strings.TrimRight(base, "/")
-	return base + "/" + db
+	return base + "/" + db, nil
+}
+
+// ErrIncompleteClickHouseEnv reports ClickHouse settings that name only half
+// of an endpoint, so BuildClickHouseDSN yields no DSN and nothing would be
+// persisted.
+var ErrIncompleteClickHouseEnv = errors.New("incomplete ClickHouse configuration")
+
+// ClickHouseEnvError explains why BuildClickHouseDSN returned "" although
+// ClickHouse variables are set: CLICKHOUSE_URL without CLICKHOUSE_DB or the
+// reverse. It wraps ErrIncompleteClickHouseEnv, and is nil when a DSN was
+// built or no ClickHouse variable is set at all. An unreadable
+// CLICKHOUSE_DSN_FILE is returned as is.
+func ClickHouseEnvError() error {
+	if dsn, err := envOrFile("CLICKHOUSE_DSN", ""); err != nil || dsn != "" {
+		return err
+	}
+	base := env("CLICKHOUSE_URL", "")
+	db := env("CLICKHOUSE_DB", "")
+	switch {
+	case base != "" && db == "":
+		return fmt.Errorf("%w: CLICKHOUSE_URL is set but CLICKHOUSE_DB is empty", ErrIncompleteClickHouseEnv)
+	case base == "" && db != "":
+		return fmt.Errorf("%w: CLICKHOUSE_DB is set but CLICKHOUSE_URL is empty", ErrIncompleteClickHouseEnv)
+	}
+	return nil
 }
 
 // RedactDSN hides credentials in DSN-like URLs to avoid logging secrets.
@@ -166,14 +221,23 @@ func RedactDSN(s string) string {
 }
 
 // Load reads environment variables and returns a Config with defaults applied.
-func Load() Config {
+// It fails when a *_FILE secret is set but cannot be read.
+func Load() (Config, error) {
 	syncConf := clampInt(parseIntEnv("SYNC_CONFIRMATIONS", 12), minSyncConfirmations, maxSyncConfirmations)
 	batch := clampInt(parseIntEnv("BATCH_BLOCKS", 5000), minBatchBlocks, maxBatchBlocks)
 	rateLimit := clampInt(parseIntEnv("RATE_LIMIT", 0), minRateLimit, maxRateLimit)
 	timeout := clampDuration(parseDurEnv("INGEST_TIMEOUT", 30*time.Second), minIngestTimeout, maxIngestTimeout)
+	providerURL, err := envOrFile("ETH_PROVIDER_URL", "")
+	if err != nil {
+		return Config{}, err
+	}
+	dsn, err := BuildClickHouseDSN()
+	if err != nil {
+		return Config{}, err
+	}
 	return Config{
-		ProviderURL:       env("ETH_PROVIDER_URL", ""),
-		ClickHouseDSN:     BuildClickHouseDSN(),
+		ProviderURL:       providerURL,
+		ClickHouseDSN:     dsn,
 		SyncConfirmations: syncConf,
 		BatchBlocks:       batch,
 		RateLimit:         rateLimit,
@@ -182,5 +246,5 @@ func Load() Config {
 		Timeout:           timeout,
 		HTTPRetries:       parseIntEnv("HTTP_RETRIES", 2),
 		HTTPBackoffBase:   parseDurEnv("HTTP_BACKOFF_BASE", 100*time.Millisecond),
-	}
+	}, nil
 }
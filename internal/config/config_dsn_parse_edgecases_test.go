@@ -8,7 +8,7 @@ func TestBuildClickHouseDSN_FallbackParseError_TrailingSlash(t *testing.T) {
     t.Setenv("CLICKHOUSE_DSN", "")
     t.Setenv("CLICKHOUSE_URL", "http://[/")
     t.Setenv("CLICKHOUSE_DB", "db")
-    if got := BuildClickHouseDSN(); got != "http://[/db" { t.Fatalf("got %q", got) }
+    if got := buildDSN(t); got != "http://[/db" { t.Fatalf("got %q", got) }
 }
 
 func TestRedactDSN_ParseSuccess_NoColonCredsInPath_Unchanged(t *testing.T) {
@@ -38,5 +38,5 @@ func TestBuildClickHouseDSN_FallbackParseError(t *testing.T) {
     t.Setenv("CLICKHOUSE_DSN", "")
     t.Setenv("CLICKHOUSE_URL", "http://[")
     t.Setenv("CLICKHOUSE_DB", "db")
-    if got := BuildClickHouseDSN(); got != "http://[/db" { t.Fatalf("got %q", got) }
+    if got := buildDSN(t); got != "http://[/db" { t.Fatalf("got %q", got) }
 }
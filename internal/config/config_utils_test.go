@@ -25,7 +25,7 @@ func TestBuildClickHouseDSN_PathAppend(t *testing.T) {
     t.Setenv("CLICKHOUSE_DSN", "")
     t.Setenv("CLICKHOUSE_URL", "http://host:8123/base")
     t.Setenv("CLICKHOUSE_DB", "wallets")
-    got := BuildClickHouseDSN()
+    got := buildDSN(t)
     if got != "http://host:8123/base/wallets" { t.Fatalf("got %q", got) }
 }
 
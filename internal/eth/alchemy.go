@@ -0,0 +1,97 @@
+package eth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// MethodAssetTransfers is Alchemy's enhanced transfers API.
+const MethodAssetTransfers = "alchemy_getAssetTransfers"
+
+// alchemyTransferCategories are the transfer kinds whose blocks may hold a
+// transaction touching the address. "external" covers its top-level
+// transactions, zero-value calls included; token categories are a safety
+// net for transactions the API files only under the token they moved.
+var alchemyTransferCategories = []string{"external", "erc20", "erc721", "erc1155"}
+
+// IsAlchemyEndpoint reports whether endpoint is served by Alchemy, whose
+// enhanced APIs WithAlchemyTransfers relies on.
+func IsAlchemyEndpoint(endpoint string) bool {
+	u, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return strings.HasSuffix(host, ".alchemy.com") || strings.HasSuffix(host, ".alchemyapi.io")
+}
+
+// assetTransfer is the subset of an alchemy_getAssetTransfers entry we keep.
+type assetTransfer struct {
+	BlockNum string `json:"blockNum"`
+}
+
+// alchemyTransferBlocks returns the blocks in [from, to] where
+// alchemy_getAssetTransfers reports a transfer from or to address, and the
+// calls it took. The set is nil when the API cannot answer, so the caller
+// walks every block instead; a method-not-found reply turns the API off for
+// later calls.
+func (p *httpProvider) alchemyTransferBlocks(ctx context.Context, address string, from, to uint64) (map[uint64]struct{}, int) {
+	if p.alchemyState() == receiptSupportUnavailable {
+		return nil, 0
+	}
+	calls := 0
+	blocks := map[uint64]struct{}{}
+	for _, side := range []string{"fromAddress", "toAddress"} {
+		pageKey := ""
+		for {
+			params := map[string]any{
+				"fromBlock":        toHex(from),
+				"toBlock":          toHex(to),
+				side:               address,
+				"category":         alchemyTransferCategories,
+				"excludeZeroValue": false,
+				"withMetadata":     false,
+			}
+			if pageKey != "" {
+				params["pageKey"] = pageKey
+			}
+			var res struct {
+				Transfers []assetTransfer `json:"transfers"`
+				PageKey   string          `json:"pageKey"`
+			}
+			calls++
+			if err := p.call(ctx, MethodAssetTransfers, []any{params}, &res); err != nil {
+				if isMethodNotFound(err) {
+					p.setAlchemyState(receiptSupportUnavailable)
+				}
+				return nil, calls
+			}
+			for _, t := range res.Transfers {
+				b, err := hexToUint64(t.BlockNum)
+				if err != nil || b < from || b > to {
+					return nil, calls
+				}
+				blocks[b] = struct{}{}
+			}
+			if res.PageKey == "" || res.PageKey == pageKey {
+				break
+			}
+			pageKey = res.PageKey
+		}
+	}
+	p.setAlchemyState(receiptSupportAvailable)
+	return blocks, calls
+}
+
+func (p *httpProvider) alchemyState() receiptSupportState {
+	p.blockReceiptsMu.Lock()
+	defer p.blockReceiptsMu.Unlock()
+	return p.alchemySupport
+}
+
+func (p *httpProvider) setAlchemyState(state receiptSupportState) {
+	p.blockReceiptsMu.Lock()
+	p.alchemySupport = state
+	p.blockReceiptsMu.Unlock()
+}
@@ -0,0 +1,174 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// alchemyChain serves blocks 1-10, with transactions touching addr in blocks
+// 3 and 7, receipts, and alchemy_getAssetTransfers over them (two pages on
+// the fromAddress side). transfers answers the enhanced API instead when set.
+type alchemyChain struct {
+	addr      string
+	transfers func(params map[string]any) *http.Response
+	blocks    []uint64
+	calls     map[string]int
+}
+
+func (c *alchemyChain) client(t *testing.T) *http.Client {
+	c.calls = map[string]int{}
+	return &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		c.calls[req.Method]++
+		switch req.Method {
+		case MethodAssetTransfers:
+			var params map[string]any
+			_ = json.Unmarshal(req.Params[0], &params)
+			if c.transfers != nil {
+				return c.transfers(params), nil
+			}
+			if params["fromBlock"] != "0x1" || params["toBlock"] != "0xa" {
+				t.Fatalf("transfer range = %v-%v", params["fromBlock"], params["toBlock"])
+			}
+			switch {
+			case params["fromAddress"] == c.addr && params["pageKey"] == nil:
+				return mkResp(map[string]any{"transfers": []map[string]any{{"blockNum": "0x3", "category": "external"}}, "pageKey": "next"}), nil
+			case params["fromAddress"] == c.addr:
+				return mkResp(map[string]any{"transfers": []map[string]any{{"blockNum": "0x3", "category": "erc20"}}}), nil
+			case params["toAddress"] == c.addr:
+				return mkResp(map[string]any{"transfers": []map[string]any{{"blockNum": "0x7", "category": "external"}}}), nil
+			}
+			t.Fatalf("unexpected transfer params: %v", params)
+		case MethodGetBlockByNumber:
+			var blockHex string
+			_ = json.Unmarshal(req.Params[0], &blockHex)
+			b, _ := hexToUint64(blockHex)
+			c.blocks = append(c.blocks, b)
+			txs := []map[string]any{{"hash": "0xother" + blockHex[2:], "from": "0x" + strings.Repeat("1", 40), "to": "0x" + strings.Repeat("2", 40), "input": "0x", "value": "0x1"}}
+			switch b {
+			case 3:
+				txs = append(txs, map[string]any{"hash": "0xsent", "from": c.addr, "to": "0x" + strings.Repeat("3", 40), "input": "0xa9059cbb", "value": "0x0"})
+			case 7:
+				txs = append(txs, map[string]any{"hash": "0xreceived", "from": "0x" + strings.Repeat("4", 40), "to": c.addr, "input": "0x", "value": "0xde0b6b3a7640000"})
+			}
+			return mkResp(map[string]any{"timestamp": toHex(b * 12), "transactions": txs}), nil
+		case "eth_getTransactionReceipt":
+			var hash string
+			_ = json.Unmarshal(req.Params[0], &hash)
+			gas := map[string]string{"0xsent": "0xb411", "0xreceived": "0x5208"}[hash]
+			return mkResp(map[string]any{"transactionHash": hash, "status": "0x1", "gasUsed": gas, "effectiveGasPrice": "0x3b9aca00"}), nil
+		}
+		return mkResp(nil), nil
+	})}
+}
+
+func (c *alchemyChain) transactions(t *testing.T, opts ...ProviderOption) []Transaction {
+	t.Helper()
+	p, _ := NewHTTPProvider("http://unit-test", c.client(t))
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	WithReceiptWorkers(1)(hp)
+	for _, opt := range opts {
+		opt(hp)
+	}
+	out, err := p.Transactions(context.Background(), c.addr, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestHTTPProvider_AlchemyTransfersMatchBlockWalk(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	standard := &alchemyChain{addr: addr}
+	want := standard.transactions(t)
+	if len(want) != 2 || len(standard.blocks) != 10 {
+		t.Fatalf("standard path: %d txs over %d blocks", len(want), len(standard.blocks))
+	}
+
+	enhanced := &alchemyChain{addr: addr}
+	got := enhanced.transactions(t, WithAlchemyTransfers())
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("alchemy path:\n%+v\nwant\n%+v", got, want)
+	}
+	if !reflect.DeepEqual(enhanced.blocks, []uint64{3, 7}) {
+		t.Fatalf("fetched blocks = %v, want [3 7]", enhanced.blocks)
+	}
+	if enhanced.calls[MethodAssetTransfers] != 3 {
+		t.Fatalf("%s calls = %d, want 3 (two pages plus toAddress)", MethodAssetTransfers, enhanced.calls[MethodAssetTransfers])
+	}
+}
+
+func TestHTTPProvider_AlchemyTransfersFallBack(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	want := (&alchemyChain{addr: addr}).transactions(t)
+
+	cases := []struct {
+		name  string
+		resp  func() *http.Response
+		again int
+	}{
+		// An unknown method turns the API off for the provider's lifetime.
+		{"method not found", func() *http.Response { return mkRespErr(-32601, "the method alchemy_getAssetTransfers does not exist") }, 0},
+		// Any other failure only affects the one call.
+		{"server error", func() *http.Response { return mkRespErr(-32000, "internal error") }, 1},
+		{"block outside range", func() *http.Response {
+			return mkResp(map[string]any{"transfers": []map[string]any{{"blockNum": "0x20"}}})
+		}, 1},
+	}
+	for _, tc := range cases {
+		c := &alchemyChain{addr: addr, transfers: func(map[string]any) *http.Response { return tc.resp() }}
+		p, _ := NewHTTPProvider("http://unit-test", c.client(t))
+		hp := p.(*httpProvider)
+		hp.backoffBase = 1
+		WithReceiptWorkers(1)(hp)
+		WithAlchemyTransfers()(hp)
+		got, err := p.Transactions(context.Background(), addr, 1, 10)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got %+v err=%v", tc.name, got, err)
+		}
+		if len(c.blocks) != 10 {
+			t.Fatalf("%s: fetched %d blocks, want the whole range", tc.name, len(c.blocks))
+		}
+		c.calls[MethodAssetTransfers] = 0
+		if _, err := p.Transactions(context.Background(), addr, 1, 10); err != nil {
+			t.Fatal(err)
+		}
+		if c.calls[MethodAssetTransfers] != tc.again {
+			t.Fatalf("%s: retried the API %d times, want %d", tc.name, c.calls[MethodAssetTransfers], tc.again)
+		}
+	}
+}
+
+func TestHTTPProvider_AlchemyTransfersEmpty(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	c := &alchemyChain{addr: addr, transfers: func(map[string]any) *http.Response {
+		return mkResp(map[string]any{"transfers": []any{}})
+	}}
+	if got := c.transactions(t, WithAlchemyTransfers()); len(got) != 0 || len(c.blocks) != 0 {
+		t.Fatalf("got %d txs over %d blocks, want none", len(got), len(c.blocks))
+	}
+}
+
+func TestIsAlchemyEndpoint(t *testing.T) {
+	for endpoint, want := range map[string]bool{
+		"https://eth-mainnet.g.alchemy.com/v2/key": true,
+		" https://eth-mainnet.alchemyapi.io/v2/k ": true,
+		"https://mainnet.infura.io/v3/key":         false,
+		"https://alchemy.com.evil.example/v2/key":  false,
+		"http://localhost:8545":                    false,
+		"://bad":                                   false,
+	} {
+		if got := IsAlchemyEndpoint(endpoint); got != want {
+			t.Fatalf("%q: got %v, want %v", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// BalanceAt reads the wei balance of address at block via eth_getBalance.
+func (p *httpProvider) BalanceAt(ctx context.Context, address string, block uint64) (*big.Int, error) {
+	var res string
+	if err := p.call(ctx, "eth_getBalance", []interface{}{address, toHex(block)}, &res); err != nil {
+		return nil, err
+	}
+	return decodeQuantity(res)
+}
+
+// decodeQuantity parses a hex quantity of arbitrary size (e.g. a wei balance).
+func decodeQuantity(s string) (*big.Int, error) {
+	h := strings.TrimSpace(s)
+	if !strings.HasPrefix(h, "0x") && !strings.HasPrefix(h, "0X") {
+		return nil, fmt.Errorf("invalid hex quantity: %q", s)
+	}
+	v, ok := new(big.Int).SetString(h[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity: %q", s)
+	}
+	return v, nil
+}
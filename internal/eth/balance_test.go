@@ -0,0 +1,75 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func balanceProvider(t *testing.T, result any) (*httpProvider, *[]any) {
+	t.Helper()
+	var params []any
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_getBalance" {
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+		params = req.Params
+		if s, ok := result.(string); ok && strings.HasPrefix(s, "rpcerr:") {
+			return mkRespErr(-32000, strings.TrimPrefix(s, "rpcerr:")), nil
+		}
+		return mkResp(result), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	return hp, &params
+}
+
+func TestBalanceAt_DecodesWei(t *testing.T) {
+	// 2^80 wei does not fit in uint64.
+	hp, params := balanceProvider(t, "0x100000000000000000000")
+	got, err := hp.BalanceAt(context.Background(), "0xabc", 255)
+	if err != nil || got.String() != "1208925819614629174706176" {
+		t.Fatalf("balance=%v err=%v", got, err)
+	}
+	if p := *params; len(p) != 2 || p[0] != "0xabc" || p[1] != "0xff" {
+		t.Fatalf("unexpected params: %v", p)
+	}
+}
+
+func TestBalanceAt_Errors(t *testing.T) {
+	hp, _ := balanceProvider(t, "rpcerr:boom")
+	if _, err := hp.BalanceAt(context.Background(), "0x1", 1); err == nil {
+		t.Fatal("expected rpc error")
+	}
+	for _, res := range []string{"42", "0xzz", "0x"} {
+		hp, _ = balanceProvider(t, res)
+		if _, err := hp.BalanceAt(context.Background(), "0x1", 1); err == nil {
+			t.Fatalf("%q: expected quantity error", res)
+		}
+	}
+}
+
+func TestRLProvider_BalanceAt(t *testing.T) {
+	hp, _ := balanceProvider(t, "0x2a")
+	br := WrapWithLimiter(hp, NewLimiter(0)).(BalanceReader)
+	if v, err := br.BalanceAt(context.Background(), "0x1", 1); err != nil || v.Int64() != 42 {
+		t.Fatalf("balance=%v err=%v", v, err)
+	}
+	if _, err := (RLProvider{p: fakeProvider{}, l: NewLimiter(0)}).BalanceAt(context.Background(), "0x1", 1); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := (RLProvider{p: hp, l: errLimiter{}}).BalanceAt(context.Background(), "0x1", 1); err == nil {
+		t.Fatal("expected limiter error")
+	}
+}
@@ -0,0 +1,114 @@
+package eth
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// bloomBytes is the size of a block header's logsBloom (2048 bits).
+const bloomBytes = 256
+
+// bloomMayContain reports whether value may have been added to bloom. Like
+// every Bloom filter it has false positives but no false negatives: each
+// value sets the three bits picked by the first six bytes of its Keccak-256
+// hash (yellow paper, M3:2048).
+func bloomMayContain(bloom, value []byte) bool {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(value)
+	sum := h.Sum(nil)
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(sum[i])<<8 | uint(sum[i+1])) & 2047
+		if bloom[bloomBytes-1-bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// logsBloomMatches reports whether a block whose header carries bloom may
+// hold a log from address matching topics (eth_getLogs semantics: every
+// non-empty position needs one of its topics). Values that do not decode,
+// and blooms of the wrong size, match so nothing is skipped wrongly.
+func logsBloomMatches(bloom []byte, address string, topics [][]string) bool {
+	if len(bloom) != bloomBytes {
+		return true
+	}
+	if address != "" && !bloomMayContainHex(bloom, address) {
+		return false
+	}
+	for _, group := range topics {
+		if len(group) == 0 {
+			continue
+		}
+		hit := false
+		for _, topic := range group {
+			if bloomMayContainHex(bloom, topic) {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+	return true
+}
+
+func bloomMayContainHex(bloom []byte, value string) bool {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(value), "0x"))
+	if err != nil {
+		return true
+	}
+	return bloomMayContain(bloom, b)
+}
+
+// logsBloom fetches a block header's logsBloom, caching its timestamp for
+// the log enrichment that follows.
+func (p *httpProvider) logsBloom(ctx context.Context, block uint64) ([]byte, error) {
+	v, err, _ := p.flight.Do("bloom:"+strconv.FormatUint(block, 10), func() (any, error) {
+		var hdr struct {
+			Timestamp string `json:"timestamp"`
+			LogsBloom string `json:"logsBloom"`
+		}
+		if err := p.getBlock(ctx, block, false, &hdr); err != nil {
+			return nil, err
+		}
+		if sec, err := hexToUint64(hdr.Timestamp); err == nil && p.blkCache != nil {
+			p.blkCache.add(block, int64(sec)*1000, p.clk().Now())
+		}
+		return hex.DecodeString(strings.TrimPrefix(hdr.LogsBloom, "0x"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// bloomCandidates returns the runs of blocks in [from, to] whose logsBloom
+// may match address and topics. Blocks whose header cannot be read count as
+// candidates.
+func (p *httpProvider) bloomCandidates(ctx context.Context, address string, from, to uint64, topics [][]string) ([]BlockRange, error) {
+	var runs []BlockRange
+	for b := from; b <= to; b++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		bloom, err := p.logsBloom(ctx, b)
+		if err == nil && !logsBloomMatches(bloom, address, topics) {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1].To == b-1 {
+			runs[n-1].To = b
+		} else {
+			runs = append(runs, BlockRange{From: b, To: b})
+		}
+		if b == to {
+			break
+		}
+	}
+	return runs, nil
+}
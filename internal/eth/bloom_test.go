@@ -0,0 +1,121 @@
+package eth
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// bloomAdd sets value's three bits in bloom.
+func bloomAdd(bloom []byte, value string) {
+	b, _ := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	h := sha3.NewLegacyKeccak256()
+	h.Write(b)
+	sum := h.Sum(nil)
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(sum[i])<<8 | uint(sum[i+1])) & 2047
+		bloom[bloomBytes-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+func TestLogsBloomMatches(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	transfer := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	approval := "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+	bloom := make([]byte, bloomBytes)
+	bloomAdd(bloom, addr)
+	bloomAdd(bloom, transfer)
+
+	cases := []struct {
+		address string
+		topics  [][]string
+		want    bool
+	}{
+		{addr, nil, true},
+		{"0x" + strings.Repeat("cd", 20), nil, false},
+		{addr, [][]string{{transfer}}, true},
+		{addr, [][]string{{approval}}, false},
+		{addr, [][]string{{approval, transfer}}, true},
+		{addr, [][]string{nil, {transfer}}, true},
+		{"", [][]string{{transfer}}, true},
+		{"0xnothex", nil, true},
+	}
+	for _, tc := range cases {
+		if got := logsBloomMatches(bloom, tc.address, tc.topics); got != tc.want {
+			t.Fatalf("address=%s topics=%v: got %v, want %v", tc.address, tc.topics, got, tc.want)
+		}
+	}
+	if !logsBloomMatches(make([]byte, 10), "0x"+strings.Repeat("cd", 20), nil) {
+		t.Fatal("a malformed bloom must not rule out blocks")
+	}
+	if logsBloomMatches(make([]byte, bloomBytes), addr, nil) {
+		t.Fatal("an empty bloom holds nothing")
+	}
+}
+
+func TestHTTPProvider_GetLogsBloomSkipsBlocks(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	blooms := map[uint64][]byte{}
+	for b := uint64(1); b <= 10; b++ {
+		blooms[b] = make([]byte, bloomBytes)
+	}
+	// Blocks 3-4 and 8 hold the address's logs; 6 is a bloom false positive.
+	for _, b := range []uint64{3, 4, 6, 8} {
+		bloomAdd(blooms[b], addr)
+	}
+	bloomAdd(blooms[9], "0x"+strings.Repeat("cd", 20))
+	logBlocks := map[uint64]bool{3: true, 4: true, 8: true}
+
+	var ranges [][2]uint64
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req.Method {
+		case "eth_getBlockByNumber":
+			var blockHex string
+			_ = json.Unmarshal(req.Params[0], &blockHex)
+			b, _ := hexToUint64(blockHex)
+			if b == 10 {
+				return mkRespErr(-32000, "header unavailable"), nil
+			}
+			return mkResp(map[string]any{"timestamp": toHex(b), "logsBloom": "0x" + hex.EncodeToString(blooms[b])}), nil
+		case "eth_getLogs":
+			var f struct{ FromBlock, ToBlock string }
+			_ = json.Unmarshal(req.Params[0], &f)
+			from, _ := hexToUint64(f.FromBlock)
+			to, _ := hexToUint64(f.ToBlock)
+			ranges = append(ranges, [2]uint64{from, to})
+			var logs []map[string]any
+			for b := from; b <= to; b++ {
+				if logBlocks[b] {
+					logs = append(logs, map[string]any{"transactionHash": "0x" + toHex(b)[2:], "logIndex": "0x0", "address": addr, "blockNumber": toHex(b)})
+				}
+			}
+			return mkResp(logs), nil
+		}
+		return mkResp(nil), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	WithLogsBloomFilter()(p.(*httpProvider))
+	out, err := p.GetLogs(context.Background(), addr, 1, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Block 10's header failed, so it is queried rather than skipped.
+	want := [][2]uint64{{3, 4}, {6, 6}, {8, 8}, {10, 10}}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("eth_getLogs ranges = %v, want %v", ranges, want)
+	}
+	if len(out) != 3 || out[0].BlockNum != 3 || out[2].BlockNum != 8 || out[2].TsMillis != 8000 {
+		t.Fatalf("logs = %+v", out)
+	}
+}
@@ -0,0 +1,12 @@
+package eth
+
+import "github.com/AIAleph/mvp_wallet_context/pkg/bodylimit"
+
+// ErrResponseTooLarge is returned when a JSON-RPC response body exceeds the
+// provider's size cap (WithMaxResponseBytes), guarding against endpoints
+// that stream without end.
+var ErrResponseTooLarge = bodylimit.ErrTooLarge
+
+// DefaultMaxResponseBytes caps JSON-RPC response bodies unless
+// WithMaxResponseBytes overrides it.
+const DefaultMaxResponseBytes = bodylimit.DefaultMaxBytes
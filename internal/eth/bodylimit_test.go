@@ -0,0 +1,61 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// endless yields '1' forever, like an endpoint that never stops streaming.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '1'
+	}
+	return len(p), nil
+}
+
+func TestHTTPProvider_ResponseTooLarge(t *testing.T) {
+	calls := 0
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		body := io.MultiReader(strings.NewReader(`{"jsonrpc":"2.0","id":1,"result":"0x`), endless{})
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(body)}, nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	WithMaxResponseBytes(1024)(p.(*httpProvider))
+	if _, err := p.BlockNumber(context.Background()); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("err = %v, want ErrResponseTooLarge", err)
+	}
+	if calls != 1 {
+		t.Fatalf("oversized response retried: %d calls", calls)
+	}
+}
+
+func TestHTTPProvider_ResponseWithinLimit(t *testing.T) {
+	payload := []byte(`{"jsonrpc":"2.0","id":1,"result":"0x2a"}`)
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(payload))}, nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	if hp.maxBody != DefaultMaxResponseBytes {
+		t.Fatalf("default cap = %d", hp.maxBody)
+	}
+	// A body of exactly the cap is not too large.
+	hp.maxBody = int64(len(payload))
+	if n, err := p.BlockNumber(context.Background()); err != nil || n != 42 {
+		t.Fatalf("BlockNumber = %d, %v", n, err)
+	}
+}
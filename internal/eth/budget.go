@@ -0,0 +1,50 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned for JSON-RPC calls a CallBudget has no room
+// left for. Nothing is sent, so the caller can checkpoint and stop.
+var ErrBudgetExceeded = errors.New("rpc call budget exceeded")
+
+// CallBudget caps the JSON-RPC calls providers sharing it may send. Each
+// call counts once, each element of a batch counts as a call, and HTTP
+// retries of a call are not counted again.
+type CallBudget struct {
+	mu   sync.Mutex
+	max  uint64
+	used uint64
+}
+
+// NewCallBudget allows max calls in total.
+func NewCallBudget(max uint64) *CallBudget {
+	return &CallBudget{max: max}
+}
+
+// take reserves n calls, failing without reserving any when fewer remain.
+// A nil budget is unlimited.
+func (b *CallBudget) take(n int) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+uint64(n) > b.max {
+		return fmt.Errorf("%w: %d of %d calls used, %d more needed", ErrBudgetExceeded, b.used, b.max, n)
+	}
+	b.used += uint64(n)
+	return nil
+}
+
+// Used returns how many calls have been sent.
+func (b *CallBudget) Used() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Max returns the budget's cap.
+func (b *CallBudget) Max() uint64 { return b.max }
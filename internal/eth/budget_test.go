@@ -0,0 +1,48 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCallBudget_StopsSendingAtCap(t *testing.T) {
+	sent := 0
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		sent++
+		return mkResp("0x2a"), nil
+	})}
+	budget := NewCallBudget(3)
+	mk := func() *httpProvider {
+		base, err := NewHTTPProvider("http://rpc.local", client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hp := base.(*httpProvider)
+		WithCallBudget(budget)(hp)
+		return hp
+	}
+	a, b := mk(), mk()
+	for _, p := range []*httpProvider{a, b} {
+		if _, err := p.BlockNumber(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A batch larger than what is left is refused whole.
+	if _, err := a.callBatch(context.Background(), MethodGetBlockReceipts, make([]rpcRequest, 2)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("batch err = %v, want ErrBudgetExceeded", err)
+	}
+	if _, err := b.BlockNumber(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.BlockNumber(context.Background()); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if _, err := a.GetLogs(context.Background(), "0xabc", 1, 1, nil); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("GetLogs err = %v, want ErrBudgetExceeded", err)
+	}
+	if sent != 3 || budget.Used() != 3 || budget.Max() != 3 {
+		t.Fatalf("sent=%d used=%d; calls past the budget must not be sent", sent, budget.Used())
+	}
+}
@@ -0,0 +1,45 @@
+package eth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrExecutionReverted is wrapped by CallContract errors for calls the EVM
+// reverted, as opposed to transport or node failures.
+var ErrExecutionReverted = errors.New("execution reverted")
+
+// CallContract runs data against to at block via eth_call.
+func (p *httpProvider) CallContract(ctx context.Context, from, to, data string, block uint64) ([]byte, error) {
+	msg := map[string]string{"to": to, "data": data}
+	if from != "" {
+		msg["from"] = from
+	}
+	var res string
+	if err := p.call(ctx, "eth_call", []interface{}{msg, toHex(block)}, &res); err != nil {
+		var re *rpcError
+		if errors.As(err, &re) && isRevert(re) {
+			return nil, fmt.Errorf("%w: %s", ErrExecutionReverted, re.detail())
+		}
+		return nil, err
+	}
+	out, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(res, "0x"), "0X"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid call result %q: %w", res, err)
+	}
+	return out, nil
+}
+
+// isRevert reports whether a JSON-RPC error is the node reporting a failed
+// execution. Geth uses code 3 when revert data is attached and -32000
+// otherwise; other clients only say so in the message.
+func isRevert(e *rpcError) bool {
+	if e.Code == 3 {
+		return true
+	}
+	m := strings.ToLower(e.Message)
+	return strings.Contains(m, "revert") || strings.Contains(m, "invalid opcode") || strings.Contains(m, "vm execution error")
+}
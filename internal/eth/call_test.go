@@ -0,0 +1,90 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func callProvider(t *testing.T, respond func() *http.Response) (*httpProvider, *[]any) {
+	t.Helper()
+	var params []any
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_call" {
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+		params = req.Params
+		return respond(), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	return hp, &params
+}
+
+func TestCallContract_ReturnsData(t *testing.T) {
+	hp, params := callProvider(t, func() *http.Response { return mkResp("0x00ff") })
+	got, err := hp.CallContract(context.Background(), "", "0xtoken", "0x70a08231", 255)
+	if err != nil || len(got) != 2 || got[1] != 0xff {
+		t.Fatalf("data=%x err=%v", got, err)
+	}
+	p := *params
+	msg, _ := p[0].(map[string]any)
+	if len(p) != 2 || p[1] != "0xff" || msg["to"] != "0xtoken" || msg["data"] != "0x70a08231" {
+		t.Fatalf("unexpected params: %v", p)
+	}
+	if _, ok := msg["from"]; ok {
+		t.Fatalf("empty from should be omitted: %v", msg)
+	}
+	if _, err := hp.CallContract(context.Background(), "0xsender", "0xtoken", "0x", 1); err != nil || (*params)[0].(map[string]any)["from"] != "0xsender" {
+		t.Fatalf("from not sent: %v err=%v", *params, err)
+	}
+}
+
+func TestCallContract_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		code     int
+		msg      string
+		reverted bool
+	}{
+		{3, "execution reverted: not owner", true},
+		{-32000, "execution reverted", true},
+		{-32015, "VM execution error.", true},
+		{-32000, "header not found", false},
+		{-32601, "the method eth_call does not exist", false},
+	} {
+		hp, _ := callProvider(t, func() *http.Response { return mkRespErr(tc.code, tc.msg) })
+		_, err := hp.CallContract(context.Background(), "", "0xtoken", "0x", 1)
+		if err == nil || errors.Is(err, ErrExecutionReverted) != tc.reverted {
+			t.Fatalf("%d %q: err=%v, want reverted=%v", tc.code, tc.msg, err, tc.reverted)
+		}
+	}
+	hp, _ := callProvider(t, func() *http.Response { return mkResp("0xzz") })
+	if _, err := hp.CallContract(context.Background(), "", "0xtoken", "0x", 1); err == nil {
+		t.Fatal("expected decode error")
+	}
+}
+
+func TestRLProvider_CallContract(t *testing.T) {
+	hp, _ := callProvider(t, func() *http.Response { return mkResp("0x01") })
+	cc := WrapWithLimiter(hp, NewLimiter(0)).(ContractCaller)
+	if got, err := cc.CallContract(context.Background(), "", "0x1", "0x", 1); err != nil || len(got) != 1 {
+		t.Fatalf("data=%x err=%v", got, err)
+	}
+	if _, err := (RLProvider{p: fakeProvider{}, l: NewLimiter(0)}).CallContract(context.Background(), "", "0x1", "0x", 1); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := (RLProvider{p: hp, l: errLimiter{}}).CallContract(context.Background(), "", "0x1", "0x", 1); err == nil {
+		t.Fatal("expected limiter error")
+	}
+}
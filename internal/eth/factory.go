@@ -4,14 +4,145 @@ import (
     "net/http"
     "strings"
     "time"
+
+    "github.com/AIAleph/mvp_wallet_context/pkg/clock"
 )
 
+// ProviderOption tunes the concrete HTTP provider built by NewProvider.
+type ProviderOption func(*httpProvider)
+
+// WithLatencyRecorder records per JSON-RPC method call latency into rec.
+func WithLatencyRecorder(rec *LatencyRecorder) ProviderOption {
+    return func(p *httpProvider) { p.latency = rec }
+}
+
+// WithCallBudget makes the provider stop sending JSON-RPC calls once b is
+// spent, failing them with ErrBudgetExceeded. Providers given the same b
+// share it.
+func WithCallBudget(b *CallBudget) ProviderOption {
+    return func(p *httpProvider) { p.budget = b }
+}
+
+// WithReceiptWorkers bounds concurrent per-transaction receipt fetches
+// (n <= 0 keeps the default). One worker makes request order deterministic.
+func WithReceiptWorkers(n int) ProviderOption {
+    return func(p *httpProvider) {
+        if n > 0 { p.receiptWorkers = n }
+    }
+}
+
+// WithReceiptBatchSize sets how many per-transaction receipt calls share one
+// JSON-RPC batch when eth_getBlockReceipts is unavailable (n <= 1 disables
+// batching and uses the worker pool only).
+func WithReceiptBatchSize(n int) ProviderOption {
+    return func(p *httpProvider) { p.receiptBatchSize = n }
+}
+
+// WithUserAgent sets the User-Agent sent on every JSON-RPC request (empty
+// keeps DefaultUserAgent).
+func WithUserAgent(ua string) ProviderOption {
+    return func(p *httpProvider) {
+        if ua != "" { p.userAgent = ua }
+    }
+}
+
+// WithStrictTransactions makes Transactions return an error when any block or
+// receipt in the range fails, rather than the transactions it could fetch.
+func WithStrictTransactions() ProviderOption {
+    return func(p *httpProvider) { p.strict = true }
+}
+
+// WithReceiptlessTransactions makes Transactions return transactions whose
+// receipt cannot be fetched, flagged ReceiptMissing with zeroed gas and
+// status, instead of skipping them. It suits providers that have pruned old
+// receipts; the block is still reported missing so a later run can refetch
+// it. WithStrictTransactions takes precedence.
+func WithReceiptlessTransactions() ProviderOption {
+    return func(p *httpProvider) { p.keepReceiptless = true }
+}
+
+// WithLogsBloomFilter makes GetLogs test each block's logsBloom and skip
+// blocks that cannot hold a matching log. It pays one header fetch per block
+// to save eth_getLogs calls, which suits sparse addresses on providers where
+// eth_getLogs is slow or tightly limited.
+func WithLogsBloomFilter() ProviderOption {
+    return func(p *httpProvider) { p.bloomFilter = true }
+}
+
+// WithoutTimestampEnrichment makes GetLogs and TraceBlock return logs and
+// traces with TsMillis left at 0, skipping the eth_getBlockByNumber call per
+// block that fills it. It suits bulk jobs that take timestamps from elsewhere,
+// such as the ingester's own timestamp cache or a blocks table.
+func WithoutTimestampEnrichment() ProviderOption {
+    return func(p *httpProvider) { p.noTimestamps = true }
+}
+
+// WithAlchemyTransfers makes Transactions ask Alchemy's
+// alchemy_getAssetTransfers which blocks hold transfers from or to the
+// address and fetch only those, instead of every block in the range. The
+// returned transactions are built from the same blocks and receipts as
+// without it. Should the API fail, the whole range is walked; on endpoints
+// that do not know the method it is not tried again.
+func WithAlchemyTransfers() ProviderOption {
+    return func(p *httpProvider) { p.alchemy = true }
+}
+
+// WithTraceLimits makes TraceBlock return ErrTraceLimitExceeded once a range
+// yields more than maxTraces traces or needs more than maxPages trace_filter
+// pages, instead of accumulating them all (0 leaves either unlimited).
+func WithTraceLimits(maxTraces, maxPages int) ProviderOption {
+    return func(p *httpProvider) {
+        p.maxTraces = maxTraces
+        p.maxTracePages = maxPages
+    }
+}
+
+// WithMethodRateLimits gives the listed JSON-RPC methods (see
+// ParseMethodRates) their own req/s limit in place of the global one, taken
+// for every request sent rather than per Provider call, so receipt lookups
+// and trace_filter pages each count; 0 leaves a method unlimited.
+func WithMethodRateLimits(rates map[string]int) ProviderOption {
+    return func(p *httpProvider) {
+        p.methodLimiters = make(map[string]Limiter, len(rates))
+        for method, rate := range rates {
+            p.methodLimiters[method] = NewLimiter(rate)
+        }
+    }
+}
+
+// WithHedging sends a duplicate of any JSON-RPC request that has not been
+// answered within delay, to hedgeEndpoint or, when empty, the same endpoint,
+// and uses whichever response arrives first; the slower attempt is
+// canceled. It trims tail latency at the cost of extra requests, which the
+// rate limiter does not count. delay <= 0 disables hedging.
+func WithHedging(delay time.Duration, hedgeEndpoint string) ProviderOption {
+    return func(p *httpProvider) {
+        p.hedgeDelay = delay
+        p.hedgeEndpoint = strings.TrimSpace(hedgeEndpoint)
+    }
+}
+
+// WithMaxResponseBytes caps each JSON-RPC response body at n bytes; reading
+// past it fails the call with ErrResponseTooLarge (n <= 0 keeps
+// DefaultMaxResponseBytes).
+func WithMaxResponseBytes(n int64) ProviderOption {
+    return func(p *httpProvider) {
+        if n > 0 { p.maxBody = n }
+    }
+}
+
+// WithClock makes timestamp cache expiry and retry backoff follow c instead
+// of the wall clock, e.g. a clock.Fake in tests (nil keeps clock.Real).
+func WithClock(c clock.Clock) ProviderOption {
+    return func(p *httpProvider) { p.clock = clock.Or(c) }
+}
+
 // NewProvider constructs a concrete Provider for the given endpoint and wraps it
 // with a rate limiter. For now, it returns a minimal stub for http(s) endpoints.
 // Validation is centralized in NewHTTPProvider (after trimming whitespace) to keep
 // behavior in one place. When real adapters are added (Alchemy/Infura/etc.),
 // switch on host/scheme here and retain centralized validation.
-func NewProvider(endpoint string, rateLimit int, retries int, backoff time.Duration) (Provider, error) {
+func NewProvider(endpoint string, rateLimit int, retries int, backoff time.Duration, opts ...ProviderOption) (Provider, error) {
     // Validate via concrete provider constructor to keep single source of truth
     base, err := NewHTTPProvider(strings.TrimSpace(endpoint), &http.Client{})
     if err != nil { return nil, err }
@@ -19,6 +150,9 @@ func NewProvider(endpoint string, rateLimit int, retries int, backoff time.Durat
     if hp, ok := base.(*httpProvider); ok {
         if retries > 0 { hp.maxRetries = retries }
         if backoff > 0 { hp.backoffBase = backoff }
+        for _, opt := range opts {
+            if opt != nil { opt(hp) }
+        }
     }
     return WrapWithLimiter(base, NewLimiter(rateLimit)), nil
 }
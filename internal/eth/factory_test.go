@@ -15,3 +15,27 @@ func TestFactory_WrapsLimiterAndValidates(t *testing.T) {
         t.Fatalf("expected RLProvider wrapper, got %T", p)
     }
 }
+
+func TestFactory_WithReceiptWorkers(t *testing.T) {
+    p, err := NewProvider("http://localhost:8545", 0, 0, 0, WithReceiptWorkers(1))
+    if err != nil { t.Fatal(err) }
+    if hp := p.(RLProvider).p.(*httpProvider); hp.receiptWorkers != 1 {
+        t.Fatalf("receiptWorkers = %d, want 1", hp.receiptWorkers)
+    }
+    p, _ = NewProvider("http://localhost:8545", 0, 0, 0, WithReceiptWorkers(0))
+    if hp := p.(RLProvider).p.(*httpProvider); hp.receiptWorkers != 4 {
+        t.Fatalf("receiptWorkers = %d, want default 4", hp.receiptWorkers)
+    }
+}
+
+func TestFactory_WithReceiptBatchSize(t *testing.T) {
+    p, err := NewProvider("http://localhost:8545", 0, 0, 0)
+    if err != nil { t.Fatal(err) }
+    if hp := p.(RLProvider).p.(*httpProvider); hp.receiptBatchSize != DefaultReceiptBatchSize {
+        t.Fatalf("receiptBatchSize = %d, want default %d", hp.receiptBatchSize, DefaultReceiptBatchSize)
+    }
+    p, _ = NewProvider("http://localhost:8545", 0, 0, 0, WithReceiptBatchSize(0))
+    if hp := p.(RLProvider).p.(*httpProvider); hp.receiptBatchSize != 0 || hp.shouldBatchReceipts(10) {
+        t.Fatalf("expected batching disabled, size=%d", hp.receiptBatchSize)
+    }
+}
@@ -0,0 +1,22 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+)
+
+// BlockNumberByTag resolves a block tag such as "safe" or "finalized" via
+// eth_getBlockByNumber. Nodes that predate the tags answer with an RPC error
+// or a null block; both are reported as errors.
+func (p *httpProvider) BlockNumberByTag(ctx context.Context, tag string) (uint64, error) {
+	var res *struct {
+		Number string `json:"number"`
+	}
+	if err := p.call(ctx, "eth_getBlockByNumber", []interface{}{tag, false}, &res); err != nil {
+		return 0, err
+	}
+	if res == nil {
+		return 0, fmt.Errorf("block tag %q not found", tag)
+	}
+	return hexToUint64(res.Number)
+}
@@ -0,0 +1,70 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func finalityProvider(t *testing.T, result any) (*httpProvider, *[]any) {
+	t.Helper()
+	var params []any
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_getBlockByNumber" {
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+		params = req.Params
+		if s, ok := result.(string); ok && strings.HasPrefix(s, "rpcerr:") {
+			return mkRespErr(-32000, strings.TrimPrefix(s, "rpcerr:")), nil
+		}
+		return mkResp(result), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	return hp, &params
+}
+
+func TestBlockNumberByTag(t *testing.T) {
+	hp, params := finalityProvider(t, map[string]any{"number": "0x64"})
+	got, err := hp.BlockNumberByTag(context.Background(), TagFinalized)
+	if err != nil || got != 100 {
+		t.Fatalf("block=%d err=%v", got, err)
+	}
+	if p := *params; len(p) != 2 || p[0] != "finalized" || p[1] != false {
+		t.Fatalf("unexpected params: %v", p)
+	}
+}
+
+func TestBlockNumberByTag_Errors(t *testing.T) {
+	for name, res := range map[string]any{"rpc": "rpcerr:unknown block", "null": nil, "number": map[string]any{"number": "zz"}} {
+		hp, _ := finalityProvider(t, res)
+		if _, err := hp.BlockNumberByTag(context.Background(), TagSafe); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRLProvider_BlockNumberByTag(t *testing.T) {
+	hp, _ := finalityProvider(t, map[string]any{"number": "0x2a"})
+	fr := WrapWithLimiter(hp, NewLimiter(0)).(FinalityReader)
+	if v, err := fr.BlockNumberByTag(context.Background(), TagSafe); err != nil || v != 42 {
+		t.Fatalf("block=%d err=%v", v, err)
+	}
+	if _, err := (RLProvider{p: fakeProvider{}, l: NewLimiter(0)}).BlockNumberByTag(context.Background(), TagSafe); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := (RLProvider{p: hp, l: errLimiter{}}).BlockNumberByTag(context.Background(), TagSafe); err == nil {
+		t.Fatal("expected limiter error")
+	}
+}
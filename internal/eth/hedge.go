@@ -0,0 +1,115 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// send posts reqBody to endpoint once.
+func (p *httpProvider) send(ctx context.Context, endpoint string, reqBody []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+	return p.hc.Do(req)
+}
+
+// do sends reqBody, hedged when WithHedging is set.
+func (p *httpProvider) do(ctx context.Context, reqBody []byte) (*http.Response, error) {
+	if p.hedgeDelay <= 0 {
+		return p.send(ctx, p.endpoint, reqBody)
+	}
+	return p.hedgedDo(ctx, reqBody)
+}
+
+// hedgedResult is the outcome of one hedged attempt.
+type hedgedResult struct {
+	n    int
+	resp *http.Response
+	err  error
+}
+
+// hedgedDo sends reqBody and, if no response has arrived after hedgeDelay, a
+// duplicate to hedgeEndpoint. The first response wins and the other attempt
+// is canceled, its response, if any, discarded unread. A transport error from
+// one attempt waits for the other; a primary that fails before the hedge
+// fires fails the call as without hedging.
+func (p *httpProvider) hedgedDo(ctx context.Context, reqBody []byte) (*http.Response, error) {
+	results := make(chan hedgedResult, 2)
+	var cancels [2]context.CancelFunc
+	launch := func(n int, endpoint string) {
+		actx, cancel := context.WithCancel(ctx)
+		cancels[n] = cancel
+		go func() {
+			resp, err := p.send(actx, endpoint, reqBody)
+			results <- hedgedResult{n: n, resp: resp, err: err}
+		}()
+	}
+	launch(0, p.endpoint)
+	timer := time.NewTimer(p.hedgeDelay)
+	defer timer.Stop()
+	launched, pending := 1, 1
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			endpoint := p.hedgeEndpoint
+			if endpoint == "" {
+				endpoint = p.endpoint
+			}
+			launch(1, endpoint)
+			launched++
+			pending++
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				for n := 0; n < launched; n++ {
+					if n != r.n {
+						cancels[n]()
+					}
+				}
+				if pending > 0 {
+					go discardHedged(results, pending)
+				}
+				r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: cancels[r.n]}
+				return r.resp, nil
+			}
+			cancels[r.n]()
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// discardHedged closes the responses of the n attempts still in flight after
+// another one won.
+func discardHedged(results <-chan hedgedResult, n int) {
+	for ; n > 0; n-- {
+		if r := <-results; r.resp != nil {
+			_ = r.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnClose releases a winning attempt's context once its body is read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
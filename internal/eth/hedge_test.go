@@ -0,0 +1,83 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProvider_HedgeWinsOverSlowAttempt(t *testing.T) {
+	var calls atomic.Int32
+	slowCanceled := make(chan struct{})
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "slow" && calls.Add(1) == 1 {
+			// The primary stalls until the hedge's win cancels it.
+			<-r.Context().Done()
+			close(slowCanceled)
+			return mkResp("0x1"), nil
+		}
+		if r.URL.Host != "hedge" {
+			t.Errorf("hedge sent to %s", r.URL.Host)
+		}
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["method"] != "eth_blockNumber" {
+			t.Errorf("hedged method %v", req["method"])
+		}
+		return mkResp("0x2a"), nil
+	})}
+	p, _ := NewHTTPProvider("http://slow", client)
+	WithHedging(10*time.Millisecond, "http://hedge")(p.(*httpProvider))
+	head, err := p.BlockNumber(context.Background())
+	if err != nil || head != 42 {
+		t.Fatalf("head=%d err=%v, want the hedge's 42", head, err)
+	}
+	select {
+	case <-slowCanceled:
+	case <-time.After(time.Second):
+		t.Fatal("slow attempt was not canceled")
+	}
+}
+
+func TestHTTPProvider_HedgeNotSentForFastAnswers(t *testing.T) {
+	var calls atomic.Int32
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return mkResp("0x7"), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	WithHedging(time.Second, "")(p.(*httpProvider))
+	if head, err := p.BlockNumber(context.Background()); err != nil || head != 7 {
+		t.Fatalf("head=%d err=%v", head, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("calls = %d, want 1", n)
+	}
+}
+
+func TestHTTPProvider_HedgeFallsBackOnFailedAttempt(t *testing.T) {
+	var calls atomic.Int32
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			// Slow, then failing: the hedge already in flight answers.
+			time.Sleep(30 * time.Millisecond)
+			return nil, errors.New("connection reset")
+		}
+		time.Sleep(50 * time.Millisecond)
+		return mkResp("0x9"), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	hp := p.(*httpProvider)
+	hp.maxRetries = 0
+	WithHedging(5*time.Millisecond, "")(hp)
+	if head, err := p.BlockNumber(context.Background()); err != nil || head != 9 {
+		t.Fatalf("head=%d err=%v", head, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("calls = %d, want 2", n)
+	}
+}
@@ -11,15 +11,29 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
 	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/pkg/bodylimit"
+	"github.com/AIAleph/mvp_wallet_context/pkg/clock"
 )
 
 var ErrUnsupported = errors.New("method not supported by provider")
 
+// ErrTraceLimitExceeded is returned by TraceBlock when a range holds more
+// traces, or needs more trace_filter pages, than WithTraceLimits allows.
+var ErrTraceLimitExceeded = errors.New("trace limit exceeded")
+
+// ErrBlockNotFound is returned when eth_getBlockByNumber keeps answering null
+// for a block, typically a replica behind a load balancer that lags the head
+// another node reported. The block is not yet available rather than invalid.
+var ErrBlockNotFound = errors.New("block not yet available")
+
 type httpDoer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -34,8 +48,54 @@ type httpProvider struct {
 	backoffBase          time.Duration
 	blkCache             *timestampCache
 	receiptWorkers       int
+	receiptBatchSize     int
 	blockReceiptsMu      sync.Mutex
 	blockReceiptsSupport receiptSupportState
+	receiptBatchSupport  receiptSupportState
+	alchemySupport       receiptSupportState
+	latency              *LatencyRecorder
+	budget               *CallBudget
+	userAgent            string
+	// strict makes Transactions fail on any per-block or receipt error
+	// instead of returning partial results.
+	strict bool
+	// keepReceiptless makes Transactions return transactions whose receipt
+	// is missing, flagged ReceiptMissing, instead of dropping them.
+	keepReceiptless bool
+	// bloomFilter makes GetLogs skip blocks whose logsBloom rules out a
+	// match, at the cost of one header fetch per block.
+	bloomFilter bool
+	// noTimestamps makes GetLogs and TraceBlock leave TsMillis at 0 instead
+	// of fetching each block's header for its timestamp.
+	noTimestamps bool
+	// alchemy makes Transactions ask alchemy_getAssetTransfers which blocks
+	// to walk instead of walking the whole range.
+	alchemy bool
+	// maxTraces and maxTracePages cap what one TraceBlock call accumulates
+	// (0 = unlimited).
+	maxTraces     int
+	maxTracePages int
+	// methodLimiters holds the per-method limiters (WithMethodRateLimits)
+	// call and callBatch wait on for each request of a listed method.
+	methodLimiters map[string]Limiter
+	// hedgeDelay, when positive, makes post send a duplicate request to
+	// hedgeEndpoint (the endpoint itself when empty) if the first has not
+	// answered within it.
+	hedgeDelay    time.Duration
+	hedgeEndpoint string
+	// maxBody caps each response body (DefaultMaxResponseBytes); 0 leaves
+	// bodies unbounded.
+	maxBody int64
+	// flight collapses concurrent identical block fetches into one call;
+	// waiters share the leader's result, including its context errors.
+	flight singleflight.Group
+	// walked holds, per address, the withdrawals the last Transactions call
+	// decoded from the full blocks it fetched, so Withdrawals can skip them.
+	walkedMu sync.Mutex
+	walked   map[string]map[uint64][]Withdrawal
+	// clock drives timestamp cache expiry and retry backoff (clock.Real
+	// unless WithClock overrides it).
+	clock clock.Clock
 }
 
 type receiptSupportState int
@@ -48,10 +108,47 @@ const (
 
 type receiptLite struct {
 	gasUsed         uint64
+	gasPrice        string
 	status          uint8
 	contractAddress string
 }
 
+// DefaultReceiptBatchSize is the number of eth_getTransactionReceipt calls sent
+// per JSON-RPC batch when eth_getBlockReceipts is unavailable.
+const DefaultReceiptBatchSize = 50
+
+// DefaultUserAgent identifies JSON-RPC requests unless WithUserAgent
+// overrides it.
+const DefaultUserAgent = "mvp_wallet_context"
+
+// rpcReceipt is the subset of an eth_getTransactionReceipt result we keep.
+type rpcReceipt struct {
+	Status            string  `json:"status"`
+	GasUsed           string  `json:"gasUsed"`
+	EffectiveGasPrice string  `json:"effectiveGasPrice"`
+	ContractAddress   *string `json:"contractAddress"`
+}
+
+func (r rpcReceipt) lite(hash string) (receiptLite, error) {
+	gasUsed, err := hexToUint64(r.GasUsed)
+	if err != nil {
+		return receiptLite{}, fmt.Errorf("receipt %s gasUsed: %w", hash, err)
+	}
+	statusVal := uint8(1)
+	if r.Status != "" {
+		s, err := hexToUint64(r.Status)
+		if err != nil {
+			return receiptLite{}, fmt.Errorf("receipt %s status: %w", hash, err)
+		}
+		statusVal = uint8(s)
+	}
+	contractAddr := ""
+	if r.ContractAddress != nil {
+		contractAddr = normalizeContractAddr(*r.ContractAddress)
+	}
+	return receiptLite{gasUsed: gasUsed, gasPrice: r.EffectiveGasPrice, status: statusVal, contractAddress: contractAddr}, nil
+}
+
 // NewHTTPProvider constructs a JSON-RPC provider using the given http.Client (or a default one if nil).
 func NewHTTPProvider(endpoint string, client *http.Client) (Provider, error) {
 	if endpoint == "" {
@@ -68,7 +165,12 @@ func NewHTTPProvider(endpoint string, client *http.Client) (Provider, error) {
 		backoffBase:          100 * time.Millisecond,
 		blkCache:             newTimestampCache(defaultBlockTimestampCacheSize, defaultBlockTimestampTTL),
 		receiptWorkers:       4,
+		receiptBatchSize:     DefaultReceiptBatchSize,
 		blockReceiptsSupport: receiptSupportUnknown,
+		receiptBatchSupport:  receiptSupportUnknown,
+		userAgent:            DefaultUserAgent,
+		maxBody:              DefaultMaxResponseBytes,
+		clock:                clock.Real,
 	}, nil
 }
 
@@ -80,8 +182,40 @@ type rpcRequest struct {
 }
 
 type rpcError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"` // revert data or provider diagnostics
+}
+
+// maxErrorData caps how much of an error's data field is quoted in Error.
+const maxErrorData = 1024
+
+func (e *rpcError) Error() string { return fmt.Sprintf("rpc %d: %s", e.Code, e.detail()) }
+
+// detail is the error message followed by its data field, when the node
+// sent one: a JSON string (such as revert data) unquoted, anything else as
+// compact JSON.
+func (e *rpcError) detail() string {
+	data := bytes.TrimSpace(e.Data)
+	if len(data) == 0 || string(data) == "null" {
+		return e.Message
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var buf bytes.Buffer
+		if json.Compact(&buf, data) != nil {
+			buf.Reset()
+			buf.Write(data)
+		}
+		s = buf.String()
+	}
+	if s == "" {
+		return e.Message
+	}
+	if len(s) > maxErrorData {
+		s = s[:maxErrorData] + "..."
+	}
+	return e.Message + " (data: " + s + ")"
 }
 
 type rpcResponse struct {
@@ -94,20 +228,31 @@ type rpcResponse struct {
 const (
 	defaultBlockTimestampCacheSize = 2048
 	defaultBlockTimestampTTL       = 15 * time.Minute
+	// Blocks above the safe head may still be reorged (and on fast chains
+	// replaced within seconds), so keep their timestamps only briefly.
+	defaultRecentBlockTimestampTTL = 12 * time.Second
 )
 
+// timestampCacheEntry stores a cached block timestamp. A zero expiresAt marks
+// a finalized block whose timestamp never changes and therefore never expires.
 type timestampCacheEntry struct {
 	key       uint64
 	value     int64
 	expiresAt time.Time
 }
 
+// timestampCache is a bounded LRU of block timestamps. Until a safe head is
+// known every entry uses ttl; afterwards blocks at or below the safe head are
+// pinned without expiry and head-adjacent blocks use the shorter recentTTL.
 type timestampCache struct {
-	mu      sync.Mutex
-	max     int
-	ttl     time.Duration
-	entries map[uint64]*list.Element
-	ordered *list.List
+	mu           sync.Mutex
+	max          int
+	ttl          time.Duration
+	recentTTL    time.Duration
+	finalized    uint64
+	hasFinalized bool
+	entries      map[uint64]*list.Element
+	ordered      *list.List
 }
 
 func newTimestampCache(max int, ttl time.Duration) *timestampCache {
@@ -118,19 +263,48 @@ func newTimestampCache(max int, ttl time.Duration) *timestampCache {
 		ttl = defaultBlockTimestampTTL
 	}
 	return &timestampCache{
-		max:     max,
-		ttl:     ttl,
-		entries: make(map[uint64]*list.Element, max),
-		ordered: list.New(),
+		max:       max,
+		ttl:       ttl,
+		recentTTL: defaultRecentBlockTimestampTTL,
+		entries:   make(map[uint64]*list.Element, max),
+		ordered:   list.New(),
 	}
 }
 
+// setFinalized records the highest block considered final. Later additions at
+// or below it are cached without TTL.
+func (c *timestampCache) setFinalized(block uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hasFinalized && block < c.finalized {
+		return
+	}
+	c.finalized = block
+	c.hasFinalized = true
+}
+
+// expiryFor returns the expiry for a block added at now; the zero time means
+// the entry never expires.
+func (c *timestampCache) expiryFor(block uint64, now time.Time) time.Time {
+	if !c.hasFinalized {
+		return now.Add(c.ttl)
+	}
+	if block <= c.finalized {
+		return time.Time{}
+	}
+	return now.Add(c.recentTTL)
+}
+
+func (e *timestampCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
 func (c *timestampCache) get(block uint64, now time.Time) (int64, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if el, ok := c.entries[block]; ok {
 		e := el.Value.(*timestampCacheEntry)
-		if !now.Before(e.expiresAt) {
+		if e.expired(now) {
 			c.removeElement(el)
 			return 0, false
 		}
@@ -146,11 +320,11 @@ func (c *timestampCache) add(block uint64, value int64, now time.Time) {
 	if el, ok := c.entries[block]; ok {
 		e := el.Value.(*timestampCacheEntry)
 		e.value = value
-		e.expiresAt = now.Add(c.ttl)
+		e.expiresAt = c.expiryFor(block, now)
 		c.ordered.MoveToFront(el)
 		return
 	}
-	entry := &timestampCacheEntry{key: block, value: value, expiresAt: now.Add(c.ttl)}
+	entry := &timestampCacheEntry{key: block, value: value, expiresAt: c.expiryFor(block, now)}
 	el := c.ordered.PushFront(entry)
 	c.entries[block] = el
 	c.evict(now)
@@ -162,7 +336,7 @@ func (c *timestampCache) evict(now time.Time) {
 	}
 	for el := c.ordered.Back(); el != nil; el = el.Prev() {
 		e := el.Value.(*timestampCacheEntry)
-		if now.Before(e.expiresAt) {
+		if !e.expired(now) {
 			break
 		}
 		c.removeElement(el)
@@ -179,6 +353,54 @@ func (c *timestampCache) removeElement(el *list.Element) {
 	c.ordered.Remove(el)
 }
 
+// prune drops the entries for blocks at or above from.
+func (c *timestampCache) prune(from uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.ordered.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*timestampCacheEntry).key >= from {
+			c.removeElement(el)
+		}
+		el = next
+	}
+}
+
+func (c *timestampCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ordered.Len()
+}
+
+// TimestampCache is the provider's bounded LRU of block timestamps for
+// callers keeping their own, safe for concurrent use. Entries never expire;
+// the caller drops blocks a reorg may have replaced with Prune.
+type TimestampCache struct{ c *timestampCache }
+
+// NewTimestampCache returns a TimestampCache holding at most max blocks,
+// evicting the least recently used past it.
+func NewTimestampCache(max int) *TimestampCache {
+	c := newTimestampCache(max, 0)
+	// Every block counts as final, so no entry is given a TTL.
+	c.setFinalized(math.MaxUint64)
+	return &TimestampCache{c: c}
+}
+
+// Get returns the cached timestamp of block.
+func (t *TimestampCache) Get(block uint64) (int64, bool) { return t.c.get(block, time.Time{}) }
+
+// Add caches ts for block.
+func (t *TimestampCache) Add(block uint64, ts int64) { t.c.add(block, ts, time.Time{}) }
+
+// Prune drops the entries for blocks at or above from.
+func (t *TimestampCache) Prune(from uint64) { t.c.prune(from) }
+
+// Len returns the number of cached blocks.
+func (t *TimestampCache) Len() int { return t.c.len() }
+
+// Cap returns the most blocks the cache holds.
+func (t *TimestampCache) Cap() int { return t.c.max }
+
 func deriveProviderLabel(endpoint string) string {
 	if endpoint == "" {
 		return ""
@@ -196,17 +418,109 @@ func deriveProviderLabel(endpoint string) string {
 	return endpoint
 }
 
+// ProviderLabel returns the endpoint's host, credentials stripped.
+func (p *httpProvider) ProviderLabel() string { return p.providerLbl }
+
 func (p *httpProvider) call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	if err := p.budget.take(1); err != nil {
+		return err
+	}
+	if err := p.waitMethod(ctx, method, 1); err != nil {
+		return err
+	}
+	if p.latency != nil {
+		defer p.latency.start(method)()
+	}
 	reqBody, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: 1})
+	return p.post(ctx, reqBody, func(body io.Reader) error {
+		var rr rpcResponse
+		if err := json.NewDecoder(body).Decode(&rr); err != nil {
+			return err
+		}
+		if rr.Error != nil {
+			// Surface JSON-RPC errors; treat as non-retriable by default (HTTP 200)
+			return rr.Error
+		}
+		if out != nil {
+			return json.Unmarshal(rr.Result, out)
+		}
+		return nil
+	})
+}
+
+// callBatch sends reqs as a single JSON-RPC batch and returns the responses
+// keyed by request ID. Per-request errors are left in the responses; an error
+// is returned only when the batch as a whole fails or the body is not an array.
+// A single error object in place of the array is returned as its *rpcError.
+func (p *httpProvider) callBatch(ctx context.Context, method string, reqs []rpcRequest) (map[int64]rpcResponse, error) {
+	if err := p.budget.take(len(reqs)); err != nil {
+		return nil, err
+	}
+	if err := p.waitMethod(ctx, method, len(reqs)); err != nil {
+		return nil, err
+	}
+	if p.latency != nil {
+		defer p.latency.start(method)()
+	}
+	reqBody, _ := json.Marshal(reqs)
+	var out map[int64]rpcResponse
+	err := p.post(ctx, reqBody, func(body io.Reader) error {
+		var raw json.RawMessage
+		if err := json.NewDecoder(body).Decode(&raw); err != nil {
+			return fmt.Errorf("decoding batch response: %w", err)
+		}
+		// An endpoint refusing the whole batch answers with a single error
+		// object instead of an array.
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '{' {
+			var rr rpcResponse
+			if err := json.Unmarshal(raw, &rr); err == nil && rr.Error != nil {
+				return rr.Error
+			}
+		}
+		var rrs []rpcResponse
+		if err := json.Unmarshal(raw, &rrs); err != nil {
+			return fmt.Errorf("decoding batch response: %w", err)
+		}
+		out = make(map[int64]rpcResponse, len(rrs))
+		for _, rr := range rrs {
+			out[rr.ID] = rr
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// waitMethod takes n tokens from method's own limiter, if it has one.
+func (p *httpProvider) waitMethod(ctx context.Context, method string, n int) error {
+	l, ok := p.methodLimiters[method]
+	if !ok {
+		return nil
+	}
+	for k := 0; k < n; k++ {
+		if err := l.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitsMethod reports whether method has its own limiter, so RLProvider
+// leaves it out of the global limit.
+func (p *httpProvider) limitsMethod(method string) bool {
+	_, ok := p.methodLimiters[method]
+	return ok
+}
+
+// post sends reqBody to the endpoint and hands a 2xx body to decode, retrying
+// transport errors, 429s and 5xx responses with exponential backoff.
+func (p *httpProvider) post(ctx context.Context, reqBody []byte, decode func(io.Reader) error) error {
 	var lastErr error
 	attempts := p.maxRetries + 1
 	for attempt := 0; attempt < attempts; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(reqBody))
-		if err != nil {
-			return err
-		}
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := p.hc.Do(req)
+		resp, err := p.do(ctx, reqBody)
 		if err != nil {
 			lastErr = err
 		} else {
@@ -214,24 +528,12 @@ func (p *httpProvider) call(ctx context.Context, method string, params interface
 				defer func() {
 					_ = resp.Body.Close()
 				}()
+				body := bodylimit.Reader(resp.Body, p.maxBody)
 				if resp.StatusCode/100 != 2 {
-					b, _ := io.ReadAll(resp.Body)
+					b, _ := io.ReadAll(body)
 					lastErr = fmt.Errorf("http %d: %s", resp.StatusCode, string(b))
 				} else {
-					var rr rpcResponse
-					if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
-						lastErr = err
-					} else if rr.Error != nil {
-						// Surface JSON-RPC errors; treat as non-retriable by default (HTTP 200)
-						lastErr = fmt.Errorf("rpc %d: %s", rr.Error.Code, rr.Error.Message)
-						return
-					} else {
-						if out != nil {
-							lastErr = json.Unmarshal(rr.Result, out)
-						} else {
-							lastErr = nil
-						}
-					}
+					lastErr = decode(body)
 				}
 			}()
 			if lastErr == nil {
@@ -246,19 +548,20 @@ func (p *httpProvider) call(ctx context.Context, method string, params interface
 		}
 		// Backoff before next attempt
 		if attempt < attempts-1 {
-			d := p.backoffBase * (1 << attempt)
-			t := time.NewTimer(d)
 			select {
 			case <-ctx.Done():
-				t.Stop()
 				return ctx.Err()
-			case <-t.C:
+			case <-p.clk().After(p.backoffBase * (1 << attempt)):
 			}
 		}
 	}
 	return lastErr
 }
 
+// clk returns the provider's clock, clock.Real for providers built without
+// NewHTTPProvider.
+func (p *httpProvider) clk() clock.Clock { return clock.Or(p.clock) }
+
 // hexToUint64 parses an Ethereum hex quantity (e.g., "0x2a") into uint64.
 func hexToUint64(s string) (uint64, error) {
 	var v uint64
@@ -282,6 +585,13 @@ func (p *httpProvider) BlockTimestamp(ctx context.Context, block uint64) (int64,
 	return p.blockTimestampMillis(ctx, block)
 }
 
+// SetSafeHead lets the block timestamp cache pin finalized blocks.
+func (p *httpProvider) SetSafeHead(block uint64) {
+	if p.blkCache != nil {
+		p.blkCache.setFinalized(block)
+	}
+}
+
 type rpcLog struct {
 	TxHash      string   `json:"transactionHash"`
 	LogIndexHex string   `json:"logIndex"`
@@ -291,7 +601,27 @@ type rpcLog struct {
 	BlockHex    string   `json:"blockNumber"`
 }
 
-// GetLogs implements a minimal eth_getLogs call.
+// rpcTrace is one trace_filter result entry.
+type rpcTrace struct {
+	TxHash       string `json:"transactionHash"`
+	BlockHex     string `json:"blockNumber"`
+	TraceAddress []int  `json:"traceAddress"`
+	Type         string `json:"type"`
+	Action       struct {
+		From     string `json:"from"`
+		To       string `json:"to"`
+		Value    string `json:"value"`
+		CallType string `json:"callType"`
+	} `json:"action"`
+	Result struct {
+		Address string `json:"address"`
+	} `json:"result"`
+}
+
+// GetLogs implements a minimal eth_getLogs call. With WithLogsBloomFilter it
+// first reads each block's header and only queries the runs of blocks whose
+// logsBloom may match; eth_getLogs still decides what matches, so bloom false
+// positives cost a query but never add logs.
 func (p *httpProvider) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]Log, error) {
 	// Build topics param: each position may be null, string, or array of strings.
 	var topicsParam []interface{}
@@ -308,33 +638,49 @@ func (p *httpProvider) GetLogs(ctx context.Context, address string, from, to uin
 		copy(arr, group)
 		topicsParam = append(topicsParam, arr)
 	}
-	params := []interface{}{
-		map[string]interface{}{
-			"address":   address,
-			"fromBlock": toHex(from),
-			"toBlock":   toHex(to),
-			"topics":    topicsParam,
-		},
-	}
-	var raw []rpcLog
-	if err := p.call(ctx, "eth_getLogs", params, &raw); err != nil {
-		return nil, err
+	runs := []BlockRange{{From: from, To: to}}
+	if p.bloomFilter {
+		var err error
+		if runs, err = p.bloomCandidates(ctx, address, from, to, topics); err != nil {
+			return nil, err
+		}
 	}
-	out := make([]Log, 0, len(raw))
+	out := []Log{}
 	uniqBlocks := map[uint64]struct{}{}
-	for _, l := range raw {
-		idx, _ := hexToUint64(l.LogIndexHex)
-		blk, _ := hexToUint64(l.BlockHex)
-		uniqBlocks[blk] = struct{}{}
-		out = append(out, Log{
-			TxHash:   l.TxHash,
-			Index:    uint32(idx),
-			Address:  l.Address,
-			Topics:   l.Topics,
-			DataHex:  l.Data,
-			BlockNum: blk,
-			TsMillis: 0, // enriched below
+	for _, r := range runs {
+		params := []interface{}{
+			map[string]interface{}{
+				"address":   address,
+				"fromBlock": toHex(r.From),
+				"toBlock":   toHex(r.To),
+				"topics":    topicsParam,
+			},
+		}
+		err := p.callArray(ctx, "eth_getLogs", params, func(dec *json.Decoder) error {
+			var l rpcLog
+			if err := dec.Decode(&l); err != nil {
+				return err
+			}
+			idx, _ := hexToUint64(l.LogIndexHex)
+			blk, _ := hexToUint64(l.BlockHex)
+			uniqBlocks[blk] = struct{}{}
+			out = append(out, Log{
+				TxHash:   l.TxHash,
+				Index:    uint32(idx),
+				Address:  l.Address,
+				Topics:   l.Topics,
+				DataHex:  l.Data,
+				BlockNum: blk,
+				TsMillis: 0, // enriched below
+			})
+			return nil
 		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.noTimestamps {
+		return out, nil
 	}
 	// Enrich timestamps: one eth_getBlockByNumber per unique block
 	tsMap := make(map[uint64]int64, len(uniqBlocks))
@@ -352,12 +698,19 @@ func (p *httpProvider) GetLogs(ctx context.Context, address string, from, to uin
 }
 
 // TraceBlock attempts to use trace_filter with pagination, mapping to Trace.
-// Providers that do not support it will return an error.
+// Providers that do not support it will return an error. Each page asks for
+// one trace more than it keeps, so a short page ends the walk without a
+// trailing empty request and the limits only fail when more traces exist.
 func (p *httpProvider) TraceBlock(ctx context.Context, from, to uint64, address string) ([]Trace, error) {
 	page := 1000
 	after := 0
+	pages := 0
 	var all []Trace
 	for {
+		if p.maxTracePages > 0 && pages == p.maxTracePages {
+			return nil, fmt.Errorf("%w: more than %d pages for blocks %d-%d", ErrTraceLimitExceeded, p.maxTracePages, from, to)
+		}
+		pages++
 		params := []interface{}{
 			map[string]interface{}{
 				"fromBlock":   toHex(from),
@@ -365,33 +718,23 @@ func (p *httpProvider) TraceBlock(ctx context.Context, from, to uint64, address
 				"fromAddress": []string{address},
 				"toAddress":   []string{address},
 				"after":       after,
-				"count":       page,
+				"count":       page + 1,
 			},
 		}
-		var raw []struct {
-			TxHash       string `json:"transactionHash"`
-			BlockHex     string `json:"blockNumber"`
-			TraceAddress []int  `json:"traceAddress"`
-			Type         string `json:"type"`
-			Action       struct {
-				From  string `json:"from"`
-				To    string `json:"to"`
-				Value string `json:"value"`
-			} `json:"action"`
-			Result struct {
-				Address string `json:"address"`
-			} `json:"result"`
-		}
-		if err := p.call(ctx, "trace_filter", params, &raw); err != nil {
-			if strings.Contains(err.Error(), "rpc -32601") || strings.Contains(err.Error(), "trace_filter") {
-				return nil, ErrUnsupported
+		n := 0
+		err := p.callArray(ctx, "trace_filter", params, func(dec *json.Decoder) error {
+			var t rpcTrace
+			if err := dec.Decode(&t); err != nil {
+				return err
+			}
+			n++
+			if p.maxTraces > 0 && len(all) == p.maxTraces {
+				return fmt.Errorf("%w: more than %d traces for blocks %d-%d", ErrTraceLimitExceeded, p.maxTraces, from, to)
+			}
+			if n > page {
+				// The lookahead trace opens the next page.
+				return nil
 			}
-			return nil, err
-		}
-		if len(raw) == 0 {
-			break
-		}
-		for _, t := range raw {
 			blk, _ := hexToUint64(t.BlockHex)
 			// Compose a simple trace ID from traceAddress path or "root" when empty
 			traceID := "root"
@@ -417,14 +760,28 @@ func (p *httpProvider) TraceBlock(ctx context.Context, from, to uint64, address
 				BlockNum:        blk,
 				TsMillis:        0, // optional enrichment later
 				Type:            typeLower,
+				CallType:        strings.ToLower(t.Action.CallType),
 				CreatedContract: created,
 			})
+			return nil
+		})
+		if errors.Is(err, ErrTraceLimitExceeded) {
+			return nil, err
 		}
-		if len(raw) < page {
+		if err != nil {
+			if strings.Contains(err.Error(), "rpc -32601") || strings.Contains(err.Error(), "trace_filter") {
+				return nil, ErrUnsupported
+			}
+			return nil, err
+		}
+		if n <= page {
 			break
 		}
 		after += page
 	}
+	if p.noTimestamps {
+		return all, nil
+	}
 	// Enrich timestamps per unique block
 	uniq := make(map[uint64]struct{}, len(all))
 	for _, t := range all {
@@ -447,13 +804,17 @@ func (p *httpProvider) TraceBlock(ctx context.Context, from, to uint64, address
 // Transactions walks blocks in the inclusive range and surfaces external
 // transactions touching the address. It opportunistically batches receipt
 // lookups and tolerates per-block/receipt failures, logging them as warnings
-// while still returning partial results when possible.
+// while still returning partial results, with a *PartialError naming the
+// failed blocks, when possible. With WithStrictTransactions any such failure
+// fails the whole range instead. With WithAlchemyTransfers only the blocks
+// alchemy_getAssetTransfers names are walked, or the whole range when the
+// API fails.
 func (p *httpProvider) Transactions(ctx context.Context, address string, from, to uint64) (result []Transaction, err error) {
 	if from > to {
 		return nil, nil
 	}
 	lowerAddr := strings.ToLower(address)
-	start := time.Now()
+	start := p.clk().Now()
 	receiptCalls := 0
 	blockCalls := 0
 	txExamined := 0
@@ -461,6 +822,7 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 	receiptFailures := 0
 	blockFailures := 0
 	txSkipped := 0
+	transferCalls := 0
 	span := to - from
 	if span != math.MaxUint64 {
 		span++
@@ -468,6 +830,14 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 	logger := logging.Logger()
 	var partialErr error
 	var partialErrs []error
+	var missing []BlockRange
+	miss := func(from, to uint64) {
+		if n := len(missing); n > 0 && missing[n-1].To+1 == from {
+			missing[n-1].To = to
+			return
+		}
+		missing = append(missing, BlockRange{From: from, To: to})
+	}
 	defer func() {
 		if logger == nil {
 			return
@@ -487,16 +857,17 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 			"block_failures", blockFailures,
 			"receipt_failures", receiptFailures,
 			"tx_skipped", txSkipped,
-			"elapsed_ms", time.Since(start).Milliseconds(),
-		}
-		if err != nil {
-			logger.Warn("receipt_lookup_failed", append(fields, "error", err.Error())...)
-			return
+			"asset_transfer_calls", transferCalls,
+			"elapsed_ms", p.clk().Now().Sub(start).Milliseconds(),
 		}
 		if partialErr != nil {
 			logger.Warn("receipt_lookup_partial", append(fields, "error", partialErr.Error())...)
 			return
 		}
+		if err != nil {
+			logger.Warn("receipt_lookup_failed", append(fields, "error", err.Error())...)
+			return
+		}
 		logger.Info("receipt_lookup", fields...)
 	}()
 
@@ -509,28 +880,38 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 		value     string
 		blockNum  uint64
 		tsMillis  int64
+		accessLen uint32
+		accessKey uint32
+		txType    string
+		gasPrice  string
 	}
 
+	// candidates, when set, holds the only blocks worth fetching.
+	var candidates map[uint64]struct{}
+	if p.alchemy {
+		candidates, transferCalls = p.alchemyTransferBlocks(ctx, lowerAddr, from, to)
+	}
+
+	walked := make(map[uint64][]Withdrawal)
+	defer p.keepWalkedWithdrawals(lowerAddr, walked)
 	for blk := from; blk <= to; blk++ {
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			partialErrs = append(partialErrs, ctxErr)
+			miss(blk, to)
 			break
 		}
-		var block struct {
-			Timestamp    string `json:"timestamp"`
-			Transactions []struct {
-				Hash  string  `json:"hash"`
-				From  string  `json:"from"`
-				To    *string `json:"to"`
-				Input string  `json:"input"`
-				Value string  `json:"value"`
-			} `json:"transactions"`
-		}
-		params := []interface{}{toHex(blk), true}
+		if _, ok := candidates[blk]; candidates != nil && !ok {
+			if blk == math.MaxUint64 {
+				break
+			}
+			continue
+		}
 		blockCalls++
-		if callErr := p.call(ctx, "eth_getBlockByNumber", params, &block); callErr != nil {
+		block, callErr := p.fullBlock(ctx, blk)
+		if callErr != nil {
 			blockFailures++
 			partialErrs = append(partialErrs, fmt.Errorf("block %d: %w", blk, callErr))
+			miss(blk, blk)
 			if blk == math.MaxUint64 {
 				break
 			}
@@ -540,12 +921,16 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 		if tsErr != nil {
 			blockFailures++
 			partialErrs = append(partialErrs, fmt.Errorf("block %d timestamp: %w", blk, tsErr))
+			miss(blk, blk)
 			if blk == math.MaxUint64 {
 				break
 			}
 			continue
 		}
 		tsMillis := int64(tsSec) * 1000
+		if ws, wErr := withdrawalsTo(block.Withdrawals, lowerAddr, blk, tsMillis); wErr == nil {
+			walked[blk] = ws
+		}
 		pending := make([]pendingTx, 0, len(block.Transactions))
 		hashes := make([]string, 0, len(block.Transactions))
 		for _, tx := range block.Transactions {
@@ -560,6 +945,10 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 			}
 			txMatched++
 			hashLower := strings.ToLower(tx.Hash)
+			var accessKeys int
+			for _, entry := range tx.AccessList {
+				accessKeys += len(entry.StorageKeys)
+			}
 			pending = append(pending, pendingTx{
 				hash:      tx.Hash,
 				hashLower: hashLower,
@@ -569,6 +958,10 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 				value:     tx.Value,
 				blockNum:  blk,
 				tsMillis:  tsMillis,
+				accessLen: uint32(len(tx.AccessList)),
+				accessKey: uint32(accessKeys),
+				txType:    tx.Type,
+				gasPrice:  tx.GasPrice,
 			})
 			hashes = append(hashes, tx.Hash)
 		}
@@ -583,10 +976,11 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 		receiptFailures += failures
 		if recErr != nil {
 			partialErrs = append(partialErrs, fmt.Errorf("block %d receipts: %w", blk, recErr))
+			miss(blk, blk)
 		}
 		for _, tx := range pending {
 			rec, ok := receipts[tx.hashLower]
-			if !ok {
+			if !ok && !p.keepReceiptless {
 				txSkipped++
 				continue
 			}
@@ -597,26 +991,55 @@ func (p *httpProvider) Transactions(ctx context.Context, address string, from, t
 				ValueWei:        tx.value,
 				InputHex:        tx.input,
 				GasUsed:         rec.gasUsed,
+				GasPriceWei:     gasPriceFor(tx.txType, tx.gasPrice, rec.gasPrice),
 				Status:          rec.status,
 				BlockNum:        tx.blockNum,
 				TsMillis:        tx.tsMillis,
 				ContractAddress: rec.contractAddress,
+				AccessListCount: tx.accessLen,
+				AccessListKeys:  tx.accessKey,
+				ReceiptMissing:  !ok,
 			})
 		}
 		if blk == math.MaxUint64 {
 			break
 		}
 	}
-	if len(result) == 0 && len(partialErrs) > 0 {
+	if (p.strict || len(result) == 0) && len(partialErrs) > 0 {
 		err = errors.Join(partialErrs...)
 		return nil, err
 	}
 	if len(partialErrs) > 0 {
 		partialErr = errors.Join(partialErrs...)
+		return result, &PartialError{Missing: missing, Err: partialErr}
 	}
 	return result, nil
 }
 
+// gasPriceFor picks the price per gas a transaction paid: a legacy
+// transaction's own gasPrice, otherwise the receipt's effectiveGasPrice (base
+// fee plus tip for EIP-1559 transactions), falling back to gasPrice for
+// receipts that predate that field or are missing.
+func gasPriceFor(txType, gasPrice, effective string) string {
+	if isLegacyTxType(txType) && gasPrice != "" {
+		return gasPrice
+	}
+	if effective != "" {
+		return effective
+	}
+	return gasPrice
+}
+
+// isLegacyTxType reports whether txType (the hex "type" field, absent on
+// pre-Berlin nodes) denotes a legacy transaction.
+func isLegacyTxType(txType string) bool {
+	switch strings.ToLower(txType) {
+	case "", "0x", "0x0", "0x00":
+		return true
+	}
+	return false
+}
+
 func normalizeContractAddr(addr string) string {
 	addr = strings.TrimSpace(addr)
 	if addr == "" {
@@ -638,41 +1061,34 @@ func (p *httpProvider) fetchReceiptsForBlock(ctx context.Context, block uint64,
 	totalCalls := 0
 	failures := 0
 	var joinedErr error
+	missing := hashes
 	if useBlockReceipts {
+		// Receipts decoded before a failed call (a body cut off mid-stream)
+		// are kept, so only the hashes still missing are fetched per tx.
 		recs, err := p.callBlockReceipts(ctx, block, hashSet)
 		totalCalls++
-		if err == nil {
-			for k, v := range recs {
-				out[k] = v
-			}
-			missing := make([]string, 0)
-			for _, h := range hashes {
-				if _, ok := out[strings.ToLower(h)]; !ok {
-					missing = append(missing, h)
-				}
-			}
-			p.setBlockReceiptsState(receiptSupportAvailable)
-			if len(missing) == 0 {
-				return out, totalCalls, 0, nil
-			}
-			perTx, calls, perFailures, perErr := p.fetchReceiptsIndividually(ctx, missing)
-			for k, v := range perTx {
-				out[k] = v
-			}
-			totalCalls += calls
-			failures += perFailures
-			if perErr != nil {
-				joinedErr = errors.Join(joinedErr, perErr)
-			}
-			return out, totalCalls, failures, joinedErr
+		for k, v := range recs {
+			out[k] = v
 		}
-		if isMethodNotFound(err) {
+		switch {
+		case err == nil:
+			p.setBlockReceiptsState(receiptSupportAvailable)
+		case isMethodNotFound(err):
 			p.setBlockReceiptsState(receiptSupportUnavailable)
-		} else {
+		default:
 			joinedErr = err
 		}
+		missing = make([]string, 0, len(hashes)-len(out))
+		for _, h := range hashes {
+			if _, ok := out[strings.ToLower(h)]; !ok {
+				missing = append(missing, h)
+			}
+		}
+		if len(missing) == 0 {
+			return out, totalCalls, 0, joinedErr
+		}
 	}
-	perTx, calls, perFailures, err := p.fetchReceiptsIndividually(ctx, hashes)
+	perTx, calls, perFailures, err := p.fetchReceiptsPerTx(ctx, missing)
 	for k, v := range perTx {
 		out[k] = v
 	}
@@ -684,6 +1100,102 @@ func (p *httpProvider) fetchReceiptsForBlock(ctx context.Context, block uint64,
 	return out, totalCalls, failures, joinedErr
 }
 
+// fetchReceiptsPerTx fetches receipts by transaction hash, sending them in
+// JSON-RPC batches of receiptBatchSize. Hashes a batch leaves unanswered, and
+// everything once the endpoint rejects batching, go through the worker pool.
+func (p *httpProvider) fetchReceiptsPerTx(ctx context.Context, hashes []string) (map[string]receiptLite, int, int, error) {
+	if !p.shouldBatchReceipts(len(hashes)) {
+		return p.fetchReceiptsIndividually(ctx, hashes)
+	}
+	out := make(map[string]receiptLite, len(hashes))
+	totalCalls := 0
+	failures := 0
+	var errs []error
+	var rest []string
+	size := p.receiptBatchSize
+	for start := 0; start < len(hashes); start += size {
+		end := start + size
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+		recs, missing, recErrs, err := p.callReceiptBatch(ctx, hashes[start:end])
+		totalCalls++
+		if err != nil {
+			// Only a definitive refusal turns batching off; after a transient
+			// failure the next call tries batching again.
+			if isBatchUnsupported(err) {
+				p.setReceiptBatchState(receiptSupportUnavailable)
+			}
+			rest = append(rest, hashes[start:]...)
+			break
+		}
+		p.setReceiptBatchState(receiptSupportAvailable)
+		for k, v := range recs {
+			out[k] = v
+		}
+		failures += len(recErrs)
+		errs = append(errs, recErrs...)
+		rest = append(rest, missing...)
+	}
+	if len(rest) > 0 {
+		perTx, calls, perFailures, err := p.fetchReceiptsIndividually(ctx, rest)
+		for k, v := range perTx {
+			out[k] = v
+		}
+		totalCalls += calls
+		failures += perFailures
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	var joined error
+	if len(errs) > 0 {
+		joined = errors.Join(errs...)
+	}
+	return out, totalCalls, failures, joined
+}
+
+// callReceiptBatch requests receipts for hashes in one JSON-RPC batch and
+// demuxes the responses by ID. It returns the decoded receipts, the hashes
+// with no response, and per-receipt errors; err reports a failed batch.
+func (p *httpProvider) callReceiptBatch(ctx context.Context, hashes []string) (map[string]receiptLite, []string, []error, error) {
+	reqs := make([]rpcRequest, len(hashes))
+	for idx, h := range hashes {
+		// IDs start at 1 so a null ID on a batch-level error never matches.
+		reqs[idx] = rpcRequest{JSONRPC: "2.0", Method: "eth_getTransactionReceipt", Params: []interface{}{h}, ID: int64(idx + 1)}
+	}
+	resps, err := p.callBatch(ctx, "eth_getTransactionReceipt", reqs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	out := make(map[string]receiptLite, len(hashes))
+	var missing []string
+	var errs []error
+	for idx, h := range hashes {
+		rr, ok := resps[int64(idx+1)]
+		if !ok {
+			missing = append(missing, h)
+			continue
+		}
+		if rr.Error != nil {
+			errs = append(errs, fmt.Errorf("receipt %s: %w", h, rr.Error))
+			continue
+		}
+		var receipt rpcReceipt
+		if err := json.Unmarshal(rr.Result, &receipt); err != nil {
+			errs = append(errs, fmt.Errorf("receipt %s: %w", h, err))
+			continue
+		}
+		rec, err := receipt.lite(h)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out[strings.ToLower(h)] = rec
+	}
+	return out, missing, errs, nil
+}
+
 func (p *httpProvider) fetchReceiptsIndividually(ctx context.Context, hashes []string) (map[string]receiptLite, int, int, error) {
 	out := make(map[string]receiptLite, len(hashes))
 	if len(hashes) == 0 {
@@ -712,35 +1224,17 @@ func (p *httpProvider) fetchReceiptsIndividually(ctx context.Context, hashes []s
 			}
 			sem <- struct{}{}
 			defer func() { <-sem }()
-			var receipt struct {
-				Status          string  `json:"status"`
-				GasUsed         string  `json:"gasUsed"`
-				ContractAddress *string `json:"contractAddress"`
-			}
+			var receipt rpcReceipt
 			if callErr := p.call(ctx, "eth_getTransactionReceipt", []interface{}{hash}, &receipt); callErr != nil {
 				resCh <- result{err: fmt.Errorf("receipt %s: %w", hash, callErr)}
 				return
 			}
-			gasUsed, gasErr := hexToUint64(receipt.GasUsed)
-			if gasErr != nil {
-				resCh <- result{err: fmt.Errorf("receipt %s gasUsed: %w", hash, gasErr)}
+			rec, recErr := receipt.lite(hash)
+			if recErr != nil {
+				resCh <- result{err: recErr}
 				return
 			}
-			statusVal := uint8(1)
-			if receipt.Status != "" {
-				s, statusErr := hexToUint64(receipt.Status)
-				if statusErr != nil {
-					resCh <- result{err: fmt.Errorf("receipt %s status: %w", hash, statusErr)}
-					return
-				}
-				statusVal = uint8(s)
-			}
-			hashLower := strings.ToLower(hash)
-			contractAddr := ""
-			if receipt.ContractAddress != nil {
-				contractAddr = normalizeContractAddr(*receipt.ContractAddress)
-			}
-			resCh <- result{hashLower: hashLower, receipt: receiptLite{gasUsed: gasUsed, status: statusVal, contractAddress: contractAddr}}
+			resCh <- result{hashLower: strings.ToLower(hash), receipt: rec}
 		}()
 	}
 	wg.Wait()
@@ -762,33 +1256,39 @@ func (p *httpProvider) fetchReceiptsIndividually(ctx context.Context, hashes []s
 	return out, len(hashes), failures, joined
 }
 
+// callBlockReceipts fetches the receipts of block through
+// eth_getBlockReceipts, keeping those in filter (all when it is empty). The
+// result is streamed (callArray), so when the response breaks off or a
+// receipt fails to decode, the receipts read up to that point are returned
+// along with the error.
 func (p *httpProvider) callBlockReceipts(ctx context.Context, block uint64, filter map[string]struct{}) (map[string]receiptLite, error) {
-	var recs []struct {
-		TxHash          string  `json:"transactionHash"`
-		Status          string  `json:"status"`
-		GasUsed         string  `json:"gasUsed"`
-		ContractAddress *string `json:"contractAddress"`
-	}
-	if err := p.call(ctx, "eth_getBlockReceipts", []interface{}{toHex(block)}, &recs); err != nil {
-		return nil, err
-	}
-	out := make(map[string]receiptLite, len(recs))
-	for _, rec := range recs {
+	out := make(map[string]receiptLite)
+	err := p.callArray(ctx, "eth_getBlockReceipts", []interface{}{toHex(block)}, func(dec *json.Decoder) error {
+		var rec struct {
+			TxHash            string  `json:"transactionHash"`
+			Status            string  `json:"status"`
+			GasUsed           string  `json:"gasUsed"`
+			EffectiveGasPrice string  `json:"effectiveGasPrice"`
+			ContractAddress   *string `json:"contractAddress"`
+		}
+		if err := dec.Decode(&rec); err != nil {
+			return err
+		}
 		hashLower := strings.ToLower(rec.TxHash)
 		if len(filter) > 0 {
 			if _, ok := filter[hashLower]; !ok {
-				continue
+				return nil
 			}
 		}
 		gasUsed, err := hexToUint64(rec.GasUsed)
 		if err != nil {
-			return nil, fmt.Errorf("block receipt %s gasUsed: %w", rec.TxHash, err)
+			return fmt.Errorf("block receipt %s gasUsed: %w", rec.TxHash, err)
 		}
 		statusVal := uint8(1)
 		if rec.Status != "" {
 			s, err := hexToUint64(rec.Status)
 			if err != nil {
-				return nil, fmt.Errorf("block receipt %s status: %w", rec.TxHash, err)
+				return fmt.Errorf("block receipt %s status: %w", rec.TxHash, err)
 			}
 			statusVal = uint8(s)
 		}
@@ -796,9 +1296,10 @@ func (p *httpProvider) callBlockReceipts(ctx context.Context, block uint64, filt
 		if rec.ContractAddress != nil {
 			contractAddr = normalizeContractAddr(*rec.ContractAddress)
 		}
-		out[hashLower] = receiptLite{gasUsed: gasUsed, status: statusVal, contractAddress: contractAddr}
-	}
-	return out, nil
+		out[hashLower] = receiptLite{gasUsed: gasUsed, gasPrice: rec.EffectiveGasPrice, status: statusVal, contractAddress: contractAddr}
+		return nil
+	})
+	return out, err
 }
 
 func (p *httpProvider) shouldUseBlockReceipts(matchCount int) bool {
@@ -822,6 +1323,43 @@ func (p *httpProvider) setBlockReceiptsState(state receiptSupportState) {
 	p.blockReceiptsMu.Unlock()
 }
 
+func (p *httpProvider) shouldBatchReceipts(count int) bool {
+	if p.receiptBatchSize <= 1 || count <= 1 {
+		return false
+	}
+	return p.receiptBatchState() != receiptSupportUnavailable
+}
+
+func (p *httpProvider) receiptBatchState() receiptSupportState {
+	p.blockReceiptsMu.Lock()
+	defer p.blockReceiptsMu.Unlock()
+	return p.receiptBatchSupport
+}
+
+func (p *httpProvider) setReceiptBatchState(state receiptSupportState) {
+	p.blockReceiptsMu.Lock()
+	p.receiptBatchSupport = state
+	p.blockReceiptsMu.Unlock()
+}
+
+// isBatchUnsupported reports whether err is a JSON-RPC error refusing a batch
+// outright: method not found, or a message saying batches are not supported.
+// Timeouts, HTTP failures and other errors are transient.
+func isBatchUnsupported(err error) bool {
+	var re *rpcError
+	if !errors.As(err, &re) {
+		return false
+	}
+	if re.Code == -32601 {
+		return true
+	}
+	msg := strings.ToLower(re.Message)
+	if strings.Contains(msg, "method not found") || strings.Contains(msg, "method not supported") {
+		return true
+	}
+	return strings.Contains(msg, "batch") && (strings.Contains(msg, "not supported") || strings.Contains(msg, "unsupported") || strings.Contains(msg, "not allowed") || strings.Contains(msg, "disabled"))
+}
+
 func isMethodNotFound(err error) bool {
 	if err == nil {
 		return false
@@ -830,27 +1368,93 @@ func isMethodNotFound(err error) bool {
 	return strings.Contains(msg, "-32601") || strings.Contains(msg, "method not found")
 }
 
+// getBlock decodes eth_getBlockByNumber for block into out. A null result
+// is retried with the transport backoff, then reported as ErrBlockNotFound.
+func (p *httpProvider) getBlock(ctx context.Context, block uint64, fullTxs bool, out any) error {
+	params := []interface{}{toHex(block), fullTxs}
+	for attempt := 0; ; attempt++ {
+		var raw json.RawMessage
+		if err := p.call(ctx, "eth_getBlockByNumber", params, &raw); err != nil {
+			return err
+		}
+		if len(raw) > 0 && string(raw) != "null" {
+			return json.Unmarshal(raw, out)
+		}
+		if attempt >= p.maxRetries {
+			return ErrBlockNotFound
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.clk().After(p.backoffBase * (1 << attempt)):
+		}
+	}
+}
+
 // blockTimestampMillis fetches the block and returns timestamp in milliseconds.
 func (p *httpProvider) blockTimestampMillis(ctx context.Context, block uint64) (int64, error) {
 	if p.blkCache != nil {
-		if ts, ok := p.blkCache.get(block, time.Now()); ok {
+		if ts, ok := p.blkCache.get(block, p.clk().Now()); ok {
 			return ts, nil
 		}
 	}
-	var blk struct {
-		Timestamp string `json:"timestamp"`
-	}
-	params := []interface{}{toHex(block), false}
-	if err := p.call(ctx, "eth_getBlockByNumber", params, &blk); err != nil {
-		return 0, err
-	}
-	sec, err := hexToUint64(blk.Timestamp)
+	v, err, _ := p.flight.Do("ts:"+strconv.FormatUint(block, 10), func() (any, error) {
+		var blk struct {
+			Timestamp string `json:"timestamp"`
+		}
+		if err := p.getBlock(ctx, block, false, &blk); err != nil {
+			return nil, err
+		}
+		sec, err := hexToUint64(blk.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		ts := int64(sec) * 1000
+		if p.blkCache != nil {
+			p.blkCache.add(block, ts, p.clk().Now())
+		}
+		return ts, nil
+	})
 	if err != nil {
 		return 0, err
 	}
-	ts := int64(sec) * 1000
-	if p.blkCache != nil {
-		p.blkCache.add(block, ts, time.Now())
+	return v.(int64), nil
+}
+
+// rpcFullBlock is the subset of eth_getBlockByNumber(n, true) we use.
+type rpcFullBlock struct {
+	Timestamp    string          `json:"timestamp"`
+	Withdrawals  []rpcWithdrawal `json:"withdrawals"`
+	Transactions []struct {
+		Hash       string           `json:"hash"`
+		Type       string           `json:"type"`
+		From       string           `json:"from"`
+		To         *string          `json:"to"`
+		Input      string           `json:"input"`
+		Value      string           `json:"value"`
+		GasPrice   string           `json:"gasPrice"`
+		AccessList []rpcAccessTuple `json:"accessList"`
+	} `json:"transactions"`
+}
+
+// rpcAccessTuple is one EIP-2930 access list entry.
+type rpcAccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// fullBlock fetches a block with transaction objects. Concurrent fetches of
+// the same block share one call; the result must be treated as read-only.
+func (p *httpProvider) fullBlock(ctx context.Context, block uint64) (*rpcFullBlock, error) {
+	v, err, _ := p.flight.Do("block:"+strconv.FormatUint(block, 10), func() (any, error) {
+		var blk rpcFullBlock
+		if err := p.getBlock(ctx, block, true, &blk); err != nil {
+			return nil, err
+		}
+		return &blk, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return ts, nil
+	return v.(*rpcFullBlock), nil
 }
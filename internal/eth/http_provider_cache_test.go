@@ -1,10 +1,38 @@
 package eth
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/pkg/clock"
 )
 
+func TestExportedTimestampCache_NoExpiryAndPrune(t *testing.T) {
+	c := NewTimestampCache(3)
+	for b := uint64(1); b <= 4; b++ {
+		c.Add(b, int64(b)*1000)
+	}
+	if c.Len() != 3 || c.Cap() != 3 {
+		t.Fatalf("len=%d cap=%d, want 3/3", c.Len(), c.Cap())
+	}
+	if _, ok := c.Get(1); ok {
+		t.Fatal("block 1 should have been evicted")
+	}
+	// No TTL applies, however old the entries are.
+	if ts, ok := c.c.get(2, time.Now().Add(1000*time.Hour)); !ok || ts != 2000 {
+		t.Fatalf("block 2 expired: ts=%d ok=%v", ts, ok)
+	}
+	c.Prune(3)
+	if _, ok := c.Get(3); ok || c.Len() != 1 {
+		t.Fatalf("prune kept blocks at or above 3: len=%d", c.Len())
+	}
+}
+
 func TestTimestampCacheEvictsAndExpires(t *testing.T) {
 	cache := newTimestampCache(2, 10*time.Millisecond)
 	now := time.Now()
@@ -78,3 +106,107 @@ func TestTimestampCacheEvictHandlesEmptyList(t *testing.T) {
 	cache := newTimestampCache(1, time.Second)
 	cache.evict(time.Now())
 }
+
+func TestTimestampCacheFinalizedBlocksOutliveTTL(t *testing.T) {
+	cache := newTimestampCache(8, 10*time.Millisecond)
+	cache.recentTTL = 5 * time.Millisecond
+	cache.setFinalized(100)
+	now := time.Now()
+	cache.add(90, 900, now)
+	cache.add(100, 1000, now)
+	cache.add(101, 1010, now)
+	later := now.Add(time.Hour)
+	if v, ok := cache.get(90, later); !ok || v != 900 {
+		t.Fatalf("expected finalized block 90 to persist, got ok=%v value=%d", ok, v)
+	}
+	if v, ok := cache.get(100, later); !ok || v != 1000 {
+		t.Fatalf("expected safe head block 100 to persist, got ok=%v value=%d", ok, v)
+	}
+	if _, ok := cache.get(101, now.Add(5*time.Millisecond)); ok {
+		t.Fatalf("expected head-adjacent block 101 to expire after recent ttl")
+	}
+}
+
+func TestTimestampCacheSetFinalizedIsMonotonic(t *testing.T) {
+	cache := newTimestampCache(4, time.Minute)
+	cache.setFinalized(50)
+	cache.setFinalized(40)
+	if cache.finalized != 50 {
+		t.Fatalf("expected finalized to stay at 50, got %d", cache.finalized)
+	}
+	now := time.Now()
+	cache.add(45, 450, now)
+	if v, ok := cache.get(45, now.Add(24*time.Hour)); !ok || v != 450 {
+		t.Fatalf("expected block 45 pinned, got ok=%v value=%d", ok, v)
+	}
+}
+
+func TestHTTPProviderSetSafeHeadPinsTimestamps(t *testing.T) {
+	p, err := NewHTTPProvider("http://unit-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	WrapWithLimiter(p, NewLimiter(0)).(SafeHeadAware).SetSafeHead(10)
+	if !hp.blkCache.hasFinalized || hp.blkCache.finalized != 10 {
+		t.Fatalf("safe head not forwarded to cache: %+v", hp.blkCache)
+	}
+	hp.blkCache = nil
+	hp.SetSafeHead(11)
+}
+
+func TestBlockTimestampCacheFollowsInjectedClock(t *testing.T) {
+	calls := 0
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return mkResp(map[string]any{"timestamp": "0x10"}), nil
+	})}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	hp := p.(*httpProvider)
+	WithClock(fake)(hp)
+	for range 2 {
+		if ts, err := hp.BlockTimestamp(context.Background(), 7); err != nil || ts != 16000 {
+			t.Fatalf("ts=%d err=%v", ts, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want the second read cached", calls)
+	}
+	fake.Advance(defaultBlockTimestampTTL + time.Second)
+	if _, err := hp.BlockTimestamp(context.Background(), 7); err != nil || calls != 2 {
+		t.Fatalf("calls=%d err=%v, want a refetch after the TTL", calls, err)
+	}
+}
+
+func TestRetryBackoffFollowsInjectedClock(t *testing.T) {
+	calls := 0
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader("busy")), Header: make(http.Header)}, nil
+		}
+		return mkResp("0x2a"), nil
+	})}
+	fake := clock.NewFake(time.Time{})
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	hp := p.(*httpProvider)
+	WithClock(fake)(hp)
+	hp.backoffBase = time.Hour
+	done := make(chan error, 1)
+	go func() {
+		_, err := hp.BlockNumber(context.Background())
+		done <- err
+	}()
+	for range 2 {
+		fake.BlockUntil(1)
+		waits := fake.Waits()
+		fake.Advance(waits[len(waits)-1])
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Duration{time.Hour, 2 * time.Hour}; !reflect.DeepEqual(fake.Waits(), want) {
+		t.Fatalf("backoffs = %v, want %v", fake.Waits(), want)
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -112,6 +114,45 @@ func TestHTTPProvider_BlockTimestamp_HexError(t *testing.T) {
 	}
 }
 
+func TestHTTPProvider_BlockTimestamp_NullBlockRetried(t *testing.T) {
+	calls := 0
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			// A replica behind the head has not seen the block yet.
+			return mkResp(nil), nil
+		}
+		return mkResp(map[string]any{"timestamp": "0x64"}), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	hp := p.(*httpProvider)
+	hp.maxRetries = 2
+	hp.backoffBase = 1
+	ts, err := p.BlockTimestamp(context.Background(), 123)
+	if err != nil || ts != 100000 || calls != 3 {
+		t.Fatalf("ts=%d err=%v calls=%d", ts, err, calls)
+	}
+}
+
+func TestHTTPProvider_NullBlockNotFound(t *testing.T) {
+	addr := "0xdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef"
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return mkResp(nil), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	hp := p.(*httpProvider)
+	hp.maxRetries = 1
+	hp.backoffBase = 1
+	_, err := p.BlockTimestamp(context.Background(), 123)
+	if !errors.Is(err, ErrBlockNotFound) {
+		t.Fatalf("expected ErrBlockNotFound, got %v", err)
+	}
+	// The block fails like any unreadable one, not as a timestamp parse error.
+	if _, err = p.Transactions(context.Background(), addr, 7, 7); !errors.Is(err, ErrBlockNotFound) {
+		t.Fatalf("expected ErrBlockNotFound, got %v", err)
+	}
+}
+
 func TestHTTPProvider_ContextCancelDuringBackoff(t *testing.T) {
 	calls := 0
 	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
@@ -268,6 +309,88 @@ func TestHTTPProvider_TraceBlock_AfterIncrementPaging(t *testing.T) {
 	}
 }
 
+// pagedTraces serves total traces through trace_filter, honoring its after
+// and count parameters, and counts the pages requested.
+func pagedTraces(total int, pages *int) *http.Client {
+	return &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["method"] != "trace_filter" {
+			return mkResp(map[string]any{"timestamp": "0x1"}), nil
+		}
+		*pages++
+		obj := req["params"].([]any)[0].(map[string]any)
+		after, count := int(obj["after"].(float64)), int(obj["count"].(float64))
+		arr := []map[string]any{}
+		for i := after; i < total && i < after+count; i++ {
+			arr = append(arr, map[string]any{
+				"transactionHash": "0x1",
+				"blockNumber":     "0x10",
+				"traceAddress":    []int{i},
+				"action":          map[string]any{"from": "0x", "to": "0x", "value": "0x1"},
+			})
+		}
+		return mkResp(arr), nil
+	})}
+}
+
+func TestHTTPProvider_TraceBlock_Limits(t *testing.T) {
+	cases := []struct {
+		name             string
+		total            int
+		maxTraces, pages int
+		wantPages        int
+		wantErr          string
+	}{
+		{"pages", 1 << 20, 0, 3, 3, "more than 3 pages"},
+		{"traces", 1 << 20, 2500, 0, 3, "more than 2500 traces"},
+		{"one trace over the page limit", 3001, 0, 3, 3, "more than 3 pages"},
+		{"one trace over the trace limit", 2001, 2000, 0, 2, "more than 2000 traces"},
+	}
+	for _, tc := range cases {
+		served := 0
+		p, _ := NewHTTPProvider("http://unit-test", pagedTraces(tc.total, &served))
+		WithTraceLimits(tc.maxTraces, tc.pages)(p.(*httpProvider))
+		out, err := p.TraceBlock(context.Background(), 1, 2, "0x")
+		if !errors.Is(err, ErrTraceLimitExceeded) || !strings.Contains(err.Error(), tc.wantErr) || out != nil {
+			t.Fatalf("%s: out=%d err=%v", tc.name, len(out), err)
+		}
+		if served != tc.wantPages {
+			t.Fatalf("%s: served %d pages, want %d", tc.name, served, tc.wantPages)
+		}
+	}
+}
+
+func TestHTTPProvider_TraceBlock_ExactPages(t *testing.T) {
+	cases := []struct {
+		name             string
+		total            int
+		maxTraces, pages int
+		wantPages        int
+	}{
+		{"multiple of the page size", 2000, 0, 0, 2},
+		{"count equals the page limit", 3000, 0, 3, 3},
+		{"count equals the trace limit", 2000, 2000, 0, 2},
+		{"short last page", 1500, 2000, 2, 2},
+		{"empty", 0, 0, 0, 1},
+	}
+	for _, tc := range cases {
+		served := 0
+		p, _ := NewHTTPProvider("http://unit-test", pagedTraces(tc.total, &served))
+		WithTraceLimits(tc.maxTraces, tc.pages)(p.(*httpProvider))
+		out, err := p.TraceBlock(context.Background(), 1, 2, "0x")
+		if err != nil || len(out) != tc.total {
+			t.Fatalf("%s: out=%d err=%v", tc.name, len(out), err)
+		}
+		if served != tc.wantPages {
+			t.Fatalf("%s: served %d pages, want %d", tc.name, served, tc.wantPages)
+		}
+		if tc.total > 0 && out[tc.total-1].TraceID != fmt.Sprint(tc.total-1) {
+			t.Fatalf("%s: last trace %s, want %d", tc.name, out[tc.total-1].TraceID, tc.total-1)
+		}
+	}
+}
+
 func TestHTTPProvider_GetLogs_TimestampEnrichmentError(t *testing.T) {
 	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
 		var req map[string]any
@@ -333,6 +456,45 @@ func TestHTTPProvider_TraceBlock_TimestampEnrichmentError(t *testing.T) {
 	}
 }
 
+func TestHTTPProvider_WithoutTimestampEnrichment(t *testing.T) {
+	blockFetches := 0
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "eth_getLogs":
+			return mkResp([]map[string]any{
+				{"transactionHash": "0x1", "logIndex": "0x0", "address": "0xdead", "topics": []string{"0x01"}, "data": "0x", "blockNumber": "0x10"},
+				{"transactionHash": "0x2", "logIndex": "0x1", "address": "0xdead", "topics": []string{"0x01"}, "data": "0x", "blockNumber": "0x11"},
+			}), nil
+		case "trace_filter":
+			return mkResp([]map[string]any{{
+				"transactionHash": "0x1", "blockNumber": "0x10", "traceAddress": []int{}, "action": map[string]any{"from": "0x", "to": "0x", "value": "0x1"},
+			}}), nil
+		case "eth_getBlockByNumber":
+			blockFetches++
+			return mkResp(map[string]any{"timestamp": "0x10"}), nil
+		}
+		return mkResp(nil), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	WithoutTimestampEnrichment()(p.(*httpProvider))
+	logs, err := p.GetLogs(context.Background(), "0xdead", 1, 20, nil)
+	if err != nil || len(logs) != 2 {
+		t.Fatalf("logs=%+v err=%v", logs, err)
+	}
+	traces, err := p.TraceBlock(context.Background(), 1, 20, "0xdead")
+	if err != nil || len(traces) != 1 {
+		t.Fatalf("traces=%+v err=%v", traces, err)
+	}
+	if blockFetches != 0 {
+		t.Fatalf("eth_getBlockByNumber called %d times with enrichment disabled", blockFetches)
+	}
+	if logs[0].TsMillis != 0 || logs[1].TsMillis != 0 || traces[0].TsMillis != 0 {
+		t.Fatalf("timestamps must stay unset: logs=%+v traces=%+v", logs, traces)
+	}
+}
+
 func TestHTTPProvider_BlockTimestamp_CallError(t *testing.T) {
 	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
 		return &http.Response{StatusCode: 500, Body: io.NopCloser(bytes.NewReader([]byte("fail")))}, nil
@@ -0,0 +1,90 @@
+package eth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedBlockClient answers eth_getBlockByNumber only after release is
+// closed, counting calls, so concurrent callers pile up on one fetch.
+func gatedBlockClient(calls *atomic.Int32, release <-chan struct{}) *http.Client {
+	return &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		<-release
+		return mkResp(map[string]any{"timestamp": "0x64", "transactions": []any{}}), nil
+	})}
+}
+
+func TestBlockTimestamp_ConcurrentCallsShareOneRPC(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	p, err := NewHTTPProvider("http://unit-test", gatedBlockClient(&calls, release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.blkCache = nil // isolate single-flight from the timestamp cache
+
+	const n = 32
+	var wg sync.WaitGroup
+	results := make([]int64, n)
+	errs := make([]error, n)
+	for k := 0; k < n; k++ {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			results[k], errs[k] = p.BlockTimestamp(context.Background(), 7)
+		}(k)
+	}
+	// Let every goroutine join the in-flight call before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("underlying RPC calls = %d, want 1", got)
+	}
+	for k := 0; k < n; k++ {
+		if errs[k] != nil || results[k] != 100000 {
+			t.Fatalf("caller %d: ts=%d err=%v", k, results[k], errs[k])
+		}
+	}
+	// Completed flights are not reused: a later call fetches again.
+	if _, err := p.BlockTimestamp(context.Background(), 7); err != nil || calls.Load() != 2 {
+		t.Fatalf("calls=%d err=%v", calls.Load(), err)
+	}
+}
+
+func TestFullBlock_ConcurrentFetchesShareOneRPC(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	p, err := NewHTTPProvider("http://unit-test", gatedBlockClient(&calls, release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	var wg sync.WaitGroup
+	for k := 0; k < 8; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if blk, err := hp.fullBlock(context.Background(), 9); err != nil || blk.Timestamp != "0x64" {
+				t.Errorf("blk=%+v err=%v", blk, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("underlying RPC calls = %d, want 1", got)
+	}
+	// Timestamp and full-block fetches use distinct keys.
+	if _, err := hp.blockTimestampMillis(context.Background(), 9); err != nil || calls.Load() != 2 {
+		t.Fatalf("calls=%d err=%v", calls.Load(), err)
+	}
+}
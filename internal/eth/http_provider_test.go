@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -119,9 +121,10 @@ func TestHTTPProvider_TraceFilterPaging(t *testing.T) {
 						"blockNumber":     "0x11",
 						"traceAddress":    []int{0, 1},
 						"action": map[string]any{
-							"from":  "0xfrom2",
-							"to":    "0xto2",
-							"value": "0x2",
+							"from":     "0xfrom2",
+							"to":       "0xto2",
+							"value":    "0x2",
+							"callType": "DelegateCall",
 						},
 					},
 				}
@@ -152,6 +155,9 @@ func TestHTTPProvider_TraceFilterPaging(t *testing.T) {
 	if out[0].TraceID != "root" || out[1].TraceID != "0-1" {
 		t.Fatalf("unexpected trace ids: %+v", out)
 	}
+	if out[0].CallType != "" || out[1].CallType != "delegatecall" {
+		t.Fatalf("unexpected call types: %+v", out)
+	}
 	if out[0].TsMillis == 0 || out[1].TsMillis == 0 {
 		t.Fatalf("timestamps not enriched: %+v", out)
 	}
@@ -205,6 +211,29 @@ func TestHTTPProvider_RpcErrorAndNoRetryOn400(t *testing.T) {
 	}
 }
 
+func TestHTTPProvider_RpcErrorIncludesData(t *testing.T) {
+	var payload string
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(payload)), Header: http.Header{"Content-Type": []string{"application/json"}}}, nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	for body, want := range map[string]string{
+		`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted","data":"0x08c379a0"}}`:              "rpc 3: execution reverted (data: 0x08c379a0)",
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"oops","data":{ "trace": "abc", "retry": false }}}`: `rpc -32000: oops (data: {"trace":"abc","retry":false})`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"oops","data":null}}`:                               "rpc -32000: oops",
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"oops"}}`:                                           "rpc -32000: oops",
+	} {
+		payload = body
+		if _, err := p.BlockNumber(context.Background()); err == nil || err.Error() != want {
+			t.Fatalf("err = %v, want %q", err, want)
+		}
+	}
+	long := &rpcError{Code: 3, Message: "m", Data: json.RawMessage(`"` + strings.Repeat("a", maxErrorData+10) + `"`)}
+	if got := long.Error(); !strings.HasSuffix(got, strings.Repeat("a", 8)+"...)") || len(got) > maxErrorData+32 {
+		t.Fatalf("long data not truncated: %d bytes", len(got))
+	}
+}
+
 func TestHTTPProvider_BlockTimestampCache(t *testing.T) {
 	calls := 0
 	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
@@ -709,8 +738,8 @@ func TestHTTPProvider_TransactionsSkipsMissingReceipts(t *testing.T) {
 	defer logging.SetLogger(prev)
 
 	txs, err := p.Transactions(context.Background(), target, 10, 10)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	if got := MissingBlocks(err); !reflect.DeepEqual(got, []BlockRange{{From: 10, To: 10}}) {
+		t.Fatalf("expected block 10 reported missing, got %v (err %v)", got, err)
 	}
 	if len(txs) != 1 {
 		t.Fatalf("expected 1 transaction with available receipt, got %d", len(txs))
@@ -720,6 +749,96 @@ func TestHTTPProvider_TransactionsSkipsMissingReceipts(t *testing.T) {
 	}
 }
 
+func TestHTTPProvider_TransactionsEmitsReceiptless(t *testing.T) {
+	const target = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "eth_getBlockByNumber":
+			return mkResp(map[string]any{
+				"timestamp": "0x64",
+				"transactions": []map[string]any{
+					{"hash": "0xaaa", "from": target, "to": target, "input": "0x", "value": "0x1"},
+					{"hash": "0xbbb", "from": target, "to": "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "input": "0x", "value": "0x2"},
+				},
+			}), nil
+		case "eth_getBlockReceipts":
+			return mkResp([]map[string]any{
+				{"transactionHash": "0xaaa", "status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x1"},
+			}), nil
+		default:
+			// The pruned receipt of 0xbbb.
+			return mkResp(nil), nil
+		}
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	WithReceiptlessTransactions()(hp)
+	prev := logging.Logger()
+	logging.SetLogger(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	defer logging.SetLogger(prev)
+
+	txs, err := p.Transactions(context.Background(), target, 10, 10)
+	if got := MissingBlocks(err); !reflect.DeepEqual(got, []BlockRange{{From: 10, To: 10}}) {
+		t.Fatalf("expected block 10 still reported missing, got %v (err %v)", got, err)
+	}
+	if len(txs) != 2 || txs[0].ReceiptMissing || txs[0].GasUsed != 0x5208 {
+		t.Fatalf("transactions = %+v", txs)
+	}
+	want := Transaction{Hash: "0xbbb", From: target, To: "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", ValueWei: "0x2", InputHex: "0x", BlockNum: 10, TsMillis: 100000, ReceiptMissing: true}
+	if txs[1] != want {
+		t.Fatalf("receiptless transaction = %+v, want %+v", txs[1], want)
+	}
+}
+
+func TestHTTPProvider_TransactionsGasPriceSourcing(t *testing.T) {
+	const target = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "eth_getBlockByNumber":
+			return mkResp(map[string]any{
+				"timestamp": "0x64",
+				"transactions": []map[string]any{
+					// Legacy: no type (pre-Berlin node) and 0x0.
+					{"hash": "0xa1", "from": target, "to": target, "input": "0x", "value": "0x0", "gasPrice": "0x4a817c800"},
+					{"hash": "0xa2", "type": "0x0", "from": target, "to": target, "input": "0x", "value": "0x0", "gasPrice": "0x3b9aca00"},
+					// EIP-1559: the receipt's effective price wins.
+					{"hash": "0xb1", "type": "0x2", "from": target, "to": target, "input": "0x", "value": "0x0", "gasPrice": "0x77359400", "maxFeePerGas": "0xba43b7400"},
+					// Access-list transaction whose receipt predates effectiveGasPrice.
+					{"hash": "0xc1", "type": "0x1", "from": target, "to": target, "input": "0x", "value": "0x0", "gasPrice": "0x2540be400"},
+				},
+			}), nil
+		case "eth_getBlockReceipts":
+			return mkResp([]map[string]any{
+				{"transactionHash": "0xa1", "status": "0x1", "gasUsed": "0x5208"},
+				{"transactionHash": "0xa2", "status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00"},
+				{"transactionHash": "0xb1", "status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x6fc23ac00"},
+				{"transactionHash": "0xc1", "status": "0x1", "gasUsed": "0x5208"},
+			}), nil
+		default:
+			return mkResp(nil), nil
+		}
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	txs, err := p.Transactions(context.Background(), target, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"0xa1": "0x4a817c800", "0xa2": "0x3b9aca00", "0xb1": "0x6fc23ac00", "0xc1": "0x2540be400"}
+	if len(txs) != len(want) {
+		t.Fatalf("transactions = %+v", txs)
+	}
+	for _, tx := range txs {
+		if tx.GasPriceWei != want[tx.Hash] {
+			t.Fatalf("%s gas price = %q, want %q", tx.Hash, tx.GasPriceWei, want[tx.Hash])
+		}
+	}
+}
+
 func TestHTTPProvider_TransactionsContextCancellation(t *testing.T) {
 	const target = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
 	ctx, cancel := context.WithCancel(context.Background())
@@ -768,8 +887,8 @@ func TestHTTPProvider_TransactionsContextCancellation(t *testing.T) {
 	defer logging.SetLogger(prev)
 
 	txs, err := p.Transactions(ctx, target, 1, 2)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	if got := MissingBlocks(err); !reflect.DeepEqual(got, []BlockRange{{From: 2, To: 2}}) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected block 2 missing after cancellation, got %v (err %v)", got, err)
 	}
 	if len(txs) != 1 {
 		t.Fatalf("expected 1 transaction before cancellation, got %d", len(txs))
@@ -990,12 +1109,84 @@ func TestHTTPProvider_TransactionsPartialBlockFailure(t *testing.T) {
 		hp.backoffBase = 1
 	}
 	txs, err := p.Transactions(context.Background(), target, 10, 11)
+	if got := MissingBlocks(err); !reflect.DeepEqual(got, []BlockRange{{From: 11, To: 11}}) {
+		t.Fatalf("expected block 11 reported missing, got %v (err %v)", got, err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("expected partial success, got %d", len(txs))
+	}
+}
+
+func TestHTTPProvider_TransactionsMergesMissingBlocks(t *testing.T) {
+	const target = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "eth_getBlockByNumber":
+			if req["params"].([]any)[0].(string) == "0xa" {
+				return mkResp(map[string]any{
+					"timestamp":    "0x64",
+					"transactions": []map[string]any{{"hash": "0xaaa", "from": target, "to": target, "input": "0x", "value": "0x1"}},
+				}), nil
+			}
+			return &http.Response{StatusCode: 500, Body: io.NopCloser(bytes.NewReader([]byte("boom")))}, nil
+		case "eth_getTransactionReceipt":
+			return mkResp(map[string]any{"status": "0x1", "gasUsed": "0x5208"}), nil
+		}
+		return mkResp(nil), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatal(err)
+	}
+	p.(*httpProvider).backoffBase = 1
+	txs, err := p.Transactions(context.Background(), target, 10, 13)
+	if got := MissingBlocks(err); !reflect.DeepEqual(got, []BlockRange{{From: 11, To: 13}}) {
+		t.Fatalf("missing = %v (err %v)", got, err)
 	}
 	if len(txs) != 1 {
 		t.Fatalf("expected partial success, got %d", len(txs))
 	}
+	if MissingBlocks(errors.New("other")) != nil {
+		t.Fatal("plain errors report no missing blocks")
+	}
+}
+
+func TestHTTPProvider_TransactionsStrictFailsOnBlockError(t *testing.T) {
+	const target = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "eth_getBlockByNumber":
+			params := req["params"].([]any)
+			if params[0].(string) == "0xa" {
+				return mkResp(map[string]any{
+					"timestamp":    "0x64",
+					"transactions": []map[string]any{{"hash": "0xaaa", "from": target, "to": target, "input": "0x", "value": "0x1"}},
+				}), nil
+			}
+			return &http.Response{StatusCode: 500, Body: io.NopCloser(bytes.NewReader([]byte("boom")))}, nil
+		case "eth_getTransactionReceipt":
+			return mkResp(map[string]any{"status": "0x1", "gasUsed": "0x5208"}), nil
+		}
+		return mkResp(nil), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	WithStrictTransactions()(hp)
+	txs, err := p.Transactions(context.Background(), target, 10, 11)
+	if err == nil || !strings.Contains(err.Error(), "block 11") {
+		t.Fatalf("expected block 11 error, got %v", err)
+	}
+	if txs != nil {
+		t.Fatalf("strict mode returned partial results: %+v", txs)
+	}
 }
 
 func TestHTTPProvider_CallBlockReceiptsNoFilter(t *testing.T) {
@@ -1467,3 +1658,52 @@ func TestHTTPProvider_FetchReceiptsIndividuallyVariants(t *testing.T) {
 		t.Fatalf("empty input fast-path mismatch: res=%v calls=%d failures=%d err=%v", res, calls, failures, err)
 	}
 }
+
+func TestHTTPProvider_TransactionsAccessList(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch req["method"] {
+		case "eth_getBlockByNumber":
+			return mkResp(map[string]any{
+				"timestamp": "0x64",
+				"transactions": []map[string]any{
+					{"hash": "0xlegacy", "type": "0x0", "from": addr, "to": "0x" + strings.Repeat("b", 40), "input": "0x", "value": "0x0"},
+					{
+						"hash": "0xtyped", "type": "0x2", "from": addr, "to": "0x" + strings.Repeat("c", 40), "input": "0x", "value": "0x0",
+						"accessList": []map[string]any{
+							{"address": "0x" + strings.Repeat("c", 40), "storageKeys": []string{"0x" + strings.Repeat("0", 64), "0x" + strings.Repeat("0", 63) + "1"}},
+							{"address": "0x" + strings.Repeat("d", 40), "storageKeys": []string{"0x" + strings.Repeat("0", 63) + "2"}},
+							{"address": "0x" + strings.Repeat("e", 40), "storageKeys": []string{}},
+						},
+					},
+				},
+			}), nil
+		case "eth_getBlockReceipts":
+			return mkResp([]map[string]any{
+				{"transactionHash": "0xlegacy", "status": "0x1", "gasUsed": "0x5208"},
+				{"transactionHash": "0xtyped", "status": "0x1", "gasUsed": "0x9c40"},
+			}), nil
+		}
+		t.Fatalf("unexpected method %v", req["method"])
+		return nil, nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.Transactions(context.Background(), addr, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 transactions, got %+v", out)
+	}
+	if out[0].AccessListCount != 0 || out[0].AccessListKeys != 0 {
+		t.Fatalf("legacy tx access list = %d/%d, want 0/0", out[0].AccessListCount, out[0].AccessListKeys)
+	}
+	if out[1].AccessListCount != 3 || out[1].AccessListKeys != 3 {
+		t.Fatalf("typed tx access list = %d/%d, want 3/3", out[1].AccessListCount, out[1].AccessListKeys)
+	}
+}
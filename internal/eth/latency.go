@@ -0,0 +1,103 @@
+package eth
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultLatencyWindow bounds the samples retained per JSON-RPC method so
+// long-running processes keep a fixed memory footprint.
+const defaultLatencyWindow = 4096
+
+// LatencyStats summarizes recorded call latency for one JSON-RPC method.
+type LatencyStats struct {
+	Method string
+	Count  int
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+}
+
+// LatencyRecorder collects per-method call durations in bounded ring buffers
+// and reports nearest-rank percentiles over the retained window.
+type LatencyRecorder struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	window  int
+	methods map[string]*latencyWindow
+}
+
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	total   int
+}
+
+// NewLatencyRecorder returns a recorder using the wall clock.
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{now: time.Now, window: defaultLatencyWindow, methods: make(map[string]*latencyWindow)}
+}
+
+// start returns a function that records the elapsed time for method when
+// called. A nil recorder yields a no-op so instrumentation stays cheap.
+func (r *LatencyRecorder) start(method string) func() {
+	if r == nil {
+		return func() {}
+	}
+	begin := r.now()
+	return func() { r.observe(method, r.now().Sub(begin)) }
+}
+
+func (r *LatencyRecorder) observe(method string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.methods[method]
+	if !ok {
+		w = &latencyWindow{samples: make([]time.Duration, 0, 16)}
+		r.methods[method] = w
+	}
+	if len(w.samples) < r.window {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+		w.next = (w.next + 1) % r.window
+	}
+	w.total++
+}
+
+// Snapshot returns percentiles per method, sorted by method name. Count
+// reports all observed calls, including those rotated out of the window.
+func (r *LatencyRecorder) Snapshot() []LatencyStats {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]LatencyStats, 0, len(r.methods))
+	for method, w := range r.methods {
+		sorted := append([]time.Duration(nil), w.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out = append(out, LatencyStats{
+			Method: method,
+			Count:  w.total,
+			P50:    percentile(sorted, 50),
+			P95:    percentile(sorted, 95),
+			P99:    percentile(sorted, 99),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Method < out[j].Method })
+	return out
+}
+
+// percentile returns the nearest-rank percentile p (0-100] of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,90 @@
+package eth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t     time.Time
+	steps []time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	cur := c.t
+	if len(c.steps) > 0 {
+		c.t = c.t.Add(c.steps[0])
+		c.steps = c.steps[1:]
+	}
+	return cur
+}
+
+func TestLatencyRecorderPercentiles(t *testing.T) {
+	rec := NewLatencyRecorder()
+	// start() reads the clock once and the stop func once; the step taken
+	// after the first read becomes the recorded duration.
+	clk := &fakeClock{t: time.Unix(0, 0)}
+	for i := 1; i <= 100; i++ {
+		clk.steps = append(clk.steps, time.Duration(i)*time.Millisecond, 0)
+	}
+	rec.now = clk.now
+	for i := 0; i < 100; i++ {
+		rec.start("eth_getLogs")()
+	}
+	rec.start("eth_blockNumber")()
+	stats := rec.Snapshot()
+	if len(stats) != 2 || stats[0].Method != "eth_blockNumber" || stats[1].Method != "eth_getLogs" {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	got := stats[1]
+	if got.Count != 100 || got.P50 != 50*time.Millisecond || got.P95 != 95*time.Millisecond || got.P99 != 99*time.Millisecond {
+		t.Fatalf("unexpected percentiles: %+v", got)
+	}
+	if stats[0].Count != 1 || stats[0].P99 != 0 {
+		t.Fatalf("unexpected blockNumber stats: %+v", stats[0])
+	}
+}
+
+func TestLatencyRecorderWindowRotates(t *testing.T) {
+	rec := NewLatencyRecorder()
+	rec.window = 2
+	rec.observe("m", 1*time.Millisecond)
+	rec.observe("m", 2*time.Millisecond)
+	rec.observe("m", 9*time.Millisecond)
+	s := rec.Snapshot()[0]
+	if s.Count != 3 || s.P50 != 2*time.Millisecond || s.P99 != 9*time.Millisecond {
+		t.Fatalf("unexpected rotated stats: %+v", s)
+	}
+}
+
+func TestLatencyRecorderNilIsNoop(t *testing.T) {
+	var rec *LatencyRecorder
+	rec.start("x")()
+	if rec.Snapshot() != nil {
+		t.Fatal("expected nil snapshot")
+	}
+	if percentile(nil, 50) != 0 {
+		t.Fatal("expected zero percentile for empty samples")
+	}
+}
+
+func TestHTTPProviderRecordsLatencyPerMethod(t *testing.T) {
+	rec := NewLatencyRecorder()
+	p, err := NewProvider("http://unit-test", 0, 0, 0, WithLatencyRecorder(rec), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(RLProvider).p.(*httpProvider)
+	hp.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return mkResp("0x2a"), nil
+	})}
+	if _, err := p.BlockNumber(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stats := rec.Snapshot()
+	if len(stats) != 1 || stats[0].Method != "eth_blockNumber" || stats[0].Count != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,119 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrTxNotFound is returned by TransactionBlock when the node knows no
+// transaction with the hash, e.g. one dropped from the mempool or replaced.
+var ErrTxNotFound = errors.New("transaction not found")
+
+// PendingTransaction is a mempool transaction not yet included in a block.
+type PendingTransaction struct {
+	Hash     string
+	From     string
+	To       string // empty for contract creations
+	ValueWei string
+	InputHex string
+	Nonce    uint64
+}
+
+// PendingReader is optionally implemented by providers whose node exposes
+// its mempool (txpool_content, served by geth, Erigon and Nethermind but
+// rarely by hosted endpoints).
+type PendingReader interface {
+	// PendingTransactions returns the executable pending transactions sent
+	// from or to address, sorted by sender and nonce.
+	PendingTransactions(ctx context.Context, address string) ([]PendingTransaction, error)
+	// TransactionBlock returns the block holding the transaction, mined
+	// false while it is still pending, or ErrTxNotFound.
+	TransactionBlock(ctx context.Context, hash string) (block uint64, mined bool, err error)
+}
+
+// rpcPendingTx is the subset of a txpool_content entry we keep.
+type rpcPendingTx struct {
+	Hash  string  `json:"hash"`
+	From  string  `json:"from"`
+	To    *string `json:"to"`
+	Value string  `json:"value"`
+	Input string  `json:"input"`
+	Nonce string  `json:"nonce"`
+}
+
+// PendingTransactions reads txpool_content and keeps the pending (not the
+// queued, nonce-gapped) transactions touching address. The whole pool is
+// transferred on every call, so poll it sparingly on busy chains. Nodes
+// without the txpool namespace yield ErrUnsupported.
+func (p *httpProvider) PendingTransactions(ctx context.Context, address string) ([]PendingTransaction, error) {
+	var res struct {
+		Pending map[string]map[string]rpcPendingTx `json:"pending"`
+	}
+	if err := p.call(ctx, MethodTxpoolContent, []interface{}{}, &res); err != nil {
+		if isMethodNotFound(err) {
+			return nil, ErrUnsupported
+		}
+		return nil, err
+	}
+	addr := strings.ToLower(address)
+	var out []PendingTransaction
+	for _, byNonce := range res.Pending {
+		for _, tx := range byNonce {
+			from := strings.ToLower(tx.From)
+			to := ""
+			if tx.To != nil {
+				to = strings.ToLower(*tx.To)
+			}
+			if from != addr && to != addr {
+				continue
+			}
+			nonce, err := hexToUint64(tx.Nonce)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, PendingTransaction{
+				Hash:     strings.ToLower(tx.Hash),
+				From:     from,
+				To:       to,
+				ValueWei: tx.Value,
+				InputHex: tx.Input,
+				Nonce:    nonce,
+			})
+		}
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].From != out[b].From {
+			return out[a].From < out[b].From
+		}
+		return out[a].Nonce < out[b].Nonce
+	})
+	return out, nil
+}
+
+// TransactionBlock looks the transaction up with eth_getTransactionByHash.
+func (p *httpProvider) TransactionBlock(ctx context.Context, hash string) (uint64, bool, error) {
+	var raw json.RawMessage
+	if err := p.call(ctx, MethodGetTransactionByHash, []interface{}{hash}, &raw); err != nil {
+		return 0, false, err
+	}
+	var tx *struct {
+		BlockNumber *string `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(raw, &tx); err != nil {
+		return 0, false, err
+	}
+	if tx == nil {
+		return 0, false, ErrTxNotFound
+	}
+	if tx.BlockNumber == nil {
+		return 0, false, nil
+	}
+	block, err := hexToUint64(*tx.BlockNumber)
+	if err != nil {
+		return 0, false, err
+	}
+	return block, true, nil
+}
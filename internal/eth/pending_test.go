@@ -0,0 +1,102 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPProvider_PendingTransactions(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	other := "0x" + strings.Repeat("cd", 20)
+	pool := map[string]any{
+		"pending": map[string]any{
+			strings.ToUpper(addr[2:]): map[string]any{
+				"8": map[string]any{"hash": "0xB2", "from": addr, "to": other, "value": "0x1", "input": "0xa9059cbb", "nonce": "0x8"},
+				"7": map[string]any{"hash": "0xb1", "from": addr, "to": nil, "value": "0x0", "input": "0x6080", "nonce": "0x7"},
+			},
+			other: map[string]any{
+				"3": map[string]any{"hash": "0xc1", "from": other, "to": strings.ToUpper(addr), "value": "0xde", "input": "0x", "nonce": "0x3"},
+				"4": map[string]any{"hash": "0xc2", "from": other, "to": other, "value": "0x0", "input": "0x", "nonce": "0x4"},
+			},
+		},
+		// Queued transactions wait on a nonce gap and are left out.
+		"queued": map[string]any{
+			addr: map[string]any{"10": map[string]any{"hash": "0xb4", "from": addr, "to": other, "value": "0x0", "input": "0x", "nonce": "0xa"}},
+		},
+	}
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["method"] != MethodTxpoolContent {
+			t.Fatalf("unexpected method %v", req["method"])
+		}
+		return mkResp(pool), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	got, err := p.(PendingReader).PendingTransactions(context.Background(), strings.ToUpper(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PendingTransaction{
+		{Hash: "0xb1", From: addr, To: "", ValueWei: "0x0", InputHex: "0x6080", Nonce: 7},
+		{Hash: "0xb2", From: addr, To: other, ValueWei: "0x1", InputHex: "0xa9059cbb", Nonce: 8},
+		{Hash: "0xc1", From: other, To: addr, ValueWei: "0xde", InputHex: "0x", Nonce: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("pending = %+v, want %+v", got, want)
+	}
+}
+
+func TestHTTPProvider_PendingTransactionsUnsupported(t *testing.T) {
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return mkRespErr(-32601, "the method txpool_content does not exist/is not available"), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	if _, err := p.(PendingReader).PendingTransactions(context.Background(), "0x"+strings.Repeat("ab", 20)); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestHTTPProvider_TransactionBlock(t *testing.T) {
+	txs := map[string]any{
+		"0xmined":   map[string]any{"hash": "0xmined", "blockNumber": "0x2a"},
+		"0xpending": map[string]any{"hash": "0xpending", "blockNumber": nil},
+	}
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != MethodGetTransactionByHash {
+			t.Fatalf("unexpected method %s", req.Method)
+		}
+		return mkResp(txs[req.Params[0]]), nil
+	})}
+	p, _ := NewHTTPProvider("http://unit-test", client)
+	pr := p.(PendingReader)
+	if block, mined, err := pr.TransactionBlock(context.Background(), "0xmined"); err != nil || !mined || block != 42 {
+		t.Fatalf("mined: block=%d mined=%v err=%v", block, mined, err)
+	}
+	if _, mined, err := pr.TransactionBlock(context.Background(), "0xpending"); err != nil || mined {
+		t.Fatalf("pending: mined=%v err=%v", mined, err)
+	}
+	if _, _, err := pr.TransactionBlock(context.Background(), "0xgone"); !errors.Is(err, ErrTxNotFound) {
+		t.Fatalf("gone: err = %v, want ErrTxNotFound", err)
+	}
+}
+
+func TestRLProvider_PendingReaderForwarding(t *testing.T) {
+	rl := RLProvider{p: fakeProvider{}, l: NewLimiter(0)}
+	if _, err := rl.PendingTransactions(context.Background(), "0x"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("PendingTransactions err = %v", err)
+	}
+	if _, _, err := rl.TransactionBlock(context.Background(), "0x"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("TransactionBlock err = %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package eth
 
 import (
 	"context"
+	"errors"
+	"math/big"
 )
 
 // Provider defines the minimal RPC surface the ingester needs. Concrete adapters
@@ -23,10 +25,110 @@ type Provider interface {
 
 	// Transactions returns external transactions touching the address within
 	// the inclusive block range [from, to]. Providers may return ErrUnsupported
-	// when a filtered view is not available.
+	// when a filtered view is not available, or the transactions they could
+	// fetch together with a *PartialError naming the blocks they could not.
 	Transactions(ctx context.Context, address string, from, to uint64) ([]Transaction, error)
 }
 
+// BlockRange is an inclusive range of block numbers.
+type BlockRange struct {
+	From uint64
+	To   uint64
+}
+
+// PartialError accompanies results that leave out some blocks of the
+// requested range. Missing is sorted and never empty; Err joins the
+// underlying failures.
+type PartialError struct {
+	Missing []BlockRange
+	Err     error
+}
+
+func (e *PartialError) Error() string { return e.Err.Error() }
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// MissingBlocks returns the blocks err reports as left out of a partial
+// result, or nil when err is not a *PartialError.
+func MissingBlocks(err error) []BlockRange {
+	var pe *PartialError
+	if errors.As(err, &pe) {
+		return pe.Missing
+	}
+	return nil
+}
+
+// SafeHeadAware is optionally implemented by providers (and wrappers) that can
+// exploit the caller's confirmation-adjusted head, e.g. to cache finalized
+// block data indefinitely while keeping head-adjacent data short-lived.
+type SafeHeadAware interface {
+	SetSafeHead(block uint64)
+}
+
+// StorageReader is optionally implemented by providers that expose
+// eth_getStorageAt. StorageAt returns the 32-byte word stored at slot.
+type StorageReader interface {
+	StorageAt(ctx context.Context, address, slot string, block uint64) ([]byte, error)
+}
+
+// BalanceReader is optionally implemented by providers that expose
+// eth_getBalance. BalanceAt returns the address balance in wei at block.
+type BalanceReader interface {
+	BalanceAt(ctx context.Context, address string, block uint64) (*big.Int, error)
+}
+
+// ContractCaller is optionally implemented by providers that expose
+// eth_call. CallContract runs calldata against contract to at block, sent
+// from from (empty for the zero address), and returns the return data. A
+// call the EVM reverted fails with an error wrapping ErrExecutionReverted.
+type ContractCaller interface {
+	CallContract(ctx context.Context, from, to, data string, block uint64) ([]byte, error)
+}
+
+// UncleReader is optionally implemented by providers that expose
+// eth_getUncleCountByBlockNumber, for block-level fee and reward accounting.
+type UncleReader interface {
+	UncleCount(ctx context.Context, block uint64) (uint64, error)
+}
+
+// WithdrawalReader is optionally implemented by providers that read the
+// EIP-4895 withdrawals of post-Shapella blocks. Withdrawals returns those
+// paid to address in the inclusive block range [from, to]; earlier blocks
+// have none.
+type WithdrawalReader interface {
+	Withdrawals(ctx context.Context, address string, from, to uint64) ([]Withdrawal, error)
+}
+
+// FinalityReader is optionally implemented by providers whose node resolves
+// the "safe" and "finalized" block tags (post-merge chains).
+type FinalityReader interface {
+	// BlockNumberByTag returns the number of the block the tag points at.
+	BlockNumberByTag(ctx context.Context, tag string) (uint64, error)
+}
+
+// ProviderLabeler is optionally implemented by providers that can name the
+// endpoint serving their calls, e.g. the RPC host without credentials.
+type ProviderLabeler interface {
+	ProviderLabel() string
+}
+
+// Withdrawal is a consensus-layer withdrawal credited to an address in a
+// block's withdrawals list: validator payouts, which are neither
+// transactions nor traces.
+type Withdrawal struct {
+	Index      uint64 // global withdrawal index
+	Validator  uint64 // validator index
+	Address    string // recipient, lowercase
+	AmountGwei string // as returned (hex); withdrawals are denominated in gwei
+	BlockNum   uint64
+	TsMillis   int64
+}
+
+// Block tags accepted by FinalityReader.BlockNumberByTag.
+const (
+	TagSafe      = "safe"
+	TagFinalized = "finalized"
+)
+
 // Log is a minimal scaffold of an Ethereum log. Extend as needed.
 type Log struct {
 	TxHash   string
@@ -48,6 +150,7 @@ type Trace struct {
 	BlockNum        uint64
 	TsMillis        int64
 	Type            string
+	CallType        string // call | delegatecall | staticcall | callcode (call traces only)
 	CreatedContract string
 }
 
@@ -60,9 +163,20 @@ type Transaction struct {
 	ValueWei        string
 	InputHex        string
 	GasUsed         uint64
+	GasPriceWei     string // paid per gas, as returned (hex): legacy gasPrice, else the receipt's effectiveGasPrice
 	Status          uint8
 	BlockNum        uint64
 	TsMillis        int64
 	TraceID         string
 	ContractAddress string
+	// AccessListCount and AccessListKeys size the EIP-2930 access list of
+	// typed (0x1, 0x2, ...) transactions: its address entries and the storage
+	// keys across them. Legacy transactions leave both 0.
+	AccessListCount uint32
+	AccessListKeys  uint32
+	// ReceiptMissing marks a transaction returned without its receipt
+	// (WithReceiptlessTransactions): GasUsed, Status and ContractAddress
+	// are unknown and left zero, as is GasPriceWei unless the transaction
+	// carried a gasPrice.
+	ReceiptMissing bool
 }
@@ -1,8 +1,49 @@
 package eth
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
 
-// RLProvider wraps a Provider with a Limiter.
+// JSON-RPC methods that can get their own rate limit (WithMethodRateLimits),
+// which the HTTP provider enforces on every request it sends. RLProvider's
+// global limiter counts each Provider call as one of them, skipping those
+// with their own limit; a Transactions range is dominated by its receipt
+// lookups, so it counts as eth_getBlockReceipts.
+const (
+	MethodBlockNumber          = "eth_blockNumber"
+	MethodGetBlockByNumber     = "eth_getBlockByNumber"
+	MethodGetLogs              = "eth_getLogs"
+	MethodTraceFilter          = "trace_filter"
+	MethodGetBlockReceipts     = "eth_getBlockReceipts"
+	MethodGetStorageAt         = "eth_getStorageAt"
+	MethodGetBalance           = "eth_getBalance"
+	MethodCall                 = "eth_call"
+	MethodGetUncleCount        = "eth_getUncleCountByBlockNumber"
+	MethodTxpoolContent        = "txpool_content"
+	MethodGetTransactionByHash = "eth_getTransactionByHash"
+)
+
+var limitedMethods = map[string]bool{
+	MethodBlockNumber:          true,
+	MethodGetBlockByNumber:     true,
+	MethodGetLogs:              true,
+	MethodTraceFilter:          true,
+	MethodGetBlockReceipts:     true,
+	MethodGetStorageAt:         true,
+	MethodGetBalance:           true,
+	MethodCall:                 true,
+	MethodGetUncleCount:        true,
+	MethodTxpoolContent:        true,
+	MethodGetTransactionByHash: true,
+}
+
+// RLProvider wraps a Provider with a Limiter. Calls whose JSON-RPC method the
+// wrapped provider limits per request (see methodLimited) skip it.
 type RLProvider struct {
 	p Provider
 	l Limiter
@@ -10,37 +51,206 @@ type RLProvider struct {
 
 func WrapWithLimiter(p Provider, l Limiter) Provider { return RLProvider{p: p, l: l} }
 
+// methodLimited is implemented by providers that rate limit some JSON-RPC
+// methods themselves, request by request.
+type methodLimited interface {
+	limitsMethod(method string) bool
+}
+
+// ParseMethodRates parses a comma-separated list of method=req/s pairs, such
+// as "trace_filter=2,eth_getLogs=10", for WithMethodRateLimits. Methods must
+// be among the Method constants above; a rate of 0 leaves the method
+// unlimited.
+func ParseMethodRates(spec string) (map[string]int, error) {
+	rates := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		method, val, ok := strings.Cut(part, "=")
+		method = strings.TrimSpace(method)
+		if !ok || method == "" {
+			return nil, fmt.Errorf("method rate %q: want method=req/s", part)
+		}
+		if !limitedMethods[method] {
+			known := make([]string, 0, len(limitedMethods))
+			for m := range limitedMethods {
+				known = append(known, m)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("method rate %q: unknown method (want one of %s)", part, strings.Join(known, ", "))
+		}
+		rate, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("method rate %q: rate must be a non-negative integer", part)
+		}
+		rates[method] = rate
+	}
+	return rates, nil
+}
+
+// wait blocks on the global limiter unless the wrapped provider limits method
+// on its own.
+func (r RLProvider) wait(ctx context.Context, method string) error {
+	if ml, ok := r.p.(methodLimited); ok && ml.limitsMethod(method) {
+		return ctx.Err()
+	}
+	return r.l.Wait(ctx)
+}
+
 func (r RLProvider) BlockNumber(ctx context.Context) (uint64, error) {
-	if err := r.l.Wait(ctx); err != nil {
+	if err := r.wait(ctx, MethodBlockNumber); err != nil {
 		return 0, err
 	}
 	return r.p.BlockNumber(ctx)
 }
 
 func (r RLProvider) BlockTimestamp(ctx context.Context, block uint64) (int64, error) {
-	if err := r.l.Wait(ctx); err != nil {
+	if err := r.wait(ctx, MethodGetBlockByNumber); err != nil {
 		return 0, err
 	}
 	return r.p.BlockTimestamp(ctx, block)
 }
 
 func (r RLProvider) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]Log, error) {
-	if err := r.l.Wait(ctx); err != nil {
+	if err := r.wait(ctx, MethodGetLogs); err != nil {
 		return nil, err
 	}
 	return r.p.GetLogs(ctx, address, from, to, topics)
 }
 
 func (r RLProvider) TraceBlock(ctx context.Context, from, to uint64, address string) ([]Trace, error) {
-	if err := r.l.Wait(ctx); err != nil {
+	if err := r.wait(ctx, MethodTraceFilter); err != nil {
 		return nil, err
 	}
 	return r.p.TraceBlock(ctx, from, to, address)
 }
 
 func (r RLProvider) Transactions(ctx context.Context, address string, from, to uint64) ([]Transaction, error) {
-	if err := r.l.Wait(ctx); err != nil {
+	if err := r.wait(ctx, MethodGetBlockReceipts); err != nil {
 		return nil, err
 	}
 	return r.p.Transactions(ctx, address, from, to)
 }
+
+// SetSafeHead forwards the safe head to the wrapped provider when supported.
+func (r RLProvider) SetSafeHead(block uint64) {
+	if sa, ok := r.p.(SafeHeadAware); ok {
+		sa.SetSafeHead(block)
+	}
+}
+
+// StorageAt forwards to the wrapped provider, or returns ErrUnsupported when
+// it cannot read storage.
+func (r RLProvider) StorageAt(ctx context.Context, address, slot string, block uint64) ([]byte, error) {
+	sr, ok := r.p.(StorageReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodGetStorageAt); err != nil {
+		return nil, err
+	}
+	return sr.StorageAt(ctx, address, slot, block)
+}
+
+// BalanceAt forwards to the wrapped provider, or returns ErrUnsupported when
+// it cannot read balances.
+func (r RLProvider) BalanceAt(ctx context.Context, address string, block uint64) (*big.Int, error) {
+	br, ok := r.p.(BalanceReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodGetBalance); err != nil {
+		return nil, err
+	}
+	return br.BalanceAt(ctx, address, block)
+}
+
+// CallContract forwards to the wrapped provider, or returns ErrUnsupported
+// when it cannot run calls.
+func (r RLProvider) CallContract(ctx context.Context, from, to, data string, block uint64) ([]byte, error) {
+	cc, ok := r.p.(ContractCaller)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodCall); err != nil {
+		return nil, err
+	}
+	return cc.CallContract(ctx, from, to, data, block)
+}
+
+// UncleCount forwards to the wrapped provider, or returns ErrUnsupported when
+// it cannot count uncles.
+func (r RLProvider) UncleCount(ctx context.Context, block uint64) (uint64, error) {
+	ur, ok := r.p.(UncleReader)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodGetUncleCount); err != nil {
+		return 0, err
+	}
+	return ur.UncleCount(ctx, block)
+}
+
+// Withdrawals forwards to the wrapped provider, or returns ErrUnsupported
+// when it cannot read block withdrawals. A range counts as one
+// eth_getBlockByNumber call.
+func (r RLProvider) Withdrawals(ctx context.Context, address string, from, to uint64) ([]Withdrawal, error) {
+	wr, ok := r.p.(WithdrawalReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodGetBlockByNumber); err != nil {
+		return nil, err
+	}
+	return wr.Withdrawals(ctx, address, from, to)
+}
+
+// BlockNumberByTag forwards to the wrapped provider, or returns ErrUnsupported
+// when it cannot resolve block tags.
+func (r RLProvider) BlockNumberByTag(ctx context.Context, tag string) (uint64, error) {
+	fr, ok := r.p.(FinalityReader)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodGetBlockByNumber); err != nil {
+		return 0, err
+	}
+	return fr.BlockNumberByTag(ctx, tag)
+}
+
+// ProviderLabel forwards to the wrapped provider, or returns "" when it
+// cannot name its endpoint.
+func (r RLProvider) ProviderLabel() string {
+	if pl, ok := r.p.(ProviderLabeler); ok {
+		return pl.ProviderLabel()
+	}
+	return ""
+}
+
+// PendingTransactions forwards to the wrapped provider, or returns
+// ErrUnsupported when it cannot read the mempool.
+func (r RLProvider) PendingTransactions(ctx context.Context, address string) ([]PendingTransaction, error) {
+	pr, ok := r.p.(PendingReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodTxpoolContent); err != nil {
+		return nil, err
+	}
+	return pr.PendingTransactions(ctx, address)
+}
+
+// TransactionBlock forwards to the wrapped provider, or returns
+// ErrUnsupported when it cannot read the mempool.
+func (r RLProvider) TransactionBlock(ctx context.Context, hash string) (uint64, bool, error) {
+	pr, ok := r.p.(PendingReader)
+	if !ok {
+		return 0, false, ErrUnsupported
+	}
+	if err := r.wait(ctx, MethodGetTransactionByHash); err != nil {
+		return 0, false, err
+	}
+	return pr.TransactionBlock(ctx, hash)
+}
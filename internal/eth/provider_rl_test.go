@@ -3,7 +3,10 @@ package eth
 import (
 	"context"
 	"errors"
+	"net/http"
+	"reflect"
 	"testing"
+	"time"
 )
 
 type fakeProvider struct{}
@@ -68,3 +71,98 @@ func TestRLProvider_PropagatesLimiterError(t *testing.T) {
 		t.Fatal("expected error")
 	}
 }
+
+type countLimiter struct{ n int }
+
+func (c *countLimiter) Wait(ctx context.Context) error {
+	c.n++
+	return nil
+}
+
+// blockedLimiter never grants a token, like an exhausted per-method budget.
+type blockedLimiter struct{}
+
+func (blockedLimiter) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// methodLimitedProvider is an httpProvider over client whose listed methods
+// have their own limiters.
+func methodLimitedProvider(t *testing.T, client *http.Client, limiters map[string]Limiter) *httpProvider {
+	t.Helper()
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.methodLimiters = limiters
+	return hp
+}
+
+func TestRLProvider_MethodLimitersReplaceGlobal(t *testing.T) {
+	global, trace := &countLimiter{}, &countLimiter{}
+	pages := 0
+	p := WrapWithLimiter(methodLimitedProvider(t, pagedTraces(2500, &pages), map[string]Limiter{MethodTraceFilter: trace}), global)
+	ctx := context.Background()
+	_, _ = p.BlockNumber(ctx)
+	_, _ = p.GetLogs(ctx, "0x", 1, 2, nil)
+	if _, err := p.TraceBlock(ctx, 1, 2, "0x"); err != nil {
+		t.Fatal(err)
+	}
+	// Every trace_filter page takes a token; the TraceBlock call takes none
+	// from the global limiter.
+	if pages != 3 || trace.n != 3 || global.n != 2 {
+		t.Fatalf("pages=%d trace waits=%d global waits=%d, want 3, 3 and 2", pages, trace.n, global.n)
+	}
+}
+
+func TestRLProvider_ExpensiveMethodThrottledIndependently(t *testing.T) {
+	pages := 0
+	p := WrapWithLimiter(methodLimitedProvider(t, pagedTraces(1, &pages), map[string]Limiter{MethodTraceFilter: blockedLimiter{}}), NewLimiter(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.TraceBlock(ctx, 1, 2, "0x"); !errors.Is(err, context.DeadlineExceeded) || pages != 0 {
+		t.Fatalf("trace err = %v after %d pages, want deadline exceeded before any", err, pages)
+	}
+	// Cheap calls keep flowing while trace_filter waits on its own budget.
+	for k := 0; k < 100; k++ {
+		if _, err := p.BlockTimestamp(context.Background(), uint64(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseMethodRates(t *testing.T) {
+	got, err := ParseMethodRates(" trace_filter=2, eth_getLogs = 10,,eth_blockNumber=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{MethodTraceFilter: 2, MethodGetLogs: 10, MethodBlockNumber: 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rates = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"trace_filter", "=2", "trace_filter=x", "trace_filter=-1", "eth_chainId=5"} {
+		if _, err := ParseMethodRates(bad); err == nil {
+			t.Fatalf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestNewProvider_MethodRateLimits(t *testing.T) {
+	p, err := NewProvider("http://localhost:8545", 5, 0, 0, WithMethodRateLimits(map[string]int{MethodTraceFilter: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := p.(RLProvider)
+	hp := rp.p.(*httpProvider)
+	if _, ok := hp.methodLimiters[MethodTraceFilter].(qpsLimiter); !ok || len(hp.methodLimiters) != 1 {
+		t.Fatalf("method limiters = %v", hp.methodLimiters)
+	}
+	if !hp.limitsMethod(MethodTraceFilter) || hp.limitsMethod(MethodGetLogs) {
+		t.Fatal("limitsMethod disagrees with the configured limits")
+	}
+	if _, ok := rp.l.(qpsLimiter); !ok {
+		t.Fatalf("global limiter = %T", rp.l)
+	}
+}
@@ -0,0 +1,269 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func mkBatchResp(v any) *http.Response {
+	b, _ := json.Marshal(v)
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(b)), Header: http.Header{"Content-Type": []string{"application/json"}}}
+}
+
+// batchReceiptServer answers eth_getBlockReceipts with method-not-found and
+// eth_getTransactionReceipt either singly or as a batch (responses reversed
+// to exercise ID demux). It records the size of every batch it serves and
+// the hashes fetched singly. With partialBlock set, eth_getBlockReceipts
+// instead streams those receipts and then fails mid-body.
+type batchReceiptServer struct {
+	mu           sync.Mutex
+	batches      []int
+	singles      int
+	singleHashes []string
+	rejectAll    bool
+	failBatches  int // batches answered with a 503 before serving normally
+	drop         map[string]bool
+	fail         map[string]bool
+	partialBlock []string
+}
+
+// cutReader returns its data, then err instead of io.EOF, like a response
+// body whose connection drops.
+type cutReader struct {
+	data []byte
+	err  error
+}
+
+func (r *cutReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *batchReceiptServer) receipt(hash string) map[string]any {
+	gas := "0x" + strings.TrimPrefix(strings.ToLower(hash), "0x")
+	return map[string]any{"status": "0x1", "gasUsed": gas, "effectiveGasPrice": "0x3b9aca00", "contractAddress": nil}
+}
+
+func (s *batchReceiptServer) client(t *testing.T) *http.Client {
+	t.Helper()
+	return &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			var reqs []rpcRequest
+			if err := json.Unmarshal(body, &reqs); err != nil {
+				t.Fatalf("decode batch: %v", err)
+			}
+			s.mu.Lock()
+			s.batches = append(s.batches, len(reqs))
+			s.mu.Unlock()
+			if s.rejectAll {
+				return mkRespErr(-32600, "batch requests not supported"), nil
+			}
+			if s.failBatches > 0 {
+				s.failBatches--
+				return &http.Response{StatusCode: 503, Body: io.NopCloser(strings.NewReader("unavailable"))}, nil
+			}
+			out := make([]map[string]any, 0, len(reqs))
+			for idx := len(reqs) - 1; idx >= 0; idx-- {
+				req := reqs[idx]
+				if req.Method != "eth_getTransactionReceipt" {
+					t.Fatalf("unexpected batch method %s", req.Method)
+				}
+				hash := req.Params.([]any)[0].(string)
+				switch {
+				case s.drop[hash]:
+					continue
+				case s.fail[hash]:
+					out = append(out, map[string]any{"jsonrpc": "2.0", "id": req.ID, "error": map[string]any{"code": -32000, "message": "boom"}})
+				default:
+					out = append(out, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": s.receipt(hash)})
+				}
+			}
+			return mkBatchResp(out), nil
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		switch req.Method {
+		case "eth_getBlockReceipts":
+			if s.partialBlock == nil {
+				return mkRespErr(-32601, "method not found"), nil
+			}
+			var recs []string
+			for _, h := range s.partialBlock {
+				rec := s.receipt(h)
+				rec["transactionHash"] = h
+				b, _ := json.Marshal(rec)
+				recs = append(recs, string(b))
+			}
+			body := `{"jsonrpc":"2.0","id":1,"result":[` + strings.Join(recs, ",") + `,{"transactionHash":"0x`
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(&cutReader{data: []byte(body), err: io.ErrUnexpectedEOF})}, nil
+		case "eth_getTransactionReceipt":
+			s.mu.Lock()
+			s.singles++
+			s.singleHashes = append(s.singleHashes, req.Params.([]any)[0].(string))
+			s.mu.Unlock()
+			return mkResp(s.receipt(req.Params.([]any)[0].(string))), nil
+		}
+		t.Fatalf("unexpected method %s", req.Method)
+		return nil, nil
+	})}
+}
+
+func newBatchTestProvider(t *testing.T, srv *batchReceiptServer, size int) *httpProvider {
+	t.Helper()
+	p, err := NewHTTPProvider("http://unit-test", srv.client(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.receiptBatchSize = size
+	hp.setBlockReceiptsState(receiptSupportUnavailable)
+	return hp
+}
+
+func TestFetchReceipts_BatchesPerChunkAndDemuxes(t *testing.T) {
+	srv := &batchReceiptServer{}
+	hp := newBatchTestProvider(t, srv, 2)
+	hashes := []string{"0xA1", "0xb2", "0xc3", "0xd4", "0xe5"}
+	out, calls, failures, err := hp.fetchReceiptsForBlock(context.Background(), 7, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || failures != 0 {
+		t.Fatalf("calls/failures = %d/%d, want 3/0", calls, failures)
+	}
+	if got := srv.batches; len(got) != 3 || got[0] != 2 || got[1] != 2 || got[2] != 1 {
+		t.Fatalf("batch sizes = %v, want [2 2 1]", got)
+	}
+	if srv.singles != 0 {
+		t.Fatalf("unexpected single receipt calls: %d", srv.singles)
+	}
+	want := map[string]uint64{"0xa1": 0xa1, "0xb2": 0xb2, "0xc3": 0xc3, "0xd4": 0xd4, "0xe5": 0xe5}
+	for h, gas := range want {
+		if rec, ok := out[h]; !ok || rec.gasUsed != gas || rec.status != 1 || rec.gasPrice != "0x3b9aca00" {
+			t.Fatalf("receipt %s = %+v (ok=%v), want gasUsed %d", h, rec, ok, gas)
+		}
+	}
+	if hp.receiptBatchState() != receiptSupportAvailable {
+		t.Fatalf("batch support not recorded")
+	}
+}
+
+func TestFetchReceipts_BatchMissingAndErrors(t *testing.T) {
+	srv := &batchReceiptServer{drop: map[string]bool{"0xb2": true}, fail: map[string]bool{"0xc3": true}}
+	hp := newBatchTestProvider(t, srv, 10)
+	out, calls, failures, err := hp.fetchReceiptsForBlock(context.Background(), 7, []string{"0xa1", "0xb2", "0xc3"})
+	if err == nil || !strings.Contains(err.Error(), "receipt 0xc3: rpc -32000: boom") {
+		t.Fatalf("expected per-receipt error, got %v", err)
+	}
+	// One batch plus a single retry for the hash the batch dropped.
+	if calls != 2 || failures != 1 || srv.singles != 1 {
+		t.Fatalf("calls/failures/singles = %d/%d/%d, want 2/1/1", calls, failures, srv.singles)
+	}
+	if len(out) != 2 || out["0xb2"].gasUsed != 0xb2 {
+		t.Fatalf("unexpected receipts: %+v", out)
+	}
+}
+
+func TestFetchReceipts_BatchRejectedFallsBackToWorkers(t *testing.T) {
+	srv := &batchReceiptServer{rejectAll: true}
+	hp := newBatchTestProvider(t, srv, 2)
+	hashes := []string{"0xa1", "0xb2", "0xc3"}
+	out, calls, failures, err := hp.fetchReceiptsForBlock(context.Background(), 7, hashes)
+	if err != nil || failures != 0 || len(out) != 3 {
+		t.Fatalf("fallback mismatch: out=%v failures=%d err=%v", out, failures, err)
+	}
+	if calls != 4 || len(srv.batches) != 1 || srv.singles != 3 {
+		t.Fatalf("calls/batches/singles = %d/%d/%d, want 4/1/3", calls, len(srv.batches), srv.singles)
+	}
+	if hp.receiptBatchState() != receiptSupportUnavailable {
+		t.Fatalf("batch rejection not recorded")
+	}
+	// Later blocks skip batching entirely.
+	if _, calls, _, err := hp.fetchReceiptsForBlock(context.Background(), 8, hashes); err != nil || calls != 3 || len(srv.batches) != 1 {
+		t.Fatalf("expected worker pool only: calls=%d batches=%d err=%v", calls, len(srv.batches), err)
+	}
+}
+
+func TestFetchReceipts_TransientBatchFailureKeepsBatching(t *testing.T) {
+	srv := &batchReceiptServer{failBatches: 1}
+	hp := newBatchTestProvider(t, srv, 10)
+	hp.maxRetries = 0
+	hashes := []string{"0xa1", "0xb2", "0xc3"}
+	out, _, failures, err := hp.fetchReceiptsForBlock(context.Background(), 7, hashes)
+	if err != nil || failures != 0 || len(out) != 3 || srv.singles != 3 {
+		t.Fatalf("fallback mismatch: out=%v singles=%d failures=%d err=%v", out, srv.singles, failures, err)
+	}
+	if hp.receiptBatchState() == receiptSupportUnavailable {
+		t.Fatal("a 503 disabled batching")
+	}
+	// The next block batches again.
+	if _, calls, _, err := hp.fetchReceiptsForBlock(context.Background(), 8, hashes); err != nil || calls != 1 || len(srv.batches) != 2 || srv.singles != 3 {
+		t.Fatalf("calls=%d batches=%d singles=%d err=%v", calls, len(srv.batches), srv.singles, err)
+	}
+	if hp.receiptBatchState() != receiptSupportAvailable {
+		t.Fatalf("batch support not recorded after success")
+	}
+}
+
+func TestIsBatchUnsupported(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&rpcError{Code: -32601, Message: "the method eth_x does not exist"}, true},
+		{&rpcError{Code: -32600, Message: "Batch requests are not supported"}, true},
+		{&rpcError{Code: -32000, Message: "batch size too large"}, false},
+		{&rpcError{Code: -32603, Message: "internal error"}, false},
+		{errors.New("http 503: unavailable"), false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tc := range cases {
+		if got := isBatchUnsupported(tc.err); got != tc.want {
+			t.Errorf("isBatchUnsupported(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestFetchReceipts_BatchDisabled(t *testing.T) {
+	srv := &batchReceiptServer{}
+	hp := newBatchTestProvider(t, srv, 1)
+	if _, calls, _, err := hp.fetchReceiptsForBlock(context.Background(), 7, []string{"0xa1", "0xb2"}); err != nil || calls != 2 {
+		t.Fatalf("calls=%d err=%v", calls, err)
+	}
+	if len(srv.batches) != 0 || srv.singles != 2 {
+		t.Fatalf("batches/singles = %d/%d, want 0/2", len(srv.batches), srv.singles)
+	}
+}
+
+func TestFetchReceipts_PartialBlockReceiptsRefetchesMissingOnly(t *testing.T) {
+	srv := &batchReceiptServer{partialBlock: []string{"0xa1", "0xb2"}}
+	hp := newBatchTestProvider(t, srv, 1)
+	hp.setBlockReceiptsState(receiptSupportAvailable)
+	out, calls, failures, err := hp.fetchReceiptsForBlock(context.Background(), 7, []string{"0xa1", "0xb2", "0xc3", "0xd4"})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("want the cut-off block receipts error, got %v", err)
+	}
+	if len(out) != 4 || failures != 0 || out["0xa1"].gasUsed != 0xa1 || out["0xd4"].gasUsed != 0xd4 {
+		t.Fatalf("out=%v failures=%d", out, failures)
+	}
+	sort.Strings(srv.singleHashes)
+	if calls != 3 || !slices.Equal(srv.singleHashes, []string{"0xc3", "0xd4"}) {
+		t.Fatalf("calls=%d refetched=%v, want 3 and only the missing hashes", calls, srv.singleHashes)
+	}
+}
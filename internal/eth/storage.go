@@ -0,0 +1,55 @@
+package eth
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// EIP1967ImplementationSlot is the EIP-1967 proxy implementation slot,
+// bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1).
+const EIP1967ImplementationSlot = "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc"
+
+// StorageAt reads one storage word via eth_getStorageAt.
+func (p *httpProvider) StorageAt(ctx context.Context, address, slot string, block uint64) ([]byte, error) {
+	var res string
+	if err := p.call(ctx, "eth_getStorageAt", []interface{}{address, slot, toHex(block)}, &res); err != nil {
+		return nil, err
+	}
+	return decodeStorageWord(res)
+}
+
+// decodeStorageWord left-pads a hex storage value to 32 bytes. Some nodes
+// return compact quantities (e.g. "0x0") instead of full words.
+func decodeStorageWord(s string) ([]byte, error) {
+	h := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+	if len(h) > 64 {
+		return nil, fmt.Errorf("storage word too long: %q", s)
+	}
+	word, err := hex.DecodeString(strings.Repeat("0", 64-len(h)) + h)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage word %q: %w", s, err)
+	}
+	return word, nil
+}
+
+// ImplementationAt reads the EIP-1967 implementation slot of proxy at block
+// and returns the implementation address (lowercase 0x-hex). Contracts that
+// are not EIP-1967 proxies have an empty slot and yield "".
+func ImplementationAt(ctx context.Context, r StorageReader, proxy string, block uint64) (string, error) {
+	word, err := r.StorageAt(ctx, proxy, EIP1967ImplementationSlot, block)
+	if err != nil {
+		return "", err
+	}
+	if len(word) != 32 {
+		return "", fmt.Errorf("storage word has %d bytes, want 32", len(word))
+	}
+	addr := word[12:]
+	for _, b := range addr {
+		if b != 0 {
+			return "0x" + hex.EncodeToString(addr), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func storageProvider(t *testing.T, result any) (*httpProvider, *[]any) {
+	t.Helper()
+	var params []any
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_getStorageAt" {
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+		params = req.Params
+		if s, ok := result.(string); ok && strings.HasPrefix(s, "rpcerr:") {
+			return mkRespErr(-32000, strings.TrimPrefix(s, "rpcerr:")), nil
+		}
+		return mkResp(result), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	return hp, &params
+}
+
+func TestImplementationAt_DecodesSlot(t *testing.T) {
+	impl := "0x" + strings.Repeat("ab", 20)
+	hp, params := storageProvider(t, "0x"+strings.Repeat("0", 24)+strings.Repeat("AB", 20))
+	got, err := ImplementationAt(context.Background(), hp, "0xproxy", 16)
+	if err != nil || got != impl {
+		t.Fatalf("impl=%q err=%v", got, err)
+	}
+	p := *params
+	if len(p) != 3 || p[0] != "0xproxy" || p[1] != EIP1967ImplementationSlot || p[2] != "0x10" {
+		t.Fatalf("unexpected params: %v", p)
+	}
+}
+
+func TestImplementationAt_NonProxyReturnsEmpty(t *testing.T) {
+	for _, res := range []string{"0x" + strings.Repeat("0", 64), "0x0", "0x"} {
+		hp, _ := storageProvider(t, res)
+		got, err := ImplementationAt(context.Background(), hp, "0xc0ffee", 1)
+		if err != nil || got != "" {
+			t.Fatalf("%q: impl=%q err=%v", res, got, err)
+		}
+	}
+}
+
+func TestStorageAt_Errors(t *testing.T) {
+	hp, _ := storageProvider(t, "rpcerr:boom")
+	if _, err := ImplementationAt(context.Background(), hp, "0x1", 1); err == nil {
+		t.Fatal("expected rpc error")
+	}
+	hp, _ = storageProvider(t, "0x"+strings.Repeat("0", 66))
+	if _, err := hp.StorageAt(context.Background(), "0x1", "0x0", 1); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Fatalf("expected length error, got %v", err)
+	}
+	hp, _ = storageProvider(t, "0xzz")
+	if _, err := hp.StorageAt(context.Background(), "0x1", "0x0", 1); err == nil {
+		t.Fatal("expected hex error")
+	}
+	if _, err := ImplementationAt(context.Background(), shortWordReader{}, "0x1", 1); err == nil {
+		t.Fatal("expected word size error")
+	}
+}
+
+type shortWordReader struct{}
+
+func (shortWordReader) StorageAt(ctx context.Context, address, slot string, block uint64) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func TestRLProvider_StorageAt(t *testing.T) {
+	hp, _ := storageProvider(t, "0x1")
+	sr := WrapWithLimiter(hp, NewLimiter(0)).(StorageReader)
+	if word, err := sr.StorageAt(context.Background(), "0x1", "0x0", 1); err != nil || word[31] != 1 {
+		t.Fatalf("word=%x err=%v", word, err)
+	}
+	if _, err := (RLProvider{p: fakeProvider{}, l: NewLimiter(0)}).StorageAt(context.Background(), "0x1", "0x0", 1); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := (RLProvider{p: hp, l: errLimiter{}}).StorageAt(context.Background(), "0x1", "0x0", 1); err == nil {
+		t.Fatal("expected limiter error")
+	}
+}
@@ -0,0 +1,101 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// callArray is call for methods whose result is a JSON array (eth_getLogs,
+// trace_filter). Instead of buffering the whole body as a json.RawMessage it
+// walks the response with json.Decoder.Token and hands each array element to
+// elem, which decodes it with dec.Decode, so memory is bounded by the
+// caller's converted results rather than the raw response. A null result
+// yields no elements.
+//
+// post retries only transport errors and non-2xx statuses, so elem only ever
+// sees elements of the one 2xx response being decoded.
+func (p *httpProvider) callArray(ctx context.Context, method string, params interface{}, elem func(dec *json.Decoder) error) error {
+	if err := p.budget.take(1); err != nil {
+		return err
+	}
+	if err := p.waitMethod(ctx, method, 1); err != nil {
+		return err
+	}
+	if p.latency != nil {
+		defer p.latency.start(method)()
+	}
+	reqBody, _ := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: params, ID: 1})
+	return p.post(ctx, reqBody, func(body io.Reader) error {
+		return decodeArrayResponse(json.NewDecoder(body), elem)
+	})
+}
+
+// decodeArrayResponse decodes a JSON-RPC response object from dec, streaming
+// its result array through elem. JSON-RPC errors are reported like call does.
+func decodeArrayResponse(dec *json.Decoder, elem func(dec *json.Decoder) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	var rpcErr *rpcError
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case "result":
+			if err := decodeArray(dec, elem); err != nil {
+				return err
+			}
+		case "error":
+			if err := dec.Decode(&rpcErr); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if rpcErr != nil {
+		return rpcErr
+	}
+	return nil
+}
+
+// decodeArray streams the elements of a JSON array (or null) through elem.
+func decodeArray(dec *json.Decoder, elem func(dec *json.Decoder) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("result is not an array: %v", tok)
+	}
+	for dec.More() {
+		if err := elem(dec); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("unexpected token %v, want %v", tok, want)
+	}
+	return nil
+}
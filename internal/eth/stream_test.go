@@ -0,0 +1,115 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// syntheticLogsBody renders an eth_getLogs response holding n logs.
+func syntheticLogsBody(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"jsonrpc":"2.0","id":1,"result":[`)
+	for k := 0; k < n; k++ {
+		if k > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"transactionHash":"0x%064x","logIndex":"0x%x","address":"0x%040x","topics":["0x%064x","0x%064x"],"data":"0x%0128x","blockNumber":"0x%x","removed":false}`,
+			k, k%7, k%13, k, k+1, k, 100+k/10)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}
+
+// decodeLogsBuffered is the buffered path call takes: the whole result as a
+// json.RawMessage, unmarshalled in one go.
+func decodeLogsBuffered(body []byte) ([]rpcLog, error) {
+	var rr rpcResponse
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&rr); err != nil {
+		return nil, err
+	}
+	if rr.Error != nil {
+		return nil, fmt.Errorf("rpc %d: %s", rr.Error.Code, rr.Error.Message)
+	}
+	var out []rpcLog
+	err := json.Unmarshal(rr.Result, &out)
+	return out, err
+}
+
+func decodeLogsStreaming(body []byte) ([]rpcLog, error) {
+	var out []rpcLog
+	err := decodeArrayResponse(json.NewDecoder(bytes.NewReader(body)), func(dec *json.Decoder) error {
+		var l rpcLog
+		if err := dec.Decode(&l); err != nil {
+			return err
+		}
+		out = append(out, l)
+		return nil
+	})
+	return out, err
+}
+
+func TestDecodeArrayResponse_MatchesBufferedDecode(t *testing.T) {
+	bodies := [][]byte{
+		syntheticLogsBody(500),
+		syntheticLogsBody(0),
+		[]byte(`{"result":[{"transactionHash":"0xa","logIndex":"0x1"}],"id":7,"jsonrpc":"2.0","extra":{"nested":[1,2]}}`),
+		[]byte(`{"jsonrpc":"2.0","id":1,"result":null}`),
+	}
+	for _, body := range bodies {
+		want, err := decodeLogsBuffered(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodeLogsStreaming(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want) != len(got) || (len(want) > 0 && !reflect.DeepEqual(want, got)) {
+			t.Fatalf("streaming decode differs: %d vs %d logs", len(got), len(want))
+		}
+	}
+}
+
+func TestDecodeArrayResponse_Errors(t *testing.T) {
+	cases := map[string]string{
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"too many results"}}`: "rpc -32005: too many results",
+		`{"jsonrpc":"2.0","id":1,"result":{"a":1}}`:                                     "not an array",
+		`{"jsonrpc":"2.0","id":1,"result":[{"logIndex":"0x1"},`:                         "unexpected end",
+		`[]`: "want {",
+	}
+	for body, want := range cases {
+		if _, err := decodeLogsStreaming([]byte(body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: err = %v, want %q", body, err, want)
+		}
+	}
+}
+
+// allocatedBytes reports the bytes f allocates on the heap.
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestDecodeArrayResponse_AllocatesLessThanBuffered(t *testing.T) {
+	body := syntheticLogsBody(20000)
+	var buffered, streamed []rpcLog
+	bufferedBytes := allocatedBytes(func() { buffered, _ = decodeLogsBuffered(body) })
+	streamedBytes := allocatedBytes(func() { streamed, _ = decodeLogsStreaming(body) })
+	if len(buffered) != 20000 || len(streamed) != 20000 {
+		t.Fatalf("decoded %d and %d logs", len(buffered), len(streamed))
+	}
+	// The buffered path holds the whole body at least twice (decoder buffer
+	// and RawMessage) on top of the decoded logs.
+	if streamedBytes+uint64(len(body)) > bufferedBytes {
+		t.Fatalf("streaming allocated %d bytes, buffered %d (body %d)", streamedBytes, bufferedBytes, len(body))
+	}
+}
@@ -0,0 +1,19 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+)
+
+// UncleCount returns the number of uncles (ommers) included in block via
+// eth_getUncleCountByBlockNumber. Post-merge blocks have none and report 0.
+func (p *httpProvider) UncleCount(ctx context.Context, block uint64) (uint64, error) {
+	var res *string
+	if err := p.call(ctx, "eth_getUncleCountByBlockNumber", []interface{}{toHex(block)}, &res); err != nil {
+		return 0, err
+	}
+	if res == nil {
+		return 0, fmt.Errorf("block %d not found", block)
+	}
+	return hexToUint64(*res)
+}
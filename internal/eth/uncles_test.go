@@ -0,0 +1,83 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func uncleProvider(t *testing.T, result any) (*httpProvider, *[]any) {
+	t.Helper()
+	var params []any
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_getUncleCountByBlockNumber" {
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+		params = req.Params
+		if s, ok := result.(string); ok && strings.HasPrefix(s, "rpcerr:") {
+			return mkRespErr(-32000, strings.TrimPrefix(s, "rpcerr:")), nil
+		}
+		return mkResp(result), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	return hp, &params
+}
+
+func TestUncleCount_Decodes(t *testing.T) {
+	hp, params := uncleProvider(t, "0x2")
+	got, err := hp.UncleCount(context.Background(), 255)
+	if err != nil || got != 2 {
+		t.Fatalf("count=%d err=%v", got, err)
+	}
+	if p := *params; len(p) != 1 || p[0] != "0xff" {
+		t.Fatalf("unexpected params: %v", p)
+	}
+}
+
+func TestUncleCount_PostMergeBlockHasNone(t *testing.T) {
+	hp, _ := uncleProvider(t, "0x0")
+	if got, err := hp.UncleCount(context.Background(), 17_000_000); err != nil || got != 0 {
+		t.Fatalf("count=%d err=%v", got, err)
+	}
+}
+
+func TestUncleCount_Errors(t *testing.T) {
+	hp, _ := uncleProvider(t, "rpcerr:boom")
+	if _, err := hp.UncleCount(context.Background(), 1); err == nil {
+		t.Fatal("expected rpc error")
+	}
+	hp, _ = uncleProvider(t, nil)
+	if _, err := hp.UncleCount(context.Background(), 1); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	hp, _ = uncleProvider(t, "0xzz")
+	if _, err := hp.UncleCount(context.Background(), 1); err == nil {
+		t.Fatal("expected quantity error")
+	}
+}
+
+func TestRLProvider_UncleCount(t *testing.T) {
+	hp, _ := uncleProvider(t, "0x1")
+	ur := WrapWithLimiter(hp, NewLimiter(0)).(UncleReader)
+	if v, err := ur.UncleCount(context.Background(), 1); err != nil || v != 1 {
+		t.Fatalf("count=%d err=%v", v, err)
+	}
+	if _, err := (RLProvider{p: fakeProvider{}, l: NewLimiter(0)}).UncleCount(context.Background(), 1); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := (RLProvider{p: hp, l: errLimiter{}}).UncleCount(context.Background(), 1); err == nil {
+		t.Fatal("expected limiter error")
+	}
+}
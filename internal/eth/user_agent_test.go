@@ -0,0 +1,38 @@
+package eth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPProvider_SendsUserAgent(t *testing.T) {
+	cases := map[string]struct {
+		opts []ProviderOption
+		want string
+	}{
+		"default":    {nil, DefaultUserAgent},
+		"configured": {[]ProviderOption{WithUserAgent("mvp_wallet_context/v1.2.3")}, "mvp_wallet_context/v1.2.3"},
+		"empty":      {[]ProviderOption{WithUserAgent("")}, DefaultUserAgent},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewProvider("http://unit-test", 0, 0, 0, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hp := p.(RLProvider).p.(*httpProvider)
+			var got []string
+			hp.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+				got = append(got, r.Header.Get("User-Agent"))
+				return mkResp("0x10"), nil
+			})}
+			if _, err := p.BlockNumber(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != tc.want {
+				t.Fatalf("User-Agent = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
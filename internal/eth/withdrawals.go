@@ -0,0 +1,97 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// rpcWithdrawal is one entry of a block's withdrawals list (EIP-4895).
+type rpcWithdrawal struct {
+	Index          string `json:"index"`
+	ValidatorIndex string `json:"validatorIndex"`
+	Address        string `json:"address"`
+	Amount         string `json:"amount"`
+}
+
+// rpcBlockWithdrawals is the part of a block header Withdrawals reads.
+type rpcBlockWithdrawals struct {
+	Timestamp   string          `json:"timestamp"`
+	Withdrawals []rpcWithdrawal `json:"withdrawals"`
+}
+
+// Withdrawals returns the withdrawals paid to address in blocks from..to.
+// Blocks the last Transactions call for the address fetched reuse the
+// withdrawals decoded there; the others are read from their headers
+// (eth_getBlockByNumber without transaction objects). Pre-Shapella blocks
+// have no withdrawals field and yield none.
+func (p *httpProvider) Withdrawals(ctx context.Context, address string, from, to uint64) ([]Withdrawal, error) {
+	addr := strings.ToLower(address)
+	walked := p.takeWalkedWithdrawals(addr)
+	var out []Withdrawal
+	for blk := from; blk <= to; blk++ {
+		ws, ok := walked[blk]
+		if !ok {
+			var hdr rpcBlockWithdrawals
+			if err := p.getBlock(ctx, blk, false, &hdr); err != nil {
+				return nil, fmt.Errorf("block %d: %w", blk, err)
+			}
+			tsSec, err := hexToUint64(hdr.Timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("block %d timestamp: %w", blk, err)
+			}
+			if ws, err = withdrawalsTo(hdr.Withdrawals, addr, blk, int64(tsSec)*1000); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, ws...)
+		if blk == to {
+			break
+		}
+	}
+	return out, nil
+}
+
+// withdrawalsTo decodes the entries of ws paid to addr (lowercase).
+func withdrawalsTo(ws []rpcWithdrawal, addr string, block uint64, tsMillis int64) ([]Withdrawal, error) {
+	var out []Withdrawal
+	for _, w := range ws {
+		if strings.ToLower(w.Address) != addr {
+			continue
+		}
+		index, err := hexToUint64(w.Index)
+		if err != nil {
+			return nil, fmt.Errorf("block %d withdrawal index: %w", block, err)
+		}
+		validator, err := hexToUint64(w.ValidatorIndex)
+		if err != nil {
+			return nil, fmt.Errorf("block %d withdrawal %d validator: %w", block, index, err)
+		}
+		if _, err := decodeQuantity(w.Amount); err != nil {
+			return nil, fmt.Errorf("block %d withdrawal %d amount: %w", block, index, err)
+		}
+		out = append(out, Withdrawal{Index: index, Validator: validator, Address: addr, AmountGwei: w.Amount, BlockNum: block, TsMillis: tsMillis})
+	}
+	return out, nil
+}
+
+// keepWalkedWithdrawals records the withdrawals to addr that a Transactions
+// call decoded, by block, replacing the previous call's.
+func (p *httpProvider) keepWalkedWithdrawals(addr string, byBlock map[uint64][]Withdrawal) {
+	p.walkedMu.Lock()
+	defer p.walkedMu.Unlock()
+	if p.walked == nil {
+		p.walked = make(map[string]map[uint64][]Withdrawal)
+	}
+	p.walked[addr] = byBlock
+}
+
+// takeWalkedWithdrawals hands over, and forgets, what keepWalkedWithdrawals
+// recorded for addr.
+func (p *httpProvider) takeWalkedWithdrawals(addr string) map[uint64][]Withdrawal {
+	p.walkedMu.Lock()
+	defer p.walkedMu.Unlock()
+	byBlock := p.walked[addr]
+	delete(p.walked, addr)
+	return byBlock
+}
@@ -0,0 +1,120 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// withdrawalProvider serves eth_getBlockByNumber from blocks, keyed by hex
+// block number, and counts the calls per block.
+func withdrawalProvider(t *testing.T, blocks map[string]any) (*httpProvider, map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "eth_getBlockByNumber" {
+			t.Fatalf("unexpected method %q", req.Method)
+		}
+		num, _ := req.Params[0].(string)
+		mu.Lock()
+		calls[num]++
+		mu.Unlock()
+		return mkResp(blocks[num]), nil
+	})}
+	p, err := NewHTTPProvider("http://unit-test", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hp := p.(*httpProvider)
+	hp.backoffBase = 1
+	hp.maxRetries = 0
+	return hp, calls
+}
+
+func TestWithdrawals_DecodesRecipientEntries(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	other := "0x" + strings.Repeat("cd", 20)
+	hp, _ := withdrawalProvider(t, map[string]any{
+		// Pre-Shapella: no withdrawals field.
+		"0x10": map[string]any{"timestamp": "0x1"},
+		"0x11": map[string]any{"timestamp": "0x2", "withdrawals": []map[string]any{
+			{"index": "0x5", "validatorIndex": "0x64", "address": strings.ToUpper(addr[:2]) + strings.ToUpper(addr[2:]), "amount": "0x1bc16d"},
+			{"index": "0x6", "validatorIndex": "0x65", "address": other, "amount": "0x1"},
+		}},
+	})
+	got, err := hp.Withdrawals(context.Background(), strings.ToUpper(addr), 0x10, 0x11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Withdrawal{{Index: 5, Validator: 100, Address: addr, AmountGwei: "0x1bc16d", BlockNum: 0x11, TsMillis: 2000}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWithdrawals_ReusesBlocksWalkedByTransactions(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	block := map[string]any{"timestamp": "0x3", "transactions": []any{}, "withdrawals": []map[string]any{
+		{"index": "0x7", "validatorIndex": "0x1", "address": addr, "amount": "0x2"},
+	}}
+	hp, calls := withdrawalProvider(t, map[string]any{"0x20": block, "0x21": block})
+	if _, err := hp.Transactions(context.Background(), addr, 0x20, 0x20); err != nil {
+		t.Fatal(err)
+	}
+	got, err := hp.Withdrawals(context.Background(), addr, 0x20, 0x21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].BlockNum != 0x20 || got[1].BlockNum != 0x21 || got[0].TsMillis != 3000 {
+		t.Fatalf("unexpected withdrawals: %+v", got)
+	}
+	if calls["0x20"] != 1 || calls["0x21"] != 1 {
+		t.Fatalf("block calls = %v, want one each", calls)
+	}
+	// The walked blocks are handed over once.
+	if _, err := hp.Withdrawals(context.Background(), addr, 0x20, 0x20); err != nil || calls["0x20"] != 2 {
+		t.Fatalf("calls=%v err=%v", calls, err)
+	}
+}
+
+func TestWithdrawals_Errors(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	cases := map[string]any{
+		"not found": nil,
+		"timestamp": map[string]any{"timestamp": "0xzz"},
+		"index":     map[string]any{"timestamp": "0x1", "withdrawals": []map[string]any{{"index": "0xzz", "validatorIndex": "0x1", "address": addr, "amount": "0x1"}}},
+		"validator": map[string]any{"timestamp": "0x1", "withdrawals": []map[string]any{{"index": "0x1", "validatorIndex": "0xzz", "address": addr, "amount": "0x1"}}},
+		"amount":    map[string]any{"timestamp": "0x1", "withdrawals": []map[string]any{{"index": "0x1", "validatorIndex": "0x1", "address": addr, "amount": "0xzz"}}},
+	}
+	for name, blk := range cases {
+		hp, _ := withdrawalProvider(t, map[string]any{"0x1": blk})
+		if _, err := hp.Withdrawals(context.Background(), addr, 1, 1); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRLProvider_Withdrawals(t *testing.T) {
+	addr := "0x" + strings.Repeat("ab", 20)
+	hp, _ := withdrawalProvider(t, map[string]any{"0x1": map[string]any{"timestamp": "0x1"}})
+	wr := WrapWithLimiter(hp, NewLimiter(0)).(WithdrawalReader)
+	if ws, err := wr.Withdrawals(context.Background(), addr, 1, 1); err != nil || len(ws) != 0 {
+		t.Fatalf("withdrawals=%v err=%v", ws, err)
+	}
+	if _, err := (RLProvider{p: fakeProvider{}, l: NewLimiter(0)}).Withdrawals(context.Background(), addr, 1, 1); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := (RLProvider{p: hp, l: errLimiter{}}).Withdrawals(context.Background(), addr, 1, 1); err == nil {
+		t.Fatal("expected limiter error")
+	}
+}
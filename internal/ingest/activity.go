@@ -0,0 +1,74 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// activityTables are the canonical tables whose rows mark the address as
+// active: its transactions, token transfers, internal calls and the
+// withdrawals credited to it.
+var activityTables = []string{"transactions", "token_transfers", "traces", "withdrawals"}
+
+// ErrNoActivity is returned by ActivityBounds when none of the activity
+// tables holds a row for the address.
+var ErrNoActivity = errors.New("ingest: no activity recorded for address")
+
+// ActivityBounds returns the first and last block at which the address
+// appears as a party in ClickHouse, combined across transactions, token
+// transfers, traces and withdrawals. Tables excluded by Options.Tables are
+// not consulted.
+func (i *Ingester) ActivityBounds(ctx context.Context) (first, last uint64, err error) {
+	if i.SchemaMode() != "canonical" {
+		return 0, 0, fmt.Errorf("activity bounds: only the canonical schema is supported")
+	}
+	if i.ch == nil || !i.ch.Enabled() {
+		return 0, 0, fmt.Errorf("activity bounds: a ClickHouse DSN is required")
+	}
+	addr := quoteCHString(i.address)
+	found := false
+	for _, s := range partyScopes {
+		if !slices.Contains(activityTables, s.table) || !i.wants(s.table) {
+			continue
+		}
+		parties := make([]string, len(s.parties))
+		for n, p := range s.parties {
+			parties[n] = i.columnName(s.table, p)
+		}
+		block := i.columnName(s.table, "block_number")
+		query := fmt.Sprintf("SELECT min(%s) AS first, max(%s) AS last, count() AS rows FROM %s WHERE has([%s], '%s') FORMAT JSONEachRow SETTINGS output_format_json_quote_64bit_integers = 0",
+			block, block, s.table, strings.Join(parties, ", "), addr)
+		rows, err := i.ch.QueryJSONEachRow(ctx, query)
+		if err != nil {
+			return 0, 0, fmt.Errorf("activity bounds of %s: %w", s.table, err)
+		}
+		for _, raw := range rows {
+			var r struct {
+				First uint64 `json:"first"`
+				Last  uint64 `json:"last"`
+				Rows  uint64 `json:"rows"`
+			}
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return 0, 0, fmt.Errorf("decoding %s bounds: %w", s.table, err)
+			}
+			if r.Rows == 0 {
+				continue
+			}
+			if !found || r.First < first {
+				first = r.First
+			}
+			if !found || r.Last > last {
+				last = r.Last
+			}
+			found = true
+		}
+	}
+	if !found {
+		return 0, 0, ErrNoActivity
+	}
+	return first, last, nil
+}
@@ -0,0 +1,84 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// boundsCH answers ActivityBounds queries with a per-table JSON row.
+func boundsCH(t *testing.T, ing *Ingester, bounds map[string]string) *[]string {
+	t.Helper()
+	var queries []string
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		queries = append(queries, q)
+		body := `{"first":0,"last":0,"rows":0}` + "\n"
+		for table, row := range bounds {
+			if strings.Contains(q, " FROM "+table+" ") {
+				body = row + "\n"
+			}
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+	return &queries
+}
+
+func TestActivityBounds_CombinesTables(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, nil)
+	queries := boundsCH(t, ing, map[string]string{
+		"transactions":    `{"first":120,"last":900,"rows":4}`,
+		"token_transfers": `{"first":80,"last":500,"rows":2}`,
+		"traces":          `{"first":300,"last":1500,"rows":7}`,
+	})
+	first, last, err := ing.ActivityBounds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 80 || last != 1500 {
+		t.Fatalf("bounds = [%d, %d], want [80, 1500]", first, last)
+	}
+	if len(*queries) != len(activityTables) {
+		t.Fatalf("queries = %d, want one per activity table", len(*queries))
+	}
+	for _, q := range *queries {
+		if !strings.Contains(q, "'"+addr+"'") || !strings.Contains(q, "min(block_number)") {
+			t.Fatalf("query not scoped to the address: %s", q)
+		}
+	}
+}
+
+func TestActivityBounds_NoData(t *testing.T) {
+	ing := NewWithProvider("0x"+strings.Repeat("a", 40), Options{ClickHouseDSN: "http://localhost:8123/db"}, nil)
+	boundsCH(t, ing, nil)
+	if _, _, err := ing.ActivityBounds(context.Background()); !errors.Is(err, ErrNoActivity) {
+		t.Fatalf("expected ErrNoActivity, got %v", err)
+	}
+}
+
+func TestActivityBounds_RespectsTablesAndColumnNames(t *testing.T) {
+	ing := NewWithProvider("0x"+strings.Repeat("a", 40), Options{
+		ClickHouseDSN: "http://localhost:8123/db",
+		Tables:        []string{"traces"},
+		ColumnNames:   map[string]string{"block_number": "block"},
+	}, nil)
+	queries := boundsCH(t, ing, map[string]string{"traces": `{"first":7,"last":7,"rows":1}`})
+	first, last, err := ing.ActivityBounds(context.Background())
+	if err != nil || first != 7 || last != 7 {
+		t.Fatalf("bounds = [%d, %d], err=%v", first, last, err)
+	}
+	if len(*queries) != 1 || !strings.Contains((*queries)[0], "min(block) AS first") {
+		t.Fatalf("queries = %q", *queries)
+	}
+}
+
+func TestActivityBounds_RequiresClickHouse(t *testing.T) {
+	ing := NewWithProvider("0x"+strings.Repeat("a", 40), Options{}, nil)
+	if _, _, err := ing.ActivityBounds(context.Background()); err == nil {
+		t.Fatal("expected error without a DSN")
+	}
+}
@@ -0,0 +1,230 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+const (
+	// DefaultMinBatchBlocks is the adaptive batch floor when MinBatchBlocks is unset.
+	DefaultMinBatchBlocks = 1
+	// adaptiveGrowAfter is the number of consecutive successful ranges before
+	// an adaptive batch doubles back toward BatchBlocks.
+	adaptiveGrowAfter = 8
+	// DefaultBatchWindowFactor bounds item-capped windows (MaxBatchItems) at
+	// this multiple of BatchBlocks when MaxBatchWindow is unset.
+	DefaultBatchWindowFactor = 16
+)
+
+// fetchError marks provider fetch failures. Fetches happen before any rows
+// are written, so a failed range can be retried over a smaller span.
+type fetchError struct{ err error }
+
+func (e *fetchError) Error() string { return e.err.Error() }
+func (e *fetchError) Unwrap() error { return e.err }
+
+// overCapError marks a fetched range holding more items than MaxBatchItems.
+// Nothing has been written yet, so the range is refetched over a narrower
+// window sized from the observed density.
+type overCapError struct {
+	span  uint64
+	items int
+}
+
+func (e *overCapError) Error() string {
+	return fmt.Sprintf("range of %d blocks holds %d items", e.span, e.items)
+}
+
+// batchSizer tracks the effective batch size. With adaptive sizing it halves
+// on fetch failures (down to min) and doubles back after sustained success
+// (up to max); otherwise it always returns max. With an item cap the window
+// instead follows the item density of the last range, between min and max.
+type batchSizer struct {
+	mu        sync.Mutex
+	adaptive  bool
+	cur       uint64
+	min       uint64
+	max       uint64
+	successes int
+	itemCap   uint64
+	lastSpan  uint64
+	lastItems uint64
+}
+
+func newBatchSizer(opts Options) *batchSizer {
+	max := uint64(opts.BatchBlocks)
+	if max == 0 {
+		max = DefaultBatchBlocks
+	}
+	min := uint64(opts.MinBatchBlocks)
+	if min == 0 {
+		min = DefaultMinBatchBlocks
+	}
+	if min > max {
+		min = max
+	}
+	b := &batchSizer{adaptive: opts.AdaptiveBatch, cur: max, min: min, max: max}
+	if opts.MaxBatchItems > 0 {
+		b.itemCap = uint64(opts.MaxBatchItems)
+		b.max = uint64(opts.MaxBatchWindow)
+		if b.max == 0 {
+			b.max = max * DefaultBatchWindowFactor
+		}
+		if b.max < b.cur {
+			b.max = b.cur
+		}
+	}
+	return b
+}
+
+func (b *batchSizer) size() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cur
+}
+
+// shrink halves the batch and reports whether it changed; false means the
+// floor was already reached (or sizing is fixed) and the error should surface.
+func (b *batchSizer) shrink() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.successes = 0
+	if !b.adaptive || b.cur <= b.min {
+		return false
+	}
+	b.cur /= 2
+	if b.cur < b.min {
+		b.cur = b.min
+	}
+	return true
+}
+
+// observe records the item count of a fetched range. Under an item cap it
+// returns an overCapError when the range holds too many items and can still
+// be narrowed.
+func (b *batchSizer) observe(from, to uint64, items int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastSpan, b.lastItems = to-from+1, uint64(items)
+	if b.itemCap == 0 || b.lastItems <= b.itemCap || b.lastSpan <= b.min {
+		return nil
+	}
+	return &overCapError{span: b.lastSpan, items: items}
+}
+
+// fit narrows the window after an over-cap range to the span the observed
+// density suggests would hold itemCap items. Progress is guaranteed because
+// the result is always narrower than span.
+func (b *batchSizer) fit(span uint64, items int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cur = span * b.itemCap / uint64(items)
+	if b.cur < b.min {
+		b.cur = b.min
+	}
+}
+
+// growItems sizes the next window from the last range's density, at most
+// doubling per range so a sparse stretch does not overshoot a dense one.
+func (b *batchSizer) growItems() {
+	next := b.cur * 2
+	if b.lastItems > 0 {
+		next = b.lastSpan * b.itemCap / b.lastItems
+	}
+	if next > b.cur*2 {
+		next = b.cur * 2
+	}
+	if next < b.min {
+		next = b.min
+	}
+	if next > b.max {
+		next = b.max
+	}
+	b.cur = next
+}
+
+func (b *batchSizer) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.itemCap > 0 {
+		b.successes = 0
+		b.growItems()
+		return
+	}
+	if !b.adaptive || b.cur >= b.max {
+		return
+	}
+	b.successes++
+	if b.successes < adaptiveGrowAfter {
+		return
+	}
+	b.successes = 0
+	b.cur *= 2
+	if b.cur > b.max {
+		b.cur = b.max
+	}
+}
+
+// processNext processes the next range starting at from (capped at to) using
+// the effective batch size and returns the last block processed. Fetch
+// failures shrink an adaptive batch and retry the shorter range, except an
+// exhausted RPC budget (eth.ErrBudgetExceeded); ranges over the item cap are
+// retried over a window fitted to their density.
+func (i *Ingester) processNext(ctx context.Context, from, to uint64, rs rangeState) (uint64, error) {
+	for {
+		end := from + i.batch.size() - 1
+		if end > to || end < from {
+			end = to
+		}
+		if err := i.walMark(walStart, from, end, rs); err != nil {
+			return 0, err
+		}
+		var err error
+		if i.stage != nil && rs.checkpoint != "" {
+			err = i.processStaged(ctx, from, end, rs)
+		} else if err = i.processRangeState(ctx, from, end, rs); err == nil && rs.checkpoint != "" {
+			i.markCovered(from, end)
+		}
+		if err == nil {
+			if err := i.walMark(walDone, from, end, rs); err != nil {
+				return 0, err
+			}
+			i.batch.succeed()
+			return end, nil
+		}
+		var oc *overCapError
+		if errors.As(err, &oc) {
+			i.batch.fit(oc.span, oc.items)
+			if logger := logging.Logger(); logger != nil {
+				logger.Debug("batch_over_cap",
+					"component", "ingest",
+					"address", i.address,
+					"from_block", from,
+					"to_block", end,
+					"items", oc.items,
+					"batch", i.batch.size(),
+				)
+			}
+			continue
+		}
+		var fe *fetchError
+		if !errors.As(err, &fe) || errors.Is(err, eth.ErrBudgetExceeded) || ctx.Err() != nil || !i.batch.shrink() {
+			return 0, err
+		}
+		if logger := logging.Logger(); logger != nil {
+			logger.Warn("batch_shrink",
+				"component", "ingest",
+				"address", i.address,
+				"from_block", from,
+				"to_block", end,
+				"batch", i.batch.size(),
+				"error", err.Error(),
+			)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// limitProv rejects GetLogs spans wider than limit for the first failFor
+// calls, mimicking a provider's response-size cap, and records served spans.
+type limitProv struct {
+	fixtureProv
+	limit   uint64
+	failFor int
+	calls   int
+	served  []uint64
+}
+
+func (p *limitProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.calls++
+	if span := to - from + 1; span > p.limit && p.calls <= p.failFor {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	p.served = append(p.served, to-from+1)
+	return nil, nil
+}
+
+func TestBackfill_AdaptiveBatchShrinksThenRecovers(t *testing.T) {
+	prov := &limitProv{fixtureProv: fixtureProv{head: 1000}, limit: 25, failFor: 2}
+	ing := NewWithProvider("", Options{BatchBlocks: 100, MinBatchBlocks: 10, AdaptiveBatch: true, ToBlock: 799}, prov)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if prov.served[0] != 25 {
+		t.Fatalf("expected first served span 25, got %v", prov.served)
+	}
+	want := []uint64{25, 25, 25, 25, 25, 25, 25, 25, 50, 50, 50, 50, 50, 50, 50, 50, 100}
+	for k, w := range want {
+		if prov.served[k] != w {
+			t.Fatalf("span %d = %d, want %d (served %v)", k, prov.served[k], w, prov.served)
+		}
+	}
+	if ing.batch.size() != 100 {
+		t.Fatalf("batch should recover to 100, got %d", ing.batch.size())
+	}
+}
+
+func TestBackfill_AdaptiveBatchStopsAtFloor(t *testing.T) {
+	prov := &limitProv{fixtureProv: fixtureProv{head: 1000}, limit: 5, failFor: 100}
+	ing := NewWithProvider("", Options{BatchBlocks: 100, MinBatchBlocks: 20, AdaptiveBatch: true}, prov)
+	err := ing.Backfill(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "getting logs") {
+		t.Fatalf("expected logs error at floor, got %v", err)
+	}
+	// 100 -> 50 -> 25 -> 20, then the floor surfaces the error.
+	if prov.calls != 4 || ing.batch.size() != 20 {
+		t.Fatalf("calls=%d batch=%d", prov.calls, ing.batch.size())
+	}
+}
+
+func TestBackfill_FixedBatchFailsWithoutRetry(t *testing.T) {
+	prov := &limitProv{fixtureProv: fixtureProv{head: 1000}, limit: 5, failFor: 100}
+	ing := NewWithProvider("", Options{BatchBlocks: 100}, prov)
+	if err := ing.Backfill(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if prov.calls != 1 || ing.batch.size() != 100 {
+		t.Fatalf("calls=%d batch=%d", prov.calls, ing.batch.size())
+	}
+}
+
+func TestProcessNext_InsertErrorsDoNotShrink(t *testing.T) {
+	prov := &fixtureProv{logs: []eth.Log{{TxHash: "0x1", Address: "0x" + strings.Repeat("c", 40), BlockNum: 1}}}
+	ing := NewWithProvider("", Options{ClickHouseDSN: "http://localhost:8123/db", BatchBlocks: 100, AdaptiveBatch: true}, prov)
+	ing.ch.SetTransport(queryFailingTransport{t: t, status: 400, matcher: "INSERT INTO logs"})
+	if _, err := ing.processNext(context.Background(), 0, 999, rangeState{}); err == nil {
+		t.Fatal("expected insert error")
+	}
+	if ing.batch.size() != 100 {
+		t.Fatalf("insert failure shrank batch to %d", ing.batch.size())
+	}
+}
+
+func TestNewBatchSizer_Defaults(t *testing.T) {
+	b := newBatchSizer(Options{MinBatchBlocks: 5000, BatchBlocks: 10})
+	if b.size() != 10 || b.min != 10 {
+		t.Fatalf("size=%d min=%d", b.size(), b.min)
+	}
+	b = newBatchSizer(Options{})
+	if b.size() != DefaultBatchBlocks || b.min != DefaultMinBatchBlocks {
+		t.Fatalf("size=%d min=%d", b.size(), b.min)
+	}
+}
+
+// densityProv returns perBlock logs for every block in [from, to] that is a
+// multiple of every, recording each requested span.
+type densityProv struct {
+	fixtureProv
+	perBlock int
+	every    uint64
+	served   []uint64
+}
+
+func (p *densityProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.served = append(p.served, to-from+1)
+	var out []eth.Log
+	for b := from; b <= to; b++ {
+		if b%p.every != 0 {
+			continue
+		}
+		for k := 0; k < p.perBlock; k++ {
+			out = append(out, eth.Log{TxHash: fmt.Sprintf("0x%x%02x", b, k), Index: uint32(k), Address: "0x" + strings.Repeat("c", 40), BlockNum: b, TsMillis: 1})
+		}
+	}
+	return out, nil
+}
+
+func TestBackfill_ItemCapNarrowsDenseWindow(t *testing.T) {
+	prov := &densityProv{fixtureProv: fixtureProv{head: 1000}, perBlock: 10, every: 1}
+	ing := NewWithProvider("", Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "canonical", Tables: []string{"logs"}, BatchBlocks: 100, MaxBatchItems: 100, FromBlock: 0, ToBlock: 199}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 100 blocks hold 1000 items: refetch at the fitted 10-block window, then
+	// stay there.
+	if prov.served[0] != 100 || prov.served[1] != 10 || ing.batch.size() != 10 {
+		t.Fatalf("served=%v batch=%d", prov.served[:2], ing.batch.size())
+	}
+	if len(prov.served) != 21 {
+		t.Fatalf("expected one over-cap refetch plus 20 ranges, got %v", prov.served)
+	}
+	rows := 0
+	for _, body := range inserts["logs"] {
+		n := strings.Count(strings.TrimSpace(body), "\n") + 1
+		if n > 100 {
+			t.Fatalf("insert of %d rows exceeds cap", n)
+		}
+		rows += n
+	}
+	if rows != 2000 {
+		t.Fatalf("wrote %d log rows, want 2000", rows)
+	}
+}
+
+func TestBackfill_ItemCapWidensSparseWindow(t *testing.T) {
+	prov := &densityProv{fixtureProv: fixtureProv{head: 100000}, perBlock: 1, every: 500}
+	ing := NewWithProvider("", Options{BatchBlocks: 100, MaxBatchItems: 10, MaxBatchWindow: 4000, ToBlock: 20000}, prov)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Growth is bounded to doubling per range, then capped by MaxBatchWindow.
+	want := []uint64{100, 200, 400, 800, 1600, 3200, 4000, 4000}
+	for k, w := range want {
+		if prov.served[k] != w {
+			t.Fatalf("span %d = %d, want %d (served %v)", k, prov.served[k], w, prov.served)
+		}
+	}
+	if ing.batch.size() != 4000 {
+		t.Fatalf("window = %d, want 4000", ing.batch.size())
+	}
+}
+
+func TestBatchSizer_ItemCapFitsDensity(t *testing.T) {
+	b := newBatchSizer(Options{BatchBlocks: 100, MinBatchBlocks: 4, MaxBatchItems: 50})
+	if b.max != 100*DefaultBatchWindowFactor {
+		t.Fatalf("default window max = %d", b.max)
+	}
+	// Over the cap: fit to the observed density.
+	var oc *overCapError
+	if err := b.observe(0, 99, 200); !errors.As(err, &oc) || oc.span != 100 {
+		t.Fatalf("expected over-cap error, got %v", err)
+	}
+	b.fit(oc.span, oc.items)
+	if b.size() != 25 {
+		t.Fatalf("fitted window = %d, want 25", b.size())
+	}
+	// At the floor the range is accepted even over the cap.
+	if err := b.observe(0, 3, 500); err != nil {
+		t.Fatalf("floor range rejected: %v", err)
+	}
+	b.fit(100, 100000)
+	if b.size() != 4 {
+		t.Fatalf("fit below floor = %d", b.size())
+	}
+	// Half-full ranges grow toward the cap, at most doubling.
+	if err := b.observe(0, 3, 10); err != nil {
+		t.Fatal(err)
+	}
+	b.succeed()
+	if b.size() != 8 {
+		t.Fatalf("grown window = %d, want 8", b.size())
+	}
+	if err := b.observe(0, 7, 40); err != nil {
+		t.Fatal(err)
+	}
+	b.succeed()
+	if b.size() != 10 {
+		t.Fatalf("density window = %d, want 10", b.size())
+	}
+}
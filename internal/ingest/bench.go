@@ -0,0 +1,200 @@
+package ingest
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// DefaultBenchBlocks is the sample size Bench processes when given 0 blocks.
+const DefaultBenchBlocks = 100
+
+// BenchReport is the throughput Bench measured over its sample.
+type BenchReport struct {
+	FromBlock uint64 `json:"from_block"`
+	ToBlock   uint64 `json:"to_block"`
+	Blocks    uint64 `json:"blocks"`
+	ElapsedMS int64  `json:"elapsed_ms"`
+	// RPCCalls counts provider calls by JSON-RPC method. A call may page
+	// over several HTTP requests.
+	RPCCalls       map[string]int `json:"rpc_calls"`
+	RPCCallsPerSec float64        `json:"rpc_calls_per_sec"`
+	Rows           int            `json:"rows"`
+	RowsPerSec     float64        `json:"rows_per_sec"`
+	Inserts        int            `json:"inserts"`
+	InsertMeanMS   float64        `json:"insert_mean_ms"`
+	InsertMaxMS    float64        `json:"insert_max_ms"`
+}
+
+// Bench processes the blocks most recently below the safe head (or from
+// Options.FromBlock, when set) exactly like a backfill, batch sizing
+// included, and reports the provider call rate, the row rate and the insert
+// latency. Rows are written like any backfill's; no checkpoint or coverage
+// is recorded, and staged commits are bypassed.
+func (i *Ingester) Bench(ctx context.Context, blocks uint64) (BenchReport, error) {
+	var r BenchReport
+	if i.prov == nil {
+		return r, nil
+	}
+	if blocks == 0 {
+		blocks = DefaultBenchBlocks
+	}
+	head, err := i.prov.BlockNumber(ctx)
+	if err != nil {
+		return r, err
+	}
+	safeHead, ok := i.safeHead(head)
+	if !ok {
+		return r, nil
+	}
+	i.noteSafeHead(safeHead)
+	from, to := i.opts.FromBlock, safeHead
+	if i.opts.ToBlock > 0 && i.opts.ToBlock < to {
+		to = i.opts.ToBlock
+	}
+	if from > 0 {
+		if from > to {
+			return r, nil
+		}
+		to = min(to, from+blocks-1)
+	} else if to >= blocks {
+		from = to - blocks + 1
+	}
+
+	prov, sink := i.prov, i.sink
+	bp := &benchProvider{p: prov, calls: map[string]int{}}
+	bs := &benchSink{s: newSink(i.ch, i.opts, i.kafka)}
+	i.prov, i.sink = bp, bs
+	defer func() { i.prov, i.sink = prov, sink }()
+
+	start := timeNow()
+	for cur := from; cur <= to; {
+		end, err := i.processNext(ctx, cur, to, rangeState{})
+		if err != nil {
+			return r, err
+		}
+		cur = end + 1
+	}
+	elapsed := timeNow().Sub(start)
+
+	r = BenchReport{
+		FromBlock: from,
+		ToBlock:   to,
+		Blocks:    to - from + 1,
+		ElapsedMS: elapsed.Milliseconds(),
+		RPCCalls:  bp.calls,
+		Rows:      bs.rows,
+		Inserts:   bs.inserts,
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		calls := 0
+		for _, n := range bp.calls {
+			calls += n
+		}
+		r.RPCCallsPerSec = float64(calls) / secs
+		r.RowsPerSec = float64(bs.rows) / secs
+	}
+	if bs.inserts > 0 {
+		r.InsertMeanMS = millis(bs.total) / float64(bs.inserts)
+		r.InsertMaxMS = millis(bs.max)
+	}
+	return r, nil
+}
+
+func millis(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// benchSink times each insert and counts the rows it writes.
+type benchSink struct {
+	s          Sink
+	rows       int
+	inserts    int
+	total, max time.Duration
+}
+
+func (b *benchSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	start := timeNow()
+	err := b.s.InsertJSONEachRow(ctx, table, rows)
+	d := timeNow().Sub(start)
+	if err != nil {
+		return err
+	}
+	b.rows += len(rows)
+	b.inserts++
+	b.total += d
+	b.max = max(b.max, d)
+	return nil
+}
+
+// benchProvider counts provider calls by JSON-RPC method. Like
+// eth.RLProvider it forwards the optional reader interfaces, returning
+// eth.ErrUnsupported when the wrapped provider lacks them.
+type benchProvider struct {
+	p     eth.Provider
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (b *benchProvider) count(method string) {
+	b.mu.Lock()
+	b.calls[method]++
+	b.mu.Unlock()
+}
+
+func (b *benchProvider) BlockNumber(ctx context.Context) (uint64, error) {
+	b.count(eth.MethodBlockNumber)
+	return b.p.BlockNumber(ctx)
+}
+
+func (b *benchProvider) BlockTimestamp(ctx context.Context, block uint64) (int64, error) {
+	b.count(eth.MethodGetBlockByNumber)
+	return b.p.BlockTimestamp(ctx, block)
+}
+
+func (b *benchProvider) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	b.count(eth.MethodGetLogs)
+	return b.p.GetLogs(ctx, address, from, to, topics)
+}
+
+func (b *benchProvider) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	b.count(eth.MethodTraceFilter)
+	return b.p.TraceBlock(ctx, from, to, address)
+}
+
+func (b *benchProvider) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	b.count(eth.MethodGetBlockReceipts)
+	return b.p.Transactions(ctx, address, from, to)
+}
+
+func (b *benchProvider) SetSafeHead(block uint64) {
+	if sa, ok := b.p.(eth.SafeHeadAware); ok {
+		sa.SetSafeHead(block)
+	}
+}
+
+func (b *benchProvider) StorageAt(ctx context.Context, address, slot string, block uint64) ([]byte, error) {
+	sr, ok := b.p.(eth.StorageReader)
+	if !ok {
+		return nil, eth.ErrUnsupported
+	}
+	b.count(eth.MethodGetStorageAt)
+	return sr.StorageAt(ctx, address, slot, block)
+}
+
+func (b *benchProvider) BalanceAt(ctx context.Context, address string, block uint64) (*big.Int, error) {
+	br, ok := b.p.(eth.BalanceReader)
+	if !ok {
+		return nil, eth.ErrUnsupported
+	}
+	b.count(eth.MethodGetBalance)
+	return br.BalanceAt(ctx, address, block)
+}
+
+func (b *benchProvider) ProviderLabel() string {
+	if pl, ok := b.p.(eth.ProviderLabeler); ok {
+		return pl.ProviderLabel()
+	}
+	return ""
+}
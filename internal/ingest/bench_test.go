@@ -0,0 +1,95 @@
+package ingest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// withTickingClock makes every timeNow call advance the clock by step.
+func withTickingClock(t *testing.T, step time.Duration) {
+	t.Helper()
+	prev := timeNow
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+	t.Cleanup(func() { timeNow = prev })
+}
+
+func runBench(t *testing.T, opts Options, blocks uint64) (BenchReport, *Ingester) {
+	t.Helper()
+	withTickingClock(t, time.Millisecond)
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	prov.head = 199
+	opts.ClickHouseDSN = "http://localhost:8123/db"
+	ing := NewWithProvider(addr, opts, &prov)
+	captureInserts(t, ing)
+	r, err := ing.Bench(context.Background(), blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, ing
+}
+
+func TestBench_ReportsRates(t *testing.T) {
+	r, ing := runBench(t, Options{BatchBlocks: 25}, 100)
+	if r.FromBlock != 100 || r.ToBlock != 199 || r.Blocks != 100 {
+		t.Fatalf("range = %d-%d (%d blocks)", r.FromBlock, r.ToBlock, r.Blocks)
+	}
+	// One call per batch, plus the trace capability probe.
+	want := map[string]int{eth.MethodGetLogs: 4, eth.MethodTraceFilter: 5, eth.MethodGetBlockReceipts: 4}
+	for method, n := range want {
+		if r.RPCCalls[method] != n {
+			t.Fatalf("%s calls = %d, want %d: %v", method, r.RPCCalls[method], n, r.RPCCalls)
+		}
+	}
+	if r.ElapsedMS <= 0 || r.Inserts == 0 || r.Rows < r.Inserts {
+		t.Fatalf("report = %+v", r)
+	}
+	secs := float64(r.ElapsedMS) / 1000
+	calls := 0
+	for _, n := range r.RPCCalls {
+		calls += n
+	}
+	if r.RowsPerSec != float64(r.Rows)/secs || r.RPCCallsPerSec != float64(calls)/secs {
+		t.Fatalf("rates do not match counts: %+v", r)
+	}
+	// Nothing reads the clock between an insert's start and end reads, so
+	// each insert takes exactly one tick.
+	if r.InsertMeanMS != 1 || r.InsertMaxMS != 1 {
+		t.Fatalf("insert latency mean=%v max=%v", r.InsertMeanMS, r.InsertMaxMS)
+	}
+	if _, ok := ing.prov.(*benchProvider); ok {
+		t.Fatal("provider not restored")
+	}
+	if _, ok := ing.sink.(*benchSink); ok {
+		t.Fatal("sink not restored")
+	}
+}
+
+func TestBench_Deterministic(t *testing.T) {
+	first, _ := runBench(t, Options{BatchBlocks: 25}, 100)
+	second, _ := runBench(t, Options{BatchBlocks: 25}, 100)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("reports differ:\n%+v\n%+v", first, second)
+	}
+}
+
+func TestBench_Range(t *testing.T) {
+	if r, _ := runBench(t, Options{FromBlock: 190}, 100); r.FromBlock != 190 || r.ToBlock != 199 {
+		t.Fatalf("from-block range = %d-%d", r.FromBlock, r.ToBlock)
+	}
+	if r, _ := runBench(t, Options{}, 500); r.FromBlock != 0 || r.ToBlock != 199 {
+		t.Fatalf("short chain range = %d-%d", r.FromBlock, r.ToBlock)
+	}
+	if r, _ := runBench(t, Options{Confirmations: 300}, 10); r.Blocks != 0 {
+		t.Fatalf("no safe head: %+v", r)
+	}
+}
@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var columnIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseColumnNames parses a comma-separated list of column=name renames for
+// Options.ColumnNames. A bare column renames it in every canonical table;
+// table.column renames it in that table only.
+func ParseColumnNames(spec string) (map[string]string, error) {
+	names := map[string]string{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(part, "=")
+		key, name = strings.TrimSpace(key), strings.TrimSpace(name)
+		if !ok || !columnIdent.MatchString(name) {
+			return nil, fmt.Errorf("invalid column rename %q (want column=name)", part)
+		}
+		column := key
+		if table, col, qualified := strings.Cut(key, "."); qualified {
+			if !slices.Contains(CanonicalTables, table) {
+				return nil, fmt.Errorf("column rename %q: %q is not a canonical table", part, table)
+			}
+			column = col
+		}
+		if !columnIdent.MatchString(column) {
+			return nil, fmt.Errorf("invalid column rename %q (want column=name)", part)
+		}
+		if _, dup := names[key]; dup {
+			return nil, fmt.Errorf("column %q renamed twice", key)
+		}
+		names[key] = name
+	}
+	return names, nil
+}
+
+// columnName returns the name table's column is written under.
+func (i *Ingester) columnName(table, column string) string {
+	return renamedColumn(i.opts.ColumnNames, table, column)
+}
+
+// renamedColumn returns the name table's column is written under given
+// names, in the Options.ColumnNames form.
+func renamedColumn(names map[string]string, table, column string) string {
+	if name, ok := names[table+"."+column]; ok {
+		return name
+	}
+	if name, ok := names[column]; ok {
+		return name
+	}
+	return column
+}
+
+// renameColumns applies Options.ColumnNames to a canonical row of table.
+func (i *Ingester) renameColumns(table string, row map[string]any) map[string]any {
+	if len(i.opts.ColumnNames) == 0 {
+		return row
+	}
+	out := make(map[string]any, len(row))
+	for column, v := range row {
+		out[i.columnName(table, column)] = v
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseColumnNames(t *testing.T) {
+	got, err := ParseColumnNames(" tx_hash = transaction_hash, logs.topics=topic_list ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"tx_hash": "transaction_hash", "logs.topics": "topic_list"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	for _, spec := range []string{"tx_hash", "tx_hash=", "tx_hash=bad name", "dev_logs.topics=t", "logs.=t", "a=b,a=c"} {
+		if _, err := ParseColumnNames(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestProcessRange_ColumnNamesRenameCanonicalRows(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: tablesFixture(addr)}
+	names := map[string]string{"tx_hash": "transaction_hash", "logs.topics": "topic_list"}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", ColumnNames: names}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	logs := strings.Join(inserts["logs"], "")
+	if !strings.Contains(logs, `"transaction_hash":"0x1"`) || !strings.Contains(logs, `"topic_list":[`) ||
+		strings.Contains(logs, `"tx_hash"`) || strings.Contains(logs, `"topics"`) {
+		t.Fatalf("logs payload: %s", logs)
+	}
+	transfers := strings.Join(inserts["token_transfers"], "")
+	if !strings.Contains(transfers, `"transaction_hash":"0x1"`) || strings.Contains(transfers, `"tx_hash"`) {
+		t.Fatalf("token_transfers payload: %s", transfers)
+	}
+	if !strings.Contains(strings.Join(inserts["transactions"], ""), `"ingested_at"`) {
+		t.Fatalf("bookkeeping columns should keep their names: %v", inserts["transactions"])
+	}
+}
+
+func TestProcessRange_DefaultColumnNames(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &countingProv{fixtureProv: tablesFixture(addr)})
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if logs := strings.Join(inserts["logs"], ""); !strings.Contains(logs, `"tx_hash":"0x1"`) || !strings.Contains(logs, `"topics":[`) {
+		t.Fatalf("logs payload: %s", logs)
+	}
+}
@@ -0,0 +1,187 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// CoverageTable holds, per address, the block intervals above its checkpoint
+// that were ingested in full while an earlier block was not.
+const CoverageTable = "ingested_ranges"
+
+// coverage is the ledger of block intervals ingested in full. When a provider
+// returns a range with blocks missing (eth.PartialError), only the rest of the
+// range counts as covered: the checkpoint stops at the first gap, and later
+// runs refetch the gaps while skipping the intervals already covered above it.
+type coverage struct {
+	start   uint64           // first block of the current run
+	covered []eth.BlockRange // sorted, disjoint and non-adjacent
+	missing []eth.BlockRange // reported by the range being processed
+}
+
+// add merges r into the covered intervals.
+func (c *coverage) add(r eth.BlockRange) {
+	out := make([]eth.BlockRange, 0, len(c.covered)+1)
+	for _, cr := range c.covered {
+		switch {
+		case cr.To < r.From && r.From-cr.To > 1:
+			out = append(out, cr)
+		case cr.From > r.To && cr.From-r.To > 1:
+			out = append(out, r)
+			r = cr
+		default:
+			r.From = min(r.From, cr.From)
+			r.To = max(r.To, cr.To)
+		}
+	}
+	c.covered = append(out, r)
+}
+
+// cover records [from, to] less the blocks in missing.
+func (c *coverage) cover(from, to uint64, missing []eth.BlockRange) {
+	sort.Slice(missing, func(a, b int) bool { return missing[a].From < missing[b].From })
+	next := from
+	for _, m := range missing {
+		if m.To < next || m.From > to {
+			continue
+		}
+		if m.From > next {
+			c.add(eth.BlockRange{From: next, To: m.From - 1})
+		}
+		if m.To >= to {
+			return
+		}
+		next = m.To + 1
+	}
+	c.add(eth.BlockRange{From: next, To: to})
+}
+
+// through reports the last block of the covered interval holding block.
+func (c *coverage) through(block uint64) (uint64, bool) {
+	for _, cr := range c.covered {
+		if cr.From <= block && block <= cr.To {
+			return cr.To, true
+		}
+	}
+	return 0, false
+}
+
+// limit caps to just below the first covered interval after block.
+func (c *coverage) limit(block, to uint64) uint64 {
+	for _, cr := range c.covered {
+		if cr.From > block && cr.From <= to {
+			return cr.From - 1
+		}
+	}
+	return to
+}
+
+// frontier returns the last block of the contiguous covered run from start.
+func (c *coverage) frontier() (uint64, bool) {
+	return c.through(c.start)
+}
+
+// trim drops the blocks at or below synced, which the checkpoint covers.
+func (c *coverage) trim(synced uint64) {
+	out := c.covered[:0]
+	for _, cr := range c.covered {
+		if cr.To <= synced {
+			continue
+		}
+		if cr.From <= synced {
+			cr.From = synced + 1
+		}
+		out = append(out, cr)
+	}
+	c.covered = out
+}
+
+// loadCoverage trims the ledger to what lies above the checkpoint and merges
+// in the intervals earlier runs persisted.
+func (i *Ingester) loadCoverage(ctx context.Context, ckpt addressCheckpoint, existed bool) error {
+	i.cov.missing = nil
+	if existed {
+		i.cov.trim(ckpt.LastSyncedBlock)
+	}
+	if i.ch == nil || !i.ch.Enabled() {
+		return nil
+	}
+	query := fmt.Sprintf("SELECT from_block, to_block FROM %s FINAL WHERE address = '%s'", CoverageTable, quoteCHString(i.address))
+	if existed {
+		query += fmt.Sprintf(" AND to_block > %d", ckpt.LastSyncedBlock)
+	}
+	query += " ORDER BY from_block FORMAT JSONEachRow SETTINGS output_format_json_quote_64bit_integers = 0"
+	rows, err := i.ch.QueryJSONEachRow(ctx, query)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", CoverageTable, err)
+	}
+	for _, raw := range rows {
+		var r struct {
+			From uint64 `json:"from_block"`
+			To   uint64 `json:"to_block"`
+		}
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return fmt.Errorf("decode %s: %w", CoverageTable, err)
+		}
+		if r.From > r.To {
+			continue
+		}
+		i.cov.add(eth.BlockRange{From: r.From, To: r.To})
+	}
+	if existed {
+		i.cov.trim(ckpt.LastSyncedBlock)
+	}
+	return nil
+}
+
+// markCovered records a processed range in the ledger, less any blocks its
+// fetches reported missing, and logs each gap left behind.
+func (i *Ingester) markCovered(from, to uint64) {
+	missing := i.cov.missing
+	i.cov.missing = nil
+	i.cov.cover(from, to, missing)
+	logger := logging.Logger()
+	if logger == nil {
+		return
+	}
+	for _, m := range missing {
+		logger.Warn("coverage_gap",
+			"component", "ingest",
+			"address", i.address,
+			"from_block", from,
+			"to_block", to,
+			"gap_from", m.From,
+			"gap_to", m.To,
+		)
+	}
+}
+
+// persistCoverage writes the covered intervals left above the checkpoint, if
+// one was written at synced, so a later run only refetches the gaps below
+// them. Nothing is written when the run left no gap.
+func (i *Ingester) persistCoverage(ctx context.Context, synced uint64, checkpointed bool) error {
+	if checkpointed {
+		i.cov.trim(synced)
+	}
+	if len(i.cov.covered) == 0 || i.ch == nil || !i.ch.Enabled() {
+		return nil
+	}
+	rows := make([]any, 0, len(i.cov.covered))
+	for _, cr := range i.cov.covered {
+		rows = append(rows, map[string]any{
+			"address":     i.address,
+			"from_block":  cr.From,
+			"to_block":    cr.To,
+			"ingested_at": i.rowVersion(),
+		})
+	}
+	if err := i.ch.InsertJSONEachRow(ctx, CoverageTable, rows); err != nil {
+		return fmt.Errorf("inserting %s: %w", CoverageTable, err)
+	}
+	return nil
+}
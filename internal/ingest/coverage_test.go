@@ -0,0 +1,145 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestCoverage_CoverFrontierAndLimit(t *testing.T) {
+	var c coverage
+	c.start = 0
+	c.cover(0, 2, nil)
+	c.cover(3, 8, []eth.BlockRange{{From: 7, To: 7}, {From: 4, To: 5}})
+	c.cover(9, 9, nil)
+	want := []eth.BlockRange{{From: 0, To: 3}, {From: 6, To: 6}, {From: 8, To: 9}}
+	if !reflect.DeepEqual(c.covered, want) {
+		t.Fatalf("covered = %v, want %v", c.covered, want)
+	}
+	if last, ok := c.frontier(); !ok || last != 3 {
+		t.Fatalf("frontier = %d, %v", last, ok)
+	}
+	if got := c.limit(4, 20); got != 5 {
+		t.Fatalf("limit(4) = %d, want 5", got)
+	}
+	if got := c.limit(10, 20); got != 20 {
+		t.Fatalf("limit(10) = %d, want 20", got)
+	}
+	c.cover(4, 5, nil)
+	c.cover(7, 7, nil)
+	if want := []eth.BlockRange{{From: 0, To: 9}}; !reflect.DeepEqual(c.covered, want) {
+		t.Fatalf("filled = %v, want %v", c.covered, want)
+	}
+	c.trim(4)
+	if want := []eth.BlockRange{{From: 5, To: 9}}; !reflect.DeepEqual(c.covered, want) {
+		t.Fatalf("trimmed = %v, want %v", c.covered, want)
+	}
+}
+
+// gappyProv serves stagedProv's transactions but reports the blocks in fail
+// as missing, like a lenient provider whose block fetches failed.
+type gappyProv struct {
+	stagedProv
+	fail   map[uint64]bool
+	ranges []eth.BlockRange
+}
+
+func (p *gappyProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	p.ranges = append(p.ranges, eth.BlockRange{From: from, To: to})
+	all, _ := p.stagedProv.Transactions(ctx, address, from, to)
+	var (
+		out     []eth.Transaction
+		missing []eth.BlockRange
+	)
+	for _, tx := range all {
+		if p.fail[tx.BlockNum] {
+			missing = append(missing, eth.BlockRange{From: tx.BlockNum, To: tx.BlockNum})
+			continue
+		}
+		out = append(out, tx)
+	}
+	if len(missing) > 0 {
+		return out, &eth.PartialError{Missing: missing, Err: context.DeadlineExceeded}
+	}
+	return out, nil
+}
+
+func stagedCheckpoint(t *testing.T, store *stagingCH) uint64 {
+	t.Helper()
+	rows := store.tables["addresses"]
+	if len(rows) == 0 {
+		t.Fatal("no checkpoint written")
+	}
+	var ckpt addressCheckpoint
+	if err := json.Unmarshal([]byte(rows[len(rows)-1]), &ckpt); err != nil {
+		t.Fatal(err)
+	}
+	return ckpt.LastSyncedBlock
+}
+
+func TestCoverage_GapHoldsCheckpointAndIsRetried(t *testing.T) {
+	for _, staged := range []bool{false, true} {
+		store := newStagingCH(t)
+		store.tables[CoverageTable] = nil
+		opts := Options{ClickHouseDSN: "http://localhost:8123/db", StagedCommit: staged, BatchBlocks: 3, ToBlock: 9, Tables: []string{"transactions"}}
+		prov := &gappyProv{stagedProv: stagedProv{fixtureProv{head: 100}}, fail: map[uint64]bool{5: true}}
+		ing := NewWithProvider("0x"+strings.Repeat("a", 40), opts, prov)
+		ing.ch.SetTransport(store)
+		logs := captureLogs(t)
+		if err := ing.Backfill(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := stagedCheckpoint(t, store); got != 4 {
+			t.Fatalf("staged=%v: checkpoint = %d, want 4 (below the gap)", staged, got)
+		}
+		if !strings.Contains(logs.String(), `"msg":"coverage_gap"`) || !strings.Contains(logs.String(), `"gap_from":5`) {
+			t.Fatalf("staged=%v: expected coverage_gap log: %s", staged, logs.String())
+		}
+		var ledger []eth.BlockRange
+		for _, raw := range store.tables[CoverageTable] {
+			var r struct {
+				From uint64 `json:"from_block"`
+				To   uint64 `json:"to_block"`
+			}
+			if err := json.Unmarshal([]byte(raw), &r); err != nil {
+				t.Fatal(err)
+			}
+			ledger = append(ledger, eth.BlockRange{From: r.From, To: r.To})
+		}
+		if want := []eth.BlockRange{{From: 6, To: 9}}; !reflect.DeepEqual(ledger, want) {
+			t.Fatalf("staged=%v: ledger = %v, want %v", staged, ledger, want)
+		}
+
+		// The next run, in a fresh process, refetches only the gap.
+		prov = &gappyProv{stagedProv: stagedProv{fixtureProv{head: 100}}}
+		ing = NewWithProvider("0x"+strings.Repeat("a", 40), opts, prov)
+		ing.ch.SetTransport(store)
+		if err := ing.Delta(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if want := []eth.BlockRange{{From: 5, To: 5}}; !reflect.DeepEqual(prov.ranges, want) {
+			t.Fatalf("staged=%v: second run fetched %v, want %v", staged, prov.ranges, want)
+		}
+		if got := stagedCheckpoint(t, store); got != 9 {
+			t.Fatalf("staged=%v: checkpoint after retry = %d, want 9", staged, got)
+		}
+		if n := len(store.tables["transactions"]); n != 10 {
+			t.Fatalf("staged=%v: transactions = %d, want 10", staged, n)
+		}
+	}
+}
+
+func TestCoverage_NoGapWritesNoLedger(t *testing.T) {
+	store := newStagingCH(t)
+	store.tables[CoverageTable] = nil
+	if err := stagedIngester(store).Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.tables[CoverageTable]); n != 0 {
+		t.Fatalf("ledger rows = %d, want none", n)
+	}
+}
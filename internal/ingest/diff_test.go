@@ -0,0 +1,151 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// storedRows ingests addr's fixture range under the column renames names and
+// returns the rows written, per table, decoded as ClickHouse would hand them
+// back.
+func storedRows(t *testing.T, addr string, names map[string]string) map[string][]map[string]any {
+	t.Helper()
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", ColumnNames: names}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	out := map[string][]map[string]any{}
+	for table, bodies := range inserts {
+		for _, line := range strings.Split(strings.TrimSpace(strings.Join(bodies, "")), "\n") {
+			var row map[string]any
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				t.Fatalf("decode %s payload: %v", table, err)
+			}
+			out[table] = append(out[table], row)
+		}
+	}
+	return out
+}
+
+// storedCH serves SELECTs scoped to the ingester's address from stored and
+// records every other query.
+func storedCH(t *testing.T, ing *Ingester, stored map[string][]map[string]any) *[]string {
+	t.Helper()
+	var writes []string
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		if !strings.HasPrefix(q, "SELECT") {
+			writes = append(writes, q)
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		var b strings.Builder
+		for table, rows := range stored {
+			if !strings.Contains(q, "FROM "+table+" FINAL WHERE block_number BETWEEN 1 AND 1 AND has([") || !strings.Contains(q, "], '"+ing.address+"')") {
+				continue
+			}
+			for _, row := range rows {
+				line, _ := json.Marshal(row)
+				b.Write(line)
+				b.WriteByte('\n')
+			}
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(b.String()))}, nil
+	}))
+	return &writes
+}
+
+func TestDiff_Counts(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	stored := storedRows(t, addr, nil)
+	// A decoder change altered the stored transfer's amount, the approval was
+	// never stored, and the log rows were written before source_provider and
+	// with another version: only decoded columns count.
+	stored["token_transfers"][0]["amount_raw"] = "999"
+	delete(stored, "approvals")
+	for _, row := range stored["logs"] {
+		row["ingested_at"] = "2020-01-01 00:00:00.000"
+		row["source_provider"] = "old.example.com"
+	}
+
+	prov := tablesFixture(addr)
+	prov.head = 1
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", FromBlock: 1, ToBlock: 1, RecordProviderSource: true}, &prov)
+	writes := storedCH(t, ing, stored)
+	r, err := ing.Diff(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*writes) != 0 {
+		t.Fatalf("diff wrote: %v", *writes)
+	}
+	if r.FromBlock != 1 || r.ToBlock != 1 {
+		t.Fatalf("range = %d-%d", r.FromBlock, r.ToBlock)
+	}
+	want := map[string]TableDiff{
+		"logs":            {Unchanged: 2},
+		"token_transfers": {Changed: 1},
+		"approvals":       {Added: 1},
+		"transactions":    {Unchanged: len(stored["transactions"])},
+		"traces":          {Unchanged: len(stored["traces"])},
+	}
+	for table, w := range want {
+		if r.Tables[table] != w {
+			t.Fatalf("%s: got %+v, want %+v (report %+v)", table, r.Tables[table], w, r.Tables)
+		}
+	}
+	if len(r.Tables) != len(want) {
+		t.Fatalf("tables = %+v", r.Tables)
+	}
+	if _, ok := ing.sink.(*diffSink); ok {
+		t.Fatal("sink not restored")
+	}
+}
+
+func TestDiff_RenamedStampedColumns(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	names := map[string]string{"ingested_at": "version", "source_provider": "provider"}
+	stored := storedRows(t, addr, names)
+	for _, row := range stored["logs"] {
+		row["version"] = "2020-01-01 00:00:00.000"
+		row["provider"] = "old.example.com"
+	}
+
+	prov := tablesFixture(addr)
+	prov.head = 1
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", FromBlock: 1, ToBlock: 1, RecordProviderSource: true, ColumnNames: names}, &prov)
+	storedCH(t, ing, stored)
+	r, err := ing.Diff(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Tables["logs"] != (TableDiff{Unchanged: 2}) {
+		t.Fatalf("logs: got %+v, want only unchanged rows", r.Tables["logs"])
+	}
+}
+
+func TestDiff_Requirements(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	if _, err := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "dev"}, &prov).Diff(context.Background()); err == nil {
+		t.Fatal("expected dev schema to be rejected")
+	}
+	if _, err := NewWithProvider(addr, Options{}, &prov).Diff(context.Background()); err == nil {
+		t.Fatal("expected missing DSN to be rejected")
+	}
+}
+
+func TestDiffValue(t *testing.T) {
+	row, err := decodeDiffRow([]byte(`{"n":1,"s":"1","b":true,"z":0,"a":["x",2],"null":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffValue(row["n"]) != diffValue(row["s"]) || diffValue(row["b"]) != "1" || diffValue(row["null"]) != "" || diffValue(row["a"]) != "[x,2]" {
+		t.Fatalf("values: %q %q %q %q %q", diffValue(row["n"]), diffValue(row["s"]), diffValue(row["b"]), diffValue(row["null"]), diffValue(row["a"]))
+	}
+}
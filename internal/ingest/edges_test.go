@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// edgeTotals emulates SELECT ... FINAL GROUP BY src, dst, asset over the
+// captured address_edges payloads: the last row written per (src, dst,
+// asset, block_number) wins, and the survivors are summed per edge.
+func edgeTotals(t *testing.T, payloads []string) map[string]string {
+	t.Helper()
+	type edgeRow struct {
+		Src      string `json:"src"`
+		Dst      string `json:"dst"`
+		Asset    string `json:"asset"`
+		Block    uint64 `json:"block_number"`
+		Count    uint64 `json:"count"`
+		ValueSum string `json:"value_sum"`
+	}
+	latest := map[string]edgeRow{}
+	for _, line := range strings.Split(strings.TrimSpace(strings.Join(payloads, "")), "\n") {
+		var r edgeRow
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("payload line %q: %v", line, err)
+		}
+		latest[fmt.Sprintf("%s>%s %s @%d", r.Src, r.Dst, r.Asset, r.Block)] = r
+	}
+	counts, sums := map[string]uint64{}, map[string]uint64{}
+	for _, r := range latest {
+		edge := r.Src + ">" + r.Dst + " " + r.Asset
+		var v uint64
+		fmt.Sscan(r.ValueSum, &v)
+		counts[edge] += r.Count
+		sums[edge] += v
+	}
+	out := map[string]string{}
+	for edge, n := range counts {
+		out[edge] = fmt.Sprintf("count=%d value=%d", n, sums[edge])
+	}
+	return out
+}
+
+func TestProcessRange_AddressEdgesAcrossBatches(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	peer := "0x" + strings.Repeat("b", 40)
+	token := "0x" + strings.Repeat("c", 40)
+	tx := func(hash, from, to, value string, block uint64, status uint8) eth.Transaction {
+		return eth.Transaction{Hash: hash, From: from, To: to, ValueWei: value, BlockNum: block, Status: status}
+	}
+	batch1 := fixtureProv{
+		logs: []eth.Log{{TxHash: "0x1", Index: 0, Address: token, Topics: []string{"0xddf252ad", padTopicAddr(addr), padTopicAddr(peer)}, DataHex: "0x0a", BlockNum: 1}},
+		txs: []eth.Transaction{
+			tx("0x1", addr, peer, "5", 1, 1),
+			tx("0x2", addr, peer, "7", 1, 1),
+			tx("0x3", addr, peer, "100", 1, 0), // failed: counted, moves nothing
+			tx("0x4", addr, "", "0", 1, 1),     // contract creation: no edge
+		},
+	}
+	batch2 := fixtureProv{
+		txs: []eth.Transaction{
+			tx("0x5", peer, addr, "3", 2, 1),
+			tx("0x6", addr, peer, "1", 2, 1),
+		},
+	}
+	prov := batch1
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", AddressEdges: true}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	first := strings.Join(inserts[EdgesTable], "")
+	for _, want := range []string{
+		`"asset":"eth","block_number":1,"count":3,"dst":"` + peer + `"`,
+		`"asset":"` + token + `","block_number":1,"count":1,"dst":"` + peer + `"`,
+		`"value_sum":"12"`, `"value_sum":"10"`,
+	} {
+		if !strings.Contains(first, want) {
+			t.Fatalf("batch 1 edges missing %s: %s", want, first)
+		}
+	}
+	prov = batch2
+	if err := ing.processRange(context.Background(), 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		addr + ">" + peer + " eth":      "count=4 value=13",
+		peer + ">" + addr + " eth":      "count=1 value=3",
+		addr + ">" + peer + " " + token: "count=1 value=10",
+	}
+	if got := edgeTotals(t, inserts[EdgesTable]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("edge totals = %v, want %v", got, want)
+	}
+	// Reprocessing block 1, as a reorg rescan does, replaces its rows
+	// instead of adding to them.
+	prov = batch1
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := edgeTotals(t, inserts[EdgesTable]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("edge totals after reprocess = %v, want %v", got, want)
+	}
+}
+
+func TestProcessRange_AddressEdgesOptIn(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts[EdgesTable]) != 0 {
+		t.Fatalf("edges written without AddressEdges: %v", inserts[EdgesTable])
+	}
+}
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// Finality levels stamped onto canonical rows (Options.TrackFinality). Rows
+// written without tracking keep the column default, FinalityUnknown.
+const (
+	FinalityUnknown   = "unknown"
+	FinalityLatest    = "latest"
+	FinalitySafe      = "safe"
+	FinalityFinalized = "finalized"
+)
+
+// finalityHeads holds the node's safe and finalized block numbers as of the
+// start of the current run.
+type finalityHeads struct {
+	known     bool
+	safe      uint64
+	finalized uint64
+}
+
+// of classifies block against the heads.
+func (f finalityHeads) of(block uint64) string {
+	switch {
+	case !f.known:
+		return FinalityUnknown
+	case block <= f.finalized:
+		return FinalityFinalized
+	case block <= f.safe:
+		return FinalitySafe
+	default:
+		return FinalityLatest
+	}
+}
+
+// refreshFinality reads the safe and finalized heads when Options.TrackFinality
+// is set (canonical schema only). A provider that cannot resolve the tags
+// fails the run rather than silently writing rows without finality.
+func (i *Ingester) refreshFinality(ctx context.Context) error {
+	if !i.opts.TrackFinality || i.SchemaMode() != "canonical" {
+		return nil
+	}
+	fr, ok := i.prov.(eth.FinalityReader)
+	if !ok {
+		return fmt.Errorf("finality: %w", eth.ErrUnsupported)
+	}
+	finalized, err := fr.BlockNumberByTag(ctx, eth.TagFinalized)
+	if err != nil {
+		return fmt.Errorf("finality: resolving %s head: %w", eth.TagFinalized, err)
+	}
+	safe, err := fr.BlockNumberByTag(ctx, eth.TagSafe)
+	if err != nil {
+		return fmt.Errorf("finality: resolving %s head: %w", eth.TagSafe, err)
+	}
+	// Nodes answer the two tags independently; never report a block finalized
+	// but not safe.
+	if safe < finalized {
+		safe = finalized
+	}
+	i.fin = finalityHeads{known: true, safe: safe, finalized: finalized}
+	return nil
+}
+
+// finalityStatements returns the ALTER TABLE ... UPDATE mutations that promote
+// the address's earlier rows to the current safe and finalized heads. Rows
+// only move up: latest or unknown to safe, anything below to finalized.
+func (i *Ingester) finalityStatements() []string {
+	addr := quoteCHString(i.address)
+	var stmts []string
+	for _, s := range partyScopes {
+		if !i.wants(s.table) {
+			continue
+		}
+		stmts = append(stmts, fmt.Sprintf(
+			"ALTER TABLE %s UPDATE finality = if(block_number <= %d, '%s', '%s') WHERE has([%s], '%s') AND ((block_number <= %d AND finality != '%s') OR (block_number <= %d AND finality IN ('%s', '%s')))",
+			s.table, i.fin.finalized, FinalityFinalized, FinalitySafe,
+			strings.Join(s.parties, ", "), addr,
+			i.fin.finalized, FinalityFinalized,
+			i.fin.safe, FinalityUnknown, FinalityLatest,
+		))
+	}
+	return stmts
+}
+
+// promoteFinality updates the finality of rows written by earlier runs as the
+// heads advance. It runs at the start of Delta, before new rows are written;
+// those are stamped with the current heads directly. ClickHouse applies the
+// updates as asynchronous mutations, and file exports are never rewritten.
+// Repeated runs of one Ingester (e.g. polling) skip the mutations until a
+// head advances past the last promotion.
+func (i *Ingester) promoteFinality(ctx context.Context) error {
+	if !i.fin.known || i.ch == nil || !i.ch.Enabled() {
+		return nil
+	}
+	if p := i.promoted; p.known && i.fin.safe <= p.safe && i.fin.finalized <= p.finalized {
+		return nil
+	}
+	stmts := i.finalityStatements()
+	for _, stmt := range stmts {
+		if err := i.ch.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("finality: %w", err)
+		}
+	}
+	i.promoted = i.fin
+	if logger := logging.Logger(); logger != nil {
+		logger.Info("finality_promoted",
+			"component", "ingest",
+			"address", i.address,
+			"safe", i.fin.safe,
+			"finalized", i.fin.finalized,
+			"tables", len(stmts),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// finalityProv serves one transaction per block and resolves the safe and
+// finalized tags to fixed heads.
+type finalityProv struct {
+	stagedProv
+	safe, finalized uint64
+	tagErr          error
+}
+
+func (p *finalityProv) BlockNumberByTag(ctx context.Context, tag string) (uint64, error) {
+	if p.tagErr != nil {
+		return 0, p.tagErr
+	}
+	if tag == eth.TagFinalized {
+		return p.finalized, nil
+	}
+	return p.safe, nil
+}
+
+func finalityIngester(prov eth.Provider) *Ingester {
+	opts := Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "canonical", TrackFinality: true, BatchBlocks: 10, ToBlock: 9, Tables: []string{"transactions"}}
+	return NewWithProvider("0x"+strings.Repeat("a", 40), opts, prov)
+}
+
+func TestFinalityHeads_Of(t *testing.T) {
+	f := finalityHeads{known: true, safe: 7, finalized: 4}
+	for block, want := range map[uint64]string{0: FinalityFinalized, 4: FinalityFinalized, 5: FinalitySafe, 7: FinalitySafe, 8: FinalityLatest} {
+		if got := f.of(block); got != want {
+			t.Fatalf("block %d = %s, want %s", block, got, want)
+		}
+	}
+	if got := (finalityHeads{}).of(0); got != FinalityUnknown {
+		t.Fatalf("untracked = %s", got)
+	}
+}
+
+func TestBackfill_StampsFinalityRelativeToHeads(t *testing.T) {
+	prov := &finalityProv{stagedProv: stagedProv{fixtureProv{head: 100}}, safe: 7, finalized: 4}
+	ing := finalityIngester(prov)
+	got := captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[uint64]string{}
+	for _, payload := range got["transactions"] {
+		for _, line := range strings.Split(strings.TrimSpace(payload), "\n") {
+			var row struct {
+				Block    uint64 `json:"block_number"`
+				Finality string `json:"finality"`
+			}
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				t.Fatal(err)
+			}
+			seen[row.Block] = row.Finality
+		}
+	}
+	for b := uint64(0); b <= 9; b++ {
+		want := FinalityLatest
+		if b <= 4 {
+			want = FinalityFinalized
+		} else if b <= 7 {
+			want = FinalitySafe
+		}
+		if seen[b] != want {
+			t.Fatalf("block %d finality = %q, want %q (%v)", b, seen[b], want, seen)
+		}
+	}
+}
+
+func TestBackfill_WithoutFinalityOmitsColumn(t *testing.T) {
+	ing := NewWithProvider("0x"+strings.Repeat("a", 40), Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "canonical", ToBlock: 1, Tables: []string{"transactions"}}, &stagedProv{fixtureProv{head: 100}})
+	got := captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(got["transactions"]) == 0 || strings.Contains(strings.Join(got["transactions"], ""), `"finality"`) {
+		t.Fatalf("transactions = %q", got["transactions"])
+	}
+}
+
+func TestDelta_PromotesEarlierRows(t *testing.T) {
+	prov := &finalityProv{stagedProv: stagedProv{fixtureProv{head: 100}}, safe: 90, finalized: 60}
+	ing := finalityIngester(prov)
+	var alters []string
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		if q := r.URL.Query().Get("query"); strings.HasPrefix(q, "ALTER TABLE ") {
+			alters = append(alters, q)
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}))
+	logs := captureLogs(t)
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "ALTER TABLE transactions UPDATE finality = if(block_number <= 60, 'finalized', 'safe') WHERE has([from_addr, to_addr], '0x" + strings.Repeat("a", 40) + "') AND ((block_number <= 60 AND finality != 'finalized') OR (block_number <= 90 AND finality IN ('unknown', 'latest')))"
+	if len(alters) != 1 || alters[0] != want {
+		t.Fatalf("alters = %q", alters)
+	}
+	if !strings.Contains(logs.String(), `"msg":"finality_promoted"`) {
+		t.Fatalf("expected finality_promoted log: %s", logs.String())
+	}
+
+	// Unmoved heads queue no further mutations; an advanced one does.
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(alters) != 1 {
+		t.Fatalf("promoted again with unchanged heads: %q", alters)
+	}
+	prov.safe = 95
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(alters) != 2 || !strings.Contains(alters[1], "block_number <= 95") {
+		t.Fatalf("alters after safe advanced = %q", alters)
+	}
+}
+
+func TestFinality_Errors(t *testing.T) {
+	if err := finalityIngester(&stagedProv{fixtureProv{head: 100}}).Backfill(context.Background()); !errors.Is(err, eth.ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	prov := &finalityProv{stagedProv: stagedProv{fixtureProv{head: 100}}, tagErr: errors.New("unknown block")}
+	if err := finalityIngester(prov).Delta(context.Background()); err == nil || !strings.Contains(err.Error(), "finalized head") {
+		t.Fatalf("expected tag error, got %v", err)
+	}
+	// Finalized ahead of safe is clamped so no row is finalized but not safe.
+	prov = &finalityProv{safe: 3, finalized: 5}
+	ing := finalityIngester(prov)
+	if err := ing.refreshFinality(context.Background()); err != nil || ing.fin.safe != 5 {
+		t.Fatalf("fin = %+v err=%v", ing.fin, err)
+	}
+	dev := NewWithProvider("0xabc", Options{Schema: "dev", TrackFinality: true}, &stagedProv{})
+	if err := dev.refreshFinality(context.Background()); err != nil || dev.fin.known {
+		t.Fatalf("dev schema tracked finality: %+v %v", dev.fin, err)
+	}
+}
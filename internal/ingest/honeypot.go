@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+// Probe calldata for Options.HoneypotGuard: balanceOf(address(0)) and
+// transfer(address(1), 0). A standard ERC-20 answers the first with a word
+// and accepts the second sent from the token itself, since a zero amount
+// needs no balance.
+var (
+	balanceOfProbe = "0x70a08231" + strings.Repeat("0", 64)
+	transferProbe  = "0xa9059cbb" + strings.Repeat("0", 63) + "1" + strings.Repeat("0", 64)
+)
+
+// tokenProbes holds the Options.HoneypotGuard verdict per token contract,
+// so each is probed at most once per Ingester.
+type tokenProbes struct {
+	mu sync.Mutex
+	m  map[string]bool // true: suspicious
+}
+
+// flagSuspicious sets Suspicious on ERC-20 transfers whose token emitted the
+// Transfer event but reverts a balanceOf or transfer probe (eth_call), a
+// sign of a contract faking transfers it never made. It is a best-effort
+// signal: probe failures other than reverts are logged and leave transfers
+// unflagged, and a provider without eth_call skips the check.
+func (i *Ingester) flagSuspicious(ctx context.Context, transfers []normalize.TokenTransferRow) {
+	if !i.opts.HoneypotGuard || len(transfers) == 0 {
+		return
+	}
+	cc, ok := i.prov.(eth.ContractCaller)
+	if !ok {
+		return
+	}
+	i.probes.mu.Lock()
+	defer i.probes.mu.Unlock()
+	if i.probes.m == nil {
+		i.probes.m = map[string]bool{}
+	}
+	failed := map[string]bool{}
+	for k := range transfers {
+		t := &transfers[k]
+		if t.Standard != "erc20" || failed[t.Token] {
+			continue
+		}
+		suspicious, known := i.probes.m[t.Token]
+		if !known {
+			var err error
+			suspicious, err = probeToken(ctx, cc, t.Token, t.BlockNum)
+			if errors.Is(err, eth.ErrUnsupported) {
+				return
+			}
+			if err != nil {
+				failed[t.Token] = true
+				if logger := logging.Logger(); logger != nil {
+					logger.Warn("token_probe_failed",
+						"component", "ingest",
+						"address", i.address,
+						"token", t.Token,
+						"error", err.Error(),
+					)
+				}
+				continue
+			}
+			i.probes.m[t.Token] = suspicious
+		}
+		if suspicious {
+			t.Suspicious = 1
+		}
+	}
+}
+
+// probeToken reports whether token reverts, or answers without a word, the
+// standard ERC-20 probes at block.
+func probeToken(ctx context.Context, cc eth.ContractCaller, token string, block uint64) (bool, error) {
+	out, err := cc.CallContract(ctx, "", token, balanceOfProbe, block)
+	if errors.Is(err, eth.ErrExecutionReverted) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(out) < 32 {
+		return true, nil
+	}
+	// Tokens such as USDT return nothing from transfer, so only a revert
+	// counts against it.
+	if _, err := cc.CallContract(ctx, token, token, transferProbe, block); err != nil {
+		if errors.Is(err, eth.ErrExecutionReverted) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,125 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// probeProv answers eth_call probes per token: "ok" behaves like an ERC-20,
+// "no-transfer" reverts transfer, "no-balance" reverts balanceOf and "down"
+// fails without a revert.
+type probeProv struct {
+	fixtureProv
+	tokens map[string]string
+	calls  []string
+}
+
+func (p *probeProv) CallContract(ctx context.Context, from, to, data string, block uint64) ([]byte, error) {
+	p.calls = append(p.calls, fmt.Sprintf("%s:%s:%s:%d", from, to, data[:10], block))
+	mode := p.tokens[to]
+	switch {
+	case mode == "down":
+		return nil, errors.New("http 503")
+	case mode == "no-balance" && data == balanceOfProbe, mode == "no-transfer" && data == transferProbe:
+		return nil, fmt.Errorf("%w: ", eth.ErrExecutionReverted)
+	case data == balanceOfProbe:
+		return make([]byte, 32), nil
+	}
+	return nil, nil
+}
+
+func TestHoneypotGuard_FlagsTokensFailingProbe(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	legit, fake, fakeBalance, down := "0x"+strings.Repeat("1", 40), "0x"+strings.Repeat("2", 40), "0x"+strings.Repeat("3", 40), "0x"+strings.Repeat("4", 40)
+	nft := "0x" + strings.Repeat("5", 40)
+	holder, other := padTopicAddr(addr), padTopicAddr("0x"+strings.Repeat("b", 40))
+	xfer := func(token string, idx uint32) eth.Log {
+		return eth.Log{TxHash: "0x1", Index: idx, Address: token, Topics: []string{"0xddf252ad", holder, other}, DataHex: "0x01", BlockNum: 7}
+	}
+	prov := &probeProv{
+		fixtureProv: fixtureProv{logs: []eth.Log{
+			xfer(legit, 0), xfer(fake, 1), xfer(fake, 2), xfer(fakeBalance, 3), xfer(down, 4),
+			{TxHash: "0x1", Index: 5, Address: nft, Topics: []string{"0xddf252ad", holder, other, padTopicAddr("0x09")}, DataHex: "0x", BlockNum: 7},
+		}},
+		tokens: map[string]string{legit: "ok", fake: "no-transfer", fakeBalance: "no-balance", down: "down"},
+	}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", HoneypotGuard: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["token_transfers"], "")), "\n")
+	if len(rows) != 6 {
+		t.Fatalf("token_transfers rows = %d: %v", len(rows), rows)
+	}
+	want := map[string]bool{legit: false, fake: true, fakeBalance: true, down: false, nft: false}
+	for _, row := range rows {
+		for token, suspicious := range want {
+			if !strings.Contains(row, `"token":"`+token+`"`) {
+				continue
+			}
+			if got := strings.Contains(row, `"suspicious":1`); got != suspicious {
+				t.Fatalf("%s: suspicious=%v, want %v: %s", token, got, suspicious, row)
+			}
+		}
+	}
+	// Each token is probed once; the ERC-721 is not probed and transfer is
+	// sent from the token itself.
+	wantCalls := []string{
+		":" + legit + ":0x70a08231:7", legit + ":" + legit + ":0xa9059cbb:7",
+		":" + fake + ":0x70a08231:7", fake + ":" + fake + ":0xa9059cbb:7",
+		":" + fakeBalance + ":0x70a08231:7",
+		":" + down + ":0x70a08231:7",
+	}
+	if strings.Join(prov.calls, " ") != strings.Join(wantCalls, " ") {
+		t.Fatalf("calls = %v, want %v", prov.calls, wantCalls)
+	}
+
+	// Verdicts are reused by later ranges; failed probes are retried.
+	prov.calls = nil
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.calls) != 1 || !strings.Contains(prov.calls[0], down) {
+		t.Fatalf("second range calls = %v", prov.calls)
+	}
+}
+
+func TestHoneypotGuard_OffByDefault(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	token := "0x" + strings.Repeat("2", 40)
+	prov := &probeProv{
+		fixtureProv: fixtureProv{logs: []eth.Log{{TxHash: "0x1", Address: token, Topics: []string{"0xddf252ad", padTopicAddr(addr), padTopicAddr("0x" + strings.Repeat("b", 40))}, DataHex: "0x01", BlockNum: 7}}},
+		tokens:      map[string]string{token: "no-transfer"},
+	}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.calls) != 0 || !strings.Contains(strings.Join(inserts["token_transfers"], ""), `"suspicious":0`) {
+		t.Fatalf("calls=%v rows=%v", prov.calls, inserts["token_transfers"])
+	}
+}
+
+func TestHoneypotGuard_DevSchema(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	token := "0x" + strings.Repeat("2", 40)
+	prov := &probeProv{
+		fixtureProv: fixtureProv{logs: []eth.Log{{TxHash: "0x1", Address: token, Topics: []string{"0xddf252ad", padTopicAddr(addr), padTopicAddr("0x" + strings.Repeat("b", 40))}, DataHex: "0x01", BlockNum: 7}}},
+		tokens:      map[string]string{token: "no-balance"},
+	}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "dev", HoneypotGuard: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(inserts["dev_token_transfers"], ""), `"suspicious":1`) {
+		t.Fatalf("dev_token_transfers = %v", inserts["dev_token_transfers"])
+	}
+}
@@ -3,26 +3,40 @@ package ingest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
 	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
 	"github.com/AIAleph/mvp_wallet_context/pkg/ch"
 )
 
 // Default ingest tunables.
 const (
-	DefaultSchemaMode  = "canonical"
-	DefaultBatchBlocks = 1000
+	DefaultSchemaMode         = "canonical"
+	DefaultBatchBlocks        = 1000
+	DefaultTimestampPrecision = "ms"
 )
 
-var addressPattern = regexp.MustCompile(`^0x[0-9a-f]*$`)
+var (
+	addressPattern     = regexp.MustCompile(`^0x[0-9a-f]*$`)
+	fullAddressPattern = regexp.MustCompile(`^0x[0-9a-f]{40}$`)
+)
+
+// CanonicalTables lists the canonical tables a run can write, in write order.
+var CanonicalTables = []string{"logs", "token_transfers", "approvals", "proxy_upgrades", "swaps", "vault_events", "contracts", "transactions", "sub_calls", "traces", "withdrawals"}
+
+// DevTables lists the tables the dev schema writes, in write order.
+var DevTables = []string{"dev_logs", "dev_token_transfers", "dev_approvals", "dev_transactions", "dev_traces"}
 
 // NormalizeSchema standardizes the ingestion schema selection.
 // Accepts "canonical" (default) and "dev"; rejects other inputs.
@@ -39,6 +53,49 @@ func NormalizeSchema(schema string) (string, error) {
 	}
 }
 
+// timestampDigits maps each accepted Options.TimestampPrecision to the
+// fractional-second digits written: DateTime, DateTime64(3), DateTime64(6).
+var timestampDigits = map[string]int{"s": 0, "ms": 3, "us": 6}
+
+// NormalizeTimestampPrecision standardizes the canonical timestamp precision.
+// Accepts "s", "ms" (default) and "us"; rejects other inputs.
+func NormalizeTimestampPrecision(precision string) (string, error) {
+	p := strings.ToLower(strings.TrimSpace(precision))
+	if p == "" {
+		return DefaultTimestampPrecision, nil
+	}
+	if _, ok := timestampDigits[p]; !ok {
+		return "", fmt.Errorf("invalid timestamp precision %q (want s, ms or us)", precision)
+	}
+	return p, nil
+}
+
+// NormalizeTables lowercases, trims and de-duplicates a canonical table
+// selection, rejecting names outside CanonicalTables. Empty input stays nil.
+func NormalizeTables(tables []string) ([]string, error) {
+	var out []string
+	seen := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		name := strings.ToLower(strings.TrimSpace(t))
+		if name == "" || seen[name] {
+			continue
+		}
+		known := false
+		for _, c := range CanonicalTables {
+			if c == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown table %q", t)
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out, nil
+}
+
 // Options configure a run of the ingester.
 type Options struct {
 	ProviderURL   string
@@ -53,8 +110,253 @@ type Options struct {
 	Timeout       time.Duration
 	// Schema selects target tables: "dev" (dev_*) or "canonical" (schema.sql tables)
 	Schema string
+	// IncludeUnconfirmed also ingests blocks above the safe head (canonical
+	// schema only), flagging those rows unconfirmed=1. The checkpoint never
+	// advances past the safe head, so the tip is re-ingested once confirmed.
+	IncludeUnconfirmed bool
+	// NoFinality treats the chain head as final, ignoring Confirmations, for
+	// local dev nodes (Anvil, Hardhat) that mine on demand and never reach
+	// a confirmation window. Delta skips its reorg rescan too. It must not
+	// be used against a public network, where the tip can reorg; New logs
+	// a no_finality warning whenever it is set.
+	NoFinality bool
+	// OutputDir, when set, also writes normalized rows to per-table .jsonl
+	// files there (see FileSink). Without a ClickHouse DSN only files are written.
+	OutputDir string
+	// OutputRotateBytes caps each export file (0 = DefaultOutputRotateBytes).
+	OutputRotateBytes int64
+	// Tables restricts canonical writes to the named tables (see
+	// CanonicalTables); empty writes everything. Provider fetches that only
+	// feed excluded tables are skipped.
+	Tables []string
+	// AdaptiveBatch halves the effective batch when a range fetch fails and
+	// retries, down to MinBatchBlocks (0 = DefaultMinBatchBlocks), growing
+	// back toward BatchBlocks after sustained success.
+	AdaptiveBatch  bool
+	MinBatchBlocks int
+	// MaxBatchItems caps ranges by fetched items (logs, transactions and
+	// traces) instead of blocks, so dense and sparse addresses produce
+	// similar insert sizes. BatchBlocks becomes the starting window; each
+	// following window is sized from the observed item density, widening up
+	// to MaxBatchWindow blocks (0 = DefaultBatchWindowFactor × BatchBlocks).
+	// A range over the cap is refetched over a narrower window before any row
+	// is written, unless it is already MinBatchBlocks wide. 0 disables.
+	MaxBatchItems  int
+	MaxBatchWindow int
+	// ClickHouseCompression requests zstd/gzip-compressed SELECT responses.
+	ClickHouseCompression bool
+	// ClickHousePool sizes the ClickHouse client's connection pool; zero
+	// fields keep the ch defaults.
+	ClickHousePool ch.PoolOptions
+	// QueryTimeout and InsertTimeout bound each ClickHouse query and insert
+	// attempt whose context has no deadline; zero keeps
+	// ch.DefaultQueryTimeout and ch.DefaultInsertTimeout.
+	QueryTimeout  time.Duration
+	InsertTimeout time.Duration
+	// UserAgent is sent on ClickHouse requests (empty = ch.DefaultUserAgent).
+	// The RPC provider is configured separately via eth.WithUserAgent.
+	UserAgent string
+	// MaxResponseBytes caps ClickHouse response bodies (0 =
+	// ch.DefaultMaxResponseBytes); see eth.WithMaxResponseBytes for RPC.
+	MaxResponseBytes int64
+	// RowBinaryTables lists canonical tables whose inserts are sent in
+	// ClickHouse's RowBinary format instead of JSONEachRow, which the server
+	// parses faster. Each table's columns are read with DESCRIBE TABLE on
+	// its first insert (see ch.Client.SetRowBinary); omitted columns take
+	// their type's zero value rather than the column DEFAULT. File exports
+	// and Kafka messages stay JSON.
+	RowBinaryTables []string
+	// InsertSplitMinRows bounds how far an insert ClickHouse rejects with
+	// MEMORY_LIMIT_EXCEEDED is split: the batch is halved and each half
+	// inserted on its own, recursively, while it holds more than this many
+	// rows (0 splits down to single rows). Negative disables splitting, so
+	// the error fails the range.
+	InsertSplitMinRows int
+	// Deterministic sorts fetched data into a canonical order and counts
+	// row versions and checkpoint timestamps up from the Unix epoch, so the
+	// same chain input yields byte-identical insert payloads. Intended for
+	// tests and debugging: those versions lose every ReplacingMergeTree merge
+	// against rows written by a normal run, so the CLI refuses it with a
+	// ClickHouse DSN.
+	Deterministic bool
+	// Reconcile compares each range's net ETH flow (external and internal
+	// transfers and withdrawals minus gas fees) with the eth_getBalance delta
+	// across its boundaries and records the result in ReconciliationTable.
+	// Traces, transactions and withdrawals are fetched even when Tables
+	// excludes them.
+	Reconcile bool
+	// ContractMode treats the address as a token contract rather than a
+	// wallet: logs are fetched by emitter address filtered to transfer event
+	// topics (normalize.TransferTopics), so every transfer of the token is
+	// written regardless of sender or recipient. Transactions and traces are
+	// not fetched, and Reconcile is ignored.
+	ContractMode bool
+	// ReportUpToDate makes Delta return ErrUpToDate instead of nil when there
+	// are no new confirmed blocks to process. The checkpoint timestamp is
+	// still refreshed first.
+	ReportUpToDate bool
+	// RunLock makes Backfill and Delta take an advisory per-address lock in
+	// RunLocksTable first, failing with ErrAddressLocked while another run
+	// holds a fresh one. The lock is kept fresh by a heartbeat and expires
+	// LockTTL (0 = DefaultLockTTL) after the last one, so a crashed run only
+	// blocks the address briefly. Requires a ClickHouse DSN.
+	RunLock bool
+	LockTTL time.Duration
+	// IndexedAmountTokens lists fungible token contracts whose Transfer event
+	// indexes the amount as topics[3]. Their transfers are decoded as erc20
+	// with non_standard=1 instead of as ERC-721 token IDs.
+	IndexedAmountTokens []string
+	// MaxBlocksPerRun caps how many blocks one Backfill or Delta call
+	// processes (0 = unlimited). At the cap the checkpoint is persisted at
+	// the last processed block and ErrMaxBlocksReached is returned, so a
+	// scheduler can re-invoke to continue. Delta's reorg rescan of blocks
+	// at or below the checkpoint does not count toward the cap.
+	MaxBlocksPerRun uint64
+	// VerifyLogs re-queries eth_getLogs once, after VerifyLogsDelay (0 =
+	// DefaultVerifyLogsDelay), when a range returns no logs although the
+	// address received a successful call with calldata in it. This guards
+	// against providers whose log indexers briefly lag their block data.
+	// Transactions are fetched even when Tables excludes them.
+	VerifyLogs      bool
+	VerifyLogsDelay time.Duration
+	// StagedCommit commits each confirmed batch together with its checkpoint:
+	// ClickHouse rows go to per-address staging tables and are copied into
+	// their targets (INSERT ... SELECT) just before the checkpoint row, and a
+	// batch interrupted mid-commit is completed by the next run. File
+	// exports (OutputDir) and Kafka messages are written directly. Requires
+	// a ClickHouse DSN.
+	StagedCommit bool
+	// TrackFinality stamps canonical rows with a finality level (latest,
+	// safe or finalized) from the node's "safe" and "finalized" block tags,
+	// read at the start of each run, and makes Delta promote the address's
+	// earlier rows as those heads advance. The provider must implement
+	// eth.FinalityReader. Canonical schema only.
+	TrackFinality bool
+	// HoneypotGuard probes each ERC-20 token that emits a transfer with
+	// balanceOf and transfer eth_calls and sets suspicious = 1 on transfers
+	// of tokens that revert them (see flagSuspicious). Off by default; the
+	// provider must implement eth.ContractCaller.
+	HoneypotGuard bool
+	// PriceFeed, when set, annotates token transfers with value_usd, their
+	// amount priced at the block timestamp (see PriceFeed). Nil, the default,
+	// leaves value_usd unset.
+	PriceFeed PriceFeed
+	// KafkaBrokers, when set, also publishes every normalized row to Kafka
+	// through these REST proxy URLs (see KafkaSink), as a JSON message keyed
+	// by the address. KafkaProducer, when set, replaces the REST producer,
+	// e.g. with a native client. KafkaTopic names each table's topic
+	// ("{table}" is substituted; empty = DefaultKafkaTopic).
+	KafkaBrokers  []string
+	KafkaProducer KafkaProducer
+	KafkaTopic    string
+	// TimestampCacheSize bounds the in-process block timestamp cache, which
+	// evicts least recently used blocks past it (0 = DefaultTimestampCacheSize).
+	TimestampCacheSize int
+	// ColumnNames renames columns of canonical rows as they are written, for
+	// tables whose schema predates this ingester: "column" applies to every
+	// canonical table and "table.column" to one, taking precedence (see
+	// ParseColumnNames). Queries the ingester runs against these tables
+	// (finality promotion, pruning) still use the default names.
+	ColumnNames map[string]string
+	// SortKeys overrides, per canonical table, the columns its rows are
+	// sorted by before each insert (see ParseSortKeys). Tables not listed
+	// use their ClickHouse sorting key; an empty key keeps fetch order.
+	// Columns are named as the ingester writes them, before ColumnNames.
+	SortKeys map[string][]string
+	// TimestampPrecision formats the ts and ingested_at/updated_at columns
+	// of canonical rows for the target column type: "s" for DateTime, "ms"
+	// (the default) for DateTime64(3) and "us" for DateTime64(6). Block
+	// timestamps are whole seconds, so only the version stamps lose detail at
+	// "s": rows rewritten within the same second tie, and ReplacingMergeTree
+	// then keeps the last inserted. The ingester's own tables keep ms.
+	TimestampPrecision string
+	// RecordProviderSource stamps canonical rows with source_provider, the
+	// label of the endpoint that served their range (eth.ProviderLabeler),
+	// to trace data-quality issues back to a provider. Off by default.
+	RecordProviderSource bool
+	// ForceFromBlock, when set, makes Backfill start at this block whatever
+	// the stored checkpoint says, unlike FromBlock, which a checkpoint past
+	// it overrides. The checkpoint is rewound to just below the block and
+	// advances as the run progresses, and coverage intervals recorded by
+	// earlier runs are not skipped. Stored rows are left in place.
+	ForceFromBlock *uint64
+
+	// StrictValidate checks normalized rows before they are written:
+	// addresses must be lowercase 0x-prefixed 20-byte hex, hashes 32-byte
+	// hex, and required ones non-empty. The first offending row fails the
+	// insert with an error wrapping ErrInvalidRow.
+	StrictValidate bool
+
+	// TrackPending makes each Delta read the provider's mempool
+	// (txpool_content) and keep PendingTable in step: transactions touching
+	// the address are written with pending = 1 while they wait, then
+	// rewritten with pending = 0 and mined_block once they leave it.
+	// Requires ClickHouse; providers without txpool_content log
+	// pending_unavailable once and are skipped.
+	TrackPending bool
+
+	// CacheStandards reads the standard of each token contract through
+	// StandardsTable before decoding, so contracts classified by any earlier
+	// run skip the per-log heuristics where those are ambiguous, and stores
+	// the contracts a decode classifies for the first time. Requires
+	// ClickHouse.
+	CacheStandards bool
+
+	// AuditUndecoded counts, per contract and topic0, the fetched logs that
+	// match no known event and writes the counts to UndecodedTable when
+	// Backfill or Delta returns, to surface event types worth a decoder.
+	// Requires ClickHouse; a failed write is logged, not returned.
+	AuditUndecoded bool
+
+	// AddressEdges writes the directed edges between the address and its
+	// counterparties (sender, recipient, asset) seen in each range's
+	// transactions and token transfers to EdgesTable, with per-block
+	// interaction counts and value sums. Canonical schema only.
+	AddressEdges bool
+
+	// CheckpointEvery makes Backfill persist its checkpoint and log
+	// backfill_progress each time this many more blocks are ingested in
+	// full, so an interrupted multi-hour run resumes close to where it
+	// stopped. 0 checkpoints once, when the run ends.
+	CheckpointEvery uint64
+
+	// WALPath, when set, keeps a local write-ahead log of Backfill ranges
+	// in this file: each range is recorded as started before it is fetched
+	// and as done once written. A restarted Backfill skips the done ranges
+	// the checkpoint does not cover yet and retries those started but not
+	// done, even below the checkpoint. Records the checkpoint covers are
+	// dropped when a run ends. Each address needs its own file.
+	WALPath string
+
+	// HashRanges records, per confirmed range, a SHA-256 of its canonical
+	// rows (sorted, without insert-time columns) in RangeHashTable. When
+	// ClickHouse already holds a different hash for the same range, the
+	// change (a reorg or decoder change) is logged as range_hash_mismatch.
+	HashRanges bool
+
+	// SkipPrecompiles drops the transactions and traces whose to is a
+	// precompiled contract (0x01-0x09) instead of writing them with
+	// is_precompile = 1.
+	SkipPrecompiles bool
+
+	// SkipReceipts skips the Transactions fetch, whose per-transaction
+	// receipts dominate RPC cost, for runs that only need logs (token
+	// transfers, approvals) and traces. transactions then holds only
+	// internal transactions from traces, and sub_calls and permit approvals
+	// stay empty. Reconcile still fetches transactions for gas fees.
+	SkipReceipts bool
 }
 
+// ErrUpToDate is returned by Delta (with Options.ReportUpToDate) when the
+// checkpoint has already reached the safe head, so callers can tell "nothing
+// new" apart from a failure.
+var ErrUpToDate = errors.New("ingest: up to date")
+
+// ErrMaxBlocksReached is returned by Backfill and Delta when
+// Options.MaxBlocksPerRun stopped the run before the target block. The
+// checkpoint has been persisted; another run resumes from it.
+var ErrMaxBlocksReached = errors.New("ingest: max blocks per run reached")
+
 // Ingester coordinates fetching, normalization and persistence for a single
 // address. It is intentionally minimal for scaffolding.
 type Ingester struct {
@@ -62,10 +364,39 @@ type Ingester struct {
 	opts    Options
 	prov    eth.Provider
 	ch      *ch.Client
-	tsMu    sync.RWMutex
-	tsCache map[uint64]int64
+	sink    Sink // normalized rows; checkpoints always go to ch
+	batch   *batchSizer
+	tsCache *eth.TimestampCache
 	curMu   sync.RWMutex
 	cur     *addressCheckpoint // TODO: consider TTL-based invalidation for long-running processes.
+
+	probeOnce     sync.Once
+	noTraces      atomic.Bool
+	noBalances    atomic.Bool
+	noPending     atomic.Bool
+	discrepancies atomic.Int64
+	lastVersion   atomic.Int64  // millis of the latest ingested_at stamp
+	lockOwner     string        // identifies this ingester in run_locks
+	stage         *stagingSink  // set with Options.StagedCommit
+	fin           finalityHeads // refreshed per run with Options.TrackFinality
+	promoted      finalityHeads // heads promoteFinality last applied
+	cov           coverage      // block intervals ingested in full
+	standards     standardCache // Options.CacheStandards lookups
+	probes        tokenProbes   // Options.HoneypotGuard verdicts
+	undecoded     undecodedLogs // Options.AuditUndecoded counts for the run
+	prog          *progress     // Options.CheckpointEvery state for a Backfill
+	wal           *rangeWAL     // open during a Backfill with Options.WALPath
+	kafka         *KafkaSink    // set with Options.KafkaProducer/KafkaBrokers
+}
+
+// RunSummary reports run-level conditions worth surfacing after ingestion.
+type RunSummary struct {
+	// TracesUnavailable is set when the provider supports no trace API, so
+	// internal transactions and trace-derived contract creations are missing.
+	TracesUnavailable bool
+	// BalanceDiscrepancies counts reconciled ranges whose net value flow did
+	// not match the balance delta (Options.Reconcile).
+	BalanceDiscrepancies int64
 }
 
 func New(address string, opts Options) *Ingester {
@@ -74,13 +405,22 @@ func New(address string, opts Options) *Ingester {
 	if addr != "" && !addressPattern.MatchString(addr) {
 		panic(fmt.Sprintf("invalid address %q", address))
 	}
-	var c *ch.Client
-	if opts.ClickHouseDSN != "" {
-		c = ch.New(opts.ClickHouseDSN)
-	} else {
-		c = ch.New("")
+	return newIngester(addr, opts, nil)
+}
+
+// NewValidated is New for library callers: it requires a full 0x-prefixed
+// 20-byte address and a valid schema/table selection, returning an error
+// instead of panicking.
+func NewValidated(address string, opts Options) (*Ingester, error) {
+	addr := strings.ToLower(strings.TrimSpace(address))
+	if !fullAddressPattern.MatchString(addr) {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return nil, err
 	}
-	return &Ingester{address: addr, opts: opts, ch: c, tsCache: make(map[uint64]int64)}
+	return newIngester(addr, opts, nil), nil
 }
 
 // NewWithProvider injects a concrete eth.Provider (already wrapped with
@@ -91,13 +431,48 @@ func NewWithProvider(address string, opts Options, p eth.Provider) *Ingester {
 	if addr != "" && !addressPattern.MatchString(addr) {
 		panic(fmt.Sprintf("invalid address %q", address))
 	}
+	return newIngester(addr, opts, p)
+}
+
+// newIngester wires an Ingester for an already validated address and options.
+func newIngester(addr string, opts Options, p eth.Provider) *Ingester {
 	var c *ch.Client
 	if opts.ClickHouseDSN != "" {
 		c = ch.New(opts.ClickHouseDSN)
 	} else {
 		c = ch.New("")
 	}
-	return &Ingester{address: addr, opts: opts, prov: p, ch: c, tsCache: make(map[uint64]int64)}
+	c.SetCompression(opts.ClickHouseCompression)
+	c.SetPool(opts.ClickHousePool)
+	c.SetTimeouts(opts.QueryTimeout, opts.InsertTimeout)
+	c.SetUserAgent(opts.UserAgent)
+	c.SetMaxResponseBytes(opts.MaxResponseBytes)
+	i := &Ingester{address: addr, opts: opts, prov: p, ch: c, batch: newBatchSizer(opts), tsCache: newTsCache(opts.TimestampCacheSize), lockOwner: newLockOwner()}
+	if opts.KafkaProducer != nil {
+		i.kafka = NewKafkaSink(opts.KafkaProducer, opts.KafkaTopic, addr)
+	}
+	if opts.StagedCommit && c.Enabled() {
+		i.stage = newStagingSink(c, addr)
+		i.sink = newSink(i.stage, opts, i.kafka)
+	} else {
+		i.sink = newSink(c, opts, i.kafka)
+	}
+	if opts.NoFinality {
+		if logger := logging.Logger(); logger != nil {
+			logger.Warn("no_finality",
+				"component", "ingest",
+				"address", addr,
+				"ignored_confirmations", opts.Confirmations,
+			)
+		}
+	}
+	for _, t := range opts.RowBinaryTables {
+		_ = c.SetRowBinary(t, nil)
+		if i.stage != nil {
+			_ = c.SetRowBinary(i.stage.prefix+t, nil)
+		}
+	}
+	return i
 }
 
 var timeNow = time.Now
@@ -114,16 +489,47 @@ func (i *Ingester) Backfill(ctx context.Context) error {
 	if i.prov == nil {
 		return nil
 	}
+	unlock, err := i.acquireRunLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	defer i.flushUndecoded(ctx)
+	if err := i.recoverStaged(ctx); err != nil {
+		return err
+	}
 	head, err := i.prov.BlockNumber(ctx)
 	if err != nil {
 		return err
 	}
+	if err := i.refreshFinality(ctx); err != nil {
+		return err
+	}
 	ckpt, existed, err := i.loadCheckpoint(ctx)
 	if err != nil {
 		return err
 	}
+	if err := i.loadCoverage(ctx, ckpt, existed); err != nil {
+		return err
+	}
+	var incomplete []eth.BlockRange
+	if i.opts.WALPath != "" {
+		if incomplete, err = i.replayWAL(ckpt, existed); err != nil {
+			return err
+		}
+		defer func() { i.wal.close(); i.wal = nil }()
+	}
 	from := i.opts.FromBlock
-	if existed && from <= ckpt.LastSyncedBlock {
+	if force := i.opts.ForceFromBlock; force != nil {
+		from = *force
+		if existed {
+			ckpt.LastSyncedBlock = 0
+			if from > 0 {
+				ckpt.LastSyncedBlock = from - 1
+			}
+		}
+		i.cov.covered = nil
+	} else if existed && from <= ckpt.LastSyncedBlock {
 		if ckpt.LastSyncedBlock == math.MaxUint64 {
 			return fmt.Errorf("address %s last_synced_block at max value", i.address)
 		}
@@ -133,6 +539,9 @@ func (i *Ingester) Backfill(ctx context.Context) error {
 	if to == 0 {
 		to = head
 	}
+	if err := i.processUnconfirmed(ctx, head); err != nil {
+		return err
+	}
 	safeHead, hasSafe := i.safeHead(head)
 	if !hasSafe {
 		if existed {
@@ -140,6 +549,12 @@ func (i *Ingester) Backfill(ctx context.Context) error {
 		}
 		return nil
 	}
+	i.noteSafeHead(safeHead)
+	if existed && i.opts.ForceFromBlock == nil {
+		if err := i.retryIncomplete(ctx, incomplete, ckpt.LastSyncedBlock, rangeState{checkpoint: checkpointBackfill, head: head}); err != nil {
+			return err
+		}
+	}
 	if to > safeHead {
 		to = safeHead
 	}
@@ -149,27 +564,37 @@ func (i *Ingester) Backfill(ctx context.Context) error {
 		}
 		return nil
 	}
-	batch := uint64(i.opts.BatchBlocks)
-	if batch == 0 {
-		batch = DefaultBatchBlocks
+	to, capped := i.capBlocks(from, to)
+	i.startProgress(ckpt, from, to)
+	defer func() { i.prog = nil }()
+	// An exhausted RPC budget stops the run like MaxBlocksPerRun: the ranges
+	// completed so far are checkpointed before the error is returned.
+	covErr := i.processCovering(ctx, from, to, rangeState{checkpoint: checkpointBackfill, head: head})
+	if covErr != nil && !errors.Is(covErr, eth.ErrBudgetExceeded) {
+		return covErr
+	}
+	lastProcessed, processed := i.cov.frontier()
+	if err := i.finalizeBackfill(ctx, ckpt, existed, processed, lastProcessed); err != nil {
+		return err
 	}
-	var (
-		lastProcessed uint64
-		processed     bool
-	)
-	for cur := from; cur <= to; {
-		end := cur + batch - 1
-		if end > to {
-			end = to
-		}
-		if err := i.processRange(ctx, cur, end); err != nil {
+	if processed && lastProcessed > ckpt.LastSyncedBlock {
+		ckpt.LastSyncedBlock = lastProcessed
+	}
+	if err := i.persistCoverage(ctx, ckpt.LastSyncedBlock, existed || processed); err != nil {
+		return err
+	}
+	if i.wal != nil && (existed || processed) {
+		if err := i.wal.compact(ckpt.LastSyncedBlock); err != nil {
 			return err
 		}
-		processed = true
-		lastProcessed = end
-		cur = end + 1
 	}
-	return i.finalizeBackfill(ctx, ckpt, existed, processed, lastProcessed)
+	if covErr != nil {
+		return covErr
+	}
+	if capped {
+		return ErrMaxBlocksReached
+	}
+	return nil
 }
 
 // Delta performs a recent delta update with N confirmations.
@@ -177,31 +602,57 @@ func (i *Ingester) Delta(ctx context.Context) error {
 	if i.prov == nil {
 		return nil
 	}
+	unlock, err := i.acquireRunLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	defer i.flushUndecoded(ctx)
+	if err := i.recoverStaged(ctx); err != nil {
+		return err
+	}
 	head, err := i.prov.BlockNumber(ctx)
 	if err != nil {
 		return err
 	}
+	if err := i.refreshFinality(ctx); err != nil {
+		return err
+	}
+	if err := i.promoteFinality(ctx); err != nil {
+		return err
+	}
 	ckpt, existed, err := i.loadCheckpoint(ctx)
 	if err != nil {
 		return err
 	}
+	if err := i.loadCoverage(ctx, ckpt, existed); err != nil {
+		return err
+	}
+	if err := i.processUnconfirmed(ctx, head); err != nil {
+		return err
+	}
+	if err := i.syncPending(ctx); err != nil {
+		return err
+	}
 	safeHead, hasSafe := i.safeHead(head)
 	to := i.opts.ToBlock
 	if to == 0 || to > safeHead {
 		to = safeHead
 	}
 	if !hasSafe {
-		if existed {
-			return i.persistCheckpoint(ctx, ckpt, checkpointDelta, ckpt.LastSyncedBlock)
-		}
-		return nil
+		return i.upToDate(ctx, ckpt, existed)
+	}
+	i.noteSafeHead(safeHead)
+	// No new safe blocks. Checked before the reorg-window rewind below, which
+	// otherwise always leaves a range to rescan once Confirmations is set.
+	if existed && ckpt.LastSyncedBlock == safeHead && safeHead < math.MaxUint64 {
+		return i.upToDate(ctx, ckpt, existed)
 	}
 	if ckpt.LastSyncedBlock > safeHead {
 		ckpt.LastSyncedBlock = safeHead
 	}
 	from := i.opts.FromBlock
-	if i.opts.Confirmations > 0 {
-		conf := uint64(i.opts.Confirmations)
+	if conf := i.confirmations(); conf > 0 {
 		var reorgStart uint64
 		if ckpt.LastSyncedBlock+1 > conf {
 			reorgStart = ckpt.LastSyncedBlock + 1 - conf
@@ -217,39 +668,100 @@ func (i *Ingester) Delta(ctx context.Context) error {
 		}
 		from = ckpt.LastSyncedBlock + 1
 	}
-	if i.opts.Confirmations > 0 {
+	if i.confirmations() > 0 {
 		i.pruneTimestampCache(from)
 	}
 	if from > to {
-		if existed {
-			return i.persistCheckpoint(ctx, ckpt, checkpointDelta, ckpt.LastSyncedBlock)
+		return i.upToDate(ctx, ckpt, existed)
+	}
+	capFrom := from
+	if existed && ckpt.LastSyncedBlock >= from && ckpt.LastSyncedBlock < math.MaxUint64 {
+		capFrom = ckpt.LastSyncedBlock + 1
+	}
+	var capped bool
+	if capFrom <= to {
+		var capTo uint64
+		capTo, capped = i.capBlocks(capFrom, to)
+		to = capTo
+	}
+	covErr := i.processCovering(ctx, from, to, rangeState{checkpoint: checkpointDelta, head: head})
+	if covErr != nil && !errors.Is(covErr, eth.ErrBudgetExceeded) {
+		return covErr
+	}
+	if lastProcessed, processed := i.cov.frontier(); processed && lastProcessed > ckpt.LastSyncedBlock {
+		ckpt.LastSyncedBlock = lastProcessed
+	}
+	if err := i.persistCheckpoint(ctx, ckpt, checkpointDelta, ckpt.LastSyncedBlock); err != nil {
+		return err
+	}
+	if err := i.persistCoverage(ctx, ckpt.LastSyncedBlock, true); err != nil {
+		return err
+	}
+	if covErr != nil {
+		return covErr
+	}
+	if capped {
+		return ErrMaxBlocksReached
+	}
+	return nil
+}
+
+// capBlocks limits [from, to] to Options.MaxBlocksPerRun blocks and reports
+// whether the range was cut short.
+func (i *Ingester) capBlocks(from, to uint64) (uint64, bool) {
+	max := i.opts.MaxBlocksPerRun
+	if max == 0 || to-from < max {
+		return to, false
+	}
+	return from + max - 1, true
+}
+
+// upToDate refreshes the delta checkpoint timestamp when there is nothing new
+// to process and reports ErrUpToDate if the caller asked for it.
+func (i *Ingester) upToDate(ctx context.Context, ckpt addressCheckpoint, existed bool) error {
+	if existed {
+		if err := i.persistCheckpoint(ctx, ckpt, checkpointDelta, ckpt.LastSyncedBlock); err != nil {
+			return err
 		}
+	}
+	if i.opts.ReportUpToDate {
+		return ErrUpToDate
+	}
+	return nil
+}
+
+// processUnconfirmed ingests the blocks between the safe head and head when
+// IncludeUnconfirmed is set, honoring FromBlock/ToBlock bounds. Rows are
+// flagged unconfirmed and no checkpoint is written for this window.
+func (i *Ingester) processUnconfirmed(ctx context.Context, head uint64) error {
+	if !i.opts.IncludeUnconfirmed || i.SchemaMode() != "canonical" {
 		return nil
 	}
-	batch := uint64(i.opts.BatchBlocks)
-	if batch == 0 {
-		batch = DefaultBatchBlocks
+	var from uint64
+	if safeHead, ok := i.safeHead(head); ok {
+		if safeHead == head {
+			return nil
+		}
+		from = safeHead + 1
+	}
+	if i.opts.FromBlock > from {
+		from = i.opts.FromBlock
+	}
+	to := head
+	if i.opts.ToBlock != 0 && i.opts.ToBlock < to {
+		to = i.opts.ToBlock
 	}
-	var (
-		lastProcessed uint64
-		processed     bool
-	)
 	for cur := from; cur <= to; {
-		rEnd := cur + batch - 1
-		if rEnd > to {
-			rEnd = to
-		}
-		if err := i.processRange(ctx, cur, rEnd); err != nil {
+		end, err := i.processNext(ctx, cur, to, rangeState{unconfirmed: true, head: head})
+		if err != nil {
 			return err
 		}
-		processed = true
-		lastProcessed = rEnd
-		cur = rEnd + 1
-	}
-	if processed && lastProcessed > ckpt.LastSyncedBlock {
-		ckpt.LastSyncedBlock = lastProcessed
+		if end == to {
+			break
+		}
+		cur = end + 1
 	}
-	return i.persistCheckpoint(ctx, ckpt, checkpointDelta, ckpt.LastSyncedBlock)
+	return nil
 }
 
 // finalizeBackfill consolidates checkpoint persistence logic once the
@@ -267,21 +779,153 @@ func (i *Ingester) finalizeBackfill(ctx context.Context, ckpt addressCheckpoint,
 	return i.persistCheckpoint(ctx, ckpt, checkpointBackfill, ckpt.LastSyncedBlock)
 }
 
+// processCovering processes [from, to] batch by batch, skipping the intervals
+// the coverage ledger shows an earlier run already ingested in full.
+func (i *Ingester) processCovering(ctx context.Context, from, to uint64, rs rangeState) error {
+	i.cov.start = from
+	for cur := from; cur <= to; {
+		if end, ok := i.cov.through(cur); ok {
+			if end >= to {
+				break
+			}
+			cur = end + 1
+			continue
+		}
+		end, err := i.processNext(ctx, cur, i.cov.limit(cur, to), rs)
+		if err != nil {
+			return err
+		}
+		if err := i.noteProgress(ctx); err != nil {
+			return err
+		}
+		cur = end + 1
+	}
+	return nil
+}
+
+// rangeState carries per-range attributes, most of them stamped onto every
+// canonical row.
+type rangeState struct {
+	// unconfirmed marks blocks above the safe head (IncludeUnconfirmed).
+	unconfirmed bool
+	// checkpoint is the checkpoint kind a staged batch commits with
+	// (Options.StagedCommit); empty ranges are never staged.
+	checkpoint string
+	// source is the provider label canonical rows record as source_provider
+	// (Options.RecordProviderSource), read once the range is fetched.
+	source string
+	// head is the chain head observed when the run started; canonical rows
+	// record head - block_number as confirmations. Zero leaves the column
+	// at its default.
+	head uint64
+	// hash collects the range's canonical rows for RangeHashTable
+	// (Options.HashRanges); nil when ranges are not hashed.
+	hash *rangeHasher
+}
+
+// ProcessRange fetches and writes the address's rows for blocks from..to
+// (inclusive) once, for callers running their own scheduler instead of
+// Backfill or Delta. It never reads or advances the checkpoint, takes no run
+// lock and applies no confirmation depth: the caller picks which blocks are
+// safe to ingest and records its own progress. Rows are written as
+// confirmed, without confirmations. The range is worked in batches like
+// Backfill, so MaxBatchItems and AdaptiveBatch apply. Re-processing a range
+// is idempotent: rewritten rows share their logical keys and supersede the
+// earlier copies on merge. Returns nil without a provider.
+func (i *Ingester) ProcessRange(ctx context.Context, from, to uint64) error {
+	if i.prov == nil {
+		return nil
+	}
+	if from > to {
+		return fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	for cur := from; ; {
+		end, err := i.processNext(ctx, cur, to, rangeState{})
+		if err != nil {
+			return err
+		}
+		if end >= to {
+			return nil
+		}
+		cur = end + 1
+	}
+}
+
 // processRange fetches logs and traces for the configured address and block range.
 func (i *Ingester) processRange(ctx context.Context, from, to uint64) error {
-	// Topics nil for now; later pass selectors for token transfers/approvals
-	logs, err := i.prov.GetLogs(ctx, i.address, from, to, nil)
-	if err != nil {
-		return fmt.Errorf("getting logs: %w", err)
+	return i.processRangeState(ctx, from, to, rangeState{})
+}
+
+// processRangeState is processRange with explicit per-range attributes.
+func (i *Ingester) processRangeState(ctx context.Context, from, to uint64, rs rangeState) error {
+	i.cov.missing = nil
+	reconcile := i.opts.Reconcile && !i.opts.ContractMode
+	wantLogs := i.wants("logs", "token_transfers", "approvals", "proxy_upgrades", "swaps", "vault_events")
+	verifyLogs := i.opts.VerifyLogs && wantLogs && !i.opts.ContractMode
+	needTraces := !i.opts.ContractMode && (i.wants("traces", "transactions", "contracts") || reconcile)
+	if needTraces {
+		i.probeCapabilities(ctx, from)
+	}
+	var (
+		logs   []eth.Log
+		traces []eth.Trace
+		txs    []eth.Transaction
+		err    error
+	)
+	var topics [][]string
+	if i.opts.ContractMode {
+		topics = [][]string{normalize.TransferTopics()}
+	}
+	if wantLogs {
+		logs, err = i.prov.GetLogs(ctx, i.address, from, to, topics)
+		if err != nil {
+			return &fetchError{fmt.Errorf("getting logs: %w", err)}
+		}
+	}
+	if needTraces && !i.noTraces.Load() {
+		traces, err = i.prov.TraceBlock(ctx, from, to, i.address)
+		if err == eth.ErrUnsupported {
+			i.markTracesUnavailable()
+		} else if err != nil {
+			return &fetchError{fmt.Errorf("tracing blocks: %w", err)}
+		}
+	}
+	wantTxs := !i.opts.SkipReceipts && (i.wants("transactions", "sub_calls", "contracts") || verifyLogs)
+	if !i.opts.ContractMode && (wantTxs || reconcile) {
+		txs, err = i.prov.Transactions(ctx, i.address, from, to)
+		if missing := eth.MissingBlocks(err); missing != nil {
+			i.cov.missing, err = missing, nil
+		}
+		if err != nil && err != eth.ErrUnsupported {
+			return &fetchError{fmt.Errorf("getting transactions: %w", err)}
+		}
+	}
+	// Withdrawals come last so the provider can reuse the blocks
+	// Transactions just walked. Reconciliation credits them too.
+	var withdrawals []eth.Withdrawal
+	if !i.opts.ContractMode && (i.SchemaMode() == "canonical" && i.wants("withdrawals") || reconcile) {
+		if wr, ok := i.prov.(eth.WithdrawalReader); ok {
+			withdrawals, err = wr.Withdrawals(ctx, i.address, from, to)
+			if err != nil && err != eth.ErrUnsupported {
+				return &fetchError{fmt.Errorf("getting withdrawals: %w", err)}
+			}
+		}
+	}
+	if verifyLogs && len(logs) == 0 && calledWithData(txs, i.address) {
+		if logs, err = i.refetchEmptyLogs(ctx, from, to, topics); err != nil {
+			return err
+		}
+	}
+	if err := i.batch.observe(from, to, len(logs)+len(traces)+len(txs)+len(withdrawals)); err != nil {
+		return err
 	}
-	traces, err := i.prov.TraceBlock(ctx, from, to, i.address)
-	if err != nil && err != eth.ErrUnsupported {
-		return fmt.Errorf("tracing blocks: %w", err)
+	if i.opts.RecordProviderSource {
+		rs.source = providerLabel(i.prov)
 	}
-	txs, err := i.prov.Transactions(ctx, i.address, from, to)
-	if err != nil && err != eth.ErrUnsupported {
-		return fmt.Errorf("getting transactions: %w", err)
+	if i.opts.Deterministic {
+		sortFetched(logs, traces, txs)
 	}
+	i.noteUndecoded(logs)
 	// Fill timestamps if missing using in-process cache + provider
 	for idx := range logs {
 		if logs[idx].TsMillis == 0 {
@@ -311,30 +955,46 @@ func (i *Ingester) processRange(ctx context.Context, from, to uint64) error {
 	if len(internalTxRows) > 0 {
 		txRows = append(txRows, internalTxRows...)
 	}
+	trows := normalize.TracesToRows(traces)
+	if i.opts.SkipPrecompiles {
+		txRows = slices.DeleteFunc(txRows, func(r normalize.TransactionRow) bool { return r.IsPrecompile == 1 })
+		trows = slices.DeleteFunc(trows, func(r normalize.TraceRow) bool { return r.IsPrecompile == 1 })
+	}
 	if mode == "canonical" {
+		// Unconfirmed blocks may still change, so only confirmed ranges are hashed.
+		if i.opts.HashRanges && !rs.unconfirmed {
+			rs.hash = &rangeHasher{}
+		}
 		// Logs
 		lrows := normalize.LogsToRows(logs)
-		if len(lrows) > 0 {
-			rows := make([]any, 0, len(lrows))
-			for _, r := range lrows {
-				rows = append(rows, map[string]any{
-					"event_uid":    r.EventUID,
-					"tx_hash":      r.TxHash,
-					"log_index":    r.LogIndex,
-					"address":      r.Address,
-					"topics":       r.Topics,
-					"data_hex":     r.DataHex,
-					"block_number": r.BlockNum,
-					"ts":           fmtDT64(r.TsMillis),
-				})
+		rowsLogs := make([]map[string]any, 0, len(lrows))
+		for _, r := range lrows {
+			row := map[string]any{
+				"event_uid":    r.EventUID,
+				"tx_hash":      r.TxHash,
+				"log_index":    r.LogIndex,
+				"address":      r.Address,
+				"topics":       r.Topics,
+				"data_hex":     r.DataHex,
+				"block_number": r.BlockNum,
+				"ts":           i.rowTs(r.TsMillis),
 			}
-			if err := i.ch.InsertJSONEachRow(ctx, "logs", rows); err != nil {
-				return fmt.Errorf("inserting logs: %w", err)
+			if len(r.HashedTopics) > 0 {
+				row["hashed_topics"] = r.HashedTopics
 			}
+			rowsLogs = append(rowsLogs, row)
+		}
+		if err := i.insertCanonical(ctx, "logs", rowsLogs, rs); err != nil {
+			return err
 		}
 		// Token events
-		tTransfers, tApprovals := normalize.DecodeTokenEvents(logs)
-		rowsTransfers := make([]any, 0, len(tTransfers))
+		tTransfers, tApprovals, err := i.decodeTokenEvents(ctx, logs)
+		if err != nil {
+			return err
+		}
+		i.priceTransfers(tTransfers)
+		i.flagSuspicious(ctx, tTransfers)
+		rowsTransfers := make([]map[string]any, 0, len(tTransfers))
 		for _, r := range tTransfers {
 			rowsTransfers = append(rowsTransfers, map[string]any{
 				"event_uid":     r.EventUID,
@@ -347,16 +1007,31 @@ func (i *Ingester) processRange(ctx context.Context, from, to uint64) error {
 				"token_id":      r.TokenID,
 				"batch_ordinal": r.BatchOrd,
 				"standard":      r.Standard,
+				"is_mint":       r.IsMint,
+				"is_burn":       r.IsBurn,
+				"non_standard":  r.NonStandard,
+				"suspicious":    r.Suspicious,
 				"block_number":  r.BlockNum,
-				"ts":            fmtDT64(r.TsMillis),
+				"ts":            i.rowTs(r.TsMillis),
 			})
+			if r.ValueUSD != "" {
+				rowsTransfers[len(rowsTransfers)-1]["value_usd"] = r.ValueUSD
+			}
 		}
-		if err := i.ch.InsertJSONEachRow(ctx, "token_transfers", rowsTransfers); err != nil {
-			return fmt.Errorf("inserting token_transfers: %w", err)
+		if err := i.insertCanonical(ctx, "token_transfers", rowsTransfers, rs); err != nil {
+			return err
 		}
 
-		rowsApprovals := make([]any, 0, len(tApprovals))
+		// Gasless permit approvals, unless the token also logged them.
+		tApprovals = append(tApprovals, permitsFor(txs, tApprovals, i.address)...)
+		rowsApprovals := make([]map[string]any, 0, len(tApprovals))
 		for _, r := range tApprovals {
+			// Set even for logged approvals: RowBinary inserts write an
+			// omitted column's zero value, not its DEFAULT.
+			source := r.Source
+			if source == "" {
+				source = normalize.EventSource
+			}
 			rowsApprovals = append(rowsApprovals, map[string]any{
 				"event_uid":           r.EventUID,
 				"tx_hash":             r.TxHash,
@@ -368,18 +1043,88 @@ func (i *Ingester) processRange(ctx context.Context, from, to uint64) error {
 				"token_id":            r.TokenID,
 				"is_approval_for_all": r.IsForAll,
 				"standard":            r.Standard,
+				"source":              source,
 				"block_number":        r.BlockNum,
-				"ts":                  fmtDT64(r.TsMillis),
+				"ts":                  i.rowTs(r.TsMillis),
+			})
+		}
+		if err := i.insertCanonical(ctx, "approvals", rowsApprovals, rs); err != nil {
+			return err
+		}
+		upgrades := normalize.DecodeProxyUpgrades(logs)
+		rowsUpgrades := make([]map[string]any, 0, len(upgrades))
+		for _, r := range upgrades {
+			rowsUpgrades = append(rowsUpgrades, map[string]any{
+				"event_uid":      r.EventUID,
+				"tx_hash":        r.TxHash,
+				"log_index":      r.LogIndex,
+				"proxy":          r.Proxy,
+				"implementation": r.Implementation,
+				"block_number":   r.BlockNum,
+				"ts":             i.rowTs(r.TsMillis),
 			})
 		}
-		if err := i.ch.InsertJSONEachRow(ctx, "approvals", rowsApprovals); err != nil {
-			return fmt.Errorf("inserting approvals: %w", err)
+		if err := i.insertCanonical(ctx, "proxy_upgrades", rowsUpgrades, rs); err != nil {
+			return err
+		}
+		swaps := normalize.DecodeSwaps(logs)
+		rowsSwaps := make([]map[string]any, 0, len(swaps))
+		for _, r := range swaps {
+			rowsSwaps = append(rowsSwaps, map[string]any{
+				"event_uid":      r.EventUID,
+				"tx_hash":        r.TxHash,
+				"log_index":      r.LogIndex,
+				"pool":           r.Pool,
+				"version":        r.Version,
+				"sender":         r.Sender,
+				"recipient":      r.Recipient,
+				"amount0":        r.Amount0,
+				"amount1":        r.Amount1,
+				"sqrt_price_x96": r.SqrtPriceX96,
+				"liquidity":      r.Liquidity,
+				"tick":           r.Tick,
+				"block_number":   r.BlockNum,
+				"ts":             i.rowTs(r.TsMillis),
+			})
 		}
+		if err := i.insertCanonical(ctx, "swaps", rowsSwaps, rs); err != nil {
+			return err
+		}
+		vaultEvents := normalize.DecodeVaultEvents(logs)
+		rowsVault := make([]map[string]any, 0, len(vaultEvents))
+		for _, r := range vaultEvents {
+			rowsVault = append(rowsVault, map[string]any{
+				"event_uid":    r.EventUID,
+				"tx_hash":      r.TxHash,
+				"log_index":    r.LogIndex,
+				"vault":        r.Vault,
+				"action":       r.Action,
+				"sender":       r.Sender,
+				"owner":        r.Owner,
+				"receiver":     r.Receiver,
+				"assets":       r.Assets,
+				"shares":       r.Shares,
+				"block_number": r.BlockNum,
+				"ts":           i.rowTs(r.TsMillis),
+			})
+		}
+		if err := i.insertCanonical(ctx, "vault_events", rowsVault, rs); err != nil {
+			return err
+		}
+		// Contract metadata is not block-scoped, so creations seen in
+		// unconfirmed blocks wait until they are confirmed.
 		contractCreations := collectContractCreations(txs, traces, i.address)
-		if len(contractCreations) > 0 {
+		if len(contractCreations) > 0 && !rs.unconfirmed && i.wants("contracts") {
+			contractCreations, err = i.unstoredCreations(ctx, contractCreations)
+			if err != nil {
+				return err
+			}
+		}
+		if len(contractCreations) > 0 && !rs.unconfirmed && i.wants("contracts") {
+			version := i.rowTs(i.versionMillis())
 			rowsContracts := make([]any, 0, len(contractCreations))
 			for _, creation := range contractCreations {
-				rowsContracts = append(rowsContracts, map[string]any{
+				row := map[string]any{
 					"address":          creation.address,
 					"is_contract":      uint8(1),
 					"name":             "",
@@ -387,111 +1132,223 @@ func (i *Ingester) processRange(ctx context.Context, from, to uint64) error {
 					"decimals":         0,
 					"created_at_tx":    creation.txHash,
 					"first_seen_block": creation.blockNumber,
-				})
+					"implementation":   i.proxyImplementation(ctx, creation.address, creation.blockNumber),
+					"updated_at":       version,
+				}
+				if rs.source != "" {
+					row["source_provider"] = rs.source
+				}
+				rowsContracts = append(rowsContracts, i.renameColumns("contracts", row))
 			}
-			if err := i.ch.InsertJSONEachRow(ctx, "contracts", rowsContracts); err != nil {
+			if err := i.sink.InsertJSONEachRow(ctx, "contracts", rowsContracts); err != nil {
 				return fmt.Errorf("inserting contracts: %w", err)
 			}
 		}
-		if len(txRows) > 0 {
-			rowsTx := make([]any, 0, len(txRows))
-			for _, r := range txRows {
-				row := map[string]any{
-					"tx_hash":      r.TxHash,
-					"block_number": r.BlockNum,
-					"ts":           fmtDT64(r.TsMillis),
-					"from_addr":    r.From,
-					"to_addr":      r.To,
-					"value_raw":    r.ValueRaw,
-					"gas_used":     r.GasUsed,
-					"status":       r.Status,
-					"is_internal":  r.IsInternal,
-					"trace_id":     nil,
-					"input_method": nil,
-				}
-				if r.TraceID != "" {
-					row["trace_id"] = r.TraceID
-				}
-				if r.InputMethod != "" {
-					row["input_method"] = r.InputMethod
-				}
-				rowsTx = append(rowsTx, row)
+		rowsTx := make([]map[string]any, 0, len(txRows))
+		for _, r := range txRows {
+			row := map[string]any{
+				"tx_hash":                  r.TxHash,
+				"block_number":             r.BlockNum,
+				"ts":                       i.rowTs(r.TsMillis),
+				"from_addr":                r.From,
+				"to_addr":                  r.To,
+				"value_raw":                r.ValueRaw,
+				"gas_used":                 r.GasUsed,
+				"gas_price_raw":            r.GasPriceRaw,
+				"status":                   r.Status,
+				"receipt_missing":          r.ReceiptMissing,
+				"is_internal":              r.IsInternal,
+				"is_precompile":            r.IsPrecompile,
+				"trace_id":                 nil,
+				"input_method":             nil,
+				"input_kind":               r.InputKind,
+				"init_code_hash":           nil,
+				"created_contract":         r.CreatedContract,
+				"access_list_count":        r.AccessListCount,
+				"access_list_storage_keys": r.AccessListKeys,
+			}
+			if r.TraceID != "" {
+				row["trace_id"] = r.TraceID
+			}
+			if r.InputMethod != "" {
+				row["input_method"] = r.InputMethod
+			}
+			if r.InitCodeHash != "" {
+				row["init_code_hash"] = r.InitCodeHash
+			}
+			rowsTx = append(rowsTx, row)
+		}
+		if err := i.insertCanonical(ctx, "transactions", rowsTx, rs); err != nil {
+			return err
+		}
+		if err := i.writeEdges(ctx, txRows, tTransfers, rs); err != nil {
+			return err
+		}
+		// Multicall batches sent by or to the address, split into inner calls.
+		subCalls := normalize.DecodeSubCalls(externalTransactionsFor(txs, i.address))
+		rowsSubCalls := make([]map[string]any, 0, len(subCalls))
+		for _, r := range subCalls {
+			row := map[string]any{
+				"tx_hash":       r.TxHash,
+				"call_index":    r.CallIndex,
+				"depth":         r.Depth,
+				"from_addr":     r.From,
+				"multicall":     r.Multicall,
+				"target":        r.Target,
+				"input_method":  nil,
+				"allow_failure": r.AllowFailure,
+				"value_raw":     r.ValueRaw,
+				"block_number":  r.BlockNum,
+				"ts":            i.rowTs(r.TsMillis),
 			}
-			if err := i.ch.InsertJSONEachRow(ctx, "transactions", rowsTx); err != nil {
-				return fmt.Errorf("inserting transactions: %w", err)
+			if r.InputMethod != "" {
+				row["input_method"] = r.InputMethod
 			}
+			rowsSubCalls = append(rowsSubCalls, row)
+		}
+		if err := i.insertCanonical(ctx, "sub_calls", rowsSubCalls, rs); err != nil {
+			return err
 		}
 
-		trows := normalize.TracesToRows(traces)
-		rowsTraces := make([]any, 0, len(trows))
+		rowsTraces := make([]map[string]any, 0, len(trows))
 		for _, r := range trows {
 			rowsTraces = append(rowsTraces, map[string]any{
-				"trace_uid":    r.TraceUID,
-				"tx_hash":      r.TxHash,
-				"trace_id":     r.TraceID,
-				"from_addr":    r.From,
-				"to_addr":      r.To,
-				"value_raw":    r.ValueRaw,
-				"block_number": r.BlockNum,
-				"ts":           fmtDT64(r.TsMillis),
+				"trace_uid":     r.TraceUID,
+				"tx_hash":       r.TxHash,
+				"trace_id":      r.TraceID,
+				"from_addr":     r.From,
+				"to_addr":       r.To,
+				"value_raw":     r.ValueRaw,
+				"block_number":  r.BlockNum,
+				"ts":            i.rowTs(r.TsMillis),
+				"is_precompile": r.IsPrecompile,
 			})
 		}
-		if err := i.ch.InsertJSONEachRow(ctx, "traces", rowsTraces); err != nil {
-			return fmt.Errorf("inserting traces: %w", err)
+		if err := i.insertCanonical(ctx, "traces", rowsTraces, rs); err != nil {
+			return err
+		}
+		// EIP-4895 withdrawals paid to the address.
+		wrows := normalize.WithdrawalsToRows(withdrawals)
+		rowsWithdrawals := make([]map[string]any, 0, len(wrows))
+		for _, r := range wrows {
+			rowsWithdrawals = append(rowsWithdrawals, map[string]any{
+				"withdrawal_index": r.Index,
+				"validator_index":  r.ValidatorIndex,
+				"address":          r.Address,
+				"amount_gwei":      r.AmountGwei,
+				"amount_raw":       r.AmountRaw,
+				"block_number":     r.BlockNum,
+				"ts":               i.rowTs(r.TsMillis),
+			})
+		}
+		if err := i.insertCanonical(ctx, "withdrawals", rowsWithdrawals, rs); err != nil {
+			return err
 		}
 	} else {
 		// dev schema (existing behavior)
 		lrows := normalize.LogsToRows(logs)
-		if err := i.ch.InsertJSONEachRow(ctx, "dev_logs", normalize.AsAny(lrows)); err != nil {
+		if err := i.sink.InsertJSONEachRow(ctx, "dev_logs", normalize.AsAny(lrows)); err != nil {
 			return fmt.Errorf("inserting dev_logs: %w", err)
 		}
-		tTransfers, tApprovals := normalize.DecodeTokenEvents(logs)
-		if err := i.ch.InsertJSONEachRow(ctx, "dev_token_transfers", normalize.AsAny(tTransfers)); err != nil {
+		tTransfers, tApprovals, err := i.decodeTokenEvents(ctx, logs)
+		if err != nil {
+			return err
+		}
+		i.priceTransfers(tTransfers)
+		i.flagSuspicious(ctx, tTransfers)
+		if err := i.sink.InsertJSONEachRow(ctx, "dev_token_transfers", normalize.AsAny(tTransfers)); err != nil {
 			return fmt.Errorf("inserting dev_token_transfers: %w", err)
 		}
-		if err := i.ch.InsertJSONEachRow(ctx, "dev_approvals", normalize.AsAny(tApprovals)); err != nil {
+		if err := i.sink.InsertJSONEachRow(ctx, "dev_approvals", normalize.AsAny(tApprovals)); err != nil {
 			return fmt.Errorf("inserting dev_approvals: %w", err)
 		}
 		if len(txRows) > 0 {
-			if err := i.ch.InsertJSONEachRow(ctx, "dev_transactions", normalize.AsAny(txRows)); err != nil {
+			if err := i.sink.InsertJSONEachRow(ctx, "dev_transactions", normalize.AsAny(txRows)); err != nil {
 				return fmt.Errorf("inserting dev_transactions: %w", err)
 			}
 		}
 		if traces != nil {
-			trows := normalize.TracesToRows(traces)
-			if err := i.ch.InsertJSONEachRow(ctx, "dev_traces", normalize.AsAny(trows)); err != nil {
+			if err := i.sink.InsertJSONEachRow(ctx, "dev_traces", normalize.AsAny(trows)); err != nil {
 				return fmt.Errorf("inserting dev_traces: %w", err)
 			}
 		}
 	}
+	if rs.hash != nil {
+		if err := i.recordRangeHash(ctx, from, to, rs.hash); err != nil {
+			return err
+		}
+	}
+	if reconcile {
+		return i.reconcileRange(ctx, from, to, txs, traces, withdrawals, rs)
+	}
+	return nil
+}
+
+// insertCanonical stamps range attributes and the ingested_at version onto
+// canonical rows and writes them to table. Empty inputs and tables excluded by
+// Options.Tables are skipped.
+func (i *Ingester) insertCanonical(ctx context.Context, table string, rows []map[string]any, rs rangeState) error {
+	if len(rows) == 0 || !i.wants(table) {
+		return nil
+	}
+	if rs.hash != nil {
+		rs.hash.add(table, rows)
+	}
+	i.sortRows(table, rows)
+	unconfirmed := uint8(0)
+	if rs.unconfirmed {
+		unconfirmed = 1
+	}
+	version := i.rowTs(i.versionMillis())
+	out := make([]any, 0, len(rows))
+	for _, row := range rows {
+		row["unconfirmed"] = unconfirmed
+		block, _ := row["block_number"].(uint64)
+		if i.fin.known {
+			row["finality"] = i.fin.of(block)
+		}
+		if rs.head > 0 {
+			row["confirmations"] = confirmations(rs.head, block)
+		}
+		row["ingested_at"] = version
+		if rs.source != "" {
+			row["source_provider"] = rs.source
+		}
+		out = append(out, i.renameColumns(table, row))
+	}
+	if err := i.sink.InsertJSONEachRow(ctx, table, out); err != nil {
+		return fmt.Errorf("inserting %s: %w", table, err)
+	}
 	return nil
 }
 
+// confirmations is the depth of block below head, zero for blocks past it.
+func confirmations(head, block uint64) uint64 {
+	if block >= head {
+		return 0
+	}
+	return head - block
+}
+
+// providerLabel names the endpoint behind prov, or "" when it cannot.
+func providerLabel(prov eth.Provider) string {
+	if pl, ok := prov.(eth.ProviderLabeler); ok {
+		return pl.ProviderLabel()
+	}
+	return ""
+}
+
 // normalizeTransactionsForAddress converts provider transactions to canonical rows
 // and filters them for the target address with case-insensitive matching.
 func normalizeTransactionsForAddress(txs []eth.Transaction, target string) []normalize.TransactionRow {
-	if len(txs) == 0 {
-		return nil
-	}
-	rows := normalize.TransactionsToRows(txs, false)
-	return filterTransactionsByAddress(rows, target)
+	return normalizeMatching(txs, target, false)
 }
 
 func normalizeInternalTracesForAddress(traces []eth.Trace, target string) []normalize.TransactionRow {
 	if len(traces) == 0 {
 		return nil
 	}
-	nonRoot := 0
-	for _, tr := range traces {
-		if strings.EqualFold(tr.TraceID, "root") {
-			continue
-		}
-		nonRoot++
-	}
-	if nonRoot == 0 {
-		return nil
-	}
-	txs := make([]eth.Transaction, 0, nonRoot)
+	txs := make([]eth.Transaction, 0, len(traces))
 	for _, tr := range traces {
 		// Skip root traces as they represent the original transaction, not an internal call.
 		if strings.EqualFold(tr.TraceID, "root") {
@@ -508,22 +1365,86 @@ func normalizeInternalTracesForAddress(traces []eth.Trace, target string) []norm
 			TraceID:  tr.TraceID,
 		})
 	}
-	rows := normalize.TransactionsToRows(txs, true)
-	return filterTransactionsByAddress(rows, target)
+	return normalizeMatching(txs, target, true)
 }
 
-func filterTransactionsByAddress(rows []normalize.TransactionRow, target string) []normalize.TransactionRow {
-	if target == "" || len(rows) == 0 {
-		return rows
+// normalizeMatching keeps the rows normalize.NormalizeTransaction matches to target.
+func normalizeMatching(txs []eth.Transaction, target string, internal bool) []normalize.TransactionRow {
+	if len(txs) == 0 {
+		return nil
 	}
-	addr := strings.ToLower(target)
-	filtered := rows[:0]
-	for _, row := range rows {
-		if row.From == addr || row.To == addr {
-			filtered = append(filtered, row)
+	rows := make([]normalize.TransactionRow, 0, len(txs))
+	for _, tx := range txs {
+		if row, ok := normalize.NormalizeTransaction(tx, target, internal); ok {
+			rows = append(rows, row)
 		}
 	}
-	return filtered
+	return rows
+}
+
+// externalTransactionsFor keeps the transactions sent by or to target.
+func externalTransactionsFor(txs []eth.Transaction, target string) []eth.Transaction {
+	var out []eth.Transaction
+	for _, tx := range txs {
+		if strings.EqualFold(tx.From, target) || strings.EqualFold(tx.To, target) {
+			out = append(out, tx)
+		}
+	}
+	return out
+}
+
+// permitsFor decodes the permit approvals in txs that grant or receive an
+// allowance for target, skipping those the token also logged as an Approval
+// with the same owner and spender in the same transaction.
+func permitsFor(txs []eth.Transaction, logged []normalize.ApprovalRow, target string) []normalize.ApprovalRow {
+	type key struct{ tx, token, owner, spender string }
+	seen := make(map[key]bool, len(logged))
+	for _, a := range logged {
+		seen[key{strings.ToLower(a.TxHash), strings.ToLower(a.Token), strings.ToLower(a.Owner), strings.ToLower(a.Spender)}] = true
+	}
+	var out []normalize.ApprovalRow
+	for _, p := range normalize.DecodePermits(txs) {
+		if !strings.EqualFold(p.Owner, target) && !strings.EqualFold(p.Spender, target) {
+			continue
+		}
+		if seen[key{p.TxHash, p.Token, p.Owner, p.Spender}] {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// sortFetched orders provider results by block and then by their natural
+// keys, removing any ordering the provider (or its concurrency) introduced.
+func sortFetched(logs []eth.Log, traces []eth.Trace, txs []eth.Transaction) {
+	sort.SliceStable(logs, func(a, b int) bool {
+		if logs[a].BlockNum != logs[b].BlockNum {
+			return logs[a].BlockNum < logs[b].BlockNum
+		}
+		if logs[a].TxHash != logs[b].TxHash {
+			return logs[a].TxHash < logs[b].TxHash
+		}
+		return logs[a].Index < logs[b].Index
+	})
+	sort.SliceStable(traces, func(a, b int) bool {
+		if traces[a].BlockNum != traces[b].BlockNum {
+			return traces[a].BlockNum < traces[b].BlockNum
+		}
+		if traces[a].TxHash != traces[b].TxHash {
+			return traces[a].TxHash < traces[b].TxHash
+		}
+		return traces[a].TraceID < traces[b].TraceID
+	})
+	sort.SliceStable(txs, func(a, b int) bool {
+		if txs[a].BlockNum != txs[b].BlockNum {
+			return txs[a].BlockNum < txs[b].BlockNum
+		}
+		if txs[a].Hash != txs[b].Hash {
+			return txs[a].Hash < txs[b].Hash
+		}
+		return txs[a].TraceID < txs[b].TraceID
+	})
 }
 
 type contractCreation struct {
@@ -597,13 +1518,52 @@ func collectContractCreations(txs []eth.Transaction, traces []eth.Trace, target
 	return out
 }
 
+// unstoredCreations drops the creations contracts already holds unchanged
+// (same creation tx and block), such as those in a Delta's reorg rescan
+// window, so they are neither re-inserted nor re-probed for an
+// implementation.
+func (i *Ingester) unstoredCreations(ctx context.Context, creations []contractCreation) ([]contractCreation, error) {
+	if i.ch == nil || !i.ch.Enabled() {
+		return creations, nil
+	}
+	quoted := make([]string, 0, len(creations))
+	for _, c := range creations {
+		quoted = append(quoted, "'"+quoteCHString(c.address)+"'")
+	}
+	addrCol := i.columnName("contracts", "address")
+	txCol := i.columnName("contracts", "created_at_tx")
+	blockCol := i.columnName("contracts", "first_seen_block")
+	query := fmt.Sprintf("SELECT %s, %s, %s FROM contracts FINAL WHERE %s IN (%s) FORMAT JSONEachRow SETTINGS output_format_json_quote_64bit_integers = 0", addrCol, txCol, blockCol, addrCol, strings.Join(quoted, ", "))
+	rows, err := i.ch.QueryJSONEachRow(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("checking stored contracts: %w", err)
+	}
+	stored := make(map[contractCreation]bool, len(rows))
+	for _, raw := range rows {
+		var r map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, fmt.Errorf("decode contracts: %w", err)
+		}
+		var c contractCreation
+		if err := errors.Join(json.Unmarshal(r[addrCol], &c.address), json.Unmarshal(r[txCol], &c.txHash), json.Unmarshal(r[blockCol], &c.blockNumber)); err != nil {
+			return nil, fmt.Errorf("decode contracts: %w", err)
+		}
+		c.address, c.txHash = strings.ToLower(c.address), strings.ToLower(c.txHash)
+		stored[c] = true
+	}
+	out := creations[:0]
+	for _, c := range creations {
+		if !stored[c] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
 func (i *Ingester) getBlockTs(ctx context.Context, block uint64) (int64, bool) {
-	i.tsMu.RLock()
-	if ts, ok := i.tsCache[block]; ok {
-		i.tsMu.RUnlock()
+	if ts, ok := i.tsCache.Get(block); ok {
 		return ts, true
 	}
-	i.tsMu.RUnlock()
 	if i.prov == nil {
 		return 0, false
 	}
@@ -611,9 +1571,7 @@ func (i *Ingester) getBlockTs(ctx context.Context, block uint64) (int64, bool) {
 	if err != nil {
 		return 0, false
 	}
-	i.tsMu.Lock()
-	i.tsCache[block] = ts
-	i.tsMu.Unlock()
+	i.tsCache.Add(block, ts)
 	return ts, true
 }
 
@@ -622,37 +1580,98 @@ func (i *Ingester) getBlockTs(ctx context.Context, block uint64) (int64, bool) {
 // cached timestamps in that window ensures we refetch fresh values instead of
 // reusing potentially stale data from the replaced chain head.
 func (i *Ingester) pruneTimestampCache(from uint64) {
-	if from == 0 {
-		i.tsMu.Lock()
-		if len(i.tsCache) > 0 {
-			i.tsCache = make(map[uint64]int64)
-		}
-		i.tsMu.Unlock()
-		return
-	}
-	i.tsMu.Lock()
-	for block := range i.tsCache {
-		if block >= from {
-			delete(i.tsCache, block)
-		}
-	}
-	i.tsMu.Unlock()
+	i.tsCache.Prune(from)
 }
 
 // safeHead returns the highest block number that satisfies the configured
 // confirmation window. The second return value is false when the chain height
 // is still within that window and no block should be processed yet.
 func (i *Ingester) safeHead(head uint64) (uint64, bool) {
-	if i.opts.Confirmations <= 0 {
+	conf := i.confirmations()
+	if conf == 0 {
 		return head, true
 	}
-	conf := uint64(i.opts.Confirmations)
 	if head <= conf {
 		return 0, false
 	}
 	return head - conf, true
 }
 
+// confirmations returns the confirmation window in blocks: 0 when none is
+// configured or Options.NoFinality disables it.
+func (i *Ingester) confirmations() uint64 {
+	if i.opts.NoFinality || i.opts.Confirmations <= 0 {
+		return 0
+	}
+	return uint64(i.opts.Confirmations)
+}
+
+// proxyImplementation resolves the EIP-1967 implementation of a contract at
+// block when the provider can read storage. Enrichment is best effort: lookup
+// failures and non-proxies yield "".
+func (i *Ingester) proxyImplementation(ctx context.Context, addr string, block uint64) string {
+	sr, ok := i.prov.(eth.StorageReader)
+	if !ok {
+		return ""
+	}
+	impl, err := eth.ImplementationAt(ctx, sr, addr, block)
+	if err != nil {
+		return ""
+	}
+	return impl
+}
+
+// probeCapabilities checks once per ingester, before the first range is
+// fetched, whether the provider can trace at all, so a missing trace API is
+// reported up front rather than silently dropping internal transactions.
+// Probe errors other than ErrUnsupported are ignored; processRange surfaces
+// them on the real calls.
+func (i *Ingester) probeCapabilities(ctx context.Context, block uint64) {
+	i.probeOnce.Do(func() {
+		if _, err := i.prov.TraceBlock(ctx, block, block, i.address); err == eth.ErrUnsupported {
+			i.markTracesUnavailable()
+		}
+	})
+}
+
+// markTracesUnavailable records that traces are unsupported and logs the
+// warning the first time only.
+func (i *Ingester) markTracesUnavailable() {
+	if !i.noTraces.CompareAndSwap(false, true) {
+		return
+	}
+	if logger := logging.Logger(); logger != nil {
+		logger.Warn("traces_unavailable",
+			"component", "ingest",
+			"address", i.address,
+			"detail", "provider supports neither trace_filter nor trace_block; internal transactions and trace-derived contract creations will not be captured",
+		)
+	}
+}
+
+// Summary reports run-level conditions observed so far.
+func (i *Ingester) Summary() RunSummary {
+	return RunSummary{TracesUnavailable: i.noTraces.Load(), BalanceDiscrepancies: i.discrepancies.Load()}
+}
+
+// Close releases the ClickHouse client's idle connections, for use as a
+// shutdown hook.
+func (i *Ingester) Close(ctx context.Context) error {
+	i.ch.CloseIdleConnections()
+	if i.kafka != nil {
+		return i.kafka.Close(ctx)
+	}
+	return nil
+}
+
+// noteSafeHead informs providers that support it about the current safe head
+// so finalized block data (e.g. timestamps) can be cached without expiry.
+func (i *Ingester) noteSafeHead(safeHead uint64) {
+	if sa, ok := i.prov.(eth.SafeHeadAware); ok {
+		sa.SetSafeHead(safeHead)
+	}
+}
+
 // loadCheckpoint returns a cached checkpoint when available or fetches the
 // latest row from storage. The cached copy allows subsequent callers to skip
 // the ClickHouse round-trip until a new value is persisted.
@@ -722,9 +1741,23 @@ func (i *Ingester) fetchCheckpoint(ctx context.Context) (*addressCheckpoint, err
 // persistCheckpoint writes the checkpoint row, updates timestamps for the
 // supplied kind (backfill or delta), and refreshes the cached snapshot.
 func (i *Ingester) persistCheckpoint(ctx context.Context, ckpt addressCheckpoint, kind string, synced uint64) error {
+	ckpt, row := i.checkpointRow(ckpt, kind, synced)
+	if err := i.ch.InsertJSONEachRow(ctx, "addresses", []any{row}); err != nil {
+		return fmt.Errorf("inserting addresses: %w", err)
+	}
+	i.saveCheckpoint(ckpt)
+	return nil
+}
+
+// checkpointRow advances ckpt to synced, stamping the timestamps for kind,
+// and returns it with its addresses row.
+func (i *Ingester) checkpointRow(ckpt addressCheckpoint, kind string, synced uint64) (addressCheckpoint, map[string]any) {
 	ckpt.Address = i.address
 	ckpt.LastSyncedBlock = synced
 	now := fmtDT64(timeNow().UTC().UnixMilli())
+	if i.opts.Deterministic {
+		now = i.rowVersion()
+	}
 	switch kind {
 	case checkpointBackfill:
 		ckpt.LastBackfillAt = now
@@ -739,11 +1772,7 @@ func (i *Ingester) persistCheckpoint(ctx context.Context, ckpt addressCheckpoint
 		"last_delta_at":     ckpt.LastDeltaAt,
 		"updated_at":        ckpt.UpdatedAt,
 	}
-	if err := i.ch.InsertJSONEachRow(ctx, "addresses", []any{row}); err != nil {
-		return fmt.Errorf("inserting addresses: %w", err)
-	}
-	i.saveCheckpoint(ckpt)
-	return nil
+	return ckpt, row
 }
 
 // saveCheckpoint caches a copy of the checkpoint for quick reuse.
@@ -769,24 +1798,131 @@ func (i *Ingester) SchemaMode() string {
 }
 
 func mustNormalizeOptions(opts Options) Options {
-	mode, err := NormalizeSchema(opts.Schema)
+	opts, err := normalizeOptions(opts)
 	if err != nil {
 		panic(err)
 	}
-	opts.Schema = mode
 	return opts
 }
 
+// normalizeOptions canonicalizes the schema mode, table selection and
+// indexed-amount token addresses, and builds the Kafka REST producer.
+func normalizeOptions(opts Options) (Options, error) {
+	mode, err := NormalizeSchema(opts.Schema)
+	if err != nil {
+		return Options{}, err
+	}
+	opts.Schema = mode
+	tables, err := NormalizeTables(opts.Tables)
+	if err != nil {
+		return Options{}, err
+	}
+	opts.Tables = tables
+	if opts.RowBinaryTables, err = NormalizeTables(opts.RowBinaryTables); err != nil {
+		return Options{}, err
+	}
+	if opts.TimestampPrecision, err = NormalizeTimestampPrecision(opts.TimestampPrecision); err != nil {
+		return Options{}, err
+	}
+	tokens := make([]string, 0, len(opts.IndexedAmountTokens))
+	for _, t := range opts.IndexedAmountTokens {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !fullAddressPattern.MatchString(t) {
+			return Options{}, fmt.Errorf("invalid indexed-amount token %q", t)
+		}
+		tokens = append(tokens, t)
+	}
+	opts.IndexedAmountTokens = tokens
+	if len(opts.KafkaBrokers) > 0 && opts.KafkaProducer == nil {
+		if opts.KafkaProducer, err = NewKafkaRESTProducer(opts.KafkaBrokers, nil); err != nil {
+			return Options{}, err
+		}
+	}
+	return opts, nil
+}
+
+// tokenDecodeOptions returns the decoding hints derived from opts.
+func (i *Ingester) tokenDecodeOptions() normalize.TokenDecodeOptions {
+	if len(i.opts.IndexedAmountTokens) == 0 {
+		return normalize.TokenDecodeOptions{}
+	}
+	indexed := make(map[string]bool, len(i.opts.IndexedAmountTokens))
+	for _, t := range i.opts.IndexedAmountTokens {
+		indexed[t] = true
+	}
+	return normalize.TokenDecodeOptions{IndexedAmountTokens: indexed}
+}
+
+// wants reports whether any of tables should be written. Table selection
+// applies to the canonical schema only; dev runs write everything.
+func (i *Ingester) wants(tables ...string) bool {
+	if len(i.opts.Tables) == 0 || i.SchemaMode() != "canonical" {
+		return true
+	}
+	for _, want := range tables {
+		for _, t := range i.opts.Tables {
+			if t == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rowVersion returns the ingested_at/updated_at stamp for the next insert:
+// the current UTC millisecond, bumped past the previous stamp so rows written
+// by a later reprocess (e.g. after a reorg) always win the ReplacingMergeTree
+// merge, even within the same millisecond. Deterministic runs count up from
+// the Unix epoch instead of reading the clock.
+func (i *Ingester) rowVersion() string {
+	return fmtDT64(i.versionMillis())
+}
+
+// rowTs formats a canonical row timestamp at Options.TimestampPrecision.
+func (i *Ingester) rowTs(ms int64) string {
+	digits, ok := timestampDigits[i.opts.TimestampPrecision]
+	if !ok {
+		digits = 3
+	}
+	return fmtDateTime(ms, digits)
+}
+
+// versionMillis returns the millisecond behind the next rowVersion stamp.
+func (i *Ingester) versionMillis() int64 {
+	now := timeNow().UTC().UnixMilli()
+	if i.opts.Deterministic {
+		now = 0
+	}
+	for {
+		last := i.lastVersion.Load()
+		next := now
+		if next <= last {
+			next = last + 1
+		}
+		if i.lastVersion.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
 // fmtDT64 formats milliseconds since epoch to ClickHouse-compatible DateTime64(3) string (UTC).
 func fmtDT64(ms int64) string {
-	if ms <= 0 {
-		return "1970-01-01 00:00:00.000"
-	}
-	sec := ms / 1000
-	nsec := (ms % 1000) * int64(time.Millisecond)
-	t := time.Unix(sec, nsec).UTC()
-	// 2006-01-02 15:04:05.000
-	return t.Format("2006-01-02 15:04:05.000")
+	return fmtDateTime(ms, 3)
+}
+
+// fmtDateTime formats milliseconds since epoch as a UTC ClickHouse DateTime
+// string with digits fractional-second digits (0 for plain DateTime).
+// Non-positive inputs yield the Unix epoch.
+func fmtDateTime(ms int64, digits int) string {
+	if ms < 0 {
+		ms = 0
+	}
+	t := time.UnixMilli(ms).UTC()
+	layout := "2006-01-02 15:04:05"
+	if digits > 0 {
+		layout += "." + strings.Repeat("0", digits)
+	}
+	return t.Format(layout)
 }
 
 func quoteCHString(s string) string {
@@ -77,7 +77,9 @@ func TestBackfillDeltaAndTsCache(t *testing.T) {
 		t.Fatal("expected timestamp calls")
 	}
 
-	// Delta with confirmations trims head and still processes range; run again
+	// A new block arrives; delta with confirmations rewinds into the reorg
+	// window and reprocesses it with the evicted timestamps.
+	p.bn = 13
 	if err := ing.Delta(context.Background()); err != nil {
 		t.Fatal(err)
 	}
@@ -88,34 +90,34 @@ func TestBackfillDeltaAndTsCache(t *testing.T) {
 
 func TestPruneTimestampCacheResetsFromGenesis(t *testing.T) {
 	ing := New("0xabc", Options{})
-	ing.tsCache[10] = 1000
-	ing.tsCache[20] = 2000
+	ing.tsCache.Add(10, 1000)
+	ing.tsCache.Add(20, 2000)
 	ing.pruneTimestampCache(0)
-	if l := len(ing.tsCache); l != 0 {
+	if l := ing.tsCache.Len(); l != 0 {
 		t.Fatalf("expected cleared cache, got %d entries", l)
 	}
 
 	// Second call exercises the empty-cache path while locked.
 	ing.pruneTimestampCache(0)
-	if l := len(ing.tsCache); l != 0 {
+	if l := ing.tsCache.Len(); l != 0 {
 		t.Fatalf("expected cache to remain empty, got %d entries", l)
 	}
 }
 
 func TestPruneTimestampCacheTrimsRollingWindow(t *testing.T) {
 	ing := New("0xabc", Options{})
-	ing.tsCache[5] = 500
-	ing.tsCache[12] = 1200
-	ing.tsCache[18] = 1800
+	ing.tsCache.Add(5, 500)
+	ing.tsCache.Add(12, 1200)
+	ing.tsCache.Add(18, 1800)
 	ing.pruneTimestampCache(12)
 
-	if _, ok := ing.tsCache[5]; !ok {
+	if _, ok := ing.tsCache.Get(5); !ok {
 		t.Fatal("expected block 5 to remain cached")
 	}
-	if _, ok := ing.tsCache[12]; ok {
+	if _, ok := ing.tsCache.Get(12); ok {
 		t.Fatal("expected block 12 to be pruned")
 	}
-	if _, ok := ing.tsCache[18]; ok {
+	if _, ok := ing.tsCache.Get(18); ok {
 		t.Fatal("expected block 18 to be pruned")
 	}
 }
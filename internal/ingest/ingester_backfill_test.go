@@ -133,6 +133,47 @@ func TestBackfillResumesFromCheckpoint(t *testing.T) {
 	}
 }
 
+// backfillFromCheckpoint runs Backfill against a stored checkpoint at block
+// 150 and returns the first block fetched and the checkpoint written last.
+func backfillFromCheckpoint(t *testing.T, opts Options) (uint64, addressCheckpoint) {
+	t.Helper()
+	prov := &captureProv{head: 210}
+	opts.ClickHouseDSN = "http://localhost:8123/db"
+	opts.BatchBlocks = 100
+	ing := NewWithProvider("0xabc", opts, prov)
+	payload, _ := json.Marshal(addressCheckpoint{Address: "0xabc", LastSyncedBlock: 150, LastBackfillAt: fmtDT64(1_000), UpdatedAt: fmtDT64(3_000)})
+	rt := &cursorRoundTripper{t: t, selectResponse: string(payload) + "\n"}
+	ing.ch.SetTransport(rt)
+	if err := ing.Backfill(context.Background()); err != nil && err != ErrMaxBlocksReached {
+		t.Fatalf("backfill err: %v", err)
+	}
+	if len(prov.calls) == 0 || len(rt.inserts) == 0 {
+		t.Fatalf("calls=%v inserts=%v", prov.calls, rt.inserts)
+	}
+	var row addressCheckpoint
+	if err := json.Unmarshal([]byte(strings.TrimSpace(rt.inserts[len(rt.inserts)-1])), &row); err != nil {
+		t.Fatalf("decode insert: %v", err)
+	}
+	return prov.calls[0].from, row
+}
+
+func TestBackfillForceFromBlockIgnoresCheckpoint(t *testing.T) {
+	defer withTimeNow(t, time.UnixMilli(7_000))()
+	force := uint64(100)
+	if from, row := backfillFromCheckpoint(t, Options{ForceFromBlock: &force}); from != 100 || row.LastSyncedBlock != 210 {
+		t.Fatalf("forced: from=%d last_synced_block=%d", from, row.LastSyncedBlock)
+	}
+	// The checkpoint is rewound, so a run stopped early resumes from the
+	// forced progress rather than from the old checkpoint.
+	if from, row := backfillFromCheckpoint(t, Options{ForceFromBlock: &force, MaxBlocksPerRun: 20}); from != 100 || row.LastSyncedBlock != 119 {
+		t.Fatalf("forced capped: from=%d last_synced_block=%d", from, row.LastSyncedBlock)
+	}
+	// A plain FromBlock below the checkpoint still resumes from it.
+	if from, row := backfillFromCheckpoint(t, Options{FromBlock: 100}); from != 151 || row.LastSyncedBlock != 210 {
+		t.Fatalf("from-block: from=%d last_synced_block=%d", from, row.LastSyncedBlock)
+	}
+}
+
 func TestBackfillMaxUintCheckpointError(t *testing.T) {
 	prov := stubCursorProvider{head: math.MaxUint64}
 	ing := NewWithProvider("0xAbc", Options{}, prov)
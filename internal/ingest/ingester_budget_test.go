@@ -0,0 +1,82 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// budgetProv fails every call with eth.ErrBudgetExceeded once left calls
+// have been made, like a provider built with eth.WithCallBudget.
+type budgetProv struct {
+	*captureProv
+	left int
+}
+
+func (p *budgetProv) take() error {
+	if p.left == 0 {
+		return fmt.Errorf("%w: test budget spent", eth.ErrBudgetExceeded)
+	}
+	p.left--
+	return nil
+}
+
+func (p *budgetProv) BlockNumber(ctx context.Context) (uint64, error) {
+	if err := p.take(); err != nil {
+		return 0, err
+	}
+	return p.captureProv.BlockNumber(ctx)
+}
+
+func (p *budgetProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	if err := p.take(); err != nil {
+		return nil, err
+	}
+	return p.captureProv.GetLogs(ctx, address, from, to, topics)
+}
+
+func (p *budgetProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	if err := p.take(); err != nil {
+		return nil, err
+	}
+	return p.captureProv.TraceBlock(ctx, from, to, address)
+}
+
+func (p *budgetProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	if err := p.take(); err != nil {
+		return nil, err
+	}
+	return p.captureProv.Transactions(ctx, address, from, to)
+}
+
+func TestBackfill_BudgetExhaustedCheckpointsCompletedRanges(t *testing.T) {
+	// Checkpoint at 50; one call for the head, one trace capability probe,
+	// then three per 10-block range: the budget covers 51..70 and runs out
+	// fetching 71..80.
+	ing, prov, rt := upToDateIngester(t, 200, Options{BatchBlocks: 10, AdaptiveBatch: true})
+	ing.prov = &budgetProv{captureProv: prov, left: 2 + 3*2}
+	err := ing.Backfill(context.Background())
+	if !errors.Is(err, eth.ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if n := len(prov.calls); n != 2 || prov.calls[1].to != 70 {
+		t.Fatalf("ranges = %+v; the exhausted budget must not be retried over smaller batches", prov.calls)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 70 {
+		t.Fatalf("checkpoint = %+v, want last_synced_block 70", row)
+	}
+}
+
+func TestDelta_BudgetExhaustedCheckpointsCompletedRanges(t *testing.T) {
+	ing, prov, rt := upToDateIngester(t, 200, Options{BatchBlocks: 10})
+	ing.prov = &budgetProv{captureProv: prov, left: 2 + 3}
+	if err := ing.Delta(context.Background()); !errors.Is(err, eth.ErrBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 60 {
+		t.Fatalf("checkpoint = %+v, want last_synced_block 60", row)
+	}
+}
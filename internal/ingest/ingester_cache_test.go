@@ -40,3 +40,24 @@ func TestGetBlockTs_NoProvider(t *testing.T) {
 		t.Fatal("expected false with no provider")
 	}
 }
+
+type safeHeadProv struct {
+	tsProv
+	safe []uint64
+}
+
+func (p *safeHeadProv) SetSafeHead(block uint64) { p.safe = append(p.safe, block) }
+
+func TestBackfillAndDeltaReportSafeHead(t *testing.T) {
+	p := &safeHeadProv{}
+	ing := NewWithProvider("0x", Options{Confirmations: 10, BatchBlocks: 100}, p)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	if len(p.safe) != 2 || p.safe[0] != 90 || p.safe[1] != 90 {
+		t.Fatalf("unexpected safe heads: %v", p.safe)
+	}
+}
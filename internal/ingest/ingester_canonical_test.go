@@ -3,10 +3,12 @@ package ingest
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/AIAleph/mvp_wallet_context/internal/eth"
 	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
@@ -125,6 +127,9 @@ func TestProcessRange_CanonicalTransactionsRow(t *testing.T) {
 			}
 			payload = strings.TrimSpace(string(body))
 		}
+		if strings.HasPrefix(q, "SELECT") {
+			return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+		}
 		return &http.Response{StatusCode: 200, Body: ioNopCloser("ok")}, nil
 	}))
 	if err := ing.processRange(context.Background(), 1, 1); err != nil {
@@ -201,6 +206,9 @@ func TestProcessRange_CanonicalTransactionTraceID(t *testing.T) {
 			}
 			payload = strings.TrimSpace(string(body))
 		}
+		if strings.HasPrefix(q, "SELECT") {
+			return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+		}
 		return &http.Response{StatusCode: 200, Body: ioNopCloser("ok")}, nil
 	}))
 	if err := ing.processRange(context.Background(), 1, 1); err != nil {
@@ -442,6 +450,9 @@ func TestProcessRange_CanonicalContractsFromTransaction(t *testing.T) {
 			}
 			payload = strings.TrimSpace(string(body))
 		}
+		if strings.HasPrefix(q, "SELECT") {
+			return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+		}
 		return &http.Response{StatusCode: 200, Body: ioNopCloser("ok")}, nil
 	}))
 	if err := ing.processRange(context.Background(), 1, 1); err != nil {
@@ -518,6 +529,9 @@ func TestProcessRange_CanonicalContractsFromTrace(t *testing.T) {
 			}
 			payload = strings.TrimSpace(string(body))
 		}
+		if strings.HasPrefix(q, "SELECT") {
+			return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+		}
 		return &http.Response{StatusCode: 200, Body: ioNopCloser("ok")}, nil
 	}))
 	if err := ing.processRange(context.Background(), 1, 1); err != nil {
@@ -563,9 +577,315 @@ func TestProcessRange_CanonicalContractsInsertError(t *testing.T) {
 		if strings.Contains(q, "INSERT INTO contracts") {
 			return &http.Response{StatusCode: 500, Body: ioNopCloser("boom")}, nil
 		}
+		if strings.HasPrefix(q, "SELECT") {
+			return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+		}
 		return &http.Response{StatusCode: 200, Body: ioNopCloser("ok")}, nil
 	}))
 	if err := ing.processRange(context.Background(), 1, 1); err == nil || !strings.Contains(err.Error(), "inserting contracts") {
 		t.Fatalf("expected contracts insert error, got %v", err)
 	}
 }
+
+func TestProcessRange_CanonicalTransfersCarryMintBurnFlags(t *testing.T) {
+	token := "0x" + strings.Repeat("c", 40)
+	holder := padTopicAddr("0x" + strings.Repeat("a", 40))
+	zero := padTopicAddr("0x" + strings.Repeat("0", 40))
+	amount := "0x" + strings.Repeat("0", 63) + "1"
+	prov := &fixtureProv{logs: []eth.Log{
+		{TxHash: "0x1", Index: 0, Address: token, Topics: []string{"0xddf252ad", zero, holder}, DataHex: amount, BlockNum: 1},
+		{TxHash: "0x2", Index: 0, Address: token, Topics: []string{"0xddf252ad", holder, zero}, DataHex: amount, BlockNum: 1},
+	}}
+	ing := NewWithProvider("", Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["token_transfers"], "")), "\n")
+	if len(rows) != 2 ||
+		!strings.Contains(rows[0], `"is_mint":1`) || !strings.Contains(rows[0], `"is_burn":0`) ||
+		!strings.Contains(rows[1], `"is_mint":0`) || !strings.Contains(rows[1], `"is_burn":1`) {
+		t.Fatalf("unexpected token_transfers payload: %v", rows)
+	}
+}
+
+func TestProcessRange_CanonicalIndexedAmountTransfers(t *testing.T) {
+	token := "0x" + strings.Repeat("c", 40)
+	holder := padTopicAddr("0x" + strings.Repeat("a", 40))
+	other := padTopicAddr("0x" + strings.Repeat("b", 40))
+	amount := "0x" + strings.Repeat("0", 60) + "2710"
+	prov := &fixtureProv{logs: []eth.Log{
+		{TxHash: "0x1", Index: 0, Address: token, Topics: []string{"0xddf252ad", holder, other, amount}, DataHex: "0x", BlockNum: 1},
+	}}
+	ing := NewWithProvider("", Options{ClickHouseDSN: "http://localhost:8123/db", IndexedAmountTokens: []string{" 0x" + strings.Repeat("C", 40)}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	rows := inserts["token_transfers"]
+	if len(rows) != 1 || !strings.Contains(rows[0], `"standard":"erc20"`) || !strings.Contains(rows[0], `"amount_raw":"10000"`) || !strings.Contains(rows[0], `"non_standard":1`) {
+		t.Fatalf("unexpected token_transfers payload: %v", rows)
+	}
+}
+
+func TestProcessRange_CanonicalTransactionsCarryAccessList(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	other := "0x" + strings.Repeat("b", 40)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: other, Status: 1, BlockNum: 1, AccessListCount: 2, AccessListKeys: 5},
+		{Hash: "0x2", From: addr, To: other, Status: 1, BlockNum: 1},
+	}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"transactions"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["transactions"], "")), "\n")
+	if len(rows) != 2 {
+		t.Fatalf("unexpected transactions payload: %v", rows)
+	}
+	if !strings.Contains(rows[0], `"access_list_count":2`) || !strings.Contains(rows[0], `"access_list_storage_keys":5`) {
+		t.Fatalf("typed row = %s", rows[0])
+	}
+	if !strings.Contains(rows[1], `"access_list_count":0`) || !strings.Contains(rows[1], `"access_list_storage_keys":0`) {
+		t.Fatalf("legacy row = %s", rows[1])
+	}
+}
+
+func TestProcessRange_ReceiptlessTransactionsFlagged(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	other := "0x" + strings.Repeat("b", 40)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: other, ValueWei: "0x1", BlockNum: 1, ReceiptMissing: true},
+		{Hash: "0x2", From: addr, To: other, Status: 1, GasUsed: 21000, BlockNum: 1},
+	}}
+	for _, tc := range []struct{ schema, table string }{{"canonical", "transactions"}, {"dev", "dev_transactions"}} {
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: tc.schema}, prov)
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		rows := strings.Split(strings.TrimSpace(strings.Join(inserts[tc.table], "")), "\n")
+		if len(rows) != 2 {
+			t.Fatalf("%s payload: %v", tc.table, rows)
+		}
+		if !strings.Contains(rows[0], `"receipt_missing":1`) || !strings.Contains(rows[0], `"gas_used":0`) || !strings.Contains(rows[0], `"status":0`) {
+			t.Fatalf("%s receiptless row = %s", tc.table, rows[0])
+		}
+		if !strings.Contains(rows[1], `"receipt_missing":0`) {
+			t.Fatalf("%s row = %s", tc.table, rows[1])
+		}
+	}
+}
+
+func TestProcessRange_CanonicalCreationCarriesInitCodeHash(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	deployed := "0x" + strings.Repeat("d", 40)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: "", InputHex: "0x6080604052", Status: 1, BlockNum: 1, ContractAddress: deployed},
+		{Hash: "0x2", From: addr, To: "0x" + strings.Repeat("b", 40), InputHex: "0x", Status: 1, BlockNum: 1},
+	}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"transactions"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["transactions"], "")), "\n")
+	if len(rows) != 2 {
+		t.Fatalf("unexpected transactions payload: %v", rows)
+	}
+	if !strings.Contains(rows[0], `"input_method":"create"`) || !strings.Contains(rows[0], `"init_code_hash":"0x1c3374235d773b2189aed115aa13143020fcdbbe86e38f358cf3e4771b2f0244"`) {
+		t.Fatalf("creation row = %s", rows[0])
+	}
+	if !strings.Contains(rows[0], `"created_contract":"`+deployed+`"`) {
+		t.Fatalf("creation row lacks created_contract: %s", rows[0])
+	}
+	if !strings.Contains(rows[1], `"init_code_hash":null`) || !strings.Contains(rows[1], `"input_method":null`) || !strings.Contains(rows[1], `"created_contract":""`) {
+		t.Fatalf("call row = %s", rows[1])
+	}
+}
+
+func TestProcessRange_CanonicalSubCalls(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	mc := "0x" + strings.Repeat("c", 40)
+	word := func(n int) string { return fmt.Sprintf("%064x", n) }
+	// aggregate([(0xb..b, transfer(0x1, 2))])
+	inner := "a9059cbb" + word(1) + word(2)
+	input := "0x252dba42" + word(32) + word(1) + word(32) + "000000000000000000000000" + strings.Repeat("b", 40) + word(64) + word(len(inner)/2) + inner + strings.Repeat("0", 56)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: mc, InputHex: input, Status: 1, BlockNum: 1},
+		{Hash: "0x2", From: mc, To: "0x" + strings.Repeat("d", 40), InputHex: input, Status: 1, BlockNum: 1},
+	}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"sub_calls"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts["transactions"]) != 0 {
+		t.Fatalf("transactions written despite --only-tables: %v", inserts["transactions"])
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["sub_calls"], "")), "\n")
+	if len(rows) != 1 {
+		t.Fatalf("unexpected sub_calls payload: %v", rows)
+	}
+	for _, want := range []string{`"tx_hash":"0x1"`, `"call_index":"0"`, `"multicall":"` + mc + `"`, `"target":"0x` + strings.Repeat("b", 40) + `"`, `"input_method":"transfer"`} {
+		if !strings.Contains(rows[0], want) {
+			t.Fatalf("row %s lacks %s", rows[0], want)
+		}
+	}
+}
+
+// contractsCH answers the stored-contracts lookup from the contracts rows it
+// has been sent, and counts them. Rows are matched on, and answered with, the
+// columns the lookup selects, the first being the address.
+func contractsCH(t *testing.T, ing *Ingester) *[]map[string]any {
+	t.Helper()
+	var stored []map[string]any
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		switch {
+		case strings.Contains(q, "INSERT INTO contracts"):
+			body, _ := io.ReadAll(r.Body)
+			for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+				var row map[string]any
+				if err := json.Unmarshal([]byte(line), &row); err != nil {
+					t.Fatalf("decode payload: %v", err)
+				}
+				stored = append(stored, row)
+			}
+		case strings.Contains(q, "FROM contracts FINAL"):
+			cols := strings.Split(strings.TrimSpace(q[len("SELECT "):strings.Index(q, " FROM ")]), ", ")
+			var b strings.Builder
+			for _, row := range stored {
+				addr, _ := row[cols[0]].(string)
+				if addr == "" || !strings.Contains(q, "'"+addr+"'") {
+					continue
+				}
+				out := make(map[string]any, len(cols))
+				for _, c := range cols {
+					out[c] = row[c]
+				}
+				line, _ := json.Marshal(out)
+				b.Write(append(line, '\n'))
+			}
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(b.String()))}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+	}))
+	return &stored
+}
+
+func TestProcessRange_StoredContractCreationNotDuplicated(t *testing.T) {
+	const addr = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	ing := NewWithProvider(addr, Options{Schema: "canonical", ClickHouseDSN: "http://localhost:8123/db"}, provCanonContractTx{})
+	stored := contractsCH(t, ing)
+	if err := ing.processRange(context.Background(), 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(*stored) != 1 {
+		t.Fatalf("first pass stored %d contracts", len(*stored))
+	}
+	// A Delta rescanning the same block sees the same creation again.
+	if err := ing.processRange(context.Background(), 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(*stored) != 1 {
+		t.Fatalf("rescan re-inserted the stored creation: %v", *stored)
+	}
+	// After a reorg moved the creation to another block it is rewritten.
+	if err := ing.processRange(context.Background(), 6, 6); err != nil {
+		t.Fatal(err)
+	}
+	if len(*stored) != 2 || (*stored)[1]["first_seen_block"].(float64) != 6 {
+		t.Fatalf("changed creation not rewritten: %v", *stored)
+	}
+}
+
+func TestProcessRange_StoredContractLookupRenamedColumns(t *testing.T) {
+	const addr = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	names := map[string]string{"contracts.address": "contract_address", "created_at_tx": "creation_tx", "first_seen_block": "first_block"}
+	ing := NewWithProvider(addr, Options{Schema: "canonical", ClickHouseDSN: "http://localhost:8123/db", ColumnNames: names}, provCanonContractTx{})
+	stored := contractsCH(t, ing)
+	for pass := 0; pass < 2; pass++ {
+		if err := ing.processRange(context.Background(), 5, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*stored) != 1 || (*stored)[0]["contract_address"] == nil {
+		t.Fatalf("stored creation not recognized under renamed columns: %v", *stored)
+	}
+}
+
+func TestProcessRange_StoredContractLookupError(t *testing.T) {
+	const addr = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	ing := NewWithProvider(addr, Options{Schema: "canonical", ClickHouseDSN: "http://localhost:8123/db"}, provCanonContractTx{})
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.Contains(r.URL.Query().Get("query"), "FROM contracts") {
+			return &http.Response{StatusCode: 500, Body: ioNopCloser("boom")}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: ioNopCloser("")}, nil
+	}))
+	if err := ing.processRange(context.Background(), 1, 1); err == nil || !strings.Contains(err.Error(), "checking stored contracts") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestProcessRange_TransactionsCarryInputKind(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	other := "0x" + strings.Repeat("b", 40)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: other, InputHex: "0x", Status: 1, BlockNum: 1},
+		{Hash: "0x2", From: addr, To: other, InputHex: "0xdeadbeef", Status: 1, BlockNum: 1},
+	}}
+	for _, tc := range []struct{ schema, table string }{{"canonical", "transactions"}, {"dev", "dev_transactions"}} {
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: tc.schema}, prov)
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		rows := strings.Split(strings.TrimSpace(strings.Join(inserts[tc.table], "")), "\n")
+		if len(rows) != 2 || !strings.Contains(rows[0], `"input_kind":"empty"`) || !strings.Contains(rows[1], `"input_kind":"unknown:0xdeadbeef"`) {
+			t.Fatalf("%s payload: %v", tc.table, rows)
+		}
+	}
+}
+
+func TestProcessRange_TransactionsCarryGasPrice(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	other := "0x" + strings.Repeat("b", 40)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: other, InputHex: "0x", GasUsed: 21000, GasPriceWei: "0x3b9aca00", Status: 1, BlockNum: 1},
+	}}
+	for _, tc := range []struct{ schema, table string }{{"canonical", "transactions"}, {"dev", "dev_transactions"}} {
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: tc.schema}, prov)
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if payload := strings.Join(inserts[tc.table], ""); !strings.Contains(payload, `"gas_price_raw":"1000000000"`) {
+			t.Fatalf("%s payload: %s", tc.table, payload)
+		}
+	}
+}
+
+func TestProcessRange_CanonicalTimestampPrecision(t *testing.T) {
+	defer withTimeNow(t, time.UnixMilli(1_700_000_000_456).UTC())()
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &fixtureProv{txs: []eth.Transaction{
+		{Hash: "0x1", From: addr, To: "0x" + strings.Repeat("b", 40), InputHex: "0x", Status: 1, BlockNum: 1, TsMillis: 1_700_000_000_000},
+	}}
+	for precision, want := range map[string][2]string{
+		"s":  {"2023-11-14 22:13:20", "2023-11-14 22:13:20"},
+		"us": {"2023-11-14 22:13:20.000000", "2023-11-14 22:13:20.456000"},
+	} {
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"transactions"}, TimestampPrecision: precision}, prov)
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		row := strings.Join(inserts["transactions"], "")
+		if !strings.Contains(row, `"ts":"`+want[0]+`"`) || !strings.Contains(row, `"ingested_at":"`+want[1]+`"`) {
+			t.Fatalf("precision %s: row = %s", precision, row)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBackfill_RecordsConfirmations(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	prov.head = 100
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", FromBlock: 1, ToBlock: 1}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"logs", "token_transfers", "approvals", "transactions", "traces"} {
+		body := strings.Join(inserts[table], "")
+		if body == "" {
+			t.Fatalf("no %s rows", table)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+			if !strings.Contains(line, `"confirmations":99`) {
+				t.Fatalf("%s row without confirmations = head - block: %s", table, line)
+			}
+		}
+	}
+}
+
+func TestProcessRange_ConfirmationsOmittedWithoutHead(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for table, bodies := range inserts {
+		if strings.Contains(strings.Join(bodies, ""), "confirmations") {
+			t.Fatalf("%s rows carry confirmations", table)
+		}
+	}
+}
+
+func TestConfirmations(t *testing.T) {
+	for _, c := range []struct{ head, block, want uint64 }{
+		{100, 1, 99},
+		{100, 100, 0},
+		{100, 101, 0},
+	} {
+		if got := confirmations(c.head, c.block); got != c.want {
+			t.Fatalf("confirmations(%d, %d) = %d, want %d", c.head, c.block, got, c.want)
+		}
+	}
+}
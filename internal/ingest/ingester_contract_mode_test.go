@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+type topicProv struct {
+	countingProv
+	address string
+	topics  [][]string
+}
+
+func (p *topicProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.address, p.topics = address, topics
+	return p.countingProv.GetLogs(ctx, address, from, to, topics)
+}
+
+func TestContractMode_IngestsAllTransfersOfContract(t *testing.T) {
+	token := "0x" + strings.Repeat("c", 40)
+	holders := []string{"0x" + strings.Repeat("1", 40), "0x" + strings.Repeat("2", 40), "0x" + strings.Repeat("3", 40)}
+	var logs []eth.Log
+	for k := range holders {
+		from, to := holders[k], holders[(k+1)%len(holders)]
+		logs = append(logs, eth.Log{TxHash: "0x" + strings.Repeat("9", k+1), Index: uint32(k), Address: token, Topics: []string{normalize.TransferTopics()[0], padTopicAddr(from), padTopicAddr(to)}, DataHex: "0x0a", BlockNum: 5})
+	}
+	prov := &topicProv{countingProv: countingProv{fixtureProv: fixtureProv{
+		logs:   logs,
+		traces: []eth.Trace{{TxHash: "0x1", TraceID: "0", From: token, To: holders[0], ValueWei: "1", BlockNum: 5}},
+		txs:    []eth.Transaction{{Hash: "0x1", From: holders[0], To: token, BlockNum: 5, Status: 1}},
+	}}}
+	ing := NewWithProvider(token, Options{ClickHouseDSN: "http://localhost:8123/db", ContractMode: true, Reconcile: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if prov.address != token || len(prov.topics) != 1 || strings.Join(prov.topics[0], ",") != strings.Join(normalize.TransferTopics(), ",") {
+		t.Fatalf("unexpected log filter: address=%s topics=%v", prov.address, prov.topics)
+	}
+	if prov.traceCalls != 0 || prov.txCalls != 0 {
+		t.Fatalf("contract mode fetched traces=%d txs=%d", prov.traceCalls, prov.txCalls)
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["token_transfers"], "")), "\n")
+	if len(rows) != len(holders) {
+		t.Fatalf("expected %d transfers, got %v", len(holders), rows)
+	}
+	for k, h := range holders {
+		if !strings.Contains(rows[k], `"from_addr":"`+h+`"`) || !strings.Contains(rows[k], `"token":"`+token+`"`) {
+			t.Fatalf("row %d missing holder %s: %s", k, h, rows[k])
+		}
+	}
+	if len(inserts["transactions"]) != 0 || len(inserts["traces"]) != 0 || len(inserts[ReconciliationTable]) != 0 {
+		t.Fatalf("unexpected wallet tables written: %v", inserts)
+	}
+}
+
+func TestWalletMode_FetchesLogsWithoutTopicFilter(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &topicProv{countingProv: countingProv{fixtureProv: tablesFixture(addr)}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if prov.topics != nil || prov.traceCalls == 0 || prov.txCalls == 0 {
+		t.Fatalf("topics=%v traces=%d txs=%d", prov.topics, prov.traceCalls, prov.txCalls)
+	}
+}
@@ -0,0 +1,140 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// shuffledProv serves the same data each call but rotates its order,
+// mimicking providers whose concurrency reorders results.
+type shuffledProv struct {
+	fixtureProv
+	rot int
+}
+
+func rotate[T any](in []T, n int) []T {
+	out := make([]T, 0, len(in))
+	if len(in) == 0 {
+		return out
+	}
+	n %= len(in)
+	return append(append(out, in[n:]...), in[:n]...)
+}
+
+func (p *shuffledProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.rot++
+	return rotate(p.logs, p.rot), nil
+}
+func (p *shuffledProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	return rotate(p.traces, p.rot), nil
+}
+func (p *shuffledProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	return rotate(p.txs, p.rot), nil
+}
+
+func deterministicFixture(addr string) fixtureProv {
+	other := "0x" + strings.Repeat("b", 40)
+	var f fixtureProv
+	f.head = 20
+	for k := 0; k < 3; k++ {
+		f.logs = append(f.logs, eth.Log{TxHash: fmt.Sprintf("0x%d", k), Index: uint32(k), Address: "0x" + strings.Repeat("c", 40), Topics: []string{"0xddf252ad", padTopicAddr(addr), padTopicAddr(other)}, DataHex: "0x01", BlockNum: uint64(k)})
+		f.traces = append(f.traces, eth.Trace{TxHash: fmt.Sprintf("0x%d", k), TraceID: "0", From: addr, To: other, ValueWei: "1", BlockNum: uint64(k)})
+		f.txs = append(f.txs, eth.Transaction{Hash: fmt.Sprintf("0x%d", k), From: addr, To: other, ValueWei: "1", BlockNum: uint64(k), Status: 1})
+	}
+	return f
+}
+
+func runDeterministic(t *testing.T, rot int, now time.Time) map[string][]string {
+	t.Helper()
+	defer withTimeNow(t, now)()
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &shuffledProv{fixtureProv: deterministicFixture(addr), rot: rot}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 1, BatchBlocks: 100, Deterministic: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return inserts
+}
+
+func TestBackfill_DeterministicPayloadsAreByteIdentical(t *testing.T) {
+	first := runDeterministic(t, 0, time.Unix(1_700_000_000, 0))
+	second := runDeterministic(t, 1, time.Unix(1_800_000_000, 0))
+	if len(first["logs"]) == 0 || len(first["addresses"]) == 0 {
+		t.Fatalf("expected logs and checkpoint inserts: %v", first)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("payloads differ:\n%v\n%v", first, second)
+	}
+	if !strings.Contains(first["addresses"][0], `"updated_at":"1970-01-01 00:00:00.0`) {
+		t.Fatalf("checkpoint time not pinned: %s", first["addresses"][0])
+	}
+}
+
+// checkpointStore serves addresses rows back like ClickHouse would for
+// fetchCheckpoint's ORDER BY updated_at DESC LIMIT 1: on a tie it returns
+// the earliest insert, the row a real merge order may well pick.
+func checkpointStore(t *testing.T, ing *Ingester, rows *[]addressCheckpoint) {
+	t.Helper()
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		body := ""
+		switch {
+		case strings.HasPrefix(q, "INSERT INTO addresses"):
+			b, _ := io.ReadAll(r.Body)
+			var ckpt addressCheckpoint
+			if err := json.Unmarshal(b, &ckpt); err != nil {
+				t.Errorf("decode checkpoint row: %v", err)
+			}
+			*rows = append(*rows, ckpt)
+		case strings.Contains(q, "FROM addresses") && len(*rows) > 0:
+			latest := (*rows)[0]
+			for _, c := range (*rows)[1:] {
+				if c.UpdatedAt > latest.UpdatedAt {
+					latest = c
+				}
+			}
+			b, _ := json.Marshal(latest)
+			body = string(b) + "\n"
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+}
+
+func TestBackfill_DeterministicResumesFromLastBatch(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	opts := Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 1, BatchBlocks: 10, CheckpointEvery: 10, Deterministic: true}
+	var rows []addressCheckpoint
+	fix := deterministicFixture(addr)
+	first := NewWithProvider(addr, opts, &fix)
+	checkpointStore(t, first, &rows)
+	if err := first.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("checkpoint rows = %+v, want one per batch", rows)
+	}
+	if rows[1].UpdatedAt <= rows[0].UpdatedAt {
+		t.Fatalf("later batch does not outrank earlier: %s <= %s", rows[1].UpdatedAt, rows[0].UpdatedAt)
+	}
+
+	fix = deterministicFixture(addr)
+	resumed := NewWithProvider(addr, opts, &fix)
+	checkpointStore(t, resumed, &rows)
+	ckpt, existed, err := resumed.loadCheckpoint(context.Background())
+	if err != nil || !existed {
+		t.Fatalf("loadCheckpoint: existed=%v err=%v", existed, err)
+	}
+	if ckpt.LastSyncedBlock != 19 {
+		t.Fatalf("resumed from block %d, want 19", ckpt.LastSyncedBlock)
+	}
+}
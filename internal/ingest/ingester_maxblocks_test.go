@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func lastCheckpoint(t *testing.T, rt *cursorRoundTripper) addressCheckpoint {
+	t.Helper()
+	if len(rt.inserts) == 0 {
+		t.Fatal("no checkpoint written")
+	}
+	var row addressCheckpoint
+	if err := json.Unmarshal([]byte(strings.TrimSpace(rt.inserts[len(rt.inserts)-1])), &row); err != nil {
+		t.Fatalf("decode insert: %v", err)
+	}
+	return row
+}
+
+func TestBackfill_MaxBlocksPerRunStopsAndCheckpoints(t *testing.T) {
+	// Checkpoint at 50; backfill through 200 in 10-block batches, 25 at a time.
+	ing, prov, rt := upToDateIngester(t, 200, Options{BatchBlocks: 10, MaxBlocksPerRun: 25})
+	if err := ing.Backfill(context.Background()); !errors.Is(err, ErrMaxBlocksReached) {
+		t.Fatalf("err = %v, want ErrMaxBlocksReached", err)
+	}
+	if n := len(prov.calls); n != 3 || prov.calls[0].from != 51 || prov.calls[2].to != 75 {
+		t.Fatalf("ranges = %+v, want 51..75 in three batches", prov.calls)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 75 {
+		t.Fatalf("checkpoint = %+v, want last_synced_block 75", row)
+	}
+	// The next run resumes from the checkpoint.
+	if err := ing.Backfill(context.Background()); !errors.Is(err, ErrMaxBlocksReached) {
+		t.Fatalf("err = %v", err)
+	}
+	if prov.calls[3].from != 76 || lastCheckpoint(t, rt).LastSyncedBlock != 100 {
+		t.Fatalf("second run ranges = %+v", prov.calls[3:])
+	}
+}
+
+func TestBackfill_MaxBlocksPerRunNotReached(t *testing.T) {
+	ing, prov, rt := upToDateIngester(t, 60, Options{BatchBlocks: 10, MaxBlocksPerRun: 10})
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatalf("exactly cap-sized range should finish cleanly: %v", err)
+	}
+	if len(prov.calls) != 1 || lastCheckpoint(t, rt).LastSyncedBlock != 60 {
+		t.Fatalf("calls=%+v", prov.calls)
+	}
+}
+
+func TestDelta_MaxBlocksPerRunExcludesRescan(t *testing.T) {
+	// Checkpoint at 50 with 5 confirmations: delta rescans 46..50 and may
+	// then add at most 20 new blocks, i.e. up to 70 of the safe head 95.
+	ing, prov, rt := upToDateIngester(t, 100, Options{BatchBlocks: 100, Confirmations: 5, MaxBlocksPerRun: 20})
+	if err := ing.Delta(context.Background()); !errors.Is(err, ErrMaxBlocksReached) {
+		t.Fatalf("err = %v, want ErrMaxBlocksReached", err)
+	}
+	if len(prov.calls) != 1 || prov.calls[0].from != 46 || prov.calls[0].to != 70 {
+		t.Fatalf("ranges = %+v, want 46..70", prov.calls)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 70 {
+		t.Fatalf("checkpoint = %+v, want last_synced_block 70", row)
+	}
+}
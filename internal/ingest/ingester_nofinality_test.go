@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBackfill_NoFinalityProcessesLowHead(t *testing.T) {
+	for _, noFinality := range []bool{false, true} {
+		prov := &captureProv{head: 10}
+		opts := Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 12, BatchBlocks: 100, NoFinality: noFinality}
+		ing := NewWithProvider("0xabc", opts, prov)
+		rt := &cursorRoundTripper{t: t, selectResponse: ""}
+		ing.ch.SetTransport(rt)
+		if err := ing.Backfill(context.Background()); err != nil {
+			t.Fatalf("no_finality=%v: %v", noFinality, err)
+		}
+		if !noFinality {
+			if len(prov.calls) != 0 {
+				t.Fatalf("confirmations gate ignored: calls=%v", prov.calls)
+			}
+			continue
+		}
+		if len(prov.calls) != 1 || prov.calls[0].to != 10 {
+			t.Fatalf("expected one range up to the head, got %v", prov.calls)
+		}
+		if got := lastCheckpoint(t, rt).LastSyncedBlock; got != 10 {
+			t.Fatalf("last_synced_block = %d, want 10", got)
+		}
+	}
+}
+
+func TestDelta_NoFinalitySkipsReorgRescan(t *testing.T) {
+	prov := &captureProv{head: 10}
+	opts := Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 12, BatchBlocks: 100, NoFinality: true}
+	ing := NewWithProvider("0xabc", opts, prov)
+	payload, _ := json.Marshal(addressCheckpoint{Address: "0xabc", LastSyncedBlock: 5})
+	rt := &cursorRoundTripper{t: t, selectResponse: string(payload) + "\n"}
+	ing.ch.SetTransport(rt)
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.calls) != 1 || prov.calls[0].from != 6 || prov.calls[0].to != 10 {
+		t.Fatalf("calls = %v, want blocks 6-10", prov.calls)
+	}
+}
+
+func TestNoFinality_LogsWarning(t *testing.T) {
+	buf := captureLogs(t)
+	NewWithProvider("0xabc", Options{Confirmations: 12, NoFinality: true}, nil)
+	if !strings.Contains(buf.String(), "no_finality") {
+		t.Fatalf("expected a no_finality warning, got %q", buf.String())
+	}
+}
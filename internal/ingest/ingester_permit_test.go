@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+func permitInput(owner, spender string) string {
+	word := func(s string) string { return strings.Repeat("0", 64-len(s)) + s }
+	return "0xd505accf" + word(strings.TrimPrefix(owner, "0x")) + word(strings.TrimPrefix(spender, "0x")) +
+		word("64") + word("ffffffff") + word("1b") + strings.Repeat("1", 64) + strings.Repeat("2", 64)
+}
+
+func TestProcessRange_PermitApprovals(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	spender := "0x" + strings.Repeat("b", 40)
+	token := "0x" + strings.Repeat("d", 40)
+	prov := tablesFixture(addr)
+	prov.txs = append(prov.txs, eth.Transaction{Hash: "0x2", From: spender, To: token, InputHex: permitInput(addr, spender), BlockNum: 1, Status: 1})
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Join(inserts["approvals"], "")
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want the logged approval and the permit, got %s", body)
+	}
+	for _, want := range []string{`"source":"permit"`, `"log_index":4294967295`, `"amount_raw":"100"`, `"token":"` + token + `"`} {
+		if !strings.Contains(lines[1], want) {
+			t.Fatalf("permit row missing %s: %s", want, lines[1])
+		}
+	}
+	if !strings.Contains(lines[0], `"source":"event"`) {
+		t.Fatalf("logged approval lacks the event source: %s", lines[0])
+	}
+}
+
+func TestPermitsFor(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	spender := "0x" + strings.Repeat("b", 40)
+	other := "0x" + strings.Repeat("e", 40)
+	token := "0x" + strings.Repeat("d", 40)
+	txs := []eth.Transaction{
+		{Hash: "0x1", To: token, InputHex: permitInput(addr, spender), Status: 1},
+		{Hash: "0x2", To: token, InputHex: permitInput(other, spender), Status: 1},
+		{Hash: "0x3", To: token, InputHex: permitInput(other, addr), Status: 1},
+	}
+	logged := []normalize.ApprovalRow{{TxHash: "0x1", Token: strings.ToUpper(token), Owner: addr, Spender: spender}}
+	got := permitsFor(txs, logged, addr)
+	if len(got) != 1 || got[0].TxHash != "0x3" {
+		t.Fatalf("want only the unlogged permit granted to the address, got %+v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func precompileFixture(addr string) fixtureProv {
+	prov := tablesFixture(addr)
+	sha256 := "0x" + strings.Repeat("0", 39) + "2"
+	prov.txs = append(prov.txs, eth.Transaction{Hash: "0x2", From: addr, To: sha256, ValueWei: "0", BlockNum: 1, Status: 1})
+	prov.traces = append(prov.traces, eth.Trace{TxHash: "0x2", TraceID: "0-1", From: addr, To: sha256, ValueWei: "0", BlockNum: 1})
+	return prov
+}
+
+func TestProcessRange_PrecompileFlagged(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := precompileFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"transactions", "traces"} {
+		for _, line := range strings.Split(strings.TrimSpace(strings.Join(inserts[table], "")), "\n") {
+			want := `"is_precompile":0`
+			if strings.Contains(line, `"tx_hash":"0x2"`) {
+				want = `"is_precompile":1`
+			}
+			if !strings.Contains(line, want) {
+				t.Fatalf("%s row missing %s: %s", table, want, line)
+			}
+		}
+	}
+}
+
+func TestProcessRange_SkipPrecompiles(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := precompileFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", SkipPrecompiles: true}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"transactions", "traces"} {
+		body := strings.Join(inserts[table], "")
+		if !strings.Contains(body, `"tx_hash":"0x1"`) || strings.Contains(body, `"tx_hash":"0x2"`) {
+			t.Fatalf("%s: want only the normal call, got %s", table, body)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+const upgradedTopic = "0xbc7cd75a20ee27fd9adebab32041f755214dbc6bffa90cc0225b39da2e5c2d3b"
+
+func TestProcessRange_WritesProxyUpgrades(t *testing.T) {
+	proxy := "0x" + strings.Repeat("d", 40)
+	impl := "0x" + strings.Repeat("5", 40)
+	prov := &fixtureProv{logs: []eth.Log{{TxHash: "0xa", Index: 2, Address: proxy, Topics: []string{upgradedTopic, padTopicAddr(impl)}, DataHex: "0x", BlockNum: 7}}}
+	ing := NewWithProvider(proxy, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	rows := inserts["proxy_upgrades"]
+	if len(rows) != 1 || !strings.Contains(rows[0], `"implementation":"`+impl+`"`) || !strings.Contains(rows[0], `"proxy":"`+proxy+`"`) {
+		t.Fatalf("unexpected proxy_upgrades payload: %v", rows)
+	}
+}
+
+func TestProcessRange_ProxyUpgradesInsertError(t *testing.T) {
+	proxy := "0x" + strings.Repeat("d", 40)
+	prov := &fixtureProv{logs: []eth.Log{{TxHash: "0xa", Address: proxy, Topics: []string{upgradedTopic, padTopicAddr(proxy)}, BlockNum: 7}}}
+	ing := NewWithProvider(proxy, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	ing.ch.SetTransport(queryFailingTransport{t: t, status: 400, matcher: "INSERT INTO proxy_upgrades"})
+	if err := ing.processRange(context.Background(), 7, 7); err == nil || !strings.Contains(err.Error(), "inserting proxy_upgrades") {
+		t.Fatalf("expected proxy_upgrades insert error, got %v", err)
+	}
+}
+
+// storageProv is a fixtureProv that can read storage, serving word for
+// every address except those in empty.
+type storageProv struct {
+	fixtureProv
+	word  []byte
+	err   error
+	empty map[string]bool
+}
+
+func (p *storageProv) StorageAt(ctx context.Context, address, slot string, block uint64) ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.empty[address] {
+		return make([]byte, 32), nil
+	}
+	return p.word, nil
+}
+
+func TestProcessRange_ContractsResolveProxyImplementation(t *testing.T) {
+	deployer := "0x" + strings.Repeat("a", 40)
+	proxy := "0x" + strings.Repeat("d", 40)
+	plain := "0x" + strings.Repeat("e", 40)
+	impl := "0x" + strings.Repeat("5", 40)
+	word := make([]byte, 32)
+	for k := 12; k < 32; k++ {
+		word[k] = 0x55
+	}
+	prov := &storageProv{
+		fixtureProv: fixtureProv{txs: []eth.Transaction{
+			{Hash: "0x1", From: deployer, BlockNum: 7, Status: 1, ContractAddress: proxy},
+			{Hash: "0x2", From: deployer, BlockNum: 7, Status: 1, ContractAddress: plain},
+		}},
+		word:  word,
+		empty: map[string]bool{plain: true},
+	}
+	ing := NewWithProvider(deployer, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(strings.Join(inserts["contracts"], "")), "\n")
+	if len(rows) != 2 ||
+		!strings.Contains(rows[0], `"implementation":"`+impl+`"`) ||
+		!strings.Contains(rows[1], `"implementation":""`) {
+		t.Fatalf("unexpected contracts payload: %v", rows)
+	}
+
+	prov.err = eth.ErrUnsupported
+	if got := ing.proxyImplementation(context.Background(), proxy, 7); got != "" {
+		t.Fatalf("lookup failure should leave implementation empty, got %q", got)
+	}
+	if got := NewWithProvider("", Options{}, &fixtureProv{}).proxyImplementation(context.Background(), proxy, 7); got != "" {
+		t.Fatalf("provider without storage access should yield empty, got %q", got)
+	}
+}
@@ -1,6 +1,9 @@
 package ingest
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestSchemaMode_DefaultsAndUnknown(t *testing.T) {
 	if got := New("0x", Options{}).SchemaMode(); got != DefaultSchemaMode {
@@ -59,3 +62,35 @@ func TestFmtDT64_Edges(t *testing.T) {
 		t.Fatalf("fmtDT64(>0) unexpected epoch: %q", got)
 	}
 }
+
+func TestNormalizeTimestampPrecision(t *testing.T) {
+	for in, want := range map[string]string{"": DefaultTimestampPrecision, "s": "s", " MS ": "ms", "us": "us"} {
+		if got, err := NormalizeTimestampPrecision(in); err != nil || got != want {
+			t.Fatalf("NormalizeTimestampPrecision(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := NormalizeTimestampPrecision("ns"); err == nil {
+		t.Fatal("expected error for unsupported precision")
+	}
+	if _, err := NewValidated("0x"+strings.Repeat("a", 40), Options{TimestampPrecision: "minutes"}); err == nil {
+		t.Fatal("NewValidated must reject an unknown precision")
+	}
+}
+
+func TestRowTs_Precision(t *testing.T) {
+	const ms = 1_700_000_000_123
+	for precision, want := range map[string]string{
+		"":   "2023-11-14 22:13:20.123",
+		"s":  "2023-11-14 22:13:20",
+		"ms": "2023-11-14 22:13:20.123",
+		"us": "2023-11-14 22:13:20.123000",
+	} {
+		ing := New("", Options{TimestampPrecision: precision})
+		if got := ing.rowTs(ms); got != want {
+			t.Fatalf("precision %q: rowTs = %q, want %q", precision, got, want)
+		}
+	}
+	if got := New("", Options{TimestampPrecision: "s"}).rowTs(0); got != "1970-01-01 00:00:00" {
+		t.Fatalf("epoch at s precision = %q", got)
+	}
+}
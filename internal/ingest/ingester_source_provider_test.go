@@ -0,0 +1,60 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// labeledProv is a fixture provider that names its endpoint.
+type labeledProv struct {
+	fixtureProv
+	label string
+}
+
+func (p *labeledProv) ProviderLabel() string { return p.label }
+
+func TestProcessRange_RecordProviderSource(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &labeledProv{fixtureProv: tablesFixture(addr), label: "rpc.example.com"}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", RecordProviderSource: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"logs", "token_transfers", "approvals", "transactions", "traces"} {
+		body := strings.Join(inserts[table], "")
+		if body == "" {
+			t.Fatalf("no %s rows", table)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+			if !strings.Contains(line, `"source_provider":"rpc.example.com"`) {
+				t.Fatalf("%s row without source_provider: %s", table, line)
+			}
+		}
+	}
+}
+
+func TestProcessRange_ProviderSourceOffByDefault(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	labeled := &labeledProv{fixtureProv: tablesFixture(addr), label: "rpc.example.com"}
+	unlabeled := tablesFixture(addr)
+	for name, ing := range map[string]*Ingester{
+		"disabled":   NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, labeled),
+		"unlabeled":  NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", RecordProviderSource: true}, &unlabeled),
+		"dev schema": NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "dev", RecordProviderSource: true}, labeled),
+	} {
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		if len(inserts) == 0 {
+			t.Fatalf("%s: no inserts", name)
+		}
+		for table, bodies := range inserts {
+			if strings.Contains(strings.Join(bodies, ""), "source_provider") {
+				t.Fatalf("%s: %s rows carry source_provider", name, table)
+			}
+		}
+	}
+}
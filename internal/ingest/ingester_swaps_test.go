@@ -0,0 +1,34 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestProcessRange_SwapRows(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	router := "0x" + strings.Repeat("b", 40)
+	pool := "0x" + strings.Repeat("e", 40)
+	word := func(s string) string { return strings.Repeat("0", 64-len(s)) + s }
+	prov := tablesFixture(addr)
+	prov.logs = append(prov.logs, eth.Log{
+		TxHash: "0x1", Index: 2, Address: pool,
+		Topics:   []string{"0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67", padTopicAddr(router), padTopicAddr(addr)},
+		DataHex:  "0x" + word("64") + strings.Repeat("f", 63) + "6" + word("1000000000000000000000000") + word("2a") + strings.Repeat("f", 63) + "6",
+		BlockNum: 1,
+	})
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Join(inserts["swaps"], "")
+	for _, want := range []string{`"pool":"` + pool + `"`, `"version":"v3"`, `"recipient":"` + addr + `"`, `"amount0":"100"`, `"amount1":"-10"`, `"liquidity":"42"`, `"tick":-10`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("swap row missing %s: %s", want, body)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// countingProv wraps fixtureProv and counts per-method fetches.
+type countingProv struct {
+	fixtureProv
+	logCalls, traceCalls, txCalls int
+}
+
+func (p *countingProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.logCalls++
+	return p.fixtureProv.GetLogs(ctx, address, from, to, topics)
+}
+func (p *countingProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	p.traceCalls++
+	return p.fixtureProv.TraceBlock(ctx, from, to, address)
+}
+func (p *countingProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	p.txCalls++
+	return p.fixtureProv.Transactions(ctx, address, from, to)
+}
+
+func tablesFixture(addr string) fixtureProv {
+	holder := padTopicAddr(addr)
+	other := padTopicAddr("0x" + strings.Repeat("b", 40))
+	return fixtureProv{
+		logs: []eth.Log{
+			{TxHash: "0x1", Index: 0, Address: "0x" + strings.Repeat("c", 40), Topics: []string{"0xddf252ad", holder, other}, DataHex: "0x01", BlockNum: 1},
+			{TxHash: "0x1", Index: 1, Address: "0x" + strings.Repeat("c", 40), Topics: []string{"0x8c5be1e5", holder, other}, DataHex: "0x01", BlockNum: 1},
+		},
+		traces: []eth.Trace{{TxHash: "0x1", TraceID: "0", From: addr, To: "0x" + strings.Repeat("b", 40), ValueWei: "1", BlockNum: 1}},
+		txs:    []eth.Transaction{{Hash: "0x1", From: addr, To: "0x" + strings.Repeat("b", 40), ValueWei: "1", BlockNum: 1, Status: 1}},
+	}
+}
+
+func TestProcessRange_OnlyTablesRestrictsInserts(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: tablesFixture(addr)}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"transactions", "token_transfers"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts["transactions"]) != 1 || len(inserts["token_transfers"]) != 1 {
+		t.Fatalf("selected tables missing: %v", inserts)
+	}
+	for _, table := range []string{"logs", "traces", "approvals", "proxy_upgrades", "contracts"} {
+		if len(inserts[table]) != 0 {
+			t.Fatalf("excluded table %s received inserts: %v", table, inserts[table])
+		}
+	}
+}
+
+func TestProcessRange_OnlyTablesSkipsExcludedFetches(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: tablesFixture(addr)}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"token_transfers"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if prov.logCalls != 1 || prov.traceCalls != 0 || prov.txCalls != 0 {
+		t.Fatalf("calls logs=%d traces=%d txs=%d", prov.logCalls, prov.traceCalls, prov.txCalls)
+	}
+	if len(inserts["token_transfers"]) != 1 || len(inserts) != 1 {
+		t.Fatalf("unexpected inserts: %v", inserts)
+	}
+
+	prov = &countingProv{fixtureProv: tablesFixture(addr)}
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"traces"}}, prov)
+	captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	// One probe plus one range fetch; logs and transactions are never requested.
+	if prov.logCalls != 0 || prov.traceCalls != 2 || prov.txCalls != 0 {
+		t.Fatalf("calls logs=%d traces=%d txs=%d", prov.logCalls, prov.traceCalls, prov.txCalls)
+	}
+}
+
+func TestProcessRange_TablesIgnoredInDevMode(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: tablesFixture(addr)}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "dev", Tables: []string{"traces"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts["dev_logs"]) != 1 || prov.txCalls != 1 {
+		t.Fatalf("dev mode should write everything: %v", inserts)
+	}
+}
+
+func TestNormalizeTables(t *testing.T) {
+	got, err := NormalizeTables([]string{" Logs", "traces", "logs", ""})
+	if err != nil || strings.Join(got, ",") != "logs,traces" {
+		t.Fatalf("got %v err %v", got, err)
+	}
+	if got, err := NormalizeTables(nil); err != nil || got != nil {
+		t.Fatalf("got %v err %v", got, err)
+	}
+	if _, err := NormalizeTables([]string{"addresses"}); err == nil {
+		t.Fatal("expected error for non-canonical table")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for invalid Tables")
+		}
+	}()
+	New("", Options{Tables: []string{"nope"}})
+}
+
+func TestProcessRange_SkipReceiptsNeverFetchesTransactions(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: tablesFixture(addr)}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", SkipReceipts: true, VerifyLogs: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if prov.txCalls != 0 {
+		t.Fatalf("Transactions called %d times with SkipReceipts", prov.txCalls)
+	}
+	if len(inserts["token_transfers"]) != 1 || len(inserts["approvals"]) != 1 || len(inserts["traces"]) != 1 {
+		t.Fatalf("log and trace tables missing: %v", inserts)
+	}
+	// The external transaction is gone; its trace remains as an internal row.
+	if body := strings.Join(inserts["transactions"], ""); strings.Contains(body, `"is_internal":0`) {
+		t.Fatalf("external transaction written without a fetch: %s", body)
+	}
+
+	captureLogs(t)
+	prov = &countingProv{fixtureProv: tablesFixture(addr)}
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", SkipReceipts: true, Reconcile: true}, prov)
+	captureInserts(t, ing)
+	_ = ing.processRange(context.Background(), 1, 1)
+	if prov.txCalls != 1 {
+		t.Fatalf("Reconcile should still fetch transactions, got %d calls", prov.txCalls)
+	}
+}
@@ -182,3 +182,47 @@ func withTimeNow(t *testing.T, now time.Time) func() {
 	timeNow = func() time.Time { return now }
 	return func() { timeNow = prev }
 }
+
+// fixtureProv returns the same canned logs, traces and transactions for every
+// requested range.
+type fixtureProv struct {
+	head   uint64
+	logs   []eth.Log
+	traces []eth.Trace
+	txs    []eth.Transaction
+}
+
+func (p *fixtureProv) BlockNumber(ctx context.Context) (uint64, error) { return p.head, nil }
+func (p *fixtureProv) BlockTimestamp(ctx context.Context, block uint64) (int64, error) {
+	return int64(block) * 1000, nil
+}
+func (p *fixtureProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	return append([]eth.Log(nil), p.logs...), nil
+}
+func (p *fixtureProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	return append([]eth.Trace(nil), p.traces...), nil
+}
+func (p *fixtureProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	return append([]eth.Transaction(nil), p.txs...), nil
+}
+
+// captureInserts records INSERT payloads per table and answers SELECTs with
+// an empty result.
+func captureInserts(t *testing.T, ing *Ingester) map[string][]string {
+	t.Helper()
+	got := map[string][]string{}
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		if strings.HasPrefix(q, "INSERT INTO ") {
+			table := strings.Fields(q)[2]
+			b, _ := io.ReadAll(r.Body)
+			got[table] = append(got[table], string(b))
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}))
+	return got
+}
+
+func padTopicAddr(a string) string {
+	return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(strings.ToLower(a), "0x")
+}
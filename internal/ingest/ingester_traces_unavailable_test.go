@@ -0,0 +1,67 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// noTraceProv serves logs but supports no trace API, counting trace calls.
+type noTraceProv struct {
+	rangeLogProv
+	traceCalls int
+}
+
+func (p *noTraceProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	p.traceCalls++
+	return nil, eth.ErrUnsupported
+}
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	original := logging.Logger()
+	t.Cleanup(func() { logging.SetLogger(original) })
+	var buf bytes.Buffer
+	logging.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	return &buf
+}
+
+func TestBackfill_NoTraceProviderWarnsOnceAndFlagsSummary(t *testing.T) {
+	logs := captureLogs(t)
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &noTraceProv{rangeLogProv: rangeLogProv{head: 50}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 1, BatchBlocks: 10}, prov)
+	captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(logs.String(), `"msg":"traces_unavailable"`); n != 1 {
+		t.Fatalf("expected one warning, got %d: %s", n, logs.String())
+	}
+	if !ing.Summary().TracesUnavailable {
+		t.Fatal("expected TracesUnavailable in summary")
+	}
+	if prov.traceCalls != 1 {
+		t.Fatalf("expected only the probe to hit TraceBlock, got %d calls", prov.traceCalls)
+	}
+	if len(prov.ranges) < 5 {
+		t.Fatalf("expected logs for every batch, got %v", prov.ranges)
+	}
+}
+
+func TestProbeCapabilities_TracingProviderLeavesSummaryClear(t *testing.T) {
+	logs := captureLogs(t)
+	ing := NewWithProvider("0x", Options{}, &fakeProv{})
+	ing.probeCapabilities(context.Background(), 1)
+	if ing.Summary().TracesUnavailable || logs.Len() != 0 {
+		t.Fatalf("unexpected degradation: summary=%+v logs=%q", ing.Summary(), logs.String())
+	}
+}
@@ -0,0 +1,114 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// rangeLogProv emits one log per requested range, stamped with the range start.
+type rangeLogProv struct {
+	head   uint64
+	ranges []struct{ from, to uint64 }
+}
+
+func (p *rangeLogProv) BlockNumber(ctx context.Context) (uint64, error) { return p.head, nil }
+func (p *rangeLogProv) BlockTimestamp(ctx context.Context, block uint64) (int64, error) {
+	return int64(block) * 1000, nil
+}
+func (p *rangeLogProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.ranges = append(p.ranges, struct{ from, to uint64 }{from, to})
+	return []eth.Log{{TxHash: "0x" + strings.Repeat("1", 64), Index: uint32(from), Address: address, BlockNum: from}}, nil
+}
+func (p *rangeLogProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	return nil, eth.ErrUnsupported
+}
+func (p *rangeLogProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	return nil, eth.ErrUnsupported
+}
+
+func unconfirmedByBlock(t *testing.T, payloads []string) map[uint64]uint8 {
+	t.Helper()
+	out := map[uint64]uint8{}
+	for _, p := range payloads {
+		for _, line := range strings.Split(strings.TrimSpace(p), "\n") {
+			var row struct {
+				Block       uint64 `json:"block_number"`
+				Unconfirmed uint8  `json:"unconfirmed"`
+			}
+			if err := json.Unmarshal([]byte(line), &row); err != nil {
+				t.Fatalf("decode %q: %v", line, err)
+			}
+			out[row.Block] = row.Unconfirmed
+		}
+	}
+	return out
+}
+
+func TestBackfill_IncludeUnconfirmedFlagsTipAndKeepsCheckpoint(t *testing.T) {
+	defer withTimeNow(t, time.Unix(1_700_000_000, 0))()
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &rangeLogProv{head: 20}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 5, BatchBlocks: 100, IncludeUnconfirmed: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	flags := unconfirmedByBlock(t, inserts["logs"])
+	if flags[0] != 0 || flags[16] != 1 || len(flags) != 2 {
+		t.Fatalf("unexpected unconfirmed flags: %v", flags)
+	}
+	if len(prov.ranges) != 2 || prov.ranges[0].from != 16 || prov.ranges[0].to != 20 {
+		t.Fatalf("unexpected ranges: %v", prov.ranges)
+	}
+	ckpt := inserts["addresses"]
+	if len(ckpt) != 1 || !strings.Contains(ckpt[0], `"last_synced_block":15`) {
+		t.Fatalf("checkpoint advanced past safe head: %v", ckpt)
+	}
+}
+
+func TestDelta_IncludeUnconfirmedBeforeSafeHeadExists(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &rangeLogProv{head: 3}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Confirmations: 12, BatchBlocks: 2, IncludeUnconfirmed: true}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	flags := unconfirmedByBlock(t, inserts["logs"])
+	if flags[0] != 1 || flags[2] != 1 || len(flags) != 2 {
+		t.Fatalf("unexpected unconfirmed flags: %v", flags)
+	}
+	if len(inserts["addresses"]) != 0 {
+		t.Fatalf("unexpected checkpoint writes: %v", inserts["addresses"])
+	}
+}
+
+func TestProcessUnconfirmed_SkipsWhenDisabledOrDevOrBounded(t *testing.T) {
+	prov := &rangeLogProv{head: 20}
+	for _, opts := range []Options{
+		{Confirmations: 5},
+		{Confirmations: 5, IncludeUnconfirmed: true, Schema: "dev"},
+		{Confirmations: 0, IncludeUnconfirmed: true},
+		{Confirmations: 5, IncludeUnconfirmed: true, ToBlock: 10},
+	} {
+		ing := NewWithProvider("0x", opts, prov)
+		if err := ing.processUnconfirmed(context.Background(), 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(prov.ranges) != 0 {
+		t.Fatalf("expected no unconfirmed ranges, got %v", prov.ranges)
+	}
+}
+
+func TestProcessUnconfirmed_PropagatesErrors(t *testing.T) {
+	ing := NewWithProvider("0x", Options{Confirmations: 1, IncludeUnconfirmed: true}, provGetLogsErr{})
+	if err := ing.processUnconfirmed(context.Background(), 5); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,74 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func upToDateIngester(t *testing.T, head uint64, opts Options) (*Ingester, *captureProv, *cursorRoundTripper) {
+	t.Helper()
+	prov := &captureProv{head: head}
+	opts.ClickHouseDSN = "http://localhost:8123/db"
+	ing := NewWithProvider("0xabc", opts, prov)
+	prev := addressCheckpoint{Address: "0xabc", LastSyncedBlock: 50, LastBackfillAt: fmtDT64(1_000), LastDeltaAt: fmtDT64(2_000), UpdatedAt: fmtDT64(3_000)}
+	payload, err := json.Marshal(prev)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	rt := &cursorRoundTripper{t: t, selectResponse: string(payload) + "\n"}
+	ing.ch.SetTransport(rt)
+	return ing, prov, rt
+}
+
+func TestDeltaReportsUpToDateAndRefreshesCheckpoint(t *testing.T) {
+	defer withTimeNow(t, time.UnixMilli(9_000))()
+	cases := []struct {
+		name string
+		head uint64
+		opts Options
+	}{
+		{"at head", 50, Options{ReportUpToDate: true}},
+		{"below confirmations", 5, Options{ReportUpToDate: true, Confirmations: 12}},
+		{"at safe head", 62, Options{ReportUpToDate: true, Confirmations: 12}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing, prov, rt := upToDateIngester(t, tc.head, tc.opts)
+			if err := ing.Delta(context.Background()); !errors.Is(err, ErrUpToDate) {
+				t.Fatalf("err = %v, want ErrUpToDate", err)
+			}
+			if len(prov.calls) != 0 {
+				t.Fatalf("unexpected fetches: %+v", prov.calls)
+			}
+			if len(rt.inserts) != 1 {
+				t.Fatalf("expected one checkpoint insert, got %d", len(rt.inserts))
+			}
+			var row addressCheckpoint
+			if err := json.Unmarshal([]byte(strings.TrimSpace(rt.inserts[0])), &row); err != nil {
+				t.Fatalf("decode insert: %v", err)
+			}
+			if row.LastSyncedBlock != 50 || row.LastDeltaAt != fmtDT64(9_000) {
+				t.Fatalf("checkpoint = %+v", row)
+			}
+		})
+	}
+}
+
+func TestDeltaUpToDateIsNilByDefault(t *testing.T) {
+	ing, _, rt := upToDateIngester(t, 50, Options{})
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if len(rt.inserts) != 1 {
+		t.Fatalf("expected checkpoint refresh, got %d inserts", len(rt.inserts))
+	}
+	// New blocks are processed normally even when reporting is enabled.
+	ing, prov, _ := upToDateIngester(t, 60, Options{ReportUpToDate: true})
+	if err := ing.Delta(context.Background()); err != nil || len(prov.calls) == 0 {
+		t.Fatalf("err=%v calls=%d", err, len(prov.calls))
+	}
+}
@@ -0,0 +1,55 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewValidated_ReturnsErrorsInsteadOfPanicking(t *testing.T) {
+	addr := "0x" + strings.Repeat("A", 40)
+	cases := []struct {
+		name    string
+		address string
+		opts    Options
+		want    string
+	}{
+		{"empty address", "", Options{}, "invalid address"},
+		{"short address", "0xabc", Options{}, "invalid address"},
+		{"non-hex address", "0x" + strings.Repeat("z", 40), Options{}, "invalid address"},
+		{"bad schema", addr, Options{Schema: "bogus"}, "invalid schema mode"},
+		{"bad table", addr, Options{Tables: []string{"nope"}}, "unknown table"},
+		{"bad indexed-amount token", addr, Options{IndexedAmountTokens: []string{"0x12"}}, "invalid indexed-amount token"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("unexpected panic: %v", r)
+				}
+			}()
+			ing, err := NewValidated(tc.address, tc.opts)
+			if err == nil || ing != nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("ing=%v err=%v, want error containing %q", ing, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewValidated_NormalizesOptions(t *testing.T) {
+	ing, err := NewValidated(" 0x"+strings.Repeat("AB", 20)+" ", Options{Schema: "DEV", Tables: []string{" Logs ", "logs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ing.address != "0x"+strings.Repeat("ab", 20) || ing.SchemaMode() != "dev" || len(ing.opts.Tables) != 1 || ing.ch == nil || ing.prov != nil {
+		t.Fatalf("unexpected ingester: address=%q schema=%q tables=%v", ing.address, ing.SchemaMode(), ing.opts.Tables)
+	}
+}
+
+func TestNew_StillPanicsOnInvalidSchema(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	New("0x", Options{Schema: "bogus"})
+}
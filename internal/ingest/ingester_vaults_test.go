@@ -0,0 +1,46 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestProcessRange_VaultEventRows(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	router := "0x" + strings.Repeat("b", 40)
+	vault := "0x" + strings.Repeat("e", 40)
+	word := func(s string) string { return strings.Repeat("0", 64-len(s)) + s }
+	prov := tablesFixture(addr)
+	prov.logs = append(prov.logs,
+		eth.Log{
+			TxHash: "0x1", Index: 2, Address: vault,
+			Topics:   []string{"0xdcbc1c05240f31ff3ad067ef1ee35ce4997762752e3a095284754544f4c709d7", padTopicAddr(addr), padTopicAddr(addr)},
+			DataHex:  "0x" + word("64") + word("5a"),
+			BlockNum: 1,
+		},
+		eth.Log{
+			TxHash: "0x1", Index: 3, Address: vault,
+			Topics:   []string{"0xfbde797d201c681b91056529119e0b02407c7bb96a4a2c75c01fc9667232c8db", padTopicAddr(router), padTopicAddr(router), padTopicAddr(addr)},
+			DataHex:  "0x" + word("32") + word("2d"),
+			BlockNum: 1,
+		},
+	)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Join(inserts["vault_events"], "")
+	for _, want := range []string{
+		`"action":"deposit","assets":"100"`, `"receiver":"","sender":"` + addr + `","shares":"90"`,
+		`"action":"withdraw","assets":"50"`, `"owner":"` + addr + `","receiver":"` + router + `"`, `"shares":"45"`,
+		`"vault":"` + vault + `"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("vault row missing %s: %s", want, body)
+		}
+	}
+}
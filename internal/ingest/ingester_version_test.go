@@ -0,0 +1,99 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// versionsByTable decodes every inserted row and returns its version column
+// (ingested_at, or updated_at for contracts) per table.
+func versionsByTable(t *testing.T, inserts map[string][]string) map[string][]string {
+	t.Helper()
+	out := map[string][]string{}
+	for table, payloads := range inserts {
+		if table == "addresses" {
+			continue
+		}
+		col := "ingested_at"
+		if table == "contracts" {
+			col = "updated_at"
+		}
+		for _, p := range payloads {
+			for _, line := range strings.Split(strings.TrimSpace(p), "\n") {
+				var row map[string]any
+				if err := json.Unmarshal([]byte(line), &row); err != nil {
+					t.Fatalf("%s: %v", table, err)
+				}
+				v, ok := row[col].(string)
+				if !ok {
+					t.Fatalf("%s row missing %s: %s", table, col, line)
+				}
+				out[table] = append(out[table], v)
+			}
+		}
+	}
+	return out
+}
+
+func TestCanonicalRows_CarryMonotonicVersion(t *testing.T) {
+	defer withTimeNow(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))()
+	addr := "0x" + strings.Repeat("a", 40)
+	fx := tablesFixture(addr)
+	fx.txs[0].To, fx.txs[0].ContractAddress = "", "0x"+strings.Repeat("d", 40)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &fx)
+	inserts := captureInserts(t, ing)
+
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	first := versionsByTable(t, inserts)
+	for _, table := range []string{"logs", "token_transfers", "approvals", "contracts", "transactions", "traces"} {
+		if len(first[table]) == 0 {
+			t.Fatalf("no versioned rows for %s: %v", table, first)
+		}
+	}
+	if got := first["logs"][0]; got != "2024-01-02 03:04:05.000" {
+		t.Fatalf("first version = %q, want the current UTC millisecond", got)
+	}
+
+	// Reprocess the same range within the same millisecond: every table's
+	// new rows must still sort after the rows they replace.
+	for table := range inserts {
+		inserts[table] = nil
+	}
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	second := versionsByTable(t, inserts)
+	for table, olds := range first {
+		news := second[table]
+		if len(news) != len(olds) {
+			t.Fatalf("%s: %d rows then %d", table, len(olds), len(news))
+		}
+		for k := range olds {
+			if news[k] <= olds[k] {
+				t.Fatalf("%s version not monotonic: %s then %s", table, olds[k], news[k])
+			}
+		}
+	}
+}
+
+func TestRowVersion_ClockAndDeterministic(t *testing.T) {
+	restore := withTimeNow(t, time.UnixMilli(5000).UTC())
+	ing := New("", Options{})
+	if a, b := ing.rowVersion(), ing.rowVersion(); a != fmtDT64(5000) || b != fmtDT64(5001) {
+		t.Fatalf("versions = %s, %s", a, b)
+	}
+	restore()
+	defer withTimeNow(t, time.UnixMilli(9000).UTC())()
+	if v := ing.rowVersion(); v != fmtDT64(9000) {
+		t.Fatalf("clock advance ignored: %s", v)
+	}
+	det := New("", Options{Deterministic: true})
+	if a, b := det.rowVersion(), det.rowVersion(); a != fmtDT64(1) || b != fmtDT64(2) {
+		t.Fatalf("deterministic versions = %s, %s", a, b)
+	}
+}
@@ -0,0 +1,82 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// withdrawalProv is a fixtureProv whose node reports block withdrawals.
+type withdrawalProv struct {
+	fixtureProv
+	withdrawals []eth.Withdrawal
+	err         error
+	calls       int
+}
+
+func (p *withdrawalProv) Withdrawals(ctx context.Context, address string, from, to uint64) ([]eth.Withdrawal, error) {
+	p.calls++
+	return p.withdrawals, p.err
+}
+
+func TestProcessRange_WithdrawalRows(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &withdrawalProv{fixtureProv: tablesFixture(addr), withdrawals: []eth.Withdrawal{
+		{Index: 42, Validator: 7, Address: addr, AmountGwei: "0x3b9aca00", BlockNum: 1, TsMillis: 1000},
+	}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Join(inserts["withdrawals"], "")
+	for _, want := range []string{`"withdrawal_index":42`, `"validator_index":7`, `"address":"` + addr + `"`, `"amount_gwei":"1000000000"`, `"amount_raw":"1000000000000000000"`, `"block_number":1`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("withdrawal row missing %s: %s", want, body)
+		}
+	}
+}
+
+func TestProcessRange_WithdrawalsSkipped(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	cases := map[string]Options{
+		"only tables": {ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"logs"}},
+		"dev schema":  {ClickHouseDSN: "http://localhost:8123/db", Schema: "dev"},
+		"contract":    {ClickHouseDSN: "http://localhost:8123/db", ContractMode: true},
+	}
+	for name, opts := range cases {
+		prov := &withdrawalProv{fixtureProv: tablesFixture(addr)}
+		ing := NewWithProvider(addr, opts, prov)
+		captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if prov.calls != 0 {
+			t.Fatalf("%s: withdrawals fetched %d times", name, prov.calls)
+		}
+	}
+}
+
+func TestProcessRange_WithdrawalErrors(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &withdrawalProv{fixtureProv: tablesFixture(addr), err: eth.ErrUnsupported}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatalf("unsupported withdrawals must not fail the range: %v", err)
+	}
+	if len(inserts["withdrawals"]) != 0 {
+		t.Fatalf("unexpected withdrawal inserts: %v", inserts["withdrawals"])
+	}
+
+	boom := errors.New("boom")
+	prov = &withdrawalProv{fixtureProv: tablesFixture(addr), err: boom}
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); !errors.Is(err, boom) {
+		t.Fatalf("expected fetch error, got %v", err)
+	}
+}
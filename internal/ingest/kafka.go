@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultKafkaTopic names the topic each table's rows are published to;
+// "{table}" is replaced by the table name.
+const DefaultKafkaTopic = "wallet_context.{table}"
+
+// KafkaMessage is one record handed to a KafkaProducer.
+type KafkaMessage struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaProducer publishes records to Kafka. It is the dependency boundary of
+// KafkaSink: the ingester ships a REST proxy producer (NewKafkaRESTProducer)
+// without linking a Kafka client, and library callers can plug in a native
+// one through Options.KafkaProducer.
+type KafkaProducer interface {
+	// Produce queues or sends msgs, failing when any is rejected.
+	Produce(ctx context.Context, msgs []KafkaMessage) error
+	// Flush returns once every produced message is acknowledged, or with
+	// the first delivery error.
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+// KafkaSink publishes each normalized row as a JSON message keyed by the
+// ingested address, so a consumer sees one address's rows in order within
+// a partition. Every insert is flushed before it returns, so a range's rows
+// are delivered before its checkpoint advances (at-least-once: re-ingested
+// ranges are published again). Each message carries a "table" header for
+// producers that support record headers.
+type KafkaSink struct {
+	p     KafkaProducer
+	topic string
+	key   []byte
+}
+
+// NewKafkaSink publishes rows for address through p. topic may contain
+// "{table}" (empty selects DefaultKafkaTopic).
+func NewKafkaSink(p KafkaProducer, topic, address string) *KafkaSink {
+	if topic == "" {
+		topic = DefaultKafkaTopic
+	}
+	return &KafkaSink{p: p, topic: topic, key: []byte(strings.ToLower(address))}
+}
+
+// InsertJSONEachRow publishes rows to table's topic and waits for delivery.
+func (s *KafkaSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	topic := strings.ReplaceAll(s.topic, "{table}", table)
+	msgs := make([]KafkaMessage, 0, len(rows))
+	for i, row := range rows {
+		v, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("encode row %d: %w", i, err)
+		}
+		msgs = append(msgs, KafkaMessage{Topic: topic, Key: s.key, Value: v, Headers: map[string]string{"table": table}})
+	}
+	if err := s.p.Produce(ctx, msgs); err != nil {
+		return fmt.Errorf("publishing %s to kafka: %w", table, err)
+	}
+	if err := s.p.Flush(ctx); err != nil {
+		return fmt.Errorf("flushing %s to kafka: %w", table, err)
+	}
+	return nil
+}
+
+// Close flushes what is still queued and closes the producer.
+func (s *KafkaSink) Close(ctx context.Context) error {
+	ferr := s.p.Flush(ctx)
+	cerr := s.p.Close()
+	if ferr != nil {
+		return fmt.Errorf("flushing kafka: %w", ferr)
+	}
+	return cerr
+}
@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kafkaRESTContentType is the REST Proxy v2 embedded-JSON produce format,
+// served by Confluent REST Proxy and Redpanda's HTTP Proxy alike.
+const kafkaRESTContentType = "application/vnd.kafka.json.v2+json"
+
+// maxKafkaRESTResponse bounds a produce response (a list of offsets).
+const maxKafkaRESTResponse = 1 << 20
+
+// kafkaRESTProducer sends messages synchronously through a Kafka REST
+// proxy, one request per topic, so Flush has nothing left to wait for.
+// REST Proxy v2 has no record headers; the topic names the table instead.
+type kafkaRESTProducer struct {
+	brokers []string
+	hc      *http.Client
+}
+
+// NewKafkaRESTProducer publishes through the REST proxies at brokers
+// (http(s) base URLs), trying each in order until one accepts a batch. A
+// nil client gets a 30s timeout.
+func NewKafkaRESTProducer(brokers []string, hc *http.Client) (KafkaProducer, error) {
+	var out []string
+	for _, b := range brokers {
+		b = strings.TrimRight(strings.TrimSpace(b), "/")
+		if b == "" {
+			continue
+		}
+		u, err := url.Parse(b)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid kafka REST proxy %q: want an http(s) URL", b)
+		}
+		out = append(out, b)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no kafka REST proxy configured")
+	}
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &kafkaRESTProducer{brokers: out, hc: hc}, nil
+}
+
+type kafkaRESTRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaRESTResponse struct {
+	Offsets []struct {
+		Partition *int32  `json:"partition"`
+		Offset    *int64  `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Produce sends msgs grouped by topic, in first-seen topic order.
+func (p *kafkaRESTProducer) Produce(ctx context.Context, msgs []KafkaMessage) error {
+	var topics []string
+	byTopic := make(map[string][]kafkaRESTRecord)
+	for _, m := range msgs {
+		if _, ok := byTopic[m.Topic]; !ok {
+			topics = append(topics, m.Topic)
+		}
+		byTopic[m.Topic] = append(byTopic[m.Topic], kafkaRESTRecord{Key: string(m.Key), Value: m.Value})
+	}
+	for _, topic := range topics {
+		body, err := json.Marshal(map[string]any{"records": byTopic[topic]})
+		if err != nil {
+			return err
+		}
+		if err := p.send(ctx, topic, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send posts one batch, moving to the next proxy on transport errors, 429
+// and 5xx answers. Rejections (other 4xx), per-record errors and unreadable
+// 2xx answers fail immediately.
+func (p *kafkaRESTProducer) send(ctx context.Context, topic string, body []byte) error {
+	var errs []error
+	for _, broker := range p.brokers {
+		err := p.post(ctx, broker+"/topics/"+url.PathEscape(topic), body)
+		if err == nil {
+			return nil
+		}
+		var rej *kafkaRejectedError
+		if errors.As(err, &rej) || ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+	}
+	return fmt.Errorf("topic %s: %w", topic, errors.Join(errs...))
+}
+
+// kafkaRejectedError reports a batch the proxy refused, which another proxy
+// would refuse as well, or whose fate is unknown after a 2xx answer.
+type kafkaRejectedError struct{ msg string }
+
+func (e *kafkaRejectedError) Error() string { return e.msg }
+
+func (p *kafkaRESTProducer) post(ctx context.Context, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaRESTContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json, application/json")
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxKafkaRESTResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := fmt.Sprintf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return &kafkaRejectedError{msg: msg}
+		}
+		return errors.New(msg)
+	}
+	var out kafkaRESTResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		// The proxy answered 2xx, so the batch may well be stored; sending
+		// it to another proxy would duplicate it.
+		return &kafkaRejectedError{msg: fmt.Sprintf("decoding produce response: %v", err)}
+	}
+	for i, o := range out.Offsets {
+		if o.ErrorCode != nil || o.Error != nil {
+			msg := ""
+			if o.Error != nil {
+				msg = *o.Error
+			}
+			code := 0
+			if o.ErrorCode != nil {
+				code = *o.ErrorCode
+			}
+			return &kafkaRejectedError{msg: fmt.Sprintf("record %d not delivered (error_code %d): %s", i, code, msg)}
+		}
+	}
+	return nil
+}
+
+// Flush is a no-op: Produce returns once the proxy acknowledged the batch.
+func (p *kafkaRESTProducer) Flush(ctx context.Context) error { return nil }
+
+// Close releases idle proxy connections.
+func (p *kafkaRESTProducer) Close() error {
+	p.hc.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,190 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// mockProducer queues produced messages until Flush delivers them.
+type mockProducer struct {
+	queued     []KafkaMessage
+	delivered  []KafkaMessage
+	produceErr error
+	flushErr   error
+	flushes    int
+	closed     bool
+}
+
+func (p *mockProducer) Produce(ctx context.Context, msgs []KafkaMessage) error {
+	if p.produceErr != nil {
+		return p.produceErr
+	}
+	p.queued = append(p.queued, msgs...)
+	return nil
+}
+
+func (p *mockProducer) Flush(ctx context.Context) error {
+	p.flushes++
+	if p.flushErr != nil {
+		return p.flushErr
+	}
+	p.delivered = append(p.delivered, p.queued...)
+	p.queued = nil
+	return nil
+}
+
+func (p *mockProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestProcessRange_PublishesRowsToKafka(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	mp := &mockProducer{}
+	ing := NewWithProvider(strings.ToUpper(addr[:2])+strings.ToUpper(addr[2:]), Options{ClickHouseDSN: "http://localhost:8123/db", KafkaProducer: mp, KafkaTopic: "wallets.{table}"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(mp.queued) != 0 || len(mp.delivered) == 0 {
+		t.Fatalf("queued=%d delivered=%d; every insert must be flushed", len(mp.queued), len(mp.delivered))
+	}
+	perTopic := map[string]int{}
+	for _, m := range mp.delivered {
+		if string(m.Key) != addr {
+			t.Fatalf("key = %q, want %q", m.Key, addr)
+		}
+		table := m.Headers["table"]
+		if m.Topic != "wallets."+table {
+			t.Fatalf("topic %q for table %q", m.Topic, table)
+		}
+		var row map[string]any
+		if err := json.Unmarshal(m.Value, &row); err != nil {
+			t.Fatalf("value is not a JSON row: %s", m.Value)
+		}
+		perTopic[m.Topic]++
+	}
+	for _, table := range []string{"logs", "token_transfers", "approvals", "transactions", "traces"} {
+		if want := strings.Count(strings.Join(inserts[table], ""), "\n"); perTopic["wallets."+table] != want || want == 0 {
+			t.Fatalf("%s: %d messages, %d ClickHouse rows", table, perTopic["wallets."+table], want)
+		}
+	}
+	if err := ing.Close(context.Background()); err != nil || !mp.closed {
+		t.Fatalf("close err=%v closed=%v", err, mp.closed)
+	}
+}
+
+func TestKafkaSink_DeliveryErrors(t *testing.T) {
+	rows := []any{map[string]any{"tx_hash": "0x1"}}
+	boom := errors.New("broker down")
+	s := NewKafkaSink(&mockProducer{produceErr: boom}, "", "0xabc")
+	if err := s.InsertJSONEachRow(context.Background(), "logs", rows); !errors.Is(err, boom) {
+		t.Fatalf("expected produce error, got %v", err)
+	}
+	mp := &mockProducer{flushErr: boom}
+	s = NewKafkaSink(mp, "", "0xabc")
+	if err := s.InsertJSONEachRow(context.Background(), "logs", rows); !errors.Is(err, boom) {
+		t.Fatalf("expected flush error, got %v", err)
+	}
+	if err := s.Close(context.Background()); !errors.Is(err, boom) || !mp.closed {
+		t.Fatalf("close must flush and close: err=%v closed=%v", err, mp.closed)
+	}
+	if err := s.InsertJSONEachRow(context.Background(), "logs", []any{func() {}}); err == nil {
+		t.Fatal("expected encode error")
+	}
+	if err := s.InsertJSONEachRow(context.Background(), "logs", nil); err != nil {
+		t.Fatal(err)
+	}
+	if mp.queued[0].Topic != "wallet_context.logs" {
+		t.Fatalf("default topic = %q", mp.queued[0].Topic)
+	}
+}
+
+func TestKafkaRESTProducer(t *testing.T) {
+	var bodies []string
+	var urls []string
+	client := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		urls = append(urls, r.URL.String())
+		if r.URL.Host == "down:8082" {
+			return &http.Response{StatusCode: 503, Body: io.NopCloser(strings.NewReader("unavailable"))}, nil
+		}
+		if ct := r.Header.Get("Content-Type"); ct != kafkaRESTContentType {
+			t.Fatalf("content type %q", ct)
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"offsets":[{"partition":0,"offset":1}]}`))}, nil
+	})}
+	p, err := NewKafkaRESTProducer([]string{"http://down:8082/", " http://up:8082 "}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []KafkaMessage{
+		{Topic: "t.logs", Key: []byte("0xabc"), Value: []byte(`{"a":1}`)},
+		{Topic: "t.traces", Key: []byte("0xabc"), Value: []byte(`{"b":2}`)},
+		{Topic: "t.logs", Key: []byte("0xabc"), Value: []byte(`{"a":3}`)},
+	}
+	if err := p.Produce(context.Background(), msgs); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"records":[{"key":"0xabc","value":{"a":1}},{"key":"0xabc","value":{"a":3}}]}`,
+		`{"records":[{"key":"0xabc","value":{"b":2}}]}`,
+	}
+	if len(bodies) != 2 || bodies[0] != want[0] || bodies[1] != want[1] {
+		t.Fatalf("bodies = %q", bodies)
+	}
+	if urls[0] != "http://down:8082/topics/t.logs" || urls[1] != "http://up:8082/topics/t.logs" {
+		t.Fatalf("urls = %v; want failover to the second proxy", urls)
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKafkaRESTProducer_Errors(t *testing.T) {
+	for _, brokers := range [][]string{nil, {" "}, {"kafka:9092"}, {"ftp://proxy"}} {
+		if _, err := NewKafkaRESTProducer(brokers, nil); err == nil {
+			t.Fatalf("brokers %q: expected error", brokers)
+		}
+	}
+	respond := func(status int, body string) *http.Client {
+		calls := 0
+		return &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			if calls > 1 {
+				t.Fatalf("rejected batch retried on %s", r.URL.Host)
+			}
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+		})}
+	}
+	msgs := []KafkaMessage{{Topic: "t", Key: []byte("k"), Value: []byte(`{}`)}}
+	cases := map[string]*http.Client{
+		"rejected":  respond(422, `{"error_code":42201,"message":"bad"}`),
+		"record":    respond(200, `{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"leader not available"}]}`),
+		"malformed": respond(200, `not json`),
+	}
+	for name, client := range cases {
+		p, _ := NewKafkaRESTProducer([]string{"http://a:8082", "http://b:8082"}, client)
+		if err := p.Produce(context.Background(), msgs); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	down := &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+	p, _ := NewKafkaRESTProducer([]string{"http://a:8082", "http://b:8082"}, down)
+	err := p.Produce(context.Background(), msgs)
+	if err == nil || !strings.Contains(err.Error(), "http://a:8082") || !strings.Contains(err.Error(), "http://b:8082") {
+		t.Fatalf("expected both proxies in error, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+const (
+	// RunLocksTable holds advisory per-address run locks (Options.RunLock).
+	RunLocksTable = "run_locks"
+	// DefaultLockTTL is how long a lock stays fresh without a heartbeat when
+	// Options.LockTTL is unset.
+	DefaultLockTTL = 2 * time.Minute
+	// lockReleaseTimeout bounds the release write, which runs even after the
+	// run's context is done.
+	lockReleaseTimeout = 5 * time.Second
+)
+
+// ErrAddressLocked is returned by Backfill and Delta (with Options.RunLock)
+// when another run holds a fresh lock on the address.
+var ErrAddressLocked = errors.New("ingest: address locked by another run")
+
+// runLock mirrors a run_locks row.
+type runLock struct {
+	Owner       string `json:"owner"`
+	AcquiredAt  string `json:"acquired_at"`
+	HeartbeatAt string `json:"heartbeat_at"`
+	ExpiresAt   string `json:"expires_at"`
+	Released    uint8  `json:"released"`
+}
+
+// acquireRunLock takes the address's advisory lock in ClickHouse and starts a
+// heartbeat that keeps it fresh; the returned func stops the heartbeat and
+// releases the lock. Without Options.RunLock or a ClickHouse DSN it is a
+// no-op.
+//
+// ClickHouse offers no compare-and-set, so acquisition is check, write,
+// verify: after writing its row a run re-reads the fresh locks and backs off
+// unless it holds the oldest one. Two runs racing on the same address thus
+// agree on a single winner once both rows are visible.
+func (i *Ingester) acquireRunLock(ctx context.Context) (func(), error) {
+	if !i.opts.RunLock || i.ch == nil || !i.ch.Enabled() {
+		return func() {}, nil
+	}
+	if held, err := i.freshLocks(ctx); err != nil {
+		return nil, err
+	} else if len(held) > 0 {
+		return nil, i.lockedBy(held[0])
+	}
+	acquired := timeNow().UTC()
+	if err := i.writeRunLock(ctx, acquired, false); err != nil {
+		return nil, err
+	}
+	held, err := i.freshLocks(ctx)
+	if err != nil {
+		i.releaseRunLock(ctx, acquired)
+		return nil, err
+	}
+	mine := fmtDT64(acquired.UnixMilli())
+	if len(held) > 0 && (held[0].AcquiredAt < mine || (held[0].AcquiredAt == mine && held[0].Owner < i.lockOwner)) {
+		i.releaseRunLock(ctx, acquired)
+		return nil, i.lockedBy(held[0])
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go i.heartbeatRunLock(ctx, acquired, stop, done)
+	return func() {
+		close(stop)
+		<-done
+		i.releaseRunLock(ctx, acquired)
+	}, nil
+}
+
+// freshLocks returns the unreleased, unexpired locks other owners hold on the
+// address, oldest first (ties broken by owner).
+func (i *Ingester) freshLocks(ctx context.Context) ([]runLock, error) {
+	query := fmt.Sprintf("SELECT owner, acquired_at, heartbeat_at, expires_at, released FROM %s WHERE address = '%s' ORDER BY heartbeat_at DESC, released DESC LIMIT 1 BY owner FORMAT JSONEachRow", RunLocksTable, quoteCHString(i.address))
+	rows, err := i.ch.QueryJSONEachRow(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", RunLocksTable, err)
+	}
+	now := fmtDT64(timeNow().UTC().UnixMilli())
+	var held []runLock
+	for _, raw := range rows {
+		var l runLock
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", RunLocksTable, err)
+		}
+		if l.Released != 0 || l.Owner == i.lockOwner || l.ExpiresAt <= now {
+			continue
+		}
+		held = append(held, l)
+	}
+	sort.Slice(held, func(a, b int) bool {
+		if held[a].AcquiredAt != held[b].AcquiredAt {
+			return held[a].AcquiredAt < held[b].AcquiredAt
+		}
+		return held[a].Owner < held[b].Owner
+	})
+	return held, nil
+}
+
+func (i *Ingester) lockedBy(l runLock) error {
+	return fmt.Errorf("%w: %s held by %s until %s", ErrAddressLocked, i.address, l.Owner, l.ExpiresAt)
+}
+
+// writeRunLock records the lock as held (heartbeat) or released.
+func (i *Ingester) writeRunLock(ctx context.Context, acquired time.Time, released bool) error {
+	now := timeNow().UTC()
+	row := map[string]any{
+		"address":      i.address,
+		"owner":        i.lockOwner,
+		"acquired_at":  fmtDT64(acquired.UnixMilli()),
+		"heartbeat_at": fmtDT64(now.UnixMilli()),
+		"expires_at":   fmtDT64(now.Add(i.lockTTL()).UnixMilli()),
+		"released":     uint8(0),
+	}
+	if released {
+		row["expires_at"] = fmtDT64(now.UnixMilli())
+		row["released"] = uint8(1)
+	}
+	if err := i.ch.InsertJSONEachRow(ctx, RunLocksTable, []any{row}); err != nil {
+		return fmt.Errorf("inserting %s: %w", RunLocksTable, err)
+	}
+	return nil
+}
+
+// heartbeatRunLock refreshes the lock every third of its TTL until stop is
+// closed. Failures are logged; the lock then lapses after its TTL.
+func (i *Ingester) heartbeatRunLock(ctx context.Context, acquired time.Time, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(i.lockTTL() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := i.writeRunLock(ctx, acquired, false); err != nil {
+				i.warnRunLock("run_lock_heartbeat_failed", err)
+			}
+		}
+	}
+}
+
+// releaseRunLock marks the lock released, even when ctx is already done.
+func (i *Ingester) releaseRunLock(ctx context.Context, acquired time.Time) {
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockReleaseTimeout)
+	defer cancel()
+	if err := i.writeRunLock(rctx, acquired, true); err != nil {
+		i.warnRunLock("run_lock_release_failed", err)
+	}
+}
+
+func (i *Ingester) warnRunLock(event string, err error) {
+	if logger := logging.Logger(); logger != nil {
+		logger.Warn(event,
+			"component", "ingest",
+			"address", i.address,
+			"owner", i.lockOwner,
+			"error", err.Error(),
+		)
+	}
+}
+
+func (i *Ingester) lockTTL() time.Duration {
+	if i.opts.LockTTL > 0 {
+		return i.opts.LockTTL
+	}
+	return DefaultLockTTL
+}
+
+// newLockOwner identifies this run in run_locks as host:pid:random.
+func newLockOwner() string {
+	host, _ := os.Hostname()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lockCH fakes ClickHouse for run locks: each run_locks SELECT returns the
+// next canned body (the last one repeats), other SELECTs return nothing, and
+// INSERT bodies are recorded per table.
+type lockCH struct {
+	selects []string
+	queried int
+	inserts map[string][]string
+}
+
+func (f *lockCH) install(ing *Ingester) {
+	f.inserts = map[string][]string{}
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		body := ""
+		switch {
+		case strings.HasPrefix(q, "INSERT INTO "):
+			b, _ := io.ReadAll(r.Body)
+			table := strings.Fields(q)[2]
+			f.inserts[table] = append(f.inserts[table], string(b))
+		case strings.Contains(q, "FROM "+RunLocksTable):
+			if len(f.selects) > 0 {
+				body = f.selects[min(f.queried, len(f.selects)-1)]
+			}
+			f.queried++
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+}
+
+func lockRow(owner, acquired, expires string, released int) string {
+	return fmt.Sprintf(`{"owner":%q,"acquired_at":%q,"heartbeat_at":%q,"expires_at":%q,"released":%d}`+"\n", owner, acquired, acquired, expires, released)
+}
+
+func lockedIngester(t *testing.T) (*Ingester, *countingProv) {
+	t.Helper()
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: fixtureProv{head: 10}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", RunLock: true, ToBlock: 5}, prov)
+	ing.lockOwner = "me"
+	return ing, prov
+}
+
+func TestRunLock_FreshLockRejects(t *testing.T) {
+	defer withTimeNow(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))()
+	ing, prov := lockedIngester(t)
+	fake := &lockCH{selects: []string{lockRow("other", "2025-01-01 11:59:00.000", "2025-01-01 12:01:00.000", 0)}}
+	fake.install(ing)
+	err := ing.Backfill(context.Background())
+	if !errors.Is(err, ErrAddressLocked) || !strings.Contains(err.Error(), "held by other") {
+		t.Fatalf("expected ErrAddressLocked, got %v", err)
+	}
+	if len(fake.inserts) != 0 || prov.logCalls != 0 {
+		t.Fatalf("rejected run wrote %v / fetched %d logs", fake.inserts, prov.logCalls)
+	}
+	if err := ing.Delta(context.Background()); !errors.Is(err, ErrAddressLocked) {
+		t.Fatalf("delta: expected ErrAddressLocked, got %v", err)
+	}
+}
+
+func TestRunLock_ExpiredOrReleasedLockProceeds(t *testing.T) {
+	defer withTimeNow(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))()
+	ing, prov := lockedIngester(t)
+	fake := &lockCH{selects: []string{
+		lockRow("stale", "2025-01-01 11:00:00.000", "2025-01-01 11:02:00.000", 0) +
+			lockRow("done", "2025-01-01 11:59:00.000", "2025-01-01 12:05:00.000", 1),
+	}}
+	fake.install(ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if prov.logCalls == 0 {
+		t.Fatal("expected the run to proceed")
+	}
+	rows := fake.inserts[RunLocksTable]
+	if len(rows) != 2 || fake.queried != 2 {
+		t.Fatalf("lock rows=%v queries=%d, want acquire+release after check+verify", rows, fake.queried)
+	}
+	for _, want := range []string{`"owner":"me"`, `"released":0`, `"acquired_at":"2025-01-01 12:00:00.000"`, `"expires_at":"2025-01-01 12:02:00.000"`} {
+		if !strings.Contains(rows[0], want) {
+			t.Fatalf("acquire row missing %s: %s", want, rows[0])
+		}
+	}
+	if !strings.Contains(rows[1], `"released":1`) || !strings.Contains(rows[1], `"acquired_at":"2025-01-01 12:00:00.000"`) {
+		t.Fatalf("release row = %s", rows[1])
+	}
+}
+
+func TestRunLock_LosesRaceToOlderLock(t *testing.T) {
+	defer withTimeNow(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))()
+	ing, prov := lockedIngester(t)
+	// The check sees nothing, but by the verify read a concurrent run's
+	// same-millisecond row is visible and its owner sorts first.
+	fake := &lockCH{selects: []string{"", lockRow("aaa", "2025-01-01 12:00:00.000", "2025-01-01 12:02:00.000", 0) + lockRow("me", "2025-01-01 12:00:00.000", "2025-01-01 12:02:00.000", 0)}}
+	fake.install(ing)
+	if err := ing.Backfill(context.Background()); !errors.Is(err, ErrAddressLocked) {
+		t.Fatalf("expected ErrAddressLocked, got %v", err)
+	}
+	rows := fake.inserts[RunLocksTable]
+	if len(rows) != 2 || !strings.Contains(rows[1], `"released":1`) || prov.logCalls != 0 {
+		t.Fatalf("loser should release its row and not ingest: rows=%v logs=%d", rows, prov.logCalls)
+	}
+}
+
+func TestRunLock_DisabledByDefault(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", ToBlock: 5}, &countingProv{fixtureProv: fixtureProv{head: 10}})
+	fake := &lockCH{}
+	fake.install(ing)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fake.queried != 0 || len(fake.inserts[RunLocksTable]) != 0 {
+		t.Fatalf("lock table touched without RunLock: queries=%d", fake.queried)
+	}
+	if ing.lockOwner == "" || ing.lockOwner == newLockOwner() {
+		t.Fatalf("unexpected lock owner %q", ing.lockOwner)
+	}
+}
+
+func TestRunLock_HeartbeatRefreshesUntilReleased(t *testing.T) {
+	ing, _ := lockedIngester(t)
+	ing.opts.LockTTL = 30 * time.Millisecond
+	fake := &lockCH{}
+	fake.install(ing)
+	unlock, err := ing.acquireRunLock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	unlock()
+	rows := fake.inserts[RunLocksTable]
+	if len(rows) < 3 || strings.Contains(rows[1], `"released":1`) || !strings.Contains(rows[len(rows)-1], `"released":1`) {
+		t.Fatalf("expected acquire, heartbeats, release; got %v", rows)
+	}
+	n := len(rows)
+	time.Sleep(30 * time.Millisecond)
+	if len(fake.inserts[RunLocksTable]) != n {
+		t.Fatal("heartbeat kept running after release")
+	}
+}
@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+// PendingTable holds the mempool transactions seen touching an address
+// (Options.TrackPending), one row per (address, tx_hash).
+const PendingTable = "pending_transactions"
+
+// syncPending reconciles PendingTable with the node's mempool: transactions
+// touching the address that are new to the table are written with
+// pending = 1, and stored pending ones that left the mempool are rewritten
+// with pending = 0 and the block that mined them (mined_block 0 when the node
+// no longer knows them: dropped or replaced). Mempool errors are logged
+// rather than failing the run, and a provider without txpool_content turns
+// tracking off for the rest of it.
+func (i *Ingester) syncPending(ctx context.Context) error {
+	if !i.opts.TrackPending || i.noPending.Load() || i.ch == nil || !i.ch.Enabled() {
+		return nil
+	}
+	pr, ok := i.prov.(eth.PendingReader)
+	if !ok {
+		i.pendingFailed(eth.ErrUnsupported)
+		return nil
+	}
+	pool, err := pr.PendingTransactions(ctx, i.address)
+	if err != nil {
+		i.pendingFailed(err)
+		return nil
+	}
+	stored, err := i.loadPending(ctx)
+	if err != nil {
+		return err
+	}
+	version := i.rowVersion()
+	var rows []any
+	inPool := make(map[string]bool, len(pool))
+	for _, tx := range pool {
+		inPool[tx.Hash] = true
+		if _, ok := stored[tx.Hash]; ok {
+			continue
+		}
+		norm, _ := normalize.NormalizeTransaction(eth.Transaction{Hash: tx.Hash, From: tx.From, To: tx.To, ValueWei: tx.ValueWei, InputHex: tx.InputHex}, "", false)
+		rows = append(rows, map[string]any{
+			"address":      i.address,
+			"tx_hash":      tx.Hash,
+			"from_addr":    norm.From,
+			"to_addr":      norm.To,
+			"value_raw":    norm.ValueRaw,
+			"input_method": norm.InputMethod,
+			"nonce":        tx.Nonce,
+			"pending":      uint8(1),
+			"mined_block":  uint64(0),
+			"first_seen":   version,
+			"updated_at":   version,
+		})
+	}
+	added := len(rows)
+	hashes := make([]string, 0, len(stored))
+	for hash := range stored {
+		if !inPool[hash] {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	var mined, dropped int
+	for _, hash := range hashes {
+		block, isMined, err := pr.TransactionBlock(ctx, hash)
+		switch {
+		case errors.Is(err, eth.ErrTxNotFound):
+			dropped++
+		case err != nil:
+			i.pendingFailed(err)
+			continue
+		case !isMined:
+			continue // still pending, outside the executable set
+		default:
+			mined++
+		}
+		row := stored[hash]
+		row["pending"] = uint8(0)
+		row["mined_block"] = block
+		row["updated_at"] = version
+		rows = append(rows, row)
+	}
+	if len(rows) > 0 {
+		if err := i.ch.InsertJSONEachRow(ctx, PendingTable, rows); err != nil {
+			return fmt.Errorf("inserting %s: %w", PendingTable, err)
+		}
+	}
+	if logger := logging.Logger(); logger != nil {
+		logger.Info("pending_sync",
+			"component", "ingest",
+			"address", i.address,
+			"in_mempool", len(pool),
+			"added", added,
+			"mined", mined,
+			"dropped", dropped,
+		)
+	}
+	return nil
+}
+
+// loadPending returns the address's stored rows still marked pending, keyed
+// by tx_hash.
+func (i *Ingester) loadPending(ctx context.Context) (map[string]map[string]any, error) {
+	query := fmt.Sprintf("SELECT * FROM %s FINAL WHERE address = '%s' AND pending = 1 FORMAT JSONEachRow SETTINGS output_format_json_quote_64bit_integers = 0", PendingTable, quoteCHString(i.address))
+	raws, err := i.ch.QueryJSONEachRow(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", PendingTable, err)
+	}
+	out := make(map[string]map[string]any, len(raws))
+	for _, raw := range raws {
+		row, err := decodeDiffRow(raw)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", PendingTable, err)
+		}
+		hash, _ := row["tx_hash"].(string)
+		out[hash] = row
+	}
+	return out, nil
+}
+
+// pendingFailed logs a mempool read that failed. ErrUnsupported turns
+// tracking off for the rest of the run.
+func (i *Ingester) pendingFailed(err error) {
+	logger := logging.Logger()
+	if errors.Is(err, eth.ErrUnsupported) {
+		if i.noPending.CompareAndSwap(false, true) && logger != nil {
+			logger.Warn("pending_unavailable",
+				"component", "ingest",
+				"address", i.address,
+				"detail", "provider does not expose txpool_content; pending transactions will not be tracked",
+			)
+		}
+		return
+	}
+	if logger != nil {
+		logger.Warn("pending_sync_failed",
+			"component", "ingest",
+			"address", i.address,
+			"error", err.Error(),
+		)
+	}
+}
@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// pendingProv serves a mempool snapshot and where transactions that left it
+// ended up: mined at a block, or unknown (dropped) when absent from mined.
+type pendingProv struct {
+	fixtureProv
+	pool  []eth.PendingTransaction
+	mined map[string]uint64
+}
+
+func (p *pendingProv) PendingTransactions(ctx context.Context, address string) ([]eth.PendingTransaction, error) {
+	return p.pool, nil
+}
+
+func (p *pendingProv) TransactionBlock(ctx context.Context, hash string) (uint64, bool, error) {
+	if block, ok := p.mined[hash]; ok {
+		return block, true, nil
+	}
+	return 0, false, eth.ErrTxNotFound
+}
+
+// pendingCH keeps pending_transactions in memory, latest write per tx_hash
+// winning as FINAL would, and answers every other SELECT with no rows.
+func pendingCH(t *testing.T, ing *Ingester) map[string]map[string]any {
+	t.Helper()
+	table := map[string]map[string]any{}
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		var out strings.Builder
+		switch {
+		case strings.HasPrefix(q, "INSERT INTO "+PendingTable):
+			b, _ := io.ReadAll(r.Body)
+			for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+				var row map[string]any
+				if err := json.Unmarshal([]byte(line), &row); err != nil {
+					t.Fatalf("decode insert: %v", err)
+				}
+				table[row["tx_hash"].(string)] = row
+			}
+		case strings.Contains(q, "FROM "+PendingTable+" FINAL"):
+			if !strings.Contains(q, "pending = 1") {
+				t.Fatalf("unexpected query %q", q)
+			}
+			for _, row := range table {
+				if row["pending"].(float64) == 1 {
+					line, _ := json.Marshal(row)
+					out.Write(line)
+					out.WriteByte('\n')
+				}
+			}
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(out.String()))}, nil
+	}))
+	return table
+}
+
+func TestSyncPending_WritesThenReconciles(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	other := "0x" + strings.Repeat("b", 40)
+	prov := &pendingProv{pool: []eth.PendingTransaction{
+		{Hash: "0x01", From: addr, To: other, ValueWei: "0xde", InputHex: "0xa9059cbb", Nonce: 7},
+		{Hash: "0x02", From: other, To: addr, ValueWei: "0x0", InputHex: "0x", Nonce: 3},
+		{Hash: "0x03", From: addr, To: "", ValueWei: "0x0", InputHex: "0x6080", Nonce: 8},
+	}}
+	prov.head = 10
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", TrackPending: true}, prov)
+	table := pendingCH(t, ing)
+
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(table) != 3 {
+		t.Fatalf("pending rows = %v", table)
+	}
+	first := table["0x01"]
+	if first["pending"] != float64(1) || first["address"] != addr || first["value_raw"] != "222" || first["input_method"] != "transfer" || first["nonce"] != float64(7) {
+		t.Fatalf("pending row = %v", first)
+	}
+	if table["0x03"]["input_method"] != "create" || table["0x03"]["to_addr"] != "" {
+		t.Fatalf("creation row = %v", table["0x03"])
+	}
+	firstSeen := first["first_seen"]
+
+	// 0x01 is mined, 0x02 dropped; 0x03 still waits and is not rewritten.
+	prov.pool = prov.pool[2:]
+	prov.mined = map[string]uint64{"0x01": 11}
+	unchanged := table["0x03"]["updated_at"]
+	if err := ing.syncPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := table["0x01"]; got["pending"] != float64(0) || got["mined_block"] != float64(11) || got["first_seen"] != firstSeen || got["updated_at"] == firstSeen {
+		t.Fatalf("mined row = %v", got)
+	}
+	if got := table["0x02"]; got["pending"] != float64(0) || got["mined_block"] != float64(0) {
+		t.Fatalf("dropped row = %v", got)
+	}
+	if got := table["0x03"]; got["pending"] != float64(1) || got["updated_at"] != unchanged {
+		t.Fatalf("still pending row = %v", got)
+	}
+}
+
+func TestSyncPending_Gating(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	pool := []eth.PendingTransaction{{Hash: "0x01", From: addr, Nonce: 1}}
+
+	// Off by default.
+	prov := &pendingProv{pool: pool}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	if table := pendingCH(t, ing); ing.syncPending(context.Background()) != nil || len(table) != 0 {
+		t.Fatalf("tracking ran while off: %v", table)
+	}
+
+	// A provider without a mempool turns tracking off instead of failing.
+	plain := tablesFixture(addr)
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", TrackPending: true}, &plain)
+	pendingCH(t, ing)
+	if err := ing.syncPending(context.Background()); err != nil || !ing.noPending.Load() {
+		t.Fatalf("err=%v noPending=%v", err, ing.noPending.Load())
+	}
+}
@@ -0,0 +1,46 @@
+package ingest
+
+import (
+	"math/big"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+// PriceFeed prices tokens for the value_usd column of token transfers.
+// PriceAt returns the USD price of one base unit of token (the unit
+// amount_raw counts, so 10^-decimals of a whole ERC-20 token, or one
+// ERC-721/1155 item) at tsMillis, as a decimal string, and false when it has
+// no price. The ingester does not ship a feed; callers inject one through
+// Options.PriceFeed.
+type PriceFeed interface {
+	PriceAt(token string, tsMillis int64) (string, bool)
+}
+
+// usdDecimals is the precision value_usd is rounded to.
+const usdDecimals = 6
+
+// priceTransfers sets ValueUSD to amount_raw times the feed's price for each
+// transfer it can price. Transfers without a price, or whose price does not
+// parse, keep an empty ValueUSD.
+func (i *Ingester) priceTransfers(transfers []normalize.TokenTransferRow) {
+	feed := i.opts.PriceFeed
+	if feed == nil {
+		return
+	}
+	for k := range transfers {
+		t := &transfers[k]
+		price, ok := feed.PriceAt(t.Token, t.TsMillis)
+		if !ok {
+			continue
+		}
+		p, ok := new(big.Rat).SetString(price)
+		if !ok {
+			continue
+		}
+		amount, ok := new(big.Rat).SetString(t.AmountRaw)
+		if !ok {
+			continue
+		}
+		t.ValueUSD = p.Mul(p, amount).FloatString(usdDecimals)
+	}
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// stubPriceFeed prices tokens from a map and records the timestamps asked for.
+type stubPriceFeed struct {
+	prices map[string]string
+	asked  []int64
+}
+
+func (f *stubPriceFeed) PriceAt(token string, tsMillis int64) (string, bool) {
+	f.asked = append(f.asked, tsMillis)
+	p, ok := f.prices[token]
+	return p, ok
+}
+
+func priceFixture(addr string) (*fixtureProv, string, string) {
+	priced, unpriced := "0x"+strings.Repeat("c", 40), "0x"+strings.Repeat("d", 40)
+	holder, other := padTopicAddr(addr), padTopicAddr("0x"+strings.Repeat("b", 40))
+	return &fixtureProv{logs: []eth.Log{
+		// 2.5 units of a 6-decimal token
+		{TxHash: "0x1", Index: 0, Address: priced, Topics: []string{"0xddf252ad", holder, other}, DataHex: "0x2625a0", BlockNum: 7},
+		{TxHash: "0x1", Index: 1, Address: unpriced, Topics: []string{"0xddf252ad", holder, other}, DataHex: "0x01", BlockNum: 7},
+	}}, priced, unpriced
+}
+
+func TestProcessRange_PriceFeedSetsValueUSD(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov, priced, _ := priceFixture(addr)
+	feed := &stubPriceFeed{prices: map[string]string{priced: "0.000001999"}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", PriceFeed: feed}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Join(inserts["token_transfers"], "")
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("token_transfers rows: %q", body)
+	}
+	if !strings.Contains(lines[0], `"value_usd":"4.997500"`) {
+		t.Fatalf("priced row: %s", lines[0])
+	}
+	if strings.Contains(lines[1], "value_usd") {
+		t.Fatalf("unpriced row carries value_usd: %s", lines[1])
+	}
+	if len(feed.asked) != 2 || feed.asked[0] != 7000 {
+		t.Fatalf("feed asked for timestamps %v, want the block's", feed.asked)
+	}
+}
+
+func TestProcessRange_NoPriceFeedOmitsValueUSD(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	for _, schema := range []string{"canonical", "dev"} {
+		prov, _, _ := priceFixture(addr)
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: schema}, prov)
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 7, 7); err != nil {
+			t.Fatal(err)
+		}
+		body := strings.Join(append(inserts["token_transfers"], inserts["dev_token_transfers"]...), "")
+		if body == "" || strings.Contains(body, "value_usd") {
+			t.Fatalf("%s: token transfers %q", schema, body)
+		}
+	}
+}
+
+func TestProcessRange_PriceFeedDevSchema(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov, priced, unpriced := priceFixture(addr)
+	feed := &stubPriceFeed{prices: map[string]string{priced: "2", unpriced: "not a number"}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "dev", PriceFeed: feed}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 7, 7); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(strings.Join(inserts["dev_token_transfers"], "")), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"value_usd":"5000000.000000"`) || strings.Contains(lines[1], "value_usd") {
+		t.Fatalf("dev_token_transfers rows: %q", lines)
+	}
+}
@@ -0,0 +1,82 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessRange_ExportedMatchesInternal(t *testing.T) {
+	defer withTimeNow(t, time.UnixMilli(1_700_000_000_000))()
+	addr := "0x" + strings.Repeat("a", 40)
+	run := func(exported bool) (map[string][]string, []string) {
+		prov := tablesFixture(addr)
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+		inserts := map[string][]string{}
+		var queries []string
+		ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+			q := r.URL.Query().Get("query")
+			queries = append(queries, q)
+			if strings.HasPrefix(q, "INSERT INTO ") {
+				b, _ := io.ReadAll(r.Body)
+				table := strings.Fields(q)[2]
+				inserts[table] = append(inserts[table], string(b))
+			}
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}))
+		var err error
+		if exported {
+			err = ing.ProcessRange(context.Background(), 1, 1)
+		} else {
+			err = ing.processRange(context.Background(), 1, 1)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inserts, queries
+	}
+	want, _ := run(false)
+	got, queries := run(true)
+	if len(got) == 0 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("ProcessRange rows differ\nwant=%v\n got=%v", want, got)
+	}
+	for _, q := range queries {
+		if strings.Contains(q, "addresses") {
+			t.Fatalf("ProcessRange touched the checkpoint: %s", q)
+		}
+	}
+}
+
+func TestProcessRange_InvalidRangeAndNoProvider(t *testing.T) {
+	prov := tablesFixture("0xabc")
+	ing := NewWithProvider("0xabc", Options{}, &prov)
+	if err := ing.ProcessRange(context.Background(), 5, 4); err == nil {
+		t.Fatal("expected an error for from > to")
+	}
+	if err := New("0xabc", Options{}).ProcessRange(context.Background(), 1, 1); err != nil {
+		t.Fatalf("no provider: %v", err)
+	}
+}
+
+func TestProcessRange_ItemCapNarrowsDenseWindow(t *testing.T) {
+	prov := &densityProv{fixtureProv: fixtureProv{head: 1000}, perBlock: 10, every: 1}
+	ing := NewWithProvider("", Options{ClickHouseDSN: "http://localhost:8123/db", Schema: "canonical", Tables: []string{"logs"}, BatchBlocks: 100, MaxBatchItems: 100}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.ProcessRange(context.Background(), 0, 199); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.served) != 21 || prov.served[1] != 10 {
+		t.Fatalf("expected one over-cap refetch plus 20 ranges, got %v", prov.served)
+	}
+	rows := 0
+	for _, body := range inserts["logs"] {
+		rows += strings.Count(strings.TrimSpace(body), "\n") + 1
+	}
+	if rows != 2000 {
+		t.Fatalf("wrote %d log rows, want 2000", rows)
+	}
+}
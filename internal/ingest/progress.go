@@ -0,0 +1,73 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// DefaultCheckpointEvery is the checkpoint interval, in blocks, that callers
+// such as the CLI's discover mode use for long-running backfills.
+const DefaultCheckpointEvery = 10_000
+
+// progress tracks a Backfill with Options.CheckpointEvery set, between the
+// periodic checkpoints it writes.
+type progress struct {
+	ckpt    addressCheckpoint
+	from    uint64 // first block of the run
+	to      uint64 // last block the run targets
+	next    uint64 // block whose ingestion triggers the next checkpoint
+	started time.Time
+}
+
+// startProgress arms periodic checkpoints for a Backfill over [from, to].
+func (i *Ingester) startProgress(ckpt addressCheckpoint, from, to uint64) {
+	if i.opts.CheckpointEvery == 0 {
+		i.prog = nil
+		return
+	}
+	i.prog = &progress{ckpt: ckpt, from: from, to: to, next: from + i.opts.CheckpointEvery - 1, started: timeNow()}
+}
+
+// noteProgress persists the checkpoint at the contiguous coverage frontier
+// and logs backfill_progress once another CheckpointEvery blocks are in.
+// Staged commits already checkpoint every batch, so only the log is written.
+func (i *Ingester) noteProgress(ctx context.Context) error {
+	p := i.prog
+	if p == nil {
+		return nil
+	}
+	synced, ok := i.cov.frontier()
+	if !ok || synced < p.next || synced >= p.to {
+		return nil
+	}
+	p.next = synced + i.opts.CheckpointEvery
+	if i.stage == nil && synced > p.ckpt.LastSyncedBlock {
+		if err := i.persistCheckpoint(ctx, p.ckpt, checkpointBackfill, synced); err != nil {
+			return err
+		}
+		p.ckpt.LastSyncedBlock = synced
+	}
+	logger := logging.Logger()
+	if logger == nil {
+		return nil
+	}
+	done := synced - p.from + 1
+	rate := 0.0
+	if elapsed := timeNow().Sub(p.started).Seconds(); elapsed > 0 {
+		rate = float64(done) / elapsed
+	}
+	logger.Info("backfill_progress",
+		"component", "ingest",
+		"address", i.address,
+		"from_block", p.from,
+		"synced_block", synced,
+		"to_block", p.to,
+		"remaining_blocks", p.to-synced,
+		"percent", float64(done)*100/float64(p.to-p.from+1),
+		"blocks_per_sec", rate,
+		"batch", i.batch.size(),
+	)
+	return nil
+}
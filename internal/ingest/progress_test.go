@@ -0,0 +1,106 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// failFromProv fails eth_getLogs for ranges starting at or above failFrom
+// (0 = never), like a scan interrupted partway.
+type failFromProv struct {
+	captureProv
+	failFrom uint64
+}
+
+func (p *failFromProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	if p.failFrom > 0 && from >= p.failFrom {
+		return nil, errors.New("provider down")
+	}
+	return p.captureProv.GetLogs(ctx, address, from, to, topics)
+}
+
+func checkpointsWritten(t *testing.T, rt *cursorRoundTripper) []uint64 {
+	t.Helper()
+	var out []uint64
+	for _, body := range rt.inserts {
+		var row addressCheckpoint
+		if err := json.Unmarshal([]byte(strings.TrimSpace(body)), &row); err != nil {
+			t.Fatalf("decode insert: %v", err)
+		}
+		out = append(out, row.LastSyncedBlock)
+	}
+	return out
+}
+
+func TestBackfill_CheckpointEveryEmitsProgress(t *testing.T) {
+	logs := captureLogs(t)
+	// Checkpoint at 50; backfill 51..200 in 10-block batches.
+	ing, prov, rt := upToDateIngester(t, 200, Options{BatchBlocks: 10, CheckpointEvery: 40})
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.calls) != 15 {
+		t.Fatalf("calls = %+v", prov.calls)
+	}
+	if got, want := checkpointsWritten(t, rt), []uint64{90, 130, 170, 200}; !slices.Equal(got, want) {
+		t.Fatalf("checkpoints = %v, want %v", got, want)
+	}
+	var synced []uint64
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var rec struct {
+			Msg       string  `json:"msg"`
+			Synced    uint64  `json:"synced_block"`
+			Remaining uint64  `json:"remaining_blocks"`
+			Percent   float64 `json:"percent"`
+		}
+		if json.Unmarshal([]byte(line), &rec) != nil || rec.Msg != "backfill_progress" {
+			continue
+		}
+		if rec.Remaining != 200-rec.Synced || rec.Percent <= 0 || rec.Percent >= 100 {
+			t.Fatalf("progress record = %s", line)
+		}
+		synced = append(synced, rec.Synced)
+	}
+	if want := []uint64{90, 130, 170}; !slices.Equal(synced, want) {
+		t.Fatalf("progress at %v, want %v", synced, want)
+	}
+}
+
+func TestBackfill_ResumesFromPeriodicCheckpoint(t *testing.T) {
+	prov := &failFromProv{captureProv: captureProv{head: 200}, failFrom: 111}
+	ing, _, rt := upToDateIngester(t, 200, Options{BatchBlocks: 10, CheckpointEvery: 20})
+	ing.prov = prov
+	if err := ing.Backfill(context.Background()); err == nil {
+		t.Fatal("expected the interrupted scan to fail")
+	}
+	if got, want := checkpointsWritten(t, rt), []uint64{70, 90, 110}; !slices.Equal(got, want) {
+		t.Fatalf("checkpoints before the failure = %v, want %v", got, want)
+	}
+	prov.failFrom = 0
+	prov.calls = nil
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.calls) == 0 || prov.calls[0].from != 111 {
+		t.Fatalf("resumed ranges = %+v, want a start at 111", prov.calls)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 200 {
+		t.Fatalf("final checkpoint = %+v", row)
+	}
+}
+
+func TestBackfill_NoPeriodicCheckpointByDefault(t *testing.T) {
+	ing, _, rt := upToDateIngester(t, 200, Options{BatchBlocks: 10})
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := checkpointsWritten(t, rt); !slices.Equal(got, []uint64{200}) {
+		t.Fatalf("checkpoints = %v, want only the final one", got)
+	}
+}
@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// PruneOptions sets the retention window for Prune. Exactly one of the two
+// must be set.
+type PruneOptions struct {
+	// RetainBlocks keeps the last N blocks up to the address's checkpoint.
+	RetainBlocks uint64
+	// RetainDays keeps rows whose block timestamp is within N days of now.
+	RetainDays int
+}
+
+// partyScopes lists, per canonical history table, the columns naming the
+// parties to a row. contracts is a registry rather than history and is never
+// pruned or promoted.
+var partyScopes = []struct {
+	table   string
+	parties []string
+}{
+	{"logs", []string{"address"}},
+	{"token_transfers", []string{"token", "from_addr", "to_addr"}},
+	{"approvals", []string{"token", "owner", "spender"}},
+	{"proxy_upgrades", []string{"proxy"}},
+	{"swaps", []string{"pool", "sender", "recipient"}},
+	{"vault_events", []string{"vault", "sender", "owner", "receiver"}},
+	{"transactions", []string{"from_addr", "to_addr"}},
+	{"sub_calls", []string{"from_addr", "multicall", "target"}},
+	{"traces", []string{"from_addr", "to_addr"}},
+	{"withdrawals", []string{"address"}},
+}
+
+// ErrNothingToPrune is returned by PruneStatements when the retention window
+// reaches back past the address's first block.
+var ErrNothingToPrune = errors.New("ingest: nothing to prune")
+
+// PruneStatements returns the ALTER TABLE ... DELETE mutations Prune would
+// issue. Each is scoped to rows older than the cutoff that name the address
+// in a party column. Rows shared with another address tracked in the
+// addresses table are kept, since they belong to that address's history too.
+func (i *Ingester) PruneStatements(ctx context.Context, po PruneOptions) ([]string, error) {
+	if (po.RetainBlocks > 0) == (po.RetainDays > 0) {
+		return nil, fmt.Errorf("prune: set exactly one of RetainBlocks or RetainDays")
+	}
+	if i.SchemaMode() != "canonical" {
+		return nil, fmt.Errorf("prune: only the canonical schema is supported")
+	}
+	if i.ch == nil || !i.ch.Enabled() {
+		return nil, fmt.Errorf("prune: a ClickHouse DSN is required")
+	}
+	var cutoff string
+	if po.RetainBlocks > 0 {
+		ckpt, existed, err := i.loadCheckpoint(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !existed {
+			return nil, fmt.Errorf("prune: address %s has no checkpoint", i.address)
+		}
+		if ckpt.LastSyncedBlock < po.RetainBlocks {
+			return nil, ErrNothingToPrune
+		}
+		cutoff = fmt.Sprintf("block_number < %d", ckpt.LastSyncedBlock+1-po.RetainBlocks)
+	} else {
+		at := timeNow().UTC().Add(-time.Duration(po.RetainDays) * 24 * time.Hour)
+		cutoff = fmt.Sprintf("ts < toDateTime64('%s', 3, 'UTC')", fmtDT64(at.UnixMilli()))
+	}
+	others, err := i.otherTrackedAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addr := quoteCHString(i.address)
+	var stmts []string
+	for _, s := range partyScopes {
+		if !i.wants(s.table) {
+			continue
+		}
+		parties := "[" + strings.Join(s.parties, ", ") + "]"
+		stmt := fmt.Sprintf("ALTER TABLE %s DELETE WHERE %s AND has(%s, '%s')", s.table, cutoff, parties, addr)
+		if len(others) > 0 {
+			stmt += fmt.Sprintf(" AND NOT hasAny(%s, [%s])", parties, others)
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// Prune deletes the address's rows older than the retention window from the
+// canonical tables. ClickHouse applies the deletes as asynchronous mutations.
+func (i *Ingester) Prune(ctx context.Context, po PruneOptions) error {
+	stmts, err := i.PruneStatements(ctx, po)
+	if errors.Is(err, ErrNothingToPrune) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if err := i.ch.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("prune: %w", err)
+		}
+		if logger := logging.Logger(); logger != nil {
+			logger.Info("prune_issued",
+				"component", "ingest",
+				"address", i.address,
+				"table", strings.Fields(stmt)[2],
+			)
+		}
+	}
+	return nil
+}
+
+// otherTrackedAddresses returns the quoted, comma-separated addresses other
+// than this one that have a checkpoint.
+func (i *Ingester) otherTrackedAddresses(ctx context.Context) (string, error) {
+	query := fmt.Sprintf("SELECT DISTINCT address FROM addresses WHERE address != '%s' ORDER BY address FORMAT JSONEachRow", quoteCHString(i.address))
+	rows, err := i.ch.QueryJSONEachRow(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("querying tracked addresses: %w", err)
+	}
+	quoted := make([]string, 0, len(rows))
+	for _, raw := range rows {
+		var r struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return "", fmt.Errorf("decoding tracked address: %w", err)
+		}
+		quoted = append(quoted, "'"+quoteCHString(strings.ToLower(r.Address))+"'")
+	}
+	return strings.Join(quoted, ", "), nil
+}
@@ -0,0 +1,134 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pruneCH answers the checkpoint and tracked-address queries and records the
+// statements Prune executes.
+type pruneCH struct {
+	checkpoint string
+	tracked    string
+	execs      []string
+}
+
+func (p *pruneCH) RoundTrip(r *http.Request) (*http.Response, error) {
+	q := r.URL.Query().Get("query")
+	body := ""
+	switch {
+	case r.Method == http.MethodPost:
+		p.execs = append(p.execs, q)
+	case strings.Contains(q, "SELECT DISTINCT address FROM addresses"):
+		body = p.tracked
+	case strings.Contains(q, "FROM addresses WHERE"):
+		body = p.checkpoint
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+func pruneIngester(t *testing.T, opts Options, fake *pruneCH) *Ingester {
+	t.Helper()
+	if opts.ClickHouseDSN == "" {
+		opts.ClickHouseDSN = "http://localhost:8123/db"
+	}
+	ing := NewWithProvider("0x"+strings.Repeat("a", 40), opts, nil)
+	ing.ch.SetTransport(fake)
+	return ing
+}
+
+func TestPruneStatements_RetainBlocksScopedToAddress(t *testing.T) {
+	addr := strings.Repeat("a", 40)
+	other := strings.Repeat("b", 40)
+	fake := &pruneCH{
+		checkpoint: `{"address":"0x` + addr + `","last_synced_block":1000}`,
+		tracked:    `{"address":"0x` + strings.ToUpper(other) + `"}` + "\n",
+	}
+	stmts, err := pruneIngester(t, Options{}, fake).PruneStatements(context.Background(), PruneOptions{RetainBlocks: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ALTER TABLE logs DELETE WHERE block_number < 901 AND has([address], '0x" + addr + "') AND NOT hasAny([address], ['0x" + other + "'])",
+		"ALTER TABLE token_transfers DELETE WHERE block_number < 901 AND has([token, from_addr, to_addr], '0x" + addr + "') AND NOT hasAny([token, from_addr, to_addr], ['0x" + other + "'])",
+		"ALTER TABLE approvals DELETE WHERE block_number < 901 AND has([token, owner, spender], '0x" + addr + "') AND NOT hasAny([token, owner, spender], ['0x" + other + "'])",
+		"ALTER TABLE proxy_upgrades DELETE WHERE block_number < 901 AND has([proxy], '0x" + addr + "') AND NOT hasAny([proxy], ['0x" + other + "'])",
+		"ALTER TABLE swaps DELETE WHERE block_number < 901 AND has([pool, sender, recipient], '0x" + addr + "') AND NOT hasAny([pool, sender, recipient], ['0x" + other + "'])",
+		"ALTER TABLE vault_events DELETE WHERE block_number < 901 AND has([vault, sender, owner, receiver], '0x" + addr + "') AND NOT hasAny([vault, sender, owner, receiver], ['0x" + other + "'])",
+		"ALTER TABLE transactions DELETE WHERE block_number < 901 AND has([from_addr, to_addr], '0x" + addr + "') AND NOT hasAny([from_addr, to_addr], ['0x" + other + "'])",
+		"ALTER TABLE sub_calls DELETE WHERE block_number < 901 AND has([from_addr, multicall, target], '0x" + addr + "') AND NOT hasAny([from_addr, multicall, target], ['0x" + other + "'])",
+		"ALTER TABLE traces DELETE WHERE block_number < 901 AND has([from_addr, to_addr], '0x" + addr + "') AND NOT hasAny([from_addr, to_addr], ['0x" + other + "'])",
+		"ALTER TABLE withdrawals DELETE WHERE block_number < 901 AND has([address], '0x" + addr + "') AND NOT hasAny([address], ['0x" + other + "'])",
+	}
+	if len(stmts) != len(want) {
+		t.Fatalf("statements = %q", stmts)
+	}
+	for k := range want {
+		if stmts[k] != want[k] {
+			t.Fatalf("statement %d:\n got %s\nwant %s", k, stmts[k], want[k])
+		}
+	}
+}
+
+func TestPruneStatements_RetainDaysAndTables(t *testing.T) {
+	defer withTimeNow(t, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC))()
+	fake := &pruneCH{}
+	stmts, err := pruneIngester(t, Options{Tables: []string{"transactions"}}, fake).PruneStatements(context.Background(), PruneOptions{RetainDays: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ALTER TABLE transactions DELETE WHERE ts < toDateTime64('2024-02-09 12:00:00.000', 3, 'UTC') AND has([from_addr, to_addr], '0x" + strings.Repeat("a", 40) + "')"
+	if len(stmts) != 1 || stmts[0] != want {
+		t.Fatalf("statements = %q", stmts)
+	}
+}
+
+func TestPruneStatements_Errors(t *testing.T) {
+	ctx := context.Background()
+	fake := &pruneCH{}
+	for name, po := range map[string]PruneOptions{"neither": {}, "both": {RetainBlocks: 1, RetainDays: 1}} {
+		if _, err := pruneIngester(t, Options{}, fake).PruneStatements(ctx, po); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+	if _, err := pruneIngester(t, Options{Schema: "dev"}, fake).PruneStatements(ctx, PruneOptions{RetainDays: 1}); err == nil {
+		t.Fatal("expected dev schema error")
+	}
+	if _, err := NewWithProvider("0xabc", Options{}, nil).PruneStatements(ctx, PruneOptions{RetainDays: 1}); err == nil {
+		t.Fatal("expected missing DSN error")
+	}
+	if _, err := pruneIngester(t, Options{}, fake).PruneStatements(ctx, PruneOptions{RetainBlocks: 10}); err == nil || !strings.Contains(err.Error(), "no checkpoint") {
+		t.Fatalf("expected missing checkpoint error, got %v", err)
+	}
+	fake.checkpoint = `{"last_synced_block":5}`
+	if _, err := pruneIngester(t, Options{}, fake).PruneStatements(ctx, PruneOptions{RetainBlocks: 10}); !errors.Is(err, ErrNothingToPrune) {
+		t.Fatalf("expected ErrNothingToPrune, got %v", err)
+	}
+	if err := pruneIngester(t, Options{}, fake).Prune(ctx, PruneOptions{RetainBlocks: 10}); err != nil || len(fake.execs) != 0 {
+		t.Fatalf("err=%v execs=%q", err, fake.execs)
+	}
+}
+
+func TestPrune_ExecutesStatements(t *testing.T) {
+	fake := &pruneCH{checkpoint: `{"last_synced_block":50}`}
+	ing := pruneIngester(t, Options{}, fake)
+	want, err := ing.PruneStatements(context.Background(), PruneOptions{RetainBlocks: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := captureLogs(t)
+	if err := ing.Prune(context.Background(), PruneOptions{RetainBlocks: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fake.execs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("execs = %q", fake.execs)
+	}
+	if n := strings.Count(logs.String(), `"msg":"prune_issued"`); n != len(want) {
+		t.Fatalf("prune_issued logs = %d: %s", n, logs.String())
+	}
+}
@@ -0,0 +1,121 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRangeHasher_StableAndSensitive(t *testing.T) {
+	rows := func(amount string, stamp int64) []map[string]any {
+		return []map[string]any{
+			{"tx_hash": "0x1", "log_index": uint32(0), "amount_raw": amount, "ingested_at": stamp, "finality": "safe"},
+			{"tx_hash": "0x2", "log_index": uint32(1), "amount_raw": "7", "ingested_at": stamp},
+		}
+	}
+	hashOf := func(transfers []map[string]any) string {
+		var h rangeHasher
+		h.add("token_transfers", transfers)
+		h.add("logs", []map[string]any{{"tx_hash": "0x1", "topics": []string{"0xddf252ad"}}})
+		return h.sum()
+	}
+	base := hashOf(rows("1", 1))
+	if again := hashOf(rows("1", 1)); again != base {
+		t.Fatalf("identical inputs hashed %s and %s", base, again)
+	}
+	reordered := rows("1", 1)
+	reordered[0], reordered[1] = reordered[1], reordered[0]
+	if got := hashOf(reordered); got != base {
+		t.Fatal("row order changed the hash")
+	}
+	if got := hashOf(rows("1", 99)); got != base {
+		t.Fatal("insert-time columns changed the hash")
+	}
+	if got := hashOf(rows("2", 1)); got == base {
+		t.Fatal("a changed amount kept the hash")
+	}
+	var empty rangeHasher
+	if empty.sum() == base {
+		t.Fatal("an empty range hashed like a populated one")
+	}
+}
+
+func rangeHashRow(t *testing.T, inserts map[string][]string) map[string]any {
+	t.Helper()
+	bodies := inserts[RangeHashTable]
+	if len(bodies) != 1 {
+		t.Fatalf("range_hashes inserts = %v", bodies)
+	}
+	var row map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(bodies[0])), &row); err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestProcessRange_HashRanges(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	hashFor := func(prov fixtureProv) map[string]any {
+		ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", HashRanges: true}, &prov)
+		inserts := captureInserts(t, ing)
+		if err := ing.processRange(context.Background(), 1, 1); err != nil {
+			t.Fatal(err)
+		}
+		return rangeHashRow(t, inserts)
+	}
+	first := hashFor(tablesFixture(addr))
+	if first["address"] != addr || first["from_block"] != float64(1) || first["to_block"] != float64(1) || first["row_count"].(float64) == 0 {
+		t.Fatalf("range hash row = %v", first)
+	}
+	if second := hashFor(tablesFixture(addr)); second["hash"] != first["hash"] {
+		t.Fatalf("identical ranges hashed %v and %v", first["hash"], second["hash"])
+	}
+	changed := tablesFixture(addr)
+	changed.logs[0].DataHex = "0x02"
+	if got := hashFor(changed); got["hash"] == first["hash"] {
+		t.Fatal("a changed transfer amount kept the hash")
+	}
+}
+
+func TestProcessRange_HashRangesOffByDefault(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts[RangeHashTable]) != 0 {
+		t.Fatalf("unexpected range hashes: %v", inserts[RangeHashTable])
+	}
+}
+
+func TestProcessRange_HashMismatchLogged(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", HashRanges: true}, &prov)
+	var query string
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		body := ""
+		if strings.Contains(q, "FROM "+RangeHashTable) {
+			query = q
+			body = `{"hash":"stale"}` + "\n"
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+	logs := captureLogs(t)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, "from_block = 1 AND to_block = 1") {
+		t.Fatalf("lookup query = %q", query)
+	}
+	out := logs.String()
+	if !strings.Contains(out, `"msg":"range_hash_mismatch"`) || !strings.Contains(out, `"previous_hash":"stale"`) {
+		t.Fatalf("expected range_hash_mismatch, got %s", out)
+	}
+}
@@ -0,0 +1,248 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// ReconciliationTable receives one row per reconciled range (Options.Reconcile).
+const ReconciliationTable = "balance_reconciliations"
+
+// valueFlow is the wei an address gained and spent over a range: external
+// and internal value transfers, EIP-4895 withdrawals, plus gas fees paid for
+// its own transactions.
+type valueFlow struct {
+	in   *big.Int
+	out  *big.Int
+	fees *big.Int
+}
+
+// net returns in - out - fees.
+func (f valueFlow) net() *big.Int {
+	n := new(big.Int).Sub(f.in, f.out)
+	return n.Sub(n, f.fees)
+}
+
+// netValueFlow sums the ETH moved into and out of address by txs, traces and
+// withdrawals, which are credited in gwei. Failed transactions still pay gas but move no value, and their traces are
+// skipped. Root traces duplicate the external transaction and are skipped, as
+// are delegatecall/staticcall traces, which carry the caller's value without
+// transferring it. Values that do not parse are returned as an error rather
+// than silently counted as zero.
+func netValueFlow(address string, txs []eth.Transaction, traces []eth.Trace, withdrawals []eth.Withdrawal) (valueFlow, error) {
+	f := valueFlow{in: new(big.Int), out: new(big.Int), fees: new(big.Int)}
+	failed := make(map[string]bool)
+	seenTx := make(map[string]bool, len(txs))
+	for _, tx := range txs {
+		hash := strings.ToLower(tx.Hash)
+		if seenTx[hash] {
+			continue
+		}
+		seenTx[hash] = true
+		from := strings.EqualFold(tx.From, address)
+		if from {
+			price, err := parseWei(tx.GasPriceWei)
+			if err != nil {
+				return valueFlow{}, fmt.Errorf("tx %s gas price: %w", tx.Hash, err)
+			}
+			f.fees.Add(f.fees, new(big.Int).Mul(price, new(big.Int).SetUint64(tx.GasUsed)))
+		}
+		if tx.Status == 0 {
+			failed[hash] = true
+			continue
+		}
+		if err := f.add(address, tx.From, tx.To, tx.ValueWei); err != nil {
+			return valueFlow{}, fmt.Errorf("tx %s value: %w", tx.Hash, err)
+		}
+	}
+	seenTrace := make(map[string]bool, len(traces))
+	for _, tr := range traces {
+		if strings.EqualFold(tr.TraceID, "root") || failed[strings.ToLower(tr.TxHash)] {
+			continue
+		}
+		switch tr.CallType {
+		case "delegatecall", "staticcall":
+			continue
+		}
+		key := strings.ToLower(tr.TxHash) + "/" + tr.TraceID
+		if seenTrace[key] {
+			continue
+		}
+		seenTrace[key] = true
+		if err := f.add(address, tr.From, tr.To, tr.ValueWei); err != nil {
+			return valueFlow{}, fmt.Errorf("trace %s/%s value: %w", tr.TxHash, tr.TraceID, err)
+		}
+	}
+	seenWithdrawal := make(map[uint64]bool, len(withdrawals))
+	for _, w := range withdrawals {
+		if seenWithdrawal[w.Index] || !strings.EqualFold(w.Address, address) {
+			continue
+		}
+		seenWithdrawal[w.Index] = true
+		gwei, err := parseWei(w.AmountGwei)
+		if err != nil {
+			return valueFlow{}, fmt.Errorf("withdrawal %d amount: %w", w.Index, err)
+		}
+		f.in.Add(f.in, gwei.Mul(gwei, big.NewInt(1_000_000_000)))
+	}
+	return f, nil
+}
+
+// add credits or debits value depending on which side address is on; self
+// transfers net to zero.
+func (f valueFlow) add(address, from, to, value string) error {
+	isFrom, isTo := strings.EqualFold(from, address), strings.EqualFold(to, address)
+	if isFrom == isTo {
+		return nil
+	}
+	v, err := parseWei(value)
+	if err != nil {
+		return err
+	}
+	if isTo {
+		f.in.Add(f.in, v)
+	} else {
+		f.out.Add(f.out, v)
+	}
+	return nil
+}
+
+// parseWei parses a hex (0x-prefixed) or decimal wei amount; empty is zero.
+func parseWei(s string) (*big.Int, error) {
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return new(big.Int), nil
+	}
+	v, ok := new(big.Int), false
+	if strings.HasPrefix(t, "0x") || strings.HasPrefix(t, "0X") {
+		if t = t[2:]; t == "" {
+			return v, nil
+		}
+		v, ok = v.SetString(t, 16)
+	} else {
+		v, ok = v.SetString(t, 10)
+	}
+	if !ok || v.Sign() < 0 {
+		return nil, fmt.Errorf("invalid wei amount %q", s)
+	}
+	return v, nil
+}
+
+// reconcileRange compares the address's balance delta over [from, to] with
+// the net value flow of the fetched transactions, traces and withdrawals.
+// Each result is written to ReconciliationTable; mismatches also log
+// balance_discrepancy. Reconciliation is a check, so provider errors are
+// logged and skipped rather than failing the range.
+func (i *Ingester) reconcileRange(ctx context.Context, from, to uint64, txs []eth.Transaction, traces []eth.Trace, withdrawals []eth.Withdrawal, rs rangeState) error {
+	if i.noBalances.Load() {
+		return nil
+	}
+	br, ok := i.prov.(eth.BalanceReader)
+	if !ok {
+		i.markBalancesUnavailable()
+		return nil
+	}
+	before := new(big.Int)
+	if from > 0 {
+		b, err := br.BalanceAt(ctx, i.address, from-1)
+		if err != nil {
+			i.reconcileFailed(from, to, err)
+			return nil
+		}
+		before = b
+	}
+	after, err := br.BalanceAt(ctx, i.address, to)
+	if err != nil {
+		i.reconcileFailed(from, to, err)
+		return nil
+	}
+	flow, err := netValueFlow(i.address, txs, traces, withdrawals)
+	if err != nil {
+		i.reconcileFailed(from, to, err)
+		return nil
+	}
+	delta := new(big.Int).Sub(after, before)
+	gap := new(big.Int).Sub(delta, flow.net())
+	matched := uint8(1)
+	if gap.Sign() != 0 {
+		matched = 0
+		i.discrepancies.Add(1)
+		if logger := logging.Logger(); logger != nil {
+			logger.Warn("balance_discrepancy",
+				"component", "ingest",
+				"address", i.address,
+				"from_block", from,
+				"to_block", to,
+				"balance_delta_wei", delta.String(),
+				"net_flow_wei", flow.net().String(),
+				"discrepancy_wei", gap.String(),
+				"traces_included", !i.noTraces.Load(),
+			)
+		}
+	}
+	tracesIncluded := uint8(1)
+	if i.noTraces.Load() {
+		tracesIncluded = 0
+	}
+	unconfirmed := uint8(0)
+	if rs.unconfirmed {
+		unconfirmed = 1
+	}
+	row := map[string]any{
+		"address":         i.address,
+		"from_block":      from,
+		"to_block":        to,
+		"balance_before":  before.String(),
+		"balance_after":   after.String(),
+		"inflow_wei":      flow.in.String(),
+		"outflow_wei":     flow.out.String(),
+		"fees_wei":        flow.fees.String(),
+		"discrepancy_wei": gap.String(),
+		"matched":         matched,
+		"traces_included": tracesIncluded,
+		"unconfirmed":     unconfirmed,
+		"ingested_at":     i.rowVersion(),
+	}
+	if err := i.sink.InsertJSONEachRow(ctx, ReconciliationTable, []any{row}); err != nil {
+		return fmt.Errorf("inserting %s: %w", ReconciliationTable, err)
+	}
+	return nil
+}
+
+// reconcileFailed logs a reconciliation that could not run. ErrUnsupported
+// disables reconciliation for the rest of the run.
+func (i *Ingester) reconcileFailed(from, to uint64, err error) {
+	if errors.Is(err, eth.ErrUnsupported) {
+		i.markBalancesUnavailable()
+		return
+	}
+	if logger := logging.Logger(); logger != nil {
+		logger.Warn("reconcile_failed",
+			"component", "ingest",
+			"address", i.address,
+			"from_block", from,
+			"to_block", to,
+			"error", err.Error(),
+		)
+	}
+}
+
+// markBalancesUnavailable disables reconciliation and warns the first time.
+func (i *Ingester) markBalancesUnavailable() {
+	if !i.noBalances.CompareAndSwap(false, true) {
+		return
+	}
+	if logger := logging.Logger(); logger != nil {
+		logger.Warn("reconcile_unavailable",
+			"component", "ingest",
+			"address", i.address,
+			"detail", "provider does not support eth_getBalance; balance reconciliation is disabled",
+		)
+	}
+}
@@ -0,0 +1,175 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+type balanceProv struct {
+	fixtureProv
+	balances map[uint64]int64
+	err      error
+	calls    int
+}
+
+func (p *balanceProv) BalanceAt(ctx context.Context, address string, block uint64) (*big.Int, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return big.NewInt(p.balances[block]), nil
+}
+
+// reconcileFixture: the address receives 1000 wei, sends 300 wei paying
+// 21 gas at 2 wei, and gets 50 wei back through an internal call. A failed
+// outgoing tx still pays 10 gas at 2 wei, and a delegatecall trace carrying
+// value must not count. Net flow: 1000 - 300 - 42 + 50 - 20 = 688.
+func reconcileFixture(addr string) fixtureProv {
+	other := "0x" + strings.Repeat("b", 40)
+	return fixtureProv{
+		txs: []eth.Transaction{
+			{Hash: "0x1", From: other, To: addr, ValueWei: "0x3e8", Status: 1, BlockNum: 11},
+			{Hash: "0x2", From: addr, To: other, ValueWei: "300", GasUsed: 21, GasPriceWei: "0x2", Status: 1, BlockNum: 12},
+			{Hash: "0x3", From: addr, To: other, ValueWei: "0x64", GasUsed: 10, GasPriceWei: "0x2", Status: 0, BlockNum: 13},
+		},
+		traces: []eth.Trace{
+			{TxHash: "0x2", TraceID: "root", From: addr, To: other, ValueWei: "0x12c", BlockNum: 12},
+			{TxHash: "0x2", TraceID: "0", From: other, To: addr, ValueWei: "0x32", BlockNum: 12, Type: "call", CallType: "call"},
+			{TxHash: "0x2", TraceID: "0-0", From: other, To: addr, ValueWei: "0x32", BlockNum: 12, Type: "call", CallType: "delegatecall"},
+			{TxHash: "0x3", TraceID: "0", From: other, To: addr, ValueWei: "0x5", BlockNum: 13, Type: "call", CallType: "call"},
+		},
+	}
+}
+
+func TestNetValueFlow(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	fx := reconcileFixture(addr)
+	f, err := netValueFlow(addr, fx.txs, fx.traces, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.in.Int64() != 1050 || f.out.Int64() != 300 || f.fees.Int64() != 62 || f.net().Int64() != 688 {
+		t.Fatalf("in=%v out=%v fees=%v net=%v", f.in, f.out, f.fees, f.net())
+	}
+	if _, err := netValueFlow(addr, []eth.Transaction{{Hash: "0x9", To: addr, ValueWei: "0xzz", Status: 1}}, nil, nil); err == nil {
+		t.Fatal("expected error for unparsable value")
+	}
+}
+
+func TestReconcile_MatchesBalanceDelta(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &balanceProv{fixtureProv: reconcileFixture(addr), balances: map[uint64]int64{9: 5000, 20: 5688}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Reconcile: true}, prov)
+	inserts := captureInserts(t, ing)
+	logs := captureLogs(t)
+	if err := ing.processRange(context.Background(), 10, 20); err != nil {
+		t.Fatal(err)
+	}
+	rows := inserts[ReconciliationTable]
+	if len(rows) != 1 {
+		t.Fatalf("expected one reconciliation row, got %v", rows)
+	}
+	for _, want := range []string{`"matched":1`, `"discrepancy_wei":"0"`, `"balance_before":"5000"`, `"balance_after":"5688"`, `"fees_wei":"62"`, `"from_block":10`, `"to_block":20`} {
+		if !strings.Contains(rows[0], want) {
+			t.Fatalf("row missing %s: %s", want, rows[0])
+		}
+	}
+	if strings.Contains(logs.String(), "balance_discrepancy") || ing.Summary().BalanceDiscrepancies != 0 {
+		t.Fatalf("unexpected discrepancy: %s", logs.String())
+	}
+}
+
+func TestReconcile_CreditsWithdrawals(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	// A 2 gwei validator payout lands on top of the fixture's 688 wei flow;
+	// the duplicate and the other recipient's withdrawal must not count.
+	wp := &withdrawalProv{fixtureProv: reconcileFixture(addr), withdrawals: []eth.Withdrawal{
+		{Index: 1, Address: addr, AmountGwei: "0x2", BlockNum: 15},
+		{Index: 1, Address: addr, AmountGwei: "0x2", BlockNum: 15},
+		{Index: 2, Address: "0x" + strings.Repeat("b", 40), AmountGwei: "0x5", BlockNum: 15},
+	}}
+	bp := &balanceProv{balances: map[uint64]int64{9: 5000, 20: 2_000_005_688}}
+	prov := struct {
+		*withdrawalProv
+		eth.BalanceReader
+	}{wp, bp}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Reconcile: true, Tables: []string{"token_transfers"}}, prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 10, 20); err != nil {
+		t.Fatal(err)
+	}
+	rows := inserts[ReconciliationTable]
+	if len(rows) != 1 || !strings.Contains(rows[0], `"matched":1`) || !strings.Contains(rows[0], `"inflow_wei":"2000001050"`) {
+		t.Fatalf("unexpected reconciliation rows: %v", rows)
+	}
+	if wp.calls != 1 || len(inserts["withdrawals"]) != 0 {
+		t.Fatalf("withdrawals fetched %d times, written %v", wp.calls, inserts["withdrawals"])
+	}
+}
+
+func TestReconcile_DetectsGap(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	// 7 wei arrived from somewhere the fetched flows do not explain.
+	prov := &balanceProv{fixtureProv: reconcileFixture(addr), balances: map[uint64]int64{9: 5000, 20: 5695}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Reconcile: true, Tables: []string{"token_transfers"}}, prov)
+	inserts := captureInserts(t, ing)
+	logs := captureLogs(t)
+	if err := ing.processRange(context.Background(), 10, 20); err != nil {
+		t.Fatal(err)
+	}
+	rows := inserts[ReconciliationTable]
+	if len(rows) != 1 || !strings.Contains(rows[0], `"matched":0`) || !strings.Contains(rows[0], `"discrepancy_wei":"7"`) {
+		t.Fatalf("unexpected reconciliation rows: %v", rows)
+	}
+	if len(inserts["transactions"]) != 0 || len(inserts["traces"]) != 0 {
+		t.Fatalf("excluded tables written: %v", inserts)
+	}
+	if !strings.Contains(logs.String(), `"msg":"balance_discrepancy"`) || !strings.Contains(logs.String(), `"discrepancy_wei":"7"`) {
+		t.Fatalf("expected discrepancy log: %s", logs.String())
+	}
+	if ing.Summary().BalanceDiscrepancies != 1 {
+		t.Fatalf("summary = %+v", ing.Summary())
+	}
+}
+
+func TestReconcile_UnsupportedAndErrors(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	logs := captureLogs(t)
+
+	// Provider without eth_getBalance: warn once, skip, never fail the range.
+	fx := reconcileFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Reconcile: true}, &fx)
+	inserts := captureInserts(t, ing)
+	for k := 0; k < 2; k++ {
+		if err := ing.processRange(context.Background(), 10, 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(logs.String(), `"msg":"reconcile_unavailable"`); n != 1 || len(inserts[ReconciliationTable]) != 0 {
+		t.Fatalf("warnings=%d rows=%v", n, inserts[ReconciliationTable])
+	}
+
+	// Transient balance errors are logged per range and do not fail ingestion.
+	prov := &balanceProv{fixtureProv: reconcileFixture(addr), err: errors.New("boom")}
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Reconcile: true}, prov)
+	inserts = captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 10, 20); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), `"msg":"reconcile_failed"`) || len(inserts[ReconciliationTable]) != 0 {
+		t.Fatalf("expected reconcile_failed and no rows: %s", logs.String())
+	}
+
+	// Reconciliation is off by default.
+	prov = &balanceProv{fixtureProv: reconcileFixture(addr)}
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, prov)
+	captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 10, 20); err != nil || prov.calls != 0 {
+		t.Fatalf("err=%v balance calls=%d", err, prov.calls)
+	}
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// logsDescribe answers DESCRIBE TABLE logs with the canonical logs columns.
+const logsDescribe = `{"name":"event_uid","type":"String","default_type":""}
+{"name":"tx_hash","type":"String","default_type":""}
+{"name":"log_index","type":"UInt32","default_type":""}
+{"name":"address","type":"String","default_type":""}
+{"name":"topics","type":"Array(String)","default_type":""}
+{"name":"hashed_topics","type":"Map(UInt8, String)","default_type":""}
+{"name":"data_hex","type":"String","default_type":""}
+{"name":"block_number","type":"UInt64","default_type":""}
+{"name":"ts","type":"DateTime64(3, 'UTC')","default_type":""}
+{"name":"unconfirmed","type":"UInt8","default_type":"DEFAULT"}
+{"name":"finality","type":"Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3)","default_type":"DEFAULT"}
+{"name":"source_provider","type":"LowCardinality(String)","default_type":"DEFAULT"}
+{"name":"confirmations","type":"UInt64","default_type":"DEFAULT"}
+{"name":"ingested_at","type":"DateTime64(3, 'UTC')","default_type":"DEFAULT"}
+`
+
+func TestProcessRange_RowBinaryTables(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"logs", "token_transfers"}, RowBinaryTables: []string{"logs"}}, &prov)
+	queries := map[string]string{}
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		body := ""
+		switch {
+		case strings.HasPrefix(q, "DESCRIBE TABLE logs "):
+			body = logsDescribe
+		case strings.HasPrefix(q, "INSERT INTO "):
+			queries[strings.Fields(q)[2]] = q + " " + r.Header.Get("Content-Type")
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+	}))
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if q := queries["logs"]; !strings.HasPrefix(q, "INSERT INTO logs (event_uid, tx_hash,") || !strings.HasSuffix(q, "FORMAT RowBinary application/octet-stream") {
+		t.Fatalf("logs insert = %q", q)
+	}
+	if q := queries["token_transfers"]; q != "INSERT INTO token_transfers FORMAT JSONEachRow application/json" {
+		t.Fatalf("token_transfers insert = %q", q)
+	}
+}
+
+func TestNewValidated_RejectsUnknownRowBinaryTable(t *testing.T) {
+	if _, err := NewValidated("0x"+strings.Repeat("a", 40), Options{RowBinaryTables: []string{"blocks"}}); err == nil {
+		t.Fatal("expected error for a non-canonical table")
+	}
+}
@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ParseSortKeys parses a comma-separated list of table=column+column sort
+// keys for Options.SortKeys. An empty key (table=) keeps that table's rows in
+// fetch order.
+func ParseSortKeys(spec string) (map[string][]string, error) {
+	keys := map[string][]string{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		table, cols, ok := strings.Cut(part, "=")
+		table = strings.TrimSpace(table)
+		if !ok {
+			return nil, fmt.Errorf("invalid sort key %q (want table=column+column)", part)
+		}
+		if !slices.Contains(CanonicalTables, table) {
+			return nil, fmt.Errorf("sort key %q: %q is not a canonical table", part, table)
+		}
+		if _, dup := keys[table]; dup {
+			return nil, fmt.Errorf("sort key of %s given twice", table)
+		}
+		key := []string{}
+		if cols = strings.TrimSpace(cols); cols != "" {
+			for _, col := range strings.Split(cols, "+") {
+				col = strings.TrimSpace(col)
+				if !columnIdent.MatchString(col) {
+					return nil, fmt.Errorf("invalid sort key %q (want table=column+column)", part)
+				}
+				key = append(key, col)
+			}
+		}
+		keys[table] = key
+	}
+	return keys, nil
+}
+
+// sortKey returns the columns table's rows are ordered by before insert:
+// Options.SortKeys, else the table's ClickHouse sorting key (diffKeys).
+func (i *Ingester) sortKey(table string) []string {
+	if key, ok := i.opts.SortKeys[table]; ok {
+		return key
+	}
+	return diffKeys[table]
+}
+
+// sortRows orders rows by table's sort key, so each insert arrives in the
+// order ClickHouse stores the part and merges touch fewer granules. The sort
+// is stable; rows tied on the key keep their fetch order.
+func (i *Ingester) sortRows(table string, rows []map[string]any) {
+	key := i.sortKey(table)
+	if len(key) == 0 || len(rows) < 2 {
+		return
+	}
+	slices.SortStableFunc(rows, func(a, b map[string]any) int {
+		for _, col := range key {
+			if c := compareSortValues(a[col], b[col]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// compareSortValues orders two column values: integers numerically, anything
+// else by its string form, with a missing or NULL value as "".
+func compareSortValues(a, b any) int {
+	if x, ok := sortInt(a); ok {
+		if y, ok := sortInt(b); ok {
+			return cmp.Compare(x, y)
+		}
+	}
+	return cmp.Compare(sortString(a), sortString(b))
+}
+
+func sortInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func sortString(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case *string:
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return fmt.Sprint(v)
+}
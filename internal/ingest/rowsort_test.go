@@ -0,0 +1,107 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestParseSortKeys(t *testing.T) {
+	got, err := ParseSortKeys(" logs = block_number + log_index, traces=,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"logs": {"block_number", "log_index"}, "traces": {}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	for _, spec := range []string{"logs", "dev_logs=tx_hash", "logs=bad col", "logs=a++b", "logs=a,logs=b"} {
+		if _, err := ParseSortKeys(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+// payloadKeys returns cols of every JSONEachRow row in payloads, joined by
+// "/" per row.
+func payloadKeys(t *testing.T, payloads []string, cols ...string) []string {
+	t.Helper()
+	var out []string
+	for _, line := range strings.Split(strings.TrimSpace(strings.Join(payloads, "")), "\n") {
+		var row map[string]any
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("payload line %q: %v", line, err)
+		}
+		parts := make([]string, len(cols))
+		for n, c := range cols {
+			parts[n] = fmt.Sprint(row[c])
+		}
+		out = append(out, strings.Join(parts, "/"))
+	}
+	return out
+}
+
+func sortFixture(addr string) fixtureProv {
+	other := "0x" + strings.Repeat("b", 40)
+	log := func(tx string, idx uint32, block uint64) eth.Log {
+		return eth.Log{TxHash: tx, Index: idx, Address: "0x" + strings.Repeat("c", 40), Topics: []string{"0xddf252ad", padTopicAddr(addr), padTopicAddr(other)}, DataHex: "0x01", BlockNum: block}
+	}
+	trace := func(tx, id string, block uint64) eth.Trace {
+		return eth.Trace{TxHash: tx, TraceID: id, From: addr, To: other, ValueWei: "1", BlockNum: block}
+	}
+	return fixtureProv{
+		logs:   []eth.Log{log("0xb", 3, 2), log("0xa", 10, 3), log("0xa", 9, 3), log("0xc", 0, 1)},
+		traces: []eth.Trace{trace("0xb", "1", 2), trace("0xa", "0", 3), trace("0xb", "0", 2)},
+		txs: []eth.Transaction{
+			{Hash: "0xb", From: addr, To: other, ValueWei: "1", BlockNum: 2, Status: 1},
+			{Hash: "0xa", From: addr, To: other, ValueWei: "1", BlockNum: 3, Status: 1},
+		},
+	}
+}
+
+func TestProcessRange_SortsRowsByTableKey(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := sortFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		table string
+		cols  []string
+		want  []string
+	}{
+		{"logs", []string{"tx_hash", "log_index"}, []string{"0xa/9", "0xa/10", "0xb/3", "0xc/0"}},
+		{"token_transfers", []string{"tx_hash", "log_index"}, []string{"0xa/9", "0xa/10", "0xb/3", "0xc/0"}},
+		{"traces", []string{"tx_hash", "trace_id"}, []string{"0xa/0", "0xb/0", "0xb/1"}},
+		{"transactions", []string{"tx_hash", "is_internal", "trace_id"}, []string{"0xa/0/<nil>", "0xa/1/0", "0xb/0/<nil>", "0xb/1/0", "0xb/1/1"}},
+	} {
+		if got := payloadKeys(t, inserts[tc.table], tc.cols...); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s order = %v, want %v", tc.table, got, tc.want)
+		}
+	}
+}
+
+func TestProcessRange_SortKeysOverride(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := sortFixture(addr)
+	keys := map[string][]string{"logs": {"block_number", "log_index"}, "traces": {}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", SortKeys: keys}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := payloadKeys(t, inserts["logs"], "block_number", "log_index"), []string{"1/0", "2/3", "3/9", "3/10"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("logs order = %v, want %v", got, want)
+	}
+	// An empty key keeps fetch order.
+	if got, want := payloadKeys(t, inserts["traces"], "tx_hash", "trace_id"), []string{"0xb/1", "0xa/0", "0xb/0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("traces order = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/pkg/ch"
+)
+
+// DefaultOutputRotateBytes caps a single .jsonl export file before the
+// FileSink rolls over to the next sequence number.
+const DefaultOutputRotateBytes int64 = 64 << 20
+
+// Sink receives normalized rows for a table. *ch.Client satisfies it, so
+// ClickHouse and file exports share the same write path.
+type Sink interface {
+	InsertJSONEachRow(ctx context.Context, table string, rows []any) error
+}
+
+// ErrUnknownTable is returned for writes to a table outside the ingester's
+// schema (ValidateTable).
+var ErrUnknownTable = errors.New("ingest: unknown table")
+
+// knownTables holds every table the ingester writes rows to: the canonical
+// and dev data tables plus its bookkeeping tables.
+var knownTables = func() map[string]bool {
+	known := map[string]bool{"addresses": true, RunLocksTable: true, ReconciliationTable: true, CoverageTable: true, PendingTable: true, StandardsTable: true, UndecodedTable: true, RangeHashTable: true, EdgesTable: true}
+	for _, t := range CanonicalTables {
+		known[t] = true
+	}
+	for _, t := range DevTables {
+		known[t] = true
+	}
+	return known
+}()
+
+// ValidateTable reports whether table is one the ingester writes, returning
+// an error wrapping ErrUnknownTable otherwise. The ClickHouse client rewrites
+// characters it cannot quote, so without this check a typo'd name would be
+// sent as a different, likely nonexistent, identifier.
+func ValidateTable(table string) error {
+	if !knownTables[table] {
+		return fmt.Errorf("%w %q (not in the canonical or dev schema)", ErrUnknownTable, table)
+	}
+	return nil
+}
+
+// checkedSink rejects rows for unknown tables before they reach the sink.
+type checkedSink struct{ Sink }
+
+func (c checkedSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	if err := ValidateTable(table); err != nil {
+		return err
+	}
+	return c.Sink.InsertJSONEachRow(ctx, table, rows)
+}
+
+// multiSink fans rows out to every sink in order, stopping at the first error.
+type multiSink []Sink
+
+func (m multiSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	for _, s := range m {
+		if err := s.InsertJSONEachRow(ctx, table, rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSink halves a batch ClickHouse rejects with MEMORY_LIMIT_EXCEEDED
+// and inserts the halves in turn, recursively, until the parts fit or are no
+// larger than minRows (Options.InsertSplitMinRows). Halves written before a
+// later one fails are rewritten when the range is retried, which the
+// ReplacingMergeTree tables absorb.
+type splitSink struct {
+	Sink
+	minRows int
+}
+
+func (s splitSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	err := s.Sink.InsertJSONEachRow(ctx, table, rows)
+	if !errors.Is(err, ch.ErrMemoryLimitExceeded) || len(rows) <= s.minRows {
+		return err
+	}
+	half := len(rows) / 2
+	if logger := logging.Logger(); logger != nil {
+		logger.Warn("insert_split",
+			"component", "ingest",
+			"table", table,
+			"rows", len(rows),
+		)
+	}
+	if err := s.InsertJSONEachRow(ctx, table, rows[:half]); err != nil {
+		return err
+	}
+	return s.InsertJSONEachRow(ctx, table, rows[half:])
+}
+
+// newSink returns the row sink for opts: the ClickHouse sink alone, or
+// followed by a FileSink when OutputDir is set and by kafka when non-nil,
+// behind a check that rejects unknown tables and, with StrictValidate,
+// malformed rows. Only the ClickHouse sink splits batches that exceed the
+// server's memory limit (Options.InsertSplitMinRows). A client without a DSN
+// is a no-op, so OutputDir without ClickHouse exports files only.
+func newSink(c Sink, opts Options, kafka *KafkaSink) Sink {
+	if opts.InsertSplitMinRows >= 0 {
+		c = splitSink{Sink: c, minRows: max(opts.InsertSplitMinRows, 1)}
+	}
+	if opts.OutputDir != "" {
+		c = multiSink{c, NewFileSink(opts.OutputDir, opts.OutputRotateBytes)}
+	}
+	if kafka != nil {
+		c = multiSink{c, kafka}
+	}
+	if opts.StrictValidate {
+		c = validatingSink{Sink: c, names: opts.ColumnNames}
+	}
+	return checkedSink{c}
+}
+
+// FileSink writes rows as newline-delimited JSON to <dir>/<table>-NNNNNN.jsonl,
+// rotating to the next sequence number once a file reaches maxBytes. Each
+// batch is appended in one write, so rows from concurrent ingesters sharing
+// a directory interleave only at batch boundaries. Existing files are
+// appended to, which keeps restarts and re-runs additive.
+type FileSink struct {
+	dir      string
+	maxBytes int64
+
+	mu  sync.Mutex
+	seq map[string]int
+}
+
+// NewFileSink creates a FileSink rooted at dir. maxBytes <= 0 selects
+// DefaultOutputRotateBytes.
+func NewFileSink(dir string, maxBytes int64) *FileSink {
+	if maxBytes <= 0 {
+		maxBytes = DefaultOutputRotateBytes
+	}
+	return &FileSink{dir: dir, maxBytes: maxBytes, seq: make(map[string]int)}
+}
+
+// InsertJSONEachRow appends rows to the current file for table.
+func (s *FileSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("encode row %d: %w", i, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+	path, err := s.currentPath(table)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// currentPath returns the first file for table that still has room, advancing
+// past full files left by earlier batches or runs. Callers hold s.mu.
+func (s *FileSink) currentPath(table string) (string, error) {
+	seq := s.seq[table]
+	for {
+		path := filepath.Join(s.dir, fmt.Sprintf("%s-%06d.jsonl", table, seq))
+		fi, err := os.Stat(path)
+		if os.IsNotExist(err) || (err == nil && fi.Size() < s.maxBytes) {
+			s.seq[table] = seq
+			return path, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("stat %s: %w", path, err)
+		}
+		seq++
+	}
+}
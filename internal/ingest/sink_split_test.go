@@ -0,0 +1,97 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/pkg/ch"
+)
+
+// memoryLimitedCH rejects inserts holding more than maxRows rows with a
+// ClickHouse memory limit exception and records the accepted ones per table.
+func memoryLimitedCH(t *testing.T, ing *Ingester, maxRows int) map[string][]string {
+	t.Helper()
+	got := map[string][]string{}
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		if strings.HasPrefix(q, "INSERT INTO ") {
+			b, _ := io.ReadAll(r.Body)
+			if strings.Count(string(b), "\n") > maxRows {
+				body := "Code: 241. DB::Exception: Memory limit (for query) exceeded: would use 9.31 GiB. (MEMORY_LIMIT_EXCEEDED)"
+				return &http.Response{StatusCode: 500, Body: io.NopCloser(strings.NewReader(body))}, nil
+			}
+			table := strings.Fields(q)[2]
+			got[table] = append(got[table], string(b))
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}))
+	return got
+}
+
+func TestProcessRange_SplitsInsertsOverMemoryLimit(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ref := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Deterministic: true}, &prov)
+	whole := captureInserts(t, ref)
+	if err := ref.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	prov = tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Deterministic: true}, &prov)
+	split := memoryLimitedCH(t, ing, 1)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatalf("expected split inserts to succeed, got %v", err)
+	}
+	multi := false
+	for table, bodies := range whole {
+		rows := strings.Count(strings.Join(bodies, ""), "\n")
+		if len(split[table]) != rows {
+			t.Fatalf("%s: %d inserts for %d rows, want one row per insert", table, len(split[table]), rows)
+		}
+		if strings.Count(strings.Join(split[table], ""), "\n") != rows {
+			t.Fatalf("%s: rows lost while splitting", table)
+		}
+		multi = multi || rows > 1
+	}
+	if !multi {
+		t.Fatal("fixture has no multi-row insert to split")
+	}
+}
+
+func TestProcessRange_MemoryLimitSplitDisabled(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", InsertSplitMinRows: -1}, &prov)
+	memoryLimitedCH(t, ing, 1)
+	if err := ing.processRange(context.Background(), 1, 1); !errors.Is(err, ch.ErrMemoryLimitExceeded) {
+		t.Fatalf("expected ErrMemoryLimitExceeded, got %v", err)
+	}
+}
+
+type sinkFunc func(ctx context.Context, table string, rows []any) error
+
+func (f sinkFunc) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	return f(ctx, table, rows)
+}
+
+func TestSplitSink_StopsAtMinRows(t *testing.T) {
+	var sizes []int
+	inner := sinkFunc(func(ctx context.Context, table string, rows []any) error {
+		sizes = append(sizes, len(rows))
+		return ch.ErrMemoryLimitExceeded
+	})
+	s := splitSink{Sink: inner, minRows: 2}
+	err := s.InsertJSONEachRow(context.Background(), "logs", make([]any, 8))
+	if !errors.Is(err, ch.ErrMemoryLimitExceeded) {
+		t.Fatalf("expected the error once parts reach minRows, got %v", err)
+	}
+	if want := []int{8, 4, 2}; !slices.Equal(sizes, want) {
+		t.Fatalf("insert sizes = %v, want %v", sizes, want)
+	}
+}
@@ -0,0 +1,159 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestFileSink_WritesPerTableAndAppends(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	s := NewFileSink(dir, 0)
+	ctx := context.Background()
+	if err := s.InsertJSONEachRow(ctx, "logs", []any{map[string]any{"a": 1}, map[string]any{"a": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertJSONEachRow(ctx, "logs", []any{map[string]any{"a": 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertJSONEachRow(ctx, "traces", nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := readLines(t, filepath.Join(dir, "logs-000000.jsonl"))
+	if len(lines) != 3 || lines[2] != `{"a":3}` {
+		t.Fatalf("unexpected rows: %v", lines)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "traces-000000.jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("empty batch should not create a file: %v", err)
+	}
+}
+
+func TestFileSink_RotatesBySizeAcrossRuns(t *testing.T) {
+	dir := t.TempDir()
+	row := []any{map[string]any{"k": "0123456789"}}
+	s := NewFileSink(dir, 10)
+	for n := 0; n < 3; n++ {
+		if err := s.InsertJSONEachRow(context.Background(), "logs", row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A fresh sink (restart) skips full files rather than growing them.
+	if err := NewFileSink(dir, 10).InsertJSONEachRow(context.Background(), "logs", row); err != nil {
+		t.Fatal(err)
+	}
+	for seq := 0; seq < 4; seq++ {
+		path := filepath.Join(dir, fmt.Sprintf("logs-%06d.jsonl", seq))
+		if lines := readLines(t, path); len(lines) != 1 {
+			t.Fatalf("%s: %v", path, lines)
+		}
+	}
+}
+
+func TestFileSink_Errors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFileSink(file, 0).InsertJSONEachRow(context.Background(), "logs", []any{1}); err == nil {
+		t.Fatal("expected error when output dir is a file")
+	}
+	if err := NewFileSink(t.TempDir(), 0).InsertJSONEachRow(context.Background(), "logs", []any{make(chan int)}); err == nil {
+		t.Fatal("expected encode error")
+	}
+}
+
+type errSink struct{ calls int }
+
+func (s *errSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	s.calls++
+	return errors.New("boom")
+}
+
+func TestMultiSink_StopsAtFirstError(t *testing.T) {
+	first, second := &errSink{}, &errSink{}
+	if err := (multiSink{first, second}).InsertJSONEachRow(context.Background(), "logs", []any{1}); err == nil {
+		t.Fatal("expected error")
+	}
+	if first.calls != 1 || second.calls != 0 {
+		t.Fatalf("calls first=%d second=%d", first.calls, second.calls)
+	}
+}
+
+func TestValidateTable(t *testing.T) {
+	known := append(append([]string{"addresses", RunLocksTable, ReconciliationTable, CoverageTable}, CanonicalTables...), DevTables...)
+	for _, table := range known {
+		if err := ValidateTable(table); err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+	}
+	for _, table := range []string{"", "log", "Logs", "dev_logs;", "token-transfers", "db.logs"} {
+		if err := ValidateTable(table); !errors.Is(err, ErrUnknownTable) {
+			t.Fatalf("%q: err = %v, want ErrUnknownTable", table, err)
+		}
+	}
+}
+
+func TestCheckedSink_RejectsUnknownTableBeforeInsert(t *testing.T) {
+	inner := &errSink{}
+	s := newSink(inner, Options{}, nil)
+	err := s.InsertJSONEachRow(context.Background(), "token-transfers", []any{1})
+	if !errors.Is(err, ErrUnknownTable) || !strings.Contains(err.Error(), `"token-transfers"`) {
+		t.Fatalf("err = %v", err)
+	}
+	if inner.calls != 0 {
+		t.Fatalf("inner sink called %d times", inner.calls)
+	}
+	if err := s.InsertJSONEachRow(context.Background(), "logs", []any{1}); err == nil || inner.calls != 1 {
+		t.Fatalf("known table not forwarded: err=%v calls=%d", err, inner.calls)
+	}
+}
+
+func TestBackfill_OutputDirWritesNormalizedRows(t *testing.T) {
+	dir := t.TempDir()
+	addr := "0x" + strings.Repeat("a", 40)
+	from := padTopicAddr(addr)
+	to := padTopicAddr("0x" + strings.Repeat("b", 40))
+	prov := &fixtureProv{
+		head: 10,
+		logs: []eth.Log{{
+			TxHash:   "0x" + strings.Repeat("1", 64),
+			Index:    0,
+			Address:  "0x" + strings.Repeat("c", 40),
+			Topics:   []string{"0xddf252ad", from, to},
+			DataHex:  "0x" + strings.Repeat("0", 63) + "5",
+			BlockNum: 3,
+			TsMillis: 3000,
+		}},
+	}
+	// No ClickHouse DSN: rows go to files only.
+	ing := NewWithProvider(addr, Options{Confirmations: 1, BatchBlocks: 100, OutputDir: dir}, prov)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	logs := readLines(t, filepath.Join(dir, "logs-000000.jsonl"))
+	if len(logs) != 1 || !strings.Contains(logs[0], `"block_number":3`) || !strings.Contains(logs[0], `"unconfirmed":0`) {
+		t.Fatalf("unexpected logs export: %v", logs)
+	}
+	transfers := readLines(t, filepath.Join(dir, "token_transfers-000000.jsonl"))
+	if len(transfers) != 1 || !strings.Contains(transfers[0], `"amount_raw":"5"`) || !strings.Contains(transfers[0], `"from_addr":"`+addr+`"`) {
+		t.Fatalf("unexpected transfers export: %v", transfers)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "addresses-000000.jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("checkpoints must not be exported: %v", err)
+	}
+}
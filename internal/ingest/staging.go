@@ -0,0 +1,204 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/pkg/ch"
+)
+
+// stagingSink redirects ClickHouse rows into per-address staging tables while
+// a batch is open (Options.StagedCommit). Outside a batch, and for tables
+// never written in one, rows go straight to their target table.
+type stagingSink struct {
+	c      *ch.Client
+	prefix string // _stage_<address>_; the target table name follows
+
+	open   bool
+	tables []string // targets staged in the open batch, in first-write order
+}
+
+func newStagingSink(c *ch.Client, address string) *stagingSink {
+	return &stagingSink{c: c, prefix: "_stage_" + strings.TrimPrefix(address, "0x") + "_"}
+}
+
+func (s *stagingSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	if !s.open || len(rows) == 0 {
+		return s.c.InsertJSONEachRow(ctx, table, rows)
+	}
+	return s.stage(ctx, table, rows)
+}
+
+// stage creates the staging table for table on first use and appends rows.
+func (s *stagingSink) stage(ctx context.Context, table string, rows []any) error {
+	if !slices.Contains(s.tables, table) {
+		if err := s.c.Exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS %s", s.prefix+table, table)); err != nil {
+			return fmt.Errorf("creating staging table for %s: %w", table, err)
+		}
+		s.tables = append(s.tables, table)
+	}
+	return s.c.InsertJSONEachRow(ctx, s.prefix+table, rows)
+}
+
+// publish copies each staged table into its target and drops it, in order.
+// A failure leaves the remaining staging tables for recoverStaged.
+func (s *stagingSink) publish(ctx context.Context, tables []string) error {
+	for _, table := range tables {
+		if err := s.c.Exec(ctx, fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", table, s.prefix+table)); err != nil {
+			return fmt.Errorf("publishing staged %s: %w", table, err)
+		}
+		if err := s.drop(ctx, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *stagingSink) drop(ctx context.Context, table string) error {
+	if err := s.c.Exec(ctx, "DROP TABLE IF EXISTS "+s.prefix+table); err != nil {
+		return fmt.Errorf("dropping staged %s: %w", table, err)
+	}
+	return nil
+}
+
+// leftovers lists the staging tables an earlier run left, keyed by target
+// table, with their row counts.
+func (s *stagingSink) leftovers(ctx context.Context) (map[string]uint64, error) {
+	query := fmt.Sprintf("SELECT name, total_rows FROM system.tables WHERE database = currentDatabase() AND startsWith(name, '%s') FORMAT JSONEachRow SETTINGS output_format_json_quote_64bit_integers = 0", quoteCHString(s.prefix))
+	rows, err := s.c.QueryJSONEachRow(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("listing staging tables: %w", err)
+	}
+	tables := make(map[string]uint64, len(rows))
+	for _, raw := range rows {
+		var r struct {
+			Name      string  `json:"name"`
+			TotalRows *uint64 `json:"total_rows"`
+		}
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, fmt.Errorf("decoding staging table: %w", err)
+		}
+		var n uint64
+		if r.TotalRows != nil {
+			n = *r.TotalRows
+		}
+		tables[strings.TrimPrefix(r.Name, s.prefix)] = n
+	}
+	return tables, nil
+}
+
+// processStaged runs one batch with its rows staged, then commits them
+// together with the checkpoint for rs.checkpoint. The checkpoint row is
+// staged last, so a non-empty staged addresses table marks a batch whose rows
+// are all staged; publishing copies the data tables first and addresses last.
+//
+// ClickHouse has no multi-statement transactions, so a crash can still
+// interrupt publishing. recoverStaged completes such a batch on the next run
+// instead of re-ingesting it; only a crash between one table's copy and its
+// drop repeats that table's rows, which the canonical ReplacingMergeTree
+// tables collapse.
+func (i *Ingester) processStaged(ctx context.Context, from, to uint64, rs rangeState) error {
+	s := i.stage
+	s.open, s.tables = true, nil
+	err := i.processRangeState(ctx, from, to, rs)
+	s.open = false
+	if err != nil {
+		i.discardStaged(ctx, s.tables)
+		return err
+	}
+	ckpt, _, err := i.loadCheckpoint(ctx)
+	if err != nil {
+		i.discardStaged(ctx, s.tables)
+		return err
+	}
+	// The batch only counts as covered once it is published.
+	covered := slices.Clone(i.cov.covered)
+	i.markCovered(from, to)
+	synced := ckpt.LastSyncedBlock
+	if last, ok := i.cov.frontier(); ok && last > synced {
+		synced = last
+	}
+	next, row := i.checkpointRow(ckpt, rs.checkpoint, synced)
+	if err := s.stage(ctx, "addresses", []any{row}); err != nil {
+		i.cov.covered = covered
+		i.discardStaged(ctx, s.tables)
+		return err
+	}
+	if err := s.publish(ctx, s.tables); err != nil {
+		i.cov.covered = covered
+		return err
+	}
+	i.saveCheckpoint(next)
+	return nil
+}
+
+// discardStaged drops an unfinished batch's staging tables. Failures are
+// logged; recoverStaged drops the leftovers on the next run.
+func (i *Ingester) discardStaged(ctx context.Context, tables []string) {
+	for _, table := range tables {
+		if err := i.stage.drop(context.WithoutCancel(ctx), table); err != nil {
+			if logger := logging.Logger(); logger != nil {
+				logger.Warn("staging_cleanup_failed",
+					"component", "ingest",
+					"address", i.address,
+					"table", table,
+					"error", err.Error(),
+				)
+			}
+		}
+	}
+}
+
+// recoverStaged resolves a batch an earlier run left in its staging tables:
+// one whose checkpoint row was staged is published, anything else is dropped
+// and re-ingested from the checkpoint. Without Options.StagedCommit it is a
+// no-op.
+func (i *Ingester) recoverStaged(ctx context.Context) error {
+	if i.stage == nil {
+		return nil
+	}
+	leftovers, err := i.stage.leftovers(ctx)
+	if err != nil || len(leftovers) == 0 {
+		return err
+	}
+	tables := make([]string, 0, len(leftovers))
+	for table := range leftovers {
+		if table != "addresses" {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+	if _, ok := leftovers["addresses"]; ok {
+		tables = append(tables, "addresses")
+	}
+	action := "discarded"
+	if leftovers["addresses"] > 0 {
+		action = "published"
+		if err := i.stage.publish(ctx, tables); err != nil {
+			return err
+		}
+		i.curMu.Lock()
+		i.cur = nil
+		i.curMu.Unlock()
+	} else {
+		for _, table := range tables {
+			if err := i.stage.drop(ctx, table); err != nil {
+				return err
+			}
+		}
+	}
+	if logger := logging.Logger(); logger != nil {
+		logger.Warn("staged_batch_recovered",
+			"component", "ingest",
+			"address", i.address,
+			"action", action,
+			"tables", strings.Join(tables, ","),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// stagingCH is an in-memory ClickHouse that understands the statements the
+// staged commit issues. Once a request matches crashAt (on its crashNth
+// occurrence) it and every later request fail, as if the process died there.
+type stagingCH struct {
+	t        *testing.T
+	tables   map[string][]string
+	crashAt  string
+	crashNth int
+	seen     int
+	down     bool
+}
+
+func newStagingCH(t *testing.T) *stagingCH {
+	return &stagingCH{t: t, tables: map[string][]string{"addresses": nil, "transactions": nil}}
+}
+
+func (s *stagingCH) RoundTrip(r *http.Request) (*http.Response, error) {
+	q := r.URL.Query().Get("query")
+	if s.crashAt != "" && strings.HasPrefix(q, s.crashAt) {
+		if s.seen++; s.seen == s.crashNth {
+			s.down = true
+		}
+	}
+	if s.down {
+		return &http.Response{StatusCode: 400, Body: io.NopCloser(strings.NewReader("crashed"))}, nil
+	}
+	body := ""
+	f := strings.Fields(q)
+	switch {
+	case strings.Contains(q, "FROM system.tables"):
+		prefix := q[strings.Index(q, "startsWith(name, '")+len("startsWith(name, '"):]
+		prefix = prefix[:strings.Index(prefix, "'")]
+		for name := range s.tables {
+			if strings.HasPrefix(name, prefix) {
+				body += fmt.Sprintf("{\"name\":%q,\"total_rows\":%d}\n", name, len(s.tables[name]))
+			}
+		}
+	case strings.Contains(q, "FROM addresses WHERE"):
+		if rows := s.tables["addresses"]; len(rows) > 0 {
+			body = rows[len(rows)-1]
+		}
+	case strings.Contains(q, "FROM "+CoverageTable+" FINAL"):
+		body = strings.Join(s.tables[CoverageTable], "\n")
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS "):
+		if _, ok := s.tables[f[5]]; !ok {
+			s.tables[f[5]] = nil
+		}
+	case strings.HasPrefix(q, "DROP TABLE IF EXISTS "):
+		delete(s.tables, f[4])
+	case strings.HasPrefix(q, "INSERT INTO ") && strings.Contains(q, " SELECT * FROM "):
+		s.tables[f[2]] = append(s.tables[f[2]], s.tables[f[6]]...)
+	case strings.HasPrefix(q, "INSERT INTO "):
+		if _, ok := s.tables[f[2]]; !ok {
+			s.t.Fatalf("insert into missing table %s", f[2])
+		}
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			s.tables[f[2]] = append(s.tables[f[2]], sc.Text())
+		}
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+// stagedProv serves one outgoing transaction per block, honoring ranges.
+type stagedProv struct{ fixtureProv }
+
+func (p *stagedProv) Transactions(ctx context.Context, address string, from, to uint64) ([]eth.Transaction, error) {
+	var out []eth.Transaction
+	for b := from; b <= to; b++ {
+		out = append(out, eth.Transaction{Hash: fmt.Sprintf("0x%x", b), From: address, To: "0x" + strings.Repeat("b", 40), ValueWei: "0x1", Status: 1, BlockNum: b})
+	}
+	return out, nil
+}
+
+func (p *stagedProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	return nil, nil
+}
+
+func (p *stagedProv) TraceBlock(ctx context.Context, from, to uint64, address string) ([]eth.Trace, error) {
+	return nil, nil
+}
+
+func stagedIngester(store *stagingCH) *Ingester {
+	opts := Options{ClickHouseDSN: "http://localhost:8123/db", StagedCommit: true, BatchBlocks: 2, ToBlock: 9, Tables: []string{"transactions"}}
+	ing := NewWithProvider("0x"+strings.Repeat("a", 40), opts, &stagedProv{fixtureProv{head: 100}})
+	ing.ch.SetTransport(store)
+	return ing
+}
+
+// assertExactlyOnce checks that blocks 0..9 each produced one transaction
+// row, the checkpoint reached block 9 and no staging tables remain.
+func assertExactlyOnce(t *testing.T, store *stagingCH) {
+	t.Helper()
+	seen := map[uint64]int{}
+	for _, raw := range store.tables["transactions"] {
+		var row struct {
+			Block uint64 `json:"block_number"`
+		}
+		if err := json.Unmarshal([]byte(raw), &row); err != nil {
+			t.Fatal(err)
+		}
+		seen[row.Block]++
+	}
+	for b := uint64(0); b <= 9; b++ {
+		if seen[b] != 1 {
+			t.Fatalf("block %d has %d rows: %v", b, seen[b], seen)
+		}
+	}
+	var ckpt addressCheckpoint
+	rows := store.tables["addresses"]
+	if err := json.Unmarshal([]byte(rows[len(rows)-1]), &ckpt); err != nil || ckpt.LastSyncedBlock != 9 {
+		t.Fatalf("checkpoint = %+v (%v)", ckpt, err)
+	}
+	for name := range store.tables {
+		if strings.HasPrefix(name, "_stage_") {
+			t.Fatalf("staging table left behind: %s", name)
+		}
+	}
+}
+
+func TestStagedCommit_CommitsEachBatchWithCheckpoint(t *testing.T) {
+	store := newStagingCH(t)
+	if err := stagedIngester(store).Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertExactlyOnce(t, store)
+	// Five batches each publish a checkpoint, plus the final backfill one.
+	if n := len(store.tables["addresses"]); n != 6 {
+		t.Fatalf("checkpoint rows = %d, want 6", n)
+	}
+}
+
+func TestStagedCommit_CrashBetweenRowsAndCheckpoint(t *testing.T) {
+	store := newStagingCH(t)
+	// The third batch (blocks 4-5) dies after its transactions are published
+	// but before its checkpoint is.
+	store.crashAt, store.crashNth = "INSERT INTO addresses SELECT", 3
+	if err := stagedIngester(store).Backfill(context.Background()); err == nil {
+		t.Fatal("expected crash")
+	}
+	if n := len(store.tables["transactions"]); n != 6 {
+		t.Fatalf("transactions before recovery = %d, want 6", n)
+	}
+	store.crashAt, store.down = "", false
+	logs := captureLogs(t)
+	if err := stagedIngester(store).Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertExactlyOnce(t, store)
+	if !strings.Contains(logs.String(), `"msg":"staged_batch_recovered"`) || !strings.Contains(logs.String(), `"action":"published"`) {
+		t.Fatalf("expected published recovery log: %s", logs.String())
+	}
+}
+
+func TestStagedCommit_CrashWhileStaging(t *testing.T) {
+	store := newStagingCH(t)
+	// The second batch (blocks 2-3) dies after staging its transactions but
+	// before staging its checkpoint.
+	store.crashAt, store.crashNth = "INSERT INTO _stage_"+strings.Repeat("a", 40)+"_addresses", 2
+	if err := stagedIngester(store).Backfill(context.Background()); err == nil {
+		t.Fatal("expected crash")
+	}
+	if n := len(store.tables["_stage_"+strings.Repeat("a", 40)+"_transactions"]); n != 2 {
+		t.Fatalf("staged transactions = %d, want 2", n)
+	}
+	store.crashAt, store.down = "", false
+	logs := captureLogs(t)
+	if err := stagedIngester(store).Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertExactlyOnce(t, store)
+	if !strings.Contains(logs.String(), `"action":"discarded"`) {
+		t.Fatalf("expected discarded recovery log: %s", logs.String())
+	}
+}
+
+func TestStagedCommit_DisabledWithoutClickHouse(t *testing.T) {
+	ing := NewWithProvider("0xabc", Options{StagedCommit: true}, &stagedProv{})
+	if ing.stage != nil {
+		t.Fatal("staging enabled without a DSN")
+	}
+	if err := ing.recoverStaged(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
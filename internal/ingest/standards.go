@@ -0,0 +1,145 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+// StandardsTable caches the token standard each contract was classified as
+// (Options.CacheStandards), shared by every address ingested into the same
+// database.
+const StandardsTable = "contract_standards"
+
+// standardCache holds the StandardsTable entries looked up this run, with ""
+// for contracts the table did not know, so each contract is queried at most
+// once per Ingester.
+type standardCache struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// decodeTokenEvents decodes the token events in logs. With
+// Options.CacheStandards the standards of their contracts are read through
+// StandardsTable first, and contracts the decode classifies for the first
+// time are stored there.
+func (i *Ingester) decodeTokenEvents(ctx context.Context, logs []eth.Log) ([]normalize.TokenTransferRow, []normalize.ApprovalRow, error) {
+	opts := i.tokenDecodeOptions()
+	if !i.opts.CacheStandards || i.ch == nil || !i.ch.Enabled() {
+		transfers, approvals := normalize.DecodeTokenEventsWith(logs, opts)
+		return transfers, approvals, nil
+	}
+	known, err := i.knownStandards(ctx, normalize.TokenContracts(logs))
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.KnownStandards = known
+	transfers, approvals := normalize.DecodeTokenEventsWith(logs, opts)
+	if err := i.storeStandards(ctx, transfers, known); err != nil {
+		return nil, nil, err
+	}
+	return transfers, approvals, nil
+}
+
+// knownStandards returns the cached standards of contracts, querying
+// StandardsTable for those not looked up yet.
+func (i *Ingester) knownStandards(ctx context.Context, contracts []string) (map[string]string, error) {
+	i.standards.mu.Lock()
+	defer i.standards.mu.Unlock()
+	if i.standards.m == nil {
+		i.standards.m = map[string]string{}
+	}
+	var quoted []string
+	for _, c := range contracts {
+		if _, ok := i.standards.m[c]; !ok {
+			quoted = append(quoted, "'"+quoteCHString(c)+"'")
+		}
+	}
+	if len(quoted) > 0 {
+		query := fmt.Sprintf("SELECT contract, standard FROM %s FINAL WHERE contract IN (%s) FORMAT JSONEachRow", StandardsTable, strings.Join(quoted, ", "))
+		rows, err := i.ch.QueryJSONEachRow(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", StandardsTable, err)
+		}
+		for _, c := range contracts {
+			if _, ok := i.standards.m[c]; !ok {
+				i.standards.m[c] = ""
+			}
+		}
+		for _, raw := range rows {
+			var r struct {
+				Contract string `json:"contract"`
+				Standard string `json:"standard"`
+			}
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", StandardsTable, err)
+			}
+			i.standards.m[strings.ToLower(r.Contract)] = r.Standard
+		}
+	}
+	known := make(map[string]string, len(contracts))
+	for _, c := range contracts {
+		if std := i.standards.m[c]; std != "" {
+			known[c] = std
+		}
+	}
+	return known, nil
+}
+
+// storeStandards writes the standards transfers classify for contracts not in
+// known. A contract seen with ERC-1155 transfers is erc1155 whatever else it
+// emitted; otherwise its first classified transfer decides. Stored entries
+// are never reclassified.
+func (i *Ingester) storeStandards(ctx context.Context, transfers []normalize.TokenTransferRow, known map[string]string) error {
+	classified := map[string]normalize.TokenTransferRow{}
+	for _, tr := range transfers {
+		token := strings.ToLower(tr.Token)
+		if tr.Standard == "" || known[token] != "" {
+			continue
+		}
+		prev, ok := classified[token]
+		switch {
+		case !ok:
+			classified[token] = tr
+		case tr.Standard == "erc1155" && prev.Standard != "erc1155":
+			tr.BlockNum = min(tr.BlockNum, prev.BlockNum)
+			classified[token] = tr
+		case tr.BlockNum < prev.BlockNum:
+			prev.BlockNum = tr.BlockNum
+			classified[token] = prev
+		}
+	}
+	if len(classified) == 0 {
+		return nil
+	}
+	contracts := make([]string, 0, len(classified))
+	for c := range classified {
+		contracts = append(contracts, c)
+	}
+	sort.Strings(contracts)
+	version := i.rowVersion()
+	rows := make([]any, 0, len(contracts))
+	for _, c := range contracts {
+		rows = append(rows, map[string]any{
+			"contract":    c,
+			"standard":    classified[c].Standard,
+			"first_block": classified[c].BlockNum,
+			"updated_at":  version,
+		})
+	}
+	if err := i.sink.InsertJSONEachRow(ctx, StandardsTable, rows); err != nil {
+		return fmt.Errorf("inserting %s: %w", StandardsTable, err)
+	}
+	i.standards.mu.Lock()
+	for _, c := range contracts {
+		i.standards.m[c] = classified[c].Standard
+	}
+	i.standards.mu.Unlock()
+	return nil
+}
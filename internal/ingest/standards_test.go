@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestCacheStandards_ReadThrough(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	cached := "0x" + strings.Repeat("c", 40)
+	fresh := "0x" + strings.Repeat("d", 40)
+	holder, other := padTopicAddr(addr), padTopicAddr("0x"+strings.Repeat("b", 40))
+	prov := &fixtureProv{logs: []eth.Log{
+		// Four topics and no data: the ERC-721 shape, but cached as erc20.
+		{TxHash: "0x1", Index: 0, Address: cached, Topics: []string{"0xddf252ad", holder, other, "0x" + strings.Repeat("0", 62) + "64"}, DataHex: "0x", BlockNum: 5},
+		{TxHash: "0x1", Index: 1, Address: fresh, Topics: []string{"0xddf252ad", holder, other}, DataHex: "0x01", BlockNum: 5},
+	}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", CacheStandards: true}, prov)
+	var lookups []string
+	inserts := map[string][]string{}
+	ing.ch.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		var out string
+		switch {
+		case strings.HasPrefix(q, "INSERT INTO "):
+			b, _ := io.ReadAll(r.Body)
+			table := strings.Fields(q)[2]
+			inserts[table] = append(inserts[table], string(b))
+		case strings.Contains(q, "FROM "+StandardsTable):
+			lookups = append(lookups, q)
+			out = `{"contract":"` + cached + `","standard":"erc20"}` + "\n"
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(out))}, nil
+	}))
+
+	if err := ing.processRange(context.Background(), 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(lookups) != 1 || !strings.Contains(lookups[0], "'"+cached+"', '"+fresh+"'") {
+		t.Fatalf("lookups = %v", lookups)
+	}
+	transfers := strings.Split(strings.TrimSpace(strings.Join(inserts["token_transfers"], "")), "\n")
+	var first map[string]any
+	if err := json.Unmarshal([]byte(transfers[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first["standard"] != "erc20" || first["amount_raw"] != "100" || first["non_standard"] != float64(1) {
+		t.Fatalf("cached contract decoded as %v", first)
+	}
+	if len(inserts[StandardsTable]) != 1 {
+		t.Fatalf("standards inserts = %v", inserts[StandardsTable])
+	}
+	var stored map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(inserts[StandardsTable][0])), &stored); err != nil {
+		t.Fatalf("only the new contract should be stored: %v", err)
+	}
+	if stored["contract"] != fresh || stored["standard"] != "erc20" || stored["first_block"] != float64(5) {
+		t.Fatalf("stored = %v", stored)
+	}
+
+	// Both contracts are known now: no lookup and nothing stored again.
+	if err := ing.processRange(context.Background(), 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(lookups) != 1 || len(inserts[StandardsTable]) != 1 {
+		t.Fatalf("lookups=%d standards inserts=%d after rerun", len(lookups), len(inserts[StandardsTable]))
+	}
+}
+
+func TestCacheStandards_Off(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts[StandardsTable]) != 0 {
+		t.Fatalf("standards stored while off: %v", inserts[StandardsTable])
+	}
+}
+
+func TestCacheStandards_DiffWritesNothing(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	fresh := "0x" + strings.Repeat("d", 40)
+	prov := &fixtureProv{logs: []eth.Log{
+		{TxHash: "0x1", Index: 0, Address: fresh, Topics: []string{"0xddf252ad", padTopicAddr(addr), padTopicAddr("0x" + strings.Repeat("b", 40))}, DataHex: "0x01", BlockNum: 5},
+	}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", CacheStandards: true}, prov)
+	inserts := captureInserts(t, ing)
+	ds := &diffSink{rows: map[string][]any{}}
+	ing.sink = ds
+	if err := ing.processRange(context.Background(), 5, 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts) != 0 {
+		t.Fatalf("diff wrote to ClickHouse: %v", inserts)
+	}
+	if len(ds.rows[StandardsTable]) != 1 {
+		t.Fatalf("standards rows = %v, want them on the sink", ds.rows[StandardsTable])
+	}
+}
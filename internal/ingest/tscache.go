@@ -0,0 +1,17 @@
+package ingest
+
+import "github.com/AIAleph/mvp_wallet_context/internal/eth"
+
+// DefaultTimestampCacheSize bounds the block timestamp cache when
+// Options.TimestampCacheSize is 0.
+const DefaultTimestampCacheSize = 65536
+
+// newTsCache returns the ingester's block timestamp cache: the provider's LRU
+// without TTLs, since the ingester drops entries itself when a reorg rescan
+// replays blocks (pruneTimestampCache).
+func newTsCache(max int) *eth.TimestampCache {
+	if max <= 0 {
+		max = DefaultTimestampCacheSize
+	}
+	return eth.NewTimestampCache(max)
+}
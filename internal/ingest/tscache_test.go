@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestTsCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTsCache(3)
+	for b := uint64(1); b <= 3; b++ {
+		c.Add(b, int64(b)*1000)
+	}
+	c.Get(1) // 2 is now the least recently used
+	c.Add(4, 4000)
+	if n := c.Len(); n != 3 {
+		t.Fatalf("len = %d, want 3", n)
+	}
+	if _, ok := c.Get(2); ok {
+		t.Fatal("block 2 should have been evicted")
+	}
+	for _, b := range []uint64{1, 3, 4} {
+		if ts, ok := c.Get(b); !ok || ts != int64(b)*1000 {
+			t.Fatalf("block %d: ts=%d ok=%v", b, ts, ok)
+		}
+	}
+	c.Add(3, 3001)
+	if ts, _ := c.Get(3); ts != 3001 || c.Len() != 3 {
+		t.Fatalf("update: ts=%d len=%d", ts, c.Len())
+	}
+}
+
+func TestTsCache_DefaultSize(t *testing.T) {
+	if c := newTsCache(0); c.Cap() != DefaultTimestampCacheSize {
+		t.Fatalf("max = %d", c.Cap())
+	}
+	ing := NewWithProvider("0xabc", Options{TimestampCacheSize: 2}, &fixtureProv{})
+	for b := uint64(1); b <= 5; b++ {
+		if _, ok := ing.getBlockTs(context.Background(), b); !ok {
+			t.Fatalf("block %d: no timestamp", b)
+		}
+	}
+	if n := ing.tsCache.Len(); n != 2 {
+		t.Fatalf("ingester cache holds %d entries, want 2", n)
+	}
+}
+
+// Run with -race.
+func TestTsCache_ConcurrentAccess(t *testing.T) {
+	ing := NewWithProvider("0xabc", Options{TimestampCacheSize: 64}, &fixtureProv{})
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for b := uint64(0); b < 500; b++ {
+				block := b*uint64(g+1)%200 + 1
+				if ts, ok := ing.getBlockTs(context.Background(), block); !ok || ts != int64(block)*1000 {
+					t.Errorf("block %d: ts=%d ok=%v", block, ts, ok)
+					return
+				}
+				if b%97 == 0 {
+					ing.pruneTimestampCache(block)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := ing.tsCache.Len(); n > 64 {
+		t.Fatalf("cache grew to %d entries past its bound", n)
+	}
+}
@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+// UndecodedTable receives, per run, one row per (contract, topic0) of the
+// logs no decoder recognised (Options.AuditUndecoded).
+const UndecodedTable = "undecoded_events"
+
+// undecodedKey identifies an undecoded event kind.
+type undecodedKey struct {
+	contract string
+	topic0   string
+}
+
+// undecodedCount aggregates the logs of one undecodedKey seen in a run.
+type undecodedCount struct {
+	count      uint64
+	firstBlock uint64
+	lastBlock  uint64
+}
+
+// undecodedLogs accumulates undecoded logs across the ranges of a run.
+type undecodedLogs struct {
+	mu     sync.Mutex
+	counts map[undecodedKey]*undecodedCount
+}
+
+// noteUndecoded counts the logs whose topic0 matches no known event.
+// Anonymous logs (no topics) carry no signature to group by and are skipped.
+func (i *Ingester) noteUndecoded(logs []eth.Log) {
+	if !i.opts.AuditUndecoded {
+		return
+	}
+	u := &i.undecoded
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, l := range logs {
+		if len(l.Topics) == 0 || normalize.IsKnownEvent(l.Topics[0]) {
+			continue
+		}
+		key := undecodedKey{contract: strings.ToLower(l.Address), topic0: strings.ToLower(l.Topics[0])}
+		if u.counts == nil {
+			u.counts = map[undecodedKey]*undecodedCount{}
+		}
+		c, ok := u.counts[key]
+		if !ok {
+			c = &undecodedCount{firstBlock: l.BlockNum, lastBlock: l.BlockNum}
+			u.counts[key] = c
+		}
+		c.count++
+		c.firstBlock = min(c.firstBlock, l.BlockNum)
+		c.lastBlock = max(c.lastBlock, l.BlockNum)
+	}
+}
+
+// flushUndecoded writes the run's undecoded event counts to UndecodedTable
+// and resets them. The audit is best effort: a failed write is logged, not
+// returned, so it never fails the ingestion it describes.
+func (i *Ingester) flushUndecoded(ctx context.Context) {
+	u := &i.undecoded
+	u.mu.Lock()
+	counts := u.counts
+	u.counts = nil
+	u.mu.Unlock()
+	if len(counts) == 0 || i.ch == nil || !i.ch.Enabled() {
+		return
+	}
+	keys := make([]undecodedKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].contract != keys[b].contract {
+			return keys[a].contract < keys[b].contract
+		}
+		return keys[a].topic0 < keys[b].topic0
+	})
+	version := i.rowVersion()
+	rows := make([]any, 0, len(keys))
+	for _, k := range keys {
+		c := counts[k]
+		rows = append(rows, map[string]any{
+			"address":     i.address,
+			"contract":    k.contract,
+			"topic0":      k.topic0,
+			"count":       c.count,
+			"first_block": c.firstBlock,
+			"last_block":  c.lastBlock,
+			"observed_at": version,
+		})
+	}
+	logger := logging.Logger()
+	if err := i.ch.InsertJSONEachRow(ctx, UndecodedTable, rows); err != nil {
+		if logger != nil {
+			logger.Warn("undecoded_flush_failed",
+				"component", "ingest",
+				"address", i.address,
+				"kinds", len(rows),
+				"error", err.Error(),
+			)
+		}
+		return
+	}
+	if logger != nil {
+		logger.Info("undecoded_events",
+			"component", "ingest",
+			"address", i.address,
+			"kinds", len(rows),
+		)
+	}
+}
@@ -0,0 +1,89 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestAuditUndecoded_AggregatesPerRun(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	pool := "0x" + strings.Repeat("c", 40)
+	swap := "0x" + strings.Repeat("d", 64)
+	sync := "0x" + strings.Repeat("e", 64)
+	prov := tablesFixture(addr) // a Transfer and an Approval, both known
+	prov.logs = append(prov.logs,
+		eth.Log{TxHash: "0x2", Index: 2, Address: strings.ToUpper(pool[:2]) + pool[2:], Topics: []string{strings.ToUpper(swap)}, BlockNum: 3},
+		eth.Log{TxHash: "0x2", Index: 3, Address: pool, Topics: []string{swap}, BlockNum: 1},
+		eth.Log{TxHash: "0x2", Index: 4, Address: pool, Topics: []string{sync}, BlockNum: 2},
+		eth.Log{TxHash: "0x2", Index: 5, Address: pool, Topics: nil, BlockNum: 2}, // anonymous
+	)
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", AuditUndecoded: true}, &prov)
+	inserts := captureInserts(t, ing)
+
+	// Two ranges of one run: the same logs are counted twice, written once.
+	for range 2 {
+		if err := ing.processRange(context.Background(), 1, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(inserts[UndecodedTable]) != 0 {
+		t.Fatalf("flushed before the run ended: %v", inserts[UndecodedTable])
+	}
+	ing.flushUndecoded(context.Background())
+	if len(inserts[UndecodedTable]) != 1 {
+		t.Fatalf("undecoded inserts = %v", inserts[UndecodedTable])
+	}
+	lines := strings.Split(strings.TrimSpace(inserts[UndecodedTable][0]), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("rows = %v", lines)
+	}
+	var rows []map[string]any
+	for _, line := range lines {
+		var row map[string]any
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if r := rows[0]; r["address"] != addr || r["contract"] != pool || r["topic0"] != swap || r["count"] != float64(4) || r["first_block"] != float64(1) || r["last_block"] != float64(3) {
+		t.Fatalf("swap row = %v", r)
+	}
+	if r := rows[1]; r["topic0"] != sync || r["count"] != float64(2) {
+		t.Fatalf("sync row = %v", r)
+	}
+
+	// Counts reset with each flush, and nothing is written for an empty run.
+	ing.flushUndecoded(context.Background())
+	if len(inserts[UndecodedTable]) != 1 {
+		t.Fatalf("empty run wrote %v", inserts[UndecodedTable][1:])
+	}
+}
+
+func TestAuditUndecoded_FlushedByDelta(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := tablesFixture(addr)
+	prov.head = 1
+	prov.logs = append(prov.logs, eth.Log{TxHash: "0x2", Index: 2, Address: addr, Topics: []string{"0x" + strings.Repeat("d", 64)}, BlockNum: 1})
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", AuditUndecoded: true}, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts[UndecodedTable]) != 1 || !strings.Contains(inserts[UndecodedTable][0], `"count":1`) {
+		t.Fatalf("undecoded inserts = %v", inserts[UndecodedTable])
+	}
+
+	// Off by default.
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	inserts = captureInserts(t, ing)
+	if err := ing.Delta(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserts[UndecodedTable]) != 0 {
+		t.Fatalf("audit ran while off: %v", inserts[UndecodedTable])
+	}
+}
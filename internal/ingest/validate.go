@@ -0,0 +1,109 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidRow is returned, wrapped, when Options.StrictValidate rejects a
+// normalized row before it is written.
+var ErrInvalidRow = errors.New("ingest: invalid row")
+
+var hashPattern = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+
+// columnRule constrains one column: a lowercase address or hash, required
+// unless optional, in which case only a non-empty value is checked.
+type columnRule struct {
+	column   string
+	hash     bool
+	optional bool
+}
+
+// rowRules lists the checked columns per data table; dev tables share the
+// rules of their canonical counterpart. Transactions and traces may lack a
+// to_addr (contract creations), block reward traces carry no tx_hash, and
+// vault deposits have no receiver.
+var rowRules = map[string][]columnRule{
+	"logs":            {{column: "tx_hash", hash: true}, {column: "address"}},
+	"token_transfers": {{column: "tx_hash", hash: true}, {column: "token"}, {column: "from_addr"}, {column: "to_addr"}},
+	"approvals":       {{column: "tx_hash", hash: true}, {column: "token"}, {column: "owner"}, {column: "spender"}},
+	"proxy_upgrades":  {{column: "tx_hash", hash: true}, {column: "proxy"}, {column: "implementation"}},
+	"swaps":           {{column: "tx_hash", hash: true}, {column: "pool"}, {column: "sender"}, {column: "recipient"}},
+	"vault_events":    {{column: "tx_hash", hash: true}, {column: "vault"}, {column: "sender"}, {column: "owner"}, {column: "receiver", optional: true}},
+	"contracts":       {{column: "address"}, {column: "created_at_tx", hash: true, optional: true}, {column: "implementation", optional: true}},
+	"transactions":    {{column: "tx_hash", hash: true}, {column: "from_addr"}, {column: "to_addr", optional: true}, {column: "created_contract", optional: true}},
+	"sub_calls":       {{column: "tx_hash", hash: true}, {column: "from_addr"}, {column: "multicall"}, {column: "target"}},
+	"traces":          {{column: "tx_hash", hash: true, optional: true}, {column: "from_addr"}, {column: "to_addr", optional: true}},
+	"withdrawals":     {{column: "address"}},
+}
+
+// validatingSink checks rows against rowRules before handing them on.
+// names are Options.ColumnNames, under which canonical columns are written.
+type validatingSink struct {
+	Sink
+	names map[string]string
+}
+
+func (v validatingSink) InsertJSONEachRow(ctx context.Context, table string, rows []any) error {
+	if err := validateRows(table, rows, v.names); err != nil {
+		return err
+	}
+	return v.Sink.InsertJSONEachRow(ctx, table, rows)
+}
+
+// validateRows checks every row of table, returning an error wrapping
+// ErrInvalidRow that names the first offending row and column. Tables
+// without rules, such as the bookkeeping ones, pass unchecked.
+func validateRows(table string, rows []any, names map[string]string) error {
+	base, dev := strings.CutPrefix(table, "dev_")
+	rules, ok := rowRules[base]
+	if !ok {
+		return nil
+	}
+	if dev {
+		names = nil // renames apply to canonical tables only
+	}
+	for n, r := range rows {
+		row, err := rowMap(r)
+		if err != nil {
+			return fmt.Errorf("%w: %s row %d: %v", ErrInvalidRow, table, n, err)
+		}
+		for _, rule := range rules {
+			column := renamedColumn(names, table, rule.column)
+			s, _ := row[column].(string)
+			if s == "" && rule.optional {
+				continue
+			}
+			pattern, what := fullAddressPattern, "a lowercase 0x-prefixed 20-byte address"
+			if rule.hash {
+				pattern, what = hashPattern, "a lowercase 0x-prefixed 32-byte hash"
+			}
+			if !pattern.MatchString(s) {
+				hash, _ := row[renamedColumn(names, table, "tx_hash")].(string)
+				return fmt.Errorf("%w: %s row %d (tx_hash %q): %s %q is not %s", ErrInvalidRow, table, n, hash, column, s, what)
+			}
+		}
+	}
+	return nil
+}
+
+// rowMap returns a row's columns: canonical rows are maps already, dev rows
+// are structs whose JSON form is what gets written.
+func rowMap(r any) (map[string]any, error) {
+	if m, ok := r.(map[string]any); ok {
+		return m, nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/normalize"
+)
+
+// validFixture is tablesFixture with full-length transaction hashes.
+func validFixture(addr string) fixtureProv {
+	prov := tablesFixture(addr)
+	hash := "0x" + strings.Repeat("1", 64)
+	for n := range prov.logs {
+		prov.logs[n].TxHash = hash
+	}
+	prov.traces[0].TxHash = hash
+	prov.txs[0].Hash = hash
+	return prov
+}
+
+func TestValidateRows(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	hash := "0x" + strings.Repeat("1", 64)
+	transfer := func(from string) map[string]any {
+		return map[string]any{"tx_hash": hash, "token": addr, "from_addr": from, "to_addr": addr, "amount_raw": "1"}
+	}
+	cases := []struct {
+		name  string
+		table string
+		rows  []any
+		names map[string]string
+		bad   string // substring of the error, "" when the rows are valid
+	}{
+		{"valid", "token_transfers", []any{transfer(addr), transfer("0x" + strings.Repeat("0", 40))}, nil, ""},
+		{"malformed address", "token_transfers", []any{transfer(addr), transfer("0xABC")}, nil, `token_transfers row 1 (tx_hash "` + hash + `"): from_addr "0xABC"`},
+		{"checksummed address", "token_transfers", []any{transfer("0x" + strings.Repeat("A", 40))}, nil, "from_addr"},
+		{"empty hash", "logs", []any{map[string]any{"tx_hash": "", "address": addr}}, nil, `logs row 0 (tx_hash ""): tx_hash ""`},
+		{"short hash", "logs", []any{map[string]any{"tx_hash": "0x1", "address": addr}}, nil, "32-byte hash"},
+		{"missing column", "approvals", []any{map[string]any{"tx_hash": hash, "token": addr, "owner": addr}}, nil, `spender ""`},
+		{"contract creation", "transactions", []any{map[string]any{"tx_hash": hash, "from_addr": addr, "to_addr": ""}}, nil, ""},
+		{"optional but malformed", "transactions", []any{map[string]any{"tx_hash": hash, "from_addr": addr, "to_addr": "0x12"}}, nil, "to_addr"},
+		{"renamed column", "transactions", []any{map[string]any{"tx_hash": hash, "sender": "nope"}}, map[string]string{"from_addr": "sender"}, `sender "nope"`},
+		{"dev struct row", "dev_transactions", []any{normalize.TransactionRow{TxHash: hash, From: "bad"}}, map[string]string{"from_addr": "sender"}, `dev_transactions row 0`},
+		{"unchecked table", "run_locks", []any{map[string]any{"owner": "host-1"}}, nil, ""},
+	}
+	for _, tc := range cases {
+		err := validateRows(tc.table, tc.rows, tc.names)
+		if tc.bad == "" {
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidRow) || !strings.Contains(err.Error(), tc.bad) {
+			t.Fatalf("%s: err = %v, want ErrInvalidRow mentioning %q", tc.name, err, tc.bad)
+		}
+	}
+}
+
+func TestStrictValidate_ProcessRange(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	opts := Options{ClickHouseDSN: "http://localhost:8123/db", StrictValidate: true}
+
+	prov := validFixture(addr)
+	ing := NewWithProvider(addr, opts, &prov)
+	inserts := captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"logs", "token_transfers", "approvals", "transactions", "traces"} {
+		if len(inserts[table]) != 1 {
+			t.Fatalf("%s not written: %v", table, inserts)
+		}
+	}
+
+	// A decoder handing back a truncated hash must not reach ClickHouse. Rows
+	// are sorted by tx_hash before insert, so it is the batch's first.
+	prov = validFixture(addr)
+	prov.logs[1].TxHash = "0x1"
+	ing = NewWithProvider(addr, opts, &prov)
+	inserts = captureInserts(t, ing)
+	err := ing.processRange(context.Background(), 1, 1)
+	if !errors.Is(err, ErrInvalidRow) || !strings.Contains(err.Error(), "logs row 0") {
+		t.Fatalf("err = %v, want ErrInvalidRow for logs row 0", err)
+	}
+	if len(inserts["logs"]) != 0 {
+		t.Fatalf("invalid logs written: %v", inserts["logs"])
+	}
+
+	// Validation is opt-in.
+	prov = tablesFixture(addr)
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db"}, &prov)
+	captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// DefaultVerifyLogsDelay is the wait before re-querying suspiciously empty
+// logs when Options.VerifyLogsDelay is unset.
+const DefaultVerifyLogsDelay = 2 * time.Second
+
+// calledWithData reports whether any successful transaction called address
+// with calldata. Such calls usually emit logs from the address, so an empty
+// eth_getLogs result for the same range is suspect.
+func calledWithData(txs []eth.Transaction, address string) bool {
+	for _, tx := range txs {
+		if tx.Status == 1 && strings.EqualFold(tx.To, address) && len(strings.TrimPrefix(tx.InputHex, "0x")) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// refetchEmptyLogs waits and queries logs for [from, to] once more. A recovered
+// recheck logs a logs_recheck warning; an empty second answer is accepted,
+// since a call need not emit events.
+func (i *Ingester) refetchEmptyLogs(ctx context.Context, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	delay := i.opts.VerifyLogsDelay
+	if delay <= 0 {
+		delay = DefaultVerifyLogsDelay
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+	logs, err := i.prov.GetLogs(ctx, i.address, from, to, topics)
+	if err != nil {
+		return nil, &fetchError{fmt.Errorf("getting logs (recheck): %w", err)}
+	}
+	if logger := logging.Logger(); logger != nil {
+		// A recovered recheck means the provider served incomplete data.
+		log := logger.Debug
+		if len(logs) > 0 {
+			log = logger.Warn
+		}
+		log("logs_recheck",
+			"component", "ingest",
+			"address", i.address,
+			"from_block", from,
+			"to_block", to,
+			"logs", len(logs),
+			"recovered", len(logs) > 0,
+		)
+	}
+	return logs, nil
+}
@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// laggingLogsProv returns no logs for the first emptyFor GetLogs calls, then
+// its fixture logs, like a provider whose log index trails its blocks.
+type laggingLogsProv struct {
+	fixtureProv
+	emptyFor int
+	logCalls int
+}
+
+func (p *laggingLogsProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	p.logCalls++
+	if p.logCalls <= p.emptyFor {
+		return nil, nil
+	}
+	return p.fixtureProv.GetLogs(ctx, address, from, to, topics)
+}
+
+func verifyLogsFixture(addr string, input string) fixtureProv {
+	return fixtureProv{
+		logs: []eth.Log{{TxHash: "0x1", Address: addr, Topics: []string{"0xabc"}, DataHex: "0x", BlockNum: 5}},
+		txs:  []eth.Transaction{{Hash: "0x1", From: "0x" + strings.Repeat("b", 40), To: addr, InputHex: input, Status: 1, BlockNum: 5}},
+	}
+}
+
+func TestVerifyLogs_RetriesEmptyLogsOnce(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &laggingLogsProv{fixtureProv: verifyLogsFixture(addr, "0xa9059cbb"), emptyFor: 1}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"logs"}, VerifyLogs: true, VerifyLogsDelay: time.Millisecond}, prov)
+	inserts := captureInserts(t, ing)
+	logs := captureLogs(t)
+	if err := ing.processRange(context.Background(), 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if prov.logCalls != 2 || len(inserts["logs"]) != 1 || !strings.Contains(inserts["logs"][0], `"tx_hash":"0x1"`) {
+		t.Fatalf("logCalls=%d inserts=%v", prov.logCalls, inserts["logs"])
+	}
+	if !strings.Contains(logs.String(), `"msg":"logs_recheck"`) || !strings.Contains(logs.String(), `"recovered":true`) {
+		t.Fatalf("expected logs_recheck warning: %s", logs.String())
+	}
+
+	// Still empty after the retry: accepted, no further calls.
+	prov = &laggingLogsProv{fixtureProv: verifyLogsFixture(addr, "0xa9059cbb"), emptyFor: 5}
+	ing = NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", VerifyLogs: true, VerifyLogsDelay: time.Millisecond}, prov)
+	captureInserts(t, ing)
+	if err := ing.processRange(context.Background(), 1, 10); err != nil || prov.logCalls != 2 {
+		t.Fatalf("err=%v logCalls=%d", err, prov.logCalls)
+	}
+}
+
+func TestVerifyLogs_SkipsWithoutSuspiciousCall(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	cases := []struct {
+		name string
+		opts Options
+		fx   fixtureProv
+	}{
+		{"plain value transfer", Options{VerifyLogs: true}, verifyLogsFixture(addr, "0x")},
+		{"off by default", Options{}, verifyLogsFixture(addr, "0xa9059cbb")},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &laggingLogsProv{fixtureProv: tc.fx, emptyFor: 1}
+			tc.opts.ClickHouseDSN = "http://localhost:8123/db"
+			tc.opts.VerifyLogsDelay = time.Millisecond
+			ing := NewWithProvider(addr, tc.opts, prov)
+			captureInserts(t, ing)
+			if err := ing.processRange(context.Background(), 1, 10); err != nil || prov.logCalls != 1 {
+				t.Fatalf("err=%v logCalls=%d", err, prov.logCalls)
+			}
+		})
+	}
+	// A failed call emits no logs either.
+	fx := verifyLogsFixture(addr, "0xa9059cbb")
+	fx.txs[0].Status = 0
+	if calledWithData(fx.txs, addr) {
+		t.Fatal("failed call should not trigger a recheck")
+	}
+}
+
+func TestVerifyLogs_ForcesTransactionFetchAndHonorsCancel(t *testing.T) {
+	addr := "0x" + strings.Repeat("a", 40)
+	prov := &countingProv{fixtureProv: fixtureProv{txs: verifyLogsFixture(addr, "0x01").txs}}
+	ing := NewWithProvider(addr, Options{ClickHouseDSN: "http://localhost:8123/db", Tables: []string{"logs"}, VerifyLogs: true, VerifyLogsDelay: time.Hour}, prov)
+	captureInserts(t, ing)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ing.processRange(ctx, 1, 10); err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if prov.txCalls != 1 {
+		t.Fatalf("transactions not fetched for verification: %d", prov.txCalls)
+	}
+}
@@ -0,0 +1,226 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// WAL record operations.
+const (
+	walStart = "start"
+	walDone  = "done"
+)
+
+// walRecord is one line of the Options.WALPath file.
+type walRecord struct {
+	Op      string `json:"op"`
+	Address string `json:"address"`
+	From    uint64 `json:"from_block"`
+	To      uint64 `json:"to_block"`
+}
+
+// rangeWAL is the write-ahead log of Backfill ranges (Options.WALPath): a
+// start record is synced to disk before a range is fetched and a done record
+// once its rows are written, less any blocks the provider reported missing.
+// The file holds JSON lines of a single address: compact rewrites it whole,
+// so it cannot be shared with another writer.
+type rangeWAL struct {
+	path    string
+	address string
+	f       *os.File
+}
+
+// openWAL reads the records in path, creating the file when it does not
+// exist, and opens it for appending. A file holding another address's
+// records is refused. A torn last line, left by a crash mid-write, is
+// ignored.
+func openWAL(path, address string) (*rangeWAL, []walRecord, error) {
+	recs, err := readWAL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range recs {
+		if r.Address != address {
+			return nil, nil, fmt.Errorf("wal %s holds records of address %s; use one file per address", path, r.Address)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening wal: %w", err)
+	}
+	return &rangeWAL{path: path, address: address, f: f}, recs, nil
+}
+
+// readWAL returns every well-formed record in path.
+func readWAL(path string) ([]walRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening wal: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	var recs []walRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r walRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil || (r.Op != walStart && r.Op != walDone) {
+			continue
+		}
+		recs = append(recs, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading wal: %w", err)
+	}
+	return recs, nil
+}
+
+// append writes one record and syncs it to disk.
+func (w *rangeWAL) append(op string, r eth.BlockRange) error {
+	line, _ := json.Marshal(walRecord{Op: op, Address: w.address, From: r.From, To: r.To})
+	if _, err := w.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing wal: %w", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("syncing wal: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the file without the records at or below synced, which
+// the checkpoint now covers.
+func (w *rangeWAL) compact(synced uint64) error {
+	recs, err := readWAL(w.path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".*")
+	if err != nil {
+		return fmt.Errorf("compacting wal: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	bw := bufio.NewWriter(tmp)
+	for _, r := range recs {
+		if r.To <= synced {
+			continue
+		}
+		line, _ := json.Marshal(r)
+		_, _ = bw.Write(append(line, '\n'))
+	}
+	if err := bw.Flush(); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("compacting wal: %w", err)
+	}
+	_ = w.f.Close()
+	if err := os.Rename(tmp.Name(), w.path); err != nil {
+		return fmt.Errorf("compacting wal: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening wal: %w", err)
+	}
+	w.f = f
+	return nil
+}
+
+func (w *rangeWAL) close() { _ = w.f.Close() }
+
+// replayWAL opens Options.WALPath for a Backfill. Ranges the log records as
+// done join the coverage ledger, so the run skips them even when the
+// checkpoint was not persisted before a crash. Ranges started but not done
+// are logged as wal_incomplete_range and returned, less their done parts.
+func (i *Ingester) replayWAL(ckpt addressCheckpoint, existed bool) ([]eth.BlockRange, error) {
+	w, recs, err := openWAL(i.opts.WALPath, i.address)
+	if err != nil {
+		return nil, err
+	}
+	i.wal = w
+	var done, incomplete coverage
+	for _, r := range recs {
+		if r.Op == walDone && r.From <= r.To {
+			done.add(eth.BlockRange{From: r.From, To: r.To})
+		}
+	}
+	for _, r := range recs {
+		if r.Op != walStart || r.From > r.To {
+			continue
+		}
+		if end, ok := done.through(r.From); ok && end >= r.To {
+			continue
+		}
+		incomplete.cover(r.From, r.To, append([]eth.BlockRange(nil), done.covered...))
+	}
+	for _, r := range done.covered {
+		i.cov.add(r)
+	}
+	if existed {
+		i.cov.trim(ckpt.LastSyncedBlock)
+	}
+	if logger := logging.Logger(); logger != nil {
+		for _, r := range incomplete.covered {
+			logger.Warn("wal_incomplete_range",
+				"component", "ingest",
+				"address", i.address,
+				"from_block", r.From,
+				"to_block", r.To,
+			)
+		}
+	}
+	return incomplete.covered, nil
+}
+
+// retryIncomplete reprocesses the incomplete WAL ranges at or below the
+// checkpoint at synced, such as a staged batch published just before a crash
+// kept its done record from being written. Those above it are not covered,
+// so the Backfill walk retries them anyway.
+func (i *Ingester) retryIncomplete(ctx context.Context, incomplete []eth.BlockRange, synced uint64, rs rangeState) error {
+	for _, r := range incomplete {
+		if r.From > synced {
+			continue
+		}
+		to := min(r.To, synced)
+		for cur := r.From; cur <= to; {
+			end, err := i.processNext(ctx, cur, to, rs)
+			if err != nil {
+				return err
+			}
+			cur = end + 1
+		}
+	}
+	return nil
+}
+
+// walMark appends a record for [from, to] during a Backfill with a WAL. Done
+// records list only the covered parts of the range.
+func (i *Ingester) walMark(op string, from, to uint64, rs rangeState) error {
+	if i.wal == nil || rs.checkpoint != checkpointBackfill {
+		return nil
+	}
+	if op == walStart {
+		return i.wal.append(walStart, eth.BlockRange{From: from, To: to})
+	}
+	for _, cr := range i.cov.covered {
+		if cr.To < from || cr.From > to {
+			continue
+		}
+		if err := i.wal.append(walDone, eth.BlockRange{From: max(cr.From, from), To: min(cr.To, to)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// crashProv fails every fetch starting at block crashAt, like a process
+// dying mid-range.
+type crashProv struct {
+	*captureProv
+	crashAt uint64
+}
+
+func (p crashProv) GetLogs(ctx context.Context, address string, from, to uint64, topics [][]string) ([]eth.Log, error) {
+	if from == p.crashAt {
+		return nil, errors.New("killed")
+	}
+	return p.captureProv.GetLogs(ctx, address, from, to, topics)
+}
+
+func walLines(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(b))
+}
+
+func TestWAL_RetriesIncompleteRangeAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.wal")
+	opts := Options{BatchBlocks: 10, WALPath: path}
+
+	// First run: checkpoint at 50, the range from 61 dies mid-fetch.
+	ing, prov, rt := upToDateIngester(t, 80, opts)
+	ing.prov = crashProv{captureProv: prov, crashAt: 61}
+	if err := ing.Backfill(context.Background()); err == nil {
+		t.Fatal("expected the crash to fail the run")
+	}
+	if len(rt.inserts) != 0 {
+		t.Fatalf("checkpoint written despite the crash: %v", rt.inserts)
+	}
+	want := []string{
+		`{"op":"start","address":"0xabc","from_block":51,"to_block":60}`,
+		`{"op":"done","address":"0xabc","from_block":51,"to_block":60}`,
+		`{"op":"start","address":"0xabc","from_block":61,"to_block":70}`,
+	}
+	if got := walLines(t, path); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("wal =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Restart: the checkpoint still says 50, but 51..60 is known done, so
+	// only the incomplete range onwards is fetched.
+	ing, prov, rt = upToDateIngester(t, 80, opts)
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(prov.calls); n != 2 || prov.calls[0].from != 61 || prov.calls[1].to != 80 {
+		t.Fatalf("ranges = %+v, want 61..80", prov.calls)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 80 {
+		t.Fatalf("checkpoint = %+v, want 80", row)
+	}
+	// The checkpoint covers every record, so the log is compacted away.
+	if got := walLines(t, path); len(got) != 0 {
+		t.Fatalf("wal not compacted: %v", got)
+	}
+}
+
+func TestWAL_RetriesIncompleteRangeBelowCheckpoint(t *testing.T) {
+	// A batch was published (checkpoint 50) but the process died before its
+	// done record; a torn line follows.
+	path := filepath.Join(t.TempDir(), "backfill.wal")
+	content := `{"op":"start","address":"0xabc","from_block":41,"to_block":50}` + "\n" + `{"op":"do`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ing, prov, rt := upToDateIngester(t, 50, Options{BatchBlocks: 10, WALPath: path})
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(prov.calls); n != 1 || prov.calls[0].from != 41 || prov.calls[0].to != 50 {
+		t.Fatalf("ranges = %+v, want 41..50 retried", prov.calls)
+	}
+	if row := lastCheckpoint(t, rt); row.LastSyncedBlock != 50 {
+		t.Fatalf("checkpoint = %+v, want 50", row)
+	}
+	// A second restart finds the range done and fetches nothing.
+	ing, prov, _ = upToDateIngester(t, 50, Options{BatchBlocks: 10, WALPath: path})
+	if err := ing.Backfill(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(prov.calls) != 0 {
+		t.Fatalf("done range refetched: %+v", prov.calls)
+	}
+	got := walLines(t, path)
+	if len(got) == 0 || got[0] != `{"op":"start","address":"0xabc","from_block":41,"to_block":50}` {
+		t.Fatalf("wal = %v", got)
+	}
+}
+
+func TestWAL_RefusesAnotherAddressFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.wal")
+	content := `{"op":"start","address":"0xdef","from_block":1,"to_block":9}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ing, prov, _ := upToDateIngester(t, 50, Options{BatchBlocks: 10, WALPath: path})
+	if err := ing.Backfill(context.Background()); err == nil || !strings.Contains(err.Error(), "0xdef") {
+		t.Fatalf("err = %v, want refusal of the 0xdef file", err)
+	}
+	if len(prov.calls) != 0 {
+		t.Fatalf("fetched despite the refusal: %+v", prov.calls)
+	}
+	if got := walLines(t, path); len(got) != 1 {
+		t.Fatalf("wal rewritten: %v", got)
+	}
+}
+
+func TestWAL_PartialRangeRecordsCoveredParts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.wal")
+	ing := NewWithProvider("0xabc", Options{WALPath: path}, &captureProv{})
+	if _, err := ing.replayWAL(addressCheckpoint{}, false); err != nil {
+		t.Fatal(err)
+	}
+	defer ing.wal.close()
+	ing.cov.cover(1, 10, []eth.BlockRange{{From: 4, To: 5}})
+	if err := ing.walMark(walDone, 1, 10, rangeState{checkpoint: checkpointBackfill}); err != nil {
+		t.Fatal(err)
+	}
+	// Delta ranges are not logged.
+	if err := ing.walMark(walStart, 11, 20, rangeState{checkpoint: checkpointDelta}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"op":"done","address":"0xabc","from_block":1,"to_block":3}` + "\n" + `{"op":"done","address":"0xabc","from_block":6,"to_block":10}`
+	if got := strings.Join(walLines(t, path), "\n"); got != want {
+		t.Fatalf("wal =\n%s\nwant\n%s", got, want)
+	}
+	// On replay the gap is incomplete only if it was started.
+	again := NewWithProvider("0xabc", Options{WALPath: path}, &captureProv{})
+	incomplete, err := again.replayWAL(addressCheckpoint{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.wal.close()
+	if len(incomplete) != 0 {
+		t.Fatalf("incomplete = %v", incomplete)
+	}
+}
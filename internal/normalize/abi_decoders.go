@@ -7,12 +7,13 @@ import (
 	"strings"
 
 	fixtureabi "github.com/AIAleph/mvp_wallet_context/fixtures/abi"
-	"golang.org/x/crypto/sha3"
 )
 
 // Standard ERC token ABIs are embedded to derive selectors and event topics.
 type abiArgument struct {
-	Type string `json:"type"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Indexed bool   `json:"indexed"`
 }
 
 type abiItem struct {
@@ -29,6 +30,14 @@ var (
 	topicApprovalForAllFull string
 	topicERC1155SingleFull  string
 	topicERC1155BatchFull   string
+	// EIP-1967 Upgraded(address indexed implementation)
+	topicUpgradedFull string
+	// Uniswap V2 and V3 pool Swap events (see DecodeSwaps).
+	topicSwapV2Full string
+	topicSwapV3Full string
+	// ERC-4626 vault Deposit/Withdraw events (see DecodeVaultEvents).
+	topicVaultDepositFull  string
+	topicVaultWithdrawFull string
 )
 
 func init() {
@@ -51,6 +60,26 @@ func init() {
 	ensureTopicDefaults()
 }
 
+// TransferTopics returns the full topic0 hashes of the token transfer events
+// DecodeTokenEvents understands: ERC-20/721 Transfer and ERC-1155
+// TransferSingle/TransferBatch. Use it as an eth_getLogs topic filter.
+func TransferTopics() []string {
+	return []string{topicTransferFull, topicERC1155SingleFull, topicERC1155BatchFull}
+}
+
+// IsKnownEvent reports whether topic0 is an event some decoder in this
+// package understands: the token events DecodeTokenEvents reads, the
+// EIP-1967 Upgraded event DecodeProxyUpgrades reads, pool swaps and ERC-4626
+// vault deposits/withdrawals.
+func IsKnownEvent(topic0 string) bool {
+	for _, full := range []string{topicTransferFull, topicApprovalFull, topicApprovalForAllFull, topicERC1155SingleFull, topicERC1155BatchFull, topicUpgradedFull, topicSwapV2Full, topicSwapV3Full, topicVaultDepositFull, topicVaultWithdrawFull} {
+		if topicMatches(topic0, full) {
+			return true
+		}
+	}
+	return false
+}
+
 func loadStandardABI(label string, raw []byte) {
 	if len(raw) == 0 {
 		return
@@ -79,6 +108,7 @@ func loadStandardABI(label string, raw []byte) {
 			if topic == "" {
 				continue
 			}
+			registerEvent(topic, item.Inputs)
 			switch strings.ToLower(name) {
 			case "transfer":
 				topicTransferFull = topic
@@ -112,6 +142,21 @@ func ensureTopicDefaults() {
 	if topicERC1155BatchFull == "" {
 		topicERC1155BatchFull = mustEventTopic("TransferBatch", []string{"address", "address", "address", "uint256[]", "uint256[]"})
 	}
+	if topicUpgradedFull == "" {
+		topicUpgradedFull = mustEventTopic("Upgraded", []string{"address"})
+	}
+	if topicSwapV2Full == "" {
+		topicSwapV2Full = mustEventTopic("Swap", []string{"address", "uint256", "uint256", "uint256", "uint256", "address"})
+	}
+	if topicSwapV3Full == "" {
+		topicSwapV3Full = mustEventTopic("Swap", []string{"address", "address", "int256", "int256", "uint160", "uint128", "int24"})
+	}
+	if topicVaultDepositFull == "" {
+		topicVaultDepositFull = mustEventTopic("Deposit", []string{"address", "address", "uint256", "uint256"})
+	}
+	if topicVaultWithdrawFull == "" {
+		topicVaultWithdrawFull = mustEventTopic("Withdraw", []string{"address", "address", "address", "uint256", "uint256"})
+	}
 
 	// Fill in canonical selectors if ABI parsing failed to provide them.
 	for sel, name := range map[string]string{
@@ -177,9 +222,7 @@ func canonicalType(t string) string {
 }
 
 func keccakHex(sig string, size int) string {
-	hasher := sha3.NewLegacyKeccak256()
-	hasher.Write([]byte(sig))
-	sum := hasher.Sum(nil)
+	sum := keccak256([]byte(sig))
 	if size > len(sum) {
 		size = len(sum)
 	}
@@ -65,6 +65,46 @@ func TestDecodeTokenEvents_GoldenFixture(t *testing.T) {
 	}
 }
 
+type proxyUpgradesFixture struct {
+	Logs     []goldenLog       `json:"logs"`
+	Upgrades []ProxyUpgradeRow `json:"upgrades"`
+}
+
+func TestDecodeProxyUpgrades_GoldenFixture(t *testing.T) {
+	data, err := os.ReadFile(fixturePath("proxy_upgrades_golden.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var fx proxyUpgradesFixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		t.Fatalf("unmarshal fixture: %v", err)
+	}
+	upgrades := DecodeProxyUpgrades(toEthLogs(fx.Logs))
+	if !reflect.DeepEqual(upgrades, fx.Upgrades) {
+		got, want := mustJSON(upgrades), mustJSON(fx.Upgrades)
+		t.Fatalf("upgrades mismatch\nwant=%s\n got=%s", want, got)
+	}
+	if topicUpgradedFull != fx.Logs[0].Topics[0] {
+		t.Fatalf("Upgraded topic = %s", topicUpgradedFull)
+	}
+}
+
+func toEthLogs(in []goldenLog) []eth.Log {
+	logs := make([]eth.Log, len(in))
+	for i, l := range in {
+		logs[i] = eth.Log{
+			TxHash:   l.TxHash,
+			Index:    l.LogIndex,
+			Address:  l.Address,
+			Topics:   append([]string(nil), l.Topics...),
+			DataHex:  l.Data,
+			BlockNum: l.BlockNumber,
+			TsMillis: l.TsMillis,
+		}
+	}
+	return logs
+}
+
 type inputMethodFixture struct {
 	Cases []struct {
 		Input  string `json:"input"`
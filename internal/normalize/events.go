@@ -0,0 +1,90 @@
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// eventInputs maps the full topic0 of every registered event (the standard
+// token ABIs and those passed to RegisterEventABI) to its inputs, so the
+// decoders know which topics hold a hash rather than a value.
+var (
+	eventMu     sync.RWMutex
+	eventInputs = map[string][]abiArgument{}
+)
+
+// RegisterEventABI registers the events of a JSON ABI (an array of ABI
+// items; non-event items are ignored). Decoders then treat the topics of
+// their indexed dynamic parameters (string, bytes, arrays and tuples), which
+// hold the keccak256 of the value, as opaque hashes (see HashedTopics).
+func RegisterEventABI(raw []byte) error {
+	var items []abiItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return fmt.Errorf("parsing event ABI: %w", err)
+	}
+	for _, item := range items {
+		if item.Type != "event" {
+			continue
+		}
+		topic := eventTopic(item.Name, item.Inputs)
+		if topic == "" {
+			return fmt.Errorf("event %q has no valid signature", item.Name)
+		}
+		registerEvent(topic, item.Inputs)
+	}
+	return nil
+}
+
+func registerEvent(topic string, inputs []abiArgument) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventInputs[strings.ToLower(topic)] = append([]abiArgument(nil), inputs...)
+}
+
+// hashedIndexedType reports whether an indexed parameter of type t is
+// stored as the keccak256 of its encoding rather than as its value.
+func hashedIndexedType(t string) bool {
+	t = canonicalType(t)
+	return t == "string" || t == "bytes" || strings.HasSuffix(t, "]") || strings.HasPrefix(t, "tuple") || strings.HasPrefix(t, "(")
+}
+
+// HashedTopics returns, keyed by topic position, the topics of a log that
+// hold hashes of indexed dynamic parameters according to the registered ABI
+// of its topic0. The values cannot be recovered from these topics, so they
+// are kept as opaque 0x hashes. It returns nil for unregistered events and
+// events without such parameters.
+func HashedTopics(topics []string) map[uint8]string {
+	if len(topics) < 2 {
+		return nil
+	}
+	eventMu.RLock()
+	inputs := eventInputs[strings.ToLower(topics[0])]
+	eventMu.RUnlock()
+	var out map[uint8]string
+	pos := 1
+	for _, in := range inputs {
+		if !in.Indexed {
+			continue
+		}
+		if pos >= len(topics) {
+			break
+		}
+		if hashedIndexedType(in.Type) {
+			if out == nil {
+				out = make(map[uint8]string)
+			}
+			out[uint8(pos)] = strings.ToLower(topics[pos])
+		}
+		pos++
+	}
+	return out
+}
+
+// hashedTopic reports whether topics[idx] holds the hash of an indexed
+// dynamic parameter, which must not be decoded as an address or amount.
+func hashedTopic(topics []string, idx int) bool {
+	_, ok := HashedTopics(topics)[uint8(idx)]
+	return ok
+}
@@ -0,0 +1,76 @@
+package normalize
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+const nameRegisteredABI = `[
+  {"type": "event", "name": "NameRegistered", "inputs": [
+    {"name": "name", "type": "string", "indexed": true},
+    {"name": "owner", "type": "address", "indexed": true},
+    {"name": "expires", "type": "uint256", "indexed": false}
+  ]},
+  {"type": "function", "name": "register", "inputs": [{"name": "name", "type": "string"}]}
+]`
+
+func TestHashedTopics_IndexedStringIsOpaque(t *testing.T) {
+	if err := RegisterEventABI([]byte(nameRegisteredABI)); err != nil {
+		t.Fatal(err)
+	}
+	topic0 := mustEventTopic("NameRegistered", []string{"string", "address", "uint256"})
+	nameHash := "0x" + hex.EncodeToString(keccak256([]byte("vitalik")))
+	owner := "0x" + strings.Repeat("ab", 20)
+	topics := []string{strings.ToUpper(topic0[:2]) + topic0[2:], nameHash, "0x" + strings.Repeat("0", 24) + owner[2:]}
+
+	if got, want := HashedTopics(topics), map[uint8]string{1: nameHash}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("HashedTopics = %v, want %v", got, want)
+	}
+	if got := addrFromTopic(topics, 1); got != "" {
+		t.Fatalf("hashed name topic decoded as address %q", got)
+	}
+	if got := addrFromTopic(topics, 2); got != owner {
+		t.Fatalf("owner = %q, want %q", got, owner)
+	}
+	rows := LogsToRows([]eth.Log{{TxHash: "0x1", Address: owner, Topics: topics}})
+	if rows[0].HashedTopics[1] != nameHash || len(rows[0].Topics) != 3 {
+		t.Fatalf("log row = %+v", rows[0])
+	}
+}
+
+func TestHashedTopics_UnregisteredAndStandardEvents(t *testing.T) {
+	word := "0x" + strings.Repeat("1", 64)
+	if got := HashedTopics([]string{"0x" + strings.Repeat("9", 64), word}); got != nil {
+		t.Fatalf("unregistered event: %v", got)
+	}
+	if got := HashedTopics([]string{topicTransferFull, word, word, word}); got != nil {
+		t.Fatalf("Transfer has no hashed topics: %v", got)
+	}
+	if rows := LogsToRows([]eth.Log{{Topics: []string{topicTransferFull, word}}}); rows[0].HashedTopics != nil {
+		t.Fatalf("hashed topics on a Transfer log: %v", rows[0].HashedTopics)
+	}
+}
+
+func TestRegisterEventABI_Errors(t *testing.T) {
+	if err := RegisterEventABI([]byte("{")); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if err := RegisterEventABI([]byte(`[{"type":"event","name":"","inputs":[]}]`)); err == nil {
+		t.Fatal("expected error for an unnamed event")
+	}
+}
+
+func TestHashedIndexedType(t *testing.T) {
+	for typ, want := range map[string]bool{
+		"string": true, "bytes": true, "uint256[]": true, "address[2]": true, "tuple": true,
+		"bytes32": false, "address": false, "uint256": false, "bool": false,
+	} {
+		if got := hashedIndexedType(typ); got != want {
+			t.Fatalf("hashedIndexedType(%q) = %v, want %v", typ, got, want)
+		}
+	}
+}
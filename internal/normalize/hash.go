@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Hasher computes the legacy (pre-FIPS) Keccak-256 digest Ethereum uses for
+// function selectors, event topics and init code hashes.
+type Hasher interface {
+	Keccak256(data []byte) []byte
+}
+
+// legacyKeccak is the default Hasher, backed by golang.org/x/crypto/sha3.
+type legacyKeccak struct{}
+
+func (legacyKeccak) Keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+var (
+	hasherMu sync.RWMutex
+	hasher   Hasher = legacyKeccak{}
+)
+
+// SetHasher swaps the Keccak-256 backend (e.g. for an accelerated or audited
+// implementation) and returns the previous one; nil restores the default.
+// The standard ABI selectors and topics are derived once at package init, so
+// only hashes computed afterwards use the new backend.
+func SetHasher(h Hasher) Hasher {
+	if h == nil {
+		h = legacyKeccak{}
+	}
+	hasherMu.Lock()
+	defer hasherMu.Unlock()
+	prev := hasher
+	hasher = h
+	return prev
+}
+
+func keccak256(data []byte) []byte {
+	hasherMu.RLock()
+	h := hasher
+	hasherMu.RUnlock()
+	return h.Keccak256(data)
+}
@@ -0,0 +1,51 @@
+package normalize
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// stubHasher returns a fixed digest so swapped-in backends are observable.
+type stubHasher struct{ calls int }
+
+func (s *stubHasher) Keccak256(data []byte) []byte {
+	s.calls++
+	return bytes.Repeat([]byte{0xab}, 32)
+}
+
+func TestSetHasher_RoutesSelectorsAndRestoresDefault(t *testing.T) {
+	args := []abiArgument{{Type: "address"}, {Type: "uint256"}}
+	if got := functionSelector("transfer", args); got != "0xa9059cbb" {
+		t.Fatalf("default selector = %s", got)
+	}
+
+	stub := &stubHasher{}
+	prev := SetHasher(stub)
+	t.Cleanup(func() { SetHasher(nil) })
+	if got := functionSelector("transfer", args); got != "0xabababab" {
+		t.Fatalf("stub selector = %s", got)
+	}
+	if got := eventTopic("Transfer", []abiArgument{{Type: "address"}, {Type: "address"}, {Type: "uint256"}}); got != "0x"+strings.Repeat("ab", 32) {
+		t.Fatalf("stub topic = %s", got)
+	}
+	if got := initCodeHash("0x6080"); got != "0x"+strings.Repeat("ab", 32) {
+		t.Fatalf("stub init code hash = %s", got)
+	}
+	if stub.calls != 3 {
+		t.Fatalf("stub calls = %d, want 3", stub.calls)
+	}
+
+	if got := SetHasher(nil); got != stub {
+		t.Fatalf("SetHasher returned %T, want the stub", got)
+	}
+	if _, ok := prev.(legacyKeccak); !ok {
+		t.Fatalf("previous hasher = %T, want the default", prev)
+	}
+	if got := functionSelector("transfer", args); got != "0xa9059cbb" {
+		t.Fatalf("restored selector = %s", got)
+	}
+	if got := eventTopic("Transfer", []abiArgument{{Type: "address"}, {Type: "address"}, {Type: "uint256"}}); got != topicTransferFull {
+		t.Fatalf("restored topic = %s, want %s", got, topicTransferFull)
+	}
+}
@@ -0,0 +1,203 @@
+package normalize
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// SubCallRow is one inner call of a Multicall3 batch, as encoded in the
+// calldata of an external transaction. Nested batches yield a row for the
+// inner multicall itself followed by rows for its own calls.
+type SubCallRow struct {
+	TxHash string `json:"tx_hash"`
+	// CallIndex is the dotted position of the call: "2", or "2.0" for the
+	// first call of a batch nested at position 2.
+	CallIndex string `json:"call_index"`
+	Depth     uint8  `json:"depth"`
+	From      string `json:"from_addr"` // transaction sender
+	Multicall string `json:"multicall"` // contract dispatching the call
+	Target    string `json:"target"`
+	// InputMethod labels the inner selector like DecodeInputMethod.
+	InputMethod  string `json:"input_method"`
+	AllowFailure uint8  `json:"allow_failure"`
+	ValueRaw     string `json:"value_raw"` // aggregate3Value only; "0" otherwise
+	BlockNum     uint64 `json:"block_number"`
+	TsMillis     int64  `json:"ts_millis"`
+}
+
+// maxMulticallDepth bounds recursion into multicalls nested in sub-calls.
+const maxMulticallDepth = 4
+
+// multicallLayout locates the Call[] argument of a Multicall3 entry point and
+// the words of each Call tuple. Negative word indexes are absent.
+type multicallLayout struct {
+	name     string
+	sig      string
+	requireW int // argument word holding requireSuccess (try* variants)
+	arrayW   int // argument word holding the Call[] offset
+	failW    int // tuple word holding allowFailure
+	valueW   int // tuple word holding value
+	dataW    int // tuple word holding the callData offset
+}
+
+var multicallLayouts = []multicallLayout{
+	{name: "aggregate", sig: "aggregate((address,bytes)[])", requireW: -1, arrayW: 0, failW: -1, valueW: -1, dataW: 1},
+	{name: "tryAggregate", sig: "tryAggregate(bool,(address,bytes)[])", requireW: 0, arrayW: 1, failW: -1, valueW: -1, dataW: 1},
+	{name: "blockAndAggregate", sig: "blockAndAggregate((address,bytes)[])", requireW: -1, arrayW: 0, failW: -1, valueW: -1, dataW: 1},
+	{name: "tryBlockAndAggregate", sig: "tryBlockAndAggregate(bool,(address,bytes)[])", requireW: 0, arrayW: 1, failW: -1, valueW: -1, dataW: 1},
+	{name: "aggregate3", sig: "aggregate3((address,bool,bytes)[])", requireW: -1, arrayW: 0, failW: 1, valueW: -1, dataW: 2},
+	{name: "aggregate3Value", sig: "aggregate3Value((address,bool,uint256,bytes)[])", requireW: -1, arrayW: 0, failW: 1, valueW: 2, dataW: 3},
+}
+
+// multicallSelectors maps 0x-prefixed selectors to their layouts.
+var multicallSelectors = map[string]multicallLayout{}
+
+func init() {
+	for _, l := range multicallLayouts {
+		sel := keccakHex(l.sig, 4)
+		multicallSelectors[sel] = l
+		if _, exists := selectorNames[sel]; !exists {
+			selectorNames[sel] = l.name
+		}
+	}
+}
+
+// innerCall is one decoded Call tuple.
+type innerCall struct {
+	target       string
+	allowFailure bool
+	value        string
+	data         []byte
+}
+
+// DecodeSubCalls extracts the inner calls of transactions whose input is a
+// Multicall3 batch. Inner selectors are labelled via DecodeInputMethod, so
+// unknown ones keep their 4-byte hex. Inputs that are not multicalls, or
+// whose encoding is malformed, yield no rows.
+func DecodeSubCalls(txs []eth.Transaction) []SubCallRow {
+	var out []SubCallRow
+	for _, tx := range txs {
+		data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(tx.InputHex)), "0x"))
+		if err != nil || tx.To == "" {
+			continue
+		}
+		base := SubCallRow{
+			TxHash:   strings.ToLower(tx.Hash),
+			From:     strings.ToLower(tx.From),
+			BlockNum: tx.BlockNum,
+			TsMillis: tx.TsMillis,
+		}
+		out = appendSubCalls(out, base, strings.ToLower(tx.To), data, "", 0)
+	}
+	return out
+}
+
+func appendSubCalls(out []SubCallRow, base SubCallRow, multicall string, data []byte, prefix string, depth int) []SubCallRow {
+	calls, ok := decodeMulticall(data)
+	if !ok {
+		return out
+	}
+	for k, c := range calls {
+		row := base
+		row.CallIndex = prefix + strconv.Itoa(k)
+		row.Depth = uint8(depth)
+		row.Multicall = multicall
+		row.Target = c.target
+		row.InputMethod = DecodeInputMethod("0x" + hex.EncodeToString(c.data))
+		row.ValueRaw = c.value
+		if c.allowFailure {
+			row.AllowFailure = 1
+		}
+		out = append(out, row)
+		if depth+1 < maxMulticallDepth {
+			out = appendSubCalls(out, base, c.target, c.data, row.CallIndex+".", depth+1)
+		}
+	}
+	return out
+}
+
+// decodeMulticall decodes calldata for one of multicallLayouts. It reports
+// false for other selectors and for encodings that run out of bounds.
+func decodeMulticall(data []byte) ([]innerCall, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	layout, ok := multicallSelectors["0x"+hex.EncodeToString(data[:4])]
+	if !ok {
+		return nil, false
+	}
+	args := data[4:]
+	allowFailure := false
+	if layout.requireW >= 0 {
+		req, ok := abiUint(args, 32*layout.requireW)
+		if !ok {
+			return nil, false
+		}
+		allowFailure = req == 0
+	}
+	arrOff, ok := abiUint(args, 32*layout.arrayW)
+	if !ok {
+		return nil, false
+	}
+	n, ok := abiUint(args, arrOff)
+	if !ok {
+		return nil, false
+	}
+	elems := args[arrOff+32:]
+	if n > len(elems)/32 {
+		return nil, false
+	}
+	calls := make([]innerCall, 0, n)
+	for k := 0; k < n; k++ {
+		tOff, ok := abiUint(elems, 32*k)
+		if !ok {
+			return nil, false
+		}
+		tuple := elems[tOff:]
+		if len(tuple) < 32 {
+			return nil, false
+		}
+		c := innerCall{target: "0x" + hex.EncodeToString(tuple[12:32]), allowFailure: allowFailure, value: "0"}
+		if layout.failW >= 0 {
+			f, ok := abiUint(tuple, 32*layout.failW)
+			if !ok {
+				return nil, false
+			}
+			c.allowFailure = f != 0
+		}
+		if layout.valueW >= 0 {
+			if len(tuple) < 32*(layout.valueW+1) {
+				return nil, false
+			}
+			c.value = new(big.Int).SetBytes(tuple[32*layout.valueW : 32*(layout.valueW+1)]).String()
+		}
+		dOff, ok := abiUint(tuple, 32*layout.dataW)
+		if !ok {
+			return nil, false
+		}
+		dLen, ok := abiUint(tuple, dOff)
+		if !ok || dLen > len(tuple)-dOff-32 {
+			return nil, false
+		}
+		c.data = tuple[dOff+32 : dOff+32+dLen]
+		calls = append(calls, c)
+	}
+	return calls, true
+}
+
+// abiUint reads the word at byte offset off as an offset or length, which
+// must fit within b.
+func abiUint(b []byte, off int) (int, bool) {
+	if off < 0 || off+32 > len(b) {
+		return 0, false
+	}
+	v := new(big.Int).SetBytes(b[off : off+32])
+	if !v.IsInt64() || v.Int64() > int64(len(b)) {
+		return 0, false
+	}
+	return int(v.Int64()), true
+}
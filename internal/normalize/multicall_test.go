@@ -0,0 +1,118 @@
+package normalize
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+type subCallsFixture struct {
+	Transactions []struct {
+		Hash        string `json:"hash"`
+		From        string `json:"from"`
+		To          string `json:"to"`
+		Input       string `json:"input"`
+		BlockNumber uint64 `json:"block_number"`
+		TsMillis    int64  `json:"ts_millis"`
+	} `json:"transactions"`
+	SubCalls []SubCallRow `json:"sub_calls"`
+}
+
+// The fixture holds Multicall3 aggregate3 (with a nested tryAggregate and an
+// unknown selector), aggregate3Value and aggregate batches against mainnet
+// token contracts, ABI-encoded independently of this decoder, plus a plain
+// transfer that yields no sub-calls.
+func TestDecodeSubCalls_GoldenFixture(t *testing.T) {
+	data, err := os.ReadFile(fixturePath("sub_calls_golden.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var fx subCallsFixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		t.Fatalf("unmarshal fixture: %v", err)
+	}
+	txs := make([]eth.Transaction, len(fx.Transactions))
+	for i, tx := range fx.Transactions {
+		txs[i] = eth.Transaction{Hash: tx.Hash, From: tx.From, To: tx.To, InputHex: tx.Input, BlockNum: tx.BlockNumber, TsMillis: tx.TsMillis}
+	}
+	got := DecodeSubCalls(txs)
+	if !reflect.DeepEqual(got, fx.SubCalls) {
+		t.Fatalf("sub_calls mismatch\nwant=%s\n got=%s", mustJSON(fx.SubCalls), mustJSON(got))
+	}
+}
+
+func TestDecodeInputMethod_MulticallSelectors(t *testing.T) {
+	for sel, want := range map[string]string{
+		"0x252dba42": "aggregate",
+		"0xbce38bd7": "tryAggregate",
+		"0xc3077fa9": "blockAndAggregate",
+		"0x399542e9": "tryBlockAndAggregate",
+		"0x82ad56cb": "aggregate3",
+		"0x174dea71": "aggregate3Value",
+	} {
+		if got := DecodeInputMethod(sel + strings.Repeat("0", 64)); got != want {
+			t.Fatalf("%s = %q, want %q", sel, got, want)
+		}
+	}
+}
+
+func TestDecodeSubCalls_Malformed(t *testing.T) {
+	fx, err := os.ReadFile(fixturePath("sub_calls_golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed subCallsFixture
+	if err := json.Unmarshal(fx, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	valid := parsed.Transactions[0].Input
+	for name, input := range map[string]string{
+		"selector only": "0x82ad56cb",
+		"truncated":     valid[:len(valid)-64],
+		"huge length":   "0x82ad56cb" + word(32) + strings.Repeat("f", 64),
+		"bad offset":    "0x82ad56cb" + strings.Repeat("f", 64),
+		"not hex":       "0x82ad56cbzz",
+	} {
+		tx := eth.Transaction{Hash: "0x1", From: "0xa", To: "0xb", InputHex: input}
+		if rows := DecodeSubCalls([]eth.Transaction{tx}); len(rows) != 0 {
+			t.Fatalf("%s: expected no rows, got %+v", name, rows)
+		}
+	}
+	// Contract creations carry init code, never a batch.
+	if rows := DecodeSubCalls([]eth.Transaction{{Hash: "0x1", InputHex: valid}}); len(rows) != 0 {
+		t.Fatalf("creation decoded: %+v", rows)
+	}
+}
+
+func TestDecodeSubCalls_DepthLimit(t *testing.T) {
+	// Each level wraps the previous one in aggregate((target, inner)[]).
+	inner := "0xa9059cbb" + word(1) + word(2)
+	for d := 0; d < maxMulticallDepth+2; d++ {
+		body := strings.TrimPrefix(inner, "0x")
+		n := len(body) / 2
+		padded := body + strings.Repeat("0", (64-len(body)%64)%64)
+		tuple := word(0xca11) + word(64) + word(n) + padded
+		inner = "0x252dba42" + word(32) + word(1) + word(32) + tuple
+	}
+	rows := DecodeSubCalls([]eth.Transaction{{Hash: "0x1", To: "0xca11", InputHex: inner}})
+	if len(rows) != maxMulticallDepth {
+		t.Fatalf("rows = %d, want %d", len(rows), maxMulticallDepth)
+	}
+	if last := rows[len(rows)-1]; last.Depth != maxMulticallDepth-1 || last.CallIndex != "0.0.0.0" {
+		t.Fatalf("deepest row = %+v", last)
+	}
+}
+
+func word(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, 64)
+	for i := 63; i >= 0; i-- {
+		b[i] = digits[n&0xf]
+		n >>= 4
+	}
+	return string(b)
+}
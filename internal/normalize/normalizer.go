@@ -1,8 +1,10 @@
 package normalize
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/AIAleph/mvp_wallet_context/internal/eth"
@@ -18,9 +20,12 @@ type LogRow struct {
 	LogIndex uint32   `json:"log_index"`
 	Address  string   `json:"address"`
 	Topics   []string `json:"topics"`
-	DataHex  string   `json:"data_hex"`
-	BlockNum uint64   `json:"block_number"`
-	TsMillis int64    `json:"ts_millis"`
+	// HashedTopics holds, by position, the topics that are hashes of indexed
+	// string, bytes, array or tuple parameters (see HashedTopics).
+	HashedTopics map[uint8]string `json:"hashed_topics,omitempty"`
+	DataHex      string           `json:"data_hex"`
+	BlockNum     uint64           `json:"block_number"`
+	TsMillis     int64            `json:"ts_millis"`
 }
 
 // TraceRow represents a normalized internal trace row for dev ingestion.
@@ -33,36 +38,72 @@ type TraceRow struct {
 	ValueRaw string `json:"value_raw"`
 	BlockNum uint64 `json:"block_number"`
 	TsMillis int64  `json:"ts_millis"`
+	// IsPrecompile is 1 when To is a precompiled contract (see IsPrecompile).
+	IsPrecompile uint8 `json:"is_precompile"`
 }
 
 // TransactionRow represents a normalized transaction row (external or internal).
 type TransactionRow struct {
-	TxHash      string `json:"tx_hash"`
-	BlockNum    uint64 `json:"block_number"`
-	TsMillis    int64  `json:"ts_millis"`
-	From        string `json:"from_addr"`
-	To          string `json:"to_addr"`
-	ValueRaw    string `json:"value_raw"`
-	GasUsed     uint64 `json:"gas_used"`
-	Status      uint8  `json:"status"`
-	InputMethod string `json:"input_method"`
-	IsInternal  uint8  `json:"is_internal"`
-	TraceID     string `json:"trace_id"`
+	TxHash       string `json:"tx_hash"`
+	BlockNum     uint64 `json:"block_number"`
+	TsMillis     int64  `json:"ts_millis"`
+	From         string `json:"from_addr"`
+	To           string `json:"to_addr"`
+	ValueRaw     string `json:"value_raw"`
+	GasUsed      uint64 `json:"gas_used"`
+	GasPriceRaw  string `json:"gas_price_raw"` // decimal wei per gas; "" when unknown
+	Status       uint8  `json:"status"`
+	InputMethod  string `json:"input_method"`
+	InitCodeHash string `json:"init_code_hash"` // keccak256 of creation init code
+	// CreatedContract is the address an external contract-creation
+	// transaction deployed, from its receipt's contractAddress; "" otherwise.
+	CreatedContract string `json:"created_contract"`
+	IsInternal      uint8  `json:"is_internal"`
+	TraceID         string `json:"trace_id"`
+	// EIP-2930 access list size; 0 for legacy and internal transactions.
+	AccessListCount uint32 `json:"access_list_count"`
+	AccessListKeys  uint32 `json:"access_list_storage_keys"`
+	// ReceiptMissing is 1 when the receipt was unavailable, leaving GasUsed
+	// and Status zero (eth.Transaction.ReceiptMissing).
+	ReceiptMissing uint8 `json:"receipt_missing"`
+	// InputKind classifies the input more finely than InputMethod (see
+	// InputKind constants): a plain transfer, a creation, or a call whose
+	// selector is known or not.
+	InputKind string `json:"input_kind"`
+	// IsPrecompile is 1 when To is a precompiled contract (see IsPrecompile).
+	IsPrecompile uint8 `json:"is_precompile"`
 }
 
+// CreateInputMethod is the InputMethod of external contract-creation
+// transactions (empty to), whose input is init code rather than calldata.
+// Internal rows keep the selector logic: traces without a recipient are not
+// necessarily creations.
+const CreateInputMethod = "create"
+
+// InputKind values. Calls are "known:<method>" when the selector is one
+// DecodeInputMethod names, else "unknown:<selector>"; input too short for a
+// selector is "unknown:" followed by the whole input.
+const (
+	InputKindEmpty   = "empty"  // no calldata: a plain ETH transfer
+	InputKindCreate  = "create" // external contract creation
+	InputKindKnown   = "known:"
+	InputKindUnknown = "unknown:"
+)
+
 // LogsToRows maps eth.Log to normalized LogRow with stable event_uid.
 func LogsToRows(in []eth.Log) []LogRow {
 	out := make([]LogRow, 0, len(in))
 	for _, l := range in {
 		out = append(out, LogRow{
-			EventUID: fmt.Sprintf("%s:%d", l.TxHash, l.Index),
-			TxHash:   l.TxHash,
-			LogIndex: l.Index,
-			Address:  l.Address,
-			Topics:   append([]string(nil), l.Topics...),
-			DataHex:  l.DataHex,
-			BlockNum: l.BlockNum,
-			TsMillis: l.TsMillis,
+			EventUID:     fmt.Sprintf("%s:%d", l.TxHash, l.Index),
+			TxHash:       l.TxHash,
+			LogIndex:     l.Index,
+			Address:      l.Address,
+			Topics:       append([]string(nil), l.Topics...),
+			HashedTopics: HashedTopics(l.Topics),
+			DataHex:      l.DataHex,
+			BlockNum:     l.BlockNum,
+			TsMillis:     l.TsMillis,
 		})
 	}
 	return out
@@ -73,22 +114,40 @@ func TracesToRows(in []eth.Trace) []TraceRow {
 	out := make([]TraceRow, 0, len(in))
 	for _, t := range in {
 		out = append(out, TraceRow{
-			TraceUID: fmt.Sprintf("%s:%s", t.TxHash, t.TraceID),
-			TxHash:   t.TxHash,
-			TraceID:  t.TraceID,
-			From:     t.From,
-			To:       t.To,
-			ValueRaw: t.ValueWei,
-			BlockNum: t.BlockNum,
-			TsMillis: t.TsMillis,
+			TraceUID:     fmt.Sprintf("%s:%s", t.TxHash, t.TraceID),
+			TxHash:       t.TxHash,
+			TraceID:      t.TraceID,
+			From:         t.From,
+			To:           t.To,
+			ValueRaw:     t.ValueWei,
+			BlockNum:     t.BlockNum,
+			TsMillis:     t.TsMillis,
+			IsPrecompile: precompileFlag(t.To),
 		})
 	}
 	return out
 }
 
-// DecodeInputMethod maps calldata selectors to short method labels. Unknown
-// selectors return the 4-byte hex prefix, empty/short inputs return "".
-func DecodeInputMethod(input string) string {
+// IsPrecompile reports whether addr is one of the precompiled contracts at
+// 0x01-0x09 (ecrecover through blake2f). Calls to them are valid but are
+// not counterparties.
+func IsPrecompile(addr string) bool {
+	h := strings.TrimPrefix(strings.ToLower(addr), "0x")
+	if len(h) != 40 || len(h) == len(addr) {
+		return false
+	}
+	return strings.TrimLeft(h[:39], "0") == "" && h[39] >= '1' && h[39] <= '9'
+}
+
+func precompileFlag(addr string) uint8 {
+	if IsPrecompile(addr) {
+		return 1
+	}
+	return 0
+}
+
+// decodeInputMethod is DecodeInputMethod without the selector cache.
+func decodeInputMethod(input string) string {
 	input = strings.ToLower(strings.TrimSpace(input))
 	if len(input) <= len("0x") || !strings.HasPrefix(input, "0x") {
 		return ""
@@ -124,33 +183,89 @@ func valueToDecimalString(s string) string {
 // TransactionsToRows normalizes provider transactions to canonical rows.
 func TransactionsToRows(in []eth.Transaction, isInternal bool) []TransactionRow {
 	out := make([]TransactionRow, 0, len(in))
+	for _, tx := range in {
+		out = append(out, transactionToRow(tx, isInternal))
+	}
+	return out
+}
+
+// NormalizeTransaction converts one provider transaction to a canonical row
+// and reports whether it touches target (from or to, case-insensitive). An
+// empty target matches every transaction.
+func NormalizeTransaction(tx eth.Transaction, target string, internal bool) (TransactionRow, bool) {
+	row := transactionToRow(tx, internal)
+	if target == "" {
+		return row, true
+	}
+	addr := strings.ToLower(target)
+	return row, row.From == addr || row.To == addr
+}
+
+func transactionToRow(tx eth.Transaction, isInternal bool) TransactionRow {
 	internalFlag := uint8(0)
 	if isInternal {
 		internalFlag = 1
 	}
-	for _, tx := range in {
-		row := TransactionRow{
-			TxHash:      strings.ToLower(tx.Hash),
-			BlockNum:    tx.BlockNum,
-			TsMillis:    tx.TsMillis,
-			From:        strings.ToLower(tx.From),
-			To:          strings.ToLower(tx.To),
-			ValueRaw:    valueToDecimalString(tx.ValueWei),
-			GasUsed:     tx.GasUsed,
-			Status:      tx.Status,
-			InputMethod: "",
-			IsInternal:  internalFlag,
-			TraceID:     tx.TraceID,
-		}
-		if tx.To == "" {
-			row.To = ""
-		}
-		if m := DecodeInputMethod(tx.InputHex); m != "" {
-			row.InputMethod = m
-		}
-		out = append(out, row)
+	row := TransactionRow{
+		TxHash:          strings.ToLower(tx.Hash),
+		BlockNum:        tx.BlockNum,
+		TsMillis:        tx.TsMillis,
+		From:            strings.ToLower(tx.From),
+		To:              strings.ToLower(tx.To),
+		ValueRaw:        valueToDecimalString(tx.ValueWei),
+		GasUsed:         tx.GasUsed,
+		Status:          tx.Status,
+		InputMethod:     "",
+		IsInternal:      internalFlag,
+		TraceID:         tx.TraceID,
+		AccessListCount: tx.AccessListCount,
+		AccessListKeys:  tx.AccessListKeys,
+		IsPrecompile:    precompileFlag(tx.To),
 	}
-	return out
+	if tx.GasPriceWei != "" {
+		row.GasPriceRaw = valueToDecimalString(tx.GasPriceWei)
+	}
+	if tx.ReceiptMissing {
+		row.ReceiptMissing = 1
+	}
+	if tx.To == "" && !isInternal {
+		row.InputMethod = CreateInputMethod
+		row.InputKind = InputKindCreate
+		row.InitCodeHash = initCodeHash(tx.InputHex)
+		row.CreatedContract = strings.ToLower(tx.ContractAddress)
+		return row
+	}
+	if m := DecodeInputMethod(tx.InputHex); m != "" {
+		row.InputMethod = m
+	}
+	row.InputKind = inputKind(tx.InputHex)
+	return row
+}
+
+// inputKind classifies call input for TransactionRow.InputKind.
+func inputKind(input string) string {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "" || input == "0x" {
+		return InputKindEmpty
+	}
+	if len(input) < 10 || !strings.HasPrefix(input, "0x") {
+		return InputKindUnknown + input
+	}
+	selector := input[:10]
+	if name, ok := selectorNames[selector]; ok {
+		return InputKindKnown + name
+	}
+	return InputKindUnknown + selector
+}
+
+// initCodeHash returns the keccak256 of hex-encoded init code, or "" when
+// the input is empty or not valid hex.
+func initCodeHash(input string) string {
+	code, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(input)), "0x"))
+	if err != nil || len(code) == 0 {
+		return ""
+	}
+	return "0x" + hex.EncodeToString(keccak256(code))
 }
 
 // AsAny converts a typed slice into []any for generic encoders.
@@ -165,18 +280,23 @@ func AsAny[T any](in []T) []any {
 // Token and approval decoding
 
 type TokenTransferRow struct {
-	EventUID  string `json:"event_uid"`
-	TxHash    string `json:"tx_hash"`
-	LogIndex  uint32 `json:"log_index"`
-	Token     string `json:"token"`
-	From      string `json:"from_addr"`
-	To        string `json:"to_addr"`
-	AmountRaw string `json:"amount_raw"`
-	TokenID   string `json:"token_id"`
-	BatchOrd  uint16 `json:"batch_ordinal"`
-	Standard  string `json:"standard"` // erc20|erc721|erc1155
-	BlockNum  uint64 `json:"block_number"`
-	TsMillis  int64  `json:"ts_millis"`
+	EventUID    string `json:"event_uid"`
+	TxHash      string `json:"tx_hash"`
+	LogIndex    uint32 `json:"log_index"`
+	Token       string `json:"token"`
+	From        string `json:"from_addr"`
+	To          string `json:"to_addr"`
+	AmountRaw   string `json:"amount_raw"`
+	TokenID     string `json:"token_id"`
+	BatchOrd    uint16 `json:"batch_ordinal"`
+	Standard    string `json:"standard"`     // erc20|erc721|erc1155
+	IsMint      uint8  `json:"is_mint"`      // from is the zero address
+	IsBurn      uint8  `json:"is_burn"`      // to is the zero address
+	NonStandard uint8  `json:"non_standard"` // erc20 amount read from topics[3] (TokenDecodeOptions)
+	BlockNum    uint64 `json:"block_number"`
+	TsMillis    int64  `json:"ts_millis"`
+	ValueUSD    string `json:"value_usd,omitempty"`  // set by the ingester's price feed, if any
+	Suspicious  uint8  `json:"suspicious,omitempty"` // set by the ingester's honeypot guard, if enabled
 }
 
 type ApprovalRow struct {
@@ -190,8 +310,25 @@ type ApprovalRow struct {
 	TokenID   string `json:"token_id"`
 	IsForAll  uint8  `json:"is_approval_for_all"`
 	Standard  string `json:"standard"`
-	BlockNum  uint64 `json:"block_number"`
-	TsMillis  int64  `json:"ts_millis"`
+	// Source is PermitSource for approvals decoded from permit calldata and
+	// empty for Approval logs.
+	Source   string `json:"source,omitempty"`
+	BlockNum uint64 `json:"block_number"`
+	TsMillis int64  `json:"ts_millis"`
+}
+
+// isWord reports whether s is a 0x-prefixed 32-byte hex word.
+func isWord(s string) bool {
+	h := strings.TrimPrefix(strings.ToLower(s), "0x")
+	if len(h) != 64 || len(h) == len(s) {
+		return false
+	}
+	for _, c := range h {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
 }
 
 func hexToBigIntString(s string) string {
@@ -204,8 +341,30 @@ func hexToBigIntString(s string) string {
 	return b.String()
 }
 
+// TokenDecodeOptions tunes DecodeTokenEventsWith.
+type TokenDecodeOptions struct {
+	// IndexedAmountTokens lists lowercase token contracts known to be
+	// fungible although their Transfer event indexes the amount. Such logs
+	// have the ERC-721 shape (four topics, empty data), so without this hint
+	// they decode as an NFT transfer of token ID topics[3].
+	IndexedAmountTokens map[string]bool
+	// KnownStandards maps lowercase token contracts to a standard
+	// ("erc20", "erc721" or "erc1155") classified earlier, e.g. from a
+	// persistent cache. It overrides the per-log shape heuristics where the
+	// shape is ambiguous: a known erc20 emitting a four-topic Transfer
+	// decodes like an IndexedAmountTokens entry, and ApprovalForAll takes
+	// the contract's standard instead of guessing from the batch.
+	KnownStandards map[string]string
+}
+
 // DecodeTokenEvents extracts token transfers and approvals from logs.
 func DecodeTokenEvents(logs []eth.Log) (transfers []TokenTransferRow, approvals []ApprovalRow) {
+	return DecodeTokenEventsWith(logs, TokenDecodeOptions{})
+}
+
+// DecodeTokenEventsWith is DecodeTokenEvents with decoding hints.
+func DecodeTokenEventsWith(logs []eth.Log, opts TokenDecodeOptions) (transfers []TokenTransferRow, approvals []ApprovalRow) {
+	erc1155 := erc1155Emitters(logs)
 	for _, l := range logs {
 		if len(l.Topics) == 0 {
 			continue
@@ -216,29 +375,38 @@ func DecodeTokenEvents(logs []eth.Log) (transfers []TokenTransferRow, approvals
 			// ERC20 vs ERC721 heuristic
 			// ERC20: topics[1]=from, topics[2]=to, data=amount
 			// ERC721: topics[1]=from, topics[2]=to, topics[3]=tokenId, data empty
+			// Non-standard ERC20 (indexedAmount): topics[3]=amount, data empty
 			var amountRaw, tokenID, standard string
+			var nonStandard uint8
 			if len(l.Topics) >= 3 && len(l.DataHex) >= 2 {
 				amountRaw = hexToBigIntString(l.DataHex)
 				tokenID = ""
 				standard = "erc20"
 			}
-			if len(l.Topics) >= 4 && (l.DataHex == "0x" || l.DataHex == "") {
-				tokenID = hexToBigIntString(l.Topics[3])
-				amountRaw = "1"
-				standard = "erc721"
+			if len(l.Topics) >= 4 && (l.DataHex == "0x" || l.DataHex == "") && !hashedTopic(l.Topics, 3) {
+				if indexedAmount(opts, l.Address) && isWord(l.Topics[3]) {
+					amountRaw = hexToBigIntString(l.Topics[3])
+					standard = "erc20"
+					nonStandard = 1
+				} else {
+					tokenID = hexToBigIntString(l.Topics[3])
+					amountRaw = "1"
+					standard = "erc721"
+				}
 			}
 			transfers = append(transfers, TokenTransferRow{
-				EventUID:  fmt.Sprintf("%s:%d", l.TxHash, l.Index),
-				TxHash:    l.TxHash,
-				LogIndex:  l.Index,
-				Token:     l.Address,
-				From:      addrFromTopic(l.Topics, 1),
-				To:        addrFromTopic(l.Topics, 2),
-				AmountRaw: amountRaw,
-				TokenID:   tokenID,
-				Standard:  standard,
-				BlockNum:  l.BlockNum,
-				TsMillis:  l.TsMillis,
+				EventUID:    fmt.Sprintf("%s:%d", l.TxHash, l.Index),
+				TxHash:      l.TxHash,
+				LogIndex:    l.Index,
+				Token:       l.Address,
+				From:        addrFromTopic(l.Topics, 1),
+				To:          addrFromTopic(l.Topics, 2),
+				AmountRaw:   amountRaw,
+				TokenID:     tokenID,
+				Standard:    standard,
+				NonStandard: nonStandard,
+				BlockNum:    l.BlockNum,
+				TsMillis:    l.TsMillis,
 			})
 		case topicMatches(t0, topicApprovalFull):
 			// ERC20: topics[1]=owner, topics[2]=spender, data=amount
@@ -249,7 +417,7 @@ func DecodeTokenEvents(logs []eth.Log) (transfers []TokenTransferRow, approvals
 				amt = hexToBigIntString(l.DataHex)
 				standard = "erc20"
 			}
-			if len(l.Topics) >= 4 && (l.DataHex == "0x" || l.DataHex == "") {
+			if len(l.Topics) >= 4 && (l.DataHex == "0x" || l.DataHex == "") && !hashedTopic(l.Topics, 3) {
 				tokenID = hexToBigIntString(l.Topics[3])
 				amt = "1"
 				standard = "erc721"
@@ -284,7 +452,7 @@ func DecodeTokenEvents(logs []eth.Log) (transfers []TokenTransferRow, approvals
 				AmountRaw: "0",
 				TokenID:   "",
 				IsForAll:  isForAll,
-				Standard:  "erc721",
+				Standard:  forAllStandard(erc1155, opts.KnownStandards, l.Address),
 				BlockNum:  l.BlockNum,
 				TsMillis:  l.TsMillis,
 			})
@@ -333,9 +501,132 @@ func DecodeTokenEvents(logs []eth.Log) (transfers []TokenTransferRow, approvals
 			}
 		}
 	}
+	for k := range transfers {
+		if isZeroAddress(transfers[k].From) {
+			transfers[k].IsMint = 1
+		}
+		if isZeroAddress(transfers[k].To) {
+			transfers[k].IsBurn = 1
+		}
+	}
 	return
 }
 
+// erc1155Emitters returns the lowercase contracts that emit an ERC-1155
+// TransferSingle or TransferBatch among logs. ApprovalForAll has the same
+// signature in ERC-721 and ERC-1155, so its emitter's transfers are the only
+// in-batch hint of which standard it belongs to.
+func erc1155Emitters(logs []eth.Log) map[string]bool {
+	var out map[string]bool
+	for _, l := range logs {
+		if len(l.Topics) == 0 {
+			continue
+		}
+		t0 := strings.ToLower(l.Topics[0])
+		if topicMatches(t0, topicERC1155SingleFull) || topicMatches(t0, topicERC1155BatchFull) {
+			if out == nil {
+				out = map[string]bool{}
+			}
+			out[strings.ToLower(l.Address)] = true
+		}
+	}
+	return out
+}
+
+// indexedAmount reports whether a Transfer with four topics and empty data
+// from token carries its amount in topics[3] (an ERC-20 indexing the value,
+// as some rebasing tokens do) rather than an ERC-721 token ID. The value
+// alone cannot tell, since hash-derived token IDs are as large as any
+// amount, so only contract hints decide: IndexedAmountTokens, then a known
+// standard.
+func indexedAmount(opts TokenDecodeOptions, token string) bool {
+	token = strings.ToLower(token)
+	if opts.IndexedAmountTokens[token] {
+		return true
+	}
+	return opts.KnownStandards[token] == "erc20"
+}
+
+// forAllStandard labels an ApprovalForAll from token: its known standard
+// when that is erc721 or erc1155, else erc1155 when token emitted ERC-1155
+// transfers in the same batch, erc721 otherwise.
+func forAllStandard(erc1155 map[string]bool, known map[string]string, token string) string {
+	token = strings.ToLower(token)
+	if std := known[token]; std == "erc721" || std == "erc1155" {
+		return std
+	}
+	if erc1155[token] {
+		return "erc1155"
+	}
+	return "erc721"
+}
+
+// TokenContracts returns the sorted lowercase contracts that emit a token
+// event DecodeTokenEvents understands among logs.
+func TokenContracts(logs []eth.Log) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, l := range logs {
+		if len(l.Topics) == 0 {
+			continue
+		}
+		t0 := strings.ToLower(l.Topics[0])
+		if !topicMatches(t0, topicTransferFull) && !topicMatches(t0, topicApprovalFull) && !topicMatches(t0, topicApprovalForAllFull) &&
+			!topicMatches(t0, topicERC1155SingleFull) && !topicMatches(t0, topicERC1155BatchFull) {
+			continue
+		}
+		addr := strings.ToLower(l.Address)
+		if !seen[addr] {
+			seen[addr] = true
+			out = append(out, addr)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isZeroAddress reports whether a is the null address in either its 40-hex
+// form or left-padded to a 32-byte topic word.
+func isZeroAddress(a string) bool {
+	h := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(a)), "0x")
+	if len(h) != 40 && len(h) != 64 {
+		return false
+	}
+	return strings.Trim(h, "0") == ""
+}
+
+// ProxyUpgradeRow records an EIP-1967 Upgraded(address) event emitted by a proxy.
+type ProxyUpgradeRow struct {
+	EventUID       string `json:"event_uid"`
+	TxHash         string `json:"tx_hash"`
+	LogIndex       uint32 `json:"log_index"`
+	Proxy          string `json:"proxy"`
+	Implementation string `json:"implementation"`
+	BlockNum       uint64 `json:"block_number"`
+	TsMillis       int64  `json:"ts_millis"`
+}
+
+// DecodeProxyUpgrades extracts EIP-1967 Upgraded events. The implementation
+// address is indexed, so it is read from topics[1]; logs without it are skipped.
+func DecodeProxyUpgrades(logs []eth.Log) []ProxyUpgradeRow {
+	var out []ProxyUpgradeRow
+	for _, l := range logs {
+		if len(l.Topics) < 2 || !topicMatches(l.Topics[0], topicUpgradedFull) {
+			continue
+		}
+		out = append(out, ProxyUpgradeRow{
+			EventUID:       fmt.Sprintf("%s:%d", l.TxHash, l.Index),
+			TxHash:         l.TxHash,
+			LogIndex:       l.Index,
+			Proxy:          strings.ToLower(l.Address),
+			Implementation: addrFromTopic(l.Topics, 1),
+			BlockNum:       l.BlockNum,
+			TsMillis:       l.TsMillis,
+		})
+	}
+	return out
+}
+
 func topicMatches(topic, full string) bool {
 	if full == "" {
 		return false
@@ -352,7 +643,7 @@ func topicMatches(topic, full string) bool {
 }
 
 func addrFromTopic(topics []string, idx int) string {
-	if idx >= len(topics) {
+	if idx >= len(topics) || hashedTopic(topics, idx) {
 		return ""
 	}
 	t := topics[idx]
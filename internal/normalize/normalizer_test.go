@@ -2,6 +2,7 @@ package normalize
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 	"testing"
 
@@ -157,6 +158,39 @@ func TestDecodeERC20AndERC721TransfersAndApprovals(t *testing.T) {
 	}
 }
 
+func TestDecodeTokenEvents_ApprovalForAllFromERC1155Emitter(t *testing.T) {
+	padAddr := func(a string) string {
+		return "0x" + strings.Repeat("0", 24) + strings.TrimPrefix(strings.ToLower(a), "0x")
+	}
+	multi := "0x" + strings.Repeat("ab", 20)
+	nft := "0x" + strings.Repeat("cd", 20)
+	owner := "0x" + strings.Repeat("3", 40)
+	operator := "0x" + strings.Repeat("4", 40)
+	forAll := func(token string, idx uint32) eth.Log {
+		return eth.Log{TxHash: "0xf1", Index: idx, Address: token, Topics: []string{topicApprovalForAllFull, padAddr(owner), padAddr(operator)}, DataHex: "0x" + strings.Repeat("0", 63) + "1"}
+	}
+	// The approval precedes the emitter's transfer in the batch, and the
+	// transfer's address is checksummed.
+	logs := []eth.Log{
+		forAll(multi, 0),
+		{TxHash: "0xf2", Index: 1, Address: "0x" + strings.ToUpper(multi[2:]), Topics: []string{topicERC1155SingleFull, padAddr(operator), padAddr(owner), padAddr(operator)}, DataHex: "0x" + pad32Hex(7) + pad32Hex(1)},
+		forAll(nft, 2),
+	}
+	transfers, approvals := DecodeTokenEvents(logs)
+	if len(transfers) != 1 || transfers[0].Standard != "erc1155" {
+		t.Fatalf("transfers = %+v", transfers)
+	}
+	if len(approvals) != 2 {
+		t.Fatalf("approvals = %+v", approvals)
+	}
+	if approvals[0].Standard != "erc1155" || approvals[0].IsForAll != 1 {
+		t.Fatalf("1155 emitter approval = %+v", approvals[0])
+	}
+	if approvals[1].Standard != "erc721" {
+		t.Fatalf("other contract approval = %+v", approvals[1])
+	}
+}
+
 func TestDecodeInputMethod(t *testing.T) {
 	cases := map[string]string{
 		"0xa9059cbb0000": "transfer",
@@ -241,6 +275,33 @@ func TestTransactionsToRowsVariants(t *testing.T) {
 	}
 }
 
+func TestTransactionsToRowsContractCreation(t *testing.T) {
+	initCode := "0x6080604052"
+	txs := []eth.Transaction{
+		{Hash: "0x1", From: "0x" + strings.Repeat("a", 40), To: "", InputHex: initCode, ContractAddress: "0x" + strings.Repeat("D", 40)},
+		{Hash: "0x2", From: "0x" + strings.Repeat("b", 40), To: "", InputHex: strings.ToUpper(initCode[2:])},
+		// A creation with empty init code is still a creation, without a hash.
+		{Hash: "0x3", From: "0x" + strings.Repeat("a", 40), To: "", InputHex: "0x"},
+		// Calls keep selector decoding and carry no hash.
+		{Hash: "0x4", From: "0x" + strings.Repeat("a", 40), To: "0x" + strings.Repeat("c", 40), InputHex: "0x6080604052"},
+	}
+	rows := TransactionsToRows(txs, false)
+	const want = "0x1c3374235d773b2189aed115aa13143020fcdbbe86e38f358cf3e4771b2f0244"
+	if rows[0].InputMethod != CreateInputMethod || rows[0].InitCodeHash != want || rows[0].CreatedContract != "0x"+strings.Repeat("d", 40) {
+		t.Fatalf("creation row = %+v", rows[0])
+	}
+	// Identical init code from another deployer hashes the same.
+	if rows[1].InitCodeHash != want {
+		t.Fatalf("duplicate deployment hash = %q", rows[1].InitCodeHash)
+	}
+	if rows[2].InputMethod != CreateInputMethod || rows[2].InitCodeHash != "" {
+		t.Fatalf("empty creation row = %+v", rows[2])
+	}
+	if rows[3].InputMethod != "0x60806040" || rows[3].InitCodeHash != "" || rows[3].CreatedContract != "" {
+		t.Fatalf("call row = %+v", rows[3])
+	}
+}
+
 func TestValueToDecimalString(t *testing.T) {
 	cases := []struct {
 		in   string
@@ -259,3 +320,282 @@ func TestValueToDecimalString(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeTokenEventsMintBurnFlags(t *testing.T) {
+	zeroTopic := "0x" + strings.Repeat("0", 64)
+	zeroAddr := "0x" + strings.Repeat("0", 40)
+	alice := "0x" + strings.Repeat("0", 24) + strings.Repeat("a", 40)
+	bob := "0x" + strings.Repeat("0", 24) + strings.Repeat("b", 40)
+	amount := "0x" + pad32Hex(1)
+	logs := []eth.Log{
+		{TxHash: "0x1", Index: 0, Topics: []string{topicTransferFull, zeroTopic, alice}, DataHex: amount},
+		{TxHash: "0x2", Index: 0, Topics: []string{topicTransferFull, alice, zeroTopic}, DataHex: amount},
+		{TxHash: "0x3", Index: 0, Topics: []string{topicTransferFull, alice, bob}, DataHex: amount},
+		// Unpadded 40-hex topic form of the zero address.
+		{TxHash: "0x4", Index: 0, Topics: []string{topicTransferFull, zeroAddr, bob}, DataHex: amount},
+		{TxHash: "0x5", Index: 0, Topics: []string{topicERC1155SingleFull, alice, alice, zeroTopic}, DataHex: "0x" + pad32Hex(9) + pad32Hex(1)},
+	}
+	transfers, _ := DecodeTokenEvents(logs)
+	want := []struct{ mint, burn uint8 }{{1, 0}, {0, 1}, {0, 0}, {1, 0}, {0, 1}}
+	if len(transfers) != len(want) {
+		t.Fatalf("got %d transfers", len(transfers))
+	}
+	for k, w := range want {
+		if transfers[k].IsMint != w.mint || transfers[k].IsBurn != w.burn {
+			t.Fatalf("%s: mint=%d burn=%d, want %d/%d", transfers[k].TxHash, transfers[k].IsMint, transfers[k].IsBurn, w.mint, w.burn)
+		}
+	}
+}
+
+func TestDecodeTokenEventsIndexedAmount(t *testing.T) {
+	alice := "0x" + strings.Repeat("0", 24) + strings.Repeat("a", 40)
+	bob := "0x" + strings.Repeat("0", 24) + strings.Repeat("b", 40)
+	fungible := "0x" + strings.Repeat("f", 40)
+	nft := "0x" + strings.Repeat("e", 40)
+	amount := "0x" + pad32Hex(1_000_000)
+	logs := []eth.Log{
+		// Same shape from both contracts: four topics, empty data.
+		{TxHash: "0x1", Address: fungible, Topics: []string{topicTransferFull, alice, bob, amount}, DataHex: "0x"},
+		{TxHash: "0x2", Address: nft, Topics: []string{topicTransferFull, alice, bob, "0x" + pad32Hex(7)}, DataHex: "0x"},
+		// A listed token emitting the standard shape still reads data.
+		{TxHash: "0x3", Address: strings.ToUpper(fungible[:2]) + fungible[2:], Topics: []string{topicTransferFull, alice, bob}, DataHex: "0x" + pad32Hex(5)},
+		// A malformed amount topic falls back to the ERC-721 reading.
+		{TxHash: "0x4", Address: fungible, Topics: []string{topicTransferFull, alice, bob, "0x07"}, DataHex: ""},
+	}
+	opts := TokenDecodeOptions{IndexedAmountTokens: map[string]bool{fungible: true}}
+	transfers, _ := DecodeTokenEventsWith(logs, opts)
+	if len(transfers) != 4 {
+		t.Fatalf("got %d transfers", len(transfers))
+	}
+	if tr := transfers[0]; tr.Standard != "erc20" || tr.AmountRaw != "1000000" || tr.TokenID != "" || tr.NonStandard != 1 {
+		t.Fatalf("indexed-amount transfer mismatch: %+v", tr)
+	}
+	if tr := transfers[1]; tr.Standard != "erc721" || tr.TokenID != "7" || tr.AmountRaw != "1" || tr.NonStandard != 0 {
+		t.Fatalf("erc721 transfer mismatch: %+v", tr)
+	}
+	if tr := transfers[2]; tr.Standard != "erc20" || tr.AmountRaw != "5" || tr.NonStandard != 0 {
+		t.Fatalf("standard erc20 transfer mismatch: %+v", tr)
+	}
+	if tr := transfers[3]; tr.Standard != "erc721" || tr.TokenID != "7" || tr.NonStandard != 0 {
+		t.Fatalf("malformed topic transfer mismatch: %+v", tr)
+	}
+	// Without hints the indexed-amount shape stays ERC-721.
+	plain, _ := DecodeTokenEvents(logs[:1])
+	if plain[0].Standard != "erc721" || plain[0].TokenID != "1000000" || plain[0].NonStandard != 0 {
+		t.Fatalf("default decoding changed: %+v", plain[0])
+	}
+}
+
+func TestDecodeTokenEventsIndexedValueTransfer(t *testing.T) {
+	// Transfer(address indexed from, address indexed to, uint256 indexed value)
+	// hashes like the standard event; only the topic count and data differ.
+	if topicTransferFull != mustEventTopic("Transfer", []string{"address", "address", "uint256"}) {
+		t.Fatalf("unexpected Transfer topic %s", topicTransferFull)
+	}
+	from := "0x" + strings.Repeat("0", 24) + strings.Repeat("a", 40)
+	to := "0x" + strings.Repeat("0", 24) + strings.Repeat("b", 40)
+	rebasing := "0x" + strings.Repeat("c", 40)
+	// 1,234,567.89 tokens at 18 decimals.
+	value := new(big.Int).Mul(big.NewInt(123456789), new(big.Int).Exp(big.NewInt(10), big.NewInt(16), nil))
+	l := eth.Log{TxHash: "0x1", Address: rebasing, Topics: []string{topicTransferFull, from, to, fmt.Sprintf("0x%064x", value)}, DataHex: "0x"}
+
+	for name, opts := range map[string]TokenDecodeOptions{
+		"indexed-amount token": {IndexedAmountTokens: map[string]bool{rebasing: true}},
+		"known erc20":          {KnownStandards: map[string]string{rebasing: "erc20"}},
+	} {
+		transfers, _ := DecodeTokenEventsWith([]eth.Log{l}, opts)
+		if len(transfers) != 1 {
+			t.Fatalf("%s: got %d transfers", name, len(transfers))
+		}
+		if tr := transfers[0]; tr.Standard != "erc20" || tr.AmountRaw != value.String() || tr.TokenID != "" || tr.NonStandard != 1 {
+			t.Fatalf("%s: transfer mismatch: %+v", name, tr)
+		}
+	}
+	// A contract known as ERC-721, or unknown, keeps the token ID reading.
+	for name, opts := range map[string]TokenDecodeOptions{
+		"known erc721": {KnownStandards: map[string]string{rebasing: "erc721"}},
+		"no hint":      {},
+	} {
+		transfers, _ := DecodeTokenEventsWith([]eth.Log{l}, opts)
+		if tr := transfers[0]; tr.Standard != "erc721" || tr.TokenID != value.String() || tr.AmountRaw != "1" {
+			t.Fatalf("%s: transfer mismatch: %+v", name, tr)
+		}
+	}
+}
+
+func TestDecodeTokenEventsKnownStandards(t *testing.T) {
+	alice := "0x" + strings.Repeat("0", 24) + strings.Repeat("a", 40)
+	bob := "0x" + strings.Repeat("0", 24) + strings.Repeat("b", 40)
+	fungible := "0x" + strings.Repeat("f", 40)
+	multi := "0x" + strings.Repeat("e", 40)
+	logs := []eth.Log{
+		{TxHash: "0x1", Address: fungible, Topics: []string{topicTransferFull, alice, bob, "0x" + pad32Hex(9)}, DataHex: "0x"},
+		// No ERC-1155 transfer in the batch, yet the cache knows the contract.
+		{TxHash: "0x2", Address: multi, Topics: []string{topicApprovalForAllFull, alice, bob}, DataHex: "0x" + pad32Hex(1)},
+	}
+	opts := TokenDecodeOptions{KnownStandards: map[string]string{fungible: "erc20", multi: "erc1155"}}
+	transfers, approvals := DecodeTokenEventsWith(logs, opts)
+	if tr := transfers[0]; tr.Standard != "erc20" || tr.AmountRaw != "9" || tr.NonStandard != 1 {
+		t.Fatalf("known erc20 transfer mismatch: %+v", tr)
+	}
+	if ap := approvals[0]; ap.Standard != "erc1155" || ap.IsForAll != 1 {
+		t.Fatalf("known erc1155 approval mismatch: %+v", ap)
+	}
+	if _, plain := DecodeTokenEvents(logs[1:]); plain[0].Standard != "erc721" {
+		t.Fatalf("default ApprovalForAll standard changed: %+v", plain[0])
+	}
+	if got := TokenContracts(append(logs, eth.Log{Address: "0x" + strings.Repeat("1", 40), Topics: []string{"0x" + strings.Repeat("9", 64)}}, logs[0])); len(got) != 2 || got[0] != multi || got[1] != fungible {
+		t.Fatalf("TokenContracts = %v", got)
+	}
+}
+
+func TestIsKnownEvent(t *testing.T) {
+	for _, topic := range []string{topicTransferFull, strings.ToUpper(topicApprovalFull), topicApprovalForAllFull, topicERC1155SingleFull, topicERC1155BatchFull, topicUpgradedFull} {
+		if !IsKnownEvent(topic) {
+			t.Fatalf("%s not known", topic)
+		}
+	}
+	for _, topic := range []string{"", "0x", "0x" + strings.Repeat("d", 64)} {
+		if IsKnownEvent(topic) {
+			t.Fatalf("%q reported known", topic)
+		}
+	}
+}
+
+func TestIsZeroAddress(t *testing.T) {
+	cases := map[string]bool{
+		"0x" + strings.Repeat("0", 40):       true,
+		"0X" + strings.Repeat("0", 64):       true,
+		strings.Repeat("0", 40):              true,
+		"0x" + strings.Repeat("0", 39) + "1": false,
+		"0x0":                                false,
+		"":                                   false,
+	}
+	for in, want := range cases {
+		if got := isZeroAddress(in); got != want {
+			t.Fatalf("isZeroAddress(%q)=%v want %v", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeTransaction(t *testing.T) {
+	target := "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	other := "0x" + strings.Repeat("b", 40)
+	tx := eth.Transaction{Hash: "0xABC", From: target, To: other, ValueWei: "0x10", InputHex: "0xa9059cbb" + strings.Repeat("0", 128), BlockNum: 7, Status: 1}
+
+	row, ok := NormalizeTransaction(tx, strings.ToLower(target), false)
+	if !ok || row.TxHash != "0xabc" || row.From != strings.ToLower(target) || row.ValueRaw != "16" || row.IsInternal != 0 || row.InputMethod == "" {
+		t.Fatalf("external match: ok=%v row=%+v", ok, row)
+	}
+	if row, ok := NormalizeTransaction(tx, other, false); !ok || row.To != other {
+		t.Fatalf("to-address match: ok=%v row=%+v", ok, row)
+	}
+	if _, ok := NormalizeTransaction(tx, "0x"+strings.Repeat("c", 40), false); ok {
+		t.Fatal("unrelated target should not match")
+	}
+	if _, ok := NormalizeTransaction(tx, "", false); !ok {
+		t.Fatal("empty target should match")
+	}
+
+	internalTx := eth.Transaction{Hash: "0x1", From: other, To: target, ValueWei: "1", TraceID: "0-1", Status: 1}
+	row, ok = NormalizeTransaction(internalTx, target, true)
+	if !ok || row.IsInternal != 1 || row.TraceID != "0-1" {
+		t.Fatalf("internal match: ok=%v row=%+v", ok, row)
+	}
+	if _, ok := NormalizeTransaction(internalTx, "0x"+strings.Repeat("c", 40), true); ok {
+		t.Fatal("unrelated internal target should not match")
+	}
+}
+
+func TestIsPrecompile(t *testing.T) {
+	cases := map[string]bool{
+		"0x0000000000000000000000000000000000000001": true,
+		"0x0000000000000000000000000000000000000009": true,
+		"0X0000000000000000000000000000000000000005": true,
+		"0x0000000000000000000000000000000000000000": false,
+		"0x000000000000000000000000000000000000000a": false,
+		"0x0000000000000000000000000000000000000010": false,
+		"0x1000000000000000000000000000000000000001": false,
+		"0000000000000000000000000000000000000001":   false,
+		"0x01": false,
+		"":     false,
+	}
+	for in, want := range cases {
+		if got := IsPrecompile(in); got != want {
+			t.Fatalf("IsPrecompile(%q)=%v want %v", in, got, want)
+		}
+	}
+}
+
+func TestPrecompileFlagOnRows(t *testing.T) {
+	target := "0x" + strings.Repeat("a", 40)
+	ecrecover := "0x" + strings.Repeat("0", 39) + "1"
+	row, _ := NormalizeTransaction(eth.Transaction{Hash: "0x1", From: target, To: ecrecover, Status: 1}, target, false)
+	if row.IsPrecompile != 1 {
+		t.Fatalf("call to ecrecover not flagged: %+v", row)
+	}
+	row, _ = NormalizeTransaction(eth.Transaction{Hash: "0x2", From: target, To: "0x" + strings.Repeat("b", 40), Status: 1}, target, false)
+	if row.IsPrecompile != 0 {
+		t.Fatalf("normal call flagged: %+v", row)
+	}
+	traces := TracesToRows([]eth.Trace{{TxHash: "0x1", TraceID: "0-0", From: target, To: ecrecover}, {TxHash: "0x1", TraceID: "0-1", From: target, To: "0x" + strings.Repeat("b", 40)}})
+	if traces[0].IsPrecompile != 1 || traces[1].IsPrecompile != 0 {
+		t.Fatalf("trace flags = %d, %d", traces[0].IsPrecompile, traces[1].IsPrecompile)
+	}
+}
+
+func TestTransferTopics(t *testing.T) {
+	got := TransferTopics()
+	want := []string{
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		"0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62",
+		"0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for k := range want {
+		if strings.ToLower(got[k]) != want[k] {
+			t.Fatalf("topic %d = %s, want %s", k, got[k], want[k])
+		}
+	}
+}
+
+func TestTransactionsToRowsGasPrice(t *testing.T) {
+	rows := TransactionsToRows([]eth.Transaction{
+		{Hash: "0x1", GasUsed: 21000, GasPriceWei: "0x4a817c800"},
+		{Hash: "0x2", ReceiptMissing: true},
+	}, false)
+	if rows[0].GasPriceRaw != "20000000000" {
+		t.Fatalf("gas_price_raw = %q", rows[0].GasPriceRaw)
+	}
+	if rows[1].GasPriceRaw != "" {
+		t.Fatalf("unknown gas price = %q, want empty", rows[1].GasPriceRaw)
+	}
+}
+
+func TestTransactionsToRowsInputKind(t *testing.T) {
+	to := "0x2222222222222222222222222222222222222222"
+	cases := []struct {
+		to, input string
+		internal  bool
+		want      string
+	}{
+		{to, "", false, InputKindEmpty},
+		{to, "0x", false, InputKindEmpty},
+		{"", "0x6080604052", false, InputKindCreate},
+		{to, "0xA9059CBB0000", false, "known:transfer"},
+		{to, "0x40c10f19", false, "known:mint"},
+		{to, "0xabcdef012345", false, "unknown:0xabcdef01"},
+		{to, "0x00000000abcd", false, "unknown:0x00000000"},
+		{to, "0x123", false, "unknown:0x123"},
+		// Internal rows without a recipient are not assumed creations.
+		{"", "", true, InputKindEmpty},
+	}
+	for _, tc := range cases {
+		rows := TransactionsToRows([]eth.Transaction{{Hash: "0x1", From: to, To: tc.to, InputHex: tc.input}}, tc.internal)
+		if got := rows[0].InputKind; got != tc.want {
+			t.Fatalf("to=%q input=%q internal=%v: input_kind %q, want %q", tc.to, tc.input, tc.internal, got, tc.want)
+		}
+	}
+}
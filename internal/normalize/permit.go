@@ -0,0 +1,88 @@
+package normalize
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// PermitSource marks approvals decoded from EIP-2612 permit calldata rather
+// than from an Approval log.
+const PermitSource = "permit"
+
+// EventSource is the approvals.source of an approval decoded from an Approval
+// log, matching the column's DEFAULT.
+const EventSource = "event"
+
+// PermitLogIndex is the log_index of permit approvals. It sits above any real
+// log index so a permit never replaces an Approval log of the same transaction.
+const PermitLogIndex = math.MaxUint32
+
+// permitSelector is permit(owner,spender,value,deadline,v,r,s).
+var permitSelector = keccakHex("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)", 4)
+
+// permitArgsLen is the seven static argument words of a permit call.
+const permitArgsLen = 7 * 32
+
+// DecodePermits extracts EIP-2612 approvals from transactions that call
+// permit on a token directly, so gasless approvals show up even when the
+// token emits no Approval log. Reverted transactions, calldata shorter than
+// the seven argument words and malformed owner/spender/v words yield no rows.
+// Permits submitted inside another call (e.g. a router's selfPermit) are not
+// visible in the transaction input and are not decoded.
+func DecodePermits(txs []eth.Transaction) []ApprovalRow {
+	var out []ApprovalRow
+	for _, tx := range txs {
+		if tx.To == "" || (tx.Status == 0 && !tx.ReceiptMissing) {
+			continue
+		}
+		input := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(tx.InputHex)), "0x")
+		if len(input) < 8 || "0x"+input[:8] != permitSelector {
+			continue
+		}
+		data, err := hex.DecodeString(input)
+		if err != nil || len(data)-4 < permitArgsLen {
+			continue
+		}
+		args := data[4:]
+		owner, ok := abiAddress(args, 0)
+		if !ok {
+			continue
+		}
+		spender, ok := abiAddress(args, 32)
+		if !ok {
+			continue
+		}
+		if v, ok := abiUint(args, 4*32); !ok || v > 255 {
+			continue
+		}
+		hash := strings.ToLower(tx.Hash)
+		out = append(out, ApprovalRow{
+			EventUID:  hash + ":permit",
+			TxHash:    hash,
+			LogIndex:  PermitLogIndex,
+			Token:     strings.ToLower(tx.To),
+			Owner:     owner,
+			Spender:   spender,
+			AmountRaw: new(big.Int).SetBytes(args[64:96]).String(),
+			Standard:  "erc20",
+			Source:    PermitSource,
+			BlockNum:  tx.BlockNum,
+			TsMillis:  tx.TsMillis,
+		})
+	}
+	return out
+}
+
+// abiAddress reads the address word at byte offset off, rejecting words with
+// non-zero padding.
+func abiAddress(b []byte, off int) (string, bool) {
+	if off < 0 || off+32 > len(b) || !bytes.Equal(b[off:off+12], make([]byte, 12)) {
+		return "", false
+	}
+	return "0x" + hex.EncodeToString(b[off+12:off+32]), true
+}
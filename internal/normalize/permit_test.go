@@ -0,0 +1,92 @@
+package normalize
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+type permitsFixture struct {
+	Transactions []struct {
+		Hash        string `json:"hash"`
+		From        string `json:"from"`
+		To          string `json:"to"`
+		Input       string `json:"input"`
+		Status      uint8  `json:"status"`
+		BlockNumber uint64 `json:"block_number"`
+		TsMillis    int64  `json:"ts_millis"`
+	} `json:"transactions"`
+	Approvals []ApprovalRow `json:"approvals"`
+}
+
+func loadPermitTxs(t *testing.T) ([]eth.Transaction, []ApprovalRow) {
+	t.Helper()
+	data, err := os.ReadFile(fixturePath("permits_golden.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var fx permitsFixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		t.Fatalf("unmarshal fixture: %v", err)
+	}
+	txs := make([]eth.Transaction, len(fx.Transactions))
+	for i, tx := range fx.Transactions {
+		txs[i] = eth.Transaction{Hash: tx.Hash, From: tx.From, To: tx.To, InputHex: tx.Input, Status: tx.Status, BlockNum: tx.BlockNumber, TsMillis: tx.TsMillis}
+	}
+	return txs, fx.Approvals
+}
+
+// The fixture holds EIP-2612 permit calls to USDC (unlimited allowance to
+// Permit2, sent by a relayer) and UNI, ABI-encoded independently of this
+// decoder, plus a DAI-style permit (different signature) and a reverted
+// permit that yield no rows.
+func TestDecodePermits_GoldenFixture(t *testing.T) {
+	txs, want := loadPermitTxs(t)
+	if got := DecodePermits(txs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("permits mismatch\nwant=%s\n got=%s", mustJSON(want), mustJSON(got))
+	}
+}
+
+func TestDecodePermits_Selector(t *testing.T) {
+	if permitSelector != "0xd505accf" {
+		t.Fatalf("permit selector = %s", permitSelector)
+	}
+}
+
+func TestDecodePermits_ReceiptMissingKept(t *testing.T) {
+	txs, _ := loadPermitTxs(t)
+	tx := txs[0]
+	tx.Status, tx.ReceiptMissing = 0, true
+	if got := DecodePermits([]eth.Transaction{tx}); len(got) != 1 {
+		t.Fatalf("expected the permit without a receipt, got %v", got)
+	}
+}
+
+func TestDecodePermits_Malformed(t *testing.T) {
+	txs, _ := loadPermitTxs(t)
+	valid := txs[0].InputHex
+	args := strings.TrimPrefix(valid, "0xd505accf")
+	for name, input := range map[string]string{
+		"selector only": "0xd505accf",
+		"truncated":     valid[:len(valid)-64],
+		"dirty owner":   "0xd505accf" + "ff" + args[2:],
+		"dirty spender": "0xd505accf" + args[:64] + "ff" + args[66:],
+		"bad v":         "0xd505accf" + args[:4*64] + strings.Repeat("f", 64) + args[5*64:],
+		"not hex":       "0xd505accfzz" + args,
+	} {
+		tx := txs[0]
+		tx.InputHex = input
+		if got := DecodePermits([]eth.Transaction{tx}); len(got) != 0 {
+			t.Fatalf("%s: expected no rows, got %v", name, got)
+		}
+	}
+	tx := txs[0]
+	tx.To = ""
+	if got := DecodePermits([]eth.Transaction{tx}); len(got) != 0 {
+		t.Fatalf("creation: expected no rows, got %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package normalize
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// selectorCacheSize bounds the selector labels DecodeInputMethod memoizes.
+const selectorCacheSize = 4096
+
+type selectorLabel struct {
+	selector string // the input's first 10 bytes, as given
+	method   string
+}
+
+// selectorCache is a bounded LRU of DecodeInputMethod results keyed by the
+// raw selector, safe for concurrent use. A hit skips lowercasing the input,
+// which for long calldata costs far more than the lookup. selectorNames is
+// fixed once package init completes, so entries never go stale.
+type selectorCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	ordered *list.List
+}
+
+func newSelectorCache(max int) *selectorCache {
+	return &selectorCache{max: max, entries: make(map[string]*list.Element), ordered: list.New()}
+}
+
+func (c *selectorCache) get(selector string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[selector]
+	if !ok {
+		return "", false
+	}
+	c.ordered.MoveToFront(el)
+	return el.Value.(*selectorLabel).method, true
+}
+
+// add caches method for selector, evicting the least recently used entries
+// past max.
+func (c *selectorCache) add(selector, method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[selector]; ok {
+		c.ordered.MoveToFront(el)
+		return
+	}
+	c.entries[selector] = c.ordered.PushFront(&selectorLabel{selector: selector, method: method})
+	for c.ordered.Len() > c.max {
+		el := c.ordered.Back()
+		delete(c.entries, el.Value.(*selectorLabel).selector)
+		c.ordered.Remove(el)
+	}
+}
+
+func (c *selectorCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ordered.Len()
+}
+
+var selectorLabels = newSelectorCache(selectorCacheSize)
+
+// DecodeInputMethod maps calldata selectors to short method labels. Unknown
+// selectors return the 4-byte hex prefix, empty/short inputs return "".
+// Results are memoized per selector (see selectorCache).
+func DecodeInputMethod(input string) string {
+	s := strings.TrimSpace(input)
+	if len(s) < 10 || !isASCII(s[:10]) {
+		return decodeInputMethod(s)
+	}
+	// Lowercasing commutes with taking an ASCII prefix, so the selector
+	// alone decides the result.
+	selector := s[:10]
+	if method, ok := selectorLabels.get(selector); ok {
+		return method
+	}
+	method := decodeInputMethod(selector)
+	// Clone so the cache does not pin the whole calldata string.
+	selectorLabels.add(strings.Clone(selector), method)
+	return method
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var inputMethodCases = []string{
+	"",
+	"0x",
+	"0xa9059c",
+	"0xa9059cbb" + strings.Repeat("0", 128),
+	"0xA9059CBB" + strings.Repeat("F", 128),
+	"  0xa9059cbb  ",
+	"0X095EA7B3",
+	"0x00000000deadbeef",
+	"0xdeadbeef",
+	"0xDEADBEEF01",
+	"a9059cbb00",
+	"0xdeadbeefİ",
+	"0xİdeadbeef",
+	"  0xabc  ",
+}
+
+func TestDecodeInputMethod_CacheKeepsSemantics(t *testing.T) {
+	for _, in := range inputMethodCases {
+		want := decodeInputMethod(strings.TrimSpace(in))
+		// The first call fills the cache, the second reads it.
+		for pass := 0; pass < 2; pass++ {
+			if got := DecodeInputMethod(in); got != want {
+				t.Fatalf("pass %d: DecodeInputMethod(%q) = %q, want %q", pass, in, got, want)
+			}
+		}
+	}
+	if got := DecodeInputMethod("0xA9059CBB00"); got != "transfer" {
+		t.Fatalf("mixed case selector = %q", got)
+	}
+}
+
+func TestDecodeInputMethod_CacheHitSkipsLowercasing(t *testing.T) {
+	in := "0xA9059CBB" + strings.Repeat("AB", 500)
+	DecodeInputMethod(in)
+	if allocs := testing.AllocsPerRun(100, func() { DecodeInputMethod(in) }); allocs != 0 {
+		t.Fatalf("cached lookup allocated %.0f times per call", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { decodeInputMethod(in) }); allocs == 0 {
+		t.Fatal("uncached decode expected to allocate the lowercased input")
+	}
+}
+
+func TestSelectorCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newSelectorCache(2)
+	c.add("0x00000001", "a")
+	c.add("0x00000002", "b")
+	if _, ok := c.get("0x00000001"); !ok {
+		t.Fatal("missing entry")
+	}
+	c.add("0x00000003", "c")
+	if _, ok := c.get("0x00000002"); ok {
+		t.Fatal("least recently used entry not evicted")
+	}
+	if m, ok := c.get("0x00000001"); !ok || m != "a" || c.len() != 2 {
+		t.Fatalf("entry=%q ok=%v len=%d", m, ok, c.len())
+	}
+}
+
+func TestDecodeInputMethod_ConcurrentAccess(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 2*selectorCacheSize; n++ {
+				sel := fmt.Sprintf("0x%08x", (n*7+g)%(selectorCacheSize+500))
+				in := sel + "00"
+				if got, want := DecodeInputMethod(in), decodeInputMethod(in); got != want {
+					errs <- fmt.Sprintf("%s: got %q, want %q", in, got, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if n := selectorLabels.len(); n > selectorCacheSize {
+		t.Fatalf("cache holds %d entries, bound is %d", n, selectorCacheSize)
+	}
+}
+
+// BenchmarkDecodeInputMethod compares repeated selectors on realistic
+// calldata with and without the cache.
+func BenchmarkDecodeInputMethod(b *testing.B) {
+	inputs := make([]string, 16)
+	for n := range inputs {
+		inputs[n] = fmt.Sprintf("0x%08X", n) + strings.Repeat("AB", 500)
+	}
+	b.Run("cached", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			_ = DecodeInputMethod(inputs[n%len(inputs)])
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			_ = decodeInputMethod(strings.TrimSpace(inputs[n%len(inputs)]))
+		}
+	})
+}
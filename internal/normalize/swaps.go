@@ -0,0 +1,120 @@
+package normalize
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// Swap versions recorded in SwapRow.Version.
+const (
+	SwapV2 = "v2"
+	SwapV3 = "v3"
+)
+
+// SwapRow is a Uniswap V2 or V3 pool Swap event. Amount0 and Amount1 are the
+// signed changes of the pool's token0/token1 balances, following V3: positive
+// when the pool received the token, negative when it paid it out. For V2 they
+// are amountIn - amountOut. SqrtPriceX96, Liquidity and Tick are the pool
+// state after a V3 swap and stay empty (Tick 0) for V2.
+type SwapRow struct {
+	EventUID     string `json:"event_uid"`
+	TxHash       string `json:"tx_hash"`
+	LogIndex     uint32 `json:"log_index"`
+	Pool         string `json:"pool"`
+	Version      string `json:"version"`
+	Sender       string `json:"sender"`
+	Recipient    string `json:"recipient"`
+	Amount0      string `json:"amount0"`
+	Amount1      string `json:"amount1"`
+	SqrtPriceX96 string `json:"sqrt_price_x96"`
+	Liquidity    string `json:"liquidity"`
+	Tick         int32  `json:"tick"`
+	BlockNum     uint64 `json:"block_number"`
+	TsMillis     int64  `json:"ts_millis"`
+}
+
+// DecodeSwaps extracts Uniswap V2 Swap(sender, amount0In, amount1In,
+// amount0Out, amount1Out, to) and V3 Swap(sender, recipient, amount0,
+// amount1, sqrtPriceX96, liquidity, tick) events. Forks that emit the same
+// signatures (e.g. SushiSwap pairs) decode the same way. Logs missing the
+// indexed sender/recipient, with fewer (or non-hex) data words than their
+// layout, or with a tick outside int24 are skipped.
+func DecodeSwaps(logs []eth.Log) []SwapRow {
+	var out []SwapRow
+	for _, l := range logs {
+		if len(l.Topics) < 3 {
+			continue
+		}
+		words := splitDataWords(l.DataHex)
+		row := SwapRow{
+			EventUID:  fmt.Sprintf("%s:%d", l.TxHash, l.Index),
+			TxHash:    l.TxHash,
+			LogIndex:  l.Index,
+			Pool:      strings.ToLower(l.Address),
+			Sender:    addrFromTopic(l.Topics, 1),
+			Recipient: addrFromTopic(l.Topics, 2),
+			BlockNum:  l.BlockNum,
+			TsMillis:  l.TsMillis,
+		}
+		switch {
+		case topicMatches(l.Topics[0], topicSwapV2Full):
+			if len(words) < 4 || !allWords(words[:4]) {
+				continue
+			}
+			row.Version = SwapV2
+			row.Amount0 = netAmount(words[0], words[2])
+			row.Amount1 = netAmount(words[1], words[3])
+		case topicMatches(l.Topics[0], topicSwapV3Full):
+			if len(words) < 5 || !allWords(words[:5]) {
+				continue
+			}
+			tick := wordToSigned(words[4])
+			if !tick.IsInt64() || tick.Int64() < -(1<<23) || tick.Int64() >= 1<<23 {
+				continue
+			}
+			row.Version = SwapV3
+			row.Amount0 = wordToSigned(words[0]).String()
+			row.Amount1 = wordToSigned(words[1]).String()
+			row.SqrtPriceX96 = hexToBigIntString(words[2])
+			row.Liquidity = hexToBigIntString(words[3])
+			row.Tick = int32(tick.Int64())
+		default:
+			continue
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// allWords reports whether every word is valid 32-byte hex.
+func allWords(words []string) bool {
+	for _, w := range words {
+		if !isWord(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// netAmount returns in - out for two unsigned data words.
+func netAmount(in, out string) string {
+	a, _ := new(big.Int).SetString(strings.TrimPrefix(in, "0x"), 16)
+	b, _ := new(big.Int).SetString(strings.TrimPrefix(out, "0x"), 16)
+	return a.Sub(a, b).String()
+}
+
+// twoTo256 is the modulus of 256-bit two's complement words.
+var twoTo256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// wordToSigned decodes a 32-byte hex data word (see isWord) as a two's
+// complement int256; sign-extended narrower types (int24) decode the same way.
+func wordToSigned(word string) *big.Int {
+	v, _ := new(big.Int).SetString(strings.TrimPrefix(word, "0x"), 16)
+	if v.Bit(255) == 1 {
+		v.Sub(v, twoTo256)
+	}
+	return v
+}
@@ -0,0 +1,89 @@
+package normalize
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+type swapsFixture struct {
+	Logs  []goldenLog `json:"logs"`
+	Swaps []SwapRow   `json:"swaps"`
+}
+
+func loadSwapLogs(t *testing.T) ([]eth.Log, []SwapRow) {
+	t.Helper()
+	data, err := os.ReadFile(fixturePath("swaps_golden.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var fx swapsFixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		t.Fatalf("unmarshal fixture: %v", err)
+	}
+	return toEthLogs(fx.Logs), fx.Swaps
+}
+
+// The fixture holds a V2 swap (WETH in, USDC out) on the USDC/WETH pair, two
+// V3 swaps (negative amount1 and tick; negative amount0 at MAX_TICK),
+// encoded independently of this decoder, and a V2 Sync event that yields no
+// row.
+func TestDecodeSwaps_GoldenFixture(t *testing.T) {
+	logs, want := loadSwapLogs(t)
+	if got := DecodeSwaps(logs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("swaps mismatch\nwant=%s\n got=%s", mustJSON(want), mustJSON(got))
+	}
+}
+
+func TestDecodeSwaps_Topics(t *testing.T) {
+	ensureTopicDefaults()
+	if topicSwapV2Full != "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822" {
+		t.Fatalf("v2 swap topic = %s", topicSwapV2Full)
+	}
+	if topicSwapV3Full != "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67" {
+		t.Fatalf("v3 swap topic = %s", topicSwapV3Full)
+	}
+	if !IsKnownEvent(topicSwapV2Full) || !IsKnownEvent(topicSwapV3Full) {
+		t.Fatalf("swap topics should be known events")
+	}
+}
+
+func TestDecodeSwaps_Malformed(t *testing.T) {
+	logs, _ := loadSwapLogs(t)
+	v2, v3 := logs[0], logs[1]
+	v3Data := strings.TrimPrefix(v3.DataHex, "0x")
+	mutate := func(l eth.Log, f func(*eth.Log)) eth.Log {
+		l.Topics = append([]string(nil), l.Topics...)
+		f(&l)
+		return l
+	}
+	for name, l := range map[string]eth.Log{
+		"v2 short data":   mutate(v2, func(l *eth.Log) { l.DataHex = v2.DataHex[:len(v2.DataHex)-64] }),
+		"v2 not hex":      mutate(v2, func(l *eth.Log) { l.DataHex = "0x" + strings.Repeat("z", 64) + v2.DataHex[66:] }),
+		"v2 no recipient": mutate(v2, func(l *eth.Log) { l.Topics = l.Topics[:2] }),
+		"v3 short data":   mutate(v3, func(l *eth.Log) { l.DataHex = v3.DataHex[:len(v3.DataHex)-64] }),
+		"v3 tick too big": mutate(v3, func(l *eth.Log) { l.DataHex = "0x" + v3Data[:4*64] + strings.Repeat("0", 58) + "800000" }),
+		"v3 tick too low": mutate(v3, func(l *eth.Log) { l.DataHex = "0x" + v3Data[:4*64] + strings.Repeat("f", 58) + "7fffff" }),
+	} {
+		if got := DecodeSwaps([]eth.Log{l}); len(got) != 0 {
+			t.Fatalf("%s: expected no rows, got %v", name, got)
+		}
+	}
+}
+
+func TestWordToSigned(t *testing.T) {
+	for word, want := range map[string]string{
+		"0x" + strings.Repeat("0", 63) + "1": "1",
+		"0x" + strings.Repeat("f", 64):       "-1",
+		"0x8" + strings.Repeat("0", 63):      "-57896044618658097711785492504343953926634992332820282019728792003956564819968",
+		"0x7" + strings.Repeat("f", 63):      "57896044618658097711785492504343953926634992332820282019728792003956564819967",
+	} {
+		if got := wordToSigned(word).String(); got != want {
+			t.Fatalf("wordToSigned(%s) = %s, want %s", word, got, want)
+		}
+	}
+}
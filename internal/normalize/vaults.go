@@ -0,0 +1,78 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// Vault actions recorded in VaultEventRow.Action.
+const (
+	VaultDeposit  = "deposit"
+	VaultWithdraw = "withdraw"
+)
+
+// VaultEventRow is an ERC-4626 Deposit or Withdraw event emitted by Vault.
+// Assets is the amount of the underlying token moved and Shares the vault
+// shares minted or burned. For deposits Owner received the shares; for
+// withdrawals Owner's shares were burned and Receiver got the assets.
+// Receiver stays empty for deposits.
+type VaultEventRow struct {
+	EventUID string `json:"event_uid"`
+	TxHash   string `json:"tx_hash"`
+	LogIndex uint32 `json:"log_index"`
+	Vault    string `json:"vault"`
+	Action   string `json:"action"`
+	Sender   string `json:"sender"`
+	Owner    string `json:"owner"`
+	Receiver string `json:"receiver"`
+	Assets   string `json:"assets"`
+	Shares   string `json:"shares"`
+	BlockNum uint64 `json:"block_number"`
+	TsMillis int64  `json:"ts_millis"`
+}
+
+// DecodeVaultEvents extracts ERC-4626 Deposit(sender, owner, assets, shares)
+// and Withdraw(sender, receiver, owner, assets, shares) events. Logs missing
+// an indexed party or with fewer (or non-hex) data words than the two
+// amounts are skipped.
+func DecodeVaultEvents(logs []eth.Log) []VaultEventRow {
+	var out []VaultEventRow
+	for _, l := range logs {
+		if len(l.Topics) < 3 {
+			continue
+		}
+		words := splitDataWords(l.DataHex)
+		if len(words) < 2 || !allWords(words[:2]) {
+			continue
+		}
+		row := VaultEventRow{
+			EventUID: fmt.Sprintf("%s:%d", l.TxHash, l.Index),
+			TxHash:   l.TxHash,
+			LogIndex: l.Index,
+			Vault:    strings.ToLower(l.Address),
+			Sender:   addrFromTopic(l.Topics, 1),
+			Assets:   hexToBigIntString(words[0]),
+			Shares:   hexToBigIntString(words[1]),
+			BlockNum: l.BlockNum,
+			TsMillis: l.TsMillis,
+		}
+		switch {
+		case topicMatches(l.Topics[0], topicVaultDepositFull):
+			row.Action = VaultDeposit
+			row.Owner = addrFromTopic(l.Topics, 2)
+		case topicMatches(l.Topics[0], topicVaultWithdrawFull):
+			if len(l.Topics) < 4 {
+				continue
+			}
+			row.Action = VaultWithdraw
+			row.Receiver = addrFromTopic(l.Topics, 2)
+			row.Owner = addrFromTopic(l.Topics, 3)
+		default:
+			continue
+		}
+		out = append(out, row)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package normalize
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+type vaultEventsFixture struct {
+	Logs   []goldenLog     `json:"logs"`
+	Events []VaultEventRow `json:"vault_events"`
+}
+
+func loadVaultLogs(t *testing.T) ([]eth.Log, []VaultEventRow) {
+	t.Helper()
+	data, err := os.ReadFile(fixturePath("vault_events_golden.json"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var fx vaultEventsFixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		t.Fatalf("unmarshal fixture: %v", err)
+	}
+	return toEthLogs(fx.Logs), fx.Events
+}
+
+// The fixture holds a Deposit into sDAI, a Withdraw routed through a third
+// party to a different receiver, encoded independently of this decoder, and
+// a WETH Deposit(address,uint256) that yields no row.
+func TestDecodeVaultEvents_GoldenFixture(t *testing.T) {
+	logs, want := loadVaultLogs(t)
+	if got := DecodeVaultEvents(logs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("vault events mismatch\nwant=%s\n got=%s", mustJSON(want), mustJSON(got))
+	}
+}
+
+func TestDecodeVaultEvents_Topics(t *testing.T) {
+	ensureTopicDefaults()
+	if topicVaultDepositFull != "0xdcbc1c05240f31ff3ad067ef1ee35ce4997762752e3a095284754544f4c709d7" {
+		t.Fatalf("deposit topic = %s", topicVaultDepositFull)
+	}
+	if topicVaultWithdrawFull != "0xfbde797d201c681b91056529119e0b02407c7bb96a4a2c75c01fc9667232c8db" {
+		t.Fatalf("withdraw topic = %s", topicVaultWithdrawFull)
+	}
+	if !IsKnownEvent(topicVaultDepositFull) || !IsKnownEvent(topicVaultWithdrawFull) {
+		t.Fatalf("vault topics should be known events")
+	}
+}
+
+func TestDecodeVaultEvents_Malformed(t *testing.T) {
+	logs, _ := loadVaultLogs(t)
+	deposit, withdraw := logs[0], logs[1]
+	mutate := func(l eth.Log, f func(*eth.Log)) eth.Log {
+		l.Topics = append([]string(nil), l.Topics...)
+		f(&l)
+		return l
+	}
+	for name, l := range map[string]eth.Log{
+		"deposit short data":  mutate(deposit, func(l *eth.Log) { l.DataHex = deposit.DataHex[:len(deposit.DataHex)-64] }),
+		"deposit not hex":     mutate(deposit, func(l *eth.Log) { l.DataHex = "0x" + strings.Repeat("z", 64) + deposit.DataHex[66:] }),
+		"deposit no owner":    mutate(deposit, func(l *eth.Log) { l.Topics = l.Topics[:2] }),
+		"withdraw short data": mutate(withdraw, func(l *eth.Log) { l.DataHex = withdraw.DataHex[:66] }),
+		"withdraw no owner":   mutate(withdraw, func(l *eth.Log) { l.Topics = l.Topics[:3] }),
+	} {
+		if got := DecodeVaultEvents([]eth.Log{l}); len(got) != 0 {
+			t.Fatalf("%s: expected no rows, got %v", name, got)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package normalize
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+// gweiToWei scales EIP-4895 withdrawal amounts, which are in gwei.
+var gweiToWei = big.NewInt(1_000_000_000)
+
+// WithdrawalRow is a consensus-layer withdrawal (EIP-4895) credited to an
+// address. AmountGwei is the decimal amount as paid; AmountRaw is the same
+// in wei, comparable with value_raw elsewhere.
+type WithdrawalRow struct {
+	Index          uint64 `json:"withdrawal_index"`
+	ValidatorIndex uint64 `json:"validator_index"`
+	Address        string `json:"address"`
+	AmountGwei     string `json:"amount_gwei"`
+	AmountRaw      string `json:"amount_raw"`
+	BlockNum       uint64 `json:"block_number"`
+	TsMillis       int64  `json:"ts_millis"`
+}
+
+// WithdrawalsToRows normalizes provider withdrawals. The withdrawal index is
+// global to the chain, so it alone identifies a row.
+func WithdrawalsToRows(in []eth.Withdrawal) []WithdrawalRow {
+	out := make([]WithdrawalRow, 0, len(in))
+	for _, w := range in {
+		gwei := valueToDecimalString(w.AmountGwei)
+		wei := gwei
+		if v, ok := new(big.Int).SetString(gwei, 10); ok {
+			wei = v.Mul(v, gweiToWei).String()
+		}
+		out = append(out, WithdrawalRow{
+			Index:          w.Index,
+			ValidatorIndex: w.Validator,
+			Address:        strings.ToLower(w.Address),
+			AmountGwei:     gwei,
+			AmountRaw:      wei,
+			BlockNum:       w.BlockNum,
+			TsMillis:       w.TsMillis,
+		})
+	}
+	return out
+}
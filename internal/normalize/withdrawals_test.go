@@ -0,0 +1,26 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/eth"
+)
+
+func TestWithdrawalsToRows(t *testing.T) {
+	in := []eth.Withdrawal{
+		// 0x1bc16d gwei = 1818989 gwei.
+		{Index: 7, Validator: 100, Address: "0xABCDEFABCDEFABCDEFABCDEFABCDEFABCDEFABCD", AmountGwei: "0x1bc16d", BlockNum: 17034870, TsMillis: 1681338455000},
+		{Index: 8, Validator: 101, Address: "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd", AmountGwei: "0x0", BlockNum: 17034870, TsMillis: 1681338455000},
+	}
+	want := []WithdrawalRow{
+		{Index: 7, ValidatorIndex: 100, Address: "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd", AmountGwei: "1818989", AmountRaw: "1818989000000000", BlockNum: 17034870, TsMillis: 1681338455000},
+		{Index: 8, ValidatorIndex: 101, Address: "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd", AmountGwei: "0", AmountRaw: "0", BlockNum: 17034870, TsMillis: 1681338455000},
+	}
+	if got := WithdrawalsToRows(in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := WithdrawalsToRows(nil); len(got) != 0 {
+		t.Fatalf("expected no rows, got %+v", got)
+	}
+}
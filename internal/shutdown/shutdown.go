@@ -0,0 +1,105 @@
+// Package shutdown collects the cleanup callbacks (metrics flushes, cache
+// persistence, sink closes) a process runs once on its way out, whether the
+// work finished or a signal cut it short.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+// DefaultTimeout bounds Run when no timeout is given.
+const DefaultTimeout = 10 * time.Second
+
+// Hook flushes or closes one component. It should return promptly once ctx
+// is done.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+// Registry holds hooks in registration order. The zero value is ready to
+// use and safe for concurrent use.
+type Registry struct {
+	mu    sync.Mutex
+	hooks []namedHook
+	ran   bool
+}
+
+// Register adds fn under name. Hooks registered after Run has started are
+// ignored.
+func (r *Registry) Register(name string, fn Hook) {
+	if r == nil || fn == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ran {
+		return
+	}
+	r.hooks = append(r.hooks, namedHook{name: name, fn: fn})
+}
+
+// Run calls the hooks once, last registered first like deferred calls, all
+// sharing a deadline of timeout (<= 0 selects DefaultTimeout). A hook still
+// running at the deadline is abandoned, and those after it are skipped, each
+// logged as shutdown_hook_timeout, so a stuck flush cannot hang the exit;
+// errors are logged as shutdown_hook_failed. Run returns the joined errors,
+// with context.DeadlineExceeded for abandoned and skipped hooks. Later calls
+// do nothing.
+func (r *Registry) Run(timeout time.Duration) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	if r.ran {
+		r.mu.Unlock()
+		return nil
+	}
+	r.ran = true
+	hooks := r.hooks
+	r.mu.Unlock()
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	// The process context is typically canceled by now, so hooks get their own.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var errs []error
+	for k := len(hooks) - 1; k >= 0; k-- {
+		h := hooks[k]
+		err := ctx.Err()
+		if err == nil {
+			done := make(chan error, 1)
+			go func() { done <- h.fn(ctx) }()
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		if err == nil {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		if logger := logging.Logger(); logger != nil {
+			event := "shutdown_hook_failed"
+			if errors.Is(err, context.DeadlineExceeded) {
+				event = "shutdown_hook_timeout"
+			}
+			logger.Warn(event,
+				"component", "shutdown",
+				"hook", h.name,
+				"error", err.Error(),
+			)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/internal/logging"
+)
+
+func TestRegistry_RunsHooksOnceInReverseOrder(t *testing.T) {
+	var r Registry
+	var order []string
+	for _, name := range []string{"metrics", "cache", "sink"} {
+		r.Register(name, func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+	r.Register("nil", nil)
+	if err := r.Run(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sink", "cache", "metrics"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	r.Register("late", func(ctx context.Context) error { order = append(order, "late"); return nil })
+	if err := r.Run(time.Second); err != nil || len(order) != 3 {
+		t.Fatalf("second run: err=%v order=%v", err, order)
+	}
+}
+
+func TestRegistry_BoundsStuckHooks(t *testing.T) {
+	prev := logging.Logger()
+	logging.DiscardLogging()
+	defer logging.SetLogger(prev)
+	var r Registry
+	var ran []string
+	r.Register("skipped", func(ctx context.Context) error { ran = append(ran, "skipped"); return nil })
+	r.Register("stuck", func(ctx context.Context) error { select {} })
+	r.Register("broken", func(ctx context.Context) error { return errors.New("push failed") })
+	r.Register("flushed", func(ctx context.Context) error { ran = append(ran, "flushed"); return nil })
+	start := time.Now()
+	err := r.Run(20 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run took %v", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stuck:") || !strings.Contains(err.Error(), "skipped:") || !strings.Contains(err.Error(), "broken: push failed") {
+		t.Fatalf("err = %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"flushed"}) {
+		t.Fatalf("ran = %v", ran)
+	}
+}
+
+func TestRegistry_Nil(t *testing.T) {
+	var r *Registry
+	r.Register("x", func(ctx context.Context) error { return nil })
+	if err := r.Run(0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,51 @@
+// Package bodylimit caps how much of an HTTP response body is read, so an
+// endpoint that streams without end fails the call instead of exhausting
+// memory. The JSON-RPC provider and the ClickHouse client share it.
+package bodylimit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTooLarge is returned by a Reader once its body exceeds the cap.
+var ErrTooLarge = errors.New("response body too large")
+
+// DefaultMaxBytes is the cap callers apply unless configured otherwise. It is
+// far above any sane eth_getLogs page or ClickHouse query result, so only a
+// broken or hostile endpoint reaches it.
+const DefaultMaxBytes int64 = 512 << 20
+
+// limitedBody reads at most limit bytes from r and fails with ErrTooLarge,
+// rather than io.LimitReader's silent EOF, when r holds more.
+type limitedBody struct {
+	r     io.Reader
+	left  int64
+	limit int64
+}
+
+// Reader wraps r with the cap; limit <= 0 leaves it unbounded.
+func Reader(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &limitedBody{r: r, left: limit, limit: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		// Probe a byte to tell a body of exactly limit bytes from a longer one.
+		var probe [1]byte
+		if n, err := b.r.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: over %d bytes", ErrTooLarge, b.limit)
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
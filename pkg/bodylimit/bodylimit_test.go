@@ -0,0 +1,23 @@
+package bodylimit
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	b, err := io.ReadAll(Reader(strings.NewReader("abcdef"), 6))
+	if err != nil || string(b) != "abcdef" {
+		t.Fatalf("at limit: %q, %v", b, err)
+	}
+	b, err = io.ReadAll(Reader(strings.NewReader("abcdefg"), 6))
+	if !errors.Is(err, ErrTooLarge) || string(b) != "abcdef" {
+		t.Fatalf("over limit: %q, %v", b, err)
+	}
+	b, err = io.ReadAll(Reader(strings.NewReader("abcdefg"), 0))
+	if err != nil || string(b) != "abcdefg" {
+		t.Fatalf("unbounded: %q, %v", b, err)
+	}
+}
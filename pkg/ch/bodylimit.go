@@ -0,0 +1,12 @@
+package ch
+
+import "github.com/AIAleph/mvp_wallet_context/pkg/bodylimit"
+
+// ErrResponseTooLarge is returned when a ClickHouse response body, after
+// decompression, exceeds the client's size cap (SetMaxResponseBytes). It is
+// not retried.
+var ErrResponseTooLarge = bodylimit.ErrTooLarge
+
+// DefaultMaxResponseBytes caps response bodies unless SetMaxResponseBytes
+// overrides it.
+const DefaultMaxResponseBytes = bodylimit.DefaultMaxBytes
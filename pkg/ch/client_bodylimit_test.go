@@ -0,0 +1,75 @@
+package ch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// endlessRows streams the same JSONEachRow line forever.
+type endlessRows struct{ line []byte }
+
+func (e endlessRows) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		n += copy(p[n:], e.line)
+	}
+	return n, nil
+}
+
+func TestQueryJSONEachRow_ResponseTooLarge(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	c.SetMaxResponseBytes(1024)
+	calls := 0
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(endlessRows{line: []byte("{\"a\":1}\n")})}, nil
+	})}
+	if _, err := c.QueryJSONEachRow(context.Background(), "SELECT 1"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("err = %v, want ErrResponseTooLarge", err)
+	}
+	if calls != 1 {
+		t.Fatalf("oversized response retried: %d calls", calls)
+	}
+}
+
+func TestQueryJSONEachRow_DecompressedSizeLimited(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(strings.Repeat("{\"a\":1}\n", 1000)))
+	_ = zw.Close()
+	c := New("http://localhost:8123/db")
+	c.SetCompression(true)
+	c.SetMaxResponseBytes(1024)
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Header: http.Header{"Content-Encoding": []string{"gzip"}}, Body: io.NopCloser(bytes.NewReader(buf.Bytes()))}, nil
+	})}
+	if buf.Len() >= 1024 {
+		t.Fatalf("compressed fixture too large: %d bytes", buf.Len())
+	}
+	if _, err := c.QueryJSONEachRow(context.Background(), "SELECT 1"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("err = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+func TestQueryJSONEachRow_WithinLimit(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	c.SetMaxResponseBytes(0)
+	if c.maxBody != DefaultMaxResponseBytes {
+		t.Fatalf("cap = %d, want the default", c.maxBody)
+	}
+	payload := "{\"a\":1}\n{\"a\":2}\n"
+	c.SetMaxResponseBytes(int64(len(payload)))
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(payload))}, nil
+	})}
+	rows, err := c.QueryJSONEachRow(context.Background(), "SELECT 1")
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("rows = %v, err = %v", rows, err)
+	}
+}
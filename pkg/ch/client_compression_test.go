@@ -0,0 +1,102 @@
+package ch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const compressedPayload = "{\"address\":\"0x1\"}\n{\"address\":\"0x2\"}\n"
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll([]byte(s), nil)
+}
+
+func encodedClient(t *testing.T, encoding string, body []byte) *Client {
+	t.Helper()
+	c := New("http://localhost:8123/db")
+	c.SetCompression(true)
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get("enable_http_compression") != "1" {
+			t.Fatalf("missing enable_http_compression: %s", r.URL.RawQuery)
+		}
+		if got := r.Header.Get("Accept-Encoding"); got != "zstd, gzip" {
+			t.Fatalf("Accept-Encoding = %q", got)
+		}
+		h := http.Header{}
+		if encoding != "" {
+			h.Set("Content-Encoding", encoding)
+		}
+		return &http.Response{StatusCode: 200, Header: h, Body: io.NopCloser(bytes.NewReader(body))}, nil
+	})}
+	return c
+}
+
+func TestQueryJSONEachRow_DecodesCompressedBodies(t *testing.T) {
+	cases := map[string][]byte{
+		"gzip": gzipBytes(t, compressedPayload),
+		"zstd": zstdBytes(t, compressedPayload),
+		"":     []byte(compressedPayload),
+	}
+	for enc, body := range cases {
+		rows, err := encodedClient(t, enc, body).QueryJSONEachRow(context.Background(), "SELECT address FROM addresses")
+		if err != nil {
+			t.Fatalf("%q: %v", enc, err)
+		}
+		if len(rows) != 2 || string(rows[1]) != `{"address":"0x2"}` {
+			t.Fatalf("%q: unexpected rows %q", enc, rows)
+		}
+	}
+}
+
+func TestQueryJSONEachRow_CompressionErrors(t *testing.T) {
+	for enc, body := range map[string][]byte{
+		"gzip": []byte("not gzip"),
+		"br":   []byte("x"),
+	} {
+		if _, err := encodedClient(t, enc, body).QueryJSONEachRow(context.Background(), "SELECT 1"); err == nil {
+			t.Fatalf("%q: expected error", enc)
+		}
+	}
+	if _, err := encodedClient(t, "zstd", []byte("not zstd")).QueryJSONEachRow(context.Background(), "SELECT 1"); err == nil || !strings.Contains(err.Error(), "magic") {
+		t.Fatalf("expected zstd decode error, got %v", err)
+	}
+}
+
+func TestQueryJSONEachRow_CompressionOffByDefault(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Has("enable_http_compression") || r.Header.Get("Accept-Encoding") != "" {
+			t.Fatalf("compression requested by default: %s %v", r.URL.RawQuery, r.Header)
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(compressedPayload))}, nil
+	})}
+	if rows, err := c.QueryJSONEachRow(context.Background(), "SELECT 1"); err != nil || len(rows) != 2 {
+		t.Fatalf("rows=%v err=%v", rows, err)
+	}
+	var nilClient *Client
+	nilClient.SetCompression(true)
+}
@@ -0,0 +1,42 @@
+package ch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExec_PostsStatement(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	var method, query string
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		method, query = r.Method, r.URL.Query().Get("query")
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	})}
+	if err := c.Exec(context.Background(), "DROP TABLE IF EXISTS t"); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || query != "DROP TABLE IF EXISTS t" {
+		t.Fatalf("method=%s query=%q", method, query)
+	}
+}
+
+func TestExec_ErrorsAndNoop(t *testing.T) {
+	if err := New("").Exec(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("disabled client: %v", err)
+	}
+	if err := New("tcp://localhost:9000").Exec(context.Background(), "SELECT 1"); err != nil {
+		t.Fatalf("unsupported scheme: %v", err)
+	}
+	c := New("http://localhost:8123/db")
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 400, Body: io.NopCloser(strings.NewReader("syntax error"))}, nil
+	})}
+	err := c.Exec(context.Background(), "CREATE TABLE")
+	if err == nil || !strings.Contains(err.Error(), "clickhouse exec http 400: syntax error") {
+		t.Fatalf("err = %v", err)
+	}
+}
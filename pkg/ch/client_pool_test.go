@@ -0,0 +1,28 @@
+package ch
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClient_SetPool(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	tr := c.hc.Transport.(*http.Transport)
+	if tr.MaxIdleConns != DefaultMaxIdleConns || tr.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || tr.MaxConnsPerHost != DefaultMaxConnsPerHost {
+		t.Fatalf("default pool = %d/%d/%d", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost)
+	}
+
+	c.SetPool(PoolOptions{MaxIdleConns: 256, MaxConnsPerHost: 128})
+	if tr.MaxIdleConns != 256 || tr.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || tr.MaxConnsPerHost != 128 {
+		t.Fatalf("pool = %d/%d/%d, want 256/%d/128", tr.MaxIdleConns, tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost, DefaultMaxIdleConnsPerHost)
+	}
+
+	// An injected transport is left alone.
+	c.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) { return nil, nil }))
+	c.SetPool(PoolOptions{MaxIdleConns: 1})
+	if tr.MaxIdleConns != 256 {
+		t.Fatalf("SetPool reached the replaced transport")
+	}
+	var nilClient *Client
+	nilClient.SetPool(PoolOptions{MaxIdleConns: 1})
+}
@@ -0,0 +1,78 @@
+package ch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// redirectTransport answers the first request with a redirect to location
+// and records every request's method, host and body.
+func redirectTransport(code int, location string, seen *[]string) rtFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+		}
+		*seen = append(*seen, r.Method+" "+r.URL.Host+r.URL.Path+" "+string(body))
+		if len(*seen) == 1 {
+			h := http.Header{"Location": []string{location}}
+			return &http.Response{StatusCode: code, Header: h, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+	}
+}
+
+func TestInsert_FollowsSameHostRedirectWithBody(t *testing.T) {
+	for _, code := range []int{307, 308} {
+		c := New("http://localhost:8123/db")
+		var seen []string
+		c.SetTransport(redirectTransport(code, "http://localhost:8123/other", &seen))
+		if err := c.InsertJSONEachRow(context.Background(), "t", []any{map[string]any{"a": 1}}); err != nil {
+			t.Fatalf("%d: %v", code, err)
+		}
+		if len(seen) != 2 || seen[1] != "POST localhost:8123/other {\"a\":1}\n" {
+			t.Fatalf("%d: requests = %q", code, seen)
+		}
+	}
+}
+
+func TestInsert_RefusesRedirectsDroppingBodyOrHost(t *testing.T) {
+	for _, tc := range []struct {
+		code     int
+		location string
+		want     string
+	}{
+		{302, "http://localhost:8123/other", "http 302 would resend the POST as a GET without its body"},
+		{303, "/other", "http 303 would resend the POST as a GET"},
+		{307, "https://localhost:8123/db", "redirected to https://localhost:8123"},
+		{308, "http://proxy.example:8123/db", "redirected to http://proxy.example:8123"},
+	} {
+		c := New("http://localhost:8123/db")
+		var seen []string
+		c.SetTransport(redirectTransport(tc.code, tc.location, &seen))
+		err := c.InsertJSONEachRow(context.Background(), "t", []any{map[string]any{"a": 1}})
+		if !errors.Is(err, ErrRedirect) || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%d %s: err = %v", tc.code, tc.location, err)
+		}
+		// Not retried, and the redirect target never sees a request.
+		if len(seen) != 1 {
+			t.Fatalf("%d %s: requests = %q", tc.code, tc.location, seen)
+		}
+	}
+}
+
+func TestQuery_FollowsSameHostGetRedirect(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	var seen []string
+	c.SetTransport(redirectTransport(302, "http://localhost:8123/other", &seen))
+	if _, err := c.QueryJSONEachRow(context.Background(), "SELECT 1 FORMAT JSONEachRow"); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || !strings.HasPrefix(seen[1], "GET localhost:8123/other") {
+		t.Fatalf("requests = %q", seen)
+	}
+}
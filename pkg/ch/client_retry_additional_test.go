@@ -3,8 +3,15 @@ package ch
 import (
 	"context"
 	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/AIAleph/mvp_wallet_context/pkg/clock"
 )
 
 func TestHTTPStatusErrErrorString(t *testing.T) {
@@ -26,6 +33,7 @@ func TestIsRetriableMatrix(t *testing.T) {
 		{name: "http 500", err: &httpStatusErr{code: 500}, want: true},
 		{name: "http 499", err: &httpStatusErr{code: 499}, want: false},
 		{name: "transport", err: errors.New("dial tcp"), want: true},
+		{name: "memory limit", err: &httpStatusErr{code: 500, body: "Code: 241. DB::Exception: Memory limit (total) exceeded. (MEMORY_LIMIT_EXCEEDED)"}, want: false},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -49,7 +57,7 @@ func TestDoWithRetryContextCanceled(t *testing.T) {
 		retryBackoffBase = oldBackoff
 	}()
 
-	err := doWithRetry(ctx, func() error { return errors.New("temporary") })
+	err := doWithRetry(ctx, nil, func() error { return errors.New("temporary") })
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("expected context canceled error, got %v", err)
 	}
@@ -66,7 +74,7 @@ func TestDoWithRetryExhaustsAttempts(t *testing.T) {
 	}()
 
 	want := &httpStatusErr{code: 503, body: "bad", op: "insert"}
-	err := doWithRetry(context.Background(), func() error { return want })
+	err := doWithRetry(context.Background(), nil, func() error { return want })
 	if !errors.Is(err, want) {
 		t.Fatalf("expected final error %v, got %v", want, err)
 	}
@@ -74,7 +82,7 @@ func TestDoWithRetryExhaustsAttempts(t *testing.T) {
 
 func TestDoWithRetryImmediateSuccess(t *testing.T) {
 	called := 0
-	err := doWithRetry(context.Background(), func() error {
+	err := doWithRetry(context.Background(), nil, func() error {
 		called++
 		return nil
 	})
@@ -89,7 +97,7 @@ func TestDoWithRetryImmediateSuccess(t *testing.T) {
 func TestDoWithRetryNonRetriable(t *testing.T) {
 	calls := 0
 	ctx := context.Background()
-	err := doWithRetry(ctx, func() error {
+	err := doWithRetry(ctx, nil, func() error {
 		calls++
 		return &httpStatusErr{code: 400, body: "bad", op: "query"}
 	})
@@ -116,7 +124,7 @@ func TestDoWithRetryCanceledBetweenAttempts(t *testing.T) {
 	attempts := 0
 	delay := retryBackoffBase / 2
 
-	err := doWithRetry(ctx, func() error {
+	err := doWithRetry(ctx, nil, func() error {
 		attempts++
 		if attempts == 1 {
 			go func() {
@@ -135,3 +143,50 @@ func TestDoWithRetryCanceledBetweenAttempts(t *testing.T) {
 		t.Fatalf("expected single attempt before cancellation, got %d", attempts)
 	}
 }
+
+func TestClientBackoffFollowsInjectedClock(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	fake := clock.NewFake(time.Time{})
+	c.SetClock(fake)
+	var calls int32
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return &http.Response{StatusCode: 503, Body: io.NopCloser(strings.NewReader("busy"))}, nil
+		}
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})}
+	done := make(chan error, 1)
+	go func() { done <- c.Ping(context.Background()) }()
+	for range 2 {
+		fake.BlockUntil(1)
+		waits := fake.Waits()
+		fake.Advance(waits[len(waits)-1])
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{retryBackoffBase, 2 * retryBackoffBase}
+	if got := fake.Waits(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("backoffs = %v, want %v", got, want)
+	}
+}
+
+func TestInsertJSONEachRow_MemoryLimitNotRetried(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	var calls int32
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		body := "Code: 241. DB::Exception: Memory limit (for query) exceeded: would use 9.31 GiB. (MEMORY_LIMIT_EXCEEDED) (version 24.3.1.1)"
+		return &http.Response{StatusCode: 500, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	err := c.InsertJSONEachRow(context.Background(), "logs", []any{map[string]any{"x": 1}})
+	if !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Fatalf("expected ErrMemoryLimitExceeded, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("attempts = %d; the same payload must not be resent", calls)
+	}
+	if errors.Is(&httpStatusErr{code: 500, body: "boom"}, ErrMemoryLimitExceeded) {
+		t.Fatal("plain 5xx must not match ErrMemoryLimitExceeded")
+	}
+}
@@ -22,7 +22,7 @@ func TestClientPing(t *testing.T) {
 func TestRequestContext(t *testing.T) {
 	c := New("http://localhost:8123/db")
 	base := context.Background()
-	ctx, cancel := c.requestContext(base)
+	ctx, cancel := c.requestContext(base, c.queryTimeout)
 	if ctx == base {
 		t.Fatalf("expected derived context when no deadline present")
 	}
@@ -33,13 +33,57 @@ func TestRequestContext(t *testing.T) {
 
 	withDeadline, cancelBase := context.WithTimeout(context.Background(), time.Second)
 	defer cancelBase()
-	ctx2, cancel2 := c.requestContext(withDeadline)
+	ctx2, cancel2 := c.requestContext(withDeadline, c.queryTimeout)
 	if ctx2 != withDeadline {
 		t.Fatalf("expected original context when deadline already set")
 	}
 	cancel2()
 }
 
+func TestTimeouts_InsertAndQueryDiffer(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	c.SetTimeouts(2*time.Second, time.Hour)
+	remaining := map[string]time.Duration{}
+	c.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+		deadline, ok := r.Context().Deadline()
+		if !ok {
+			t.Fatalf("%s: request has no deadline", r.URL.Query().Get("query"))
+		}
+		remaining[strings.Fields(r.URL.Query().Get("query"))[0]] = time.Until(deadline)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	}))
+	ctx := context.Background()
+	if err := c.InsertJSONEachRow(ctx, "logs", []any{map[string]any{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.QueryJSONEachRow(ctx, "SELECT 1 FORMAT JSONEachRow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Exec(ctx, "OPTIMIZE TABLE logs"); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining["INSERT"]; got < 59*time.Minute {
+		t.Fatalf("insert deadline in %v, want the 1h insert timeout", got)
+	}
+	if got := remaining["OPTIMIZE"]; got < 59*time.Minute {
+		t.Fatalf("exec deadline in %v, want the 1h insert timeout", got)
+	}
+	if got := remaining["SELECT"]; got > 2*time.Second || got <= 0 {
+		t.Fatalf("query deadline in %v, want the 2s query timeout", got)
+	}
+
+	// Non-positive values keep the current timeouts; defaults differ.
+	c.SetTimeouts(0, -1)
+	if c.queryTimeout != 2*time.Second || c.insertTimeout != time.Hour {
+		t.Fatalf("timeouts = %v/%v after no-op SetTimeouts", c.queryTimeout, c.insertTimeout)
+	}
+	if d := New(""); d.queryTimeout != DefaultQueryTimeout || d.insertTimeout != DefaultInsertTimeout {
+		t.Fatalf("defaults = %v/%v", d.queryTimeout, d.insertTimeout)
+	}
+	var nilClient *Client
+	nilClient.SetTimeouts(time.Second, time.Second)
+}
+
 func TestSetTransport(t *testing.T) {
 	c := New("http://localhost:8123/db")
 	c.SetTransport(nil) // should be a no-op
@@ -0,0 +1,45 @@
+package ch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestClient_SendsUserAgent(t *testing.T) {
+	for _, tc := range []struct{ set, want string }{
+		{"", DefaultUserAgent},
+		{"mvp_wallet_context/v1.2.3", "mvp_wallet_context/v1.2.3"},
+	} {
+		c := New("http://localhost:8123/db")
+		c.SetUserAgent(tc.set)
+		var got []string
+		c.SetTransport(rtFunc(func(r *http.Request) (*http.Response, error) {
+			got = append(got, r.Method+" "+r.Header.Get("User-Agent"))
+			return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+		}))
+		ctx := context.Background()
+		if err := c.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.InsertJSONEachRow(ctx, "t", []any{map[string]any{"a": 1}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.QueryJSONEachRow(ctx, "SELECT 1 FORMAT JSONEachRow"); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"GET " + tc.want, "POST " + tc.want, "GET " + tc.want}
+		if len(got) != len(want) {
+			t.Fatalf("requests = %q", got)
+		}
+		for k := range want {
+			if got[k] != want[k] {
+				t.Fatalf("request %d = %q, want %q", k, got[k], want[k])
+			}
+		}
+	}
+	var nilClient *Client
+	nilClient.SetUserAgent("x")
+}
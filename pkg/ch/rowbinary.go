@@ -0,0 +1,750 @@
+package ch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column declares one column of a RowBinary insert: its name and ClickHouse
+// type as DESCRIBE TABLE reports it, e.g. "UInt64", "Nullable(String)" or
+// "DateTime64(3, 'UTC')".
+//
+// Supported types: String, FixedString(N), Bool, UInt8-64, Int8-64,
+// Float32/64, DateTime, DateTime64(P), Decimal(P, S) up to P = 18,
+// Enum8/Enum16, and Nullable, LowCardinality, Array and Map of those.
+type Column struct {
+	Name string
+	Type string
+}
+
+// rbEncoder appends v, a row value, to buf in RowBinary. A nil v (an omitted
+// column) encodes as the type's zero value, or NULL for Nullable.
+type rbEncoder func(buf *bytes.Buffer, v any) error
+
+// rowBinaryTable is a table's declared columns with their encoders.
+type rowBinaryTable struct {
+	cols  []Column
+	encs  []rbEncoder
+	names map[string]bool
+}
+
+func compileColumns(cols []Column) (*rowBinaryTable, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("rowbinary: no columns")
+	}
+	t := &rowBinaryTable{cols: cols, encs: make([]rbEncoder, len(cols)), names: make(map[string]bool, len(cols))}
+	for n, col := range cols {
+		if col.Name == "" || t.names[col.Name] {
+			return nil, fmt.Errorf("rowbinary: empty or duplicate column %q", col.Name)
+		}
+		t.names[col.Name] = true
+		enc, err := compileType(col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("rowbinary: column %s: %w", col.Name, err)
+		}
+		t.encs[n] = enc
+	}
+	return t, nil
+}
+
+// encode serializes rows, which are maps keyed by column name or values that
+// encode to such a JSON object. A row key outside the declared columns fails
+// the batch rather than being dropped.
+func (t *rowBinaryTable) encode(rows []any) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, row := range rows {
+		fields, err := rowFields(row)
+		if err != nil {
+			return nil, fmt.Errorf("encode row %d: %w", i, err)
+		}
+		for name := range fields {
+			if !t.names[name] {
+				return nil, fmt.Errorf("encode row %d: column %q is not declared", i, name)
+			}
+		}
+		for n, col := range t.cols {
+			if err := t.encs[n](&buf, fields[col.Name]); err != nil {
+				return nil, fmt.Errorf("encode row %d column %s: %w", i, col.Name, err)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// rowFields returns row as a column map, going through JSON for structs.
+func rowFields(row any) (map[string]any, error) {
+	if m, ok := row.(map[string]any); ok {
+		return m, nil
+	}
+	b, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("row is not an object: %w", err)
+	}
+	return m, nil
+}
+
+// compileType builds the encoder of one ClickHouse type.
+func compileType(typ string) (rbEncoder, error) {
+	name, args, err := splitType(typ)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "String":
+		return encodeString, nil
+	case "FixedString":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("type %q: want FixedString(N)", typ)
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("type %q: bad length", typ)
+		}
+		return fixedStringEncoder(n), nil
+	case "Bool":
+		return encodeBool, nil
+	case "UInt8", "UInt16", "UInt32", "UInt64":
+		bits, _ := strconv.Atoi(strings.TrimPrefix(name, "UInt"))
+		return uintEncoder(bits), nil
+	case "Int8", "Int16", "Int32", "Int64":
+		bits, _ := strconv.Atoi(strings.TrimPrefix(name, "Int"))
+		return intEncoder(bits), nil
+	case "Float32", "Float64":
+		return floatEncoder(name == "Float32"), nil
+	case "DateTime", "DateTime64":
+		return dateTimeEncoder(typ, name == "DateTime64", args)
+	case "Decimal", "Decimal32", "Decimal64":
+		return decimalEncoder(typ, name, args)
+	case "Enum8", "Enum16":
+		return enumEncoder(typ, name == "Enum16", args)
+	case "Nullable", "LowCardinality", "Array":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("type %q: want one type argument", typ)
+		}
+		inner, err := compileType(args[0])
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "Nullable":
+			return nullableEncoder(inner), nil
+		case "Array":
+			return arrayEncoder(inner), nil
+		}
+		// LowCardinality does not change the RowBinary encoding.
+		return inner, nil
+	case "Map":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("type %q: want Map(K, V)", typ)
+		}
+		key, err := compileType(args[0])
+		if err != nil {
+			return nil, err
+		}
+		val, err := compileType(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return mapEncoder(key, val), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", typ)
+}
+
+// splitType splits "Name(a, b)" into its name and top-level arguments,
+// keeping quoted strings and nested parentheses intact.
+func splitType(typ string) (string, []string, error) {
+	typ = strings.TrimSpace(typ)
+	open := strings.IndexByte(typ, '(')
+	if open < 0 {
+		return typ, nil, nil
+	}
+	if !strings.HasSuffix(typ, ")") {
+		return "", nil, fmt.Errorf("type %q: unbalanced parentheses", typ)
+	}
+	var args []string
+	depth, quoted, start := 0, false, open+1
+	inner := typ[:len(typ)-1]
+	for i := open + 1; i < len(inner); i++ {
+		switch b := inner[i]; {
+		case b == '\\' && quoted:
+			i++
+		case b == '\'':
+			quoted = !quoted
+		case quoted:
+		case b == '(':
+			depth++
+		case b == ')':
+			depth--
+		case b == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(inner[start:i]))
+			start = i + 1
+		}
+	}
+	if depth != 0 || quoted {
+		return "", nil, fmt.Errorf("type %q: unbalanced parentheses or quotes", typ)
+	}
+	args = append(args, strings.TrimSpace(inner[start:]))
+	return strings.TrimSpace(typ[:open]), args, nil
+}
+
+// deref follows pointers; a nil pointer yields nil.
+func deref(v any) any {
+	for v != nil {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Pointer {
+			return v
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		v = rv.Elem().Interface()
+	}
+	return v
+}
+
+func putUvarint(buf *bytes.Buffer, n uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], n)])
+}
+
+func toString(v any) (string, error) {
+	switch s := deref(v).(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	case []byte:
+		return string(s), nil
+	case json.Number:
+		return s.String(), nil
+	case fmt.Stringer:
+		return s.String(), nil
+	}
+	return "", fmt.Errorf("cannot encode %T as a string", v)
+}
+
+func encodeString(buf *bytes.Buffer, v any) error {
+	s, err := toString(v)
+	if err != nil {
+		return err
+	}
+	putUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+	return nil
+}
+
+func fixedStringEncoder(n int) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		s, err := toString(v)
+		if err != nil {
+			return err
+		}
+		if len(s) > n {
+			return fmt.Errorf("%d bytes do not fit FixedString(%d)", len(s), n)
+		}
+		buf.WriteString(s)
+		buf.Write(make([]byte, n-len(s)))
+		return nil
+	}
+}
+
+func toUint(v any) (uint64, error) {
+	switch x := deref(v).(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseUint(strings.TrimSpace(x), 10, 64)
+	case json.Number:
+		return strconv.ParseUint(x.String(), 10, 64)
+	case float64:
+		if x < 0 || x != math.Trunc(x) || x >= math.MaxUint64 {
+			return 0, fmt.Errorf("%v is not an unsigned integer", x)
+		}
+		return uint64(x), nil
+	}
+	rv := reflect.ValueOf(deref(v))
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, fmt.Errorf("%d is negative", rv.Int())
+		}
+		return uint64(rv.Int()), nil
+	}
+	return 0, fmt.Errorf("cannot encode %T as an unsigned integer", v)
+}
+
+func toInt(v any) (int64, error) {
+	switch x := deref(v).(type) {
+	case nil:
+		return 0, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(x), 10, 64)
+	case json.Number:
+		return x.Int64()
+	case float64:
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", x)
+		}
+		return int64(x), nil
+	}
+	rv := reflect.ValueOf(deref(v))
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows Int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("cannot encode %T as an integer", v)
+}
+
+func encodeBool(buf *bytes.Buffer, v any) error {
+	n, err := toUint(v)
+	if err != nil || n > 1 {
+		return fmt.Errorf("cannot encode %v as Bool", v)
+	}
+	buf.WriteByte(byte(n))
+	return nil
+}
+
+func uintEncoder(bits int) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		n, err := toUint(v)
+		if err != nil {
+			return err
+		}
+		if bits < 64 && n>>bits != 0 {
+			return fmt.Errorf("%d overflows UInt%d", n, bits)
+		}
+		putFixed(buf, n, bits/8)
+		return nil
+	}
+}
+
+func intEncoder(bits int) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		n, err := toInt(v)
+		if err != nil {
+			return err
+		}
+		if bits < 64 && (n < -1<<(bits-1) || n >= 1<<(bits-1)) {
+			return fmt.Errorf("%d overflows Int%d", n, bits)
+		}
+		putFixed(buf, uint64(n), bits/8)
+		return nil
+	}
+}
+
+// putFixed appends the low size bytes of n, little endian.
+func putFixed(buf *bytes.Buffer, n uint64, size int) {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], n)
+	buf.Write(tmp[:size])
+}
+
+func floatEncoder(single bool) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		var f float64
+		switch x := deref(v).(type) {
+		case nil:
+		case float64:
+			f = x
+		case float32:
+			f = float64(x)
+		case json.Number:
+			var err error
+			if f, err = x.Float64(); err != nil {
+				return err
+			}
+		case string:
+			var err error
+			if f, err = strconv.ParseFloat(strings.TrimSpace(x), 64); err != nil {
+				return err
+			}
+		default:
+			n, err := toInt(v)
+			if err != nil {
+				return fmt.Errorf("cannot encode %T as a float", v)
+			}
+			f = float64(n)
+		}
+		if single {
+			putFixed(buf, uint64(math.Float32bits(float32(f))), 4)
+		} else {
+			putFixed(buf, math.Float64bits(f), 8)
+		}
+		return nil
+	}
+}
+
+// dateTimeEncoder encodes DateTime([tz]) as UInt32 seconds and
+// DateTime64(P[, tz]) as Int64 ticks of 10^-P seconds. Strings are parsed in
+// the column's time zone (UTC when it has none), numbers are taken as seconds
+// or ticks.
+func dateTimeEncoder(typ string, is64 bool, args []string) (rbEncoder, error) {
+	precision, tzArg := 0, ""
+	switch {
+	case is64 && len(args) >= 1 && len(args) <= 2:
+		p, err := strconv.Atoi(args[0])
+		if err != nil || p < 0 || p > 9 {
+			return nil, fmt.Errorf("type %q: bad precision", typ)
+		}
+		precision = p
+		if len(args) == 2 {
+			tzArg = args[1]
+		}
+	case !is64 && len(args) <= 1:
+		if len(args) == 1 {
+			tzArg = args[0]
+		}
+	default:
+		return nil, fmt.Errorf("type %q: bad arguments", typ)
+	}
+	loc := time.UTC
+	if tz := strings.Trim(tzArg, "'"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("type %q: %w", typ, err)
+		}
+	}
+	scale := int64(math.Pow10(precision))
+	return func(buf *bytes.Buffer, v any) error {
+		var t time.Time
+		switch x := deref(v).(type) {
+		case time.Time:
+			t = x
+		case string:
+			var err error
+			if t, err = parseDateTime(x, loc); err != nil {
+				return err
+			}
+		default:
+			n, err := toInt(v)
+			if err != nil {
+				return fmt.Errorf("cannot encode %T as %s", v, typ)
+			}
+			if !is64 {
+				return uintEncoder(32)(buf, n)
+			}
+			putFixed(buf, uint64(n), 8)
+			return nil
+		}
+		if !is64 {
+			return uintEncoder(32)(buf, t.Unix())
+		}
+		ticks := t.Unix()*scale + int64(t.Nanosecond())/(int64(time.Second)/scale)
+		putFixed(buf, uint64(ticks), 8)
+		return nil
+	}, nil
+}
+
+// parseDateTime reads "2006-01-02 15:04:05[.fraction]" in loc, or RFC 3339.
+func parseDateTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation(time.DateTime, s, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date time", s)
+}
+
+// decimalEncoder encodes Decimal(P, S), Decimal32(S) and Decimal64(S) as the
+// value scaled by 10^S in an Int32 (P <= 9) or Int64 (P <= 18). Extra
+// fractional digits are truncated, as ClickHouse does when parsing.
+func decimalEncoder(typ, name string, args []string) (rbEncoder, error) {
+	var precision, scale int
+	var err error
+	switch {
+	case name == "Decimal" && len(args) == 2:
+		if precision, err = strconv.Atoi(args[0]); err == nil {
+			scale, err = strconv.Atoi(args[1])
+		}
+	case name == "Decimal32" && len(args) == 1:
+		precision = 9
+		scale, err = strconv.Atoi(args[0])
+	case name == "Decimal64" && len(args) == 1:
+		precision = 18
+		scale, err = strconv.Atoi(args[0])
+	default:
+		err = fmt.Errorf("bad arguments")
+	}
+	if err != nil || precision < 1 || precision > 18 || scale < 0 || scale > precision {
+		return nil, fmt.Errorf("type %q: unsupported precision or scale", typ)
+	}
+	size := 4
+	if precision > 9 {
+		size = 8
+	}
+	factor := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	return func(buf *bytes.Buffer, v any) error {
+		s, err := toString(v)
+		if err != nil {
+			switch x := deref(v).(type) {
+			case float64:
+				s = strconv.FormatFloat(x, 'f', -1, 64)
+			default:
+				n, ierr := toInt(v)
+				if ierr != nil {
+					return fmt.Errorf("cannot encode %T as %s", v, typ)
+				}
+				s = strconv.FormatInt(n, 10)
+			}
+		}
+		if s == "" {
+			s = "0"
+		}
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+		if !ok {
+			return fmt.Errorf("cannot parse %q as %s", s, typ)
+		}
+		r.Mul(r, factor)
+		scaled := new(big.Int).Quo(r.Num(), r.Denom())
+		if !scaled.IsInt64() || (size == 4 && (scaled.Int64() < math.MinInt32 || scaled.Int64() > math.MaxInt32)) {
+			return fmt.Errorf("%s overflows %s", s, typ)
+		}
+		putFixed(buf, uint64(scaled.Int64()), size)
+		return nil
+	}, nil
+}
+
+// enumEncoder encodes Enum8/Enum16 values, given by name or number, as their
+// Int8/Int16 number. A nil value takes the first declared name, ClickHouse's
+// default for an enum column.
+func enumEncoder(typ string, is16 bool, args []string) (rbEncoder, error) {
+	values := make(map[string]int64, len(args))
+	var first int64
+	for n, arg := range args {
+		eq := strings.LastIndexByte(arg, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("type %q: want 'name' = value pairs", typ)
+		}
+		label := strings.TrimSpace(arg[:eq])
+		if len(label) < 2 || label[0] != '\'' || label[len(label)-1] != '\'' {
+			return nil, fmt.Errorf("type %q: bad name %s", typ, label)
+		}
+		label = strings.ReplaceAll(label[1:len(label)-1], `\'`, "'")
+		num, err := strconv.ParseInt(strings.TrimSpace(arg[eq+1:]), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("type %q: bad value in %s", typ, arg)
+		}
+		values[label] = num
+		if n == 0 {
+			first = num
+		}
+	}
+	bits := 8
+	if is16 {
+		bits = 16
+	}
+	enc := intEncoder(bits)
+	return func(buf *bytes.Buffer, v any) error {
+		switch x := deref(v).(type) {
+		case nil:
+			return enc(buf, first)
+		case string:
+			num, ok := values[x]
+			if !ok {
+				return fmt.Errorf("unknown %s value %q", typ, x)
+			}
+			return enc(buf, num)
+		}
+		return enc(buf, v)
+	}, nil
+}
+
+func nullableEncoder(inner rbEncoder) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		if deref(v) == nil {
+			buf.WriteByte(1)
+			return nil
+		}
+		buf.WriteByte(0)
+		return inner(buf, v)
+	}
+}
+
+func arrayEncoder(elem rbEncoder) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		v = deref(v)
+		if v == nil {
+			putUvarint(buf, 0)
+			return nil
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("cannot encode %T as an array", v)
+		}
+		putUvarint(buf, uint64(rv.Len()))
+		for i := 0; i < rv.Len(); i++ {
+			if err := elem(buf, rv.Index(i).Interface()); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return nil
+	}
+}
+
+// mapEncoder writes a map's entries sorted by key, so equal maps encode to
+// equal bytes.
+func mapEncoder(key, val rbEncoder) rbEncoder {
+	return func(buf *bytes.Buffer, v any) error {
+		v = deref(v)
+		if v == nil {
+			putUvarint(buf, 0)
+			return nil
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return fmt.Errorf("cannot encode %T as a map", v)
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(a, b int) bool {
+			return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+		})
+		putUvarint(buf, uint64(len(keys)))
+		for _, k := range keys {
+			if err := key(buf, k.Interface()); err != nil {
+				return fmt.Errorf("key %v: %w", k.Interface(), err)
+			}
+			if err := val(buf, rv.MapIndex(k).Interface()); err != nil {
+				return fmt.Errorf("value of %v: %w", k.Interface(), err)
+			}
+		}
+		return nil
+	}
+}
+
+// SetRowBinary makes InsertJSONEachRow send table's rows in RowBinary, in
+// the order and types of cols, instead of JSONEachRow. Nil cols are read
+// with DescribeTable on the first insert. Rows may omit columns, which then
+// take their type's zero value rather than the column DEFAULT; a row key
+// outside cols fails the insert.
+func (c *Client) SetRowBinary(table string, cols []Column) error {
+	var t *rowBinaryTable
+	if cols != nil {
+		var err error
+		if t, err = compileColumns(cols); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	c.rbMu.Lock()
+	defer c.rbMu.Unlock()
+	if c.rowBinary == nil {
+		c.rowBinary = make(map[string]*rowBinaryTable)
+	}
+	c.rowBinary[table] = t
+	return nil
+}
+
+// rowBinaryTable returns table's RowBinary columns, describing the table on
+// first use, or nil when the table is sent as JSONEachRow.
+func (c *Client) rowBinaryTable(ctx context.Context, table string) (*rowBinaryTable, error) {
+	c.rbMu.Lock()
+	t, ok := c.rowBinary[table]
+	c.rbMu.Unlock()
+	if !ok || t != nil {
+		return t, nil
+	}
+	cols, err := c.DescribeTable(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if t, err = compileColumns(cols); err != nil {
+		return nil, fmt.Errorf("%s: %w", table, err)
+	}
+	c.rbMu.Lock()
+	c.rowBinary[table] = t
+	c.rbMu.Unlock()
+	return t, nil
+}
+
+// DescribeTable returns the columns an INSERT into table takes, in table
+// order; MATERIALIZED, ALIAS and EPHEMERAL columns are left out.
+func (c *Client) DescribeTable(ctx context.Context, table string) ([]Column, error) {
+	rows, err := c.QueryJSONEachRow(ctx, fmt.Sprintf("DESCRIBE TABLE %s FORMAT JSONEachRow", sanitizeIdent(table)))
+	if err != nil {
+		return nil, fmt.Errorf("describing %s: %w", table, err)
+	}
+	cols := make([]Column, 0, len(rows))
+	for _, raw := range rows {
+		var r struct {
+			Name        string `json:"name"`
+			Type        string `json:"type"`
+			DefaultType string `json:"default_type"`
+		}
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, fmt.Errorf("describing %s: %w", table, err)
+		}
+		switch r.DefaultType {
+		case "MATERIALIZED", "ALIAS", "EPHEMERAL":
+			continue
+		}
+		cols = append(cols, Column{Name: r.Name, Type: r.Type})
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("describing %s: no insertable columns", table)
+	}
+	return cols, nil
+}
+
+// InsertRowBinary performs an INSERT INTO <table> (<cols>) FORMAT RowBinary
+// of rows (maps keyed by column name, or structs encoding to them). If the
+// endpoint is empty, it is a no-op.
+func (c *Client) InsertRowBinary(ctx context.Context, table string, cols []Column, rows []any) error {
+	t, err := compileColumns(cols)
+	if err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	return c.insertRowBinary(ctx, table, t, rows)
+}
+
+func (c *Client) insertRowBinary(ctx context.Context, table string, t *rowBinaryTable, rows []any) error {
+	if len(rows) == 0 || !c.Enabled() {
+		return nil
+	}
+	payload, err := t.encode(rows)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(t.cols))
+	for n, col := range t.cols {
+		names[n] = sanitizeIdent(col.Name)
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) FORMAT RowBinary", sanitizeIdent(table), strings.Join(names, ", "))
+	return c.postInsert(ctx, query, "application/octet-stream", payload)
+}
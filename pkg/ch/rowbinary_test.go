@@ -0,0 +1,286 @@
+package ch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logsColumns mirrors the canonical logs table plus the column types other
+// canonical tables use.
+var logsColumns = []Column{
+	{"event_uid", "String"},
+	{"log_index", "UInt32"},
+	{"topics", "Array(String)"},
+	{"hashed_topics", "Map(UInt8, String)"},
+	{"block_number", "UInt64"},
+	{"ts", "DateTime64(3, 'UTC')"},
+	{"unconfirmed", "UInt8"},
+	{"finality", "Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3)"},
+	{"source_provider", "LowCardinality(String)"},
+	{"value_usd", "Nullable(String)"},
+	{"confidence", "Decimal(5, 4)"},
+	{"embedding", "Array(Float32)"},
+	{"tick", "Int32"},
+}
+
+// rbReader decodes RowBinary back into Go values for round-trip checks:
+// integers as int64/uint64, DateTime64 as time.Time, enums by name and
+// decimals as strings.
+type rbReader struct {
+	t *testing.T
+	r *bytes.Reader
+}
+
+func (d rbReader) fixed(size int) uint64 {
+	var tmp [8]byte
+	if _, err := io.ReadFull(d.r, tmp[:size]); err != nil {
+		d.t.Fatalf("short payload: %v", err)
+	}
+	return binary.LittleEndian.Uint64(tmp[:])
+}
+
+func (d rbReader) value(typ string) any {
+	name, args, err := splitType(typ)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	switch name {
+	case "String":
+		n, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			d.t.Fatal(err)
+		}
+		b := make([]byte, n)
+		_, _ = io.ReadFull(d.r, b)
+		return string(b)
+	case "LowCardinality":
+		return d.value(args[0])
+	case "UInt8", "UInt32", "UInt64":
+		bits, _ := strconv.Atoi(strings.TrimPrefix(name, "UInt"))
+		return d.fixed(bits / 8)
+	case "Int32":
+		return int64(int32(d.fixed(4)))
+	case "Float32":
+		return math.Float32frombits(uint32(d.fixed(4)))
+	case "DateTime64":
+		return time.UnixMilli(int64(d.fixed(8))).UTC()
+	case "Decimal":
+		scale, _ := strconv.Atoi(args[1])
+		v := int64(int32(d.fixed(4)))
+		return strconv.FormatFloat(float64(v)/math.Pow10(scale), 'f', scale, 64)
+	case "Enum8":
+		v := int64(int8(d.fixed(1)))
+		for _, arg := range args {
+			if strings.HasSuffix(arg, " "+strconv.FormatInt(v, 10)) {
+				return strings.Trim(strings.Fields(arg)[0], "'")
+			}
+		}
+		d.t.Fatalf("enum value %d not declared", v)
+	case "Nullable":
+		if d.fixed(1) == 1 {
+			return nil
+		}
+		return d.value(args[0])
+	case "Array":
+		n, _ := binary.ReadUvarint(d.r)
+		out := []any{}
+		for i := uint64(0); i < n; i++ {
+			out = append(out, d.value(args[0]))
+		}
+		return out
+	case "Map":
+		n, _ := binary.ReadUvarint(d.r)
+		out := map[any]any{}
+		for i := uint64(0); i < n; i++ {
+			k := d.value(args[0])
+			out[k] = d.value(args[1])
+		}
+		return out
+	}
+	d.t.Fatalf("decoder lacks type %s", typ)
+	return nil
+}
+
+func decodeRowBinary(t *testing.T, cols []Column, payload []byte) []map[string]any {
+	t.Helper()
+	d := rbReader{t: t, r: bytes.NewReader(payload)}
+	var rows []map[string]any
+	for d.r.Len() > 0 {
+		row := map[string]any{}
+		for _, c := range cols {
+			row[c.Name] = d.value(c.Type)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestRowBinary_RoundTrip(t *testing.T) {
+	usd := "12.5"
+	type logRow struct {
+		EventUID    string `json:"event_uid"`
+		LogIndex    uint32 `json:"log_index"`
+		BlockNumber uint64 `json:"block_number"`
+		TS          string `json:"ts"`
+	}
+	rows := []any{
+		map[string]any{
+			"event_uid":       "0xabc:1",
+			"log_index":       uint32(7),
+			"topics":          []string{"0xddf2", "0x01"},
+			"hashed_topics":   map[uint8]string{3: "0xfeed", 1: "0xbeef"},
+			"block_number":    uint64(18_000_000),
+			"ts":              "2023-11-14 22:13:20.123",
+			"unconfirmed":     uint8(1),
+			"finality":        "safe",
+			"source_provider": "alchemy",
+			"value_usd":       &usd,
+			"confidence":      "0.8765",
+			"embedding":       []float32{0.5, -1.25},
+			"tick":            -887272,
+		},
+		// A struct row omitting most columns: they take zero values, NULL
+		// and the first enum name.
+		logRow{EventUID: "0xdef:0", LogIndex: 0, BlockNumber: 1, TS: "1970-01-01 00:00:00.000"},
+	}
+	payload, err := mustCompile(t, logsColumns).encode(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decodeRowBinary(t, logsColumns, payload)
+	want := []map[string]any{
+		{
+			"event_uid": "0xabc:1", "log_index": uint64(7), "topics": []any{"0xddf2", "0x01"},
+			"hashed_topics": map[any]any{uint64(1): "0xbeef", uint64(3): "0xfeed"},
+			"block_number":  uint64(18_000_000), "ts": time.UnixMilli(1_700_000_000_123).UTC(),
+			"unconfirmed": uint64(1), "finality": "safe", "source_provider": "alchemy",
+			"value_usd": "12.5", "confidence": "0.8765", "embedding": []any{float32(0.5), float32(-1.25)},
+			"tick": int64(-887272),
+		},
+		{
+			"event_uid": "0xdef:0", "log_index": uint64(0), "topics": []any{},
+			"hashed_topics": map[any]any{}, "block_number": uint64(1), "ts": time.UnixMilli(0).UTC(),
+			"unconfirmed": uint64(0), "finality": "unknown", "source_provider": "",
+			"value_usd": nil, "confidence": "0.0000", "embedding": []any{}, "tick": int64(0),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func mustCompile(t *testing.T, cols []Column) *rowBinaryTable {
+	t.Helper()
+	tbl, err := compileColumns(cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tbl
+}
+
+func TestRowBinary_Errors(t *testing.T) {
+	for _, cols := range [][]Column{
+		nil,
+		{{"a", "UUID"}},
+		{{"a", "String"}, {"a", "String"}},
+		{{"a", "Array(String"}},
+		{{"a", "Decimal(38, 2)"}},
+		{{"a", "DateTime64(3, 'Mars/Olympus')"}},
+		{{"a", "Enum8(unknown = 0)"}},
+	} {
+		if _, err := compileColumns(cols); err == nil {
+			t.Fatalf("columns %v: expected error", cols)
+		}
+	}
+	tbl := mustCompile(t, []Column{{"n", "UInt8"}, {"f", "Enum8('a' = 1)"}, {"s", "FixedString(2)"}})
+	for _, row := range []map[string]any{
+		{"n": 256},
+		{"n": -1},
+		{"n": "x"},
+		{"f": "b"},
+		{"s": "abc"},
+		{"other": 1},
+	} {
+		if _, err := tbl.encode([]any{row}); err == nil {
+			t.Fatalf("row %v: expected error", row)
+		}
+	}
+}
+
+func TestInsertRowBinary_UsesRowBinaryFormat(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	var gotQuery, gotType string
+	var body []byte
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		gotQuery = r.URL.Query().Get("query")
+		gotType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	cols := []Column{{"tx_hash", "String"}, {"block_number", "UInt64"}}
+	if err := c.SetRowBinary("transactions", cols); err != nil {
+		t.Fatal(err)
+	}
+	rows := []any{map[string]any{"tx_hash": "0x1", "block_number": uint64(5)}}
+	if err := c.InsertJSONEachRow(context.Background(), "transactions", rows); err != nil {
+		t.Fatal(err)
+	}
+	if gotQuery != "INSERT INTO transactions (tx_hash, block_number) FORMAT RowBinary" || gotType != "application/octet-stream" {
+		t.Fatalf("query=%q content-type=%q", gotQuery, gotType)
+	}
+	if want := []byte("\x030x1\x05\x00\x00\x00\x00\x00\x00\x00"); !bytes.Equal(body, want) {
+		t.Fatalf("payload = %q, want %q", body, want)
+	}
+	// Other tables keep JSONEachRow.
+	if err := c.InsertJSONEachRow(context.Background(), "logs", rows); err != nil {
+		t.Fatal(err)
+	}
+	if gotQuery != "INSERT INTO logs FORMAT JSONEachRow" || gotType != "application/json" {
+		t.Fatalf("query=%q content-type=%q", gotQuery, gotType)
+	}
+	if err := c.InsertRowBinary(context.Background(), "logs", []Column{{"x", "Int128"}}, rows); err == nil {
+		t.Fatal("expected unsupported type error")
+	}
+	if err := c.SetRowBinary("logs", []Column{{"x", "Int128"}}); err == nil {
+		t.Fatal("expected unsupported type error")
+	}
+}
+
+func TestInsertRowBinary_DescribesTableOnce(t *testing.T) {
+	c := New("http://localhost:8123/db")
+	describes := 0
+	var inserts []string
+	c.hc = &http.Client{Transport: rtFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query().Get("query")
+		if strings.HasPrefix(q, "DESCRIBE TABLE logs") {
+			describes++
+			body := `{"name":"tx_hash","type":"String","default_type":""}` + "\n" +
+				`{"name":"ts","type":"DateTime64(3, 'UTC')","default_type":"DEFAULT"}` + "\n" +
+				`{"name":"day","type":"Date","default_type":"MATERIALIZED"}` + "\n"
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(body))}, nil
+		}
+		inserts = append(inserts, q)
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	if err := c.SetRowBinary("logs", nil); err != nil {
+		t.Fatal(err)
+	}
+	rows := []any{map[string]any{"tx_hash": "0x1", "ts": "2024-01-01 00:00:00.000"}}
+	for n := 0; n < 2; n++ {
+		if err := c.InsertJSONEachRow(context.Background(), "logs", rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if describes != 1 || len(inserts) != 2 || inserts[0] != "INSERT INTO logs (tx_hash, ts) FORMAT RowBinary" {
+		t.Fatalf("describes=%d inserts=%q", describes, inserts)
+	}
+}
@@ -0,0 +1,63 @@
+package clock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFake_AdvanceFiresDueWaiters(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	short := f.After(time.Second)
+	long := f.After(time.Minute)
+	select {
+	case <-short:
+		t.Fatal("fired before Advance")
+	default:
+	}
+	f.Advance(time.Second)
+	if got := <-short; !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("fired at %v", got)
+	}
+	select {
+	case <-long:
+		t.Fatal("long waiter fired early")
+	default:
+	}
+	f.Advance(time.Minute)
+	<-long
+	if !f.Now().Equal(start.Add(time.Minute + time.Second)) {
+		t.Fatalf("now = %v", f.Now())
+	}
+	<-f.After(0)
+	if want := []time.Duration{time.Second, time.Minute, 0}; !reflect.DeepEqual(f.Waits(), want) {
+		t.Fatalf("waits = %v, want %v", f.Waits(), want)
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(time.Time{})
+	done := make(chan struct{})
+	go func() {
+		<-f.After(time.Hour)
+		close(done)
+	}()
+	f.BlockUntil(1)
+	f.Advance(time.Hour)
+	<-done
+}
+
+func TestOr(t *testing.T) {
+	if Or(nil) != Real {
+		t.Fatal("nil must fall back to Real")
+	}
+	f := NewFake(time.Time{})
+	if Or(f) != f {
+		t.Fatal("non-nil clock must be kept")
+	}
+	if d := time.Since(Real.Now()); d < 0 || d > time.Minute {
+		t.Fatalf("real clock off by %v", d)
+	}
+	<-Real.After(0)
+}
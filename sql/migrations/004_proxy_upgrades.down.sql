@@ -0,0 +1,2 @@
+-- v4 down: drop proxy upgrade events
+DROP TABLE IF EXISTS proxy_upgrades;
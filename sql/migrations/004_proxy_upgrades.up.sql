@@ -0,0 +1,18 @@
+-- v4 up: track EIP-1967 Upgraded(address) events emitted by proxy contracts
+CREATE TABLE IF NOT EXISTS proxy_upgrades (
+  event_uid String,
+  tx_hash String,
+  log_index UInt32,
+  proxy String,
+  implementation String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_proxy_upgrades_proxy proxy TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_proxy_upgrades_impl implementation TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_proxy_upgrades_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT proxy_upgrades_proxy_chk CHECK match(proxy, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT proxy_upgrades_impl_chk CHECK match(implementation, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, log_index)
+SETTINGS index_granularity = 4096;
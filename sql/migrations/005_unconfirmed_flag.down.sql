@@ -0,0 +1,7 @@
+-- v5 down: drop unconfirmed flag columns
+ALTER TABLE logs DROP COLUMN IF EXISTS unconfirmed;
+ALTER TABLE traces DROP COLUMN IF EXISTS unconfirmed;
+ALTER TABLE transactions DROP COLUMN IF EXISTS unconfirmed;
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS unconfirmed;
+ALTER TABLE approvals DROP COLUMN IF EXISTS unconfirmed;
+ALTER TABLE proxy_upgrades DROP COLUMN IF EXISTS unconfirmed;
@@ -0,0 +1,7 @@
+-- v5 up: flag rows ingested above the safe head (IncludeUnconfirmed)
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS unconfirmed UInt8 DEFAULT 0 BEFORE ingested_at;
+ALTER TABLE traces ADD COLUMN IF NOT EXISTS unconfirmed UInt8 DEFAULT 0 BEFORE ingested_at;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS unconfirmed UInt8 DEFAULT 0 BEFORE ingested_at;
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS unconfirmed UInt8 DEFAULT 0 BEFORE ingested_at;
+ALTER TABLE approvals ADD COLUMN IF NOT EXISTS unconfirmed UInt8 DEFAULT 0 BEFORE ingested_at;
+ALTER TABLE proxy_upgrades ADD COLUMN IF NOT EXISTS unconfirmed UInt8 DEFAULT 0 BEFORE ingested_at;
@@ -0,0 +1,5 @@
+-- v6 down: drop mint/burn flags from token transfer tables
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS is_burn;
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS is_mint;
+ALTER TABLE dev_token_transfers DROP COLUMN IF EXISTS is_burn;
+ALTER TABLE dev_token_transfers DROP COLUMN IF EXISTS is_mint;
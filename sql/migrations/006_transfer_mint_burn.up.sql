@@ -0,0 +1,12 @@
+-- v6 up: flag token transfers from (mint) or to (burn) the zero address
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS is_mint UInt8 DEFAULT 0 AFTER standard;
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS is_burn UInt8 DEFAULT 0 AFTER is_mint;
+ALTER TABLE dev_token_transfers ADD COLUMN IF NOT EXISTS is_mint UInt8 DEFAULT 0 AFTER standard;
+ALTER TABLE dev_token_transfers ADD COLUMN IF NOT EXISTS is_burn UInt8 DEFAULT 0 AFTER is_mint;
+
+-- Backfill flags on rows ingested before this migration.
+ALTER TABLE token_transfers
+    UPDATE is_mint = from_addr = '0x0000000000000000000000000000000000000000',
+           is_burn = to_addr = '0x0000000000000000000000000000000000000000'
+WHERE is_mint = 0 AND is_burn = 0
+SETTINGS mutations_sync = 1;
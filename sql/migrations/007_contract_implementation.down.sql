@@ -0,0 +1,2 @@
+-- v7 down: drop resolved proxy implementation
+ALTER TABLE contracts DROP COLUMN IF EXISTS implementation;
@@ -0,0 +1,2 @@
+-- v7 up: EIP-1967 implementation address resolved for proxy contracts
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS implementation String DEFAULT '' AFTER first_seen_block;
@@ -0,0 +1,2 @@
+-- v8 down: drop balance reconciliation results
+DROP TABLE IF EXISTS balance_reconciliations;
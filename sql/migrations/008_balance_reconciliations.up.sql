@@ -0,0 +1,20 @@
+-- v8 up: per-range balance reconciliation results (net value flow vs eth_getBalance delta)
+CREATE TABLE IF NOT EXISTS balance_reconciliations (
+  address String,
+  from_block UInt64,
+  to_block UInt64,
+  balance_before String,
+  balance_after String,
+  inflow_wei String,
+  outflow_wei String,
+  fees_wei String,
+  discrepancy_wei String,
+  matched UInt8,
+  traces_included UInt8,
+  unconfirmed UInt8 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_reconciliations_addr address TYPE bloom_filter GRANULARITY 2,
+  CONSTRAINT reconciliations_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, from_block, to_block)
+SETTINGS index_granularity = 2048;
@@ -0,0 +1,2 @@
+-- v9 down: drop run locks
+DROP TABLE IF EXISTS run_locks;
@@ -0,0 +1,12 @@
+-- v9 up: advisory per-address run locks (--run-lock)
+CREATE TABLE IF NOT EXISTS run_locks (
+  address String,
+  owner String,
+  acquired_at DateTime64(3, 'UTC'),
+  heartbeat_at DateTime64(3, 'UTC'),
+  expires_at DateTime64(3, 'UTC'),
+  released UInt8 DEFAULT 0,
+  CONSTRAINT run_locks_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(heartbeat_at)
+ORDER BY (address, owner)
+TTL toDateTime(expires_at) + INTERVAL 1 DAY;
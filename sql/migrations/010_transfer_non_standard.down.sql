@@ -0,0 +1,3 @@
+-- v10 down: drop the non-standard transfer flag
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS non_standard;
+ALTER TABLE dev_token_transfers DROP COLUMN IF EXISTS non_standard;
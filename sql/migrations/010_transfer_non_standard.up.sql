@@ -0,0 +1,3 @@
+-- v10 up: flag ERC-20 transfers whose amount was read from topics[3] (--indexed-amount-tokens)
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS non_standard UInt8 DEFAULT 0 AFTER is_burn;
+ALTER TABLE dev_token_transfers ADD COLUMN IF NOT EXISTS non_standard UInt8 DEFAULT 0 AFTER is_burn;
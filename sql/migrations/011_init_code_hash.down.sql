@@ -0,0 +1,3 @@
+-- v11 down: drop the init code hash
+ALTER TABLE transactions DROP COLUMN IF EXISTS init_code_hash;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS init_code_hash;
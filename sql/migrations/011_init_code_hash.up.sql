@@ -0,0 +1,3 @@
+-- v11 up: keccak256 of contract-creation init code (input_method = 'create')
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS init_code_hash Nullable(String) AFTER input_method;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS init_code_hash String DEFAULT '' AFTER input_method;
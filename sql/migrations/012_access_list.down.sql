@@ -0,0 +1,5 @@
+-- v12 down: drop the access list size
+ALTER TABLE transactions DROP COLUMN IF EXISTS access_list_storage_keys;
+ALTER TABLE transactions DROP COLUMN IF EXISTS access_list_count;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS access_list_storage_keys;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS access_list_count;
@@ -0,0 +1,5 @@
+-- v12 up: EIP-2930 access list size of typed transactions
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS access_list_count UInt32 DEFAULT 0 AFTER init_code_hash;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS access_list_storage_keys UInt32 DEFAULT 0 AFTER access_list_count;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS access_list_count UInt32 DEFAULT 0 AFTER init_code_hash;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS access_list_storage_keys UInt32 DEFAULT 0 AFTER access_list_count;
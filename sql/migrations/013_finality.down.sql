@@ -0,0 +1,7 @@
+-- v13 down: drop finality columns
+ALTER TABLE logs DROP COLUMN IF EXISTS finality;
+ALTER TABLE traces DROP COLUMN IF EXISTS finality;
+ALTER TABLE transactions DROP COLUMN IF EXISTS finality;
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS finality;
+ALTER TABLE approvals DROP COLUMN IF EXISTS finality;
+ALTER TABLE proxy_upgrades DROP COLUMN IF EXISTS finality;
@@ -0,0 +1,7 @@
+-- v13 up: finality of the block each row came from (--track-finality)
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown' AFTER unconfirmed;
+ALTER TABLE traces ADD COLUMN IF NOT EXISTS finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown' AFTER unconfirmed;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown' AFTER unconfirmed;
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown' AFTER unconfirmed;
+ALTER TABLE approvals ADD COLUMN IF NOT EXISTS finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown' AFTER unconfirmed;
+ALTER TABLE proxy_upgrades ADD COLUMN IF NOT EXISTS finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown' AFTER unconfirmed;
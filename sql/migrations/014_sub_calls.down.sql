@@ -0,0 +1,2 @@
+-- v14 down: drop sub_calls
+DROP TABLE IF EXISTS sub_calls;
@@ -0,0 +1,25 @@
+-- v14 up: inner calls of Multicall3 batches
+CREATE TABLE IF NOT EXISTS sub_calls (
+  tx_hash String,
+  call_index String,
+  depth UInt8,
+  from_addr String,
+  multicall String,
+  target String,
+  input_method Nullable(String),
+  allow_failure UInt8,
+  value_raw String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_sub_calls_from from_addr TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_sub_calls_target target TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_sub_calls_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT sub_calls_from_chk CHECK match(from_addr, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT sub_calls_multicall_chk CHECK match(multicall, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT sub_calls_target_chk CHECK match(target, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, call_index)
+SETTINGS index_granularity = 4096;
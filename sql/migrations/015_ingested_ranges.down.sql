@@ -0,0 +1,2 @@
+-- v15 down: drop the coverage ledger
+DROP TABLE IF EXISTS ingested_ranges;
@@ -0,0 +1,9 @@
+-- v15 up: block intervals ingested above an address's checkpoint (coverage ledger)
+CREATE TABLE IF NOT EXISTS ingested_ranges (
+  address String,
+  from_block UInt64,
+  to_block UInt64,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT ingested_ranges_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, from_block);
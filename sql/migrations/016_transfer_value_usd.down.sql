@@ -0,0 +1,3 @@
+-- v16 down: drop the transfer USD value
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS value_usd;
+ALTER TABLE dev_token_transfers DROP COLUMN IF EXISTS value_usd;
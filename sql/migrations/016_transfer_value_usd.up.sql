@@ -0,0 +1,3 @@
+-- v16 up: approximate USD value of each transfer at its block (Options.PriceFeed)
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS value_usd Nullable(String) AFTER non_standard;
+ALTER TABLE dev_token_transfers ADD COLUMN IF NOT EXISTS value_usd Nullable(String) AFTER ts_millis;
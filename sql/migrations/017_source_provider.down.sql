@@ -0,0 +1,9 @@
+-- v17 down: drop source_provider columns
+ALTER TABLE logs DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE traces DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE transactions DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE sub_calls DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE approvals DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE proxy_upgrades DROP COLUMN IF EXISTS source_provider;
+ALTER TABLE contracts DROP COLUMN IF EXISTS source_provider;
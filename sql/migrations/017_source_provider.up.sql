@@ -0,0 +1,9 @@
+-- v17 up: endpoint that served each row (--record-provider-source)
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE traces ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE sub_calls ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE approvals ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE proxy_upgrades ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER finality;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS source_provider LowCardinality(String) DEFAULT '' AFTER implementation;
@@ -0,0 +1,3 @@
+-- v18 down: drop the missing receipt flag
+ALTER TABLE transactions DROP COLUMN IF EXISTS receipt_missing;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS receipt_missing;
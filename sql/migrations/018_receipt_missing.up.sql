@@ -0,0 +1,3 @@
+-- v18 up: transactions stored without their receipt (--missing-receipts emit)
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS receipt_missing UInt8 DEFAULT 0 AFTER status;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS receipt_missing UInt8 DEFAULT 0 AFTER status;
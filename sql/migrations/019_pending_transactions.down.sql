@@ -0,0 +1,2 @@
+-- v19 down: drop the pending transactions table
+DROP TABLE IF EXISTS pending_transactions;
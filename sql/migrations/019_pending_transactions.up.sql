@@ -0,0 +1,16 @@
+-- v19 up: mempool transactions touching an address (--track-pending)
+CREATE TABLE IF NOT EXISTS pending_transactions (
+  address String,
+  tx_hash String,
+  from_addr String,
+  to_addr String,
+  value_raw String,
+  input_method String,
+  nonce UInt64,
+  pending UInt8,
+  mined_block UInt64 DEFAULT 0,
+  first_seen DateTime64(3, 'UTC'),
+  updated_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT pending_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (address, tx_hash);
@@ -0,0 +1,3 @@
+-- v20 down: drop the input classification
+ALTER TABLE transactions DROP COLUMN IF EXISTS input_kind;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS input_kind;
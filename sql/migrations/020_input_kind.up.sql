@@ -0,0 +1,3 @@
+-- v20 up: finer input classification (empty | create | known:<method> | unknown:<selector>)
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS input_kind String DEFAULT '' AFTER input_method;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS input_kind String DEFAULT '' AFTER input_method;
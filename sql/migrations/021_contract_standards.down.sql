@@ -0,0 +1,2 @@
+-- v21 down: drop the contract standards cache
+DROP TABLE IF EXISTS contract_standards;
@@ -0,0 +1,9 @@
+-- v21 up: per-contract token standard cache (--cache-standards)
+CREATE TABLE IF NOT EXISTS contract_standards (
+  contract String,
+  standard LowCardinality(String),
+  first_block UInt64,
+  updated_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT standards_contract_chk CHECK match(contract, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY contract;
@@ -0,0 +1,2 @@
+-- v22 down: drop the undecoded events audit table
+DROP TABLE IF EXISTS undecoded_events;
@@ -0,0 +1,12 @@
+-- v22 up: per-run counts of logs no decoder recognised (--audit-undecoded)
+CREATE TABLE IF NOT EXISTS undecoded_events (
+  address String,
+  contract String,
+  topic0 String,
+  count UInt64,
+  first_block UInt64,
+  last_block UInt64,
+  observed_at DateTime64(3, 'UTC'),
+  CONSTRAINT undecoded_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = MergeTree
+ORDER BY (topic0, contract, address, observed_at);
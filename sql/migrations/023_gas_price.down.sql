@@ -0,0 +1,3 @@
+-- v23 down: drop the gas price column
+ALTER TABLE transactions DROP COLUMN IF EXISTS gas_price_raw;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS gas_price_raw;
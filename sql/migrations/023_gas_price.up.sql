@@ -0,0 +1,3 @@
+-- v23 up: price paid per gas (legacy gasPrice, else effectiveGasPrice) in decimal wei
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS gas_price_raw String DEFAULT '' AFTER gas_used;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS gas_price_raw String DEFAULT '' AFTER gas_used;
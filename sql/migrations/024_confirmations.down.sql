@@ -0,0 +1,8 @@
+-- v24 down: drop confirmations columns
+ALTER TABLE logs DROP COLUMN IF EXISTS confirmations;
+ALTER TABLE traces DROP COLUMN IF EXISTS confirmations;
+ALTER TABLE transactions DROP COLUMN IF EXISTS confirmations;
+ALTER TABLE sub_calls DROP COLUMN IF EXISTS confirmations;
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS confirmations;
+ALTER TABLE approvals DROP COLUMN IF EXISTS confirmations;
+ALTER TABLE proxy_upgrades DROP COLUMN IF EXISTS confirmations;
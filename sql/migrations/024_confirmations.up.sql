@@ -0,0 +1,8 @@
+-- v24 up: blocks between each row's block and the chain head seen when its run started
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
+ALTER TABLE traces ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
+ALTER TABLE sub_calls ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
+ALTER TABLE approvals ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
+ALTER TABLE proxy_upgrades ADD COLUMN IF NOT EXISTS confirmations UInt64 DEFAULT 0 AFTER source_provider;
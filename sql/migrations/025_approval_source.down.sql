@@ -0,0 +1,2 @@
+-- v25 down: drop the approval source column
+ALTER TABLE approvals DROP COLUMN IF EXISTS source;
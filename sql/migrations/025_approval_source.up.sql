@@ -0,0 +1,2 @@
+-- v25 up: where an approval came from: an Approval log or EIP-2612 permit calldata
+ALTER TABLE approvals ADD COLUMN IF NOT EXISTS source LowCardinality(String) DEFAULT 'event' AFTER standard;
@@ -0,0 +1,2 @@
+-- v26 down: drop range hashes
+DROP TABLE IF EXISTS range_hashes;
@@ -0,0 +1,12 @@
+-- v26 up: per-range content hashes of canonical rows (--hash-ranges)
+CREATE TABLE IF NOT EXISTS range_hashes (
+  address String,
+  from_block UInt64,
+  to_block UInt64,
+  hash String,
+  row_count UInt64,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT range_hashes_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, from_block, to_block)
+SETTINGS index_granularity = 2048;
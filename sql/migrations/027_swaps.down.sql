@@ -0,0 +1,2 @@
+-- v27 down: drop swaps
+DROP TABLE IF EXISTS swaps;
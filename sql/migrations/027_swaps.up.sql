@@ -0,0 +1,31 @@
+-- v27 up: Uniswap V2/V3 pool Swap events; amounts are signed pool balance changes
+CREATE TABLE IF NOT EXISTS swaps (
+  event_uid String,
+  tx_hash String,
+  log_index UInt32,
+  pool String,
+  version LowCardinality(String),
+  sender String,
+  recipient String,
+  amount0 String,
+  amount1 String,
+  sqrt_price_x96 String,
+  liquidity String,
+  tick Int32,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_swaps_pool pool TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_swaps_sender sender TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_swaps_recipient recipient TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_swaps_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT swaps_pool_chk CHECK match(pool, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT swaps_sender_chk CHECK match(sender, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT swaps_recipient_chk CHECK match(recipient, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, log_index)
+SETTINGS index_granularity = 4096;
@@ -0,0 +1,5 @@
+-- v28 down: drop the precompile flags
+ALTER TABLE transactions DROP COLUMN IF EXISTS is_precompile;
+ALTER TABLE traces DROP COLUMN IF EXISTS is_precompile;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS is_precompile;
+ALTER TABLE dev_traces DROP COLUMN IF EXISTS is_precompile;
@@ -0,0 +1,5 @@
+-- v28 up: flag transactions and traces sent to a precompiled contract (0x01-0x09)
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS is_precompile UInt8 DEFAULT 0 AFTER is_internal;
+ALTER TABLE traces ADD COLUMN IF NOT EXISTS is_precompile UInt8 DEFAULT 0 AFTER value_raw;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS is_precompile UInt8 DEFAULT 0 AFTER is_internal;
+ALTER TABLE dev_traces ADD COLUMN IF NOT EXISTS is_precompile UInt8 DEFAULT 0 AFTER value_raw;
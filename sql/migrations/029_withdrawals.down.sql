@@ -0,0 +1,2 @@
+-- v29 down: drop withdrawals
+DROP TABLE IF EXISTS withdrawals;
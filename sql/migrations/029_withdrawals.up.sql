@@ -0,0 +1,20 @@
+-- v29 up: EIP-4895 withdrawals paid to an address (validator payouts)
+CREATE TABLE IF NOT EXISTS withdrawals (
+  withdrawal_index UInt64,
+  validator_index UInt64,
+  address String,
+  amount_gwei String,
+  amount_raw String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_withdrawals_address address TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_withdrawals_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT withdrawals_address_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (withdrawal_index)
+SETTINGS index_granularity = 4096;
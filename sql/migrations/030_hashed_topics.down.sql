@@ -0,0 +1,3 @@
+-- v30 down: drop the hashed topic maps
+ALTER TABLE logs DROP COLUMN IF EXISTS hashed_topics;
+ALTER TABLE dev_logs DROP COLUMN IF EXISTS hashed_topics;
@@ -0,0 +1,3 @@
+-- v30 up: keep hashes of indexed string/bytes/array/tuple event parameters by topic position
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS hashed_topics Map(UInt8, String) AFTER topics;
+ALTER TABLE dev_logs ADD COLUMN IF NOT EXISTS hashed_topics Map(UInt8, String) AFTER topics;
@@ -0,0 +1,3 @@
+-- v31 down: drop the created contract column
+ALTER TABLE transactions DROP COLUMN IF EXISTS created_contract;
+ALTER TABLE dev_transactions DROP COLUMN IF EXISTS created_contract;
@@ -0,0 +1,3 @@
+-- v31 up: record the contract an external creation transaction deployed
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS created_contract String DEFAULT '' AFTER init_code_hash;
+ALTER TABLE dev_transactions ADD COLUMN IF NOT EXISTS created_contract String DEFAULT '' AFTER init_code_hash;
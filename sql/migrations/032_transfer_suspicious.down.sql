@@ -0,0 +1,3 @@
+-- v32 down: drop the suspicious transfer flag
+ALTER TABLE token_transfers DROP COLUMN IF EXISTS suspicious;
+ALTER TABLE dev_token_transfers DROP COLUMN IF EXISTS suspicious;
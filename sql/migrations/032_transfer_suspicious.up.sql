@@ -0,0 +1,3 @@
+-- v32 up: flag transfers of tokens failing the honeypot probe
+ALTER TABLE token_transfers ADD COLUMN IF NOT EXISTS suspicious UInt8 DEFAULT 0 AFTER non_standard;
+ALTER TABLE dev_token_transfers ADD COLUMN IF NOT EXISTS suspicious UInt8 DEFAULT 0 AFTER non_standard;
@@ -0,0 +1,2 @@
+-- v33 down: drop vault_events
+DROP TABLE IF EXISTS vault_events;
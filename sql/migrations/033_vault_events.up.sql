@@ -0,0 +1,31 @@
+-- v33 up: ERC-4626 vault Deposit/Withdraw events with assets and shares per action
+CREATE TABLE IF NOT EXISTS vault_events (
+  event_uid String,
+  tx_hash String,
+  log_index UInt32,
+  vault String,
+  action LowCardinality(String),
+  sender String,
+  owner String,
+  receiver String,
+  assets String,
+  shares String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_vault_events_vault vault TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_sender sender TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_owner owner TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_receiver receiver TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT vault_events_vault_chk CHECK match(vault, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT vault_events_sender_chk CHECK match(sender, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT vault_events_owner_chk CHECK match(owner, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT vault_events_receiver_chk CHECK receiver = '' OR match(receiver, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, log_index)
+SETTINGS index_granularity = 4096;
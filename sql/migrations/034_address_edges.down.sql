@@ -0,0 +1,2 @@
+-- v34 down: drop address_edges
+DROP TABLE IF EXISTS address_edges;
@@ -0,0 +1,18 @@
+-- v34 up: per-block interaction edges of an address (--address-edges)
+CREATE TABLE IF NOT EXISTS address_edges (
+  address String,
+  src String,
+  dst String,
+  asset String,
+  block_number UInt64,
+  count UInt64,
+  value_sum String,
+  unconfirmed UInt8 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_edges_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT edges_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT edges_src_chk CHECK match(src, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT edges_dst_chk CHECK match(dst, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, src, dst, asset, block_number)
+SETTINGS index_granularity = 4096;
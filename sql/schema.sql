@@ -8,9 +8,14 @@ CREATE TABLE IF NOT EXISTS logs (
   log_index UInt32,
   address String,
   topics Array(String),
+  hashed_topics Map(UInt8, String),
   data_hex String,
   block_number UInt64,
   ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
   ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
   -- Data skipping indexes for common filters (ClickHouse requires these inside column list)
   INDEX idx_logs_address address TYPE bloom_filter GRANULARITY 2,
@@ -28,8 +33,13 @@ CREATE TABLE IF NOT EXISTS traces (
   from_addr String,
   to_addr String,
   value_raw String,
+  is_precompile UInt8 DEFAULT 0,
   block_number UInt64,
   ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
   ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
   INDEX idx_traces_from from_addr TYPE bloom_filter GRANULARITY 2,
   INDEX idx_traces_to to_addr TYPE bloom_filter GRANULARITY 2,
@@ -49,10 +59,22 @@ CREATE TABLE IF NOT EXISTS transactions (
   to_addr String,
   value_raw String,
   gas_used UInt64,
+  gas_price_raw String DEFAULT '',
   status UInt8,
+  receipt_missing UInt8 DEFAULT 0,
   input_method Nullable(String),
+  input_kind String DEFAULT '',
+  init_code_hash Nullable(String),
+  created_contract String DEFAULT '',
+  access_list_count UInt32 DEFAULT 0,
+  access_list_storage_keys UInt32 DEFAULT 0,
   is_internal UInt8,
+  is_precompile UInt8 DEFAULT 0,
   trace_id Nullable(String),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
   ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
   INDEX idx_tx_from from_addr TYPE bloom_filter GRANULARITY 2,
   INDEX idx_tx_to to_addr TYPE bloom_filter GRANULARITY 2,
@@ -63,6 +85,34 @@ CREATE TABLE IF NOT EXISTS transactions (
 ORDER BY (tx_hash, is_internal, ifNull(trace_id, ''))
 SETTINGS index_granularity = 4096;
 
+-- Inner calls of Multicall3 batches sent by or to tracked addresses
+CREATE TABLE IF NOT EXISTS sub_calls (
+  tx_hash String,
+  call_index String,
+  depth UInt8,
+  from_addr String,
+  multicall String,
+  target String,
+  input_method Nullable(String),
+  allow_failure UInt8,
+  value_raw String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_sub_calls_from from_addr TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_sub_calls_target target TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_sub_calls_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT sub_calls_from_chk CHECK match(from_addr, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT sub_calls_multicall_chk CHECK match(multicall, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT sub_calls_target_chk CHECK match(target, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, call_index)
+SETTINGS index_granularity = 4096;
+
 -- Token transfers (ERC-20/721/1155)
 CREATE TABLE IF NOT EXISTS token_transfers (
   event_uid String,
@@ -75,8 +125,17 @@ CREATE TABLE IF NOT EXISTS token_transfers (
   token_id String,
   batch_ordinal UInt16 DEFAULT 0,
   standard LowCardinality(String),
+  is_mint UInt8 DEFAULT 0,
+  is_burn UInt8 DEFAULT 0,
+  non_standard UInt8 DEFAULT 0,
+  suspicious UInt8 DEFAULT 0,
+  value_usd Nullable(String),
   block_number UInt64,
   ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
   ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
   INDEX idx_tok_xfer_token token TYPE bloom_filter GRANULARITY 2,
   INDEX idx_tok_xfer_from from_addr TYPE bloom_filter GRANULARITY 2,
@@ -101,8 +160,13 @@ CREATE TABLE IF NOT EXISTS approvals (
   token_id String,
   is_approval_for_all UInt8,
   standard LowCardinality(String),
+  source LowCardinality(String) DEFAULT 'event',
   block_number UInt64,
   ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
   ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
   INDEX idx_approvals_token token TYPE bloom_filter GRANULARITY 2,
   INDEX idx_approvals_owner owner TYPE bloom_filter GRANULARITY 2,
@@ -115,6 +179,224 @@ CREATE TABLE IF NOT EXISTS approvals (
 ORDER BY (tx_hash, log_index)
 SETTINGS index_granularity = 4096;
 
+-- Proxy implementation upgrades (EIP-1967 Upgraded events)
+CREATE TABLE IF NOT EXISTS proxy_upgrades (
+  event_uid String,
+  tx_hash String,
+  log_index UInt32,
+  proxy String,
+  implementation String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_proxy_upgrades_proxy proxy TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_proxy_upgrades_impl implementation TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_proxy_upgrades_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT proxy_upgrades_proxy_chk CHECK match(proxy, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT proxy_upgrades_impl_chk CHECK match(implementation, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, log_index)
+SETTINGS index_granularity = 4096;
+
+-- Uniswap V2/V3 Swap events; amount0/amount1 are signed pool balance changes
+CREATE TABLE IF NOT EXISTS swaps (
+  event_uid String,
+  tx_hash String,
+  log_index UInt32,
+  pool String,
+  version LowCardinality(String),
+  sender String,
+  recipient String,
+  amount0 String,
+  amount1 String,
+  sqrt_price_x96 String,
+  liquidity String,
+  tick Int32,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_swaps_pool pool TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_swaps_sender sender TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_swaps_recipient recipient TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_swaps_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT swaps_pool_chk CHECK match(pool, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT swaps_sender_chk CHECK match(sender, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT swaps_recipient_chk CHECK match(recipient, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, log_index)
+SETTINGS index_granularity = 4096;
+
+-- ERC-4626 vault Deposit/Withdraw events; receiver is empty for deposits
+CREATE TABLE IF NOT EXISTS vault_events (
+  event_uid String,
+  tx_hash String,
+  log_index UInt32,
+  vault String,
+  action LowCardinality(String),
+  sender String,
+  owner String,
+  receiver String,
+  assets String,
+  shares String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_vault_events_vault vault TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_sender sender TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_owner owner TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_receiver receiver TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_vault_events_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT vault_events_vault_chk CHECK match(vault, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT vault_events_sender_chk CHECK match(sender, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT vault_events_owner_chk CHECK match(owner, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT vault_events_receiver_chk CHECK receiver = '' OR match(receiver, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (tx_hash, log_index)
+SETTINGS index_granularity = 4096;
+
+-- EIP-4895 consensus-layer withdrawals paid to the address; amount_raw is in wei
+CREATE TABLE IF NOT EXISTS withdrawals (
+  withdrawal_index UInt64,
+  validator_index UInt64,
+  address String,
+  amount_gwei String,
+  amount_raw String,
+  block_number UInt64,
+  ts DateTime64(3, 'UTC'),
+  unconfirmed UInt8 DEFAULT 0,
+  finality Enum8('unknown' = 0, 'latest' = 1, 'safe' = 2, 'finalized' = 3) DEFAULT 'unknown',
+  source_provider LowCardinality(String) DEFAULT '',
+  confirmations UInt64 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_withdrawals_address address TYPE bloom_filter GRANULARITY 2,
+  INDEX idx_withdrawals_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT withdrawals_address_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (withdrawal_index)
+SETTINGS index_granularity = 4096;
+
+-- Balance reconciliation per ingested range (--reconcile)
+CREATE TABLE IF NOT EXISTS balance_reconciliations (
+  address String,
+  from_block UInt64,
+  to_block UInt64,
+  balance_before String,
+  balance_after String,
+  inflow_wei String,
+  outflow_wei String,
+  fees_wei String,
+  discrepancy_wei String,
+  matched UInt8,
+  traces_included UInt8,
+  unconfirmed UInt8 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_reconciliations_addr address TYPE bloom_filter GRANULARITY 2,
+  CONSTRAINT reconciliations_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, from_block, to_block)
+SETTINGS index_granularity = 2048;
+
+-- Per-block directed edges between an address and its counterparties (--address-edges);
+-- asset is 'eth' or the token contract, value_sum a decimal string
+CREATE TABLE IF NOT EXISTS address_edges (
+  address String,
+  src String,
+  dst String,
+  asset String,
+  block_number UInt64,
+  count UInt64,
+  value_sum String,
+  unconfirmed UInt8 DEFAULT 0,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  INDEX idx_edges_block block_number TYPE minmax GRANULARITY 1,
+  CONSTRAINT edges_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT edges_src_chk CHECK match(src, '^0x[0-9a-fA-F]{40}$'),
+  CONSTRAINT edges_dst_chk CHECK match(dst, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, src, dst, asset, block_number)
+SETTINGS index_granularity = 4096;
+
+-- Mempool transactions touching an address (--track-pending); pending = 0 once
+-- they leave the mempool, with mined_block 0 when dropped or replaced
+CREATE TABLE IF NOT EXISTS pending_transactions (
+  address String,
+  tx_hash String,
+  from_addr String,
+  to_addr String,
+  value_raw String,
+  input_method String,
+  nonce UInt64,
+  pending UInt8,
+  mined_block UInt64 DEFAULT 0,
+  first_seen DateTime64(3, 'UTC'),
+  updated_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT pending_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY (address, tx_hash);
+
+-- Token standard each contract was classified as (--cache-standards)
+CREATE TABLE IF NOT EXISTS contract_standards (
+  contract String,
+  standard LowCardinality(String),
+  first_block UInt64,
+  updated_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT standards_contract_chk CHECK match(contract, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(updated_at)
+ORDER BY contract;
+
+-- Per-run counts of logs matching no known event (--audit-undecoded); sum
+-- count by topic0 to find event types worth a decoder
+CREATE TABLE IF NOT EXISTS undecoded_events (
+  address String,
+  contract String,
+  topic0 String,
+  count UInt64,
+  first_block UInt64,
+  last_block UInt64,
+  observed_at DateTime64(3, 'UTC'),
+  CONSTRAINT undecoded_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = MergeTree
+ORDER BY (topic0, contract, address, observed_at);
+
+-- SHA-256 of each confirmed range's canonical rows (--hash-ranges); a new hash
+-- for the same range means its data changed since it was last ingested
+CREATE TABLE IF NOT EXISTS range_hashes (
+  address String,
+  from_block UInt64,
+  to_block UInt64,
+  hash String,
+  row_count UInt64,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT range_hashes_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, from_block, to_block)
+SETTINGS index_granularity = 2048;
+
+-- Advisory per-address run locks (--run-lock); rows expire a day after their lease
+CREATE TABLE IF NOT EXISTS run_locks (
+  address String,
+  owner String,
+  acquired_at DateTime64(3, 'UTC'),
+  heartbeat_at DateTime64(3, 'UTC'),
+  expires_at DateTime64(3, 'UTC'),
+  released UInt8 DEFAULT 0,
+  CONSTRAINT run_locks_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(heartbeat_at)
+ORDER BY (address, owner)
+TTL toDateTime(expires_at) + INTERVAL 1 DAY;
+
 -- Addresses sync checkpoints
 CREATE TABLE IF NOT EXISTS addresses (
   address String,
@@ -129,6 +411,17 @@ CREATE TABLE IF NOT EXISTS addresses (
 ORDER BY (address)
 SETTINGS index_granularity = 2048;
 
+-- Block intervals ingested in full above an address's checkpoint, left when a
+-- range came back with blocks missing; later runs refetch only the gaps
+CREATE TABLE IF NOT EXISTS ingested_ranges (
+  address String,
+  from_block UInt64,
+  to_block UInt64,
+  ingested_at DateTime64(3, 'UTC') DEFAULT now64(3),
+  CONSTRAINT ingested_ranges_addr_chk CHECK match(address, '^0x[0-9a-fA-F]{40}$')
+) ENGINE = ReplacingMergeTree(ingested_at)
+ORDER BY (address, from_block);
+
 -- Contracts registry and metadata
 CREATE TABLE IF NOT EXISTS contracts (
   address String,
@@ -138,6 +431,8 @@ CREATE TABLE IF NOT EXISTS contracts (
   decimals UInt16,
   created_at_tx String,
   first_seen_block UInt64,
+  implementation String DEFAULT '',
+  source_provider LowCardinality(String) DEFAULT '',
   probed_at DateTime64(3, 'UTC') DEFAULT now64(3),
   updated_at DateTime64(3, 'UTC') DEFAULT now64(3),
   INDEX idx_contracts_addr address TYPE bloom_filter GRANULARITY 2,
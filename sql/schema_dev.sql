@@ -5,6 +5,7 @@ CREATE TABLE IF NOT EXISTS dev_logs (
   log_index UInt32,
   address String,
   topics Array(String),
+  hashed_topics Map(UInt8, String),
   data_hex String,
   block_number UInt64,
   ts_millis Int64,
@@ -19,6 +20,7 @@ CREATE TABLE IF NOT EXISTS dev_traces (
   from_addr String,
   to_addr String,
   value_raw String,
+  is_precompile UInt8 DEFAULT 0,
   block_number UInt64,
   ts_millis Int64,
   INDEX idx_dev_traces_from from_addr TYPE bloom_filter GRANULARITY 2,
@@ -34,9 +36,17 @@ CREATE TABLE IF NOT EXISTS dev_transactions (
   to_addr String,
   value_raw String,
   gas_used UInt64,
+  gas_price_raw String DEFAULT '',
   status UInt8,
+  receipt_missing UInt8 DEFAULT 0,
   input_method String,
+  input_kind String DEFAULT '',
+  init_code_hash String DEFAULT '',
+  created_contract String DEFAULT '',
+  access_list_count UInt32 DEFAULT 0,
+  access_list_storage_keys UInt32 DEFAULT 0,
   is_internal UInt8,
+  is_precompile UInt8 DEFAULT 0,
   trace_id String,
   INDEX idx_dev_tx_from from_addr TYPE bloom_filter GRANULARITY 2,
   INDEX idx_dev_tx_to to_addr TYPE bloom_filter GRANULARITY 2,
@@ -54,8 +64,13 @@ CREATE TABLE IF NOT EXISTS dev_token_transfers (
   token_id String,
   batch_ordinal UInt16 DEFAULT 0,
   standard String,
+  is_mint UInt8 DEFAULT 0,
+  is_burn UInt8 DEFAULT 0,
+  non_standard UInt8 DEFAULT 0,
+  suspicious UInt8 DEFAULT 0,
   block_number UInt64,
   ts_millis Int64,
+  value_usd Nullable(String),
   INDEX idx_dev_xfer_token token TYPE bloom_filter GRANULARITY 2,
   INDEX idx_dev_xfer_from from_addr TYPE bloom_filter GRANULARITY 2,
   INDEX idx_dev_xfer_to to_addr TYPE bloom_filter GRANULARITY 2,